@@ -1,9 +1,17 @@
 package app
 
 import (
+	"errors"
 	"fmt"
-	"gopkg.in/yaml.v3"
+	"net"
 	"os"
+	"strings"
+
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/graph"
+	"cmdb2neo/pkg/logging"
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
 )
 
 type Neo4j struct {
@@ -13,6 +21,16 @@ type Neo4j struct {
 	Database             string `yaml:"database"`
 	MaxConnectionPool    int    `yaml:"max_connection_pool_size"`
 	ConnectTimeoutSecond int    `yaml:"connect_timeout_second"`
+	// QueryTimeoutSecond 为单条查询（含结果遍历）的超时秒数，为 0 时使用默认的 30 秒。
+	QueryTimeoutSecond int `yaml:"query_timeout_second"`
+	// Routing 为 true 时按因果集群路由，读走副本、写走 leader，并在同步与 RCA 之间传递书签。
+	Routing bool `yaml:"routing"`
+	// HealthCheckIntervalSecond 为后台连通性检查的间隔秒数，为 0 时使用默认的 30 秒。
+	HealthCheckIntervalSecond int `yaml:"health_check_interval_second"`
+	// QueryCacheTTLSecond 大于 0 时缓存 RCA 只读查询结果的秒数，默认 0 即关闭；开启后同步写入的数据最多延迟该时长才会被读到。
+	QueryCacheTTLSecond int `yaml:"query_cache_ttl_second"`
+	// QueryCacheSize 为查询结果缓存的条数上限，为 0 时使用默认的 1024。
+	QueryCacheSize int `yaml:"query_cache_size"`
 }
 
 type Sync struct {
@@ -23,6 +41,35 @@ type Sync struct {
 	IntervalSeconds int        `yaml:"interval_seconds"`
 	JobCron         string     `yaml:"job_cron"`
 	Source          SyncSource `yaml:"source"`
+	// Streaming 开启后增量同步按页边拉边写，适用于超大规模数据。
+	Streaming bool `yaml:"streaming"`
+	// SnapshotDir 非空时保存上一次快照，增量同步只写入差异。
+	SnapshotDir string `yaml:"snapshot_dir"`
+	// FullResync 为 true 时忽略差异，每次全量 upsert 并按批次清理。
+	FullResync bool `yaml:"full_resync"`
+	// StrictValidation 为 true 时快照存在 error 级悬空引用即中止写入，否则只记录告警。
+	StrictValidation bool `yaml:"strict_validation"`
+	// AllowDelete 为 true 时同步才会删除图数据，也可通过命令行 --allow-delete 开启。
+	AllowDelete bool `yaml:"allow_delete"`
+	// HardDelete 为 true 时直接删除过期数据；默认仅标记墓碑，超过 TombstoneRetentionHours 后清除。
+	HardDelete              bool `yaml:"hard_delete"`
+	TombstoneRetentionHours int  `yaml:"tombstone_retention_hours"`
+	// MaxDeleteRatio 为单个标签单次允许删除的最大比例，默认 0.2，>=1 表示不限制；
+	// 删除数量低于 DeleteGuardMinCount（默认 10）的标签不受比例限制。
+	MaxDeleteRatio      float64 `yaml:"max_delete_ratio"`
+	DeleteGuardMinCount int     `yaml:"delete_guard_min_count"`
+	// BatchTransactional 为 true 时单次节点或关系写入在同一事务中完成，失败整体回滚；大规模初始化时注意内存。
+	BatchTransactional bool `yaml:"batch_transactional"`
+	// DistributedLock 为 true 时定时任务先在 Neo4j 中获取租约锁，多副本部署时只有一个副本执行；
+	// LockTTLSeconds 为租期，默认 7200，应长于单次同步耗时。
+	DistributedLock bool `yaml:"distributed_lock"`
+	LockTTLSeconds  int  `yaml:"lock_ttl_seconds"`
+	// JobRetry 为定时任务层面的重试：同步整体失败且错误为 Neo4j 或 CMDB 暂时不可用时，
+	// 在本次调度内按该配置重跑，attempts 不大于 1 时不重跑；与流程内的 retry 相互独立。
+	JobRetry Retry `yaml:"job_retry"`
+	// FlowRetry 为增量同步流程整体失败后的重跑，attempts 不大于 1 时不重跑；retry 只作用于单次 CMDB 请求，
+	// 两者分开配置，避免 CMDB 不可用时每次重跑再叠加一轮请求重试。
+	FlowRetry Retry `yaml:"flow_retry"`
 }
 
 type Retry struct {
@@ -32,25 +79,117 @@ type Retry struct {
 
 type HTTP struct {
 	Listen string `yaml:"listen"`
+	// TrustedProxies 为可信反向代理的 IP 或 CIDR，只有来自这些地址的 X-Forwarded-For 才用于识别客户端 IP；默认为空，不信任任何代理。
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// RCA 限制根因分析接口的请求规模与频率。
+	RCA RCALimits `yaml:"rca"`
+	// Topology 限制拓扑邻域查询接口的范围。
+	Topology TopologyLimits `yaml:"topology"`
+}
+
+// RCALimits 为根因分析接口的请求限制，字段为 0 时使用默认值。
+type RCALimits struct {
+	// MaxEvents 为单次请求允许的最大告警条数（多窗口时为各窗口之和），默认 5000。
+	MaxEvents int `yaml:"max_events"`
+	// MaxBodyBytes 为请求体的最大字节数，默认 10 MiB。
+	MaxBodyBytes int `yaml:"max_body_bytes"`
+	// RateLimitPerSecond 为每个客户端 IP 每秒补充的请求令牌数，默认 10；为负数时关闭限流。
+	RateLimitPerSecond float64 `yaml:"rate_limit_per_second"`
+	// RateLimitBurst 为每个客户端 IP 的令牌桶容量，默认 20。
+	RateLimitBurst int `yaml:"rate_limit_burst"`
+	// MaxAsyncJobs 为同时未结束的异步分析任务上限，超出时返回 429，默认 4。
+	MaxAsyncJobs int `yaml:"max_async_jobs"`
+}
+
+// TopologyLimits 为拓扑邻域查询接口的范围限制，字段为 0 时使用默认值。
+type TopologyLimits struct {
+	// MaxNodes 为单次查询返回的最大节点数，默认 200。
+	MaxNodes int `yaml:"max_nodes"`
+	// MaxDepth 为可查询的最大跳数，默认且最大为 4。
+	MaxDepth int `yaml:"max_depth"`
+}
+
+// Log 控制日志输出。
+type Log struct {
+	// Level 为 debug、info、warn、error 之一，默认 info；debug 时额外输出每条 Neo4j 查询与正常的探针请求。
+	Level string `yaml:"level"`
+}
+
+// GraphSchema 覆盖图中使用的标签、关系类型与 cmdb_key 前缀，键为默认名称（如 App、DEPLOYED_ON），
+// 未指定的沿用默认值；改名后需重新 init 或迁移已有数据。
+type GraphSchema struct {
+	Labels map[string]string `yaml:"labels"`
+	Rels   map[string]string `yaml:"rels"`
+	// KeyPrefixes 的键为实体标签，如 App: APP。
+	KeyPrefixes map[string]string `yaml:"key_prefixes"`
+}
+
+// Build 校验并构建 domain.Schema。
+func (s GraphSchema) Build() (domain.Schema, error) {
+	return domain.NewSchema(s.Labels, s.Rels, s.KeyPrefixes)
+}
+
+// RCA 为根因分析拓扑查询的运行参数。
+type RCA struct {
+	// PartitionRefreshSeconds 为重建网络分区 CIDR 索引的间隔秒数，为 0 时使用默认的 300 秒，为负数时只在启动时加载一次。
+	PartitionRefreshSeconds int `yaml:"partition_refresh_seconds"`
+	// ChainCacheSize 与 ChainCacheTTLSeconds 控制拓扑链路缓存的条数与有效期，任一为 0 时关闭缓存；
+	// 开启后拓扑变更最多延迟一个 TTL 生效。
+	ChainCacheSize       int `yaml:"chain_cache_size"`
+	ChainCacheTTLSeconds int `yaml:"chain_cache_ttl_seconds"`
+	// ReportLayerConflicts 为 true 时按 IP 解析的事件额外查询该 IP 命中的全部承载层，命中多层时记录告警；
+	// 每个事件多一次查询，默认关闭，排查 CMDB 数据问题时再开启。
+	ReportLayerConflicts bool `yaml:"report_layer_conflicts"`
 }
 
 type Config struct {
-	Neo4j Neo4j `yaml:"neo4j"`
-	Sync  Sync  `yaml:"sync"`
-	HTTP  HTTP  `yaml:"http"`
+	Neo4j  Neo4j       `yaml:"neo4j"`
+	Sync   Sync        `yaml:"sync"`
+	HTTP   HTTP        `yaml:"http"`
+	RCA    RCA         `yaml:"rca"`
+	Log    Log         `yaml:"log"`
+	Schema GraphSchema `yaml:"schema"`
 }
 
 type SyncSource struct {
-	BaseURL      string `yaml:"base_url"`
-	SnapshotAPI  string `yaml:"snapshot_api"`
+	BaseURL     string `yaml:"base_url"`
+	SnapshotAPI string `yaml:"snapshot_api"`
+	// PartitionAPI 为可选的网络分区 CIDR 接口，按 idc 查询，为空时不调用。
+	PartitionAPI string `yaml:"partition_api"`
 	AuthHeader   string `yaml:"auth_header"`
+	// AuthScheme 为 auth_header 中 token 前的前缀，为空时取 Bearer，none 表示直接发送 token。
+	AuthScheme   string `yaml:"auth_scheme"`
 	StaticToken  string `yaml:"static_token"`
 	AuthEndpoint string `yaml:"auth_endpoint"`
+	// Username/Password 与 ClientID/ClientSecret 二选一：配置了 client_id 时按 OAuth2 client credentials 换取 token。
 	Username     string `yaml:"username"`
 	Password     string `yaml:"password"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// Scope 为 client credentials 模式可选的 scope，多个以空格分隔。
+	Scope string `yaml:"scope"`
+	// IDCs 为需要同步的机房名称列表，使用 HTTP 数据源时必填。
+	IDCs []string `yaml:"idcs"`
+	// Pagination 为 offset（默认）或 cursor。
+	Pagination string `yaml:"pagination"`
+	// MaxInvalidRatio 为 CMDB 记录中未知 server_type、缺少 id 或 ip 的最大允许占比，超过时本次同步失败；<=0 时只告警。
+	MaxInvalidRatio float64 `yaml:"max_invalid_ratio"`
+	// FileDir 在 BaseURL 为空时生效，从该目录读取 JSON 快照。
+	FileDir string `yaml:"file_dir"`
+	// TLS 同时用于 CMDB 接口与认证接口，均未配置时使用系统默认。
+	TLS SourceTLS `yaml:"tls"`
 }
 
-// LoadConfig 从文件加载配置。
+// SourceTLS 配置访问 CMDB 的 mTLS 客户端证书与 CA。
+type SourceTLS struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	CAFile   string `yaml:"ca_file"`
+	// InsecureSkipVerify 跳过服务端证书校验，仅用于开发环境。
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+}
+
+// LoadConfig 从文件加载配置，展开 ${VAR} 引用并应用 CMDB2NEO_ 前缀的环境变量覆盖后校验。
 func LoadConfig(path string) (*Config, error) {
 	cfg := new(Config)
 	data, err := os.ReadFile(path)
@@ -60,5 +199,104 @@ func LoadConfig(path string) (*Config, error) {
 	if err := yaml.Unmarshal(data, cfg); err != nil {
 		return nil, fmt.Errorf("解析配置失败: %w", err)
 	}
+	if err := expandEnv(cfg); err != nil {
+		return nil, fmt.Errorf("展开配置中的环境变量失败 %s: %w", path, err)
+	}
+	if err := applyEnvOverrides(cfg); err != nil {
+		return nil, fmt.Errorf("应用环境变量覆盖失败: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("配置校验失败 %s: %w", path, err)
+	}
 	return cfg, nil
 }
+
+// Validate 校验必填项与取值范围，返回汇总所有问题的错误，每条错误以 YAML 路径开头。
+func (c *Config) Validate() error {
+	var errs []error
+	if strings.TrimSpace(c.Neo4j.URI) == "" {
+		errs = append(errs, errors.New("neo4j.uri 不能为空"))
+	}
+	if strings.TrimSpace(c.Neo4j.Username) == "" {
+		errs = append(errs, errors.New("neo4j.username 不能为空"))
+	}
+	if c.Neo4j.Routing && strings.TrimSpace(c.Neo4j.URI) != "" {
+		if _, err := graph.RoutingURI(c.Neo4j.URI, true); err != nil {
+			errs = append(errs, fmt.Errorf("neo4j.routing 开启时 %v", err))
+		}
+	}
+	if c.Neo4j.QueryTimeoutSecond < 0 {
+		errs = append(errs, fmt.Errorf("neo4j.query_timeout_second 不能为负数，当前为 %d", c.Neo4j.QueryTimeoutSecond))
+	}
+	if c.Neo4j.HealthCheckIntervalSecond < 0 {
+		errs = append(errs, fmt.Errorf("neo4j.health_check_interval_second 不能为负数，当前为 %d", c.Neo4j.HealthCheckIntervalSecond))
+	}
+	if c.Neo4j.QueryCacheTTLSecond < 0 {
+		errs = append(errs, fmt.Errorf("neo4j.query_cache_ttl_second 不能为负数，当前为 %d", c.Neo4j.QueryCacheTTLSecond))
+	}
+	if c.Neo4j.QueryCacheSize < 0 {
+		errs = append(errs, fmt.Errorf("neo4j.query_cache_size 不能为负数，当前为 %d", c.Neo4j.QueryCacheSize))
+	}
+	if c.RCA.ChainCacheSize < 0 {
+		errs = append(errs, fmt.Errorf("rca.chain_cache_size 不能为负数，当前为 %d", c.RCA.ChainCacheSize))
+	}
+	if c.RCA.ChainCacheTTLSeconds < 0 {
+		errs = append(errs, fmt.Errorf("rca.chain_cache_ttl_seconds 不能为负数，当前为 %d", c.RCA.ChainCacheTTLSeconds))
+	}
+	for _, proxy := range c.HTTP.TrustedProxies {
+		if !validProxy(proxy) {
+			errs = append(errs, fmt.Errorf("http.trusted_proxies 中的 %q 不是合法的 IP 或 CIDR", proxy))
+		}
+	}
+	if c.HTTP.RCA.MaxEvents < 0 {
+		errs = append(errs, fmt.Errorf("http.rca.max_events 不能为负数，当前为 %d", c.HTTP.RCA.MaxEvents))
+	}
+	if c.HTTP.RCA.MaxBodyBytes < 0 {
+		errs = append(errs, fmt.Errorf("http.rca.max_body_bytes 不能为负数，当前为 %d", c.HTTP.RCA.MaxBodyBytes))
+	}
+	if c.HTTP.RCA.RateLimitBurst < 0 {
+		errs = append(errs, fmt.Errorf("http.rca.rate_limit_burst 不能为负数，当前为 %d", c.HTTP.RCA.RateLimitBurst))
+	}
+	if c.HTTP.RCA.MaxAsyncJobs < 0 {
+		errs = append(errs, fmt.Errorf("http.rca.max_async_jobs 不能为负数，当前为 %d", c.HTTP.RCA.MaxAsyncJobs))
+	}
+	if c.HTTP.Topology.MaxNodes < 0 {
+		errs = append(errs, fmt.Errorf("http.topology.max_nodes 不能为负数，当前为 %d", c.HTTP.Topology.MaxNodes))
+	}
+	if c.HTTP.Topology.MaxDepth < 0 || c.HTTP.Topology.MaxDepth > 4 {
+		errs = append(errs, fmt.Errorf("http.topology.max_depth 必须在 0 到 4 之间，当前为 %d", c.HTTP.Topology.MaxDepth))
+	}
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log.level %v", err))
+	}
+	if _, err := c.Schema.Build(); err != nil {
+		errs = append(errs, fmt.Errorf("schema %v", err))
+	}
+	if c.Sync.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("sync.batch_size 必须为正数，当前为 %d", c.Sync.BatchSize))
+	}
+	if c.Sync.IntervalSeconds < 0 {
+		errs = append(errs, fmt.Errorf("sync.interval_seconds 不能为负数，当前为 %d", c.Sync.IntervalSeconds))
+	}
+	if spec := strings.TrimSpace(c.Sync.JobCron); spec != "" {
+		if _, err := cron.ParseStandard(spec); err != nil {
+			errs = append(errs, fmt.Errorf("sync.job_cron %q 不是合法的 cron 表达式: %v", spec, err))
+		}
+	}
+	if c.Sync.LockTTLSeconds < 0 {
+		errs = append(errs, fmt.Errorf("sync.lock_ttl_seconds 不能为负数，当前为 %d", c.Sync.LockTTLSeconds))
+	}
+	if c.Sync.InitialResync && strings.TrimSpace(c.Sync.Source.BaseURL) == "" && strings.TrimSpace(c.Sync.Source.FileDir) == "" {
+		errs = append(errs, errors.New("sync.initial_resync 开启时 sync.source.base_url 与 sync.source.file_dir 不能同时为空"))
+	}
+	return errors.Join(errs...)
+}
+
+// validProxy 判断可信代理配置是否为合法的 IP 或 CIDR。
+func validProxy(proxy string) bool {
+	if net.ParseIP(proxy) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(proxy)
+	return err == nil
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cmdb2neo/internal/domain"
+	"go.uber.org/zap"
+)
+
+// 删除保护的默认阈值。
+const (
+	defaultMaxDeleteRatio      = 0.2
+	defaultDeleteGuardMinCount = 10
+)
+
+// ErrDeleteGuard 表示本次删除比例异常，多半是 CMDB 返回了空或截断的快照，重跑无法自愈，不参与流程重试。
+var ErrDeleteGuard = errors.New("删除比例超过阈值")
+
+// DeleteGuard 在删除前按标签检查待删除比例，防止异常快照清空图数据。
+type DeleteGuard struct {
+	// MaxRatio 为单个标签允许删除的最大比例，<=0 时取默认值，>=1 表示不限制。
+	MaxRatio float64
+	// MinCount 为触发保护的最小删除数量，删除量低于该值的标签不受比例限制，<=0 时取默认值。
+	MinCount int
+}
+
+func (g DeleteGuard) limits() (float64, int) {
+	ratio, minCount := g.MaxRatio, g.MinCount
+	if ratio <= 0 {
+		ratio = defaultMaxDeleteRatio
+	}
+	if minCount <= 0 {
+		minCount = defaultDeleteGuardMinCount
+	}
+	return ratio, minCount
+}
+
+// Check 记录每个标签的删除比例，任一标签比例超过 MaxRatio 且删除数量不低于 MinCount 时返回 ErrDeleteGuard。
+func (g DeleteGuard) Check(logger *zap.Logger, counts []domain.LabelCount) error {
+	maxRatio, minCount := g.limits()
+	var tripped []string
+	for _, c := range counts {
+		if c.Total <= 0 {
+			continue
+		}
+		ratio := float64(c.Stale) / float64(c.Total)
+		if logger != nil {
+			logger.Info("待删除比例",
+				zap.String("label", c.Label),
+				zap.Int("stale", c.Stale),
+				zap.Int("total", c.Total),
+				zap.Float64("ratio", ratio),
+				zap.Float64("max_ratio", maxRatio))
+		}
+		if ratio > maxRatio && c.Stale >= minCount {
+			tripped = append(tripped, fmt.Sprintf("%s %d/%d(%.0f%%)", c.Label, c.Stale, c.Total, ratio*100))
+		}
+	}
+	if len(tripped) == 0 {
+		return nil
+	}
+	sort.Strings(tripped)
+	return fmt.Errorf("%w %.0f%%: %s", ErrDeleteGuard, maxRatio*100, strings.Join(tripped, ", "))
+}
+
+// countRemoved 按主标签统计差异中待删除的节点占上一快照的比例。
+func countRemoved(prev []domain.NodeRow, removed []domain.NodeRow) []domain.LabelCount {
+	index := make(map[string]*domain.LabelCount)
+	var order []string
+	get := func(row domain.NodeRow) *domain.LabelCount {
+		label := ""
+		if len(row.Labels) > 0 {
+			label = row.Labels[0]
+		}
+		c, ok := index[label]
+		if !ok {
+			c = &domain.LabelCount{Label: label}
+			index[label] = c
+			order = append(order, label)
+		}
+		return c
+	}
+	for _, row := range prev {
+		get(row).Total++
+	}
+	for _, row := range removed {
+		get(row).Stale++
+	}
+	counts := make([]domain.LabelCount, 0, len(order))
+	for _, label := range order {
+		counts = append(counts, *index[label])
+	}
+	return counts
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/loader"
+)
+
+// NodeWriter 抽象节点写入，默认由 loader.NodeUpserter 实现，便于测试替换。
+type NodeWriter interface {
+	InitNodes(ctx context.Context, rows []domain.NodeRow) (loader.WriteStats, error)
+	UpsertNodes(ctx context.Context, rows []domain.NodeRow) (loader.WriteStats, error)
+}
+
+// RelWriter 抽象关系写入，默认由 loader.RelUpserter 实现。
+type RelWriter interface {
+	InitRels(ctx context.Context, rows []domain.RelRow) (loader.WriteStats, error)
+	UpsertRels(ctx context.Context, rows []domain.RelRow) (loader.WriteStats, error)
+}
+
+// EdgeRepairer 抽象补边步骤，默认由 loader.EdgeFixer 实现，返回本次新建与恢复的边数。
+type EdgeRepairer interface {
+	Run(ctx context.Context, runID string) (loader.EdgeFixReport, error)
+}
+
+// OrphanRepairer 抽象孤儿节点修复，默认由 loader.OrphanReconciler 实现，返回各标签的孤儿数与修复数。
+type OrphanRepairer interface {
+	Run(ctx context.Context, runID string) (loader.OrphanReport, error)
+}
+
+// StaleCleaner 抽象过期数据清理，默认由 loader.Cleaner 实现。
+type StaleCleaner interface {
+	HardDeleteRelationships(ctx context.Context, retentionRunID string) error
+	HardDeleteNodes(ctx context.Context, retentionRunID string) error
+	SoftDeleteRelationships(ctx context.Context, retentionRunID string) error
+	SoftDeleteNodes(ctx context.Context, retentionRunID string) error
+	CountStale(ctx context.Context, retentionRunID string) ([]domain.LabelCount, error)
+}
+
+// TombstonePurger 清除超过保留期的墓碑，默认由 loader.Cleaner 实现。
+type TombstonePurger interface {
+	PurgeTombstones(ctx context.Context, retention time.Duration) error
+}
+
+// KeyDeleter 按 key 精确删除或软删除节点与关系，增量同步移除下线实体时使用，默认由 loader.Cleaner 实现。
+type KeyDeleter interface {
+	DeleteNodes(ctx context.Context, rows []domain.NodeRow) error
+	DeleteRelationships(ctx context.Context, rows []domain.RelRow) error
+	TombstoneNodes(ctx context.Context, rows []domain.NodeRow) error
+	TombstoneRelationships(ctx context.Context, rows []domain.RelRow) error
+}
+
+// SchemaEnsurer 抽象 schema 初始化，默认由 loader.SchemaManager 实现。
+type SchemaEnsurer interface {
+	Ensure(ctx context.Context) (loader.SchemaReport, error)
+}
+
+// GraphResetter 清空图中的 CMDB 数据，默认由 loader.SchemaManager 实现，回放 --wipe 使用。
+type GraphResetter interface {
+	Reset(ctx context.Context, opts loader.ResetOptions) error
+}
+
+// GraphStateReader 读取图中现有的节点与关系，默认由 loader.StateReader 实现，对账使用。
+type GraphStateReader interface {
+	Nodes(ctx context.Context) ([]domain.NodeRow, error)
+	Relationships(ctx context.Context) ([]domain.RelRow, error)
+}
+
+// GraphReader 抽象只读查询，默认由 loader.Client 实现，一致性检查使用。
+type GraphReader interface {
+	RunRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error)
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+	"go.uber.org/zap"
+)
+
+// ErrSnapshotEmpty 表示 CMDB 返回的快照没有任何机器与应用而图中已有数据，多半是数据源故障，重跑无法自愈，不参与流程重试。
+var ErrSnapshotEmpty = errors.New("CMDB 快照为空")
+
+// snapshotEntities 返回快照中的机器与应用数；机房与网络分区常由配置补齐，不计入。
+func snapshotEntities(snapshot cmdb.Snapshot) int {
+	return len(snapshot.HostMachines) + len(snapshot.PhysicalMachines) + len(snapshot.VirtualMachines) + len(snapshot.Containers) + len(snapshot.Apps)
+}
+
+// graphEntities 按标签统计图中现存的机器与应用数，与 snapshotEntities 口径一致。
+func graphEntities(counts []domain.LabelCount) int {
+	total := 0
+	for _, c := range counts {
+		if c.Label == domain.LabelIDC || c.Label == domain.LabelNetPartition {
+			continue
+		}
+		total += c.Total
+	}
+	return total
+}
+
+// checkEmptySnapshot 在快照没有任何机器与应用时对比图中现有数据，图非空则返回 ErrSnapshotEmpty，须在任何删除之前调用。
+func (f *SyncFlow) checkEmptySnapshot(ctx context.Context, runID string, entities int) error {
+	if entities > 0 {
+		return nil
+	}
+	counts, err := f.Cleaner.CountStale(ctx, runID)
+	if err != nil {
+		return err
+	}
+	return rejectEmptySnapshot(f.Logger, runID, entities, counts)
+}
+
+// rejectEmptySnapshot 在快照没有机器与应用而 counts 统计的图中已有数据时返回 ErrSnapshotEmpty。
+func rejectEmptySnapshot(logger *zap.Logger, runID string, entities int, counts []domain.LabelCount) error {
+	if entities > 0 {
+		return nil
+	}
+	existing := graphEntities(counts)
+	if existing == 0 {
+		return nil
+	}
+	if logger != nil {
+		fields := []zap.Field{zap.String("run_id", runID), zap.Int("snapshot", entities), zap.Int("graph", existing)}
+		for _, c := range counts {
+			fields = append(fields, zap.Int(c.Label, c.Total))
+		}
+		logger.Error("CMDB 快照为空而图中已有数据，中止同步", fields...)
+	}
+	return fmt.Errorf("%w: 图中现有 %d 个机器与应用节点", ErrSnapshotEmpty, existing)
+}
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix 为环境变量覆盖配置的前缀，如 CMDB2NEO_NEO4J_URI 覆盖 neo4j.uri。
+const EnvPrefix = "CMDB2NEO_"
+
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv 将字符串字段中的 ${VAR} 替换为环境变量的值，引用未设置的变量时返回包含变量名与 YAML 路径的错误。
+func expandEnv(cfg *Config) error {
+	var errs []error
+	walkConfig(reflect.ValueOf(cfg).Elem(), "", func(path string, field reflect.Value) {
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(expandString(path, field.String(), &errs))
+		case reflect.Slice:
+			if field.Type().Elem().Kind() != reflect.String {
+				return
+			}
+			for i := 0; i < field.Len(); i++ {
+				item := field.Index(i)
+				item.SetString(expandString(fmt.Sprintf("%s[%d]", path, i), item.String(), &errs))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+func expandString(path, value string, errs *[]error) string {
+	return envRef.ReplaceAllStringFunc(value, func(ref string) string {
+		name := envRef.FindStringSubmatch(ref)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			*errs = append(*errs, fmt.Errorf("%s 引用的环境变量 %s 未设置", path, name))
+		}
+		return v
+	})
+}
+
+// applyEnvOverrides 用 CMDB2NEO_ 前缀的环境变量覆盖对应字段，变量名为 YAML 路径转大写、点号换成下划线，
+// 列表字段以逗号分隔。覆盖值按字面使用，不再展开 ${VAR}。
+func applyEnvOverrides(cfg *Config) error {
+	var errs []error
+	walkConfig(reflect.ValueOf(cfg).Elem(), "", func(path string, field reflect.Value) {
+		name := EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if err := setField(field, raw); err != nil {
+			errs = append(errs, fmt.Errorf("环境变量 %s 无法覆盖 %s: %w", name, path, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+func setField(field reflect.Value, raw string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int:
+		v, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(v))
+	case reflect.Bool:
+		v, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return err
+		}
+		field.SetBool(v)
+	case reflect.Float64:
+		v, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(v)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", field.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// walkConfig 按 yaml 标签深度遍历配置结构体，对每个叶子字段回调其 YAML 路径。
+func walkConfig(v reflect.Value, prefix string, fn func(path string, field reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		path := tag
+		if prefix != "" {
+			path = prefix + "." + tag
+		}
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			walkConfig(field, path, fn)
+			continue
+		}
+		fn(path, field)
+	}
+}
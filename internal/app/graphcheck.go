@@ -0,0 +1,158 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cmdb2neo/internal/domain"
+)
+
+// defaultSampleSize 为每项检查默认返回的样例 key 数量。
+const defaultSampleSize = 10
+
+// 软删除的墓碑不参与检查：节点 n 需存活，关联的关系 r 与对端 c 也需存活。
+const (
+	liveNode = "coalesce(n.deleted, false) = false"
+	liveEdge = "coalesce(r.deleted, false) = false AND coalesce(c.deleted, false) = false"
+)
+
+// GraphCheck 描述一条图结构不变量。Match 为 Cypher 片段，需将违反不变量的节点绑定为 n。
+type GraphCheck struct {
+	Name        string
+	Description string
+	Match       string
+}
+
+// query 统一包装计数与样例收集，检查只需关心匹配条件。
+func (c GraphCheck) query() string {
+	return c.Match + `
+WITH n ORDER BY n.cmdb_key
+RETURN count(n) AS count, collect(n.cmdb_key)[..$limit] AS samples`
+}
+
+// DefaultGraphChecks 返回默认 Schema 下内置的一致性检查，调用方可在此基础上追加。
+func DefaultGraphChecks() []GraphCheck {
+	return GraphChecksFor(domain.DefaultSchema())
+}
+
+// GraphChecksFor 按 schema 中的实际标签与关系类型生成内置的一致性检查。
+func GraphChecksFor(schema domain.Schema) []GraphCheck {
+	label := func(canonical ...string) string {
+		return strings.Join(schema.Labels(canonical), "|")
+	}
+	rel := func(canonical ...string) string {
+		out := make([]string, len(canonical))
+		for i, name := range canonical {
+			out[i] = schema.Rel(name)
+		}
+		return strings.Join(out, "|")
+	}
+	return []GraphCheck{
+		{
+			Name:        "idc_without_partition",
+			Description: "机房下没有任何网络分区",
+			Match:       "MATCH (n:" + label(domain.LabelIDC) + ") WHERE " + liveNode + " AND NOT EXISTS { (n)-[r:" + rel(domain.RelHasPartition) + "]->(c:" + label(domain.LabelNetPartition) + ") WHERE " + liveEdge + " }",
+		},
+		{
+			Name:        "partition_without_machine",
+			Description: "网络分区下没有任何宿主机或物理机",
+			Match:       "MATCH (n:" + label(domain.LabelNetPartition) + ") WHERE " + liveNode + " AND NOT EXISTS { (n)-[r:" + rel(domain.RelHasHost, domain.RelHasPhysical) + "]->(c:" + label(domain.LabelMachine) + ") WHERE " + liveEdge + " }",
+		},
+		{
+			Name:        "vm_without_host",
+			Description: "虚拟机没有 HOSTS_VM 上游宿主机或物理机",
+			Match:       "MATCH (n:" + label(domain.LabelVirtualMachine) + ") WHERE " + liveNode + " AND NOT EXISTS { (c:" + label(domain.LabelMachine) + ")-[r:" + rel(domain.RelHostsVM) + "]->(n) WHERE " + liveEdge + " }",
+		},
+		{
+			Name:        "container_without_host",
+			Description: "容器没有 RUNS_ON 到任何虚拟机或宿主机",
+			Match:       "MATCH (n:" + label(domain.LabelContainer) + ") WHERE " + liveNode + " AND NOT EXISTS { (n)-[r:" + rel(domain.RelRunsOn) + "]->(c:" + label(domain.LabelVirtualMachine, domain.LabelHostMachine) + ") WHERE " + liveEdge + " }",
+		},
+		{
+			Name:        "app_not_deployed",
+			Description: "应用没有 DEPLOYED_ON 到任何机器",
+			Match:       "MATCH (n:" + label(domain.LabelApp) + ") WHERE " + liveNode + " AND NOT EXISTS { (n)-[r:" + rel(domain.RelAppDeploy) + "]->(c:" + label(domain.LabelCompute) + ") WHERE " + liveEdge + " }",
+		},
+	}
+}
+
+// GraphCheckResult 为单项检查的结果。
+type GraphCheckResult struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Count       int      `json:"count"`
+	Samples     []string `json:"samples,omitempty"`
+}
+
+// GraphReport 汇总一次一致性检查的结果。
+type GraphReport struct {
+	Checks []GraphCheckResult `json:"checks"`
+	// Violations 为所有检查发现的问题节点总数。
+	Violations int `json:"violations"`
+}
+
+// OK 表示所有检查均通过。
+func (r GraphReport) OK() bool {
+	return r.Violations == 0
+}
+
+// GraphValidator 依次执行检查并生成报告。
+type GraphValidator struct {
+	Reader GraphReader
+	// Schema 为图中实际使用的标签与关系类型，零值为默认 Schema。
+	Schema domain.Schema
+	// Checks 为空时按 Schema 使用 GraphChecksFor 生成的内置检查。
+	Checks []GraphCheck
+	// SampleSize 为每项检查返回的样例数量，<=0 时使用默认值。
+	SampleSize int
+}
+
+// Run 执行全部检查，任一查询失败即返回错误。
+func (v *GraphValidator) Run(ctx context.Context) (GraphReport, error) {
+	if v == nil || v.Reader == nil {
+		return GraphReport{}, fmt.Errorf("graph validator 依赖未注入完整")
+	}
+	checks := v.Checks
+	if len(checks) == 0 {
+		checks = GraphChecksFor(v.Schema)
+	}
+	limit := v.SampleSize
+	if limit <= 0 {
+		limit = defaultSampleSize
+	}
+
+	report := GraphReport{Checks: make([]GraphCheckResult, 0, len(checks))}
+	for _, check := range checks {
+		rows, err := v.Reader.RunRead(ctx, check.query(), map[string]any{"limit": limit})
+		if err != nil {
+			return GraphReport{}, fmt.Errorf("执行检查 %s 失败: %w", check.Name, err)
+		}
+		result := GraphCheckResult{Name: check.Name, Description: check.Description}
+		if len(rows) > 0 {
+			result.Count = toInt(rows[0]["count"])
+			if samples, ok := rows[0]["samples"].([]any); ok {
+				for _, s := range samples {
+					if key, ok := s.(string); ok {
+						result.Samples = append(result.Samples, key)
+					}
+				}
+			}
+		}
+		report.Violations += result.Count
+		report.Checks = append(report.Checks, result)
+	}
+	return report, nil
+}
+
+func toInt(v any) int {
+	switch n := v.(type) {
+	case int64:
+		return int(n)
+	case int:
+		return n
+	case float64:
+		return int(n)
+	}
+	return 0
+}
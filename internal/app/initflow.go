@@ -3,20 +3,35 @@ package app
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"cmdb2neo/internal/cmdb"
-	"cmdb2neo/internal/loader"
+	"cmdb2neo/internal/metrics"
 	"go.uber.org/zap"
 )
 
 // InitFlow 负责首跑初始化：建 schema -> 写节点 -> 写关系 -> 补边。
 type InitFlow struct {
 	CMDB   cmdb.Client
-	Schema *loader.SchemaManager
-	Nodes  *loader.NodeUpserter
-	Rels   *loader.RelUpserter
-	Fixer  *loader.EdgeFixer
+	Schema SchemaEnsurer
+	Nodes  NodeWriter
+	Rels   RelWriter
+	Fixer  EdgeRepairer
 	Logger *zap.Logger
+	// Progress 可选，用于上报各阶段进度。
+	Progress ProgressFunc
+	// StrictValidation 为 true 时快照存在 error 级悬空引用即中止初始化。
+	StrictValidation bool
+	// Metrics 可选，记录快照拉取耗时、写入数量与初始化结果。
+	Metrics metrics.Recorder
+	// Mapping 可选，映射快照时传给 cmdb.BuildInitRows，如 cmdb.WithSchema 按配置的前缀生成 cmdb_key。
+	Mapping []cmdb.MapperOption
+}
+
+func (f *InitFlow) report(stage string, counts map[string]int) {
+	if f.Progress != nil {
+		f.Progress(stage, counts)
+	}
 }
 
 // Run 执行初始化流程。
@@ -27,32 +42,54 @@ func (f *InitFlow) Run(ctx context.Context) error {
 	if f.Logger == nil {
 		f.Logger = zap.NewNop()
 	}
+	start := time.Now()
+	err := f.run(ctx)
+	metrics.OrNop(f.Metrics).ObserveSync("init", time.Since(start), err)
+	return err
+}
 
-	snapshot, err := f.CMDB.FetchSnapshot(ctx)
+func (f *InitFlow) run(ctx context.Context) error {
+	f.report("fetch", nil)
+	snapshot, err := fetchSnapshot(ctx, f.CMDB, f.Metrics)
 	if err != nil {
 		return fmt.Errorf("拉取 CMDB 快照失败: %w", err)
 	}
-	f.Logger.Info("加载 CMDB 快照", zap.Int("idc", len(snapshot.IDCs)), zap.Int("np", len(snapshot.NetworkPartitions)), zap.Int("host", len(snapshot.HostMachines)), zap.Int("physical", len(snapshot.PhysicalMachines)), zap.Int("vm", len(snapshot.VirtualMachines)), zap.Int("app", len(snapshot.Apps)))
+	f.Logger.Info("加载 CMDB 快照", zap.Int("idc", len(snapshot.IDCs)), zap.Int("np", len(snapshot.NetworkPartitions)), zap.Int("host", len(snapshot.HostMachines)), zap.Int("physical", len(snapshot.PhysicalMachines)), zap.Int("vm", len(snapshot.VirtualMachines)), zap.Int("container", len(snapshot.Containers)), zap.Int("app", len(snapshot.Apps)))
+
+	if err := validateSnapshot(f.Logger, snapshot, f.StrictValidation); err != nil {
+		return err
+	}
 
-	nodes, rels := cmdb.BuildInitRows(snapshot)
+	nodes, rels := cmdb.BuildInitRows(snapshot, f.Mapping...)
 
 	if f.Schema != nil {
-		if err := f.Schema.Ensure(ctx); err != nil {
+		f.report("schema", nil)
+		schema, err := f.Schema.Ensure(ctx)
+		if err != nil {
 			return err
 		}
+		f.Logger.Info("schema 已就绪", zap.Strings("created", schema.Created), zap.Int("existing", len(schema.Existing)))
 	}
 
-	if err := f.Nodes.InitNodes(ctx, nodes); err != nil {
+	f.report("nodes", map[string]int{"nodes": len(nodes), "rels": len(rels)})
+	totals := writeTotals{payload: snapshot.Payload}
+	if totals.nodes, err = f.Nodes.InitNodes(ctx, nodes); err != nil {
 		return err
 	}
-	if err := f.Rels.InitRels(ctx, rels); err != nil {
+	f.report("rels", nil)
+	if totals.rels, err = f.Rels.InitRels(ctx, rels); err != nil {
 		return err
 	}
 	if f.Fixer != nil {
-		if err := f.Fixer.Run(ctx, snapshot.RunID); err != nil {
+		f.report("fix_edges", nil)
+		fixed, err := f.Fixer.Run(ctx, snapshot.RunID)
+		if err != nil {
 			return err
 		}
+		f.Logger.Info("补边完成", append([]zap.Field{zap.String("run_id", snapshot.RunID)}, fixFields(fixed)...)...)
 	}
-	f.Logger.Info("初始化同步完成")
+	observeUpserted(f.Metrics, "init", totals)
+	f.report("written", totals.counts())
+	f.Logger.Info("初始化同步完成", append([]zap.Field{zap.String("run_id", snapshot.RunID)}, totals.fields()...)...)
 	return nil
 }
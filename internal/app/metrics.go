@@ -0,0 +1,22 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/metrics"
+)
+
+// fetchSnapshot 拉取 CMDB 快照并记录拉取耗时。
+func fetchSnapshot(ctx context.Context, client cmdb.Client, recorder metrics.Recorder) (cmdb.Snapshot, error) {
+	start := time.Now()
+	snapshot, err := client.FetchSnapshot(ctx)
+	metrics.OrNop(recorder).ObserveCMDBFetch(time.Since(start), err)
+	return snapshot, err
+}
+
+// observeUpserted 记录一次流程写入的节点与关系数，新建与更新均计入。
+func observeUpserted(recorder metrics.Recorder, flow string, totals writeTotals) {
+	metrics.OrNop(recorder).ObserveUpserted(flow, totals.nodes.Created+totals.nodes.Updated, totals.rels.Created+totals.rels.Updated)
+}
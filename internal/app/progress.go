@@ -0,0 +1,213 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/loader"
+	"go.uber.org/zap"
+)
+
+// ErrSyncRunning 表示已有同步在执行。
+var ErrSyncRunning = errors.New("已有同步任务在执行")
+
+// ProgressFunc 由各 Flow 在阶段切换时回调，counts 为该阶段相关的计数。
+type ProgressFunc func(stage string, counts map[string]int)
+
+// writeTotals 累计一次流程中节点与关系的写入统计，用于完成日志。
+type writeTotals struct {
+	nodes loader.WriteStats
+	rels  loader.WriteStats
+	// payload 为本次快照中不符合约定的 CMDB 记录统计，有问题记录时才出现在日志与进度中
+	payload cmdb.PayloadStats
+}
+
+func (w writeTotals) fields() []zap.Field {
+	fields := []zap.Field{
+		zap.Int("nodes_created", w.nodes.Created),
+		zap.Int("nodes_updated", w.nodes.Updated),
+		zap.Int("rels_created", w.rels.Created),
+		zap.Int("rels_updated", w.rels.Updated),
+		zap.Int("batches", w.nodes.Batches+w.rels.Batches),
+	}
+	if w.payload.Invalid > 0 {
+		fields = append(fields,
+			zap.Int("records_invalid", w.payload.Invalid),
+			zap.Any("unknown_server_types", w.payload.UnknownServerTypes),
+			zap.Int("missing_id", w.payload.MissingID),
+			zap.Int("missing_ip", w.payload.MissingIP))
+	}
+	return fields
+}
+
+// payloadCounts 返回不符合约定的记录统计，没有问题记录时为空。
+func (w writeTotals) payloadCounts() map[string]int {
+	if w.payload.Invalid == 0 {
+		return nil
+	}
+	return map[string]int{
+		"records_invalid":     w.payload.Invalid,
+		"unknown_server_type": w.payload.Unknown(),
+		"missing_id":          w.payload.MissingID,
+		"missing_ip":          w.payload.MissingIP,
+	}
+}
+
+// fixFields 将补边结果转换为日志字段，按关系类型分别记录新建与恢复数。
+func fixFields(report loader.EdgeFixReport) []zap.Field {
+	fields := []zap.Field{
+		zap.Int("edges_created", report.Created()),
+		zap.Int("edges_repaired", report.Repaired()),
+	}
+	for _, fix := range report.Fixes {
+		fields = append(fields, zap.Dict(fix.Name(), zap.Int("created", fix.Created), zap.Int("repaired", fix.Repaired)))
+	}
+	return fields
+}
+
+// counts 将写入统计转换为进度计数，便于通过进度接口查看本次写入结果。
+func (w writeTotals) counts() map[string]int {
+	counts := map[string]int{
+		"nodes_created": w.nodes.Created,
+		"nodes_updated": w.nodes.Updated,
+		"rels_created":  w.rels.Created,
+		"rels_updated":  w.rels.Updated,
+	}
+	for name, n := range w.payloadCounts() {
+		counts[name] = n
+	}
+	return counts
+}
+
+// SyncProgress 描述当前（或最近一次）同步的进度。
+type SyncProgress struct {
+	// TriggerID 标识一次同步触发，由 Start 生成，定时任务与子命令触发时为空。
+	TriggerID string         `json:"trigger_id,omitempty"`
+	Running   bool           `json:"running"`
+	Stage     string         `json:"stage"`
+	Counts    map[string]int `json:"counts,omitempty"`
+	StartedAt time.Time      `json:"started_at,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at,omitempty"`
+	Cancelled bool           `json:"cancelled"`
+	LastError string         `json:"last_error,omitempty"`
+}
+
+// SyncTracker 记录同步进度，并持有在途同步的取消函数。
+type SyncTracker struct {
+	mu       sync.Mutex
+	progress SyncProgress
+	cancel   context.CancelFunc
+	seq      int64
+}
+
+// NewSyncTracker 创建进度跟踪器。
+func NewSyncTracker() *SyncTracker {
+	return &SyncTracker{}
+}
+
+// Run 在可取消的上下文中执行 fn，同一时刻只允许一个同步在途。
+func (t *SyncTracker) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if err := t.begin(cancel, ""); err != nil {
+		return err
+	}
+	return t.finish(fn(runCtx))
+}
+
+// Start 在后台执行 fn 并立即返回触发 ID 与结果通道，已有同步在途时返回 ErrSyncRunning。
+func (t *SyncTracker) Start(ctx context.Context, fn func(ctx context.Context) error) (string, <-chan error, error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	t.mu.Lock()
+	t.seq++
+	id := fmt.Sprintf("sync-%d-%d", time.Now().Unix(), t.seq)
+	t.mu.Unlock()
+	if err := t.begin(cancel, id); err != nil {
+		cancel()
+		return "", nil, err
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer cancel()
+		done <- t.finish(fn(runCtx))
+	}()
+	return id, done, nil
+}
+
+func (t *SyncTracker) begin(cancel context.CancelFunc, triggerID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.progress.Running {
+		return ErrSyncRunning
+	}
+	now := time.Now()
+	t.progress = SyncProgress{TriggerID: triggerID, Running: true, Stage: "start", StartedAt: now, UpdatedAt: now}
+	t.cancel = cancel
+	return nil
+}
+
+func (t *SyncTracker) finish(err error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Running = false
+	t.progress.UpdatedAt = time.Now()
+	t.cancel = nil
+	if err != nil {
+		t.progress.LastError = err.Error()
+		if errors.Is(err, context.Canceled) {
+			t.progress.Stage = "cancelled"
+		} else {
+			t.progress.Stage = "failed"
+		}
+	} else {
+		t.progress.Stage = "done"
+	}
+	return err
+}
+
+// Report 记录阶段进度，可直接作为 ProgressFunc 使用。
+func (t *SyncTracker) Report(stage string, counts map[string]int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Stage = stage
+	if len(counts) > 0 {
+		if t.progress.Counts == nil {
+			t.progress.Counts = make(map[string]int, len(counts))
+		}
+		for k, v := range counts {
+			t.progress.Counts[k] = v
+		}
+	}
+	t.progress.UpdatedAt = time.Now()
+}
+
+// Progress 返回当前进度的副本。
+func (t *SyncTracker) Progress() SyncProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	snapshot := t.progress
+	if len(t.progress.Counts) > 0 {
+		snapshot.Counts = make(map[string]int, len(t.progress.Counts))
+		for k, v := range t.progress.Counts {
+			snapshot.Counts[k] = v
+		}
+	}
+	return snapshot
+}
+
+// Cancel 取消在途同步，没有在途同步时返回 false。
+func (t *SyncTracker) Cancel() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.progress.Running || t.cancel == nil {
+		return false
+	}
+	t.cancel()
+	t.progress.Cancelled = true
+	t.progress.UpdatedAt = time.Now()
+	return true
+}
@@ -2,18 +2,160 @@ package app
 
 import (
 	"context"
+	"fmt"
 
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/loader"
+	"cmdb2neo/internal/metrics"
 	"go.uber.org/zap"
 )
 
-// ReconcileFlow 用于软删、硬删、资源回收，占位实现。
+// ReconcileFlow 对比 CMDB 快照与图中现状，只修复不一致的节点和关系。
 type ReconcileFlow struct {
-	Logger *zap.Logger
+	CMDB    cmdb.Client
+	Graph   GraphStateReader
+	Nodes   NodeWriter
+	Rels    RelWriter
+	Deleter KeyDeleter
+	Logger  *zap.Logger
+	// AllowDelete 为 false 时多余的节点和关系只计数不删除。
+	AllowDelete bool
+	// HardDelete 为 true 时直接删除多余数据，否则标记墓碑。
+	HardDelete bool
+	// StrictValidation 为 true 时快照存在 error 级悬空引用即中止对账。
+	StrictValidation bool
+	// Guard 在删除前按标签检查多余节点占图中节点的比例，超过阈值时中止删除并返回 ErrDeleteGuard。
+	Guard DeleteGuard
+	// Metrics 可选，记录快照拉取耗时。
+	Metrics metrics.Recorder
+	// Mapping 可选，映射快照时传给 cmdb.BuildInitRows，如 cmdb.WithSchema 按配置的前缀生成 cmdb_key。
+	Mapping []cmdb.MapperOption
+	// Orphans 可选，写完差异后为缺少上游关系的节点补挂父节点，并按标签上报剩余孤儿数。
+	Orphans OrphanRepairer
 }
 
-func (f *ReconcileFlow) Run(ctx context.Context) error {
+// ReconcileSummary 汇总一次对账修复的数量。
+type ReconcileSummary struct {
+	RunID         string `json:"run_id"`
+	NodesAdded    int    `json:"nodes_added"`
+	NodesUpdated  int    `json:"nodes_updated"`
+	NodesDeleted  int    `json:"nodes_deleted"`
+	RelsAdded     int    `json:"rels_added"`
+	RelsUpdated   int    `json:"rels_updated"`
+	RelsDeleted   int    `json:"rels_deleted"`
+	DeleteSkipped int    `json:"delete_skipped"`
+	// OrphansFixed 与 OrphansRemaining 为孤儿修复挂回与仍无法挂回的节点数，各标签明细见日志与指标。
+	OrphansFixed     int `json:"orphans_fixed"`
+	OrphansRemaining int `json:"orphans_remaining"`
+}
+
+// Run 拉取快照、读取图状态并写入差异，返回修复汇总。
+func (f *ReconcileFlow) Run(ctx context.Context) (ReconcileSummary, error) {
+	if f == nil || f.CMDB == nil || f.Graph == nil || f.Nodes == nil || f.Rels == nil {
+		return ReconcileSummary{}, fmt.Errorf("reconcile flow 依赖未注入完整")
+	}
+
+	snapshot, err := fetchSnapshot(ctx, f.CMDB, f.Metrics)
+	if err != nil {
+		return ReconcileSummary{}, fmt.Errorf("拉取 CMDB 快照失败: %w", err)
+	}
+	if err := validateSnapshot(f.Logger, snapshot, f.StrictValidation); err != nil {
+		return ReconcileSummary{}, err
+	}
+	graphNodes, err := f.Graph.Nodes(ctx)
+	if err != nil {
+		return ReconcileSummary{}, err
+	}
+	// 空快照多半是数据源故障，删除保护对小图不生效，须在写入与删除之前拒绝
+	if err := rejectEmptySnapshot(f.Logger, snapshot.RunID, snapshotEntities(snapshot), countRemoved(graphNodes, nil)); err != nil {
+		return ReconcileSummary{}, err
+	}
+	graphRels, err := f.Graph.Relationships(ctx)
+	if err != nil {
+		return ReconcileSummary{}, err
+	}
+
+	diff := cmdb.DiffGraph(graphNodes, graphRels, snapshot, f.Mapping...)
+	summary := ReconcileSummary{
+		RunID:        snapshot.RunID,
+		NodesAdded:   len(diff.AddedNodes),
+		NodesUpdated: len(diff.ChangedNodes),
+		RelsAdded:    len(diff.AddedRels),
+		RelsUpdated:  len(diff.ChangedRels),
+	}
+
+	if nodes := diff.UpsertNodes(); len(nodes) > 0 {
+		if _, err := f.Nodes.UpsertNodes(ctx, nodes); err != nil {
+			return ReconcileSummary{}, fmt.Errorf("修复节点失败: %w", err)
+		}
+	}
+	if rels := diff.UpsertRels(); len(rels) > 0 {
+		if _, err := f.Rels.UpsertRels(ctx, rels); err != nil {
+			return ReconcileSummary{}, fmt.Errorf("修复关系失败: %w", err)
+		}
+	}
+
+	if removed := len(diff.RemovedNodes) + len(diff.RemovedRels); removed > 0 {
+		if !f.AllowDelete || f.Deleter == nil {
+			summary.DeleteSkipped = removed
+			if f.Logger != nil {
+				f.Logger.Warn("未开启 allow_delete，跳过删除多余数据",
+					zap.Int("removed_nodes", len(diff.RemovedNodes)),
+					zap.Int("removed_rels", len(diff.RemovedRels)))
+			}
+		} else {
+			if err := f.Guard.Check(f.Logger, countRemoved(graphNodes, diff.RemovedNodes)); err != nil {
+				return ReconcileSummary{}, err
+			}
+			deleteRels, deleteNodes := f.Deleter.TombstoneRelationships, f.Deleter.TombstoneNodes
+			if f.HardDelete {
+				deleteRels, deleteNodes = f.Deleter.DeleteRelationships, f.Deleter.DeleteNodes
+			}
+			if err := deleteRels(ctx, diff.RemovedRels); err != nil {
+				return ReconcileSummary{}, fmt.Errorf("删除多余关系失败: %w", err)
+			}
+			if err := deleteNodes(ctx, diff.RemovedNodes); err != nil {
+				return ReconcileSummary{}, fmt.Errorf("删除多余节点失败: %w", err)
+			}
+			summary.RelsDeleted = len(diff.RemovedRels)
+			summary.NodesDeleted = len(diff.RemovedNodes)
+		}
+	}
+
+	if f.Orphans != nil {
+		report, err := f.Orphans.Run(ctx, summary.RunID)
+		if err != nil {
+			return ReconcileSummary{}, err
+		}
+		summary.OrphansFixed, summary.OrphansRemaining = report.Fixed(), report.Remaining()
+		f.recordOrphans(report)
+	}
+
+	if f.Logger != nil {
+		f.Logger.Info("对账完成",
+			zap.String("run_id", summary.RunID),
+			zap.Int("nodes_added", summary.NodesAdded),
+			zap.Int("nodes_updated", summary.NodesUpdated),
+			zap.Int("nodes_deleted", summary.NodesDeleted),
+			zap.Int("rels_added", summary.RelsAdded),
+			zap.Int("rels_updated", summary.RelsUpdated),
+			zap.Int("rels_deleted", summary.RelsDeleted),
+			zap.Int("delete_skipped", summary.DeleteSkipped),
+			zap.Int("orphans_fixed", summary.OrphansFixed),
+			zap.Int("orphans_remaining", summary.OrphansRemaining))
+	}
+	return summary, nil
+}
+
+// recordOrphans 按标签上报剩余孤儿数，便于持续跟踪图的完整度。
+func (f *ReconcileFlow) recordOrphans(report loader.OrphanReport) {
+	recorder := metrics.OrNop(f.Metrics)
+	fields := make([]zap.Field, 0, len(report.Labels))
+	for _, fix := range report.Labels {
+		recorder.SetOrphans(fix.Label, fix.Remaining())
+		fields = append(fields, zap.String(fix.Label, fmt.Sprintf("%d/%d", fix.Fixed, fix.Orphans)))
+	}
 	if f.Logger != nil {
-		f.Logger.Info("对账流程暂未实现")
+		f.Logger.Info("孤儿节点修复完成（已修复/孤儿数）", fields...)
 	}
-	return nil
 }
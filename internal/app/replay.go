@@ -0,0 +1,232 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/loader"
+	"cmdb2neo/internal/rca"
+	"go.uber.org/zap"
+)
+
+// ErrReplayGraphNotEmpty 表示回放目标图中已有 CMDB 数据：回放以步骤时间作为批次号，在已有更新批次的图上
+// 既清理不掉快照中缺失的实体，还会回退 last_seen_run_id，因此只在空图或显式清空后回放。
+var ErrReplayGraphNotEmpty = errors.New("回放目标图非空")
+
+// replayTimeLayout 为回放目录中步骤子目录的命名格式，与批次号一致，回放时直接作为该步的 run_id。
+const replayTimeLayout = "20060102T150405Z"
+
+// 回放步骤目录中的文件：idc.json 存在时视为快照（格式同 cmdb.FileClient），alarms.json 为该时间点的告警窗口。
+const (
+	replaySnapshotMarker = "idc.json"
+	replayAlarmsFile     = "alarms.json"
+)
+
+// ReplayStep 为回放目录中的一个时间点，快照与告警窗口至少有一个。
+type ReplayStep struct {
+	At          time.Time
+	Dir         string
+	HasSnapshot bool
+	HasAlarms   bool
+}
+
+// RunID 返回该步同步使用的批次号，按时间递增，保证按批次清理时新快照覆盖旧快照。
+func (s ReplayStep) RunID() string {
+	return s.At.UTC().Format(replayTimeLayout)
+}
+
+// LoadReplaySteps 读取 dir 下以时间命名的子目录并按时间排序，from、to 非零时只保留 [from, to] 内的步骤。
+// 名称不符合时间格式的条目被忽略，既无快照也无告警的子目录同样忽略。
+func LoadReplaySteps(dir string, from, to time.Time) ([]ReplayStep, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取回放目录失败: %w", err)
+	}
+	var steps []ReplayStep
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		at, err := time.Parse(replayTimeLayout, entry.Name())
+		if err != nil {
+			continue
+		}
+		if (!from.IsZero() && at.Before(from)) || (!to.IsZero() && at.After(to)) {
+			continue
+		}
+		step := ReplayStep{At: at, Dir: filepath.Join(dir, entry.Name())}
+		step.HasSnapshot = fileExists(filepath.Join(step.Dir, replaySnapshotMarker))
+		step.HasAlarms = fileExists(filepath.Join(step.Dir, replayAlarmsFile))
+		if step.HasSnapshot || step.HasAlarms {
+			steps = append(steps, step)
+		}
+	}
+	sort.Slice(steps, func(i, j int) bool { return steps[i].At.Before(steps[j].At) })
+	return steps, nil
+}
+
+// ParseReplayTime 解析 --from/--to，支持 RFC3339 与批次号格式，空串返回零值表示不限制。
+func ParseReplayTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, replayTimeLayout} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间 %q，支持 RFC3339 或 %s", value, replayTimeLayout)
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// ReplayAnalyzer 抽象回放使用的根因分析，默认由 rca.Analyzer 实现。
+type ReplayAnalyzer interface {
+	AnalyzeWithOptions(ctx context.Context, events []rca.AlarmEvent, opts rca.AnalyzeOptions) (rca.Result, error)
+}
+
+// ReplayResult 为一个回放步骤的输出。
+type ReplayResult struct {
+	At         time.Time       `json:"at"`
+	RunID      string          `json:"run_id,omitempty"`
+	WindowID   string          `json:"window_id,omitempty"`
+	Events     int             `json:"events"`
+	Candidates []rca.Candidate `json:"candidates,omitempty"`
+}
+
+// ReplayFlow 按时间顺序回放采集的快照与告警窗口：每步先用该步快照执行一次同步，再分析该步的告警，用于复现 RCA 回归。
+// 回放应指向专用的图，开始前图中须没有 CMDB 数据，否则需设置 Wipe 清空。
+type ReplayFlow struct {
+	// Sync 为同步模板，每步复制后把数据源替换为该步快照；回放不读写增量快照存储，每步都是全量同步，
+	// 并按批次硬删除快照中缺失的实体，使每步拓扑只取决于该步快照。
+	Sync     *SyncFlow
+	Analyzer ReplayAnalyzer
+	// Reset 在 Wipe 为 true 时清空目标图中的 CMDB 数据。
+	Reset  GraphResetter
+	Wipe   bool
+	Logger *zap.Logger
+}
+
+// Run 依次执行 steps，任一步失败即停止并返回已完成步骤的结果。
+func (f *ReplayFlow) Run(ctx context.Context, steps []ReplayStep) ([]ReplayResult, error) {
+	if f == nil || f.Sync == nil || f.Analyzer == nil {
+		return nil, errors.New("replay flow 依赖未注入完整")
+	}
+	if err := f.prepare(ctx); err != nil {
+		return nil, err
+	}
+	results := make([]ReplayResult, 0, len(steps))
+	for _, step := range steps {
+		result := ReplayResult{At: step.At}
+		if step.HasSnapshot {
+			if err := f.sync(ctx, step); err != nil {
+				return results, fmt.Errorf("回放 %s 同步失败: %w", step.RunID(), err)
+			}
+			result.RunID = step.RunID()
+		}
+		if step.HasAlarms {
+			events, err := readReplayAlarms(filepath.Join(step.Dir, replayAlarmsFile))
+			if err != nil {
+				return results, err
+			}
+			result.WindowID = "replay-" + step.RunID()
+			analysis, err := f.Analyzer.AnalyzeWithOptions(ctx, events, rca.AnalyzeOptions{WindowID: result.WindowID})
+			if err != nil {
+				return results, fmt.Errorf("回放 %s 分析失败: %w", step.RunID(), err)
+			}
+			result.Events, result.Candidates = len(events), analysis.Candidates
+		}
+		if f.Logger != nil {
+			f.Logger.Info("回放步骤完成",
+				zap.String("at", step.RunID()),
+				zap.Bool("synced", step.HasSnapshot),
+				zap.Int("events", result.Events),
+				zap.Int("candidates", len(result.Candidates)))
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// prepare 检查目标图是否为空，非空时仅在 Wipe 为 true 时清空，否则返回 ErrReplayGraphNotEmpty。
+func (f *ReplayFlow) prepare(ctx context.Context) error {
+	counts, err := f.Sync.Cleaner.CountStale(ctx, "")
+	if err != nil {
+		return fmt.Errorf("检查回放目标图失败: %w", err)
+	}
+	existing := 0
+	for _, c := range counts {
+		existing += c.Total
+	}
+	if existing == 0 {
+		return nil
+	}
+	if !f.Wipe {
+		return fmt.Errorf("%w: 现有 %d 个 CMDB 节点，确认目标图仅用于回放后加 --wipe 清空", ErrReplayGraphNotEmpty, existing)
+	}
+	if f.Reset == nil {
+		return errors.New("replay flow 未注入 Reset，无法清空目标图")
+	}
+	if err := f.Reset.Reset(ctx, loader.ResetOptions{Confirm: true}); err != nil {
+		return fmt.Errorf("清空回放目标图失败: %w", err)
+	}
+	if f.Logger != nil {
+		f.Logger.Warn("回放前已清空目标图", zap.Int("nodes", existing))
+	}
+	return nil
+}
+
+func (f *ReplayFlow) sync(ctx context.Context, step ReplayStep) error {
+	client, err := cmdb.NewFileClient(step.Dir)
+	if err != nil {
+		return err
+	}
+	flow := *f.Sync
+	flow.CMDB = replaySnapshotClient{client: client, runID: step.RunID()}
+	flow.Streaming = false
+	flow.Snapshots = nil
+	// 目标图只用于回放，快照间的删除都是有意的，不经删除比例保护
+	flow.AllowDelete = true
+	flow.HardDelete = true
+	flow.Guard = DeleteGuard{MaxRatio: 1}
+	return flow.Run(ctx)
+}
+
+// replaySnapshotClient 以步骤时间作为快照批次号，使回放结果不依赖文件修改时间。
+type replaySnapshotClient struct {
+	client cmdb.Client
+	runID  string
+}
+
+func (c replaySnapshotClient) FetchSnapshot(ctx context.Context) (cmdb.Snapshot, error) {
+	snapshot, err := c.client.FetchSnapshot(ctx)
+	if err != nil {
+		return cmdb.Snapshot{}, err
+	}
+	snapshot.RunID = c.runID
+	return snapshot, nil
+}
+
+func readReplayAlarms(path string) ([]rca.AlarmEvent, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取告警窗口失败: %w", err)
+	}
+	var events []rca.AlarmEvent
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("解析告警窗口 %s 失败: %w", path, err)
+	}
+	return events, nil
+}
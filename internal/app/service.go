@@ -3,10 +3,16 @@ package app
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/graph"
 	"cmdb2neo/internal/loader"
+	"cmdb2neo/internal/metrics"
 	"cmdb2neo/pkg/logging"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -18,21 +24,75 @@ type Service struct {
 	InitFlow      *InitFlow
 	SyncFlow      *SyncFlow
 	ReconcileFlow *ReconcileFlow
+	Validator     *GraphValidator
+	schema        *loader.SchemaManager
+	tracker       *SyncTracker
+	trackerOnce   sync.Once
 	logger        *zap.Logger
 }
 
+// ServiceOption 配置 Service 的可选项。
+type ServiceOption func(*serviceOptions)
+
+type serviceOptions struct {
+	recorder  metrics.Recorder
+	tracer    trace.TracerProvider
+	bookmarks neo4j.BookmarkManager
+}
+
+// WithRecorder 注入同步与 Neo4j 写入的指标记录器。
+func WithRecorder(recorder metrics.Recorder) ServiceOption {
+	return func(o *serviceOptions) {
+		o.recorder = recorder
+	}
+}
+
+// WithTracerProvider 为 Neo4j 写入开启 span。
+func WithTracerProvider(provider trace.TracerProvider) ServiceOption {
+	return func(o *serviceOptions) {
+		o.tracer = provider
+	}
+}
+
+// WithBookmarkManager 让同步写入记录书签，与 RCA 读侧共享同一个 BookmarkManager 时可读到刚同步的数据。
+func WithBookmarkManager(bookmarks neo4j.BookmarkManager) ServiceOption {
+	return func(o *serviceOptions) {
+		o.bookmarks = bookmarks
+	}
+}
+
 // NewService 根据配置构建 Service。
-func NewService(ctx context.Context, cfg *Config, cmdbClient cmdb.Client) (*Service, error) {
+func NewService(ctx context.Context, cfg *Config, cmdbClient cmdb.Client, opts ...ServiceOption) (*Service, error) {
 	if cmdbClient == nil {
 		return nil, fmt.Errorf("必须提供 cmdb client")
 	}
 	if cfg == nil {
 		return nil, fmt.Errorf("config is nil")
 	}
-	logger, err := logging.NewZpaLogger()
+	var options serviceOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	recorder := metrics.OrNop(options.recorder)
+	logger, err := logging.NewLevelLogger(cfg.Log.Level)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := cfg.Schema.Build()
 	if err != nil {
 		return nil, err
 	}
+	mapping := []cmdb.MapperOption{cmdb.WithSchema(schema)}
+	var snapshots cmdb.SnapshotStore
+	if cfg.Sync.SnapshotDir != "" {
+		store, err := cmdb.NewFileSnapshotStore(cfg.Sync.SnapshotDir)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = store
+	}
 	neoClient, err := loader.NewClient(ctx, loader.Config{
 		URI:                  cfg.Neo4j.URI,
 		Username:             cfg.Neo4j.Username,
@@ -40,7 +100,9 @@ func NewService(ctx context.Context, cfg *Config, cmdbClient cmdb.Client) (*Serv
 		Database:             cfg.Neo4j.Database,
 		MaxConnectionPool:    cfg.Neo4j.MaxConnectionPool,
 		ConnectionTimeoutSec: cfg.Neo4j.ConnectTimeoutSecond,
-	})
+		QueryTimeoutSec:      cfg.Neo4j.QueryTimeoutSecond,
+		Routing:              cfg.Neo4j.Routing,
+	}, loader.WithRecorder(recorder), loader.WithTracerProvider(options.tracer), loader.WithBookmarkManager(options.bookmarks))
 	if err != nil {
 		return nil, err
 	}
@@ -48,39 +110,95 @@ func NewService(ctx context.Context, cfg *Config, cmdbClient cmdb.Client) (*Serv
 
 	nodeUpserter := loader.NewNodeUpserter(neoClient, batchSize)
 	relUpserter := loader.NewRelUpserter(neoClient, batchSize)
+	nodeUpserter.BatchTransactional = cfg.Sync.BatchTransactional
+	relUpserter.BatchTransactional = cfg.Sync.BatchTransactional
+	nodeUpserter.Schema, relUpserter.Schema = schema, schema
 	edgeFixer := loader.NewEdgeFixer(neoClient)
-	schema := loader.NewSchemaManager(neoClient)
+	edgeFixer.Schema = schema
+	schemaManager := loader.NewSchemaManager(neoClient)
+	schemaManager.Schema = schema
+	tracker := NewSyncTracker()
 
 	initFlow := &InitFlow{
-		CMDB:   cmdbClient,
-		Schema: schema,
-		Nodes:  nodeUpserter,
-		Rels:   relUpserter,
-		Fixer:  edgeFixer,
-		Logger: logger,
+		CMDB:             cmdbClient,
+		Schema:           schemaManager,
+		Nodes:            nodeUpserter,
+		Rels:             relUpserter,
+		Fixer:            edgeFixer,
+		Logger:           logger,
+		Progress:         tracker.Report,
+		StrictValidation: cfg.Sync.StrictValidation,
+		Metrics:          recorder,
+		Mapping:          mapping,
 	}
 
+	cleaner := loader.NewCleaner(neoClient)
+	cleaner.Schema = schema
+	stateReader := loader.NewStateReader(neoClient)
+	stateReader.Schema = schema
+	orphanReconciler := loader.NewOrphanReconciler(neoClient)
+	orphanReconciler.Schema = schema
+
 	syncFlow := &SyncFlow{
-		CMDB:    cmdbClient,
-		Nodes:   nodeUpserter,
-		Rels:    relUpserter,
-		Fixer:   edgeFixer,
-		Cleaner: loader.NewCleaner(neoClient),
-		Logger:  logger,
+		CMDB:               cmdbClient,
+		Nodes:              nodeUpserter,
+		Rels:               relUpserter,
+		Fixer:              edgeFixer,
+		Cleaner:            cleaner,
+		Logger:             logger,
+		Progress:           tracker.Report,
+		Retry:              cfg.Sync.FlowRetry,
+		Streaming:          cfg.Sync.Streaming,
+		Snapshots:          snapshots,
+		Deleter:            cleaner,
+		FullResync:         cfg.Sync.FullResync,
+		StrictValidation:   cfg.Sync.StrictValidation,
+		AllowDelete:        cfg.Sync.AllowDelete,
+		HardDelete:         cfg.Sync.HardDelete,
+		Purger:             cleaner,
+		TombstoneRetention: time.Duration(cfg.Sync.TombstoneRetentionHours) * time.Hour,
+		Guard:              DeleteGuard{MaxRatio: cfg.Sync.MaxDeleteRatio, MinCount: cfg.Sync.DeleteGuardMinCount},
+		Metrics:            recorder,
+		Mapping:            mapping,
 	}
 
 	svc := &Service{
-		cfg:           cfg,
-		cmdbClient:    cmdbClient,
-		neoClient:     neoClient,
-		InitFlow:      initFlow,
-		SyncFlow:      syncFlow,
-		ReconcileFlow: &ReconcileFlow{Logger: logger},
-		logger:        logger,
+		cfg:        cfg,
+		cmdbClient: cmdbClient,
+		neoClient:  neoClient,
+		InitFlow:   initFlow,
+		SyncFlow:   syncFlow,
+		ReconcileFlow: &ReconcileFlow{
+			CMDB:             cmdbClient,
+			Graph:            stateReader,
+			Nodes:            nodeUpserter,
+			Rels:             relUpserter,
+			Deleter:          cleaner,
+			Logger:           logger,
+			AllowDelete:      cfg.Sync.AllowDelete,
+			HardDelete:       cfg.Sync.HardDelete,
+			StrictValidation: cfg.Sync.StrictValidation,
+			Guard:            DeleteGuard{MaxRatio: cfg.Sync.MaxDeleteRatio, MinCount: cfg.Sync.DeleteGuardMinCount},
+			Metrics:          recorder,
+			Mapping:          mapping,
+			Orphans:          orphanReconciler,
+		},
+		Validator: &GraphValidator{Reader: neoClient, Schema: schema},
+		schema:    schemaManager,
+		tracker:   tracker,
+		logger:    logger,
 	}
 	return svc, nil
 }
 
+// GraphResetter 返回清空 CMDB 数据使用的 SchemaManager，回放 --wipe 使用。
+func (s *Service) GraphResetter() GraphResetter {
+	if s == nil || s.schema == nil {
+		return nil
+	}
+	return s.schema
+}
+
 // Close 释放资源。
 func (s *Service) Close(ctx context.Context) error {
 	if s.logger != nil {
@@ -96,27 +214,79 @@ func (s *Service) Init(ctx context.Context) error {
 	if s.InitFlow == nil {
 		return fmt.Errorf("未初始化 init flow")
 	}
-	return s.InitFlow.Run(ctx)
+	return s.Tracker().Run(ctx, s.InitFlow.Run)
 }
 
 func (s *Service) Sync(ctx context.Context) error {
 	if s.SyncFlow == nil {
 		return fmt.Errorf("未初始化 sync flow")
 	}
-	return s.SyncFlow.Run(ctx)
+	return s.Tracker().Run(ctx, s.SyncFlow.Run)
+}
+
+// StartSync 在后台触发一次同步并返回触发 ID 与结果通道，init 为 true 时执行全量初始化。
+func (s *Service) StartSync(ctx context.Context, init bool) (string, <-chan error, error) {
+	if init {
+		if s.InitFlow == nil {
+			return "", nil, fmt.Errorf("未初始化 init flow")
+		}
+		return s.Tracker().Start(ctx, s.InitFlow.Run)
+	}
+	if s.SyncFlow == nil {
+		return "", nil, fmt.Errorf("未初始化 sync flow")
+	}
+	return s.Tracker().Start(ctx, s.SyncFlow.Run)
+}
+
+// SyncLock 返回基于同步所用 Neo4j 连接的租约锁，owner 为空时使用 主机名-进程号。
+func (s *Service) SyncLock(owner string) *loader.SyncLock {
+	if s.neoClient == nil {
+		return nil
+	}
+	return loader.NewSyncLock(s.neoClient, owner)
 }
 
-func (s *Service) Reconcile(ctx context.Context) error {
+// ConnectionMonitor 返回同步所用 Neo4j 连接的连通性监控。
+func (s *Service) ConnectionMonitor(opts ...graph.MonitorOption) *graph.ConnectionMonitor {
+	if s.neoClient == nil {
+		return nil
+	}
+	return graph.NewConnectionMonitor("loader", s.neoClient, opts...)
+}
+
+// Tracker 返回同步进度跟踪器。NewService 已创建跟踪器，零值 Service 在首次调用时创建，并发调用得到同一实例。
+func (s *Service) Tracker() *SyncTracker {
+	s.trackerOnce.Do(func() {
+		if s.tracker == nil {
+			s.tracker = NewSyncTracker()
+		}
+	})
+	return s.tracker
+}
+
+// Reconcile 修复 CMDB 与图之间的漂移并返回修复汇总。
+func (s *Service) Reconcile(ctx context.Context) (ReconcileSummary, error) {
 	if s.ReconcileFlow == nil {
-		return fmt.Errorf("未初始化 reconcile flow")
+		return ReconcileSummary{}, fmt.Errorf("未初始化 reconcile flow")
 	}
 	return s.ReconcileFlow.Run(ctx)
 }
 
-func (s *Service) Validate(ctx context.Context) error {
-	// 目前仅做占位
+// Validate 对图数据执行一致性检查并返回报告，报告中有问题不视为错误，由调用方决定如何处置。
+func (s *Service) Validate(ctx context.Context) (GraphReport, error) {
+	if s.Validator == nil {
+		return GraphReport{}, fmt.Errorf("未初始化 graph validator")
+	}
+	report, err := s.Validator.Run(ctx)
+	if err != nil {
+		return GraphReport{}, err
+	}
 	if s.logger != nil {
-		s.logger.Info("validate 尚未实现")
+		fields := []zap.Field{zap.Int("violations", report.Violations)}
+		for _, check := range report.Checks {
+			fields = append(fields, zap.Int(check.Name, check.Count))
+		}
+		s.logger.Info("图一致性检查完成", fields...)
 	}
-	return nil
+	return report, nil
 }
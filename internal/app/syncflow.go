@@ -2,23 +2,60 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
 	"cmdb2neo/internal/loader"
+	"cmdb2neo/internal/metrics"
+	"cmdb2neo/pkg/util"
 	"go.uber.org/zap"
 )
 
 // SyncFlow 负责增量同步。
 type SyncFlow struct {
 	CMDB    cmdb.Client
-	Nodes   *loader.NodeUpserter
-	Rels    *loader.RelUpserter
-	Fixer   *loader.EdgeFixer
-	Cleaner *loader.Cleaner
+	Nodes   NodeWriter
+	Rels    RelWriter
+	Fixer   EdgeRepairer
+	Cleaner StaleCleaner
 	Logger  *zap.Logger
+	// Progress 可选，用于上报各阶段进度。
+	Progress ProgressFunc
+	// Retry 控制整个流程失败后的重跑次数与退避，取自 sync.flow_retry，与单次 CMDB 请求内的 sync.retry 相互独立。
+	Retry Retry
+	// Streaming 为 true 且数据源实现 cmdb.StreamClient 时按页边拉边写，不构建完整快照。
+	Streaming bool
+	// Snapshots 与 Deleter 均注入且未开启 FullResync 时，只写入与上次快照的差异。
+	Snapshots  cmdb.SnapshotStore
+	Deleter    KeyDeleter
+	FullResync bool
+	// StrictValidation 为 true 时快照存在 error 级悬空引用即中止同步；流式同步不构建完整快照，不做校验。
+	StrictValidation bool
+	// AllowDelete 为 false 时只做 upsert，跳过按批次清理和下线实体删除并告警，避免误删图数据。
+	AllowDelete bool
+	// HardDelete 为 true 时直接删除过期数据，否则仅标记墓碑（deleted=true），由 Purger 在保留期后清除。
+	HardDelete bool
+	// Purger 与 TombstoneRetention 均配置时，每次清理后清除超过保留期的墓碑。
+	Purger             TombstonePurger
+	TombstoneRetention time.Duration
+	// Guard 在删除前按标签检查待删除比例，超过阈值时中止本次删除并返回 ErrDeleteGuard。
+	Guard DeleteGuard
+	// Metrics 可选，记录快照拉取耗时、写入数量与同步结果；流式同步边拉边写，不单独记录拉取耗时。
+	Metrics metrics.Recorder
+	// Mapping 可选，映射快照时传给 cmdb.BuildInitRows，如 cmdb.WithSchema 按配置的前缀生成 cmdb_key。
+	Mapping []cmdb.MapperOption
 }
 
+func (f *SyncFlow) report(stage string, counts map[string]int) {
+	if f.Progress != nil {
+		f.Progress(stage, counts)
+	}
+}
+
+// Run 执行增量同步，失败时按 Retry 配置整体重跑。各步骤均为幂等的 MERGE/清理，重跑安全。
 func (f *SyncFlow) Run(ctx context.Context) error {
 	if f == nil {
 		return fmt.Errorf("sync flow 未初始化")
@@ -27,7 +64,45 @@ func (f *SyncFlow) Run(ctx context.Context) error {
 		return fmt.Errorf("sync flow 依赖未注入完整")
 	}
 
-	snapshot, err := f.CMDB.FetchSnapshot(ctx)
+	attempt := 0
+	start := time.Now()
+	backoff := time.Duration(f.Retry.BackoffSeconds) * time.Second
+	err := util.RetryIf(ctx, f.Retry.Attempts, backoff, isRetryableFlowError, func() error {
+		attempt++
+		err := f.runOnce(ctx)
+		if err != nil && f.Logger != nil && attempt < f.Retry.Attempts && isRetryableFlowError(err) {
+			f.Logger.Warn("增量同步失败，准备重试", zap.Int("attempt", attempt), zap.Int("attempts", f.Retry.Attempts), zap.Error(err))
+		}
+		return err
+	})
+	metrics.OrNop(f.Metrics).ObserveSync("sync", time.Since(start), err)
+	return err
+}
+
+// isRetryableFlowError 判断流程级错误是否值得整体重跑，调用方取消或超时、CMDB 认证失败与数据校验失败不重试。
+func isRetryableFlowError(err error) bool {
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, ErrSnapshotInvalid) &&
+		!errors.Is(err, ErrDeleteGuard) && !errors.Is(err, ErrSnapshotEmpty) &&
+		!errors.Is(err, cmdb.ErrCMDBAuth) && !errors.Is(err, domain.ErrValidation)
+}
+
+// IsTransientError 判断错误是否来自 Neo4j 或 CMDB 暂时不可用，这类失败下一次调度通常可自行恢复。
+func IsTransientError(err error) bool {
+	return errors.Is(err, loader.ErrNeo4jUnavailable) || errors.Is(err, cmdb.ErrCMDBUnavailable)
+}
+
+func (f *SyncFlow) runOnce(ctx context.Context) error {
+	if f.Streaming {
+		if stream, ok := f.CMDB.(cmdb.StreamClient); ok {
+			return f.runStreaming(ctx, stream)
+		}
+		if f.Logger != nil {
+			f.Logger.Warn("CMDB 数据源不支持流式拉取，回退为全量快照")
+		}
+	}
+
+	f.report("fetch", nil)
+	snapshot, err := fetchSnapshot(ctx, f.CMDB, f.Metrics)
 	if err != nil {
 		return fmt.Errorf("拉取 CMDB 快照失败: %w", err)
 	}
@@ -39,32 +114,266 @@ func (f *SyncFlow) Run(ctx context.Context) error {
 			zap.Int("host", len(snapshot.HostMachines)),
 			zap.Int("physical", len(snapshot.PhysicalMachines)),
 			zap.Int("vm", len(snapshot.VirtualMachines)),
+			zap.Int("container", len(snapshot.Containers)),
 			zap.Int("app", len(snapshot.Apps)))
 	}
+	if err := validateSnapshot(f.Logger, snapshot, f.StrictValidation); err != nil {
+		return err
+	}
+	if err := f.checkEmptySnapshot(ctx, snapshot.RunID, snapshotEntities(snapshot)); err != nil {
+		return err
+	}
 
-	nodes, rels := cmdb.BuildInitRows(snapshot)
+	if f.deltaEnabled() {
+		prev, ok, err := f.Snapshots.Load(ctx)
+		if err != nil {
+			return fmt.Errorf("读取上次快照失败: %w", err)
+		}
+		if ok {
+			prevNodes, _ := cmdb.BuildInitRows(prev, f.Mapping...)
+			deferred, err := f.applyDiff(ctx, cmdb.DiffSnapshots(prev, snapshot, f.Mapping...), prevNodes, snapshot.RunID, snapshot.Payload)
+			if err != nil {
+				return err
+			}
+			if deferred {
+				// 删除被跳过时基线保留下线实体，已写入的变化不再重复比较，开启 allow_delete 后仍能识别这些下线实体
+				return f.saveSnapshot(ctx, cmdb.RetainRemoved(prev, snapshot))
+			}
+			return f.saveSnapshot(ctx, snapshot)
+		}
+	}
 
-	if err := f.Nodes.UpsertNodes(ctx, nodes); err != nil {
+	nodes, rels := cmdb.BuildInitRows(snapshot, f.Mapping...)
+
+	f.report("nodes", map[string]int{"nodes": len(nodes), "rels": len(rels)})
+	totals := writeTotals{payload: snapshot.Payload}
+	if totals.nodes, err = f.Nodes.UpsertNodes(ctx, nodes); err != nil {
 		return fmt.Errorf("增量写入节点失败: %w", err)
 	}
-	if err := f.Rels.UpsertRels(ctx, rels); err != nil {
+	f.report("rels", nil)
+	if totals.rels, err = f.Rels.UpsertRels(ctx, rels); err != nil {
 		return fmt.Errorf("增量写入关系失败: %w", err)
 	}
+	if err := f.finish(ctx, snapshot.RunID, totals); err != nil {
+		return err
+	}
+	return f.saveSnapshot(ctx, snapshot)
+}
+
+func (f *SyncFlow) deltaEnabled() bool {
+	return !f.FullResync && f.Snapshots != nil && f.Deleter != nil
+}
+
+// applyDiff 只写入变化的节点和关系，并按 key 删除已下线的实体；未变化的数据不刷新批次号，
+// 因此这里不能按批次清理。未开启 AllowDelete 且存在下线实体时返回 deferred=true。
+// prevNodes 为上次快照的节点，用于计算各标签的删除比例；payload 为本次快照的记录检查统计，计入完成日志。
+func (f *SyncFlow) applyDiff(ctx context.Context, diff cmdb.SnapshotDiff, prevNodes []domain.NodeRow, runID string, payload cmdb.PayloadStats) (deferred bool, err error) {
+	if f.Logger != nil {
+		f.Logger.Info("增量同步差异",
+			zap.String("run_id", runID),
+			zap.Int("added_nodes", len(diff.AddedNodes)),
+			zap.Int("changed_nodes", len(diff.ChangedNodes)),
+			zap.Int("removed_nodes", len(diff.RemovedNodes)),
+			zap.Int("added_rels", len(diff.AddedRels)),
+			zap.Int("changed_rels", len(diff.ChangedRels)),
+			zap.Int("removed_rels", len(diff.RemovedRels)))
+	}
+	totals := writeTotals{payload: payload}
+	if diff.Empty() {
+		f.logDone(runID, totals)
+		return false, nil
+	}
+
+	upsertNodes, upsertRels := diff.UpsertNodes(), diff.UpsertRels()
+	f.report("nodes", map[string]int{"nodes": len(upsertNodes), "rels": len(upsertRels)})
+	if totals.nodes, err = f.Nodes.UpsertNodes(ctx, upsertNodes); err != nil {
+		return false, fmt.Errorf("增量写入节点失败: %w", err)
+	}
+	f.report("rels", nil)
+	if totals.rels, err = f.Rels.UpsertRels(ctx, upsertRels); err != nil {
+		return false, fmt.Errorf("增量写入关系失败: %w", err)
+	}
 	if f.Fixer != nil {
-		if err := f.Fixer.Run(ctx, snapshot.RunID); err != nil {
-			return fmt.Errorf("补边失败: %w", err)
+		f.report("fix_edges", nil)
+		fixed, err := f.Fixer.Run(ctx, runID)
+		if err != nil {
+			return false, fmt.Errorf("补边失败: %w", err)
 		}
+		f.logFixed(runID, fixed)
 	}
 
-	if err := f.Cleaner.HardDeleteRelationships(ctx, snapshot.RunID); err != nil {
-		return fmt.Errorf("删除过期关系失败: %w", err)
+	if len(diff.RemovedNodes) == 0 && len(diff.RemovedRels) == 0 {
+		f.logDone(runID, totals)
+		return false, nil
 	}
-	if err := f.Cleaner.HardDeleteNodes(ctx, snapshot.RunID); err != nil {
-		return fmt.Errorf("删除过期节点失败: %w", err)
+	if !f.AllowDelete {
+		f.warnDeleteSkipped(zap.Int("removed_nodes", len(diff.RemovedNodes)), zap.Int("removed_rels", len(diff.RemovedRels)))
+		f.logDone(runID, totals)
+		return true, nil
 	}
+	if err := f.Guard.Check(f.Logger, countRemoved(prevNodes, diff.RemovedNodes)); err != nil {
+		return false, err
+	}
+	f.report("clean", map[string]int{"nodes": len(diff.RemovedNodes), "rels": len(diff.RemovedRels)})
+	deleteRels, deleteNodes := f.Deleter.TombstoneRelationships, f.Deleter.TombstoneNodes
+	if f.HardDelete {
+		deleteRels, deleteNodes = f.Deleter.DeleteRelationships, f.Deleter.DeleteNodes
+	}
+	if err := deleteRels(ctx, diff.RemovedRels); err != nil {
+		return false, fmt.Errorf("删除下线关系失败: %w", err)
+	}
+	if err := deleteNodes(ctx, diff.RemovedNodes); err != nil {
+		return false, fmt.Errorf("删除下线节点失败: %w", err)
+	}
+	if err := f.purge(ctx); err != nil {
+		return false, err
+	}
+	f.logDone(runID, totals)
+	return false, nil
+}
+
+// logDone 记录同步完成及实际写入统计，并上报写入数量指标。
+func (f *SyncFlow) logDone(runID string, totals writeTotals) {
+	observeUpserted(f.Metrics, "sync", totals)
+	f.report("written", totals.counts())
+	if f.Logger != nil {
+		f.Logger.Info("增量同步完成", append([]zap.Field{zap.String("run_id", runID)}, totals.fields()...)...)
+	}
+}
 
+// logFixed 记录补边结果。
+func (f *SyncFlow) logFixed(runID string, report loader.EdgeFixReport) {
 	if f.Logger != nil {
-		f.Logger.Info("增量同步完成", zap.String("run_id", snapshot.RunID))
+		f.Logger.Info("补边完成", append([]zap.Field{zap.String("run_id", runID)}, fixFields(report)...)...)
 	}
+}
+
+// purge 清除超过保留期的墓碑，未配置时跳过。
+func (f *SyncFlow) purge(ctx context.Context) error {
+	if f.Purger == nil || f.TombstoneRetention <= 0 {
+		return nil
+	}
+	f.report("purge", nil)
+	if err := f.Purger.PurgeTombstones(ctx, f.TombstoneRetention); err != nil {
+		return fmt.Errorf("清除墓碑失败: %w", err)
+	}
+	return nil
+}
+
+// warnDeleteSkipped 记录因未开启 allow_delete 而跳过的删除。
+func (f *SyncFlow) warnDeleteSkipped(fields ...zap.Field) {
+	f.report("clean", map[string]int{"skipped": 1})
+	if f.Logger != nil {
+		f.Logger.Warn("未开启 allow_delete，跳过删除", fields...)
+	}
+}
+
+// saveSnapshot 记录本次快照作为下次差异计算的基线。
+func (f *SyncFlow) saveSnapshot(ctx context.Context, snapshot cmdb.Snapshot) error {
+	if f.Snapshots == nil {
+		return nil
+	}
+	if err := f.Snapshots.Save(ctx, snapshot); err != nil {
+		return fmt.Errorf("保存快照失败: %w", err)
+	}
+	return nil
+}
+
+// runStreaming 逐页映射并写入节点与关系，内存占用只与单页数据及 key 索引相关。
+func (f *SyncFlow) runStreaming(ctx context.Context, stream cmdb.StreamClient) error {
+	f.report("fetch", nil)
+	var (
+		mapper   *cmdb.RowMapper
+		pages    int
+		entities int
+		total    = map[string]int{"nodes": 0, "rels": 0}
+		totals   writeTotals
+	)
+	runID, err := stream.StreamSnapshot(ctx, func(part cmdb.Snapshot) error {
+		if mapper == nil {
+			mapper = cmdb.NewRowMapper(part.RunID, f.Mapping...)
+		}
+		nodes, rels := mapper.Map(part)
+		pages++
+		entities += snapshotEntities(part)
+		totals.payload.Add(part.Payload)
+		total["nodes"] += len(nodes)
+		total["rels"] += len(rels)
+		f.report("stream", map[string]int{"pages": pages, "nodes": total["nodes"], "rels": total["rels"]})
+		nodeStats, err := f.Nodes.UpsertNodes(ctx, nodes)
+		if err != nil {
+			return fmt.Errorf("增量写入节点失败: %w", err)
+		}
+		relStats, err := f.Rels.UpsertRels(ctx, rels)
+		if err != nil {
+			return fmt.Errorf("增量写入关系失败: %w", err)
+		}
+		totals.nodes.Add(nodeStats)
+		totals.rels.Add(relStats)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("流式同步失败: %w", err)
+	}
+	if runID == "" {
+		return fmt.Errorf("流式同步未返回批次号")
+	}
+	if f.Logger != nil {
+		pending := 0
+		if mapper != nil {
+			pending = mapper.Pending()
+		}
+		f.Logger.Info("流式写入完成",
+			zap.String("run_id", runID),
+			zap.Int("pages", pages),
+			zap.Int("nodes", total["nodes"]),
+			zap.Int("rels", total["rels"]),
+			zap.Int("unresolved", pending))
+	}
+	// 流式写入只有 upsert，全部页都为空时在清理前拦截
+	if err := f.checkEmptySnapshot(ctx, runID, entities); err != nil {
+		return err
+	}
+	return f.finish(ctx, runID, totals)
+}
+
+// finish 执行补边与过期数据清理，totals 为本次写入统计。
+func (f *SyncFlow) finish(ctx context.Context, runID string, totals writeTotals) error {
+	if f.Fixer != nil {
+		f.report("fix_edges", nil)
+		fixed, err := f.Fixer.Run(ctx, runID)
+		if err != nil {
+			return fmt.Errorf("补边失败: %w", err)
+		}
+		f.logFixed(runID, fixed)
+	}
+
+	if f.AllowDelete {
+		counts, err := f.Cleaner.CountStale(ctx, runID)
+		if err != nil {
+			return err
+		}
+		if err := f.Guard.Check(f.Logger, counts); err != nil {
+			return err
+		}
+		f.report("clean", nil)
+		deleteRels, deleteNodes := f.Cleaner.SoftDeleteRelationships, f.Cleaner.SoftDeleteNodes
+		if f.HardDelete {
+			deleteRels, deleteNodes = f.Cleaner.HardDeleteRelationships, f.Cleaner.HardDeleteNodes
+		}
+		if err := deleteRels(ctx, runID); err != nil {
+			return fmt.Errorf("删除过期关系失败: %w", err)
+		}
+		if err := deleteNodes(ctx, runID); err != nil {
+			return fmt.Errorf("删除过期节点失败: %w", err)
+		}
+		if err := f.purge(ctx); err != nil {
+			return err
+		}
+	} else {
+		f.warnDeleteSkipped(zap.String("retention_run_id", runID))
+	}
+
+	f.logDone(runID, totals)
 	return nil
 }
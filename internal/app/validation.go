@@ -0,0 +1,43 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+	"go.uber.org/zap"
+)
+
+// ErrSnapshotInvalid 表示严格校验模式下快照存在 error 级悬空引用，重跑无法自愈，不参与流程重试。
+var ErrSnapshotInvalid = errors.New("快照校验未通过")
+
+// maxLoggedIssues 限制日志中逐条输出的问题数量，其余只计数。
+const maxLoggedIssues = 20
+
+// validateSnapshot 校验快照引用完整性并记录问题；strict 时存在 error 级问题则返回错误中止写入。
+func validateSnapshot(logger *zap.Logger, snapshot cmdb.Snapshot, strict bool) error {
+	issues := cmdb.ValidateSnapshot(snapshot)
+	if len(issues) == 0 {
+		return nil
+	}
+	counts := cmdb.CountIssues(issues)
+	if logger != nil {
+		logger.Warn("CMDB 快照存在悬空引用",
+			zap.String("run_id", snapshot.RunID),
+			zap.Int("errors", counts[cmdb.SeverityError]),
+			zap.Int("warnings", counts[cmdb.SeverityWarning]))
+		for _, issue := range issues[:min(len(issues), maxLoggedIssues)] {
+			logger.Warn("快照引用问题",
+				zap.String("severity", string(issue.Severity)),
+				zap.String("kind", issue.Kind),
+				zap.String("key", issue.Key),
+				zap.String("ref", issue.Ref),
+				zap.String("message", issue.Message))
+		}
+	}
+	if strict && counts[cmdb.SeverityError] > 0 {
+		return domain.MarkError(domain.ErrValidation, fmt.Errorf("%w: %d 个错误, %d 个警告", ErrSnapshotInvalid, counts[cmdb.SeverityError], counts[cmdb.SeverityWarning]))
+	}
+	return nil
+}
@@ -1,18 +1,26 @@
 package cmdb
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"cmdb2neo/internal/domain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
 )
 
 // Client 抽象 CMDB 数据源。
@@ -20,6 +28,11 @@ type Client interface {
 	FetchSnapshot(ctx context.Context) (Snapshot, error)
 }
 
+// StreamClient 可选接口：按页流式回调快照片段，用于控制超大规模数据同步时的内存占用。
+type StreamClient interface {
+	StreamSnapshot(ctx context.Context, fn func(part Snapshot) error) (string, error)
+}
+
 // StaticClient 用于测试或最小实现，直接返回内存中的快照。
 type StaticClient struct {
 	Snapshot Snapshot
@@ -30,133 +43,45 @@ func (c *StaticClient) FetchSnapshot(context.Context) (Snapshot, error) {
 	return c.Snapshot, nil
 }
 
-// TokenSource 用于提供调用 CMDB 接口所需的 Token。
-type TokenSource interface {
-	Token(ctx context.Context) (string, error)
-}
-
-// StaticTokenSource 返回固定 Token，适用于测试或简易场景。
-type StaticTokenSource struct {
-	Value string
-}
-
-// Token 返回固定值。
-func (s *StaticTokenSource) Token(context.Context) (string, error) {
-	return s.Value, nil
-}
-
-// PasswordTokenSource 通过用户名/密码调用认证接口换取 Token，并带简单缓存。
-type PasswordTokenSource struct {
-	endpoint   string
-	username   string
-	password   string
-	httpClient *http.Client
-
-	mu     sync.Mutex
-	token  string
-	expiry time.Time
-}
-
-// PasswordTokenConfig 配置基于用户名/密码的 TokenSource。
-type PasswordTokenConfig struct {
-	Endpoint   string
-	Username   string
-	Password   string
-	Timeout    time.Duration
-	HTTPClient *http.Client
-}
-
-// NewPasswordTokenSource 创建一个 PasswordTokenSource。
-func NewPasswordTokenSource(cfg PasswordTokenConfig) (*PasswordTokenSource, error) {
-	if strings.TrimSpace(cfg.Endpoint) == "" {
-		return nil, errors.New("token endpoint 不能为空")
-	}
-	if cfg.Username == "" || cfg.Password == "" {
-		return nil, errors.New("用户名和密码不能为空")
-	}
-	client := cfg.HTTPClient
-	if client == nil {
-		timeout := cfg.Timeout
-		if timeout <= 0 {
-			timeout = 5 * time.Second
-		}
-		client = &http.Client{Timeout: timeout}
-	}
-	return &PasswordTokenSource{
-		endpoint:   cfg.Endpoint,
-		username:   cfg.Username,
-		password:   cfg.Password,
-		httpClient: client,
-	}, nil
-}
-
-// Token 实现 TokenSource 接口，必要时刷新 Token。
-func (s *PasswordTokenSource) Token(ctx context.Context) (string, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if s.token != "" && time.Until(s.expiry) > 30*time.Second {
-		return s.token, nil
-	}
-	return s.refresh(ctx)
-}
-
-func (s *PasswordTokenSource) refresh(ctx context.Context) (string, error) {
-	body := map[string]string{
-		"username": s.username,
-		"password": s.password,
-	}
-	payload, err := json.Marshal(body)
-	if err != nil {
-		return "", fmt.Errorf("编码 token 请求失败: %w", err)
-	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(payload))
-	if err != nil {
-		return "", fmt.Errorf("构建 token 请求失败: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("获取 token 失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("token 接口返回状态码 %d", resp.StatusCode)
-	}
-
-	var tokenResp struct {
-		AccessToken string `json:"access_token"`
-		TokenType   string `json:"token_type"`
-		ExpiresIn   int64  `json:"expires_in"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return "", fmt.Errorf("解析 token 响应失败: %w", err)
-	}
-	if tokenResp.AccessToken == "" {
-		return "", errors.New("token 响应中缺少 access_token")
-	}
-	expires := time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
-	if tokenResp.ExpiresIn == 0 {
-		expires = time.Now().Add(30 * time.Minute)
-	}
-	s.token = tokenResp.AccessToken
-	s.expiry = expires
-	return s.token, nil
-}
-
 // HTTPClient 实现 Client，通过 HTTP 与 CMDB 通信。
 type HTTPClient struct {
 	baseURL     string
 	httpClient  *http.Client
 	tokenSource TokenSource
 	snapshotAPI string
-	authHeader  string
+	// partitionAPI 为空时不额外拉取网络分区 CIDR
+	partitionAPI string
+	authHeader   string
+	// authScheme 为 token 前的前缀，如 Bearer，为空时直接发送 token
+	authScheme string
+	logger     *zap.Logger
+	tracer     trace.Tracer
+
+	retryAttempts int
+	retryBackoff  time.Duration
+	workers       int
+	idcs          []string
+	pagination    PaginationMode
+	// maxInvalidRatio 见 HTTPConfig.MaxInvalidRatio
+	maxInvalidRatio float64
+
+	pageMu    sync.Mutex
+	pageCache map[string]cachedPage
+}
+
+// cachedPage 记录分页响应的 ETag 及解析后的数据，命中 304 时直接复用。
+type cachedPage struct {
+	etag string
+	data ResponseData
 }
 
+// AppObject 为机器上部署的一个应用，一条机器记录可携带多个。
 type AppObject struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Service string `json:"service,omitempty"`
+	// Instances 为可选字段，应用在该机器上的实例数。
+	Instances int `json:"instances,omitempty"`
 }
 
 type DataContent struct {
@@ -168,6 +93,10 @@ type DataContent struct {
 	HostName         string      `json:"host_name"`
 	HostIp           string      `json:"host_ip"`
 	AppObj           []AppObject `json:"app_obj"`
+	// NetworkPartitionCIDR 为可选字段，接口提供时直接作为所属网络分区的 CIDR。
+	NetworkPartitionCIDR string `json:"network_partition_cidr,omitempty"`
+	// Weight 为可选字段，虚拟机在宿主机上占用的资源权重。
+	Weight float64 `json:"weight,omitempty"`
 }
 
 type ResponseData struct {
@@ -175,8 +104,23 @@ type ResponseData struct {
 	Limit int           `json:"limit"`
 	Total int           `json:"total"`
 	Data  []DataContent `json:"data"`
+	// NextCursor 仅游标分页模式下使用，为空表示没有下一页。
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
+// AuthSchemeNone 作为 HTTPConfig.AuthScheme 时请求头只包含 token 本身，不带前缀。
+const AuthSchemeNone = "none"
+
+// PaginationMode 表示 CMDB 接口的分页方式。
+type PaginationMode string
+
+const (
+	// PaginationOffset 使用 page/limit/total 分页，为默认方式。
+	PaginationOffset PaginationMode = "offset"
+	// PaginationCursor 使用 next_cursor 游标分页。
+	PaginationCursor PaginationMode = "cursor"
+)
+
 type Request struct {
 	Code int          `json:"code"`
 	Data ResponseData `json:"data"`
@@ -185,12 +129,34 @@ type Request struct {
 
 // HTTPConfig 配置 HTTP 客户端。
 type HTTPConfig struct {
-	BaseURL        string
-	TokenSource    TokenSource
-	Timeout        time.Duration
-	CustomClient   *http.Client
-	SnapshotAPI    string
+	BaseURL     string
+	TokenSource TokenSource
+	Timeout     time.Duration
+	// CustomClient 优先于 TLS，注入后 Timeout 与 TLS 均不生效。
+	CustomClient *http.Client
+	// TLS 用于 mTLS 或自定义 CA，构建时即加载证书文件，加载失败返回错误。
+	TLS         TLSConfig
+	SnapshotAPI string
+	// PartitionAPI 为按 idc 查询网络分区 CIDR 的接口，为空时只使用分页数据中的 network_partition_cidr。
+	PartitionAPI   string
 	AuthHeaderName string
+	// AuthScheme 为请求头中 token 前的前缀，为空时取 Bearer，AuthSchemeNone 表示直接发送 token。
+	AuthScheme string
+	// RetryAttempts 为单次请求的最大尝试次数，仅对网络错误和 5xx 重试，<=1 表示不重试。
+	RetryAttempts int
+	// RetryBackoff 为首次重试前的等待时间，之后指数增长并带随机抖动。
+	RetryBackoff time.Duration
+	// Workers 为并发拉取 IDC 数据的协程数，<=0 时按 1 处理。
+	Workers int
+	// IDCs 为需要拉取的机房名称列表，不能为空。
+	IDCs []string
+	// PaginationMode 为空时按 offset 处理。
+	PaginationMode PaginationMode
+	// MaxInvalidRatio 为不符合约定的记录（未知 server_type、缺少 id 或 ip）允许的最大占比，超过时拉取失败；<=0 时只记录日志。
+	MaxInvalidRatio float64
+	Logger          *zap.Logger
+	// TracerProvider 为空时不产生 span。
+	TracerProvider trace.TracerProvider
 }
 
 // NewHTTPClient 根据配置创建 CMDB HTTP 客户端。
@@ -204,7 +170,13 @@ func NewHTTPClient(cfg HTTPConfig) (*HTTPClient, error) {
 		if timeout <= 0 {
 			timeout = 10 * time.Second
 		}
-		client = &http.Client{Timeout: timeout}
+		var err error
+		if client, err = cfg.TLS.Client(timeout); err != nil {
+			return nil, err
+		}
+		if cfg.TLS.InsecureSkipVerify && cfg.Logger != nil {
+			cfg.Logger.Warn("CMDB 已关闭 TLS 证书校验，仅可用于开发环境")
+		}
 	}
 	endpoint := cfg.SnapshotAPI
 	if endpoint == "" {
@@ -214,13 +186,48 @@ func NewHTTPClient(cfg HTTPConfig) (*HTTPClient, error) {
 	if strings.TrimSpace(authHeader) == "" {
 		authHeader = "Authorization"
 	}
+	authScheme := strings.TrimSpace(cfg.AuthScheme)
+	switch {
+	case authScheme == "":
+		authScheme = "Bearer"
+	case strings.EqualFold(authScheme, AuthSchemeNone):
+		authScheme = ""
+	}
+	pagination := cfg.PaginationMode
+	switch pagination {
+	case "":
+		pagination = PaginationOffset
+	case PaginationOffset, PaginationCursor:
+	default:
+		return nil, fmt.Errorf("不支持的分页方式 %q", pagination)
+	}
+	idcs := normalizeIDCs(cfg.IDCs)
+	if len(idcs) == 0 {
+		return nil, errors.New("cmdb idc 列表不能为空，请配置 sync.source.idcs")
+	}
+	tracerProvider := cfg.TracerProvider
+	if tracerProvider == nil {
+		tracerProvider = noop.NewTracerProvider()
+	}
 
 	return &HTTPClient{
-		baseURL:     strings.TrimRight(cfg.BaseURL, "/"),
-		httpClient:  client,
-		tokenSource: cfg.TokenSource,
-		snapshotAPI: endpoint,
-		authHeader:  authHeader,
+		baseURL:      strings.TrimRight(cfg.BaseURL, "/"),
+		httpClient:   client,
+		tokenSource:  cfg.TokenSource,
+		snapshotAPI:  endpoint,
+		partitionAPI: strings.TrimSpace(cfg.PartitionAPI),
+		authHeader:   authHeader,
+		authScheme:   authScheme,
+		logger:       cfg.Logger,
+		tracer:       tracerProvider.Tracer("cmdb2neo"),
+
+		retryAttempts:   cfg.RetryAttempts,
+		retryBackoff:    cfg.RetryBackoff,
+		workers:         cfg.Workers,
+		idcs:            idcs,
+		pagination:      pagination,
+		maxInvalidRatio: cfg.MaxInvalidRatio,
+		pageCache:       make(map[string]cachedPage),
 	}, nil
 }
 
@@ -229,10 +236,24 @@ func (c *HTTPClient) FetchSnapshot(ctx context.Context) (Snapshot, error) {
 	if c == nil {
 		return Snapshot{}, errors.New("cmdb http client 未初始化")
 	}
+	ctx, span := c.tracer.Start(ctx, "cmdb.fetch_snapshot", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("cmdb.api", c.snapshotAPI),
+		attribute.Int("cmdb.idc_count", len(c.idcs)),
+	))
+	defer span.End()
+
 	var snapshot Snapshot
 	if err := c.getJSON(ctx, c.snapshotAPI, &snapshot); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return Snapshot{}, err
 	}
+	span.SetAttributes(
+		attribute.Int("cmdb.host_count", len(snapshot.HostMachines)),
+		attribute.Int("cmdb.physical_count", len(snapshot.PhysicalMachines)),
+		attribute.Int("cmdb.vm_count", len(snapshot.VirtualMachines)),
+		attribute.Int("cmdb.app_count", len(snapshot.Apps)),
+	)
 	return snapshot, nil
 }
 
@@ -246,144 +267,379 @@ func (c *HTTPClient) getJSON(ctx context.Context, path string, out any) error {
 		return nil
 	}
 
+	return c.withRetry(ctx, path, func() error {
+		return c.getJSONOnce(ctx, path, out)
+	})
+}
+
+// authorize 按配置的请求头名称与前缀附加 token，未配置 TokenSource 或 token 为空时不设置。
+func (c *HTTPClient) authorize(ctx context.Context, req *http.Request) error {
+	if c.tokenSource == nil {
+		return nil
+	}
+	token, err := c.tokenSource.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("获取 token 失败: %w", err)
+	}
+	if token == "" {
+		return nil
+	}
+	if c.authScheme != "" {
+		token = c.authScheme + " " + token
+	}
+	req.Header.Set(c.authHeader, token)
+	return nil
+}
+
+func (c *HTTPClient) getJSONOnce(ctx context.Context, path string, out any) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
 	if err != nil {
 		return fmt.Errorf("构建请求失败: %w", err)
 	}
 	req.Header.Set("Accept", "application/json")
-	if c.tokenSource != nil {
-		token, err := c.tokenSource.Token(ctx)
-		if err != nil {
-			return fmt.Errorf("获取 token 失败: %w", err)
-		}
-		if token != "" {
-			req.Header.Set(c.authHeader, "Bearer "+token)
-		}
+	if err := c.authorize(ctx, req); err != nil {
+		return err
 	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("请求 CMDB 失败: %w", err)
+		return retryable(fmt.Errorf("请求 CMDB 失败: %w", err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("CMDB 返回状态码 %d", resp.StatusCode)
+		return statusError(resp.StatusCode)
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
-		return fmt.Errorf("解析 CMDB 响应失败: %w", err)
+		return domain.MarkError(domain.ErrValidation, fmt.Errorf("解析 CMDB 响应失败: %w", err))
 	}
 	return nil
 }
 
 func (c *HTTPClient) fetchSnapshot(ctx context.Context, path string) (Snapshot, error) {
-	idcs := []string{"M5", "IDC1", "IDC2"}
-	snapshot := Snapshot{RunID: time.Now().UTC().Format("20060102T150405Z")}
+	idcs := c.idcs
+	snapshot := Snapshot{RunID: domain.NewRunID(time.Now())}
 
-	hostSeen := make(map[int]bool)
-	vmSeen := make(map[int]bool)
-	physicalSeen := make(map[int]bool)
-	appSeen := make(map[int]bool)
-	npIDs := make(map[string]int)
-	npCounter := 1
+	contentsByIDC, err := c.fetchIDCs(ctx, path, idcs)
+	if err != nil {
+		return Snapshot{}, err
+	}
 
+	// 按 IDC 列表顺序合并，保证结果与并发调度无关
+	builder := newSnapshotBuilder(c.warnInvalidCIDR)
 	for idx, idcName := range idcs {
-		snapshot.IDCs = append(snapshot.IDCs, IDC{Id: idx + 1, Name: idcName, Location: idcName})
+		snapshot.IDCs = append(snapshot.IDCs, IDC{Id: IDCID(idcName), Name: idcName, Location: idcName})
+		builder.setCIDRs(idcName, c.partitionCIDRs(ctx, idcName))
+		snapshot.Payload.Add(builder.add(&snapshot, idcName, contentsByIDC[idx]))
+	}
+	if err := c.checkPayload(snapshot.Payload); err != nil {
+		return Snapshot{}, err
+	}
+
+	return snapshot, nil
+}
 
-		contents, err := c.fetchAllPagesForIDC(ctx, path, idcName)
+// StreamSnapshot 逐页拉取 CMDB 数据，每页去重后以局部快照回调 fn，不在内存中保留完整快照。
+// 返回本次同步的批次号，供后续补边与清理使用。
+func (c *HTTPClient) StreamSnapshot(ctx context.Context, fn func(part Snapshot) error) (string, error) {
+	if c == nil {
+		return "", errors.New("cmdb http client 未初始化")
+	}
+	idcs := c.idcs
+	runID := domain.NewRunID(time.Now())
+
+	builder := newSnapshotBuilder(c.warnInvalidCIDR)
+	var payload PayloadStats
+	for _, idcName := range idcs {
+		builder.setCIDRs(idcName, c.partitionCIDRs(ctx, idcName))
+		if err := fn(Snapshot{RunID: runID, IDCs: []IDC{{Id: IDCID(idcName), Name: idcName, Location: idcName}}}); err != nil {
+			return "", err
+		}
+		err := c.eachPageForIDC(ctx, c.snapshotAPI, idcName, func(items []DataContent) error {
+			part := Snapshot{RunID: runID}
+			part.Payload = builder.add(&part, idcName, items)
+			payload.Add(part.Payload)
+			return fn(part)
+		})
 		if err != nil {
-			return Snapshot{}, err
+			return "", err
 		}
+	}
+	// 流式拉取时各页已交给调用方写入，超过阈值时在返回批次号前报错，调用方据此跳过清理
+	if err := c.checkPayload(payload); err != nil {
+		return "", err
+	}
+	return runID, nil
+}
 
-		for _, item := range contents {
-			npKey := idcName + ":" + item.NetworkPartition
-			if item.NetworkPartition != "" {
-				if _, exists := npIDs[npKey]; !exists {
-					snapshot.NetworkPartitions = append(snapshot.NetworkPartitions, NetworkPartition{
-						Id:   npCounter,
-						Idc:  idcName,
-						Name: item.NetworkPartition,
-						CIDR: "",
-					})
-					npIDs[npKey] = npCounter
-					npCounter++
-				}
+// legacyIDCIDs 为改为按名称派生 ID 之前固定机房列表的位置 ID，这些机房沿用旧 ID，升级后 cmdb_key 不变。
+var legacyIDCIDs = map[string]int{"M5": 1, "IDC1": 2, "IDC2": 3}
+
+// IDCID 由机房名称派生稳定的数值 ID，保证同一机房在不同批次、不同配置顺序下 cmdb_key 不变；
+// 早期内置的机房沿用 legacyIDCIDs 中的 ID。
+func IDCID(name string) int {
+	if id, ok := legacyIDCIDs[name]; ok {
+		return id
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return int(h.Sum32() & 0x7fffffff)
+}
+
+// AppNaturalKey 由应用名称与所在机器 IP 派生自然键，用于 CMDB 未返回应用 id 的情况，
+// 使应用顺序或机器 id 变化时 cmdb_key 不变；带 "n:" 前缀，与数值 ID 生成的 key 互不重叠。
+func AppNaturalKey(name, ip string) string {
+	return "n:" + name + "@" + domain.NormalizeIP(ip)
+}
+
+// normalizeIDCs 去除空白与重复项，保持配置顺序。
+func normalizeIDCs(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	return out
+}
+
+// snapshotBuilder 把分页数据转换为快照实体，并跨页去重。
+type snapshotBuilder struct {
+	hostSeen      map[int]bool
+	vmSeen        map[int]bool
+	physicalSeen  map[int]bool
+	containerSeen map[int]bool
+	appSeen       map[string]bool
+	npIDs         map[string]int
+	npCounter     int
+	// cidrs 以 idc:分区名 为键，记录网络分区接口返回的 CIDR
+	cidrs map[string]string
+	// onInvalidCIDR 在分页数据中的 CIDR 无效时回调
+	onInvalidCIDR func(idc, partition string, err error)
+}
+
+func newSnapshotBuilder(onInvalidCIDR func(idc, partition string, err error)) *snapshotBuilder {
+	return &snapshotBuilder{
+		cidrs:         make(map[string]string),
+		onInvalidCIDR: onInvalidCIDR,
+		hostSeen:      make(map[int]bool),
+		vmSeen:        make(map[int]bool),
+		physicalSeen:  make(map[int]bool),
+		containerSeen: make(map[int]bool),
+		appSeen:       make(map[string]bool),
+		npIDs:         make(map[string]int),
+		npCounter:     1,
+	}
+}
+
+// setCIDRs 记录 idc 下各分区的 CIDR，分页数据自带 CIDR 时以分页数据为准。
+func (b *snapshotBuilder) setCIDRs(idcName string, cidrs map[string]string) {
+	for name, cidr := range cidrs {
+		b.cidrs[idcName+":"+name] = cidr
+	}
+}
+
+// cidr 返回分区的 CIDR：优先使用分页数据中的 network_partition_cidr，无效或缺失时回退到网络分区接口的结果。
+func (b *snapshotBuilder) cidr(npKey, idcName string, item DataContent) string {
+	if item.NetworkPartitionCIDR != "" {
+		cidr, err := NormalizeCIDR(item.NetworkPartitionCIDR)
+		if err == nil {
+			return cidr
+		}
+		if b.onInvalidCIDR != nil {
+			b.onInvalidCIDR(idcName, item.NetworkPartition, err)
+		}
+	}
+	return b.cidrs[npKey]
+}
+
+// add 把一批记录加入快照，返回这批记录的契约检查统计。
+func (b *snapshotBuilder) add(snapshot *Snapshot, idcName string, items []DataContent) PayloadStats {
+	var stats PayloadStats
+	for _, item := range items {
+		stats.observe(item)
+		npKey := idcName + ":" + item.NetworkPartition
+		if item.NetworkPartition != "" {
+			if _, exists := b.npIDs[npKey]; !exists {
+				snapshot.NetworkPartitions = append(snapshot.NetworkPartitions, NetworkPartition{
+					Id:   b.npCounter,
+					Idc:  idcName,
+					Name: item.NetworkPartition,
+					CIDR: b.cidr(npKey, idcName, item),
+				})
+				b.npIDs[npKey] = b.npCounter
+				b.npCounter++
 			}
+		}
 
-			switch item.ServerType {
-			case 1:
-				if !hostSeen[item.Id] {
-					snapshot.HostMachines = append(snapshot.HostMachines, HostMachine{
-						Id:             item.Id,
-						Idc:            idcName,
-						NetworkPartion: item.NetworkPartition,
-						ServerType:     strconv.Itoa(item.ServerType),
-						Ip:             item.Ip,
-						Hostname:       item.HostName,
-					})
-					hostSeen[item.Id] = true
-				}
-			case 2:
-				if !vmSeen[item.Id] {
-					snapshot.VirtualMachines = append(snapshot.VirtualMachines, VirtualMachine{
-						Id:             item.Id,
-						Idc:            idcName,
-						NetworkPartion: item.NetworkPartition,
-						ServerType:     strconv.Itoa(item.ServerType),
-						Ip:             item.Ip,
-						Hostname:       item.HostName,
-						HostIp:         item.HostIp,
-					})
-					vmSeen[item.Id] = true
-				}
-			case 3:
-				if !physicalSeen[item.Id] {
-					snapshot.PhysicalMachines = append(snapshot.PhysicalMachines, PhysicalMachine{
-						Id:             item.Id,
-						Idc:            idcName,
-						NetworkPartion: item.NetworkPartition,
-						ServerType:     strconv.Itoa(item.ServerType),
-						Ip:             item.Ip,
-						Hostname:       item.HostName,
-					})
-					physicalSeen[item.Id] = true
+		switch item.ServerType {
+		case 1:
+			if !b.hostSeen[item.Id] {
+				snapshot.HostMachines = append(snapshot.HostMachines, HostMachine{
+					Id:             item.Id,
+					Idc:            idcName,
+					NetworkPartion: item.NetworkPartition,
+					ServerType:     strconv.Itoa(item.ServerType),
+					Ip:             item.Ip,
+					Hostname:       item.HostName,
+				})
+				b.hostSeen[item.Id] = true
+			}
+		case 2:
+			if !b.vmSeen[item.Id] {
+				snapshot.VirtualMachines = append(snapshot.VirtualMachines, VirtualMachine{
+					Id:             item.Id,
+					Idc:            idcName,
+					NetworkPartion: item.NetworkPartition,
+					ServerType:     strconv.Itoa(item.ServerType),
+					Ip:             item.Ip,
+					Hostname:       item.HostName,
+					HostIp:         item.HostIp,
+					Weight:         item.Weight,
+				})
+				b.vmSeen[item.Id] = true
+			}
+		case 3:
+			if !b.physicalSeen[item.Id] {
+				snapshot.PhysicalMachines = append(snapshot.PhysicalMachines, PhysicalMachine{
+					Id:             item.Id,
+					Idc:            idcName,
+					NetworkPartion: item.NetworkPartition,
+					ServerType:     strconv.Itoa(item.ServerType),
+					Ip:             item.Ip,
+					Hostname:       item.HostName,
+				})
+				b.physicalSeen[item.Id] = true
+			}
+		case 4:
+			if !b.containerSeen[item.Id] {
+				snapshot.Containers = append(snapshot.Containers, Container{
+					Id:             item.Id,
+					Idc:            idcName,
+					NetworkPartion: item.NetworkPartition,
+					ServerType:     strconv.Itoa(item.ServerType),
+					Ip:             item.Ip,
+					Hostname:       item.HostName,
+					HostIp:         item.HostIp,
+				})
+				b.containerSeen[item.Id] = true
+			}
+		}
+
+		// 机器上的每个应用各自成为一个 App，按 CMDB id 去重；缺少 id 时由名称与 IP 派生自然键，保证重复同步 key 不变
+		for idxApp, appInfo := range item.AppObj {
+			app := App{
+				Id:         appInfo.ID,
+				Ip:         item.Ip,
+				Name:       appInfo.Name,
+				ServerType: strconv.Itoa(item.ServerType),
+				Service:    strings.TrimSpace(appInfo.Service),
+				Instances:  appInfo.Instances,
+			}
+			if app.Id == 0 {
+				name := strings.TrimSpace(appInfo.Name)
+				if name == "" {
+					// 没有名称时只能以下标区分同一机器上的应用
+					name = "#" + strconv.Itoa(idxApp)
 				}
+				app.NaturalKey = AppNaturalKey(name, item.Ip)
+			}
+			seenKey := fmt.Sprint(app.KeyID())
+			if b.appSeen[seenKey] {
+				continue
+			}
+			if strings.TrimSpace(app.Name) == "" {
+				app.Name = "app-" + seenKey
 			}
+			snapshot.Apps = append(snapshot.Apps, app)
+			b.appSeen[seenKey] = true
+		}
+	}
+	return stats
+}
+
+// fetchIDCs 按 workers 并发拉取各 IDC 的全部分页，结果与 idcs 下标一一对应；
+// 任一 IDC 失败时取消其余请求并返回第一个错误。
+func (c *HTTPClient) fetchIDCs(ctx context.Context, path string, idcs []string) ([][]DataContent, error) {
+	workers := c.workers
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(idcs) {
+		workers = len(idcs)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-			if len(item.AppObj) > 0 {
-				for idxApp, appInfo := range item.AppObj {
-					appID := appInfo.ID
-					if appID == 0 {
-						appID = item.Id*100 + idxApp + 1
-					}
-					if appSeen[appID] {
-						continue
-					}
-					name := appInfo.Name
-					if strings.TrimSpace(name) == "" {
-						name = fmt.Sprintf("app-%d", appID)
-					}
-					snapshot.Apps = append(snapshot.Apps, App{
-						Id:         appID,
-						Ip:         item.Ip,
-						Name:       name,
-						ServerType: strconv.Itoa(item.ServerType),
+	results := make([][]DataContent, len(idcs))
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	jobs := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				contents, err := c.fetchAllPagesForIDC(ctx, path, idcs[idx])
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
 					})
-					appSeen[appID] = true
+					continue
 				}
+				results[idx] = contents
 			}
+		}()
+	}
+
+dispatch:
+	for idx := range idcs {
+		select {
+		case jobs <- idx:
+		case <-ctx.Done():
+			break dispatch
 		}
 	}
+	close(jobs)
+	wg.Wait()
 
-	return snapshot, nil
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
 }
 
 func (c *HTTPClient) fetchAllPagesForIDC(ctx context.Context, path, idc string) ([]DataContent, error) {
+	var allData []DataContent
+	err := c.eachPageForIDC(ctx, path, idc, func(items []DataContent) error {
+		allData = append(allData, items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allData, nil
+}
+
+// eachPageForIDC 顺序拉取某个 IDC 的全部分页，每拿到一页非空数据即回调 fn。
+func (c *HTTPClient) eachPageForIDC(ctx context.Context, path, idc string, fn func(items []DataContent) error) error {
 	endpoint := c.baseURL + path
 	parsed, err := url.Parse(endpoint)
 	if err != nil {
-		return nil, fmt.Errorf("解析请求地址失败: %w", err)
+		return fmt.Errorf("解析请求地址失败: %w", err)
 	}
 	query := parsed.Query()
 	if query.Get("limit") == "" {
@@ -393,8 +649,11 @@ func (c *HTTPClient) fetchAllPagesForIDC(ctx context.Context, path, idc string)
 		query.Set("idc", idc)
 	}
 
+	if c.pagination == PaginationCursor {
+		return c.eachCursorPage(ctx, parsed, query, fn)
+	}
+
 	var (
-		allData    []DataContent
 		page       = 1
 		pageLimit  = 0
 		totalItems = 0
@@ -404,53 +663,208 @@ func (c *HTTPClient) fetchAllPagesForIDC(ctx context.Context, path, idc string)
 		query.Set("page", strconv.Itoa(page))
 		parsed.RawQuery = query.Encode()
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+		data, err := c.fetchPage(ctx, parsed.String())
 		if err != nil {
-			return nil, fmt.Errorf("构建请求失败: %w", err)
-		}
-		req.Header.Set("Accept", "application/json")
-		if c.tokenSource != nil {
-			token, err := c.tokenSource.Token(ctx)
-			if err != nil {
-				return nil, fmt.Errorf("获取 token 失败: %w", err)
-			}
-			if token != "" {
-				req.Header.Set(c.authHeader, "Bearer "+token)
-			}
+			return err
 		}
 
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("请求 CMDB 失败: %w", err)
+		if len(data.Data) == 0 {
+			break
 		}
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return nil, fmt.Errorf("读取 CMDB 响应失败: %w", err)
+		if err := fn(data.Data); err != nil {
+			return err
 		}
 
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("CMDB 返回状态码 %d", resp.StatusCode)
+		pageLimit = data.Limit
+		totalItems = data.Total
+		if pageLimit > 0 && totalItems > 0 && page*pageLimit >= totalItems {
+			break
 		}
 
-		var payload Request
-		if err := json.Unmarshal(body, &payload); err != nil {
-			return nil, fmt.Errorf("解析 CMDB 响应失败: %w", err)
+		page++
+	}
+
+	return nil
+}
+
+// eachCursorPage 按 next_cursor 翻页，服务端返回空游标时结束。
+func (c *HTTPClient) eachCursorPage(ctx context.Context, parsed *url.URL, query url.Values, fn func(items []DataContent) error) error {
+	seen := make(map[string]bool)
+	cursor := ""
+	for {
+		if cursor != "" {
+			query.Set("cursor", cursor)
 		}
+		parsed.RawQuery = query.Encode()
 
-		if len(payload.Data.Data) == 0 {
-			break
+		data, err := c.fetchPage(ctx, parsed.String())
+		if err != nil {
+			return err
+		}
+		if len(data.Data) > 0 {
+			if err := fn(data.Data); err != nil {
+				return err
+			}
 		}
-		allData = append(allData, payload.Data.Data...)
 
-		pageLimit = payload.Data.Limit
-		totalItems = payload.Data.Total
-		if pageLimit > 0 && totalItems > 0 && page*pageLimit >= totalItems {
-			break
+		if data.NextCursor == "" {
+			return nil
 		}
+		if seen[data.NextCursor] {
+			return domain.MarkError(domain.ErrValidation, fmt.Errorf("CMDB 返回重复游标 %q，终止翻页", data.NextCursor))
+		}
+		seen[data.NextCursor] = true
+		cursor = data.NextCursor
+	}
+}
 
-		page++
+// fetchPage 拉取单页数据，携带上次响应的 ETag 发起条件请求，304 时复用缓存的解析结果。
+func (c *HTTPClient) fetchPage(ctx context.Context, pageURL string) (ResponseData, error) {
+	var data ResponseData
+	err := c.withRetry(ctx, pageURL, func() error {
+		var err error
+		data, err = c.fetchPageOnce(ctx, pageURL)
+		return err
+	})
+	return data, err
+}
+
+func (c *HTTPClient) fetchPageOnce(ctx context.Context, pageURL string) (ResponseData, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return ResponseData{}, fmt.Errorf("构建请求失败: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if err := c.authorize(ctx, req); err != nil {
+		return ResponseData{}, err
+	}
+	cached, hasCache := c.cachedPage(pageURL)
+	if hasCache {
+		req.Header.Set("If-None-Match", cached.etag)
 	}
 
-	return allData, nil
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return ResponseData{}, retryable(fmt.Errorf("请求 CMDB 失败: %w", err))
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return ResponseData{}, retryable(fmt.Errorf("读取 CMDB 响应失败: %w", err))
+	}
+
+	if resp.StatusCode == http.StatusNotModified && hasCache {
+		return cached.data, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ResponseData{}, statusError(resp.StatusCode)
+	}
+
+	var payload Request
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ResponseData{}, domain.MarkError(domain.ErrValidation, fmt.Errorf("解析 CMDB 响应失败: %w", err))
+	}
+	c.storePage(pageURL, resp.Header.Get("ETag"), payload.Data)
+	return payload.Data, nil
+}
+
+func (c *HTTPClient) cachedPage(pageURL string) (cachedPage, bool) {
+	c.pageMu.Lock()
+	defer c.pageMu.Unlock()
+	entry, ok := c.pageCache[pageURL]
+	if !ok || entry.etag == "" {
+		return cachedPage{}, false
+	}
+	return entry, true
+}
+
+func (c *HTTPClient) storePage(pageURL, etag string, data ResponseData) {
+	c.pageMu.Lock()
+	defer c.pageMu.Unlock()
+	if c.pageCache == nil {
+		c.pageCache = make(map[string]cachedPage)
+	}
+	if etag == "" {
+		delete(c.pageCache, pageURL)
+		return
+	}
+	c.pageCache[pageURL] = cachedPage{etag: etag, data: data}
+}
+
+// retryableError 标记可重试的错误（网络错误、5xx），对调用方表现为 ErrCMDBUnavailable。
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+
+func (e *retryableError) Unwrap() error { return e.err }
+
+func (e *retryableError) Is(target error) bool { return target == ErrCMDBUnavailable }
+
+func retryable(err error) error {
+	return &retryableError{err: err}
+}
+
+// statusError 根据状态码构造错误，5xx 视为可重试，401/403 标记为 ErrCMDBAuth。
+func statusError(code int) error {
+	err := fmt.Errorf("CMDB 返回状态码 %d", code)
+	switch {
+	case code >= http.StatusInternalServerError:
+		return retryable(err)
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return domain.MarkError(ErrCMDBAuth, err)
+	}
+	return err
+}
+
+func isRetryable(err error) bool {
+	var target *retryableError
+	return errors.As(err, &target)
+}
+
+// withRetry 按配置对 fn 做指数退避重试，退避时间带随机抖动，避免并发请求同步打到服务端。
+func (c *HTTPClient) withRetry(ctx context.Context, target string, fn func() error) error {
+	attempts := c.retryAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	backoff := c.retryBackoff
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		err = fn()
+		if err == nil || !isRetryable(err) || attempt == attempts {
+			return err
+		}
+		wait := jitter(backoff)
+		if c.logger != nil {
+			c.logger.Warn("CMDB 请求失败，准备重试",
+				zap.String("target", target),
+				zap.Int("attempt", attempt),
+				zap.Int("attempts", attempts),
+				zap.Duration("wait", wait),
+				zap.Error(err))
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+// jitter 返回 [d/2, d) 区间内的随机时长。
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)))
 }
@@ -0,0 +1,153 @@
+package cmdb
+
+import (
+	"reflect"
+	"sort"
+
+	"cmdb2neo/internal/domain"
+)
+
+// SnapshotDiff 描述两次快照映射结果之间的差异，行数据均取自新快照（删除项取自旧快照）。
+type SnapshotDiff struct {
+	AddedNodes   []domain.NodeRow
+	ChangedNodes []domain.NodeRow
+	RemovedNodes []domain.NodeRow
+	AddedRels    []domain.RelRow
+	ChangedRels  []domain.RelRow
+	RemovedRels  []domain.RelRow
+}
+
+// Empty 判断两次快照是否完全一致。
+func (d SnapshotDiff) Empty() bool {
+	return len(d.AddedNodes)+len(d.ChangedNodes)+len(d.RemovedNodes)+
+		len(d.AddedRels)+len(d.ChangedRels)+len(d.RemovedRels) == 0
+}
+
+// UpsertNodes 返回需要写入的节点（新增与变更）。
+func (d SnapshotDiff) UpsertNodes() []domain.NodeRow {
+	return append(append([]domain.NodeRow(nil), d.AddedNodes...), d.ChangedNodes...)
+}
+
+// UpsertRels 返回需要写入的关系（新增与变更）。
+func (d SnapshotDiff) UpsertRels() []domain.RelRow {
+	return append(append([]domain.RelRow(nil), d.AddedRels...), d.ChangedRels...)
+}
+
+// DiffSnapshots 比较两次快照，按 cmdb_key 对比节点的标签与属性，
+// 按起点、类型、终点对比关系，属性级变化（如虚拟机迁移后 host_ip 变化）计为变更。
+func DiffSnapshots(prev, curr Snapshot, opts ...MapperOption) SnapshotDiff {
+	prevNodes, prevRels := BuildInitRows(prev, opts...)
+	currNodes, currRels := BuildInitRows(curr, opts...)
+	return diffRows(prevNodes, prevRels, currNodes, currRels, func(old, curr map[string]any) bool {
+		return reflect.DeepEqual(old, curr)
+	})
+}
+
+// DiffGraph 比较图中现有的节点与关系和快照应有的状态。图中的属性由读取方去掉元数据，
+// 只比较快照中出现的属性，数值类型统一后再比较，图中多余的属性不视为差异。
+func DiffGraph(graphNodes []domain.NodeRow, graphRels []domain.RelRow, snapshot Snapshot, opts ...MapperOption) SnapshotDiff {
+	nodes, rels := BuildInitRows(snapshot, opts...)
+	return diffRows(graphNodes, graphRels, nodes, rels, containsProperties)
+}
+
+func diffRows(prevNodes []domain.NodeRow, prevRels []domain.RelRow, currNodes []domain.NodeRow, currRels []domain.RelRow, same func(old, curr map[string]any) bool) SnapshotDiff {
+	var diff SnapshotDiff
+
+	prevNodeIndex := make(map[string]domain.NodeRow, len(prevNodes))
+	for _, row := range prevNodes {
+		prevNodeIndex[row.CMDBKey] = row
+	}
+	for _, row := range currNodes {
+		old, ok := prevNodeIndex[row.CMDBKey]
+		switch {
+		case !ok:
+			diff.AddedNodes = append(diff.AddedNodes, row)
+		case domain.JoinLabels(old.Labels) != domain.JoinLabels(row.Labels) || !same(old.Properties, row.Properties):
+			diff.ChangedNodes = append(diff.ChangedNodes, row)
+		}
+		delete(prevNodeIndex, row.CMDBKey)
+	}
+	for _, row := range prevNodeIndex {
+		diff.RemovedNodes = append(diff.RemovedNodes, row)
+	}
+	sort.Slice(diff.RemovedNodes, func(i, j int) bool { return diff.RemovedNodes[i].CMDBKey < diff.RemovedNodes[j].CMDBKey })
+
+	prevRelIndex := make(map[string]domain.RelRow, len(prevRels))
+	for _, row := range prevRels {
+		prevRelIndex[relKey(row)] = row
+	}
+	for _, row := range currRels {
+		key := relKey(row)
+		old, ok := prevRelIndex[key]
+		switch {
+		case !ok:
+			diff.AddedRels = append(diff.AddedRels, row)
+		case !same(old.Properties, row.Properties):
+			diff.ChangedRels = append(diff.ChangedRels, row)
+		}
+		delete(prevRelIndex, key)
+	}
+	for _, row := range prevRelIndex {
+		diff.RemovedRels = append(diff.RemovedRels, row)
+	}
+	sort.Slice(diff.RemovedRels, func(i, j int) bool { return relKey(diff.RemovedRels[i]) < relKey(diff.RemovedRels[j]) })
+
+	return diff
+}
+
+// containsProperties 判断 actual 是否包含 want 的全部属性且取值一致。
+func containsProperties(actual, want map[string]any) bool {
+	for k, v := range want {
+		got, ok := actual[k]
+		if !ok || !reflect.DeepEqual(normalizeValue(got), normalizeValue(v)) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeValue 统一 Neo4j 返回值与本地值的数值类型。
+func normalizeValue(v any) any {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case float32:
+		return float64(n)
+	}
+	return v
+}
+
+func relKey(row domain.RelRow) string {
+	return row.StartKey + "|" + row.Type + "|" + row.EndKey
+}
+
+// RetainRemoved 返回 curr 的副本，并补回 prev 中已不在 curr 的实体（按类型与 ID 判断），
+// 用于删除被推迟时保存基线：已写入的变化不再重复比较，下线实体在后续差异中仍会被识别。
+// 仍在 curr 中的实体因属性变化而失去的关系（如虚拟机迁移宿主机）无法保留，由对账流程清理。
+func RetainRemoved(prev, curr Snapshot) Snapshot {
+	out := curr
+	out.IDCs = retainRemoved(prev.IDCs, curr.IDCs, func(v IDC) any { return v.Id })
+	out.NetworkPartitions = retainRemoved(prev.NetworkPartitions, curr.NetworkPartitions, func(v NetworkPartition) any { return v.Id })
+	out.PhysicalMachines = retainRemoved(prev.PhysicalMachines, curr.PhysicalMachines, func(v PhysicalMachine) any { return v.Id })
+	out.HostMachines = retainRemoved(prev.HostMachines, curr.HostMachines, func(v HostMachine) any { return v.Id })
+	out.VirtualMachines = retainRemoved(prev.VirtualMachines, curr.VirtualMachines, func(v VirtualMachine) any { return v.Id })
+	out.Containers = retainRemoved(prev.Containers, curr.Containers, func(v Container) any { return v.Id })
+	out.Apps = retainRemoved(prev.Apps, curr.Apps, App.KeyID)
+	return out
+}
+
+func retainRemoved[T any](prev, curr []T, id func(T) any) []T {
+	seen := make(map[any]struct{}, len(curr))
+	for _, item := range curr {
+		seen[id(item)] = struct{}{}
+	}
+	out := append([]T(nil), curr...)
+	for _, item := range prev {
+		if _, ok := seen[id(item)]; !ok {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,10 @@
+package cmdb
+
+import "errors"
+
+var (
+	// ErrCMDBUnavailable 表示 CMDB 或认证接口暂时不可用（网络错误、5xx），重试可能恢复。
+	ErrCMDBUnavailable = errors.New("CMDB 服务不可用")
+	// ErrCMDBAuth 表示认证失败（凭据错误、401/403、token 响应缺失），需修正配置，重试无法自愈。
+	ErrCMDBAuth = errors.New("CMDB 认证失败")
+)
@@ -0,0 +1,250 @@
+package cmdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"cmdb2neo/internal/domain"
+)
+
+// 目录中各实体对应的文件名，idc.json 必须存在，其余缺失时按空处理。
+const (
+	fileIDC              = "idc.json"
+	fileNetworkPartition = "network_partition.json"
+	fileHostMachine      = "host_machine.json"
+	filePhysicalMachine  = "physical_machine.json"
+	fileVirtualMachine   = "virtual_machine.json"
+	fileContainer        = "container.json"
+	fileApp              = "app.json"
+	// fileRunID 可选，存在时其内容作为批次号。
+	fileRunID = "run_id"
+)
+
+// FileClient 从本地目录读取 JSON 文件组装快照，用于离线环境测试和故障回放。
+type FileClient struct {
+	dir string
+}
+
+// NewFileClient 创建基于目录的 CMDB 数据源。
+func NewFileClient(dir string) (*FileClient, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, errors.New("cmdb file_dir 不能为空")
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取快照目录失败: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s 不是目录", dir)
+	}
+	return &FileClient{dir: dir}, nil
+}
+
+// FetchSnapshot 读取目录下的 JSON 文件并校验引用完整性。
+func (c *FileClient) FetchSnapshot(ctx context.Context) (Snapshot, error) {
+	if c == nil {
+		return Snapshot{}, errors.New("cmdb file client 未初始化")
+	}
+	if err := ctx.Err(); err != nil {
+		return Snapshot{}, err
+	}
+
+	var (
+		idcs      []fileIDCRow
+		nps       []fileNPRow
+		hosts     []fileMachineRow
+		physicals []fileMachineRow
+		vms       []fileMachineRow
+		ctrs      []fileMachineRow
+		apps      []App
+	)
+	if err := c.readJSON(fileIDC, true, &idcs); err != nil {
+		return Snapshot{}, err
+	}
+	for _, item := range []struct {
+		name string
+		out  any
+	}{
+		{fileNetworkPartition, &nps},
+		{fileHostMachine, &hosts},
+		{filePhysicalMachine, &physicals},
+		{fileVirtualMachine, &vms},
+		{fileContainer, &ctrs},
+		{fileApp, &apps},
+	} {
+		if err := c.readJSON(item.name, false, item.out); err != nil {
+			return Snapshot{}, err
+		}
+	}
+
+	runID, err := c.runID()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	snapshot := Snapshot{RunID: runID}
+	idcNameToID := make(map[string]string, len(idcs))
+	for _, idc := range idcs {
+		snapshot.IDCs = append(snapshot.IDCs, IDC{Id: idc.Id, Name: idc.Name, Location: idc.Location})
+		idcNameToID[idc.Name] = strconv.Itoa(idc.Id)
+	}
+	mapIDC := func(idc string) string {
+		if mapped, ok := idcNameToID[idc]; ok {
+			return mapped
+		}
+		return idc
+	}
+
+	npNameToID := make(map[string]string, len(nps))
+	for _, np := range nps {
+		snapshot.NetworkPartitions = append(snapshot.NetworkPartitions, NetworkPartition{Id: np.Id, Idc: mapIDC(np.Idc), Name: np.Name, CIDR: np.CIDR})
+		npNameToID[np.Name] = strconv.Itoa(np.Id)
+	}
+	mapNP := func(np string) string {
+		if mapped, ok := npNameToID[np]; ok {
+			return mapped
+		}
+		return np
+	}
+
+	for _, h := range hosts {
+		snapshot.HostMachines = append(snapshot.HostMachines, HostMachine{
+			Id:             h.Id,
+			Idc:            mapIDC(h.Idc),
+			NetworkPartion: mapNP(h.NetworkPartition),
+			ServerType:     strconv.Itoa(h.ServerType),
+			Ip:             h.Ip,
+			Hostname:       h.HostName,
+		})
+	}
+	for _, p := range physicals {
+		snapshot.PhysicalMachines = append(snapshot.PhysicalMachines, PhysicalMachine{
+			Id:             p.Id,
+			Idc:            mapIDC(p.Idc),
+			NetworkPartion: mapNP(p.NetworkPartition),
+			ServerType:     strconv.Itoa(p.ServerType),
+			Ip:             p.Ip,
+			Hostname:       p.HostName,
+		})
+	}
+	for _, vm := range vms {
+		snapshot.VirtualMachines = append(snapshot.VirtualMachines, VirtualMachine{
+			Id:             vm.Id,
+			Idc:            mapIDC(vm.Idc),
+			NetworkPartion: mapNP(vm.NetworkPartition),
+			ServerType:     strconv.Itoa(vm.ServerType),
+			Ip:             vm.Ip,
+			Hostname:       vm.HostName,
+			HostIp:         vm.HostIp,
+		})
+	}
+	for _, ctr := range ctrs {
+		snapshot.Containers = append(snapshot.Containers, Container{
+			Id:             ctr.Id,
+			Idc:            mapIDC(ctr.Idc),
+			NetworkPartion: mapNP(ctr.NetworkPartition),
+			ServerType:     strconv.Itoa(ctr.ServerType),
+			Ip:             ctr.Ip,
+			Hostname:       ctr.HostName,
+			HostIp:         ctr.HostIp,
+		})
+	}
+	snapshot.Apps = apps
+
+	if err := checkFileSnapshot(snapshot); err != nil {
+		return Snapshot{}, domain.MarkError(domain.ErrValidation, fmt.Errorf("快照目录 %s 引用不完整: %w", c.dir, err))
+	}
+	return snapshot, nil
+}
+
+type fileIDCRow struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	Location string `json:"location"`
+}
+
+type fileNPRow struct {
+	Id   int    `json:"id"`
+	Idc  string `json:"idc"`
+	Name string `json:"Name"`
+	CIDR string `json:"CIDR"`
+}
+
+type fileMachineRow struct {
+	Id               int    `json:"id"`
+	Idc              string `json:"idc"`
+	NetworkPartition string `json:"network_partition"`
+	ServerType       int    `json:"server_type"`
+	Ip               string `json:"ip"`
+	HostName         string `json:"host_name"`
+	HostIp           string `json:"host_ip"`
+}
+
+func (c *FileClient) readJSON(name string, required bool, out any) error {
+	path := filepath.Join(c.dir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return nil
+		}
+		return fmt.Errorf("读取 %s 失败: %w", path, err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return domain.MarkError(domain.ErrValidation, fmt.Errorf("解析 %s 失败: %w", path, err))
+	}
+	return nil
+}
+
+// runID 优先读取 run_id 文件，否则取 JSON 文件最新的修改时间，保证目录不变时重复读取得到相同批次号。
+// run_id 文件须符合 domain.RunIDLayout，否则清理无法按批次号判断先后。
+func (c *FileClient) runID() (string, error) {
+	data, err := os.ReadFile(filepath.Join(c.dir, fileRunID))
+	if err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			if err := domain.ValidateRunID(id); err != nil {
+				return "", domain.MarkError(domain.ErrValidation, fmt.Errorf("run_id 文件: %w", err))
+			}
+			return id, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("读取 run_id 失败: %w", err)
+	}
+
+	var latest time.Time
+	for _, name := range []string{fileIDC, fileNetworkPartition, fileHostMachine, filePhysicalMachine, fileVirtualMachine, fileContainer, fileApp} {
+		info, err := os.Stat(filepath.Join(c.dir, name))
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest.UTC().Format(domain.RunIDLayout), nil
+}
+
+// checkFileSnapshot 用 ValidateSnapshot 校验目录快照，离线数据应当自洽，任何悬空引用都视为错误，汇总前若干个问题返回。
+func checkFileSnapshot(snapshot Snapshot) error {
+	issues := ValidateSnapshot(snapshot)
+	if len(issues) == 0 {
+		return nil
+	}
+	const maxShown = 5
+	problems := make([]string, 0, maxShown)
+	for _, issue := range issues[:min(len(issues), maxShown)] {
+		problems = append(problems, issue.Message)
+	}
+	msg := strings.Join(problems, "; ")
+	if len(issues) > maxShown {
+		msg += fmt.Sprintf(" 等共 %d 处", len(issues))
+	}
+	return errors.New(msg)
+}
@@ -8,21 +8,99 @@ import (
 )
 
 // BuildInitRows 根据 CMDB 快照生成建图所需的节点和关系。
-func BuildInitRows(snapshot Snapshot) ([]domain.NodeRow, []domain.RelRow) {
-	runID := snapshot.RunID
+func BuildInitRows(snapshot Snapshot, opts ...MapperOption) ([]domain.NodeRow, []domain.RelRow) {
+	return NewRowMapper(snapshot.RunID, opts...).Map(snapshot)
+}
+
+// MapperOption 配置 RowMapper 的可选项。
+type MapperOption func(*RowMapper)
+
+// WithSchema 按 schema 中的前缀生成 cmdb_key；行中的标签与关系类型始终为规范名，由 loader 写入时换算。
+func WithSchema(schema domain.Schema) MapperOption {
+	return func(m *RowMapper) {
+		m.schema = schema
+	}
+}
+
+// RowMapper 增量地把快照片段映射为节点和关系，跨片段保留 key 索引，
+// 供流式同步按页写入；引用尚未出现实体的关系会挂起，待目标出现后补齐。
+type RowMapper struct {
+	runID  string
+	now    time.Time
+	schema domain.Schema
+
+	idcKeyMap     map[string]string
+	npKeyMap      map[string]string
+	hostByIP      map[string]string
+	physicalByIP  map[string]string
+	vmKeyByIP     map[string]string
+	containerByIP map[string]string
+	serviceKeys   map[string]string
+
+	pendingVMs        []pendingRef
+	pendingContainers []pendingRef
+	pendingApps       []pendingRef
+}
+
+// pendingRef 记录暂未找到目标节点的关系端点，props 为解析后附加到关系上的快照属性。
+type pendingRef struct {
+	key        string
+	ip         string
+	serverType string
+	props      map[string]any
+}
+
+// NewRowMapper 创建映射器，runID 为空时按当前时间生成。
+func NewRowMapper(runID string, opts ...MapperOption) *RowMapper {
 	if runID == "" {
-		runID = time.Now().UTC().Format("20060102T150405Z")
+		runID = domain.NewRunID(time.Now())
+	}
+	m := &RowMapper{
+		runID:         runID,
+		now:           time.Now().UTC(),
+		idcKeyMap:     make(map[string]string),
+		npKeyMap:      make(map[string]string),
+		hostByIP:      make(map[string]string),
+		physicalByIP:  make(map[string]string),
+		vmKeyByIP:     make(map[string]string),
+		containerByIP: make(map[string]string),
+		serviceKeys:   make(map[string]string),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(m)
+		}
 	}
-	now := time.Now().UTC()
+	return m
+}
+
+// RunID 返回本次映射使用的批次号。
+func (m *RowMapper) RunID() string {
+	return m.runID
+}
+
+// Pending 返回仍未解析出关系的实体数量。
+func (m *RowMapper) Pending() int {
+	return len(m.pendingVMs) + len(m.pendingContainers) + len(m.pendingApps)
+}
 
-	nodes := make([]domain.NodeRow, 0, len(snapshot.IDCs)+len(snapshot.NetworkPartitions)+len(snapshot.PhysicalMachines)+len(snapshot.HostMachines)+len(snapshot.VirtualMachines)+len(snapshot.Apps))
-	rels := make([]domain.RelRow, 0, len(snapshot.NetworkPartitions)+len(snapshot.PhysicalMachines)+len(snapshot.HostMachines)+len(snapshot.VirtualMachines)+len(snapshot.Apps))
+// Map 映射一个快照片段，返回本片段新增的节点和关系。
+func (m *RowMapper) Map(snapshot Snapshot) ([]domain.NodeRow, []domain.RelRow) {
+	runID := m.runID
+	now := m.now
 
-	idcKeyMap := make(map[string]string, len(snapshot.IDCs))
+	nodes := make([]domain.NodeRow, 0, len(snapshot.IDCs)+len(snapshot.NetworkPartitions)+len(snapshot.PhysicalMachines)+len(snapshot.HostMachines)+len(snapshot.VirtualMachines)+len(snapshot.Containers)+len(snapshot.Apps))
+	rels := make([]domain.RelRow, 0, len(snapshot.NetworkPartitions)+len(snapshot.PhysicalMachines)+len(snapshot.HostMachines)+len(snapshot.VirtualMachines)+len(snapshot.Containers)+len(snapshot.Apps))
+
+	idcKeyMap := m.idcKeyMap
 	for _, idc := range snapshot.IDCs {
 		idStr := strconv.Itoa(idc.Id)
-		key := domain.MakeKey(domain.PrefixIDC, idc.Id)
+		key := m.schema.MakeKey(domain.LabelIDC, idc.Id)
 		idcKeyMap[idStr] = key
+		if idc.Name != "" {
+			// HTTP 数据源中分区只携带机房名称，按名称同样建立索引
+			idcKeyMap[idc.Name] = key
+		}
 		nodes = append(nodes, domain.NodeRow{
 			CMDBKey: key,
 			Labels:  []string{domain.LabelIDC},
@@ -36,10 +114,10 @@ func BuildInitRows(snapshot Snapshot) ([]domain.NodeRow, []domain.RelRow) {
 		})
 	}
 
-	npKeyMap := make(map[string]string, len(snapshot.NetworkPartitions))
+	npKeyMap := m.npKeyMap
 	for _, np := range snapshot.NetworkPartitions {
 		npStr := strconv.Itoa(np.Id)
-		key := domain.MakeKey(domain.PrefixNetPartition, np.Id)
+		key := m.schema.MakeKey(domain.LabelNetPartition, np.Id)
 		npKeyMap[npStr] = key
 		props := map[string]any{
 			"cmdb_id": np.Id,
@@ -66,9 +144,10 @@ func BuildInitRows(snapshot Snapshot) ([]domain.NodeRow, []domain.RelRow) {
 		})
 	}
 
-	hostByIP := make(map[string]string, len(snapshot.HostMachines))
+	hostByIP := m.hostByIP
 	for _, host := range snapshot.HostMachines {
-		key := domain.MakeKey(domain.PrefixHostMachine, host.Id)
+		host.Ip = domain.NormalizeIP(host.Ip)
+		key := m.schema.MakeKey(domain.LabelHostMachine, host.Id)
 		if host.Ip != "" {
 			hostByIP[host.Ip] = key
 		}
@@ -103,9 +182,10 @@ func BuildInitRows(snapshot Snapshot) ([]domain.NodeRow, []domain.RelRow) {
 		})
 	}
 
-	physicalByIP := make(map[string]string, len(snapshot.PhysicalMachines))
+	physicalByIP := m.physicalByIP
 	for _, pm := range snapshot.PhysicalMachines {
-		key := domain.MakeKey(domain.PrefixPhysical, pm.Id)
+		pm.Ip = domain.NormalizeIP(pm.Ip)
+		key := m.schema.MakeKey(domain.LabelPhysicalMachine, pm.Id)
 		if pm.Ip != "" {
 			physicalByIP[pm.Ip] = key
 		}
@@ -140,9 +220,10 @@ func BuildInitRows(snapshot Snapshot) ([]domain.NodeRow, []domain.RelRow) {
 		})
 	}
 
-	vmKeyByIP := make(map[string]string, len(snapshot.VirtualMachines))
+	vmKeyByIP := m.vmKeyByIP
 	for _, vm := range snapshot.VirtualMachines {
-		key := domain.MakeKey(domain.PrefixVirtual, vm.Id)
+		vm.Ip, vm.HostIp = domain.NormalizeIP(vm.Ip), domain.NormalizeIP(vm.HostIp)
+		key := m.schema.MakeKey(domain.LabelVirtualMachine, vm.Id)
 		if vm.Ip != "" {
 			vmKeyByIP[vm.Ip] = key
 		}
@@ -155,14 +236,16 @@ func BuildInitRows(snapshot Snapshot) ([]domain.NodeRow, []domain.RelRow) {
 			"network_partion": vm.NetworkPartion,
 			"server_type":     vm.ServerType,
 		}
-		if hostKey, ok := hostByIP[vm.HostIp]; ok && vm.HostIp != "" {
-			rels = append(rels, domain.RelRow{
-				StartKey:   hostKey,
-				EndKey:     key,
-				Type:       domain.RelHostsVM,
-				Properties: map[string]any{"via": "host_ip"},
-				RunID:      runID,
-			})
+		if vm.HostIp != "" {
+			ref := pendingRef{key: key, ip: vm.HostIp}
+			if vm.Weight > 0 {
+				ref.props = map[string]any{"weight": vm.Weight}
+			}
+			if rel, ok := m.vmRel(ref); ok {
+				rels = append(rels, rel)
+			} else {
+				m.pendingVMs = append(m.pendingVMs, ref)
+			}
 		}
 		nodes = append(nodes, domain.NodeRow{
 			CMDBKey: key,
@@ -176,49 +259,65 @@ func BuildInitRows(snapshot Snapshot) ([]domain.NodeRow, []domain.RelRow) {
 		})
 	}
 
+	for _, ctr := range snapshot.Containers {
+		ctr.Ip, ctr.HostIp = domain.NormalizeIP(ctr.Ip), domain.NormalizeIP(ctr.HostIp)
+		key := m.schema.MakeKey(domain.LabelContainer, ctr.Id)
+		if ctr.Ip != "" {
+			m.containerByIP[ctr.Ip] = key
+		}
+		props := map[string]any{
+			"cmdb_id":         ctr.Id,
+			"hostname":        ctr.Hostname,
+			"ip":              ctr.Ip,
+			"host_ip":         ctr.HostIp,
+			"idc":             ctr.Idc,
+			"network_partion": ctr.NetworkPartion,
+			"server_type":     ctr.ServerType,
+		}
+		if ctr.HostIp != "" {
+			ref := pendingRef{key: key, ip: ctr.HostIp}
+			if rel, ok := m.containerRel(ref); ok {
+				rels = append(rels, rel)
+			} else {
+				m.pendingContainers = append(m.pendingContainers, ref)
+			}
+		}
+		nodes = append(nodes, domain.NodeRow{
+			CMDBKey: key,
+			Labels: []string{
+				domain.LabelContainer,
+				domain.LabelCompute,
+			},
+			Properties: props,
+			RunID:      runID,
+			UpdatedAt:  now,
+		})
+	}
+
 	for _, app := range snapshot.Apps {
-		key := domain.MakeKey(domain.PrefixApp, app.Id)
+		app.Ip = domain.NormalizeIP(app.Ip)
+		key := m.schema.MakeKey(domain.LabelApp, app.KeyID())
 		props := map[string]any{
-			"cmdb_id": app.Id,
-			"name":    app.Name,
-			"ip":      app.Ip,
+			"name": app.Name,
+			"ip":   app.Ip,
+		}
+		// 派生自然键的应用没有 CMDB id，不写 cmdb_id，避免与真实 id 混淆
+		if app.NaturalKey == "" {
+			props["cmdb_id"] = app.Id
 		}
 		if app.ServerType != "" {
 			props["server_type"] = app.ServerType
 		}
 
 		if app.Ip != "" {
-			addRelation := func(targetKey, via string) {
-				rels = append(rels, domain.RelRow{
-					StartKey:   key,
-					EndKey:     targetKey,
-					Type:       domain.RelAppDeploy,
-					Properties: map[string]any{"via": via},
-					RunID:      runID,
-				})
+			ref := pendingRef{key: key, ip: app.Ip, serverType: app.ServerType}
+			if app.Instances > 0 {
+				ref.props = map[string]any{"instances": app.Instances}
 			}
-
-			switch app.ServerType {
-			case "1":
-				if hostKey, ok := hostByIP[app.Ip]; ok {
-					addRelation(hostKey, "host_ip")
-				}
-			case "3":
-				if physicalKey, ok := physicalByIP[app.Ip]; ok {
-					addRelation(physicalKey, "physical_ip")
-				}
-			case "2":
-				if vmKey, ok := vmKeyByIP[app.Ip]; ok {
-					addRelation(vmKey, "vm_ip")
-				}
-			default:
-				if vmKey, ok := vmKeyByIP[app.Ip]; ok {
-					addRelation(vmKey, "vm_ip")
-				} else if hostKey, ok := hostByIP[app.Ip]; ok {
-					addRelation(hostKey, "host_ip")
-				} else if physicalKey, ok := physicalByIP[app.Ip]; ok {
-					addRelation(physicalKey, "physical_ip")
-				}
+			if rel, ok := m.appRel(ref); ok {
+				rels = append(rels, rel)
+			} else {
+				m.pendingApps = append(m.pendingApps, ref)
 			}
 		}
 
@@ -229,7 +328,134 @@ func BuildInitRows(snapshot Snapshot) ([]domain.NodeRow, []domain.RelRow) {
 			RunID:      runID,
 			UpdatedAt:  now,
 		})
+
+		if app.Service != "" {
+			serviceKey, seen := m.serviceKeys[app.Service]
+			if !seen {
+				// 服务节点只在首次出现时生成，跨分页共享
+				serviceKey = m.schema.MakeKey(domain.LabelService, app.Service)
+				m.serviceKeys[app.Service] = serviceKey
+				nodes = append(nodes, domain.NodeRow{
+					CMDBKey:    serviceKey,
+					Labels:     []string{domain.LabelService},
+					Properties: map[string]any{"name": app.Service},
+					RunID:      runID,
+					UpdatedAt:  now,
+				})
+			}
+			rels = append(rels, domain.RelRow{
+				StartKey:   key,
+				EndKey:     serviceKey,
+				Type:       domain.RelPartOf,
+				Properties: map[string]any{"source": "cmdb"},
+				RunID:      runID,
+			})
+		}
 	}
 
+	rels = append(rels, m.resolvePending()...)
 	return nodes, rels
 }
+
+// resolvePending 尝试为之前挂起的实体补齐关系，仍无法解析的继续挂起。
+func (m *RowMapper) resolvePending() []domain.RelRow {
+	var rels []domain.RelRow
+	m.pendingVMs = m.retry(m.pendingVMs, m.vmRel, &rels)
+	m.pendingContainers = m.retry(m.pendingContainers, m.containerRel, &rels)
+	m.pendingApps = m.retry(m.pendingApps, m.appRel, &rels)
+	return rels
+}
+
+func (m *RowMapper) retry(refs []pendingRef, resolve func(pendingRef) (domain.RelRow, bool), out *[]domain.RelRow) []pendingRef {
+	remaining := refs[:0]
+	for _, ref := range refs {
+		if rel, ok := resolve(ref); ok {
+			*out = append(*out, rel)
+			continue
+		}
+		remaining = append(remaining, ref)
+	}
+	return remaining
+}
+
+// vmRel 按 host_ip 为虚拟机找承载机器，优先宿主机，未命中时虚拟机可能直接跑在物理机上。
+func (m *RowMapper) vmRel(ref pendingRef) (domain.RelRow, bool) {
+	hostKey, via := m.hostByIP[ref.ip], "host_ip"
+	if hostKey == "" {
+		hostKey, via = m.physicalByIP[ref.ip], "physical_ip"
+	}
+	if hostKey == "" {
+		return domain.RelRow{}, false
+	}
+	return domain.RelRow{
+		StartKey:   hostKey,
+		EndKey:     ref.key,
+		Type:       domain.RelHostsVM,
+		Properties: ref.properties(via),
+		RunID:      m.runID,
+	}, true
+}
+
+// containerRel 按 host_ip 为容器找所在节点，优先虚拟机，未命中时容器直接跑在宿主机上。
+func (m *RowMapper) containerRel(ref pendingRef) (domain.RelRow, bool) {
+	targetKey, via := m.vmKeyByIP[ref.ip], "vm_ip"
+	if targetKey == "" {
+		targetKey, via = m.hostByIP[ref.ip], "host_ip"
+	}
+	if targetKey == "" {
+		return domain.RelRow{}, false
+	}
+	return domain.RelRow{
+		StartKey:   ref.key,
+		EndKey:     targetKey,
+		Type:       domain.RelRunsOn,
+		Properties: ref.properties(via),
+		RunID:      m.runID,
+	}, true
+}
+
+// appRel 按 server_type 指明的承载层绑定应用；未指明时按 虚拟机、宿主机、物理机、容器 的优先级取第一个 IP 命中的层，
+// 容器排在最后，避免新增容器改变已有应用的绑定。
+func (m *RowMapper) appRel(ref pendingRef) (domain.RelRow, bool) {
+	var targetKey, via string
+	switch ref.serverType {
+	case "1":
+		targetKey, via = m.hostByIP[ref.ip], "host_ip"
+	case "3":
+		targetKey, via = m.physicalByIP[ref.ip], "physical_ip"
+	case "2":
+		targetKey, via = m.vmKeyByIP[ref.ip], "vm_ip"
+	case "4":
+		targetKey, via = m.containerByIP[ref.ip], "container_ip"
+	default:
+		if vmKey, ok := m.vmKeyByIP[ref.ip]; ok {
+			targetKey, via = vmKey, "vm_ip"
+		} else if hostKey, ok := m.hostByIP[ref.ip]; ok {
+			targetKey, via = hostKey, "host_ip"
+		} else if physicalKey, ok := m.physicalByIP[ref.ip]; ok {
+			targetKey, via = physicalKey, "physical_ip"
+		} else if containerKey, ok := m.containerByIP[ref.ip]; ok {
+			targetKey, via = containerKey, "container_ip"
+		}
+	}
+	if targetKey == "" {
+		return domain.RelRow{}, false
+	}
+	return domain.RelRow{
+		StartKey:   ref.key,
+		EndKey:     targetKey,
+		Type:       domain.RelAppDeploy,
+		Properties: ref.properties(via),
+		RunID:      m.runID,
+	}, true
+}
+
+// properties 合并关系的来源与快照中的数值属性。
+func (r pendingRef) properties(via string) map[string]any {
+	props := make(map[string]any, len(r.props)+1)
+	for k, v := range r.props {
+		props[k] = v
+	}
+	props["via"] = via
+	return props
+}
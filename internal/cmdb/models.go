@@ -44,14 +44,42 @@ type VirtualMachine struct {
 	Ip             string `json:"ip"`
 	Hostname       string `json:"hostname"`
 	HostIp         string `json:"host_ip"`
+	// Weight 为虚拟机在宿主机上占用的资源权重，大于 0 时写入 HOSTS_VM 的 weight 属性。
+	Weight float64 `json:"weight,omitempty"`
 }
 
-// App 表示应用。
+// Container 表示容器（Pod），HostIp 为其所在的虚拟机或宿主机 IP。
+type Container struct {
+	Id             int    `json:"id"`
+	Idc            string `json:"idc"`
+	NetworkPartion string `json:"network_partion"`
+	ServerType     string `json:"server_type"`
+	Ip             string `json:"ip"`
+	Hostname       string `json:"hostname"`
+	HostIp         string `json:"host_ip"`
+}
+
+// App 表示应用，以 CMDB id 区分；同一台机器可部署多个应用，它们共享 Ip，各自建节点与 DEPLOYED_ON 关系。
 type App struct {
-	Id         int    `json:"id"`
+	Id int `json:"id"`
+	// NaturalKey 为 CMDB 未返回 id 时由名称与 IP 派生的自然键（见 AppNaturalKey），此时 Id 为 0；
+	// 与数值 ID 分开保存，派生的应用不会与真实 id 的应用冲突。
+	NaturalKey string `json:"natural_key,omitempty"`
 	Ip         string `json:"ip"`
 	Name       string `json:"name"`
 	ServerType string `json:"server_type"`
+	// Service 为应用所属的服务（集群）名称，为空时不建立服务节点。
+	Service string `json:"service,omitempty"`
+	// Instances 为应用在该机器上的实例数，大于 0 时写入 DEPLOYED_ON 的 instances 属性。
+	Instances int `json:"instances,omitempty"`
+}
+
+// KeyID 返回生成 cmdb_key 使用的 ID，有自然键时使用自然键，否则使用 CMDB id。
+func (a App) KeyID() any {
+	if a.NaturalKey != "" {
+		return a.NaturalKey
+	}
+	return a.Id
 }
 
 // Snapshot 汇总快照数据。
@@ -62,5 +90,8 @@ type Snapshot struct {
 	PhysicalMachines  []PhysicalMachine
 	HostMachines      []HostMachine
 	VirtualMachines   []VirtualMachine
+	Containers        []Container
 	Apps              []App
+	// Payload 为 HTTP 数据源对分页记录的契约检查统计，不随快照持久化。
+	Payload PayloadStats `json:"-"`
 }
@@ -0,0 +1,111 @@
+package cmdb
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// PartitionContent 为网络分区接口返回的一条分区信息。
+type PartitionContent struct {
+	Name string `json:"name"`
+	CIDR string `json:"cidr"`
+}
+
+// PartitionRequest 为网络分区接口的响应。
+type PartitionRequest struct {
+	Code int                `json:"code"`
+	Data []PartitionContent `json:"data"`
+	Msg  string             `json:"msg"`
+}
+
+// NormalizeCIDR 用 net/netip 校验 CIDR 并返回规范形式（主机位清零），空串返回空串。
+func NormalizeCIDR(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	prefix, err := netip.ParsePrefix(raw)
+	if err != nil {
+		return "", fmt.Errorf("无效的 CIDR %q: %w", raw, err)
+	}
+	return prefix.Masked().String(), nil
+}
+
+// MatchPartition 返回 CIDR 包含 ip 的网络分区，多个分区重叠时取前缀最长者；ip 无效或没有分区包含它时 ok=false。
+// 用于告警只带 IP、缺少所属分区信息时补全分区，CIDR 为空或无效的分区不参与匹配。
+func MatchPartition(partitions []NetworkPartition, ip string) (match NetworkPartition, ok bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return NetworkPartition{}, false
+	}
+	addr = addr.Unmap()
+	best := -1
+	for _, np := range partitions {
+		prefix, err := netip.ParsePrefix(np.CIDR)
+		if err != nil || !prefix.Contains(addr) {
+			continue
+		}
+		if prefix.Bits() > best {
+			best = prefix.Bits()
+			match = np
+		}
+	}
+	return match, best >= 0
+}
+
+// fetchPartitionCIDRs 调用网络分区接口拉取 idc 下各分区的 CIDR，返回 分区名 -> CIDR，无效的 CIDR 记录告警后跳过。
+func (c *HTTPClient) fetchPartitionCIDRs(ctx context.Context, idc string) (map[string]string, error) {
+	parsed, err := url.Parse(c.partitionAPI)
+	if err != nil {
+		return nil, fmt.Errorf("解析请求地址失败: %w", err)
+	}
+	query := parsed.Query()
+	query.Set("idc", idc)
+	parsed.RawQuery = query.Encode()
+	target := parsed.String()
+
+	var resp PartitionRequest
+	if err := c.withRetry(ctx, target, func() error {
+		return c.getJSONOnce(ctx, target, &resp)
+	}); err != nil {
+		return nil, err
+	}
+	cidrs := make(map[string]string, len(resp.Data))
+	for _, item := range resp.Data {
+		cidr, err := NormalizeCIDR(item.CIDR)
+		if err != nil {
+			c.warnInvalidCIDR(idc, item.Name, err)
+			continue
+		}
+		if item.Name != "" && cidr != "" {
+			cidrs[item.Name] = cidr
+		}
+	}
+	return cidrs, nil
+}
+
+// partitionCIDRs 在配置了网络分区接口时拉取 CIDR，失败只记录告警，不影响同步。
+func (c *HTTPClient) partitionCIDRs(ctx context.Context, idc string) map[string]string {
+	if c.partitionAPI == "" {
+		return nil
+	}
+	cidrs, err := c.fetchPartitionCIDRs(ctx, idc)
+	if err != nil {
+		if c.logger != nil {
+			c.logger.Warn("拉取网络分区 CIDR 失败", zap.String("idc", idc), zap.Error(err))
+		}
+		return nil
+	}
+	return cidrs
+}
+
+func (c *HTTPClient) warnInvalidCIDR(idc, partition string, err error) {
+	if c.logger != nil {
+		c.logger.Warn("跳过无效的网络分区 CIDR", zap.String("idc", idc), zap.String("partition", partition), zap.Error(err))
+	}
+}
@@ -0,0 +1,106 @@
+package cmdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"cmdb2neo/internal/domain"
+	"go.uber.org/zap"
+)
+
+// PayloadStats 统计 CMDB 分页数据中不符合约定的机器记录，用于发现上游接口契约变化。
+// server_type 不是 1/2/3/4 的记录不会生成机器节点；缺少 id 或 ip 的记录仍会写入，但 key 冲突或无法按 IP 关联。
+type PayloadStats struct {
+	// Records 为收到的机器记录数。
+	Records int `json:"records"`
+	// Invalid 为存在任一问题的记录数，同一记录的多个问题只计一次。
+	Invalid int `json:"invalid"`
+	// UnknownServerTypes 按 server_type 统计无法识别的记录数。
+	UnknownServerTypes map[int]int `json:"unknown_server_types,omitempty"`
+	MissingID          int         `json:"missing_id"`
+	MissingIP          int         `json:"missing_ip"`
+}
+
+// Add 累加另一批记录的统计。
+func (s *PayloadStats) Add(other PayloadStats) {
+	s.Records += other.Records
+	s.Invalid += other.Invalid
+	s.MissingID += other.MissingID
+	s.MissingIP += other.MissingIP
+	for serverType, n := range other.UnknownServerTypes {
+		if s.UnknownServerTypes == nil {
+			s.UnknownServerTypes = make(map[int]int)
+		}
+		s.UnknownServerTypes[serverType] += n
+	}
+}
+
+// Unknown 返回 server_type 无法识别的记录总数。
+func (s PayloadStats) Unknown() int {
+	total := 0
+	for _, n := range s.UnknownServerTypes {
+		total += n
+	}
+	return total
+}
+
+// InvalidRatio 返回问题记录占全部记录的比例，没有记录时为 0。
+func (s PayloadStats) InvalidRatio() float64 {
+	if s.Records == 0 {
+		return 0
+	}
+	return float64(s.Invalid) / float64(s.Records)
+}
+
+// observe 检查一条机器记录并计入统计。
+func (s *PayloadStats) observe(item DataContent) {
+	s.Records++
+	invalid := false
+	switch item.ServerType {
+	case 1, 2, 3, 4:
+	default:
+		if s.UnknownServerTypes == nil {
+			s.UnknownServerTypes = make(map[int]int)
+		}
+		s.UnknownServerTypes[item.ServerType]++
+		invalid = true
+	}
+	if item.Id == 0 {
+		s.MissingID++
+		invalid = true
+	}
+	if strings.TrimSpace(item.Ip) == "" {
+		s.MissingIP++
+		invalid = true
+	}
+	if invalid {
+		s.Invalid++
+	}
+}
+
+// checkPayload 记录不符合约定的记录，maxRatio > 0 且问题记录占比超过它时返回标记为 domain.ErrValidation 的错误。
+func (c *HTTPClient) checkPayload(stats PayloadStats) error {
+	if stats.Invalid == 0 {
+		return nil
+	}
+	if c.logger != nil {
+		types := make([]int, 0, len(stats.UnknownServerTypes))
+		for serverType := range stats.UnknownServerTypes {
+			types = append(types, serverType)
+		}
+		sort.Ints(types)
+		for _, serverType := range types {
+			c.logger.Warn("CMDB 返回无法识别的 server_type，对应机器未写入",
+				zap.Int("server_type", serverType), zap.Int("records", stats.UnknownServerTypes[serverType]))
+		}
+		if stats.MissingID > 0 || stats.MissingIP > 0 {
+			c.logger.Warn("CMDB 记录缺少必填字段", zap.Int("missing_id", stats.MissingID), zap.Int("missing_ip", stats.MissingIP))
+		}
+	}
+	if c.maxInvalidRatio > 0 && stats.InvalidRatio() > c.maxInvalidRatio {
+		return domain.MarkError(domain.ErrValidation, fmt.Errorf("CMDB 响应中不符合约定的记录 %d/%d(%.1f%%) 超过阈值 %.1f%%: 未知 server_type %d, 缺少 id %d, 缺少 ip %d",
+			stats.Invalid, stats.Records, stats.InvalidRatio()*100, c.maxInvalidRatio*100, stats.Unknown(), stats.MissingID, stats.MissingIP))
+	}
+	return nil
+}
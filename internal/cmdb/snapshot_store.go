@@ -0,0 +1,100 @@
+package cmdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SnapshotStore 保存上一次成功同步的快照，供增量同步计算差异。
+type SnapshotStore interface {
+	Load(ctx context.Context) (Snapshot, bool, error)
+	Save(ctx context.Context, snapshot Snapshot) error
+}
+
+// latestFile 记录最近一次快照的 RunID。
+const latestFile = "latest"
+
+// FileSnapshotStore 以 <run_id>.json 的形式把快照保存在本地目录，只保留最新一份。
+type FileSnapshotStore struct {
+	dir string
+}
+
+// NewFileSnapshotStore 创建基于目录的快照存储，目录不存在时自动创建。
+func NewFileSnapshotStore(dir string) (*FileSnapshotStore, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, errors.New("snapshot 目录不能为空")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建 snapshot 目录失败: %w", err)
+	}
+	return &FileSnapshotStore{dir: dir}, nil
+}
+
+// Load 读取最近一次保存的快照，不存在时返回 false。
+func (s *FileSnapshotStore) Load(context.Context) (Snapshot, bool, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, latestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, false, nil
+	}
+	if err != nil {
+		return Snapshot{}, false, fmt.Errorf("读取 snapshot 索引失败: %w", err)
+	}
+	runID := strings.TrimSpace(string(data))
+	if runID == "" {
+		return Snapshot{}, false, nil
+	}
+	raw, err := os.ReadFile(s.path(runID))
+	if err != nil {
+		return Snapshot{}, false, fmt.Errorf("读取 snapshot %s 失败: %w", runID, err)
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return Snapshot{}, false, fmt.Errorf("解析 snapshot %s 失败: %w", runID, err)
+	}
+	snapshot.RunID = runID
+	return snapshot, true, nil
+}
+
+// Save 写入快照并切换索引，成功后删除上一份快照文件。
+func (s *FileSnapshotStore) Save(_ context.Context, snapshot Snapshot) error {
+	if strings.TrimSpace(snapshot.RunID) == "" {
+		return errors.New("snapshot run_id 不能为空")
+	}
+	previous, _ := os.ReadFile(filepath.Join(s.dir, latestFile))
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("序列化 snapshot 失败: %w", err)
+	}
+	if err := writeFileAtomic(s.path(snapshot.RunID), data); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.dir, latestFile), []byte(snapshot.RunID)); err != nil {
+		return err
+	}
+	if prev := strings.TrimSpace(string(previous)); prev != "" && prev != snapshot.RunID {
+		_ = os.Remove(s.path(prev))
+	}
+	return nil
+}
+
+func (s *FileSnapshotStore) path(runID string) string {
+	return filepath.Join(s.dir, filepath.Base(runID)+".json")
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("替换 %s 失败: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package cmdb
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// TLSConfig 配置访问 CMDB 与认证接口时的 TLS，字段均为空时使用系统默认的 Transport。
+type TLSConfig struct {
+	// CertFile 与 KeyFile 为 mTLS 客户端证书与私钥的 PEM 文件，须同时配置。
+	CertFile string
+	KeyFile  string
+	// CAFile 为校验服务端证书的 CA 证书 PEM 文件，配置后替代系统根证书。
+	CAFile string
+	// InsecureSkipVerify 跳过服务端证书校验，仅用于开发环境。
+	InsecureSkipVerify bool
+}
+
+// Enabled 返回是否配置了任一 TLS 选项。
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || c.CAFile != "" || c.InsecureSkipVerify
+}
+
+// Build 加载证书文件并构建 tls.Config，文件缺失或内容无效时返回错误。
+func (c TLSConfig) Build() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: c.InsecureSkipVerify}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, errors.New("CMDB 客户端证书与私钥必须同时配置")
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载 CMDB 客户端证书失败 %s: %w", c.CertFile, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if c.CAFile != "" {
+		data, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 CMDB CA 证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("CMDB CA 证书 %s 中没有可用的 PEM 证书", c.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// Client 返回使用该 TLS 配置的 http.Client，未配置任何 TLS 选项时使用默认 Transport。
+func (c TLSConfig) Client(timeout time.Duration) (*http.Client, error) {
+	if !c.Enabled() {
+		return &http.Client{Timeout: timeout}, nil
+	}
+	tlsConfig, err := c.Build()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
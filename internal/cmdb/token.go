@@ -0,0 +1,364 @@
+package cmdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cmdb2neo/internal/domain"
+)
+
+// TokenSource 用于提供调用 CMDB 接口所需的 Token。
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticTokenSource 返回固定 Token，适用于测试或简易场景。
+type StaticTokenSource struct {
+	Value string
+}
+
+// Token 返回固定值。
+func (s *StaticTokenSource) Token(context.Context) (string, error) {
+	return s.Value, nil
+}
+
+// token 有效期相关的默认值。
+const (
+	// tokenMinValidity 为 token 可直接使用的最短剩余有效期，低于该值时调用方等待刷新完成；不超过有效期的四分之一。
+	tokenMinValidity = 30 * time.Second
+	// defaultTokenRefreshBefore 为提前刷新的窗口，剩余有效期低于该值时在后台刷新，调用方继续使用当前 token；不超过有效期的一半。
+	defaultTokenRefreshBefore = 2 * time.Minute
+	// defaultTokenTTL 用于响应既没有 expires_in 也没有缓存头的情况。
+	defaultTokenTTL = 30 * time.Minute
+	// defaultTokenTimeout 为未配置 Timeout 时认证请求的超时，注入的 HTTPClient 同样受此限制。
+	defaultTokenTimeout = 5 * time.Second
+	// tokenRetryBackoff 与 tokenMaxRetryBackoff 为刷新失败后再次请求认证接口前的等待，连续失败时翻倍直至上限。
+	tokenRetryBackoff    = time.Second
+	tokenMaxRetryBackoff = time.Minute
+)
+
+// tokenCache 缓存从认证接口换取的 token：同一时刻最多一个刷新请求，并发调用方共享其结果；
+// 临近过期时在后台提前刷新，不阻塞调用方；刷新失败后按退避等待，期间不再请求认证接口。
+type tokenCache struct {
+	fetch         func(ctx context.Context) (string, time.Time, error)
+	refreshBefore time.Duration
+	// timeout 为单次刷新的截止时间，刷新不随调用方取消，只能靠它结束挂起的请求
+	timeout time.Duration
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+	// window 与 minValidity 按当前 token 的有效期收紧，避免短有效期 token 一拿到就进入刷新窗口
+	window      time.Duration
+	minValidity time.Duration
+	inflight    *tokenCall
+	failures    int
+	retryAt     time.Time
+	lastErr     error
+}
+
+// tokenCall 为一次进行中的刷新，done 关闭后 token 与 err 可读。
+type tokenCall struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+func newTokenCache(refreshBefore, timeout time.Duration, fetch func(ctx context.Context) (string, time.Time, error)) *tokenCache {
+	if refreshBefore <= 0 {
+		refreshBefore = defaultTokenRefreshBefore
+	}
+	if timeout <= 0 {
+		timeout = defaultTokenTimeout
+	}
+	return &tokenCache{fetch: fetch, refreshBefore: refreshBefore, timeout: timeout}
+}
+
+// Token 在缓存的 token 足够新时直接返回；进入提前刷新窗口时触发后台刷新并返回当前 token；
+// 否则等待刷新完成，等待期间 ctx 取消只影响当前调用方，刷新请求本身继续供其他调用方使用。
+// 刷新失败后的退避期内，有可用 token 时继续使用，没有时直接返回上次的错误。
+func (c *tokenCache) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	now := time.Now()
+	backoff := now.Before(c.retryAt)
+	remaining := c.expiry.Sub(now)
+	if c.token != "" && remaining > c.minValidity {
+		token := c.token
+		if remaining <= c.window && !backoff {
+			c.startRefresh(ctx)
+		}
+		c.mu.Unlock()
+		return token, nil
+	}
+	if backoff && c.inflight == nil {
+		err := c.lastErr
+		c.mu.Unlock()
+		return "", err
+	}
+	call := c.startRefresh(ctx)
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// startRefresh 返回进行中的刷新，没有时新建一个，调用方须持有 mu。
+// 刷新使用不随调用方取消的 ctx，并以 timeout 为截止时间，注入的 http.Client 没有超时也不会永久挂起。
+func (c *tokenCache) startRefresh(ctx context.Context) *tokenCall {
+	if c.inflight != nil {
+		return c.inflight
+	}
+	call := &tokenCall{done: make(chan struct{})}
+	c.inflight = call
+	go func() {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+		token, expiry, err := c.fetch(fetchCtx)
+		cancel()
+		c.mu.Lock()
+		if err == nil {
+			c.store(token, expiry)
+		} else {
+			c.failures++
+			c.retryAt = time.Now().Add(retryBackoff(c.failures))
+			c.lastErr = err
+		}
+		c.inflight = nil
+		c.mu.Unlock()
+		call.token, call.err = token, err
+		close(call.done)
+	}()
+	return call
+}
+
+// store 保存新 token 并按其有效期收紧提前刷新窗口与最短可用期，调用方须持有 mu。
+func (c *tokenCache) store(token string, expiry time.Time) {
+	ttl := time.Until(expiry)
+	c.token, c.expiry = token, expiry
+	c.window = min(c.refreshBefore, ttl/2)
+	c.minValidity = min(tokenMinValidity, ttl/4)
+	c.failures, c.retryAt, c.lastErr = 0, time.Time{}, nil
+}
+
+// retryBackoff 返回第 failures 次连续刷新失败后的等待时间。
+func retryBackoff(failures int) time.Duration {
+	backoff := tokenRetryBackoff
+	for i := 1; i < failures && backoff < tokenMaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, tokenMaxRetryBackoff)
+}
+
+// PasswordTokenSource 通过用户名/密码调用认证接口换取 Token，请求体为 JSON，并带缓存。
+type PasswordTokenSource struct {
+	endpoint   string
+	username   string
+	password   string
+	httpClient *http.Client
+	cache      *tokenCache
+}
+
+// PasswordTokenConfig 配置基于用户名/密码的 TokenSource。
+type PasswordTokenConfig struct {
+	Endpoint   string
+	Username   string
+	Password   string
+	Timeout    time.Duration
+	HTTPClient *http.Client
+	// TLS 在未注入 HTTPClient 时生效，认证接口与 CMDB 在同一 mTLS 网络时与 HTTPConfig.TLS 相同。
+	TLS TLSConfig
+	// RefreshBefore 为提前刷新的窗口，<=0 时取 2 分钟，且不超过 token 有效期的一半；
+	// 剩余有效期不足 30 秒（短有效期 token 为有效期的四分之一）时同步等待刷新。
+	RefreshBefore time.Duration
+}
+
+// NewPasswordTokenSource 创建一个 PasswordTokenSource。
+func NewPasswordTokenSource(cfg PasswordTokenConfig) (*PasswordTokenSource, error) {
+	if strings.TrimSpace(cfg.Endpoint) == "" {
+		return nil, errors.New("token endpoint 不能为空")
+	}
+	if cfg.Username == "" || cfg.Password == "" {
+		return nil, errors.New("用户名和密码不能为空")
+	}
+	httpClient, err := tokenHTTPClient(cfg.HTTPClient, cfg.TLS, cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	s := &PasswordTokenSource{
+		endpoint:   cfg.Endpoint,
+		username:   cfg.Username,
+		password:   cfg.Password,
+		httpClient: httpClient,
+	}
+	s.cache = newTokenCache(cfg.RefreshBefore, cfg.Timeout, s.refresh)
+	return s, nil
+}
+
+// Token 实现 TokenSource 接口，必要时刷新 Token。
+func (s *PasswordTokenSource) Token(ctx context.Context) (string, error) {
+	return s.cache.Token(ctx)
+}
+
+func (s *PasswordTokenSource) refresh(ctx context.Context) (string, time.Time, error) {
+	body := map[string]string{
+		"username": s.username,
+		"password": s.password,
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("编码 token 请求失败: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("构建 token 请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return requestToken(s.httpClient, req)
+}
+
+// ClientCredentialsTokenSource 通过 OAuth2 client credentials 模式换取 Token，请求体为表单编码，并带缓存。
+type ClientCredentialsTokenSource struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	scope        string
+	httpClient   *http.Client
+	cache        *tokenCache
+}
+
+// ClientCredentialsConfig 配置 OAuth2 client credentials 模式的 TokenSource。
+type ClientCredentialsConfig struct {
+	Endpoint     string
+	ClientID     string
+	ClientSecret string
+	// Scope 可选，多个 scope 以空格分隔。
+	Scope      string
+	Timeout    time.Duration
+	HTTPClient *http.Client
+	TLS        TLSConfig
+	// RefreshBefore 含义同 PasswordTokenConfig.RefreshBefore。
+	RefreshBefore time.Duration
+}
+
+// NewClientCredentialsTokenSource 创建一个 ClientCredentialsTokenSource。
+func NewClientCredentialsTokenSource(cfg ClientCredentialsConfig) (*ClientCredentialsTokenSource, error) {
+	if strings.TrimSpace(cfg.Endpoint) == "" {
+		return nil, errors.New("token endpoint 不能为空")
+	}
+	if cfg.ClientID == "" || cfg.ClientSecret == "" {
+		return nil, errors.New("client_id 和 client_secret 不能为空")
+	}
+	httpClient, err := tokenHTTPClient(cfg.HTTPClient, cfg.TLS, cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	s := &ClientCredentialsTokenSource{
+		endpoint:     cfg.Endpoint,
+		clientID:     cfg.ClientID,
+		clientSecret: cfg.ClientSecret,
+		scope:        strings.TrimSpace(cfg.Scope),
+		httpClient:   httpClient,
+	}
+	s.cache = newTokenCache(cfg.RefreshBefore, cfg.Timeout, s.refresh)
+	return s, nil
+}
+
+// Token 实现 TokenSource 接口，必要时刷新 Token。
+func (s *ClientCredentialsTokenSource) Token(ctx context.Context) (string, error) {
+	return s.cache.Token(ctx)
+}
+
+func (s *ClientCredentialsTokenSource) refresh(ctx context.Context) (string, time.Time, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {s.clientID},
+		"client_secret": {s.clientSecret},
+	}
+	if s.scope != "" {
+		form.Set("scope", s.scope)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("构建 token 请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	return requestToken(s.httpClient, req)
+}
+
+func tokenHTTPClient(client *http.Client, tlsConfig TLSConfig, timeout time.Duration) (*http.Client, error) {
+	if client != nil {
+		return client, nil
+	}
+	if timeout <= 0 {
+		timeout = defaultTokenTimeout
+	}
+	return tlsConfig.Client(timeout)
+}
+
+// requestToken 发送认证请求并解析 access_token 与过期时间，两种认证方式的响应格式一致。
+func requestToken(client *http.Client, req *http.Request) (string, time.Time, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, domain.MarkError(ErrCMDBUnavailable, fmt.Errorf("获取 token 失败: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// 认证接口 5xx 视为暂时不可用，其余非 200 多为凭据问题
+		kind := ErrCMDBAuth
+		if resp.StatusCode >= http.StatusInternalServerError {
+			kind = ErrCMDBUnavailable
+		}
+		return "", time.Time{}, domain.MarkError(kind, fmt.Errorf("token 接口返回状态码 %d", resp.StatusCode))
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", time.Time{}, domain.MarkError(domain.ErrValidation, fmt.Errorf("解析 token 响应失败: %w", err))
+	}
+	if tokenResp.AccessToken == "" {
+		return "", time.Time{}, domain.MarkError(ErrCMDBAuth, errors.New("token 响应中缺少 access_token"))
+	}
+	now := time.Now()
+	ttl := time.Duration(tokenResp.ExpiresIn) * time.Second
+	if tokenResp.ExpiresIn == 0 {
+		ttl = headerTTL(resp.Header, now)
+	}
+	return tokenResp.AccessToken, now.Add(ttl), nil
+}
+
+// headerTTL 在 token 响应缺少 expires_in 时按 Cache-Control 的 max-age、其次 Expires 推算有效期，都没有时取 30 分钟。
+func headerTTL(header http.Header, now time.Time) time.Duration {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(directive), "=")
+		if !ok || !strings.EqualFold(name, "max-age") {
+			continue
+		}
+		if seconds, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		if at, err := http.ParseTime(expires); err == nil && at.After(now) {
+			return at.Sub(now)
+		}
+	}
+	return defaultTokenTTL
+}
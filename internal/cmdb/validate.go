@@ -0,0 +1,150 @@
+package cmdb
+
+import (
+	"fmt"
+	"strconv"
+
+	"cmdb2neo/internal/domain"
+)
+
+// Severity 表示校验问题的严重程度。
+type Severity string
+
+const (
+	// SeverityWarning 引用缺失只影响拓扑展示，不影响根因分析链路。
+	SeverityWarning Severity = "warning"
+	// SeverityError 引用缺失会产生孤立的计算节点，导致根因分析无法向上追溯。
+	SeverityError Severity = "error"
+)
+
+// 校验问题的类型，对应映射时会被丢弃的关系。
+const (
+	IssueNPIDC         = "np_idc"
+	IssueMachineNP     = "machine_np"
+	IssueVMHost        = "vm_host"
+	IssueContainerHost = "container_host"
+	IssueAppDeployed   = "app_deployed"
+)
+
+// ValidationIssue 描述快照中一处无法解析的引用。
+type ValidationIssue struct {
+	Severity Severity `json:"severity"`
+	Kind     string   `json:"kind"`
+	// Key 为引用方实体的 cmdb_key，Ref 为无法解析的引用值。
+	Key     string `json:"key"`
+	Ref     string `json:"ref"`
+	Message string `json:"message"`
+}
+
+// ValidateSnapshot 按 BuildInitRows 的解析规则检查快照的引用完整性，返回所有悬空引用。
+// 空引用视为未配置，不计入问题。
+func ValidateSnapshot(snapshot Snapshot) []ValidationIssue {
+	idcs := make(map[string]bool, len(snapshot.IDCs)*2)
+	for _, idc := range snapshot.IDCs {
+		idcs[strconv.Itoa(idc.Id)] = true
+		if idc.Name != "" {
+			idcs[idc.Name] = true
+		}
+	}
+	nps := make(map[string]bool, len(snapshot.NetworkPartitions))
+	for _, np := range snapshot.NetworkPartitions {
+		nps[strconv.Itoa(np.Id)] = true
+	}
+	hostIPs := make(map[string]bool, len(snapshot.HostMachines))
+	for _, h := range snapshot.HostMachines {
+		if h.Ip != "" {
+			hostIPs[domain.NormalizeIP(h.Ip)] = true
+		}
+	}
+	physicalIPs := make(map[string]bool, len(snapshot.PhysicalMachines))
+	for _, p := range snapshot.PhysicalMachines {
+		if p.Ip != "" {
+			physicalIPs[domain.NormalizeIP(p.Ip)] = true
+		}
+	}
+	vmIPs := make(map[string]bool, len(snapshot.VirtualMachines))
+	for _, vm := range snapshot.VirtualMachines {
+		if vm.Ip != "" {
+			vmIPs[domain.NormalizeIP(vm.Ip)] = true
+		}
+	}
+	containerIPs := make(map[string]bool, len(snapshot.Containers))
+	for _, ctr := range snapshot.Containers {
+		if ctr.Ip != "" {
+			containerIPs[domain.NormalizeIP(ctr.Ip)] = true
+		}
+	}
+
+	var issues []ValidationIssue
+	add := func(severity Severity, kind, key, ref, msg string) {
+		issues = append(issues, ValidationIssue{Severity: severity, Kind: kind, Key: key, Ref: ref, Message: msg})
+	}
+
+	for _, np := range snapshot.NetworkPartitions {
+		if np.Idc != "" && !idcs[np.Idc] {
+			add(SeverityWarning, IssueNPIDC, domain.MakeKey(domain.PrefixNetPartition, np.Id), np.Idc,
+				fmt.Sprintf("网络分区 %d 的机房 %q 不存在", np.Id, np.Idc))
+		}
+	}
+	checkNP := func(kind, prefix string, id int, np string) {
+		if np != "" && !nps[np] {
+			add(SeverityWarning, IssueMachineNP, domain.MakeKey(prefix, id), np,
+				fmt.Sprintf("%s %d 的网络分区 %q 不存在", kind, id, np))
+		}
+	}
+	for _, h := range snapshot.HostMachines {
+		checkNP("宿主机", domain.PrefixHostMachine, h.Id, h.NetworkPartion)
+	}
+	for _, p := range snapshot.PhysicalMachines {
+		checkNP("物理机", domain.PrefixPhysical, p.Id, p.NetworkPartion)
+	}
+	for _, vm := range snapshot.VirtualMachines {
+		checkNP("虚拟机", domain.PrefixVirtual, vm.Id, vm.NetworkPartion)
+		// 与映射一致，host_ip 未命中宿主机时虚拟机可能直接跑在物理机上
+		if ip := domain.NormalizeIP(vm.HostIp); ip != "" && !hostIPs[ip] && !physicalIPs[ip] {
+			add(SeverityError, IssueVMHost, domain.MakeKey(domain.PrefixVirtual, vm.Id), vm.HostIp,
+				fmt.Sprintf("虚拟机 %d 的宿主机或物理机 %s 不存在", vm.Id, vm.HostIp))
+		}
+	}
+	for _, ctr := range snapshot.Containers {
+		checkNP("容器", domain.PrefixContainer, ctr.Id, ctr.NetworkPartion)
+		if ip := domain.NormalizeIP(ctr.HostIp); ip != "" && !vmIPs[ip] && !hostIPs[ip] {
+			add(SeverityError, IssueContainerHost, domain.MakeKey(domain.PrefixContainer, ctr.Id), ctr.HostIp,
+				fmt.Sprintf("容器 %d 所在的虚拟机或宿主机 %s 不存在", ctr.Id, ctr.HostIp))
+		}
+	}
+
+	for _, app := range snapshot.Apps {
+		if app.Ip == "" {
+			continue
+		}
+		var found bool
+		ip := domain.NormalizeIP(app.Ip)
+		switch app.ServerType {
+		case "1":
+			found = hostIPs[ip]
+		case "2":
+			found = vmIPs[ip]
+		case "3":
+			found = physicalIPs[ip]
+		case "4":
+			found = containerIPs[ip]
+		default:
+			found = vmIPs[ip] || hostIPs[ip] || physicalIPs[ip] || containerIPs[ip]
+		}
+		if !found {
+			add(SeverityError, IssueAppDeployed, domain.MakeKey(domain.PrefixApp, app.KeyID()), app.Ip,
+				fmt.Sprintf("应用 %s(%v) 部署的机器 %s 不存在", app.Name, app.KeyID(), app.Ip))
+		}
+	}
+	return issues
+}
+
+// CountIssues 按严重程度统计问题数量。
+func CountIssues(issues []ValidationIssue) map[Severity]int {
+	counts := make(map[Severity]int, 2)
+	for _, issue := range issues {
+		counts[issue.Severity]++
+	}
+	return counts
+}
@@ -4,4 +4,30 @@ import "errors"
 
 var (
 	ErrEmptyPayload = errors.New("空数据，无法写入图数据库")
+	// ErrInvalidLabel 与 ErrInvalidRelType 表示标签或关系类型不在白名单内，不能拼入 Cypher。
+	ErrInvalidLabel   = errors.New("非法的节点标签")
+	ErrInvalidRelType = errors.New("非法的关系类型")
+	// ErrInvalidKeyPrefix 表示 cmdb_key 前缀不是合法的字母数字串。
+	ErrInvalidKeyPrefix = errors.New("非法的 cmdb_key 前缀")
+	// ErrInvalidRunID 表示批次号不是固定宽度的 UTC 时间，不能按字符串比较先后。
+	ErrInvalidRunID = errors.New("非法的批次号")
+	// ErrValidation 表示数据本身有问题（响应无法解析、违反约束等），重试无法自愈。
+	ErrValidation = errors.New("数据校验失败")
 )
+
+// MarkError 为 err 附加可供 errors.Is 判断的类别 kind，错误信息保持不变；err 为 nil 时返回 nil。
+func MarkError(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &markedError{kind: kind, err: err}
+}
+
+type markedError struct {
+	kind error
+	err  error
+}
+
+func (e *markedError) Error() string { return e.err.Error() }
+
+func (e *markedError) Unwrap() []error { return []error{e.err, e.kind} }
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// identifierPattern 为可以不加反引号直接写入 Cypher 的标识符。
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// knownLabels 为同步会写入的全部节点标签，包括 Machine、Compute 等辅助标签。
+var knownLabels = func() map[string]bool {
+	m := make(map[string]bool, len(EntityLabels)+2)
+	for _, label := range EntityLabels {
+		m[label] = true
+	}
+	m[LabelMachine] = true
+	m[LabelCompute] = true
+	return m
+}()
+
+// knownRelTypes 为同步会写入的全部关系类型。
+var knownRelTypes = func() map[string]bool {
+	m := make(map[string]bool, len(RelTypes))
+	for _, t := range RelTypes {
+		m[t] = true
+	}
+	return m
+}()
+
+// ValidateLabels 校验标签均为合法标识符且属于已知标签，标签会以字符串拼接的方式写入 Cypher 模板，
+// 任何来自外部数据的标签都必须先经过这里。
+func ValidateLabels(labels []string) error {
+	if len(labels) == 0 {
+		return fmt.Errorf("%w: 标签为空", ErrInvalidLabel)
+	}
+	for _, label := range labels {
+		if !identifierPattern.MatchString(label) || !knownLabels[label] {
+			return fmt.Errorf("%w %q", ErrInvalidLabel, label)
+		}
+	}
+	return nil
+}
+
+// ValidateRelType 校验关系类型为合法标识符且属于 RelTypes。
+func ValidateRelType(relType string) error {
+	if !identifierPattern.MatchString(relType) || !knownRelTypes[relType] {
+		return fmt.Errorf("%w %q", ErrInvalidRelType, relType)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package domain
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// NormalizeIP 返回 IP 的规范写法，使 2001:DB8:0:0:0:0:0:1 与 2001:db8::1 这类等价写法一致：
+// IPv6 小写并压缩零段，IPv4 映射的 IPv6 地址还原为 IPv4；无法解析的值去掉首尾空白后原样返回。
+func NormalizeIP(raw string) string {
+	raw = strings.TrimSpace(raw)
+	addr, err := netip.ParseAddr(raw)
+	if err != nil {
+		return raw
+	}
+	return addr.Unmap().String()
+}
@@ -12,7 +12,9 @@ const (
 	LabelPhysicalMachine = "PhysicalMachine"
 	LabelHostMachine     = "HostMachine"
 	LabelVirtualMachine  = "VirtualMachine"
+	LabelContainer       = "Container"
 	LabelApp             = "App"
+	LabelService         = "Service"
 	LabelMachine         = "Machine"
 	LabelCompute         = "Compute"
 
@@ -20,7 +22,45 @@ const (
 	RelHasHost      = "HAS_HOST"
 	RelHasPhysical  = "HAS_PHYSICAL"
 	RelHostsVM      = "HOSTS_VM"
+	RelRunsOn       = "RUNS_ON"
 	RelAppDeploy    = "DEPLOYED_ON"
+	RelPartOf       = "PART_OF"
+)
+
+// EntityLabels 为 CMDB 实体的主标签，用于限定清理等危险操作的范围。
+var EntityLabels = []string{
+	LabelIDC,
+	LabelNetPartition,
+	LabelPhysicalMachine,
+	LabelHostMachine,
+	LabelVirtualMachine,
+	LabelContainer,
+	LabelApp,
+	LabelService,
+}
+
+// RelTypes 为同步写入的全部关系类型。
+var RelTypes = []string{
+	RelHasPartition,
+	RelHasHost,
+	RelHasPhysical,
+	RelHostsVM,
+	RelRunsOn,
+	RelAppDeploy,
+	RelPartOf,
+}
+
+// 同步写入节点与关系时维护的元数据属性。每次 init/upsert 都把 last_seen_run_id 写为当前 RunID（新建与已存在均如此），
+// first_seen_run_id 只在首次写入时设置；RunID 为 RunIDLayout 格式的 UTC 时间（同一秒内追加 -NNNN 序号），
+// 按字符串即按时间排序，清理以 last_seen_run_id < 当前 RunID 判定过期，外部传入的 RunID 须经 ValidateRunID 校验。
+const (
+	PropCMDBKey        = "cmdb_key"
+	PropFirstSeenRunID = "first_seen_run_id"
+	PropLastSeenRunID  = "last_seen_run_id"
+	PropUpdatedAt      = "updated_at"
+	PropActive         = "active"
+	PropDeleted        = "deleted"
+	PropDeletedAt      = "deleted_at"
 )
 
 const (
@@ -29,7 +69,9 @@ const (
 	PrefixHostMachine  = "HM"
 	PrefixPhysical     = "PM"
 	PrefixVirtual      = "VM"
+	PrefixContainer    = "CTR"
 	PrefixApp          = "APP"
+	PrefixService      = "SVC"
 )
 
 // MakeKey 统一生成 cmdb_key，带上前缀以避免不同实体冲突。
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// RunIDLayout 为批次号的时间部分：固定宽度的 UTC 时间，按字符串比较即按时间比较。
+const RunIDLayout = "20060102T150405Z"
+
+// maxRunIDSeq 为同一秒内可追加的最大序号，序号固定 4 位以保持字符串有序。
+const maxRunIDSeq = 9999
+
+// runIDPattern 为合法批次号：RunIDLayout 时间，可选 -NNNN 序号。
+var runIDPattern = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}Z(-[0-9]{4})?$`)
+
+var runIDState struct {
+	mu   sync.Mutex
+	last time.Time
+	seq  int
+}
+
+// NewRunID 按 t 生成批次号。同一秒内再次生成时追加递增序号（如 20250101T000000Z-0001），
+// 时钟回拨时沿用上一次的时间继续递增，保证进程内生成的批次号严格递增。
+func NewRunID(t time.Time) string {
+	runIDState.mu.Lock()
+	defer runIDState.mu.Unlock()
+	sec := t.UTC().Truncate(time.Second)
+	if sec.After(runIDState.last) {
+		runIDState.last = sec
+		runIDState.seq = 0
+		return sec.Format(RunIDLayout)
+	}
+	runIDState.seq++
+	if runIDState.seq > maxRunIDSeq {
+		runIDState.last = runIDState.last.Add(time.Second)
+		runIDState.seq = 0
+		return runIDState.last.Format(RunIDLayout)
+	}
+	return fmt.Sprintf("%s-%04d", runIDState.last.Format(RunIDLayout), runIDState.seq)
+}
+
+// ValidateRunID 校验批次号符合 RunIDLayout（可带 -NNNN 序号）。清理按 last_seen_run_id 的字符串大小判定过期，
+// 任何来自外部输入的批次号都必须先经过这里。
+func ValidateRunID(id string) error {
+	if !runIDPattern.MatchString(id) {
+		return fmt.Errorf("%w %q，应为 %s 或带 -NNNN 序号", ErrInvalidRunID, id, RunIDLayout)
+	}
+	if _, err := time.Parse(RunIDLayout, id[:len(RunIDLayout)]); err != nil {
+		return fmt.Errorf("%w %q: %v", ErrInvalidRunID, id, err)
+	}
+	return nil
+}
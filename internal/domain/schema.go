@@ -0,0 +1,218 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// keyPrefixPattern 为 cmdb_key 前缀允许的字符，前缀与 ID 之间以下划线连接。
+var keyPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// defaultKeyPrefixes 为各实体标签默认的 cmdb_key 前缀。
+var defaultKeyPrefixes = map[string]string{
+	LabelIDC:             PrefixIDC,
+	LabelNetPartition:    PrefixNetPartition,
+	LabelHostMachine:     PrefixHostMachine,
+	LabelPhysicalMachine: PrefixPhysical,
+	LabelVirtualMachine:  PrefixVirtual,
+	LabelContainer:       PrefixContainer,
+	LabelApp:             PrefixApp,
+	LabelService:         PrefixService,
+}
+
+// Schema 描述图中实际使用的标签、关系类型与 cmdb_key 前缀。
+// 代码内部始终使用 Label*、Rel* 常量作为规范名，只在读写 Neo4j 时通过 Schema 换算为实际名称；
+// 零值即默认 Schema，与常量一致。
+type Schema struct {
+	labels   map[string]string
+	rels     map[string]string
+	prefixes map[string]string
+}
+
+// DefaultSchema 返回与 Label*、Rel*、Prefix* 常量一致的 Schema。
+func DefaultSchema() Schema {
+	return Schema{}
+}
+
+// NewSchema 按规范名覆盖标签、关系类型与 cmdb_key 前缀，未指定的沿用默认值。
+// labels 的键为规范标签，rels 的键为规范关系类型，prefixes 的键为实体标签；
+// 名称必须为合法标识符且换算后互不重复，否则返回 ErrInvalidLabel、ErrInvalidRelType 或 ErrInvalidKeyPrefix。
+func NewSchema(labels, rels, prefixes map[string]string) (Schema, error) {
+	var s Schema
+	var err error
+	if s.labels, err = overrides(labels, knownLabels, nil, knownLabels, identifierPattern, ErrInvalidLabel); err != nil {
+		return Schema{}, err
+	}
+	if s.rels, err = overrides(rels, knownRelTypes, nil, knownRelTypes, identifierPattern, ErrInvalidRelType); err != nil {
+		return Schema{}, err
+	}
+	entities := make(map[string]bool, len(defaultKeyPrefixes))
+	for label := range defaultKeyPrefixes {
+		entities[label] = true
+	}
+	if s.prefixes, err = overrides(prefixes, entities, defaultKeyPrefixes, nil, keyPrefixPattern, ErrInvalidKeyPrefix); err != nil {
+		return Schema{}, err
+	}
+	if err := s.checkUnique(); err != nil {
+		return Schema{}, err
+	}
+	return s, nil
+}
+
+// overrides 校验覆盖项并只保留与默认值不同的部分，值为空表示沿用默认值；defaults 为 nil 时默认值即规范名，
+// reserved 中的名称不能作为覆盖值。
+func overrides(names map[string]string, known map[string]bool, defaults map[string]string, reserved map[string]bool, pattern *regexp.Regexp, errKind error) (map[string]string, error) {
+	out := make(map[string]string, len(names))
+	for canonical, name := range names {
+		if !known[canonical] {
+			return nil, fmt.Errorf("%w: 未知的名称 %q", errKind, canonical)
+		}
+		def := canonical
+		if defaults != nil {
+			def = defaults[canonical]
+		}
+		name = strings.TrimSpace(name)
+		if name == "" || name == def {
+			continue
+		}
+		if !pattern.MatchString(name) {
+			return nil, fmt.Errorf("%w %q（%s）", errKind, name, canonical)
+		}
+		// 不能占用其他规范名，否则读回时无法区分
+		if reserved[name] {
+			return nil, fmt.Errorf("%w: %s 不能映射为另一个规范名 %q", errKind, canonical, name)
+		}
+		out[canonical] = name
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// checkUnique 校验换算后的标签、关系类型与前缀互不重复，避免两类实体落到同一个标签或 key 空间。
+func (s Schema) checkUnique() error {
+	labels := make([]string, 0, len(knownLabels))
+	for label := range knownLabels {
+		labels = append(labels, label)
+	}
+	entities := make([]string, 0, len(defaultKeyPrefixes))
+	for label := range defaultKeyPrefixes {
+		entities = append(entities, label)
+	}
+	groups := []struct {
+		names   []string
+		resolve func(string) string
+		errKind error
+	}{
+		{labels, s.Label, ErrInvalidLabel},
+		{RelTypes, s.Rel, ErrInvalidRelType},
+		{entities, s.Prefix, ErrInvalidKeyPrefix},
+	}
+	for _, g := range groups {
+		sorted := append([]string(nil), g.names...)
+		sort.Strings(sorted)
+		seen := make(map[string]string, len(sorted))
+		for _, canonical := range sorted {
+			name := g.resolve(canonical)
+			if other, dup := seen[name]; dup {
+				return fmt.Errorf("%w: %s 与 %s 都映射为 %q", g.errKind, other, canonical, name)
+			}
+			seen[name] = canonical
+		}
+	}
+	return nil
+}
+
+// IsDefault 判断是否与默认 Schema 一致。
+func (s Schema) IsDefault() bool {
+	return len(s.labels) == 0 && len(s.rels) == 0 && len(s.prefixes) == 0
+}
+
+// Label 返回规范标签在图中的实际名称，未知标签原样返回。
+func (s Schema) Label(canonical string) string {
+	if name, ok := s.labels[canonical]; ok {
+		return name
+	}
+	return canonical
+}
+
+// Rel 返回规范关系类型在图中的实际名称，未知类型原样返回。
+func (s Schema) Rel(canonical string) string {
+	if name, ok := s.rels[canonical]; ok {
+		return name
+	}
+	return canonical
+}
+
+// Prefix 返回实体标签对应的 cmdb_key 前缀，未知标签返回空串。
+func (s Schema) Prefix(label string) string {
+	if prefix, ok := s.prefixes[label]; ok {
+		return prefix
+	}
+	return defaultKeyPrefixes[label]
+}
+
+// MakeKey 按实体标签的前缀生成 cmdb_key。
+func (s Schema) MakeKey(label string, rawID any) string {
+	return MakeKey(s.Prefix(label), rawID)
+}
+
+// Labels 把一组规范标签换算为实际名称。
+func (s Schema) Labels(canonical []string) []string {
+	out := make([]string, len(canonical))
+	for i, label := range canonical {
+		out[i] = s.Label(label)
+	}
+	return out
+}
+
+// LabelPattern 与包级 LabelPattern 相同，但使用实际标签名。
+func (s Schema) LabelPattern(canonical []string) string {
+	return LabelPattern(s.Labels(canonical))
+}
+
+// EntityLabels 返回 EntityLabels 对应的实际标签。
+func (s Schema) EntityLabels() []string {
+	return s.Labels(EntityLabels)
+}
+
+// RelTypes 返回 RelTypes 对应的实际关系类型。
+func (s Schema) RelTypes() []string {
+	out := make([]string, len(RelTypes))
+	for i, t := range RelTypes {
+		out[i] = s.Rel(t)
+	}
+	return out
+}
+
+// CanonicalLabel 把图中读到的标签换算回规范名，不属于 Schema 的标签原样返回。
+func (s Schema) CanonicalLabel(name string) string {
+	for canonical, actual := range s.labels {
+		if actual == name {
+			return canonical
+		}
+	}
+	return name
+}
+
+// CanonicalLabels 把一组实际标签换算回规范名。
+func (s Schema) CanonicalLabels(names []string) []string {
+	out := make([]string, len(names))
+	for i, name := range names {
+		out[i] = s.CanonicalLabel(name)
+	}
+	return out
+}
+
+// CanonicalRel 把图中读到的关系类型换算回规范名，不属于 Schema 的类型原样返回。
+func (s Schema) CanonicalRel(name string) string {
+	for canonical, actual := range s.rels {
+		if actual == name {
+			return canonical
+		}
+	}
+	return name
+}
@@ -31,3 +31,10 @@ type GroupedRels struct {
 	Type string
 	Rows []RelRow
 }
+
+// LabelCount 统计某个标签下存活节点总数及本批次未刷新的过期节点数。
+type LabelCount struct {
+	Label string `json:"label"`
+	Total int    `json:"total"`
+	Stale int    `json:"stale"`
+}
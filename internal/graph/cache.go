@@ -0,0 +1,209 @@
+package graph
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// DefaultQueryCacheSize 为查询结果缓存的默认条数上限。
+const DefaultQueryCacheSize = 1024
+
+// QueryCacheStats 为查询结果缓存的命中统计。
+type QueryCacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	Size   int   `json:"size"`
+}
+
+type queryCacheEntry struct {
+	key     string
+	records []map[string]any
+	expires time.Time
+}
+
+// QueryCache 按查询文本与参数缓存只读查询的结果，是带 TTL 的 LRU 缓存，并发安全；
+// 写入与读出时都会深拷贝记录，调用方修改返回值不会影响缓存。
+type QueryCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+	order   *list.List
+	entries map[string]*list.Element
+	hits    int64
+	misses  int64
+}
+
+// NewQueryCache 创建查询结果缓存，size<=0 时取 DefaultQueryCacheSize；ttl<=0 时返回 nil，表示不缓存。
+func NewQueryCache(ttl time.Duration, size int) *QueryCache {
+	if ttl <= 0 {
+		return nil
+	}
+	if size <= 0 {
+		size = DefaultQueryCacheSize
+	}
+	return &QueryCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// queryCacheKey 为查询文本与参数的哈希，参数按 JSON 编码（map 键有序）；无法编码的参数返回 false，不参与缓存。
+func queryCacheKey(query string, params map[string]any) (string, bool) {
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.New()
+	sum.Write([]byte(query))
+	sum.Write([]byte{0})
+	sum.Write(encoded)
+	return hex.EncodeToString(sum.Sum(nil)), true
+}
+
+// Get 返回未过期的缓存结果的副本。
+func (c *QueryCache) Get(query string, params map[string]any) ([]map[string]any, bool) {
+	if c == nil {
+		return nil, false
+	}
+	key, ok := queryCacheKey(query, params)
+	if !ok {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*queryCacheEntry)
+		if c.now().Before(entry.expires) {
+			c.order.MoveToFront(elem)
+			c.hits++
+			return cloneRecords(entry.records), true
+		}
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+	c.misses++
+	return nil, false
+}
+
+// Put 缓存查询结果的副本，超出容量时淘汰最久未使用的条目。
+func (c *QueryCache) Put(query string, params map[string]any, records []map[string]any) {
+	if c == nil {
+		return
+	}
+	key, ok := queryCacheKey(query, params)
+	if !ok {
+		return
+	}
+	records = cloneRecords(records)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.ttl)
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*queryCacheEntry)
+		entry.records, entry.expires = records, expires
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&queryCacheEntry{key: key, records: records, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*queryCacheEntry).key)
+	}
+}
+
+// Purge 清空缓存，同步写入后可调用以避免读到旧数据。
+func (c *QueryCache) Purge() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+}
+
+// Stats 返回命中统计，未开启缓存时为零值。
+func (c *QueryCache) Stats() QueryCacheStats {
+	if c == nil {
+		return QueryCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return QueryCacheStats{Hits: c.hits, Misses: c.misses, Size: c.order.Len()}
+}
+
+func cloneRecords(records []map[string]any) []map[string]any {
+	if records == nil {
+		return nil
+	}
+	out := make([]map[string]any, len(records))
+	for i, record := range records {
+		out[i] = cloneMap(record)
+	}
+	return out
+}
+
+func cloneMap(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = cloneValue(v)
+	}
+	return out
+}
+
+// cloneValue 深拷贝驱动返回的值，覆盖 map、切片以及节点、关系、路径中的可变字段，其余值按值复制。
+func cloneValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		return cloneMap(val)
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = cloneValue(item)
+		}
+		return out
+	case []string:
+		return append([]string(nil), val...)
+	case neo4j.Node:
+		return cloneNode(val)
+	case neo4j.Relationship:
+		return cloneRelationship(val)
+	case neo4j.Path:
+		nodes := make([]neo4j.Node, len(val.Nodes))
+		for i, n := range val.Nodes {
+			nodes[i] = cloneNode(n)
+		}
+		rels := make([]neo4j.Relationship, len(val.Relationships))
+		for i, r := range val.Relationships {
+			rels[i] = cloneRelationship(r)
+		}
+		return neo4j.Path{Nodes: nodes, Relationships: rels}
+	default:
+		return v
+	}
+}
+
+func cloneNode(n neo4j.Node) neo4j.Node {
+	n.Labels = append([]string(nil), n.Labels...)
+	n.Props = cloneMap(n.Props)
+	return n
+}
+
+func cloneRelationship(r neo4j.Relationship) neo4j.Relationship {
+	r.Props = cloneMap(r.Props)
+	return r
+}
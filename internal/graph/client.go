@@ -3,9 +3,17 @@ package graph
 import (
 	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/metrics"
+	"cmdb2neo/pkg/logging"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
 // Reader 定义只读查询接口，便于测试替换实现。
@@ -13,6 +21,34 @@ type Reader interface {
 	RunRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error)
 }
 
+// StreamReader 逐条回调查询结果而不一次性加载到内存，适用于导出等大结果集。
+type StreamReader interface {
+	RunReadStream(ctx context.Context, query string, params map[string]any, fn func(record map[string]any) error) error
+}
+
+// ForEachRecord 对查询结果逐条调用 fn，reader 实现 StreamReader 时流式读取，否则退化为 RunRead 后遍历；fn 返回错误时停止并返回该错误。
+func ForEachRecord(ctx context.Context, reader Reader, query string, params map[string]any, fn func(record map[string]any) error) error {
+	if stream, ok := reader.(StreamReader); ok {
+		return stream.RunReadStream(ctx, query, params, fn)
+	}
+	records, err := reader.RunRead(ctx, query, params)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadWriter 在只读查询之外提供写入能力，供分析结果等少量写入使用。
+type ReadWriter interface {
+	Reader
+	RunWrite(ctx context.Context, query string, params map[string]any) error
+}
+
 // Config 描述连接 Neo4j 的必要参数。
 type Config struct {
 	URI                  string
@@ -21,58 +57,228 @@ type Config struct {
 	Database             string
 	MaxConnectionPool    int
 	ConnectionTimeoutSec int
+	// QueryTimeoutSec 为单条查询（含结果遍历）的超时秒数，未配置时使用 DefaultQueryTimeout。
+	QueryTimeoutSec int
+	// Routing 为 true 时按集群路由访问，bolt:// 地址会改写为 neo4j://，默认直连单实例。
+	Routing bool
+	// CacheTTL 大于 0 时按查询文本与参数缓存只读查询结果，默认关闭：同步写入后缓存期内可能读到旧数据。
+	CacheTTL time.Duration
+	// CacheSize 为查询结果缓存的条数上限，为 0 时使用 DefaultQueryCacheSize。
+	CacheSize int
 }
 
-// Client 封装了只读能力的 Neo4j 访问。
+// Client 封装了 Neo4j 访问，以只读查询为主，写入仅用于保存分析结果。
 type Client struct {
-	driver   neo4j.DriverWithContext
-	database string
+	mu           sync.RWMutex
+	driver       neo4j.DriverWithContext
+	dial         func(ctx context.Context) (neo4j.DriverWithContext, error)
+	inUse        atomic.Int64
+	database     string
+	queryTimeout time.Duration
+	bookmarks    neo4j.BookmarkManager
+	recorder     metrics.Recorder
+	tracer       trace.Tracer
+	// cache 为 nil 时不缓存查询结果
+	cache *QueryCache
+}
+
+// ClientOption 配置 Client 的可选项。
+type ClientOption func(*Client)
+
+// WithRecorder 注入查询耗时指标的记录器。
+func WithRecorder(recorder metrics.Recorder) ClientOption {
+	return func(c *Client) {
+		c.recorder = recorder
+	}
+}
+
+// WithTracerProvider 注入查询 span 使用的 TracerProvider，未配置时不产生 span。
+func WithTracerProvider(provider trace.TracerProvider) ClientOption {
+	return func(c *Client) {
+		c.tracer = Tracer(provider)
+	}
+}
+
+// WithBookmarkManager 与同步写入共享书签，读事务会等待副本追上最近一次同步，RCA 因此能读到刚写入的数据。
+func WithBookmarkManager(bookmarks neo4j.BookmarkManager) ClientOption {
+	return func(c *Client) {
+		c.bookmarks = bookmarks
+	}
 }
 
 // NewClient 创建并校验连接。
-func NewClient(ctx context.Context, cfg Config) (*Client, error) {
+func NewClient(ctx context.Context, cfg Config, opts ...ClientOption) (*Client, error) {
 	if cfg.URI == "" {
 		return nil, fmt.Errorf("neo4j uri 不能为空")
 	}
+	uri, err := RoutingURI(cfg.URI, cfg.Routing)
+	if err != nil {
+		return nil, err
+	}
 	auth := neo4j.BasicAuth(cfg.Username, cfg.Password, "")
-	driver, err := neo4j.NewDriverWithContext(cfg.URI, auth, func(conf *neo4j.Config) {
-		if cfg.MaxConnectionPool > 0 {
-			conf.MaxConnectionPoolSize = cfg.MaxConnectionPool
+	dial := func(ctx context.Context) (neo4j.DriverWithContext, error) {
+		driver, err := neo4j.NewDriverWithContext(uri, auth, func(conf *neo4j.Config) {
+			if cfg.MaxConnectionPool > 0 {
+				conf.MaxConnectionPoolSize = cfg.MaxConnectionPool
+			}
+			if cfg.ConnectionTimeoutSec > 0 {
+				conf.SocketConnectTimeout = time.Duration(cfg.ConnectionTimeoutSec) * time.Second
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("创建 neo4j driver 失败: %w", err)
 		}
-		if cfg.ConnectionTimeoutSec > 0 {
-			conf.SocketConnectTimeout = time.Duration(cfg.ConnectionTimeoutSec) * time.Second
+		if err := driver.VerifyConnectivity(ctx); err != nil {
+			_ = driver.Close(ctx)
+			return nil, domain.MarkError(ErrNeo4jUnavailable, fmt.Errorf("neo4j 无法连通: %w", err))
 		}
-	})
+		return driver, nil
+	}
+	driver, err := dial(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("创建 neo4j driver 失败: %w", err)
+		return nil, err
+	}
+	client := &Client{
+		driver:       driver,
+		dial:         dial,
+		database:     cfg.Database,
+		queryTimeout: QueryTimeout(cfg.QueryTimeoutSec),
+		cache:        NewQueryCache(cfg.CacheTTL, cfg.CacheSize),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(client)
+		}
 	}
-	if err := driver.VerifyConnectivity(ctx); err != nil {
-		_ = driver.Close(ctx)
-		return nil, fmt.Errorf("neo4j 无法连通: %w", err)
+	client.recorder = metrics.OrNop(client.recorder)
+	if client.tracer == nil {
+		client.tracer = Tracer(nil)
+	}
+	return client, nil
+}
+
+// sessionConfig 返回指定访问模式的会话配置，配置了 BookmarkManager 时在会话间传递书签。
+func (c *Client) sessionConfig(mode neo4j.AccessMode) neo4j.SessionConfig {
+	return neo4j.SessionConfig{DatabaseName: c.database, AccessMode: mode, BookmarkManager: c.bookmarks}
+}
+
+// currentDriver 返回当前使用的 driver，Reconnect 可能随时替换它。
+func (c *Client) currentDriver() neo4j.DriverWithContext {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.driver
+}
+
+// openSession 打开会话并计入使用中的会话数，返回的 release 负责关闭会话。
+func (c *Client) openSession(ctx context.Context, mode neo4j.AccessMode) (neo4j.SessionWithContext, func()) {
+	session := c.currentDriver().NewSession(ctx, c.sessionConfig(mode))
+	c.recorder.SetSessionsInUse("graph", int(c.inUse.Add(1)))
+	return session, func() {
+		_ = session.Close(ctx)
+		c.recorder.SetSessionsInUse("graph", int(c.inUse.Add(-1)))
 	}
-	return &Client{driver: driver, database: cfg.Database}, nil
 }
 
 // Close 关闭底层连接。
 func (c *Client) Close(ctx context.Context) error {
-	if c == nil || c.driver == nil {
+	if c == nil {
 		return nil
 	}
-	return c.driver.Close(ctx)
+	driver := c.currentDriver()
+	if driver == nil {
+		return nil
+	}
+	return driver.Close(ctx)
 }
 
-// RunRead 执行只读查询并返回记录集合。
-func (c *Client) RunRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
-	session := c.driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: c.database, AccessMode: neo4j.AccessModeRead})
-	defer session.Close(ctx)
+// VerifyConnectivity 实现 Connector，校验当前 driver 能否连通。
+func (c *Client) VerifyConnectivity(ctx context.Context) error {
+	return c.currentDriver().VerifyConnectivity(ctx)
+}
 
-	resultAny, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
-		res, err := tx.Run(ctx, query, params)
+// Reconnect 实现 Connector，新建并校验 driver 后替换旧 driver，旧 driver 上进行中的查询会失败。
+func (c *Client) Reconnect(ctx context.Context) error {
+	driver, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	old := c.driver
+	c.driver = driver
+	c.mu.Unlock()
+	if old != nil {
+		_ = old.Close(ctx)
+	}
+	return nil
+}
+
+// Ping 校验驱动连通性并执行 RETURN 1，供就绪探针使用。
+func (c *Client) Ping(ctx context.Context) error {
+	if c == nil || c.currentDriver() == nil {
+		return fmt.Errorf("neo4j client not initialized")
+	}
+	if err := c.VerifyConnectivity(ctx); err != nil {
+		return ClassifyError(err)
+	}
+	_, err := c.RunRead(ctx, "RETURN 1 AS ok", nil)
+	return err
+}
+
+// logQuery 用上下文中的请求级日志器记录查询：失败记为 warn，成功只在 debug 级别输出；上下文没有日志器时不记录。
+func logQuery(ctx context.Context, mode, query string, elapsed time.Duration, records int, err error) {
+	logger := logging.FromContext(ctx, nil)
+	fields := []zap.Field{zap.String("mode", mode), zap.String("query", QueryName(query)), zap.Duration("elapsed", elapsed)}
+	if err != nil {
+		logger.Warn("neo4j query failed", append(fields, zap.Error(err))...)
+		return
+	}
+	if ce := logger.Check(zap.DebugLevel, "neo4j query"); ce != nil {
+		ce.Write(append(fields, zap.Int("records", records))...)
+	}
+}
+
+// QueryCacheStats 返回查询结果缓存的命中统计，未开启缓存时为零值。
+func (c *Client) QueryCacheStats() QueryCacheStats {
+	return c.cache.Stats()
+}
+
+// RunRead 执行只读查询并返回记录集合，开启缓存时相同查询与参数在 CacheTTL 内直接返回缓存结果的副本。
+func (c *Client) RunRead(ctx context.Context, query string, params map[string]any) (records []map[string]any, err error) {
+	if c.cache != nil {
+		if cached, ok := c.cache.Get(query, params); ok {
+			c.recorder.ObserveQueryCache(true)
+			return cached, nil
+		}
+		c.recorder.ObserveQueryCache(false)
+		defer func() {
+			if err == nil {
+				c.cache.Put(query, params, records)
+			}
+		}()
+	}
+	ctx, span := StartQuerySpan(ctx, c.tracer, "neo4j.read", query, params)
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		c.recorder.ObserveQuery("read", elapsed, err)
+		logQuery(ctx, "read", query, elapsed, len(records), err)
+		span.SetAttributes(attribute.Int("db.record_count", len(records)))
+		EndSpan(span, err)
+	}()
+
+	session, release := c.openSession(ctx, neo4j.AccessModeRead)
+	defer release()
+
+	// 超时覆盖整个读事务，包括逐条遍历结果
+	queryCtx, cancel := WithQueryTimeout(ctx, c.queryTimeout)
+	defer cancel()
+	resultAny, err := session.ExecuteRead(queryCtx, func(tx neo4j.ManagedTransaction) (any, error) {
+		res, err := tx.Run(queryCtx, query, params)
 		if err != nil {
 			return nil, err
 		}
 		records := make([]map[string]any, 0)
-		for res.Next(ctx) {
+		for res.Next(queryCtx) {
 			records = append(records, res.Record().AsMap())
 		}
 		if err := res.Err(); err != nil {
@@ -81,7 +287,7 @@ func (c *Client) RunRead(ctx context.Context, query string, params map[string]an
 		return records, nil
 	})
 	if err != nil {
-		return nil, err
+		return nil, ClassifyError(TimeoutError(queryCtx, c.queryTimeout, err))
 	}
 	records, ok := resultAny.([]map[string]any)
 	if !ok {
@@ -89,3 +295,73 @@ func (c *Client) RunRead(ctx context.Context, query string, params map[string]an
 	}
 	return records, nil
 }
+
+// RunReadStream 在只读事务中执行查询并逐条回调结果，不经过查询结果缓存；fn 返回错误时回滚事务并返回该错误。
+// 回调可能已经处理了部分记录，因此不使用会自动重试的托管事务；无论回调是否出错，会话都会在返回前关闭。
+func (c *Client) RunReadStream(ctx context.Context, query string, params map[string]any, fn func(record map[string]any) error) (err error) {
+	ctx, span := StartQuerySpan(ctx, c.tracer, "neo4j.read_stream", query, params)
+	start := time.Now()
+	var count int
+	defer func() {
+		elapsed := time.Since(start)
+		c.recorder.ObserveQuery("read", elapsed, err)
+		logQuery(ctx, "read_stream", query, elapsed, count, err)
+		span.SetAttributes(attribute.Int("db.record_count", count))
+		EndSpan(span, err)
+	}()
+
+	session, release := c.openSession(ctx, neo4j.AccessModeRead)
+	defer release()
+
+	queryCtx, cancel := WithQueryTimeout(ctx, c.queryTimeout)
+	defer cancel()
+	tx, err := session.BeginTransaction(queryCtx)
+	if err != nil {
+		return ClassifyError(TimeoutError(queryCtx, c.queryTimeout, err))
+	}
+	defer func() {
+		// 提交成功后 Close 为空操作，其余情况回滚
+		_ = tx.Close(ctx)
+	}()
+	res, err := tx.Run(queryCtx, query, params)
+	if err != nil {
+		return ClassifyError(TimeoutError(queryCtx, c.queryTimeout, err))
+	}
+	for res.Next(queryCtx) {
+		count++
+		if err := fn(res.Record().AsMap()); err != nil {
+			return err
+		}
+	}
+	if err := res.Err(); err != nil {
+		return ClassifyError(TimeoutError(queryCtx, c.queryTimeout, err))
+	}
+	return ClassifyError(TimeoutError(queryCtx, c.queryTimeout, tx.Commit(queryCtx)))
+}
+
+// RunWrite 在写事务中执行一条语句，开启缓存时写入后清空查询结果缓存。
+func (c *Client) RunWrite(ctx context.Context, query string, params map[string]any) (err error) {
+	ctx, span := StartQuerySpan(ctx, c.tracer, "neo4j.write", query, params)
+	start := time.Now()
+	defer func() {
+		c.cache.Purge()
+		elapsed := time.Since(start)
+		c.recorder.ObserveQuery("write", elapsed, err)
+		logQuery(ctx, "write", query, elapsed, 0, err)
+		EndSpan(span, err)
+	}()
+
+	session, release := c.openSession(ctx, neo4j.AccessModeWrite)
+	defer release()
+
+	queryCtx, cancel := WithQueryTimeout(ctx, c.queryTimeout)
+	defer cancel()
+	_, err = session.ExecuteWrite(queryCtx, func(tx neo4j.ManagedTransaction) (any, error) {
+		res, err := tx.Run(queryCtx, query, params)
+		if err != nil {
+			return nil, err
+		}
+		return res.Consume(queryCtx)
+	})
+	return ClassifyError(TimeoutError(queryCtx, c.queryTimeout, err))
+}
@@ -0,0 +1,32 @@
+package graph
+
+import (
+	"errors"
+
+	"cmdb2neo/internal/domain"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// ErrNeo4jUnavailable 表示 Neo4j 暂时不可用（连接失败、集群切主、死锁等驱动判定可重试的错误），重试可能恢复。
+var ErrNeo4jUnavailable = errors.New("neo4j 暂时不可用")
+
+// ClassifyError 为驱动返回的错误标记类别：可重试的连接或事务错误标记为 ErrNeo4jUnavailable，
+// 违反约束标记为 domain.ErrValidation，其余原样返回。
+func ClassifyError(err error) error {
+	var (
+		connectivity *neo4j.ConnectivityError
+		limit        *neo4j.TransactionExecutionLimit
+		neoErr       *neo4j.Neo4jError
+	)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrNeo4jUnavailable):
+		return err
+	case errors.As(err, &connectivity), errors.As(err, &limit), neo4j.IsRetryable(err):
+		return domain.MarkError(ErrNeo4jUnavailable, err)
+	case errors.As(err, &neoErr) && neoErr.Code == "Neo.ClientError.Schema.ConstraintValidationFailed":
+		return domain.MarkError(domain.ErrValidation, err)
+	}
+	return err
+}
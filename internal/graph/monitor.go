@@ -0,0 +1,225 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cmdb2neo/internal/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultCheckInterval 为连通性检查的默认间隔。
+	DefaultCheckInterval = 30 * time.Second
+	// DefaultMaxBackoff 为连续失败时检查间隔的上限。
+	DefaultMaxBackoff = 5 * time.Minute
+	// DefaultFailureThreshold 为判定连接持续不可用并开始重建 driver 的连续失败次数。
+	DefaultFailureThreshold = 3
+	// checkTimeout 限制单次连通性检查的耗时。
+	checkTimeout = 5 * time.Second
+)
+
+// Connector 为可探测连通性并重建 driver 的客户端，graph.Client 与 loader.Client 均实现。
+type Connector interface {
+	VerifyConnectivity(ctx context.Context) error
+	Reconnect(ctx context.Context) error
+}
+
+// ConnectionMonitor 周期性校验 Neo4j 连通性，连续失败达到阈值后按指数退避重建 driver，
+// 避免 Neo4j 重启后连接池中的失效连接让服务一直不可用。
+type ConnectionMonitor struct {
+	name       string
+	target     Connector
+	interval   time.Duration
+	maxBackoff time.Duration
+	threshold  int
+	recorder   metrics.Recorder
+	logger     *zap.Logger
+
+	mu       sync.Mutex
+	failures int
+	lastErr  error
+}
+
+// MonitorOption 配置 ConnectionMonitor 的可选项。
+type MonitorOption func(*ConnectionMonitor)
+
+// WithCheckInterval 设置正常情况下的检查间隔，非正数时使用 DefaultCheckInterval。
+func WithCheckInterval(d time.Duration) MonitorOption {
+	return func(m *ConnectionMonitor) {
+		if d > 0 {
+			m.interval = d
+		}
+	}
+}
+
+// WithMaxBackoff 设置连续失败时检查间隔的上限。
+func WithMaxBackoff(d time.Duration) MonitorOption {
+	return func(m *ConnectionMonitor) {
+		if d > 0 {
+			m.maxBackoff = d
+		}
+	}
+}
+
+// WithFailureThreshold 设置开始重建 driver 并判定不可用的连续失败次数。
+func WithFailureThreshold(n int) MonitorOption {
+	return func(m *ConnectionMonitor) {
+		if n > 0 {
+			m.threshold = n
+		}
+	}
+}
+
+// WithMonitorRecorder 注入连通性与重连指标的记录器。
+func WithMonitorRecorder(recorder metrics.Recorder) MonitorOption {
+	return func(m *ConnectionMonitor) {
+		m.recorder = recorder
+	}
+}
+
+// WithMonitorLogger 注入日志。
+func WithMonitorLogger(logger *zap.Logger) MonitorOption {
+	return func(m *ConnectionMonitor) {
+		m.logger = logger
+	}
+}
+
+// NewConnectionMonitor 为 target 构建连通性监控，name 用于指标与日志区分客户端。
+func NewConnectionMonitor(name string, target Connector, opts ...MonitorOption) *ConnectionMonitor {
+	m := &ConnectionMonitor{
+		name:       name,
+		target:     target,
+		interval:   DefaultCheckInterval,
+		maxBackoff: DefaultMaxBackoff,
+		threshold:  DefaultFailureThreshold,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(m)
+		}
+	}
+	m.recorder = metrics.OrNop(m.recorder)
+	if m.maxBackoff < m.interval {
+		m.maxBackoff = m.interval
+	}
+	return m
+}
+
+// Start 在后台按间隔执行检查，失败时间隔翻倍直至 maxBackoff，恢复后回到正常间隔；返回停止函数。
+func (m *ConnectionMonitor) Start(parent context.Context) context.CancelFunc {
+	if m == nil || m.target == nil {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(parent)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		delay := m.interval
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+			if err := m.Check(ctx); err != nil {
+				delay = min(delay*2, m.maxBackoff)
+			} else {
+				delay = m.interval
+			}
+			timer.Reset(delay)
+		}
+	}()
+	if m.logger != nil {
+		m.logger.Info("neo4j connection monitor started", zap.String("client", m.name), zap.Duration("interval", m.interval))
+	}
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Check 执行一次连通性检查，连续失败达到阈值时尝试重建 driver，重建成功视为恢复。
+func (m *ConnectionMonitor) Check(ctx context.Context) error {
+	checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	err := m.target.VerifyConnectivity(checkCtx)
+
+	m.mu.Lock()
+	failures := m.failures
+	m.mu.Unlock()
+	if err != nil && failures+1 >= m.threshold {
+		reconnectErr := m.target.Reconnect(checkCtx)
+		m.recorder.ObserveReconnect(m.name, reconnectErr)
+		if reconnectErr == nil {
+			if m.logger != nil {
+				m.logger.Info("neo4j driver reconnected", zap.String("client", m.name), zap.Int("failures", failures+1))
+			}
+			err = nil
+		} else {
+			err = errors.Join(err, fmt.Errorf("reconnect failed: %w", reconnectErr))
+		}
+	}
+
+	m.mu.Lock()
+	if err != nil {
+		m.failures++
+		m.lastErr = err
+	} else {
+		m.failures = 0
+		m.lastErr = nil
+	}
+	failures = m.failures
+	m.mu.Unlock()
+
+	m.recorder.ObserveConnectivity(m.name, err)
+	if err != nil && m.logger != nil {
+		m.logger.Warn("neo4j connectivity check failed", zap.String("client", m.name), zap.Int("failures", failures), zap.Error(err))
+	}
+	return err
+}
+
+// Err 在连续失败达到阈值时返回最近一次错误，供就绪探针判定服务不可用。
+func (m *ConnectionMonitor) Err() error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failures < m.threshold {
+		return nil
+	}
+	return fmt.Errorf("%s: %d consecutive connectivity failures: %w", m.name, m.failures, m.lastErr)
+}
+
+// ConnectionMonitors 汇总多个客户端的监控，统一启动并合并状态。
+type ConnectionMonitors []*ConnectionMonitor
+
+// Start 启动全部监控，返回的函数停止全部监控。
+func (ms ConnectionMonitors) Start(parent context.Context) context.CancelFunc {
+	stops := make([]context.CancelFunc, 0, len(ms))
+	for _, m := range ms {
+		stops = append(stops, m.Start(parent))
+	}
+	return func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+}
+
+// Err 合并各监控的不可用错误，全部正常时返回 nil。
+func (ms ConnectionMonitors) Err() error {
+	var errs []error
+	for _, m := range ms {
+		if err := m.Err(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,183 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cmdb2neo/internal/domain"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// 节点列表的默认与最大分页大小。
+const (
+	DefaultNodePageSize = 50
+	MaxNodePageSize     = 500
+)
+
+// ErrUnknownLabel 表示标签不在 domain.EntityLabels 中；标签会直接拼入 Cypher，只接受已知标签。
+var ErrUnknownLabel = errors.New("unknown label")
+
+// NodeFilterProps 为列表接口允许过滤的属性，键为查询参数名，值为节点属性名；过滤值一律作为参数传入。
+var NodeFilterProps = map[string]string{
+	"cmdb_key":  domain.PropCMDBKey,
+	"name":      "name",
+	"idc":       "idc",
+	"ip":        "ip",
+	"hostname":  "hostname",
+	"partition": "network_partion",
+}
+
+// NodeQuery 描述一次分页查询，Page 从 1 开始，Page 与 Limit 为 0 时使用默认值。
+type NodeQuery struct {
+	Label string
+	// Filters 的键为 NodeFilterProps 中的查询参数名，按属性值精确匹配。
+	Filters map[string]string
+	Page    int
+	Limit   int
+	// Schema 决定匹配的实际标签，Label 与返回的标签仍为规范名，零值为默认 Schema。
+	Schema domain.Schema
+}
+
+// NodeItem 为列表中的一个节点。
+type NodeItem struct {
+	CMDBKey string         `json:"cmdb_key"`
+	Labels  []string       `json:"labels"`
+	Props   map[string]any `json:"props"`
+}
+
+// NodePage 为一页节点，Total 为满足条件的节点总数。
+type NodePage struct {
+	Label string     `json:"label"`
+	Page  int        `json:"page"`
+	Limit int        `json:"limit"`
+	Total int        `json:"total"`
+	Items []NodeItem `json:"items"`
+}
+
+// NodeListing 为校验后的一次分页查询，先用 Count 得到总数，再用 Each 逐个读取本页节点，便于流式输出。
+type NodeListing struct {
+	Label  string
+	Page   int
+	Limit  int
+	match  string
+	params map[string]any
+	schema domain.Schema
+}
+
+// NewNodeListing 校验标签、过滤属性与分页参数并生成查询；标签未知时返回 ErrUnknownLabel。
+func NewNodeListing(q NodeQuery) (*NodeListing, error) {
+	label, ok := entityLabel(q.Label)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownLabel, q.Label)
+	}
+	page, limit := q.Page, q.Limit
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = DefaultNodePageSize
+	}
+	if page < 1 {
+		return nil, errors.New("page must be positive")
+	}
+	if limit < 1 || limit > MaxNodePageSize {
+		return nil, fmt.Errorf("limit must be between 1 and %d", MaxNodePageSize)
+	}
+
+	conds := []string{"coalesce(n.deleted, false) = false"}
+	params := map[string]any{"skip": (page - 1) * limit, "limit": limit}
+	names := make([]string, 0, len(q.Filters))
+	for name := range q.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop, ok := NodeFilterProps[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported filter %q", name)
+		}
+		conds = append(conds, fmt.Sprintf("n.%s = $f_%s", prop, name))
+		params["f_"+name] = q.Filters[name]
+	}
+	return &NodeListing{
+		Label:  label,
+		Page:   page,
+		Limit:  limit,
+		match:  fmt.Sprintf("MATCH (n:%s)\nWHERE %s\n", q.Schema.Label(label), strings.Join(conds, " AND ")),
+		params: params,
+		schema: q.Schema,
+	}, nil
+}
+
+// Count 返回满足条件的节点总数。
+func (l *NodeListing) Count(ctx context.Context, reader Reader) (int, error) {
+	records, err := reader.RunRead(ctx, l.match+"RETURN count(n) AS total\n", l.params)
+	if err != nil {
+		return 0, fmt.Errorf("count %s nodes failed: %w", l.Label, err)
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+	total, _ := records[0]["total"].(int64)
+	return int(total), nil
+}
+
+// Each 按 cmdb_key 顺序逐个回调本页节点，reader 实现 StreamReader 时不会一次性加载整页。
+func (l *NodeListing) Each(ctx context.Context, reader Reader, fn func(NodeItem) error) error {
+	query := l.match + "RETURN n\nORDER BY n.cmdb_key\nSKIP $skip LIMIT $limit\n"
+	err := ForEachRecord(ctx, reader, query, l.params, func(record map[string]any) error {
+		node, ok := record["n"].(neo4j.Node)
+		if !ok {
+			return fmt.Errorf("field n is %T, not neo4j node", record["n"])
+		}
+		key, _ := node.Props[domain.PropCMDBKey].(string)
+		return fn(NodeItem{CMDBKey: key, Labels: l.schema.CanonicalLabels(node.Labels), Props: node.Props})
+	})
+	if err != nil {
+		return fmt.Errorf("list %s nodes failed: %w", l.Label, err)
+	}
+	return nil
+}
+
+// ListNodes 按标签与属性过滤分页列出未软删除的节点，按 cmdb_key 排序；标签未知时返回 ErrUnknownLabel。
+func ListNodes(ctx context.Context, reader Reader, q NodeQuery) (NodePage, error) {
+	listing, err := NewNodeListing(q)
+	if err != nil {
+		return NodePage{}, err
+	}
+	total, err := listing.Count(ctx, reader)
+	if err != nil {
+		return NodePage{}, err
+	}
+	result := NodePage{Label: listing.Label, Page: listing.Page, Limit: listing.Limit, Total: total, Items: []NodeItem{}}
+	if !listing.HasItems(total) {
+		return result, nil
+	}
+	err = listing.Each(ctx, reader, func(item NodeItem) error {
+		result.Items = append(result.Items, item)
+		return nil
+	})
+	if err != nil {
+		return NodePage{}, err
+	}
+	return result, nil
+}
+
+// HasItems 判断总数为 total 时本页是否有节点，没有时无需再查询。
+func (l *NodeListing) HasItems(total int) bool {
+	return total > (l.Page-1)*l.Limit
+}
+
+// entityLabel 返回与 label 大小写无关地匹配的已知实体标签。
+func entityLabel(label string) (string, bool) {
+	label = strings.TrimSpace(label)
+	for _, known := range domain.EntityLabels {
+		if strings.EqualFold(known, label) {
+			return known, true
+		}
+	}
+	return "", false
+}
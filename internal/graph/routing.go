@@ -0,0 +1,33 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// routingSchemes 为直连 scheme 与对应路由 scheme 的映射。
+var routingSchemes = map[string]string{
+	"bolt":      "neo4j",
+	"bolt+s":    "neo4j+s",
+	"bolt+ssc":  "neo4j+ssc",
+	"neo4j":     "neo4j",
+	"neo4j+s":   "neo4j+s",
+	"neo4j+ssc": "neo4j+ssc",
+}
+
+// RoutingURI 在开启路由时把 bolt:// 系列地址改写为 neo4j:// 系列，由驱动按集群路由表把读事务分发到副本、写事务发往 leader；
+// 未开启时原样返回，保持单实例直连行为。
+func RoutingURI(uri string, routing bool) (string, error) {
+	if !routing {
+		return uri, nil
+	}
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok {
+		return "", fmt.Errorf("neo4j uri %q 缺少 scheme", uri)
+	}
+	target, ok := routingSchemes[strings.ToLower(scheme)]
+	if !ok {
+		return "", fmt.Errorf("neo4j uri scheme %q 不支持路由", scheme)
+	}
+	return target + "://" + rest, nil
+}
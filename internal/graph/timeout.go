@@ -0,0 +1,38 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultQueryTimeout 为未配置时单条查询（含结果遍历）的超时时间。
+const DefaultQueryTimeout = 30 * time.Second
+
+// ErrQueryTimeout 表示查询超出了单条查询超时，调用方可据此重试或映射为网关超时。
+var ErrQueryTimeout = errors.New("neo4j query timed out")
+
+// QueryTimeout 将秒数转换为查询超时，未配置或非正数时使用 DefaultQueryTimeout。
+func QueryTimeout(seconds int) time.Duration {
+	if seconds <= 0 {
+		return DefaultQueryTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// WithQueryTimeout 为一次查询派生带超时的上下文，调用方的截止时间更早时以调用方为准。
+func WithQueryTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = DefaultQueryTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// TimeoutError 在查询上下文已超时时将 err 包装为 ErrQueryTimeout，其余错误原样返回。
+func TimeoutError(ctx context.Context, timeout time.Duration, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w after %s: %w", ErrQueryTimeout, timeout, err)
+}
@@ -0,0 +1,60 @@
+package graph
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// TracerName 为本项目各模块创建 span 时使用的 instrumentation 名称。
+const TracerName = "cmdb2neo"
+
+// maxQueryNameLen 限制 span 上查询名称的长度。
+const maxQueryNameLen = 80
+
+// Tracer 从 provider 获取 tracer，provider 为空时返回不产生 span 的 noop 实现。
+func Tracer(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		provider = noop.NewTracerProvider()
+	}
+	return provider.Tracer(TracerName)
+}
+
+// QueryName 取查询的首个非空行作为名称，用于 span 与日志中区分查询。
+func QueryName(query string) string {
+	for _, line := range strings.Split(query, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if len(line) > maxQueryNameLen {
+			line = line[:maxQueryNameLen]
+		}
+		return line
+	}
+	return ""
+}
+
+// StartQuerySpan 为一次 Neo4j 查询开启 client span，记录查询名称与参数个数。
+func StartQuerySpan(ctx context.Context, tracer trace.Tracer, name string, query string, params map[string]any) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "neo4j"),
+			attribute.String("db.query.name", QueryName(query)),
+			attribute.Int("db.param_count", len(params)),
+		))
+}
+
+// EndSpan 记录错误并结束 span。
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -2,6 +2,8 @@ package job
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"sync"
 	"time"
@@ -11,52 +13,158 @@ import (
 	"go.uber.org/zap"
 )
 
-const defaultCronSpec = "0 7 * * *"
+const (
+	defaultCronSpec = "0 7 * * *"
+	// DefaultLockTTL 为分布式锁的默认租期，应长于单次同步耗时。
+	DefaultLockTTL = 2 * time.Hour
+)
+
+var (
+	// ErrJobRunning 表示同名任务上一次执行尚未结束。
+	ErrJobRunning = errors.New("job still running")
+	// ErrLockHeld 表示任务锁由其他副本持有。
+	ErrLockHeld = errors.New("job lock held by another replica")
+)
+
+// Locker 为多副本部署提供按任务名互斥的租约锁，测试可替换为内存实现。
+type Locker interface {
+	TryLock(ctx context.Context, name string, ttl time.Duration) (bool, error)
+	Unlock(ctx context.Context, name string) error
+}
+
+// Job 描述一个按 cron 表达式执行的后台任务。
+type Job struct {
+	Name string
+	Spec string
+	Func func(context.Context) error
+}
+
+// scheduledJob 为单个任务持有独立的防重入标记。
+type scheduledJob struct {
+	Job
+	mu      sync.Mutex
+	running bool
+}
 
-// Scheduler 负责基于 cron 表达式执行后台任务。
+// Scheduler 负责基于 cron 表达式执行后台任务，各任务互不阻塞。
 type Scheduler struct {
-	cronExpr string
-	logger   *zap.Logger
-	cron     *cron.Cron
-	syncFunc func(context.Context) error
-	parent   context.Context
-	mu       sync.Mutex
-	running  bool
+	jobs    []*scheduledJob
+	logger  *zap.Logger
+	cron    *cron.Cron
+	parent  context.Context
+	locker  Locker
+	lockTTL time.Duration
+	// retryAttempts 大于 1 时任务因暂时性错误失败后在同一次调度内重跑，retryBackoff 为两次执行的间隔
+	retryAttempts int
+	retryBackoff  time.Duration
 }
 
-// NewScheduler 根据配置构建调度器。
-func NewScheduler(cfg app.Config, syncFunc func(context.Context) error, logger *zap.Logger) *Scheduler {
-	spec := strings.TrimSpace(cfg.Sync.JobCron)
-func NewScheduler(cfg *app.Config, logger *zap.Logger) *Scheduler {
-	spec := ""
-	if cfg != nil {
-		spec = strings.TrimSpace(cfg.Sync.JobCron)
+// SchedulerOption 用于定制 Scheduler。
+type SchedulerOption func(*Scheduler)
+
+// WithLocker 要求每次执行前先获得同名租约锁，获取失败或被他人持有时跳过本次执行；ttl 非正数时使用 DefaultLockTTL。
+func WithLocker(locker Locker, ttl time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		s.locker = locker
+		s.lockTTL = ttl
 	}
-	if spec == "" {
-		spec = defaultCronSpec
+}
+
+// WithTransientRetry 任务因 Neo4j 或 CMDB 暂时不可用失败时，在本次调度内最多执行 attempts 次，间隔 backoff；
+// 其余错误不重跑，等待下一次调度。
+func WithTransientRetry(attempts int, backoff time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		s.retryAttempts = attempts
+		s.retryBackoff = backoff
 	}
-	return &Scheduler{cronExpr: spec, logger: logger, syncFunc: syncFunc}
 }
 
-// Start 启动调度器，返回用于停止任务的函数。
+// ConfigJobs 将同步配置映射为任务列表，优先使用 job_cron，未配置时按 interval_seconds 固定间隔执行。
+func ConfigJobs(cfg *app.Config, syncFunc func(context.Context) error) []Job {
+	return []Job{{Name: "sync", Spec: scheduleSpec(cfg), Func: syncFunc}}
+}
+
+// NewScheduler 根据任务列表构建调度器。
+func NewScheduler(jobs []Job, logger *zap.Logger, opts ...SchedulerOption) *Scheduler {
+	s := &Scheduler{logger: logger}
+	for _, j := range jobs {
+		s.jobs = append(s.jobs, &scheduledJob{Job: j})
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	if s.lockTTL <= 0 {
+		s.lockTTL = DefaultLockTTL
+	}
+	return s
+}
+
+func scheduleSpec(cfg *app.Config) string {
+	if cfg == nil {
+		return defaultCronSpec
+	}
+	if spec := strings.TrimSpace(cfg.Sync.JobCron); spec != "" {
+		return spec
+	}
+	if cfg.Sync.IntervalSeconds > 0 {
+		return fmt.Sprintf("@every %ds", cfg.Sync.IntervalSeconds)
+	}
+	return defaultCronSpec
+}
+
+// Jobs 返回已登记的任务。
+func (s *Scheduler) Jobs() []Job {
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j.Job)
+	}
+	return jobs
+}
+
+// NextRuns 返回各任务在 now 之后的下一次执行时间，表达式非法的任务不在结果中。
+func (s *Scheduler) NextRuns(now time.Time) map[string]time.Time {
+	next := make(map[string]time.Time, len(s.jobs))
+	for _, j := range s.jobs {
+		schedule, err := cron.ParseStandard(j.Spec)
+		if err != nil {
+			continue
+		}
+		next[j.Name] = schedule.Next(now)
+	}
+	return next
+}
+
+// Start 启动调度器，返回用于停止全部任务的函数。
 func (s *Scheduler) Start(parent context.Context) context.CancelFunc {
 	if s == nil {
 		return func() {}
 	}
 	s.parent = parent
 	c := cron.New()
-	id, err := c.AddFunc(s.cronExpr, s.runOnce)
-	if err != nil {
-		if s.logger != nil {
-			s.logger.Error("failed to register cron job", zap.String("cron", s.cronExpr), zap.Error(err))
+	ids := make(map[string]cron.EntryID, len(s.jobs))
+	for _, j := range s.jobs {
+		j := j
+		id, err := c.AddFunc(j.Spec, func() { _ = s.runOnce(j) })
+		if err != nil {
+			if s.logger != nil {
+				s.logger.Error("failed to register cron job", zap.String("job", j.Name), zap.String("cron", j.Spec), zap.Error(err))
+			}
+			continue
 		}
+		ids[j.Name] = id
+	}
+	if len(ids) == 0 {
 		return func() {}
 	}
 	s.cron = c
 	c.Start()
 	if s.logger != nil {
-		entry := c.Entry(id)
-		s.logger.Info("job scheduler started", zap.String("cron", s.cronExpr), zap.Time("next", entry.Next))
+		for name, id := range ids {
+			entry := c.Entry(id)
+			s.logger.Info("job scheduler started", zap.String("job", name), zap.String("cron", s.lookup(name).Spec), zap.Time("next", entry.Next))
+		}
 	}
 
 	var once sync.Once
@@ -78,23 +186,47 @@ func (s *Scheduler) Start(parent context.Context) context.CancelFunc {
 	return stop
 }
 
-func (s *Scheduler) runOnce() {
-	if s.syncFunc == nil {
+// Run 立即执行指定任务并等待结束，与定时触发共用防重入标记与分布式锁；返回任务自身重试后的最终错误。
+func (s *Scheduler) Run(name string) error {
+	j := s.lookup(name)
+	if j == nil {
+		return fmt.Errorf("unknown job %q", name)
+	}
+	return s.runOnce(j)
+}
+
+func (s *Scheduler) lookup(name string) *scheduledJob {
+	for _, j := range s.jobs {
+		if j.Name == name {
+			return j
+		}
+	}
+	return nil
+}
+
+// runOnce 执行一次任务并返回任务的错误，上一次尚未结束或锁被他人持有时跳过并返回对应错误。
+func (s *Scheduler) runOnce(j *scheduledJob) error {
+	if j.Func == nil {
 		if s.logger != nil {
-			s.logger.Warn("sync function not configured")
+			s.logger.Warn("job function not configured", zap.String("job", j.Name))
 		}
-		return
+		return nil
 	}
-	s.mu.Lock()
-	if s.running {
-		s.mu.Unlock()
+	j.mu.Lock()
+	if j.running {
+		j.mu.Unlock()
 		if s.logger != nil {
-			s.logger.Warn("previous sync still running, skip current schedule")
+			s.logger.Warn("previous run still in progress, skip current schedule", zap.String("job", j.Name))
 		}
-		return
+		return ErrJobRunning
 	}
-	s.running = true
-	s.mu.Unlock()
+	j.running = true
+	j.mu.Unlock()
+	defer func() {
+		j.mu.Lock()
+		j.running = false
+		j.mu.Unlock()
+	}()
 
 	start := time.Now()
 	runCtx := context.Background()
@@ -102,26 +234,78 @@ func (s *Scheduler) runOnce() {
 		select {
 		case <-s.parent.Done():
 			if s.logger != nil {
-				s.logger.Info("scheduler context cancelled, skip sync")
+				s.logger.Info("scheduler context cancelled, skip job", zap.String("job", j.Name))
 			}
-			s.mu.Lock()
-			s.running = false
-			s.mu.Unlock()
-			return
+			return nil
 		default:
 		}
 		runCtx = s.parent
 	}
-	err := s.syncFunc(runCtx)
+	if s.locker != nil {
+		release, err := s.lock(runCtx, j.Name)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+	err := s.runWithRetry(runCtx, j)
 	elapsed := time.Since(start)
 	if s.logger != nil {
 		if err != nil {
-			s.logger.Error("scheduled sync failed", zap.Duration("duration", elapsed), zap.Error(err))
+			s.logger.Error("scheduled job failed", zap.String("job", j.Name), zap.Duration("duration", elapsed),
+				zap.Bool("transient", app.IsTransientError(err)), zap.Error(err))
 		} else {
-			s.logger.Info("scheduled sync completed", zap.Duration("duration", elapsed))
+			s.logger.Info("scheduled job completed", zap.String("job", j.Name), zap.Duration("duration", elapsed))
 		}
 	}
-	s.mu.Lock()
-	s.running = false
-	s.mu.Unlock()
+	return err
+}
+
+// runWithRetry 执行任务，仅暂时性错误按 WithTransientRetry 重跑，等待间隔时上下文取消则返回最后一次的错误。
+func (s *Scheduler) runWithRetry(ctx context.Context, j *scheduledJob) error {
+	attempts := s.retryAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = j.Func(ctx)
+		if err == nil || !app.IsTransientError(err) || attempt == attempts {
+			return err
+		}
+		if s.logger != nil {
+			s.logger.Warn("scheduled job failed with transient error, retrying", zap.String("job", j.Name),
+				zap.Int("attempt", attempt), zap.Int("attempts", attempts), zap.Duration("backoff", s.retryBackoff), zap.Error(err))
+		}
+		timer := time.NewTimer(s.retryBackoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+	return err
+}
+
+// lock 获取任务锁并返回释放函数，释放使用独立上下文，避免停机取消导致锁滞留到过期。
+func (s *Scheduler) lock(ctx context.Context, name string) (func(), error) {
+	ok, err := s.locker.TryLock(ctx, name, s.lockTTL)
+	if err != nil {
+		if s.logger != nil {
+			s.logger.Error("acquire job lock failed, skip current schedule", zap.String("job", name), zap.Error(err))
+		}
+		return nil, err
+	}
+	if !ok {
+		if s.logger != nil {
+			s.logger.Info("job lock held by another replica, skip current schedule", zap.String("job", name))
+		}
+		return nil, ErrLockHeld
+	}
+	return func() {
+		if err := s.locker.Unlock(context.Background(), name); err != nil && s.logger != nil {
+			s.logger.Warn("release job lock failed", zap.String("job", name), zap.Error(err))
+		}
+	}, nil
 }
@@ -1,10 +1,20 @@
 package loader
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cmdb2neo/internal/cypher"
+	"cmdb2neo/internal/domain"
+)
 
 // Cleaner 负责删除过期节点和关系。
 type Cleaner struct {
 	client *Client
+	// Schema 决定删除时匹配的实际标签与关系类型，零值为默认 Schema。
+	Schema domain.Schema
 }
 
 func NewCleaner(client *Client) *Cleaner {
@@ -13,12 +23,141 @@ func NewCleaner(client *Client) *Cleaner {
 
 // HardDeleteNodes 删除 last_seen_run_id 小于 retentionRunID 的节点。
 func (c *Cleaner) HardDeleteNodes(ctx context.Context, retentionRunID string) error {
-	query := `MATCH (n) WHERE n.last_seen_run_id < $retention_run_id AND exists(n.cmdb_key) DETACH DELETE n`
-	return c.client.RunWrite(ctx, query, map[string]any{"retention_run_id": retentionRunID})
+	query := cypher.MustTemplate("hard_delete.cql", map[string]string{"LabelPattern": ""})
+	_, err := c.client.RunWrite(ctx, query, map[string]any{"retention_run_id": retentionRunID})
+	return err
 }
 
 // HardDeleteRelationships 删除 last_seen_run_id 小于 retentionRunID 的关系。
 func (c *Cleaner) HardDeleteRelationships(ctx context.Context, retentionRunID string) error {
 	query := `MATCH ()-[r]-() WHERE r.last_seen_run_id < $retention_run_id DELETE r`
-	return c.client.RunWrite(ctx, query, map[string]any{"retention_run_id": retentionRunID})
+	_, err := c.client.RunWrite(ctx, query, map[string]any{"retention_run_id": retentionRunID})
+	return err
+}
+
+// CountStale 按标签统计存活节点总数及 last_seen_run_id 小于 retentionRunID 的节点数，供删除前做比例保护。
+func (c *Cleaner) CountStale(ctx context.Context, retentionRunID string) ([]domain.LabelCount, error) {
+	query := `MATCH (n) WHERE n.cmdb_key IS NOT NULL AND coalesce(n.deleted, false) = false
+UNWIND [l IN labels(n) WHERE l IN $labels] AS label
+RETURN label, count(n) AS total, count(CASE WHEN n.last_seen_run_id < $retention_run_id THEN 1 END) AS stale
+ORDER BY label`
+	records, err := c.client.RunRead(ctx, query, map[string]any{"labels": c.Schema.EntityLabels(), "retention_run_id": retentionRunID})
+	if err != nil {
+		return nil, fmt.Errorf("统计过期节点失败: %w", err)
+	}
+	counts := make([]domain.LabelCount, 0, len(records))
+	for _, rec := range records {
+		label, _ := rec["label"].(string)
+		total, _ := rec["total"].(int64)
+		stale, _ := rec["stale"].(int64)
+		counts = append(counts, domain.LabelCount{Label: c.Schema.CanonicalLabel(label), Total: int(total), Stale: int(stale)})
+	}
+	return counts, nil
+}
+
+// SoftDeleteNodes 将 last_seen_run_id 小于 retentionRunID 的节点标记为墓碑（deleted=true 并记录 deleted_at），
+// 数据保留在图中，由 PurgeTombstones 在保留期后清除。
+func (c *Cleaner) SoftDeleteNodes(ctx context.Context, retentionRunID string) error {
+	query := cypher.MustTemplate("soft_delete.cql", map[string]string{"LabelPattern": ""})
+	_, err := c.client.RunWrite(ctx, query, map[string]any{"retention_run_id": retentionRunID})
+	return err
+}
+
+// SoftDeleteRelationships 将 last_seen_run_id 小于 retentionRunID 的关系标记为墓碑。
+func (c *Cleaner) SoftDeleteRelationships(ctx context.Context, retentionRunID string) error {
+	_, err := c.client.RunWrite(ctx, cypher.MustAsset("soft_delete_rels.cql"), map[string]any{"retention_run_id": retentionRunID})
+	return err
+}
+
+// PurgeTombstones 删除标记时间早于 retention 之前的墓碑关系与节点，未标记的数据不受影响。
+func (c *Cleaner) PurgeTombstones(ctx context.Context, retention time.Duration) error {
+	before := time.Now().Add(-retention).UnixMilli()
+	for _, stmt := range strings.Split(cypher.MustAsset("purge_tombstones.cql"), ";") {
+		query := strings.TrimSpace(stmt)
+		if query == "" {
+			continue
+		}
+		if _, err := c.client.RunWrite(ctx, query, map[string]any{"before": before}); err != nil {
+			return fmt.Errorf("清除墓碑失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeleteNodes 按 cmdb_key 删除指定节点及其关系，用于增量同步时移除已下线的实体。
+func (c *Cleaner) DeleteNodes(ctx context.Context, rows []domain.NodeRow) error {
+	grouped := make(map[string][]string)
+	patterns := make(map[string]string)
+	for _, row := range rows {
+		if err := domain.ValidateLabels(row.Labels); err != nil {
+			return fmt.Errorf("节点 %s: %w", row.CMDBKey, err)
+		}
+		key := domain.JoinLabels(row.Labels)
+		grouped[key] = append(grouped[key], row.CMDBKey)
+		patterns[key] = c.Schema.LabelPattern(row.Labels)
+	}
+	for key, keys := range grouped {
+		query := cypher.MustTemplate("delete_nodes.cql", map[string]string{"LabelPattern": patterns[key]})
+		if _, err := c.client.RunWrite(ctx, query, map[string]any{"keys": keys}); err != nil {
+			return fmt.Errorf("删除节点失败 labels=%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// DeleteRelationships 按起点、类型、终点删除指定关系。
+func (c *Cleaner) DeleteRelationships(ctx context.Context, rows []domain.RelRow) error {
+	grouped := make(map[string][]domain.RelRow)
+	for _, row := range rows {
+		if err := domain.ValidateRelType(row.Type); err != nil {
+			return fmt.Errorf("关系 %s->%s: %w", row.StartKey, row.EndKey, err)
+		}
+		grouped[row.Type] = append(grouped[row.Type], row)
+	}
+	for relType, rows := range grouped {
+		query := cypher.MustTemplate("delete_rels.cql", map[string]string{"RelType": ":" + c.Schema.Rel(relType)})
+		if _, err := c.client.RunWrite(ctx, query, map[string]any{"rows": toRelParameters(rows)}); err != nil {
+			return fmt.Errorf("删除关系失败 type=%s: %w", relType, err)
+		}
+	}
+	return nil
+}
+
+// TombstoneNodes 按 cmdb_key 将指定节点标记为墓碑，是 DeleteNodes 的软删除版本。
+func (c *Cleaner) TombstoneNodes(ctx context.Context, rows []domain.NodeRow) error {
+	grouped := make(map[string][]string)
+	patterns := make(map[string]string)
+	for _, row := range rows {
+		if err := domain.ValidateLabels(row.Labels); err != nil {
+			return fmt.Errorf("节点 %s: %w", row.CMDBKey, err)
+		}
+		key := domain.JoinLabels(row.Labels)
+		grouped[key] = append(grouped[key], row.CMDBKey)
+		patterns[key] = c.Schema.LabelPattern(row.Labels)
+	}
+	for key, keys := range grouped {
+		query := cypher.MustTemplate("tombstone_nodes.cql", map[string]string{"LabelPattern": patterns[key]})
+		if _, err := c.client.RunWrite(ctx, query, map[string]any{"keys": keys}); err != nil {
+			return fmt.Errorf("标记节点删除失败 labels=%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// TombstoneRelationships 按起点、类型、终点将指定关系标记为墓碑。
+func (c *Cleaner) TombstoneRelationships(ctx context.Context, rows []domain.RelRow) error {
+	grouped := make(map[string][]domain.RelRow)
+	for _, row := range rows {
+		if err := domain.ValidateRelType(row.Type); err != nil {
+			return fmt.Errorf("关系 %s->%s: %w", row.StartKey, row.EndKey, err)
+		}
+		grouped[row.Type] = append(grouped[row.Type], row)
+	}
+	for relType, rows := range grouped {
+		query := cypher.MustTemplate("tombstone_rels.cql", map[string]string{"RelType": ":" + c.Schema.Rel(relType)})
+		if _, err := c.client.RunWrite(ctx, query, map[string]any{"rows": toRelParameters(rows)}); err != nil {
+			return fmt.Errorf("标记关系删除失败 type=%s: %w", relType, err)
+		}
+	}
+	return nil
 }
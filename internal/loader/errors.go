@@ -0,0 +1,11 @@
+package loader
+
+import "cmdb2neo/internal/graph"
+
+// ErrNeo4jUnavailable 与 graph.ErrNeo4jUnavailable 为同一个错误，写入与只读查询的不可用错误可统一判断。
+var ErrNeo4jUnavailable = graph.ErrNeo4jUnavailable
+
+// classifyError 见 graph.ClassifyError。
+func classifyError(err error) error {
+	return graph.ClassifyError(err)
+}
@@ -6,28 +6,102 @@ import (
 	"strings"
 
 	"cmdb2neo/internal/cypher"
+	"cmdb2neo/internal/domain"
 )
 
+// EdgeFixClient 为 EdgeFixer 所需的最小客户端能力，默认由 Client 实现，便于测试替换。
+type EdgeFixClient interface {
+	RunWriteRecords(ctx context.Context, query string, params map[string]any) ([]map[string]any, error)
+}
+
+// EdgeFix 为一种关系的补边结果：Target 为关系终点的标签，Created 为本次新建的边，Repaired 为已软删除或失活后被恢复的边。
+type EdgeFix struct {
+	Type     string `json:"type"`
+	Target   string `json:"target"`
+	Created  int    `json:"created"`
+	Repaired int    `json:"repaired"`
+}
+
+// Name 返回 类型:终点标签，同一关系类型指向不同层级时用于区分。
+func (f EdgeFix) Name() string {
+	return f.Type + ":" + f.Target
+}
+
+// EdgeFixReport 汇总一次补边的结果，按 fix_edges.cql 中语句的顺序排列。
+type EdgeFixReport struct {
+	Fixes []EdgeFix `json:"fixes"`
+}
+
+// Created 返回各关系新建边数之和。
+func (r EdgeFixReport) Created() int {
+	total := 0
+	for _, fix := range r.Fixes {
+		total += fix.Created
+	}
+	return total
+}
+
+// Repaired 返回各关系恢复边数之和。
+func (r EdgeFixReport) Repaired() int {
+	total := 0
+	for _, fix := range r.Fixes {
+		total += fix.Repaired
+	}
+	return total
+}
+
 // EdgeFixer 根据属性补边，确保拓扑完整。
+//
+// 分页或增量写入时子节点可能先于父节点到达，按 key 写入的关系此时找不到端点而被跳过：
+// 宿主机晚于虚拟机到达时补 HOSTS_VM（vm.host_ip = host.ip），虚拟机或宿主机晚于容器到达时补 RUNS_ON（ctr.host_ip = 节点 ip），应用与机器 IP 事后对上时补 DEPLOYED_ON（app.ip = 机器 ip），
+// 承载层的选择与 cmdb.RowMapper 一致，可以是虚拟机、宿主机、物理机或容器。
+// 只处理至少一端在本次 runID 中写入过的节点，保持增量。
 type EdgeFixer struct {
-	client *Client
+	client EdgeFixClient
+	// Schema 决定补边语句中的实际标签与关系类型，零值为默认 Schema。
+	Schema domain.Schema
 }
 
-func NewEdgeFixer(client *Client) *EdgeFixer {
+func NewEdgeFixer(client EdgeFixClient) *EdgeFixer {
 	return &EdgeFixer{client: client}
 }
 
-func (f *EdgeFixer) Run(ctx context.Context, runID string) error {
-	statements := strings.Split(cypher.MustAsset("fix_edges.cql"), ";")
+// Run 执行 runID 范围内的补边并返回各关系新建与恢复的边数。
+func (f *EdgeFixer) Run(ctx context.Context, runID string) (EdgeFixReport, error) {
+	var report EdgeFixReport
+	statements := strings.Split(cypher.MustTemplate("fix_edges.cql", schemaNames(f.Schema)), ";")
 	for _, stmt := range statements {
 		query := strings.TrimSpace(stmt)
 		if query == "" {
 			continue
 		}
 		params := map[string]any{"run_id": runID}
-		if err := f.client.RunWrite(ctx, query, params); err != nil {
-			return fmt.Errorf("补边失败: %w", err)
+		records, err := f.client.RunWriteRecords(ctx, query, params)
+		if err != nil {
+			return report, fmt.Errorf("补边失败: %w", err)
+		}
+		for _, rec := range records {
+			relType, _ := rec["type"].(string)
+			target, _ := rec["target"].(string)
+			created, _ := rec["created"].(int64)
+			repaired, _ := rec["repaired"].(int64)
+			report.Fixes = append(report.Fixes, EdgeFix{Type: relType, Target: target, Created: int(created), Repaired: int(repaired)})
 		}
 	}
-	return nil
+	return report, nil
+}
+
+// schemaNames 为 cypher 模板提供 {{.Label.App}}、{{.Rel.DEPLOYED_ON}} 形式的实际名称，键为规范名。
+func schemaNames(s domain.Schema) map[string]map[string]string {
+	labels := make(map[string]string, len(domain.EntityLabels)+2)
+	for _, label := range domain.EntityLabels {
+		labels[label] = s.Label(label)
+	}
+	labels[domain.LabelMachine] = s.Label(domain.LabelMachine)
+	labels[domain.LabelCompute] = s.Label(domain.LabelCompute)
+	rels := make(map[string]string, len(domain.RelTypes))
+	for _, t := range domain.RelTypes {
+		rels[t] = s.Rel(t)
+	}
+	return map[string]map[string]string{"Label": labels, "Rel": rels}
 }
@@ -0,0 +1,64 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// LockLabel 为分布式锁节点的标签，不属于 CMDB 实体，不参与同步与清理。
+const LockLabel = "SyncLock"
+
+// acquireLockQuery 仅在锁不存在、已过期或由自己持有时重建锁节点，新建计数为 1 即表示获得锁。
+// name 上的唯一约束保证并发获取时至多一个副本成功，失败的一方返回约束冲突错误。
+const acquireLockQuery = `OPTIONAL MATCH (held:SyncLock {name: $name})
+WITH held WHERE held IS NULL OR held.expires_at < $now OR held.owner = $owner
+FOREACH (_ IN CASE WHEN held IS NULL THEN [] ELSE [1] END | DELETE held)
+CREATE (:SyncLock {name: $name, owner: $owner, acquired_at: $now, expires_at: $expires_at})`
+
+const releaseLockQuery = `MATCH (l:SyncLock {name: $name, owner: $owner}) DELETE l`
+
+// SyncLock 以 Neo4j 中带过期时间的 :SyncLock 节点实现租约锁，避免多副本同时执行定时同步。
+type SyncLock struct {
+	client *Client
+	owner  string
+	now    func() time.Time
+}
+
+// NewSyncLock 创建租约锁，owner 为空时使用 主机名-进程号。
+func NewSyncLock(client *Client, owner string) *SyncLock {
+	if owner == "" {
+		host, _ := os.Hostname()
+		owner = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	return &SyncLock{client: client, owner: owner, now: time.Now}
+}
+
+// Owner 返回锁持有者标识。
+func (l *SyncLock) Owner() string {
+	return l.owner
+}
+
+// TryLock 尝试获取名为 name 的租约，ttl 后未释放的租约视为过期，可被其他副本接管。
+func (l *SyncLock) TryLock(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	now := l.now()
+	stats, err := l.client.RunWrite(ctx, acquireLockQuery, map[string]any{
+		"name":       name,
+		"owner":      l.owner,
+		"now":        now.UnixMilli(),
+		"expires_at": now.Add(ttl).UnixMilli(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("获取同步锁 %s 失败: %w", name, err)
+	}
+	return stats.Created > 0, nil
+}
+
+// Unlock 释放自己持有的租约，锁已过期被他人接管时不做任何修改。
+func (l *SyncLock) Unlock(ctx context.Context, name string) error {
+	if _, err := l.client.RunWrite(ctx, releaseLockQuery, map[string]any{"name": name, "owner": l.owner}); err != nil {
+		return fmt.Errorf("释放同步锁 %s 失败: %w", name, err)
+	}
+	return nil
+}
@@ -3,9 +3,16 @@ package loader
 import (
 	"context"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/graph"
+	"cmdb2neo/internal/metrics"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Config 控制 Neo4j 连接参数。
@@ -16,69 +23,319 @@ type Config struct {
 	Database             string
 	MaxConnectionPool    int
 	ConnectionTimeoutSec int
+	// QueryTimeoutSec 为单个读写事务（含结果遍历）的超时秒数，未配置时使用 graph.DefaultQueryTimeout。
+	QueryTimeoutSec int
+	// Routing 为 true 时按集群路由访问，bolt:// 地址会改写为 neo4j://，默认直连单实例。
+	Routing bool
 }
 
 // Client 封装 Neo4j Driver，提供最小写接口。
 type Client struct {
-	driver   neo4j.DriverWithContext
-	database string
+	mu           sync.RWMutex
+	driver       neo4j.DriverWithContext
+	dial         func(ctx context.Context) (neo4j.DriverWithContext, error)
+	inUse        atomic.Int64
+	database     string
+	queryTimeout time.Duration
+	bookmarks    neo4j.BookmarkManager
+	recorder     metrics.Recorder
+	tracer       trace.Tracer
+}
+
+// ClientOption 配置 Client 的可选项。
+type ClientOption func(*Client)
+
+// WithRecorder 注入查询耗时指标的记录器。
+func WithRecorder(recorder metrics.Recorder) ClientOption {
+	return func(c *Client) {
+		c.recorder = recorder
+	}
+}
+
+// WithTracerProvider 注入写入 span 使用的 TracerProvider，未配置时不产生 span。
+func WithTracerProvider(provider trace.TracerProvider) ClientOption {
+	return func(c *Client) {
+		c.tracer = graph.Tracer(provider)
+	}
+}
+
+// WithBookmarkManager 将写事务产生的书签记录到共享的 BookmarkManager，供读侧做因果一致读取。
+func WithBookmarkManager(bookmarks neo4j.BookmarkManager) ClientOption {
+	return func(c *Client) {
+		c.bookmarks = bookmarks
+	}
 }
 
 // NewClient 创建一个新的 Neo4j 客户端。
-func NewClient(ctx context.Context, cfg Config) (*Client, error) {
+func NewClient(ctx context.Context, cfg Config, opts ...ClientOption) (*Client, error) {
 	if cfg.URI == "" {
 		return nil, fmt.Errorf("neo4j uri 不能为空")
 	}
+	uri, err := graph.RoutingURI(cfg.URI, cfg.Routing)
+	if err != nil {
+		return nil, err
+	}
 	auth := neo4j.BasicAuth(cfg.Username, cfg.Password, "")
-	driver, err := neo4j.NewDriverWithContext(cfg.URI, auth, func(config *neo4j.Config) {
-		if cfg.MaxConnectionPool > 0 {
-			config.MaxConnectionPoolSize = cfg.MaxConnectionPool
+	dial := func(ctx context.Context) (neo4j.DriverWithContext, error) {
+		driver, err := neo4j.NewDriverWithContext(uri, auth, func(config *neo4j.Config) {
+			if cfg.MaxConnectionPool > 0 {
+				config.MaxConnectionPoolSize = cfg.MaxConnectionPool
+			}
+			if cfg.ConnectionTimeoutSec > 0 {
+				config.SocketConnectTimeout = time.Duration(cfg.ConnectionTimeoutSec) * time.Second
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("创建 neo4j driver 失败: %w", err)
 		}
-		if cfg.ConnectionTimeoutSec > 0 {
-			config.SocketConnectTimeout = time.Duration(cfg.ConnectionTimeoutSec) * time.Second
+		if err := driver.VerifyConnectivity(ctx); err != nil {
+			_ = driver.Close(ctx)
+			return nil, domain.MarkError(ErrNeo4jUnavailable, fmt.Errorf("neo4j 无法连通: %w", err))
 		}
-	})
+		return driver, nil
+	}
+	driver, err := dial(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("创建 neo4j driver 失败: %w", err)
+		return nil, err
 	}
-	if err := driver.VerifyConnectivity(ctx); err != nil {
-		_ = driver.Close(ctx)
-		return nil, fmt.Errorf("neo4j 无法连通: %w", err)
+	client := &Client{driver: driver, dial: dial, database: cfg.Database, queryTimeout: graph.QueryTimeout(cfg.QueryTimeoutSec)}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(client)
+		}
+	}
+	client.recorder = metrics.OrNop(client.recorder)
+	if client.tracer == nil {
+		client.tracer = graph.Tracer(nil)
+	}
+	return client, nil
+}
+
+// sessionConfig 返回指定访问模式的会话配置，配置了 BookmarkManager 时在会话间传递书签。
+func (c *Client) sessionConfig(mode neo4j.AccessMode) neo4j.SessionConfig {
+	return neo4j.SessionConfig{DatabaseName: c.database, AccessMode: mode, BookmarkManager: c.bookmarks}
+}
+
+// currentDriver 返回当前使用的 driver，Reconnect 可能随时替换它。
+func (c *Client) currentDriver() neo4j.DriverWithContext {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.driver
+}
+
+// openSession 打开会话并计入使用中的会话数，返回的 release 负责关闭会话。
+func (c *Client) openSession(ctx context.Context, mode neo4j.AccessMode) (neo4j.SessionWithContext, func()) {
+	sess := c.currentDriver().NewSession(ctx, c.sessionConfig(mode))
+	c.recorder.SetSessionsInUse("loader", int(c.inUse.Add(1)))
+	return sess, func() {
+		_ = sess.Close(ctx)
+		c.recorder.SetSessionsInUse("loader", int(c.inUse.Add(-1)))
 	}
-	return &Client{driver: driver, database: cfg.Database}, nil
 }
 
 // Close 关闭连接。
 func (c *Client) Close(ctx context.Context) error {
-	if c == nil || c.driver == nil {
+	if c == nil {
 		return nil
 	}
-	return c.driver.Close(ctx)
+	driver := c.currentDriver()
+	if driver == nil {
+		return nil
+	}
+	return driver.Close(ctx)
 }
 
-// RunWrite 执行写事务。
-func (c *Client) RunWrite(ctx context.Context, query string, params map[string]any) error {
-	sess := c.driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: c.database, AccessMode: neo4j.AccessModeWrite})
-	defer sess.Close(ctx)
-	_, err := sess.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
-		_, runErr := tx.Run(ctx, query, params)
-		return nil, runErr
-	})
+// VerifyConnectivity 实现 graph.Connector，校验当前 driver 能否连通。
+func (c *Client) VerifyConnectivity(ctx context.Context) error {
+	return c.currentDriver().VerifyConnectivity(ctx)
+}
+
+// Reconnect 实现 graph.Connector，新建并校验 driver 后替换旧 driver，旧 driver 上进行中的写入会失败并由同步重试。
+func (c *Client) Reconnect(ctx context.Context) error {
+	driver, err := c.dial(ctx)
 	if err != nil {
-		return fmt.Errorf("执行写入失败: %w", err)
+		return err
+	}
+	c.mu.Lock()
+	old := c.driver
+	c.driver = driver
+	c.mu.Unlock()
+	if old != nil {
+		_ = old.Close(ctx)
 	}
 	return nil
 }
 
+// WriteStats 汇总写入结果，Created 为新建的节点与关系数，Updated 为命中已有实体的行数。
+type WriteStats struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Batches int `json:"batches"`
+}
+
+// Add 累加另一次写入的统计。
+func (s *WriteStats) Add(other WriteStats) {
+	s.Created += other.Created
+	s.Updated += other.Updated
+	s.Batches += other.Batches
+}
+
+func statsFromSummary(summary neo4j.ResultSummary) WriteStats {
+	counters := summary.Counters()
+	return WriteStats{Created: counters.NodesCreated() + counters.RelationshipsCreated(), Batches: 1}
+}
+
+// RunWrite 执行写事务，返回由 ResultSummary 计数得到的新建数量，Updated 由调用方按行数推算。
+func (c *Client) RunWrite(ctx context.Context, query string, params map[string]any) (stats WriteStats, err error) {
+	ctx, span := graph.StartQuerySpan(ctx, c.tracer, "neo4j.write", query, params)
+	start := time.Now()
+	defer func() {
+		c.recorder.ObserveQuery("write", time.Since(start), err)
+		span.SetAttributes(attribute.Int("db.created_count", stats.Created))
+		graph.EndSpan(span, err)
+	}()
+
+	sess, release := c.openSession(ctx, neo4j.AccessModeWrite)
+	defer release()
+	queryCtx, cancel := graph.WithQueryTimeout(ctx, c.queryTimeout)
+	defer cancel()
+	out, err := sess.ExecuteWrite(queryCtx, func(tx neo4j.ManagedTransaction) (any, error) {
+		res, runErr := tx.Run(queryCtx, query, params)
+		if runErr != nil {
+			return nil, runErr
+		}
+		summary, runErr := res.Consume(queryCtx)
+		if runErr != nil {
+			return nil, runErr
+		}
+		return statsFromSummary(summary), nil
+	})
+	if err != nil {
+		return WriteStats{}, classifyError(fmt.Errorf("执行写入失败: %w", graph.TimeoutError(queryCtx, c.queryTimeout, err)))
+	}
+	return out.(WriteStats), nil
+}
+
+// RunWriteRecords 执行写事务并返回语句 RETURN 的记录，供需要回报写入结果明细的步骤使用。
+func (c *Client) RunWriteRecords(ctx context.Context, query string, params map[string]any) (records []map[string]any, err error) {
+	ctx, span := graph.StartQuerySpan(ctx, c.tracer, "neo4j.write", query, params)
+	start := time.Now()
+	defer func() {
+		c.recorder.ObserveQuery("write", time.Since(start), err)
+		span.SetAttributes(attribute.Int("db.record_count", len(records)))
+		graph.EndSpan(span, err)
+	}()
+
+	sess, release := c.openSession(ctx, neo4j.AccessModeWrite)
+	defer release()
+	queryCtx, cancel := graph.WithQueryTimeout(ctx, c.queryTimeout)
+	defer cancel()
+	out, err := sess.ExecuteWrite(queryCtx, func(tx neo4j.ManagedTransaction) (any, error) {
+		res, runErr := tx.Run(queryCtx, query, params)
+		if runErr != nil {
+			return nil, runErr
+		}
+		records := make([]map[string]any, 0)
+		for res.Next(queryCtx) {
+			records = append(records, res.Record().AsMap())
+		}
+		return records, res.Err()
+	})
+	if err != nil {
+		return nil, classifyError(fmt.Errorf("执行写入失败: %w", graph.TimeoutError(queryCtx, c.queryTimeout, err)))
+	}
+	return out.([]map[string]any), nil
+}
+
+// Statement 为一条待执行的 Cypher 语句及其参数。
+type Statement struct {
+	Query  string
+	Params map[string]any
+}
+
+// RunWriteBatch 在同一个写事务中依次执行多条语句，任一失败整体回滚，返回各语句统计之和。
+func (c *Client) RunWriteBatch(ctx context.Context, statements []Statement) (stats WriteStats, err error) {
+	if len(statements) == 0 {
+		return WriteStats{}, nil
+	}
+	ctx, span := c.tracer.Start(ctx, "neo4j.write_batch", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system", "neo4j"),
+		attribute.String("db.query.name", graph.QueryName(statements[0].Query)),
+		attribute.Int("db.statement_count", len(statements)),
+	))
+	start := time.Now()
+	defer func() {
+		c.recorder.ObserveQuery("write", time.Since(start), err)
+		span.SetAttributes(attribute.Int("db.created_count", stats.Created))
+		graph.EndSpan(span, err)
+	}()
+
+	sess, release := c.openSession(ctx, neo4j.AccessModeWrite)
+	defer release()
+	// 超时覆盖整个批量事务而不是单条语句
+	queryCtx, cancel := graph.WithQueryTimeout(ctx, c.queryTimeout)
+	defer cancel()
+	out, err := sess.ExecuteWrite(queryCtx, func(tx neo4j.ManagedTransaction) (any, error) {
+		var stats WriteStats
+		for i, stmt := range statements {
+			res, runErr := tx.Run(queryCtx, stmt.Query, stmt.Params)
+			if runErr != nil {
+				return nil, fmt.Errorf("第 %d 条语句: %w", i+1, runErr)
+			}
+			summary, runErr := res.Consume(queryCtx)
+			if runErr != nil {
+				return nil, fmt.Errorf("第 %d 条语句: %w", i+1, runErr)
+			}
+			stats.Add(statsFromSummary(summary))
+		}
+		return stats, nil
+	})
+	if err != nil {
+		return WriteStats{}, classifyError(fmt.Errorf("执行批量写入失败: %w", graph.TimeoutError(queryCtx, c.queryTimeout, err)))
+	}
+	return out.(WriteStats), nil
+}
+
+// RunRead 执行读事务并返回记录集合，供一致性检查等只读场景使用。
+func (c *Client) RunRead(ctx context.Context, query string, params map[string]any) (records []map[string]any, err error) {
+	ctx, span := graph.StartQuerySpan(ctx, c.tracer, "neo4j.read", query, params)
+	start := time.Now()
+	defer func() {
+		c.recorder.ObserveQuery("read", time.Since(start), err)
+		span.SetAttributes(attribute.Int("db.record_count", len(records)))
+		graph.EndSpan(span, err)
+	}()
+
+	sess, release := c.openSession(ctx, neo4j.AccessModeRead)
+	defer release()
+	queryCtx, cancel := graph.WithQueryTimeout(ctx, c.queryTimeout)
+	defer cancel()
+	out, err := sess.ExecuteRead(queryCtx, func(tx neo4j.ManagedTransaction) (any, error) {
+		res, runErr := tx.Run(queryCtx, query, params)
+		if runErr != nil {
+			return nil, runErr
+		}
+		records := make([]map[string]any, 0)
+		for res.Next(queryCtx) {
+			records = append(records, res.Record().AsMap())
+		}
+		return records, res.Err()
+	})
+	if err != nil {
+		return nil, classifyError(fmt.Errorf("执行查询失败: %w", graph.TimeoutError(queryCtx, c.queryTimeout, err)))
+	}
+	return out.([]map[string]any), nil
+}
+
 // RunRaw 在已有事务外执行原始语句（无事务）。
 func (c *Client) RunRaw(ctx context.Context, query string, params map[string]any) error {
-	sess := c.driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: c.database, AccessMode: neo4j.AccessModeWrite})
-	defer sess.Close(ctx)
+	sess, release := c.openSession(ctx, neo4j.AccessModeWrite)
+	defer release()
 	res, err := sess.Run(ctx, query, params)
 	if err != nil {
-		return fmt.Errorf("执行语句失败: %w", err)
+		return classifyError(fmt.Errorf("执行语句失败: %w", err))
 	}
-	return consume(ctx, res)
+	return classifyError(consume(ctx, res))
 }
 
 func consume(ctx context.Context, result neo4j.ResultWithContext) error {
@@ -13,6 +13,11 @@ import (
 type NodeUpserter struct {
 	client    *Client
 	batchSize int
+	// BatchTransactional 为 true 时一次调用的所有批次在同一事务中写入，失败整体回滚；
+	// 默认逐批提交，避免超大事务耗尽内存。
+	BatchTransactional bool
+	// Schema 决定写入时使用的实际标签，零值为默认 Schema。
+	Schema domain.Schema
 }
 
 // NewNodeUpserter 创建节点 upsert 器。
@@ -24,26 +29,34 @@ func NewNodeUpserter(client *Client, batchSize int) *NodeUpserter {
 }
 
 // InitNodes 负责初始化节点（首跑使用）。
-func (u *NodeUpserter) InitNodes(ctx context.Context, rows []domain.NodeRow) error {
+func (u *NodeUpserter) InitNodes(ctx context.Context, rows []domain.NodeRow) (WriteStats, error) {
 	return u.write(ctx, rows, true)
 }
 
 // UpsertNodes 负责增量 upsert。
-func (u *NodeUpserter) UpsertNodes(ctx context.Context, rows []domain.NodeRow) error {
+func (u *NodeUpserter) UpsertNodes(ctx context.Context, rows []domain.NodeRow) (WriteStats, error) {
 	return u.write(ctx, rows, false)
 }
 
-func (u *NodeUpserter) write(ctx context.Context, rows []domain.NodeRow, init bool) error {
+func (u *NodeUpserter) write(ctx context.Context, rows []domain.NodeRow, init bool) (WriteStats, error) {
+	var stats WriteStats
 	if len(rows) == 0 {
-		return nil
+		return stats, nil
 	}
 	grouped := make(map[string][]domain.NodeRow)
 	labelCache := make(map[string]string)
 	for _, row := range rows {
+		if row.RunID == "" {
+			// 空批次号会被清理视为过期数据
+			return stats, fmt.Errorf("节点 %s 缺少 run_id", row.CMDBKey)
+		}
 		key := domain.JoinLabels(row.Labels)
 		grouped[key] = append(grouped[key], row)
 		if _, ok := labelCache[key]; !ok {
-			labelCache[key] = domain.LabelPattern(row.Labels)
+			if err := domain.ValidateLabels(row.Labels); err != nil {
+				return stats, fmt.Errorf("节点 %s: %w", row.CMDBKey, err)
+			}
+			labelCache[key] = u.Schema.LabelPattern(row.Labels)
 		}
 	}
 
@@ -52,6 +65,7 @@ func (u *NodeUpserter) write(ctx context.Context, rows []domain.NodeRow, init bo
 		tplName = "init_nodes.cql"
 	}
 
+	var statements []Statement
 	for key, rows := range grouped {
 		if len(rows) == 0 {
 			continue
@@ -60,12 +74,27 @@ func (u *NodeUpserter) write(ctx context.Context, rows []domain.NodeRow, init bo
 		query := cypher.MustTemplate(tplName, map[string]string{"LabelPattern": labelPattern})
 		for _, chunk := range util.Batch(rows, u.batchSize) {
 			params := map[string]any{"rows": toNodeParameters(chunk)}
-			if err := u.client.RunWrite(ctx, query, params); err != nil {
-				return fmt.Errorf("写入节点失败 labels=%s: %w", key, err)
+			if u.BatchTransactional {
+				statements = append(statements, Statement{Query: query, Params: params})
+				continue
+			}
+			chunkStats, err := u.client.RunWrite(ctx, query, params)
+			if err != nil {
+				return stats, fmt.Errorf("写入节点失败 labels=%s: %w", key, err)
 			}
+			stats.Add(chunkStats)
+		}
+	}
+	if len(statements) > 0 {
+		batchStats, err := u.client.RunWriteBatch(ctx, statements)
+		if err != nil {
+			return WriteStats{}, fmt.Errorf("写入节点失败: %w", err)
 		}
+		stats.Add(batchStats)
 	}
-	return nil
+	// MERGE 未新建即命中已有节点
+	stats.Updated = len(rows) - stats.Created
+	return stats, nil
 }
 
 func toNodeParameters(rows []domain.NodeRow) []map[string]any {
@@ -0,0 +1,85 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cmdb2neo/internal/cypher"
+	"cmdb2neo/internal/domain"
+)
+
+// OrphanFix 为一种标签的孤儿修复结果：Orphans 为修复前缺少上游关系的存活节点数，Fixed 为本次重新挂上的数量。
+type OrphanFix struct {
+	Label   string `json:"label"`
+	Type    string `json:"type"`
+	Orphans int    `json:"orphans"`
+	Fixed   int    `json:"fixed"`
+}
+
+// Remaining 返回按现有属性仍无法挂回的孤儿数，包括找不到或找到多个候选上游的节点。
+func (f OrphanFix) Remaining() int {
+	return f.Orphans - f.Fixed
+}
+
+// OrphanReport 汇总一次孤儿修复的结果，按 reconcile_orphans.cql 中语句的顺序排列。
+type OrphanReport struct {
+	Labels []OrphanFix `json:"labels"`
+}
+
+// Fixed 返回各标签重新挂上的节点数之和。
+func (r OrphanReport) Fixed() int {
+	total := 0
+	for _, fix := range r.Labels {
+		total += fix.Fixed
+	}
+	return total
+}
+
+// Remaining 返回各标签仍为孤儿的节点数之和。
+func (r OrphanReport) Remaining() int {
+	total := 0
+	for _, fix := range r.Labels {
+		total += fix.Remaining()
+	}
+	return total
+}
+
+// OrphanReconciler 为缺少上游关系的节点补挂父节点，是事后修复工具，不限定 runID，扫描全图。
+//
+// 与 EdgeFixer 只处理本次写入的节点不同，多次异常同步后残留的孤儿也会被找出：
+// 虚拟机按 host_ip 挂回宿主机或物理机，容器按 host_ip 挂回虚拟机或宿主机，宿主机与物理机按 network_partion 挂回网络分区，网络分区按 idc 挂回机房；
+// 候选上游不存在或不唯一时不做猜测，计入 Remaining。
+type OrphanReconciler struct {
+	client EdgeFixClient
+	// Schema 决定修复语句中的实际标签与关系类型，零值为默认 Schema。
+	Schema domain.Schema
+}
+
+func NewOrphanReconciler(client EdgeFixClient) *OrphanReconciler {
+	return &OrphanReconciler{client: client}
+}
+
+// Run 扫描各标签的孤儿并尝试挂回上游，新建或恢复的关系记入 runID。
+func (o *OrphanReconciler) Run(ctx context.Context, runID string) (OrphanReport, error) {
+	var report OrphanReport
+	statements := strings.Split(cypher.MustTemplate("reconcile_orphans.cql", schemaNames(o.Schema)), ";")
+	for _, stmt := range statements {
+		query := strings.TrimSpace(stmt)
+		if query == "" {
+			continue
+		}
+		records, err := o.client.RunWriteRecords(ctx, query, map[string]any{"run_id": runID})
+		if err != nil {
+			return report, fmt.Errorf("修复孤儿节点失败: %w", err)
+		}
+		for _, rec := range records {
+			label, _ := rec["label"].(string)
+			relType, _ := rec["type"].(string)
+			orphans, _ := rec["orphans"].(int64)
+			fixed, _ := rec["fixed"].(int64)
+			report.Labels = append(report.Labels, OrphanFix{Label: label, Type: relType, Orphans: int(orphans), Fixed: int(fixed)})
+		}
+	}
+	return report, nil
+}
@@ -13,6 +13,11 @@ import (
 type RelUpserter struct {
 	client    *Client
 	batchSize int
+	// BatchTransactional 为 true 时一次调用的所有批次在同一事务中写入，失败整体回滚；
+	// 默认逐批提交，避免超大事务耗尽内存。
+	BatchTransactional bool
+	// Schema 决定写入时使用的实际关系类型，零值为默认 Schema。
+	Schema domain.Schema
 }
 
 func NewRelUpserter(client *Client, batchSize int) *RelUpserter {
@@ -22,20 +27,27 @@ func NewRelUpserter(client *Client, batchSize int) *RelUpserter {
 	return &RelUpserter{client: client, batchSize: batchSize}
 }
 
-func (u *RelUpserter) InitRels(ctx context.Context, rows []domain.RelRow) error {
+func (u *RelUpserter) InitRels(ctx context.Context, rows []domain.RelRow) (WriteStats, error) {
 	return u.write(ctx, rows, true)
 }
 
-func (u *RelUpserter) UpsertRels(ctx context.Context, rows []domain.RelRow) error {
+func (u *RelUpserter) UpsertRels(ctx context.Context, rows []domain.RelRow) (WriteStats, error) {
 	return u.write(ctx, rows, false)
 }
 
-func (u *RelUpserter) write(ctx context.Context, rows []domain.RelRow, init bool) error {
+func (u *RelUpserter) write(ctx context.Context, rows []domain.RelRow, init bool) (WriteStats, error) {
+	var stats WriteStats
 	if len(rows) == 0 {
-		return nil
+		return stats, nil
 	}
 	grouped := make(map[string][]domain.RelRow)
 	for _, row := range rows {
+		if row.RunID == "" {
+			return stats, fmt.Errorf("关系 %s-[%s]->%s 缺少 run_id", row.StartKey, row.Type, row.EndKey)
+		}
+		if err := domain.ValidateRelType(row.Type); err != nil {
+			return stats, fmt.Errorf("关系 %s->%s: %w", row.StartKey, row.EndKey, err)
+		}
 		grouped[row.Type] = append(grouped[row.Type], row)
 	}
 
@@ -44,29 +56,50 @@ func (u *RelUpserter) write(ctx context.Context, rows []domain.RelRow, init bool
 		tplName = "init_edges.cql"
 	}
 
+	var statements []Statement
 	for relType, rows := range grouped {
 		if len(rows) == 0 {
 			continue
 		}
-		relPattern := fmt.Sprintf(":%s", relType)
+		relPattern := fmt.Sprintf(":%s", u.Schema.Rel(relType))
 		query := cypher.MustTemplate(tplName, map[string]string{"RelType": relPattern})
 		for _, chunk := range util.Batch(rows, u.batchSize) {
 			params := map[string]any{"rows": toRelParameters(chunk)}
-			if err := u.client.RunWrite(ctx, query, params); err != nil {
-				return fmt.Errorf("写入关系失败 type=%s: %w", relType, err)
+			if u.BatchTransactional {
+				statements = append(statements, Statement{Query: query, Params: params})
+				continue
+			}
+			chunkStats, err := u.client.RunWrite(ctx, query, params)
+			if err != nil {
+				return stats, fmt.Errorf("写入关系失败 type=%s: %w", relType, err)
 			}
+			stats.Add(chunkStats)
 		}
 	}
-	return nil
+	if len(statements) > 0 {
+		batchStats, err := u.client.RunWriteBatch(ctx, statements)
+		if err != nil {
+			return WriteStats{}, fmt.Errorf("写入关系失败: %w", err)
+		}
+		stats.Add(batchStats)
+	}
+	// 端点缺失的行既未新建也未命中，这里按行数推算会略微偏大
+	stats.Updated = len(rows) - stats.Created
+	return stats, nil
 }
 
 func toRelParameters(rows []domain.RelRow) []map[string]any {
 	res := make([]map[string]any, 0, len(rows))
 	for _, row := range rows {
+		props := row.Properties
+		if props == nil {
+			// SET r += null 会报错，没有属性的关系传空 map
+			props = map[string]any{}
+		}
 		res = append(res, map[string]any{
 			"start_key":  row.StartKey,
 			"end_key":    row.EndKey,
-			"properties": map[string]any(row.Properties),
+			"properties": props,
 			"run_id":     row.RunID,
 		})
 	}
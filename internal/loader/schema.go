@@ -2,31 +2,206 @@ package loader
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
+	"slices"
 
-	"cmdb2neo/internal/cypher"
+	"cmdb2neo/internal/domain"
 )
 
+// SchemaClient 为 SchemaManager 所需的最小客户端能力，默认由 Client 实现，便于测试替换。
+type SchemaClient interface {
+	RunRaw(ctx context.Context, query string, params map[string]any) error
+	RunRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error)
+}
+
 // SchemaManager 负责初始化约束和索引。
 type SchemaManager struct {
-	client *Client
+	client SchemaClient
+	// Schema 决定约束和索引建在哪些实际标签上，零值为默认 Schema。
+	Schema domain.Schema
 }
 
-func NewSchemaManager(client *Client) *SchemaManager {
+func NewSchemaManager(client SchemaClient) *SchemaManager {
 	return &SchemaManager{client: client}
 }
 
-func (m *SchemaManager) Ensure(ctx context.Context) error {
-	statements := strings.Split(cypher.MustAsset("init_schema.cql"), ";")
-	for _, raw := range statements {
-		query := strings.TrimSpace(raw)
-		if query == "" {
-			continue
+// SchemaObject 声明一个约束或索引，Unique 为 true 时为唯一约束，否则为普通索引。
+type SchemaObject struct {
+	Name     string
+	Label    string
+	Property string
+	Unique   bool
+}
+
+func (o SchemaObject) kind() string {
+	if o.Unique {
+		return "CONSTRAINT"
+	}
+	return "INDEX"
+}
+
+// CreateStatement 返回幂等的创建语句。
+func (o SchemaObject) CreateStatement() string {
+	if o.Unique {
+		return fmt.Sprintf("CREATE CONSTRAINT %s IF NOT EXISTS FOR (n:%s) REQUIRE n.%s IS UNIQUE", o.Name, o.Label, o.Property)
+	}
+	return fmt.Sprintf("CREATE INDEX %s IF NOT EXISTS FOR (n:%s) ON (n.%s)", o.Name, o.Label, o.Property)
+}
+
+// DropStatement 返回幂等的删除语句。
+func (o SchemaObject) DropStatement() string {
+	return fmt.Sprintf("DROP %s %s IF EXISTS", o.kind(), o.Name)
+}
+
+// RequiredSchema 为同步依赖的约束和索引：每个主标签的 cmdb_key 唯一，RCA 结果按 window_id 唯一，同步锁按 name 唯一，补边与 RCA 定位时按 ip、name 查找的属性建索引。
+var RequiredSchema = []SchemaObject{
+	{Name: "idc_cmdb_key", Label: domain.LabelIDC, Property: domain.PropCMDBKey, Unique: true},
+	{Name: "np_cmdb_key", Label: domain.LabelNetPartition, Property: domain.PropCMDBKey, Unique: true},
+	{Name: "host_cmdb_key", Label: domain.LabelHostMachine, Property: domain.PropCMDBKey, Unique: true},
+	{Name: "physical_cmdb_key", Label: domain.LabelPhysicalMachine, Property: domain.PropCMDBKey, Unique: true},
+	{Name: "vm_cmdb_key", Label: domain.LabelVirtualMachine, Property: domain.PropCMDBKey, Unique: true},
+	{Name: "container_cmdb_key", Label: domain.LabelContainer, Property: domain.PropCMDBKey, Unique: true},
+	{Name: "app_cmdb_key", Label: domain.LabelApp, Property: domain.PropCMDBKey, Unique: true},
+	{Name: "service_cmdb_key", Label: domain.LabelService, Property: domain.PropCMDBKey, Unique: true},
+	{Name: "rca_result_window_id", Label: "RCAResult", Property: "window_id", Unique: true},
+	{Name: "sync_lock_name", Label: LockLabel, Property: "name", Unique: true},
+	{Name: "vm_host_ip", Label: domain.LabelVirtualMachine, Property: "host_ip"},
+	{Name: "host_ip", Label: domain.LabelHostMachine, Property: "ip"},
+	{Name: "physical_ip", Label: domain.LabelPhysicalMachine, Property: "ip"},
+	{Name: "container_host_ip", Label: domain.LabelContainer, Property: "host_ip"},
+	{Name: "container_ip", Label: domain.LabelContainer, Property: "ip"},
+	{Name: "app_ip", Label: domain.LabelApp, Property: "ip"},
+	{Name: "vm_ip", Label: domain.LabelVirtualMachine, Property: "ip"},
+	{Name: "host_hostname", Label: domain.LabelHostMachine, Property: "hostname"},
+	{Name: "physical_hostname", Label: domain.LabelPhysicalMachine, Property: "hostname"},
+	{Name: "vm_hostname", Label: domain.LabelVirtualMachine, Property: "hostname"},
+	{Name: "container_hostname", Label: domain.LabelContainer, Property: "hostname"},
+	{Name: "app_name", Label: domain.LabelApp, Property: "name"},
+	{Name: "service_name", Label: domain.LabelService, Property: "name"},
+	{Name: "np_name", Label: domain.LabelNetPartition, Property: "name"},
+	{Name: "idc_name", Label: domain.LabelIDC, Property: "name"},
+}
+
+// IndexedProperties 返回各标签上有索引覆盖的属性（唯一约束自带索引），用于校验查询条件均有索引。
+func (m *SchemaManager) IndexedProperties() map[string][]string {
+	out := make(map[string][]string)
+	for _, obj := range RequiredSchema {
+		if !slices.Contains(out[obj.Label], obj.Property) {
+			out[obj.Label] = append(out[obj.Label], obj.Property)
+		}
+	}
+	return out
+}
+
+// SchemaReport 记录 Ensure 新建与已存在的约束、索引名称。
+type SchemaReport struct {
+	Created  []string `json:"created"`
+	Existing []string `json:"existing"`
+}
+
+// Ensure 按 RequiredSchema 创建缺失的约束和索引，重复执行不会报错，返回本次新建与已存在的对象。
+func (m *SchemaManager) Ensure(ctx context.Context) (SchemaReport, error) {
+	existing, err := m.existingNames(ctx)
+	if err != nil {
+		return SchemaReport{}, err
+	}
+	var report SchemaReport
+	for _, obj := range RequiredSchema {
+		obj.Label = m.Schema.Label(obj.Label)
+		if err := m.client.RunRaw(ctx, obj.CreateStatement(), nil); err != nil {
+			return report, fmt.Errorf("创建 %s 失败: %w", obj.Name, err)
+		}
+		if existing[obj.Name] {
+			report.Existing = append(report.Existing, obj.Name)
+		} else {
+			report.Created = append(report.Created, obj.Name)
+		}
+	}
+	return report, nil
+}
+
+// Drop 删除 RequiredSchema 中的约束和索引，不影响数据，主要用于测试清理。
+func (m *SchemaManager) Drop(ctx context.Context) error {
+	for _, obj := range RequiredSchema {
+		if err := m.client.RunRaw(ctx, obj.DropStatement(), nil); err != nil {
+			return fmt.Errorf("删除 %s 失败: %w", obj.Name, err)
+		}
+	}
+	return nil
+}
+
+// existingNames 查询库中已有的约束与索引名称。
+func (m *SchemaManager) existingNames(ctx context.Context) (map[string]bool, error) {
+	names := make(map[string]bool)
+	for _, query := range []string{"SHOW CONSTRAINTS YIELD name", "SHOW INDEXES YIELD name"} {
+		records, err := m.client.RunRead(ctx, query, nil)
+		if err != nil {
+			return nil, fmt.Errorf("查询已有 schema 失败: %w", err)
+		}
+		for _, rec := range records {
+			if name, ok := rec["name"].(string); ok {
+				names[name] = true
+			}
 		}
+	}
+	return names, nil
+}
+
+// ErrResetNotConfirmed 表示调用 Reset 时未显式确认。
+var ErrResetNotConfirmed = errors.New("reset 需要显式确认 Confirm=true")
+
+// ResetOptions 控制 Reset 的清理范围。
+type ResetOptions struct {
+	// Confirm 必须为 true，防止误删生产数据。
+	Confirm bool
+	// Labels 为允许删除的标签，只能取 domain.EntityLabels 中的值，为空时表示全部 CMDB 标签。
+	Labels []string
+	// DropSchema 为 true 时同时删除 RequiredSchema 中的约束和索引。
+	DropSchema bool
+	// Schema 决定清理时匹配的实际标签，Labels 仍使用规范名，零值为默认 Schema。
+	Schema domain.Schema
+}
+
+// Reset 删除带 cmdb_key 的 CMDB 节点及其关系，可选删除 CMDB 自有的约束和索引，重复执行结果一致。
+func (m *SchemaManager) Reset(ctx context.Context, opts ResetOptions) error {
+	if opts.Schema.IsDefault() {
+		opts.Schema = m.Schema
+	}
+	statements, err := ResetStatements(opts)
+	if err != nil {
+		return err
+	}
+	for _, query := range statements {
 		if err := m.client.RunRaw(ctx, query, nil); err != nil {
-			return fmt.Errorf("执行 schema 语句失败: %w", err)
+			return fmt.Errorf("执行 reset 语句失败: %w", err)
 		}
 	}
 	return nil
 }
+
+// ResetStatements 生成 Reset 将执行的语句，便于在不连库的情况下检查范围。
+func ResetStatements(opts ResetOptions) ([]string, error) {
+	if !opts.Confirm {
+		return nil, ErrResetNotConfirmed
+	}
+	labels := opts.Labels
+	if len(labels) == 0 {
+		labels = domain.EntityLabels
+	}
+
+	statements := make([]string, 0, len(labels))
+	for _, label := range labels {
+		if !slices.Contains(domain.EntityLabels, label) {
+			return nil, fmt.Errorf("标签 %q 不属于 CMDB，拒绝清理", label)
+		}
+		statements = append(statements, fmt.Sprintf("MATCH (n:%s) WHERE n.cmdb_key IS NOT NULL DETACH DELETE n", opts.Schema.Label(label)))
+	}
+
+	if opts.DropSchema {
+		for _, obj := range RequiredSchema {
+			statements = append(statements, obj.DropStatement())
+		}
+	}
+	return statements, nil
+}
@@ -0,0 +1,93 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+
+	"cmdb2neo/internal/domain"
+)
+
+// metaProperties 为同步写入的元数据属性，读取图状态时剔除，只保留业务属性。
+var metaProperties = []string{
+	domain.PropCMDBKey,
+	domain.PropFirstSeenRunID,
+	domain.PropLastSeenRunID,
+	domain.PropUpdatedAt,
+	domain.PropActive,
+	domain.PropDeleted,
+	domain.PropDeletedAt,
+}
+
+// StateReader 读取图中现有的 CMDB 节点与关系，供对账比较。
+type StateReader struct {
+	client *Client
+	// Schema 决定读取时匹配的实际标签与关系类型，读到的名称会换算回规范名，零值为默认 Schema。
+	Schema domain.Schema
+}
+
+func NewStateReader(client *Client) *StateReader {
+	return &StateReader{client: client}
+}
+
+// Nodes 返回所有带 cmdb_key 且未被软删除的实体节点。
+func (r *StateReader) Nodes(ctx context.Context) ([]domain.NodeRow, error) {
+	query := `MATCH (n) WHERE n.cmdb_key IS NOT NULL AND any(l IN labels(n) WHERE l IN $labels) AND coalesce(n.deleted, false) = false
+RETURN n.cmdb_key AS key, labels(n) AS labels, properties(n) AS props`
+	records, err := r.client.RunRead(ctx, query, map[string]any{"labels": r.Schema.EntityLabels()})
+	if err != nil {
+		return nil, fmt.Errorf("读取图节点失败: %w", err)
+	}
+	rows := make([]domain.NodeRow, 0, len(records))
+	for _, rec := range records {
+		key, _ := rec["key"].(string)
+		props, _ := rec["props"].(map[string]any)
+		row := domain.NodeRow{CMDBKey: key, Labels: r.Schema.CanonicalLabels(toStrings(rec["labels"]))}
+		row.Properties, row.RunID = splitMeta(props)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Relationships 返回 CMDB 节点之间同步写入类型且未被软删除的关系。
+func (r *StateReader) Relationships(ctx context.Context) ([]domain.RelRow, error) {
+	query := `MATCH (a)-[r]->(b) WHERE type(r) IN $types AND a.cmdb_key IS NOT NULL AND b.cmdb_key IS NOT NULL AND coalesce(r.deleted, false) = false
+RETURN a.cmdb_key AS start_key, type(r) AS type, b.cmdb_key AS end_key, properties(r) AS props`
+	records, err := r.client.RunRead(ctx, query, map[string]any{"types": r.Schema.RelTypes()})
+	if err != nil {
+		return nil, fmt.Errorf("读取图关系失败: %w", err)
+	}
+	rows := make([]domain.RelRow, 0, len(records))
+	for _, rec := range records {
+		start, _ := rec["start_key"].(string)
+		end, _ := rec["end_key"].(string)
+		relType, _ := rec["type"].(string)
+		props, _ := rec["props"].(map[string]any)
+		row := domain.RelRow{StartKey: start, EndKey: end, Type: r.Schema.CanonicalRel(relType)}
+		row.Properties, row.RunID = splitMeta(props)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func splitMeta(props map[string]any) (map[string]any, string) {
+	runID, _ := props["last_seen_run_id"].(string)
+	out := make(map[string]any, len(props))
+	for k, v := range props {
+		out[k] = v
+	}
+	for _, k := range metaProperties {
+		delete(out, k)
+	}
+	return out, runID
+}
+
+func toStrings(v any) []string {
+	items, _ := v.([]any)
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import "time"
+
+// Recorder 收集同步与根因分析的运行指标，业务代码只依赖该接口，测试可注入 Nop 或自定义实现。
+type Recorder interface {
+	// ObserveCMDBFetch 记录一次 CMDB 快照拉取的耗时与结果。
+	ObserveCMDBFetch(d time.Duration, err error)
+	// ObserveUpserted 记录一次同步写入的节点与关系数，flow 为 init 或 sync。
+	ObserveUpserted(flow string, nodes, rels int)
+	// ObserveQuery 记录一次 Neo4j 查询的耗时与结果，mode 为 read 或 write。
+	ObserveQuery(mode string, d time.Duration, err error)
+	// ObserveAnalyze 记录一次根因分析的耗时、候选数与结果。
+	ObserveAnalyze(d time.Duration, candidates int, err error)
+	// ObserveSync 记录一次同步流程的耗时与结果，flow 为 init 或 sync。
+	ObserveSync(flow string, d time.Duration, err error)
+	// ObserveConnectivity 记录一次 Neo4j 连通性检查结果，client 为 graph 或 loader。
+	ObserveConnectivity(client string, err error)
+	// ObserveReconnect 记录一次 driver 重建的结果。
+	ObserveReconnect(client string, err error)
+	// SetSessionsInUse 记录客户端当前打开的会话数，驱动未暴露连接池统计，以会话数近似使用中的连接。
+	SetSessionsInUse(client string, n int)
+	// ObserveQueryCache 记录一次只读查询缓存的查找结果。
+	ObserveQueryCache(hit bool)
+	// ObserveChainCache 记录一次 RCA 拓扑链路缓存的查找结果。
+	ObserveChainCache(hit bool)
+	// SetOrphans 记录孤儿修复后某标签仍缺少上游关系的节点数。
+	SetOrphans(label string, n int)
+}
+
+// Nop 丢弃所有指标。
+type Nop struct{}
+
+func (Nop) ObserveCMDBFetch(time.Duration, error)     {}
+func (Nop) ObserveUpserted(string, int, int)          {}
+func (Nop) ObserveQuery(string, time.Duration, error) {}
+func (Nop) ObserveAnalyze(time.Duration, int, error)  {}
+func (Nop) ObserveSync(string, time.Duration, error)  {}
+func (Nop) ObserveConnectivity(string, error)         {}
+func (Nop) ObserveReconnect(string, error)            {}
+func (Nop) SetSessionsInUse(string, int)              {}
+func (Nop) ObserveQueryCache(bool)                    {}
+func (Nop) ObserveChainCache(bool)                    {}
+func (Nop) SetOrphans(string, int)                    {}
+
+// OrNop 在 r 为空时返回 Nop，便于可选注入。
+func OrNop(r Recorder) Recorder {
+	if r == nil {
+		return Nop{}
+	}
+	return r
+}
+
+// result 将错误转换为指标的 result 标签。
+func result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
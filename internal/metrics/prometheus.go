@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "cmdb2neo"
+
+// Prometheus 以 Prometheus 指标实现 Recorder，使用独立的 registry，通过 Handler 暴露。
+type Prometheus struct {
+	registry     *prometheus.Registry
+	cmdbFetch    *prometheus.HistogramVec
+	upserted     *prometheus.CounterVec
+	query        *prometheus.HistogramVec
+	analyze      *prometheus.HistogramVec
+	candidates   prometheus.Histogram
+	syncRuns     *prometheus.CounterVec
+	syncDuration *prometheus.HistogramVec
+	neo4jUp      *prometheus.GaugeVec
+	reconnects   *prometheus.CounterVec
+	sessions     *prometheus.GaugeVec
+	queryCache   *prometheus.CounterVec
+	chainCache   *prometheus.CounterVec
+	orphans      *prometheus.GaugeVec
+}
+
+// NewPrometheus 创建指标并注册到新的 registry，同时包含 Go 运行时与进程指标。
+func NewPrometheus() *Prometheus {
+	p := &Prometheus{
+		registry: prometheus.NewRegistry(),
+		cmdbFetch: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "cmdb_fetch_duration_seconds",
+			Help:      "CMDB snapshot fetch duration.",
+			Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+		}, []string{"result"}),
+		upserted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sync_upserted_total",
+			Help:      "Nodes and relationships written by sync runs.",
+		}, []string{"flow", "kind"}),
+		query: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "neo4j_query_duration_seconds",
+			Help:      "Neo4j query latency.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"mode", "result"}),
+		analyze: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rca_analyze_duration_seconds",
+			Help:      "RCA analyze duration.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"result"}),
+		candidates: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rca_candidates",
+			Help:      "Root cause candidates per analysis.",
+			Buckets:   []float64{0, 1, 2, 3, 5, 10, 20, 50},
+		}),
+		syncRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sync_runs_total",
+			Help:      "Sync runs by flow and result.",
+		}, []string{"flow", "result"}),
+		syncDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "sync_duration_seconds",
+			Help:      "Sync run duration.",
+			Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800},
+		}, []string{"flow"}),
+		neo4jUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "neo4j_up",
+			Help:      "Whether the last Neo4j connectivity check succeeded (1) or failed (0).",
+		}, []string{"client"}),
+		reconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "neo4j_reconnects_total",
+			Help:      "Neo4j driver reconnection attempts by client and result.",
+		}, []string{"client", "result"}),
+		sessions: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "neo4j_sessions_in_use",
+			Help:      "Neo4j sessions currently open by client.",
+		}, []string{"client"}),
+		queryCache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "neo4j_query_cache_lookups_total",
+			Help:      "Neo4j read query cache lookups by result (hit or miss).",
+		}, []string{"result"}),
+		chainCache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rca_chain_cache_lookups_total",
+			Help:      "RCA topology chain cache lookups by result (hit or miss).",
+		}, []string{"result"}),
+		orphans: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "graph_orphan_nodes",
+			Help:      "Live nodes still missing their parent relationship after the last orphan reconcile, by label.",
+		}, []string{"label"}),
+	}
+	p.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		p.cmdbFetch, p.upserted, p.query, p.analyze, p.candidates, p.syncRuns, p.syncDuration,
+		p.neo4jUp, p.reconnects, p.sessions, p.queryCache, p.chainCache, p.orphans,
+	)
+	return p
+}
+
+// Handler 返回 /metrics 的 HTTP 处理器。
+func (p *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{})
+}
+
+func (p *Prometheus) ObserveCMDBFetch(d time.Duration, err error) {
+	p.cmdbFetch.WithLabelValues(result(err)).Observe(d.Seconds())
+}
+
+func (p *Prometheus) ObserveUpserted(flow string, nodes, rels int) {
+	p.upserted.WithLabelValues(flow, "nodes").Add(float64(nodes))
+	p.upserted.WithLabelValues(flow, "rels").Add(float64(rels))
+}
+
+func (p *Prometheus) ObserveQuery(mode string, d time.Duration, err error) {
+	p.query.WithLabelValues(mode, result(err)).Observe(d.Seconds())
+}
+
+func (p *Prometheus) ObserveAnalyze(d time.Duration, candidates int, err error) {
+	p.analyze.WithLabelValues(result(err)).Observe(d.Seconds())
+	if err == nil {
+		p.candidates.Observe(float64(candidates))
+	}
+}
+
+func (p *Prometheus) ObserveSync(flow string, d time.Duration, err error) {
+	p.syncRuns.WithLabelValues(flow, result(err)).Inc()
+	p.syncDuration.WithLabelValues(flow).Observe(d.Seconds())
+}
+
+func (p *Prometheus) ObserveConnectivity(client string, err error) {
+	up := 1.0
+	if err != nil {
+		up = 0
+	}
+	p.neo4jUp.WithLabelValues(client).Set(up)
+}
+
+func (p *Prometheus) ObserveReconnect(client string, err error) {
+	p.reconnects.WithLabelValues(client, result(err)).Inc()
+}
+
+func (p *Prometheus) SetSessionsInUse(client string, n int) {
+	p.sessions.WithLabelValues(client).Set(float64(n))
+}
+
+func (p *Prometheus) ObserveQueryCache(hit bool) {
+	if hit {
+		p.queryCache.WithLabelValues("hit").Inc()
+		return
+	}
+	p.queryCache.WithLabelValues("miss").Inc()
+}
+
+func (p *Prometheus) ObserveChainCache(hit bool) {
+	if hit {
+		p.chainCache.WithLabelValues("hit").Inc()
+		return
+	}
+	p.chainCache.WithLabelValues("miss").Inc()
+}
+
+func (p *Prometheus) SetOrphans(label string, n int) {
+	p.orphans.WithLabelValues(label).Set(float64(n))
+}
@@ -0,0 +1,120 @@
+package rca
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// AlarmSource 提供存储在图中的告警事件。
+type AlarmSource interface {
+	RecentAlarms(ctx context.Context, since time.Time) ([]AlarmEvent, error)
+}
+
+// RecentAlarms 读取 occurred_at 不早于 since 的 :Alarm 节点，按发生时间升序返回。
+// occurred_at 可以是 datetime、localdatetime（按 UTC）、ISO 8601 字符串（无时区时按 UTC）或毫秒时间戳。
+// 查询中只按各类型做不会报错的比较，字符串按日期前缀或纯数字粗筛，精确过滤与排序在 timeValue 解析后完成，
+// 单条格式错误的告警只会被跳过，不会导致整个查询失败。
+func (p *GraphProvider) RecentAlarms(ctx context.Context, since time.Time) ([]AlarmEvent, error) {
+	query := `
+MATCH (a:Alarm)
+WITH a, a.occurred_at AS raw
+WHERE (raw IS :: ZONED DATETIME AND raw >= $since)
+   OR (raw IS :: LOCAL DATETIME AND raw >= $since_local)
+   OR (raw IS :: INTEGER AND raw >= $since_millis)
+   OR (raw IS :: STRING AND (raw >= $since_day OR raw =~ '[0-9]+'))
+RETURN a AS alarm
+`
+	since = since.UTC()
+	params := map[string]any{
+		"since":        since,
+		"since_local":  neo4j.LocalDateTime(since),
+		"since_millis": since.UnixMilli(),
+		// 字符串可能带任意时区偏移，按提前一天的日期前缀粗筛
+		"since_day": since.AddDate(0, 0, -1).Format("2006-01-02"),
+	}
+	records, err := p.client.RunRead(ctx, query, params)
+	if err != nil {
+		return nil, fmt.Errorf("query recent alarms failed: %w", err)
+	}
+	events := make([]AlarmEvent, 0, len(records))
+	for _, record := range records {
+		node, ok := record["alarm"].(neo4j.Node)
+		if !ok {
+			return nil, fmt.Errorf("field alarm is not neo4j node")
+		}
+		evt := alarmFromProps(node.Props)
+		if evt.OccurredAt.IsZero() || evt.OccurredAt.Before(since) {
+			continue
+		}
+		events = append(events, evt)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].OccurredAt.Before(events[j].OccurredAt) })
+	return events, nil
+}
+
+func alarmFromProps(props map[string]any) AlarmEvent {
+	evt := AlarmEvent{
+		AppName:          firstNonEmpty(props["app_name"]),
+		Datacenter:       firstNonEmpty(props["datacenter"]),
+		HostIP:           firstNonEmpty(props["host_ip"]),
+		IP:               firstNonEmpty(props["ip"]),
+		Hostname:         firstNonEmpty(props["hostname"]),
+		CMDBKey:          firstNonEmpty(props["cmdb_key"]),
+		NetworkPartition: firstNonEmpty(props["network_partition"]),
+		RuleName:         firstNonEmpty(props["rule_name"]),
+		OccurredAt:       timeValue(props["occurred_at"]),
+		Count:            intValue(props["count"]),
+	}
+	switch v := props["server_type"].(type) {
+	case string:
+		evt.ServerType = ServerType(v)
+	case int64:
+		evt.ServerType = ServerType(strconv.FormatInt(v, 10))
+	}
+	if healthy, ok := props["healthy"].(bool); ok {
+		evt.Healthy = healthy
+	}
+	return evt
+}
+
+// isoLayouts 为字符串时间支持的格式，不带时区的格式按 UTC 解析。
+var isoLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// timeValue 解析 occurred_at，兼容 datetime、localdatetime（按 UTC）、ISO 8601 字符串与毫秒时间戳，无法识别时返回零值。
+func timeValue(raw any) time.Time {
+	switch v := raw.(type) {
+	case time.Time:
+		return v
+	case neo4j.LocalDateTime:
+		t := v.Time()
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	case string:
+		v = strings.TrimSpace(v)
+		for _, layout := range isoLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t
+			}
+		}
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.UnixMilli(ms)
+		}
+	case int64:
+		return time.UnixMilli(v)
+	case int:
+		return time.UnixMilli(int64(v))
+	case float64:
+		return time.UnixMilli(int64(v))
+	}
+	return time.Time{}
+}
@@ -2,71 +2,307 @@ package rca
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
+	"time"
+
+	"cmdb2neo/internal/graph"
+	"cmdb2neo/internal/metrics"
+	"cmdb2neo/pkg/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
 type Analyzer struct {
 	provider TopologyProvider
 	config   Config
+	store    ResultStore
+	readOnly bool
+	prompt   PromptOptions
+	recorder metrics.Recorder
+	tracer   trace.Tracer
+	// logger 在上下文中没有请求级日志器时使用，为 nil 时不输出
+	logger *zap.Logger
 }
 
-func NewAnalyzer(provider TopologyProvider, cfg Config) (*Analyzer, error) {
+// ResultStore 持久化分析结果，按窗口 ID 归档。
+type ResultStore interface {
+	Save(ctx context.Context, windowID string, result Result) error
+}
+
+// AnalyzerOption 用于定制 Analyzer。
+type AnalyzerOption func(*Analyzer)
+
+// WithResultStore 配置结果存储，分析完成后自动保存。
+func WithResultStore(store ResultStore) AnalyzerOption {
+	return func(a *Analyzer) {
+		a.store = store
+	}
+}
+
+// WithReadOnly 使分析器永不写入存储，适用于推演、回放等场景。
+func WithReadOnly() AnalyzerOption {
+	return func(a *Analyzer) {
+		a.readOnly = true
+	}
+}
+
+// WithPromptOptions 配置提示词渲染选项，其中 Language 同时决定候选解释的语言。
+func WithPromptOptions(opts PromptOptions) AnalyzerOption {
+	return func(a *Analyzer) {
+		a.prompt = opts
+	}
+}
+
+// WithRecorder 注入分析耗时与候选数的指标记录器。
+func WithRecorder(recorder metrics.Recorder) AnalyzerOption {
+	return func(a *Analyzer) {
+		a.recorder = recorder
+	}
+}
+
+// WithTracerProvider 为每次分析开启 span，解析链路的查询作为其子 span。
+func WithTracerProvider(provider trace.TracerProvider) AnalyzerOption {
+	return func(a *Analyzer) {
+		a.tracer = graph.Tracer(provider)
+	}
+}
+
+// WithLogger 指定分析日志的默认日志器；上下文中带有请求级日志器（见 logging.WithLogger）时优先使用后者。
+func WithLogger(logger *zap.Logger) AnalyzerOption {
+	return func(a *Analyzer) {
+		a.logger = logger
+	}
+}
+
+// AnalyzeOptions 控制单次分析的行为。
+type AnalyzeOptions struct {
+	// WindowID 为结果归档使用的窗口标识，为空时不保存。
+	WindowID string
+	// ReadOnly 为 true 时本次分析跳过存储。
+	ReadOnly bool
+	// CaptureTopology 为 true 时在结果中导出解析过程经过的子图。
+	CaptureTopology bool
+	// Config 不为空时仅本次分析使用该配置，不影响分析器默认配置。
+	Config *Config
+}
+
+func NewAnalyzer(provider TopologyProvider, cfg Config, opts ...AnalyzerOption) (*Analyzer, error) {
 	if provider == nil {
 		return nil, fmt.Errorf("topology provider is required")
 	}
 	if len(cfg.Hierarchy) == 0 {
 		cfg = DefaultConfig()
 	}
-	return &Analyzer{provider: provider, config: cfg}, nil
+	a := &Analyzer{provider: provider, config: cfg, prompt: DefaultPromptOptions()}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(a)
+		}
+	}
+	a.recorder = metrics.OrNop(a.recorder)
+	if a.tracer == nil {
+		a.tracer = graph.Tracer(nil)
+	}
+	return a, nil
+}
+
+// Config 返回分析器默认配置的副本，可安全修改后用于单次分析。
+func (a *Analyzer) Config() Config {
+	return a.config.Clone()
 }
 
 func (a *Analyzer) Analyze(ctx context.Context, events []AlarmEvent) (Result, error) {
+	return a.AnalyzeWithOptions(ctx, events, AnalyzeOptions{})
+}
+
+// AnalyzeWithOptions 按给定选项执行一次分析。
+func (a *Analyzer) AnalyzeWithOptions(ctx context.Context, events []AlarmEvent, opts AnalyzeOptions) (Result, error) {
+	ctx, span := a.tracer.Start(ctx, "rca.analyze", trace.WithAttributes(
+		attribute.Int("rca.event_count", len(events)),
+		attribute.String("rca.window_id", opts.WindowID),
+	))
+	start := time.Now()
+	res, err := a.analyze(ctx, events, opts)
+	elapsed := time.Since(start)
+	a.recorder.ObserveAnalyze(elapsed, len(res.Candidates), err)
+	a.logResult(ctx, opts.WindowID, len(events), res, elapsed, err)
+	span.SetAttributes(
+		attribute.Int("rca.candidate_count", len(res.Candidates)),
+		attribute.Int("rca.unresolved_count", len(res.ResolutionErrors)),
+	)
+	graph.EndSpan(span, err)
+	return res, err
+}
+
+// logResult 用请求级日志器记录一次分析的结果：失败记为 warn，有事件无法解析时记为 info，其余只在 debug 级别输出。
+func (a *Analyzer) logResult(ctx context.Context, windowID string, events int, res Result, elapsed time.Duration, err error) {
+	logger := logging.FromContext(ctx, a.logger).With(zap.String("window_id", windowID), zap.Int("events", events))
+	switch {
+	case err != nil:
+		logger.Warn("rca analyze failed", zap.Duration("elapsed", elapsed), zap.Error(err))
+	case len(res.ResolutionErrors) > 0:
+		logger.Info("rca analyze finished with unresolved events",
+			zap.Int("candidates", len(res.Candidates)),
+			zap.Int("unresolved", len(res.ResolutionErrors)),
+			zap.String("first_error", res.ResolutionErrors[0].Error),
+			zap.Duration("elapsed", elapsed))
+	default:
+		logger.Debug("rca analyze finished", zap.Int("candidates", len(res.Candidates)), zap.Duration("elapsed", elapsed))
+	}
+}
+
+func (a *Analyzer) analyze(ctx context.Context, events []AlarmEvent, opts AnalyzeOptions) (Result, error) {
 	if len(events) == 0 {
 		return Result{}, fmt.Errorf("empty alarms")
 	}
+	inputCount := len(events)
+	// 规范化 IP 后，同一地址的不同写法在风暴过滤、合并与拓扑解析中视为同一台机器
+	events = normalizeEventIPs(events)
+	windowStart, windowEnd := windowBounds(events)
+	cfg := a.config
+	if opts.Config != nil {
+		cfg = *opts.Config
+	}
+	events, signals := splitHealthy(events)
+	events = FilterStorms(events, cfg.StormFilter)
+	events = CoalesceEventsBy(events, cfg.CoalesceWindow, cfg.CoalesceKeys)
+	healthy := a.resolveHealthy(ctx, signals)
 
-	appOutages := a.computeAppOutages(ctx, events)
+	appOutages := a.computeAppOutages(ctx, cfg, events)
 
 	topoIndex := make(map[string]*TopoNode)
-	records := make([]*eventRecord, 0, len(events))
-	for _, evt := range events {
-		resolved, err := a.provider.ResolveEvent(ctx, evt)
+	var recorder *subgraphRecorder
+	if opts.CaptureTopology {
+		recorder = newSubgraphRecorder()
+	}
+	chains, failed, err := a.resolveEvents(ctx, events, cfg.FailFast)
+	if err != nil {
+		return Result{}, err
+	}
+	var resolutionErrors []EventError
+	var unexplained []UnexplainedEvent
+	var totalWeight float64
+	above := cfg.layersAboveStop()
+	for i, evt := range events {
+		if resolveErr, ok := failed[i]; ok {
+			eventID := buildEventID(evt)
+			resolutionErrors = append(resolutionErrors, EventError{EventID: eventID, Event: evt, Error: resolveErr.Error()})
+			unexplained = append(unexplained, UnexplainedEvent{EventID: eventID, Event: evt, Reason: UnexplainedUnresolved})
+			continue
+		}
+		recorder.record(chains[i])
+		resolved, err := orderChain(chains[i], cfg.Hierarchy)
 		if err != nil {
-			return Result{}, fmt.Errorf("resolve topology for %s/%s failed: %w", evt.AppName, evt.IP, err)
+			return Result{}, fmt.Errorf("event %s: %w", buildEventID(evt), err)
 		}
 		rec := &eventRecord{event: evt, eventID: buildEventID(evt)}
-		records = append(records, rec)
+		weight := cfg.priorityWeight(evt.Priority)
+		totalWeight += weight
 
-		var child *TopoNode
+		var child, app *TopoNode
 		for _, node := range resolved {
+			if _, ok := above[node.NodeRef.Type]; ok {
+				break
+			}
 			topo := ensureTopoNode(topoIndex, node)
-			nodeRef := AlarmEventRef{ID: rec.eventID, RuleName: evt.RuleName, NodeType: node.NodeRef.Type, Occurred: evt.OccurredAt}
+			nodeRef := AlarmEventRef{ID: rec.eventID, RuleName: evt.RuleName, NodeType: node.NodeRef.Type, Occurred: evt.OccurredAt, Suppressed: evt.Suppressed, Weight: weight}
 			topo.AddEvent(rec.eventID, nodeRef)
+			if node.NodeRef.Type == NodeTypeService {
+				// 服务聚合的是应用而不是链路上的下一层，应用仍保留其物理父节点
+				if app != nil {
+					topo.attachMember(app)
+					topo.AddImpact(app, AlarmEventRef{ID: rec.eventID, RuleName: evt.RuleName, NodeType: NodeTypeApp, Occurred: evt.OccurredAt, Suppressed: evt.Suppressed, Weight: weight})
+				}
+				continue
+			}
+			if node.NodeRef.Type == NodeTypeApp {
+				app = topo
+			}
 			if child != nil {
 				topo.AttachChild(child)
-				impactRef := AlarmEventRef{ID: rec.eventID, RuleName: evt.RuleName, NodeType: child.NodeRef.Type, Occurred: evt.OccurredAt}
+				impactRef := AlarmEventRef{ID: rec.eventID, RuleName: evt.RuleName, NodeType: child.NodeRef.Type, Occurred: evt.OccurredAt, Suppressed: evt.Suppressed, Weight: weight}
 				topo.AddImpact(child, impactRef)
 			}
 			child = topo
 		}
 	}
 
-	candidates, paths, err := a.evaluate(topoIndex)
+	candidates, paths, err := a.evaluate(cfg, topoIndex, totalWeight)
 	if err != nil {
 		return Result{}, err
 	}
+	candidates = demoteHealthy(candidates, healthy, cfg.HealthyPenalty)
+	if cfg.CalibrateConfidence {
+		// 在健康降权之后校准，保证最终输出的置信度之和为 1
+		candidates = calibrateConfidence(candidates)
+	}
 
 	res := Result{
-		AppOutages: appOutages,
-		Candidates: candidates,
-		Paths:      paths,
+		EventCount:  inputCount,
+		WindowStart: windowStart,
+		WindowEnd:   windowEnd,
+		AppOutages:  appOutages,
+		Candidates:  candidates,
+		Paths:       paths,
+		RootCauses:  reconcileStages(events, appOutages, candidates, cfg.Hierarchy, cfg.StageWeights),
+
+		ResolutionErrors:  resolutionErrors,
+		UnexplainedEvents: unexplained,
+	}
+	recorder.apply(&res)
+	res.Prompt = RenderPrompt(res, a.prompt)
+
+	if a.shouldPersist(opts) {
+		if err := a.store.Save(ctx, opts.WindowID, res); err != nil {
+			return Result{}, fmt.Errorf("persist result for window %s failed: %w", opts.WindowID, err)
+		}
 	}
-	res.Prompt = RenderPrompt(res, DefaultPromptOptions())
 	return res, nil
 }
 
+// resolveEvents 解析每条事件的拓扑链路，provider 支持批量时只按层级各发一次查询。
+// 非 failFast 模式下无法解析的事件按下标返回在 failed 中，其余事件照常解析；
+// 批量查询整体失败或上下文已取消时仍返回错误。
+func (a *Analyzer) resolveEvents(ctx context.Context, events []AlarmEvent, failFast bool) ([][]Node, EventErrors, error) {
+	failed := make(EventErrors)
+	if batch, ok := a.provider.(BatchTopologyProvider); ok {
+		chains, err := batch.ResolveEvents(ctx, events)
+		var eventErrs EventErrors
+		switch {
+		case err == nil:
+		case !failFast && errors.As(err, &eventErrs):
+			failed = eventErrs
+		default:
+			return nil, nil, fmt.Errorf("resolve topology failed: %w", err)
+		}
+		if len(chains) != len(events) {
+			return nil, nil, fmt.Errorf("resolve topology returned %d chains for %d events", len(chains), len(events))
+		}
+		return chains, failed, nil
+	}
+	chains := make([][]Node, 0, len(events))
+	for i, evt := range events {
+		resolved, err := a.provider.ResolveEvent(ctx, evt)
+		if err != nil {
+			// 查询超时说明图库已不堪重负，继续逐条解析只会叠加等待，直接中止
+			if failFast || ctx.Err() != nil || errors.Is(err, graph.ErrQueryTimeout) {
+				return nil, nil, fmt.Errorf("resolve topology for %s/%s failed: %w", evt.AppName, evt.IP, err)
+			}
+			failed[i] = err
+		}
+		chains = append(chains, resolved)
+	}
+	return chains, failed, nil
+}
+
+func (a *Analyzer) shouldPersist(opts AnalyzeOptions) bool {
+	return a.store != nil && !a.readOnly && !opts.ReadOnly && opts.WindowID != ""
+}
+
 // Stage A -------------------------------------------------
 
 type appGroup struct {
@@ -75,8 +311,8 @@ type appGroup struct {
 	Events  []AlarmEvent
 }
 
-func (a *Analyzer) computeAppOutages(ctx context.Context, events []AlarmEvent) []AppOutage {
-	threshold := a.config.AppOutageThreshold
+func (a *Analyzer) computeAppOutages(ctx context.Context, cfg Config, events []AlarmEvent) []AppOutage {
+	threshold := cfg.AppOutageThreshold
 	if threshold <= 0 {
 		threshold = 0.6
 	}
@@ -221,8 +457,56 @@ type eventRecord struct {
 	eventID string
 }
 
+// orderChain 按 hierarchy 自底向上排列解析出的链路，链路中出现层级之外的节点类型时报错；
+// 可选的 Container、Service 层未配置时直接丢弃，应用直接挂到容器所在的虚拟机或宿主机。
+func orderChain(chain []Node, hierarchy []NodeType) ([]Node, error) {
+	rank := make(map[NodeType]int, len(hierarchy))
+	for i, t := range hierarchy {
+		rank[t] = i
+	}
+	ordered := make([]Node, 0, len(chain))
+	for _, node := range chain {
+		if _, ok := rank[node.NodeRef.Type]; !ok {
+			if optionalNodeTypes[node.NodeRef.Type] {
+				continue
+			}
+			return nil, fmt.Errorf("node %s has type %q which is not in hierarchy %v", node.NodeRef.Key, node.NodeRef.Type, hierarchy)
+		}
+		ordered = append(ordered, node)
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return rank[ordered[i].NodeRef.Type] < rank[ordered[j].NodeRef.Type] })
+	return ordered, nil
+}
+
+// windowBounds 返回告警的最早、最晚发生时间，已合并事件的 LastOccurredAt 计入窗口结束，零值时间被忽略。
+func windowBounds(events []AlarmEvent) (start, end time.Time) {
+	for _, evt := range events {
+		for _, ts := range []time.Time{evt.OccurredAt, evt.LastOccurredAt} {
+			if ts.IsZero() {
+				continue
+			}
+			if start.IsZero() || ts.Before(start) {
+				start = ts
+			}
+			if ts.After(end) {
+				end = ts
+			}
+		}
+	}
+	return start, end
+}
+
+// windowLabel 将分析窗口格式化为 "start ~ end"，窗口未知时返回空串。
+func windowLabel(result Result) string {
+	if result.WindowStart.IsZero() {
+		return ""
+	}
+	return result.WindowStart.UTC().Format(time.RFC3339) + " ~ " + result.WindowEnd.UTC().Format(time.RFC3339)
+}
+
+// buildEventID 生成事件标识，没有 IP 的事件用主机名或 cmdb_key 代替，避免不同机器的告警互相覆盖。
 func buildEventID(evt AlarmEvent) string {
-	return fmt.Sprintf("%s|%s|%s|%s|%s", evt.AppName, evt.ServerType, evt.Datacenter, evt.IP, evt.RuleName)
+	return fmt.Sprintf("%s|%s|%s|%s|%s", evt.AppName, evt.ServerType, evt.Datacenter, firstNonEmpty(evt.IP, evt.Hostname, evt.CMDBKey), evt.RuleName)
 }
 
 func ensureTopoNode(index map[string]*TopoNode, node Node) *TopoNode {
@@ -237,6 +521,18 @@ func ensureTopoNode(index map[string]*TopoNode, node Node) *TopoNode {
 			}
 			existing.ChildCounts[k] = v
 		}
+		for k, v := range node.ChildWeights {
+			if v <= 0 {
+				continue
+			}
+			if existing.ChildWeights == nil {
+				existing.ChildWeights = make(map[NodeType]float64)
+			}
+			existing.ChildWeights[k] = v
+		}
+		if node.LinkWeight > 0 {
+			existing.LinkWeight = node.LinkWeight
+		}
 		return existing
 	}
 	topo := NewTopoNode(node)
@@ -247,7 +543,7 @@ func ensureTopoNode(index map[string]*TopoNode, node Node) *TopoNode {
 	return topo
 }
 
-func (a *Analyzer) evaluate(nodes map[string]*TopoNode) ([]Candidate, []AlarmPath, error) {
+func (a *Analyzer) evaluate(cfg Config, nodes map[string]*TopoNode, totalWeight float64) ([]Candidate, []AlarmPath, error) {
 
 	// 只保留最上层的节点
 	for _, v := range nodes {
@@ -259,52 +555,106 @@ func (a *Analyzer) evaluate(nodes map[string]*TopoNode) ([]Candidate, []AlarmPat
 	candidates := make([]Candidate, 0)
 	paths := make([]AlarmPath, 0)
 	for _, root := range nodes {
-		a.postOrderEvaluate(root, &candidates, &paths)
+		a.postOrderEvaluate(cfg, root, totalWeight, &candidates, &paths)
 	}
 
+	candidates = dedupCandidates(candidates)
+	paths = MergePaths(paths)
+
 	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Confidence > candidates[j].Confidence })
-	sort.Slice(paths, func(i, j int) bool { return paths[i].Candidate.Key < paths[j].Candidate.Key })
+	SortPaths(paths, cfg.PathSort)
 	return candidates, paths, nil
 }
 
+// dedupCandidates 按节点 key 合并候选，同一节点经多条链路到达时只保留一条：
+// 解释事件取并集，置信度及指标取最高的那一次。
+func dedupCandidates(candidates []Candidate) []Candidate {
+	if len(candidates) < 2 {
+		return candidates
+	}
+	index := make(map[string]int, len(candidates))
+	merged := make([]Candidate, 0, len(candidates))
+	for _, cand := range candidates {
+		pos, ok := index[cand.Node.Key]
+		if !ok {
+			index[cand.Node.Key] = len(merged)
+			merged = append(merged, cand)
+			continue
+		}
+		existing := &merged[pos]
+		explained := mergeSortedIDs(existing.Explained, cand.Explained)
+		if cand.Confidence > existing.Confidence {
+			*existing = cand
+		}
+		existing.Explained = explained
+	}
+	return merged
+}
+
+func mergeSortedIDs(a, b []string) []string {
+	set := make(map[string]struct{}, len(a)+len(b))
+	for _, id := range a {
+		set[id] = struct{}{}
+	}
+	for _, id := range b {
+		set[id] = struct{}{}
+	}
+	return sortedStrings(set)
+}
+
 // postOrderEvaluate 后序遍历，从叶子节点开始处理
-func (a *Analyzer) postOrderEvaluate(node *TopoNode, candidates *[]Candidate, paths *[]AlarmPath) {
+func (a *Analyzer) postOrderEvaluate(cfg Config, node *TopoNode, totalWeight float64, candidates *[]Candidate, paths *[]AlarmPath) {
 	if node == nil {
 		return
 	}
 
 	for _, child := range node.Children {
-		a.postOrderEvaluate(child, candidates, paths)
+		a.postOrderEvaluate(cfg, child, totalWeight, candidates, paths)
 	}
 
-	layerCfg, ok := a.config.Layers[node.NodeRef.Type]
+	layerCfg, ok := cfg.Layers[node.NodeRef.Type]
 	if !ok {
 		layerCfg = LayerConfig{CoverageThreshold: 0.6, MinChildren: 1, Weights: ScoreWeights{Coverage: 0.7}}
 	}
 
+	node.weighted = cfg.WeightedCoverage
 	coverage := node.Coverage()
 
-	if coverage > layerCfg.CoverageThreshold {
-		// 满足条件，标记为候选根因
-		score := node.ComputeScore(layerCfg.Weights)
-		eventIds := collectEventIDs(node.Events)
-
-		candidate := Candidate{
-			Node:       node.NodeRef,
-			Confidence: score.Normalized,
-			Coverage:   coverage,
-			Reason:     "TREE_POSTORDER",
-			Metrics:    score,
-			Explained:  eventIds,
+	reason := "TREE_POSTORDER"
+	switch {
+	case node.HasBaseline():
+		if coverage <= layerCfg.CoverageThreshold {
+			return
 		}
-
-		*candidates = append(*candidates, candidate)
-		*paths = append(*paths, buildPath(node))
+	case len(node.Impacts) == 0:
+		// 叶子节点只有自身告警，覆盖率无从谈起，按 0 覆盖率打分作为低置信度候选
+		reason += "+UNKNOWN_BASELINE"
+	default:
+		// 有告警子节点但缺少基线，无法判断覆盖率，不作为候选
 		return
-	} else {
+	}
+
+	score := node.ComputeScore(layerCfg.Weights, totalWeight)
+	if !node.HasBaseline() && score.Normalized <= 0 {
+		// 叶子节点得分为 0 时没有任何信号，不输出
 		return
 	}
+	eventIds := collectEventIDs(node.Events)
+
+	candidate := Candidate{
+		Node:       node.NodeRef,
+		Confidence: score.Normalized,
+		Coverage:   coverage,
+		Reason:     reason,
+		Metrics:    score,
+		Explained:  eventIds,
+	}
+	if cfg.Explain {
+		candidate.Explanation = explainCandidate(node, a.prompt.Language)
+	}
 
+	*candidates = append(*candidates, candidate)
+	*paths = append(*paths, buildPath(node))
 }
 
 func buildPath(node *TopoNode) AlarmPath {
@@ -339,12 +689,7 @@ func collectImpacts(node *TopoNode) []PathImpact {
 		for _, evt := range impact.Events {
 			events = append(events, evt)
 		}
-		sort.Slice(events, func(i, j int) bool {
-			if events[i].Occurred.Equal(events[j].Occurred) {
-				return events[i].ID < events[j].ID
-			}
-			return events[i].Occurred.Before(events[j].Occurred)
-		})
+		sortEventRefs(events)
 
 		var childImpacts []PathImpact
 		if child, ok := node.Children[key]; ok && child != nil {
@@ -0,0 +1,114 @@
+package rca
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// 拓扑缓存默认容量与有效期。
+const (
+	defaultCacheSize = 1024
+	defaultCacheTTL  = time.Minute
+)
+
+// CacheConfig 控制拓扑链路缓存，Size<=0 或 TTL<=0 时取默认值。
+type CacheConfig struct {
+	Size int
+	TTL  time.Duration
+}
+
+// CacheStats 为缓存命中统计。
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	Size   int   `json:"size"`
+}
+
+type cacheEntry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+// lruCache 是带 TTL 的 LRU 缓存，并发安全。
+type lruCache[V any] struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+	order   *list.List
+	entries map[string]*list.Element
+	hits    int64
+	misses  int64
+}
+
+// chainCache 按起始层级与事件定位参数缓存解析出的链路。
+type chainCache = lruCache[Chain]
+
+func newChainCache(cfg CacheConfig) *chainCache {
+	return newLRUCache[Chain](cfg)
+}
+
+func newLRUCache[V any](cfg CacheConfig) *lruCache[V] {
+	if cfg.Size <= 0 {
+		cfg.Size = defaultCacheSize
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultCacheTTL
+	}
+	return &lruCache[V]{
+		size:    cfg.Size,
+		ttl:     cfg.TTL,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// cacheKey 由起始层级及解析查询用到的全部参数组成，参数不同的事件不会共用结果。
+func cacheKey(from NodeType, event AlarmEvent) string {
+	return string(from) + "|" + event.CMDBKey + "|" + event.IP + "|" + event.Hostname + "|" + event.AppName + "|" + event.Datacenter
+}
+
+func (c *lruCache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry[V])
+		if c.now().Before(entry.expires) {
+			c.order.MoveToFront(elem)
+			c.hits++
+			return entry.value, true
+		}
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+	c.misses++
+	var zero V
+	return zero, false
+}
+
+func (c *lruCache[V]) put(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.ttl)
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry[V])
+		entry.value, entry.expires = value, expires
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry[V]{key: key, value: value, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry[V]).key)
+	}
+}
+
+func (c *lruCache[V]) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Size: c.order.Len()}
+}
@@ -0,0 +1,18 @@
+package rca
+
+// calibrateConfidence 将同一窗口内候选的置信度按比例归一化为和为 1，便于看出首个候选的领先程度。
+// 校准前的置信度保留在 Metrics.Normalized，RawScore 不变；候选总分为 0 时不做处理。
+func calibrateConfidence(candidates []Candidate) []Candidate {
+	total := 0.0
+	for _, cand := range candidates {
+		total += cand.Confidence
+	}
+	if total <= 0 {
+		return candidates
+	}
+	for i := range candidates {
+		candidates[i].Confidence /= total
+		candidates[i].Metrics.Calibrated = candidates[i].Confidence
+	}
+	return candidates
+}
@@ -0,0 +1,91 @@
+package rca
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// coalesceFields 为可用于合并判定的告警字段。
+var coalesceFields = map[string]func(AlarmEvent) string{
+	"app_name":          func(e AlarmEvent) string { return e.AppName },
+	"server_type":       func(e AlarmEvent) string { return string(e.ServerType) },
+	"datacenter":        func(e AlarmEvent) string { return e.Datacenter },
+	"ip":                func(e AlarmEvent) string { return e.IP },
+	"host_ip":           func(e AlarmEvent) string { return e.HostIP },
+	"network_partition": func(e AlarmEvent) string { return e.NetworkPartition },
+	"rule_name":         func(e AlarmEvent) string { return e.RuleName },
+}
+
+func validateCoalesceKeys(keys []string) error {
+	for _, key := range keys {
+		if _, ok := coalesceFields[key]; !ok {
+			return fmt.Errorf("unknown coalesce key %q", key)
+		}
+	}
+	return nil
+}
+
+// coalesceKey 按 keys 拼接合并键，keys 为空时使用事件 ID。
+func coalesceKey(evt AlarmEvent, keys []string) string {
+	if len(keys) == 0 {
+		return buildEventID(evt)
+	}
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		if field, ok := coalesceFields[key]; ok {
+			parts[i] = field(evt)
+		}
+	}
+	return strings.Join(parts, "|")
+}
+
+// CoalesceEvents 将同一告警（同一事件 ID）在 window 内的重复上报合并为一条逻辑事件，
+// 保留首次发生时间，并记录发生次数与最后一次发生时间。window <= 0 时原样返回。
+func CoalesceEvents(events []AlarmEvent, window time.Duration) []AlarmEvent {
+	return CoalesceEventsBy(events, window, nil)
+}
+
+// CoalesceEventsBy 与 CoalesceEvents 相同，但按 keys 指定的字段判定重复，合并后保留最早一条作为代表事件。
+func CoalesceEventsBy(events []AlarmEvent, window time.Duration, keys []string) []AlarmEvent {
+	if window <= 0 || len(events) < 2 {
+		return events
+	}
+
+	ordered := append([]AlarmEvent(nil), events...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].OccurredAt.Before(ordered[j].OccurredAt) })
+
+	result := make([]AlarmEvent, 0, len(ordered))
+	open := make(map[string]int)
+	for _, evt := range ordered {
+		key := coalesceKey(evt, keys)
+		if pos, ok := open[key]; ok && evt.OccurredAt.Sub(result[pos].OccurredAt) <= window {
+			merged := &result[pos]
+			merged.Count = occurrences(*merged) + occurrences(evt)
+			merged.Suppressed += evt.Suppressed
+			last := evt.LastOccurredAt
+			if last.IsZero() {
+				last = evt.OccurredAt
+			}
+			if last.After(merged.LastOccurredAt) {
+				merged.LastOccurredAt = last
+			}
+			continue
+		}
+		evt.Count = occurrences(evt)
+		if evt.LastOccurredAt.IsZero() {
+			evt.LastOccurredAt = evt.OccurredAt
+		}
+		open[key] = len(result)
+		result = append(result, evt)
+	}
+	return result
+}
+
+func occurrences(evt AlarmEvent) int {
+	if evt.Count <= 0 {
+		return 1
+	}
+	return evt.Count
+}
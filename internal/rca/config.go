@@ -1,8 +1,16 @@
 package rca
 
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
 // ScoreWeights 控制各指标权重。
 type ScoreWeights struct {
 	Coverage float64 `json:"coverage"`
+	// TimeLead 奖励自身告警早于下游告警的节点，根因的症状通常先于被它影响的子节点出现。
+	TimeLead float64 `json:"time_lead"`
 	Impact   float64 `json:"impact"`
 	Base     float64 `json:"base"`
 }
@@ -14,6 +22,12 @@ type LayerConfig struct {
 	Weights           ScoreWeights `json:"weights"`
 }
 
+// StageWeights 控制 Stage A（应用整体故障）与 Stage B（拓扑候选）在统一排序中的权重。
+type StageWeights struct {
+	AppOutage float64 `json:"app_outage"`
+	Topology  float64 `json:"topology"`
+}
+
 // Config 根因分析配置。
 type Config struct {
 	Hierarchy          []NodeType               `json:"hierarchy"`
@@ -21,25 +35,53 @@ type Config struct {
 	Datacenters        []string                 `json:"datacenters"`
 	AppOutageThreshold float64                  `json:"app_outage_threshold"`
 	RequireFullMatch   bool                     `json:"require_full_match"`
+	// CoalesceWindow 大于 0 时，分析前将该时间窗内重复的告警合并为一条。
+	CoalesceWindow time.Duration `json:"coalesce_window"`
+	// CoalesceKeys 为判定重复告警的字段，如 ip、rule_name，为空时按完整事件 ID 判定。
+	CoalesceKeys []string `json:"coalesce_keys,omitempty"`
+	// StormFilter 在合并前抑制告警风暴，避免抖动节点的大量重复告警主导分析结果。
+	StormFilter StormFilter `json:"storm_filter"`
+	// StageWeights 为空时使用 DefaultStageWeights。
+	StageWeights StageWeights `json:"stage_weights"`
+	// HealthyPenalty 为节点上报健康信号时置信度的扣减比例，取值 [0,1]。
+	HealthyPenalty float64 `json:"healthy_penalty"`
+	// PathSort 控制链路与影响的输出顺序，为空时按 key 排序。
+	PathSort PathSort `json:"path_sort"`
+	// Explain 为 true 时为每个候选生成可读的解释文本。
+	Explain bool `json:"explain"`
+	// StopAt 不为空时只分析到该层为止，层级中位于其上方的节点不参与候选评估。
+	StopAt NodeType `json:"stop_at,omitempty"`
+	// FailFast 为 true 时任一事件无法解析拓扑即中止整个窗口，默认跳过该事件并记入 ResolutionErrors。
+	FailFast bool `json:"fail_fast,omitempty"`
+	// CalibrateConfidence 为 true 时将同一窗口内候选的置信度按比例归一化为和为 1。
+	CalibrateConfidence bool `json:"calibrate_confidence,omitempty"`
+	// PriorityWeights 为告警优先级到影响面权重的映射（如 P1: 4、P4: 1），未列出的优先级按 1 计。
+	PriorityWeights map[string]float64 `json:"priority_weights,omitempty"`
+	// WeightedCoverage 为 true 时宿主机、虚拟机的覆盖率按 HOSTS_VM.weight、DEPLOYED_ON.instances 加权，缺少属性的关系按 1 计。
+	WeightedCoverage bool `json:"weighted_coverage,omitempty"`
+}
+
+// DefaultStageWeights 默认更信任拓扑候选，应用故障作为加成。
+func DefaultStageWeights() StageWeights {
+	return StageWeights{AppOutage: 0.3, Topology: 0.7}
 }
 
 // DefaultConfig 提供默认配置。
 func DefaultConfig() Config {
 	return Config{
-		Hierarchy: []NodeType{
-			NodeTypeApp,
-			NodeTypeVirtualMachine,
-			NodeTypeHostMachine,
-			NodeTypePhysicalMachine,
-			NodeTypeNetPartition,
-			NodeTypeIDC,
-		},
+		Hierarchy: defaultHierarchy(),
 		Layers: map[NodeType]LayerConfig{
 			NodeTypeApp: {
 				CoverageThreshold: 0.6,
 				MinChildren:       1,
 				Weights:           ScoreWeights{Coverage: 0.7, Impact: 0.3, Base: 0},
 			},
+			// 容器层默认不在 hierarchy 中，加入后使用与虚拟机相同的阈值
+			NodeTypeContainer: {
+				CoverageThreshold: 0.6,
+				MinChildren:       1,
+				Weights:           ScoreWeights{Coverage: 0.7, Impact: 0.3, Base: 0},
+			},
 			NodeTypeVirtualMachine: {
 				CoverageThreshold: 0.6,
 				MinChildren:       1,
@@ -69,5 +111,332 @@ func DefaultConfig() Config {
 		Datacenters:        []string{"M5", "星光", "三星大厦"},
 		AppOutageThreshold: 0.6,
 		RequireFullMatch:   true,
+		StageWeights:       DefaultStageWeights(),
+		HealthyPenalty:     0.5,
+	}
+}
+
+// Clone 深拷贝配置，避免共享 Layers 等引用类型。
+func (c Config) Clone() Config {
+	out := c
+	out.Hierarchy = append([]NodeType(nil), c.Hierarchy...)
+	out.Datacenters = append([]string(nil), c.Datacenters...)
+	out.CoalesceKeys = append([]string(nil), c.CoalesceKeys...)
+	out.StormFilter = c.StormFilter.clone()
+	out.PriorityWeights = clonePriorityWeights(c.PriorityWeights)
+	if c.Layers != nil {
+		out.Layers = make(map[NodeType]LayerConfig, len(c.Layers))
+		for t, layer := range c.Layers {
+			out.Layers[t] = layer
+		}
+	}
+	return out
+}
+
+func clonePriorityWeights(weights map[string]float64) map[string]float64 {
+	if weights == nil {
+		return nil
+	}
+	out := make(map[string]float64, len(weights))
+	for priority, w := range weights {
+		out[priority] = w
+	}
+	return out
+}
+
+// priorityWeight 返回告警优先级对应的影响面权重，未配置的优先级为 1。
+func (c Config) priorityWeight(priority string) float64 {
+	if w, ok := c.PriorityWeights[priority]; ok {
+		return w
+	}
+	return 1
+}
+
+// layersAboveStop 返回层级中位于 StopAt 之上的节点类型，未设置 StopAt 时为空。
+func (c Config) layersAboveStop() map[NodeType]struct{} {
+	if c.StopAt == "" {
+		return nil
+	}
+	above := make(map[NodeType]struct{})
+	reached := false
+	for _, t := range c.Hierarchy {
+		if reached {
+			above[t] = struct{}{}
+		}
+		if t == c.StopAt {
+			reached = true
+		}
+	}
+	return above
+}
+
+// LayerOverride 为单层配置的部分覆盖，nil 字段保持原值。
+type LayerOverride struct {
+	CoverageThreshold *float64      `json:"coverage_threshold,omitempty"`
+	MinChildren       *int          `json:"min_children,omitempty"`
+	Weights           *ScoreWeights `json:"weights,omitempty"`
+}
+
+// ConfigOverride 为配置的部分覆盖，用于单次请求调整阈值；Hierarchy 不为空时整体替换层级顺序。
+type ConfigOverride struct {
+	Hierarchy           []NodeType                 `json:"hierarchy,omitempty"`
+	Layers              map[NodeType]LayerOverride `json:"layers,omitempty"`
+	AppOutageThreshold  *float64                   `json:"app_outage_threshold,omitempty"`
+	RequireFullMatch    *bool                      `json:"require_full_match,omitempty"`
+	CoalesceWindow      *time.Duration             `json:"coalesce_window,omitempty"`
+	CoalesceKeys        []string                   `json:"coalesce_keys,omitempty"`
+	StormFilter         *StormFilter               `json:"storm_filter,omitempty"`
+	StageWeights        *StageWeights              `json:"stage_weights,omitempty"`
+	HealthyPenalty      *float64                   `json:"healthy_penalty,omitempty"`
+	PathSort            *PathSort                  `json:"path_sort,omitempty"`
+	Explain             *bool                      `json:"explain,omitempty"`
+	StopAt              *NodeType                  `json:"stop_at,omitempty"`
+	FailFast            *bool                      `json:"fail_fast,omitempty"`
+	CalibrateConfidence *bool                      `json:"calibrate_confidence,omitempty"`
+	PriorityWeights     map[string]float64         `json:"priority_weights,omitempty"`
+	WeightedCoverage    *bool                      `json:"weighted_coverage,omitempty"`
+}
+
+// Merge 在配置副本上应用覆盖并校验，原配置不受影响。
+func (c Config) Merge(o ConfigOverride) (Config, error) {
+	out := c.Clone()
+	if len(o.Hierarchy) > 0 {
+		out.Hierarchy = append([]NodeType(nil), o.Hierarchy...)
+	}
+	for t, lo := range o.Layers {
+		if !isKnownNodeType(t) {
+			return Config{}, fmt.Errorf("unknown node type %q in override", t)
+		}
+		if out.Layers == nil {
+			out.Layers = make(map[NodeType]LayerConfig)
+		}
+		layer := out.Layers[t]
+		if lo.CoverageThreshold != nil {
+			layer.CoverageThreshold = *lo.CoverageThreshold
+		}
+		if lo.MinChildren != nil {
+			layer.MinChildren = *lo.MinChildren
+		}
+		if lo.Weights != nil {
+			layer.Weights = *lo.Weights
+		}
+		out.Layers[t] = layer
+	}
+	if o.AppOutageThreshold != nil {
+		out.AppOutageThreshold = *o.AppOutageThreshold
+	}
+	if o.RequireFullMatch != nil {
+		out.RequireFullMatch = *o.RequireFullMatch
+	}
+	if o.CoalesceWindow != nil {
+		out.CoalesceWindow = *o.CoalesceWindow
+	}
+	if o.CoalesceKeys != nil {
+		out.CoalesceKeys = append([]string(nil), o.CoalesceKeys...)
+	}
+	if o.StormFilter != nil {
+		out.StormFilter = o.StormFilter.clone()
+	}
+	if o.StageWeights != nil {
+		out.StageWeights = *o.StageWeights
+	}
+	if o.HealthyPenalty != nil {
+		out.HealthyPenalty = *o.HealthyPenalty
+	}
+	if o.PathSort != nil {
+		out.PathSort = *o.PathSort
+	}
+	if o.Explain != nil {
+		out.Explain = *o.Explain
+	}
+	if o.StopAt != nil {
+		out.StopAt = *o.StopAt
+	}
+	if o.FailFast != nil {
+		out.FailFast = *o.FailFast
+	}
+	if o.CalibrateConfidence != nil {
+		out.CalibrateConfidence = *o.CalibrateConfidence
+	}
+	if o.PriorityWeights != nil {
+		out.PriorityWeights = clonePriorityWeights(o.PriorityWeights)
+	}
+	if o.WeightedCoverage != nil {
+		out.WeightedCoverage = *o.WeightedCoverage
+	}
+	if err := out.Validate(); err != nil {
+		return Config{}, err
+	}
+	return out, nil
+}
+
+// knownNodeTypes 为分析器支持的节点类型，自底向上排列；optionalNodeTypes 中的层默认配置不包含。
+var knownNodeTypes = []NodeType{
+	NodeTypeApp,
+	NodeTypeContainer,
+	NodeTypeVirtualMachine,
+	NodeTypeHostMachine,
+	NodeTypePhysicalMachine,
+	NodeTypeNetPartition,
+	NodeTypeIDC,
+	NodeTypeService,
+}
+
+// optionalNodeTypes 为可选层：容器层在应用与虚拟机之间增加一级，服务层聚合应用而不在物理链路上；
+// 解析出的链路包含这些层但 hierarchy 未配置时直接丢弃，不视为错误。
+var optionalNodeTypes = map[NodeType]bool{
+	NodeTypeContainer: true,
+	NodeTypeService:   true,
+}
+
+// defaultHierarchy 返回去掉可选层后的 knownNodeTypes。
+func defaultHierarchy() []NodeType {
+	out := make([]NodeType, 0, len(knownNodeTypes))
+	for _, t := range knownNodeTypes {
+		if !optionalNodeTypes[t] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func isKnownNodeType(t NodeType) bool {
+	for _, known := range knownNodeTypes {
+		if known == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 校验配置是否合法。
+func (c Config) Validate() error {
+	if len(c.Hierarchy) == 0 {
+		return fmt.Errorf("hierarchy is empty")
+	}
+	seen := make(map[NodeType]struct{}, len(c.Hierarchy))
+	for _, t := range c.Hierarchy {
+		if !isKnownNodeType(t) {
+			return fmt.Errorf("unknown node type %q in hierarchy", t)
+		}
+		if _, ok := seen[t]; ok {
+			return fmt.Errorf("duplicate node type %q in hierarchy", t)
+		}
+		seen[t] = struct{}{}
+	}
+	if err := validateResolveQueries(c.Hierarchy); err != nil {
+		return err
+	}
+	if _, ok := seen[c.StopAt]; c.StopAt != "" && !ok {
+		return fmt.Errorf("stop_at %q is not in hierarchy", c.StopAt)
+	}
+	for t, layer := range c.Layers {
+		if !isKnownNodeType(t) {
+			return fmt.Errorf("unknown node type %q in layers", t)
+		}
+		if layer.CoverageThreshold < 0 || layer.CoverageThreshold > 1 {
+			return fmt.Errorf("layer %s coverage_threshold %.2f out of [0,1]", t, layer.CoverageThreshold)
+		}
+		if layer.MinChildren < 0 {
+			return fmt.Errorf("layer %s min_children must not be negative", t)
+		}
+		w := layer.Weights
+		if w.Coverage < 0 || w.TimeLead < 0 || w.Impact < 0 || w.Base < 0 {
+			return fmt.Errorf("layer %s weights must not be negative", t)
+		}
+	}
+	if c.AppOutageThreshold < 0 || c.AppOutageThreshold > 1 {
+		return fmt.Errorf("app_outage_threshold %.2f out of [0,1]", c.AppOutageThreshold)
+	}
+	if c.CoalesceWindow < 0 {
+		return fmt.Errorf("coalesce_window must not be negative")
+	}
+	if err := validateCoalesceKeys(c.CoalesceKeys); err != nil {
+		return err
+	}
+	if err := c.StormFilter.validate(); err != nil {
+		return err
+	}
+	if c.StageWeights.AppOutage < 0 || c.StageWeights.Topology < 0 {
+		return fmt.Errorf("stage_weights must not be negative")
+	}
+	if c.HealthyPenalty < 0 || c.HealthyPenalty > 1 {
+		return fmt.Errorf("healthy_penalty %.2f out of [0,1]", c.HealthyPenalty)
+	}
+	if err := c.PathSort.validate(); err != nil {
+		return err
+	}
+	for priority, w := range c.PriorityWeights {
+		if w <= 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return fmt.Errorf("priority_weights[%s] must be a positive number", priority)
+		}
+	}
+	return nil
+}
+
+// Level 表示自动生成配置时的严格程度预设。
+type Level string
+
+const (
+	LevelLenient  Level = "lenient"
+	LevelBalanced Level = "balanced"
+	LevelStrict   Level = "strict"
+)
+
+// levelPreset 描述某个严格程度下最底层的阈值、逐层递增幅度以及覆盖率权重。
+type levelPreset struct {
+	baseThreshold float64
+	step          float64
+	coverage      float64
+}
+
+var levelPresets = map[Level]levelPreset{
+	LevelLenient:  {baseThreshold: 0.4, step: 0.2, coverage: 0.6},
+	LevelBalanced: {baseThreshold: 0.6, step: 0.2, coverage: 0.7},
+	LevelStrict:   {baseThreshold: 0.75, step: 0.2, coverage: 0.8},
+}
+
+// topologyLevels 为分析器沿链路自底向上经过的层级：物理机直接承载应用，与宿主机同属主机层；
+// 容器、服务等可选层不计入深度。
+var topologyLevels = [][]NodeType{
+	{NodeTypeApp},
+	{NodeTypeVirtualMachine},
+	{NodeTypeHostMachine, NodeTypePhysicalMachine},
+	{NodeTypeNetPartition},
+	{NodeTypeIDC},
+}
+
+// ConfigForTopology 按层级深度与严格程度生成配置：层级保持完整的默认层级，保证解析出的链路都能排序，
+// 只分析自底向上的前 depth 层（通过 StopAt 截断），越靠上的层级覆盖率阈值越高，严格程度越高覆盖率权重越大。
+func ConfigForTopology(depth int, strictness Level) (Config, error) {
+	if depth <= 0 || depth > len(topologyLevels) {
+		return Config{}, fmt.Errorf("depth %d out of [1,%d]", depth, len(topologyLevels))
+	}
+	preset, ok := levelPresets[strictness]
+	if !ok {
+		return Config{}, fmt.Errorf("unknown strictness %q", strictness)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Hierarchy = defaultHierarchy()
+	cfg.Layers = make(map[NodeType]LayerConfig)
+	for i, level := range topologyLevels[:depth] {
+		threshold := preset.baseThreshold
+		if depth > 1 {
+			threshold += preset.step * float64(i) / float64(depth-1)
+		}
+		if threshold > 0.95 {
+			threshold = 0.95
+		}
+		for _, t := range level {
+			cfg.Layers[t] = LayerConfig{
+				CoverageThreshold: threshold,
+				MinChildren:       1,
+				Weights:           ScoreWeights{Coverage: preset.coverage, Impact: 1 - preset.coverage},
+			}
+		}
+		cfg.StopAt = level[len(level)-1]
 	}
+	cfg.AppOutageThreshold = preset.baseThreshold
+	return cfg, cfg.Validate()
 }
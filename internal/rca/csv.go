@@ -0,0 +1,40 @@
+package rca
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// CandidateCSVHeader 为候选 CSV 导出的表头。
+var CandidateCSVHeader = []string{"node_key", "node_type", "node_name", "confidence", "coverage", "reason", "explained_event_count"}
+
+// WriteCandidatesCSV 按置信度降序将候选逐行写入 w，不在内存中拼接完整结果，适合直接写入 HTTP 响应。
+func WriteCandidatesCSV(w io.Writer, candidates []Candidate) error {
+	ordered := append([]Candidate(nil), candidates...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Confidence > ordered[j].Confidence
+	})
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CandidateCSVHeader); err != nil {
+		return err
+	}
+	for _, cand := range ordered {
+		record := []string{
+			cand.Node.Key,
+			string(cand.Node.Type),
+			cand.Node.Name,
+			strconv.FormatFloat(cand.Confidence, 'f', 4, 64),
+			strconv.FormatFloat(cand.Coverage, 'f', 4, 64),
+			cand.Reason,
+			strconv.Itoa(len(cand.Explained)),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,111 @@
+package rca
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenderPathDOT 将候选及其告警扩散路径渲染为 Graphviz DOT，首个候选高亮，边上标注子树内的告警数。
+func RenderPathDOT(result Result) string {
+	r := dotRenderer{declared: make(map[string]bool), linked: make(map[string]bool)}
+	r.b.WriteString("digraph rca {\n")
+	r.b.WriteString("  rankdir=TB;\n")
+	if window := windowLabel(result); window != "" {
+		fmt.Fprintf(&r.b, "  label=%s;\n  labelloc=t;\n", dotQuote("window "+window))
+	}
+	r.b.WriteString("  node [shape=box, style=\"rounded,filled\", fillcolor=white];\n")
+
+	top := ""
+	if len(result.Candidates) > 0 {
+		top = result.Candidates[0].Node.Key
+	}
+	for _, cand := range result.Candidates {
+		attrs := fmt.Sprintf("fillcolor=lightyellow, tooltip=%s", dotQuote(fmt.Sprintf("confidence %.2f", cand.Confidence)))
+		if cand.Node.Key == top {
+			attrs = fmt.Sprintf("fillcolor=tomato, penwidth=2, tooltip=%s", dotQuote(fmt.Sprintf("top candidate, confidence %.2f", cand.Confidence)))
+		}
+		r.node(cand.Node, attrs)
+	}
+	for _, path := range result.Paths {
+		r.node(path.Candidate, "")
+		r.impacts(path.Candidate, path.Impacts)
+	}
+	r.b.WriteString("}\n")
+	return r.b.String()
+}
+
+type dotRenderer struct {
+	b        strings.Builder
+	declared map[string]bool
+	linked   map[string]bool
+}
+
+// node 声明节点，同一节点只声明一次，候选的样式优先于路径中的普通节点。
+func (r *dotRenderer) node(ref NodeRef, attrs string) {
+	id := dotNodeID(ref)
+	if r.declared[id] {
+		return
+	}
+	r.declared[id] = true
+	fmt.Fprintf(&r.b, "  %s [label=%s", dotQuote(id), dotQuote(dotLabel(ref)))
+	if attrs != "" {
+		fmt.Fprintf(&r.b, ", %s", attrs)
+	}
+	r.b.WriteString("];\n")
+}
+
+func (r *dotRenderer) impacts(parent NodeRef, impacts []PathImpact) {
+	for _, impact := range impacts {
+		r.node(impact.Node, "")
+		edge := dotNodeID(parent) + "->" + dotNodeID(impact.Node)
+		if !r.linked[edge] {
+			r.linked[edge] = true
+			fmt.Fprintf(&r.b, "  %s -> %s [label=%s];\n", dotQuote(dotNodeID(parent)), dotQuote(dotNodeID(impact.Node)), dotQuote(fmt.Sprintf("%d events", impactEventCount(impact))))
+		}
+		r.impacts(impact.Node, impact.Impacts)
+	}
+}
+
+// impactEventCount 统计子树内的去重告警数：同一告警沿链路出现在多层时只计一次，
+// 合并前被抑制的重复告警一并计入。
+func impactEventCount(impact PathImpact) int {
+	seen := make(map[string]int)
+	collectImpactEvents(impact, seen)
+	total := 0
+	for _, suppressed := range seen {
+		total += 1 + suppressed
+	}
+	return total
+}
+
+func collectImpactEvents(impact PathImpact, seen map[string]int) {
+	for _, ev := range impact.Events {
+		if _, ok := seen[ev.ID]; !ok {
+			seen[ev.ID] = ev.Suppressed
+		}
+	}
+	for _, child := range impact.Impacts {
+		collectImpactEvents(child, seen)
+	}
+}
+
+func dotNodeID(ref NodeRef) string {
+	if ref.Key != "" {
+		return ref.Key
+	}
+	return string(ref.Type) + ":" + ref.Name
+}
+
+func dotLabel(ref NodeRef) string {
+	name := ref.Name
+	if name == "" {
+		name = ref.Key
+	}
+	return name + "\n" + string(ref.Type)
+}
+
+// dotQuote 生成 DOT 双引号字符串，转义反斜杠、引号与换行。
+func dotQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", "", "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
@@ -0,0 +1,62 @@
+package rca
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// nodeTypeNames 为各节点类型在解释文本中的中英文名称。
+var nodeTypeNames = map[NodeType][2]string{
+	NodeTypeApp:             {"应用", "apps"},
+	NodeTypeContainer:       {"容器", "containers"},
+	NodeTypeVirtualMachine:  {"虚拟机", "VMs"},
+	NodeTypeHostMachine:     {"宿主机", "hosts"},
+	NodeTypePhysicalMachine: {"物理机", "physical machines"},
+	NodeTypeNetPartition:    {"网络分区", "network partitions"},
+	NodeTypeIDC:             {"机房", "IDCs"},
+	NodeTypeService:         {"服务", "services"},
+}
+
+// explainCandidate 根据覆盖的子节点与告警领先时长生成候选的解释文本，language 以 en 开头时输出英文。
+func explainCandidate(node *TopoNode, language string) string {
+	english := strings.HasPrefix(strings.ToLower(language), "en")
+	var parts []string
+
+	if childType := node.ChildType(); childType != "" {
+		names, ok := nodeTypeNames[childType]
+		if !ok {
+			names = [2]string{string(childType), string(childType)}
+		}
+		alarmed := len(node.Impacts)
+		total := node.ChildCounts[childType]
+		if total < alarmed {
+			total = alarmed
+		}
+		if english {
+			parts = append(parts, fmt.Sprintf("%d/%d child %s alarmed", alarmed, total, names[1]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d 台%s中 %d 台告警", total, names[0], alarmed))
+		}
+	} else if count := len(node.Events); count > 0 {
+		if english {
+			parts = append(parts, fmt.Sprintf("%d alarms on the node itself", count))
+		} else {
+			parts = append(parts, fmt.Sprintf("节点自身告警 %d 条", count))
+		}
+	}
+
+	if lead, _ := node.leadDurations(); lead > 0 {
+		lead = lead.Round(time.Second)
+		if english {
+			parts = append(parts, fmt.Sprintf("fired %s before downstream", lead))
+		} else {
+			parts = append(parts, fmt.Sprintf("自身告警早于下游 %s", lead))
+		}
+	}
+
+	if english {
+		return strings.Join(parts, ", ")
+	}
+	return strings.Join(parts, "，")
+}
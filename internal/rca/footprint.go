@@ -0,0 +1,234 @@
+package rca
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/graph"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// defaultFootprintCacheTTL 为部署范围查询的默认缓存时间，拓扑变化缓慢，短时间内重复查询直接复用结果。
+const defaultFootprintCacheTTL = 30 * time.Second
+
+// footprintQueryTemplates 与 ListAppInstances 的计数查询一一对应，返回实例本身及其所在宿主机、网络分区与机房；
+// 指定机房时只保留该机房的实例，与分析器的覆盖率分母一致，未指定时拓扑不完整的实例也会返回。
+// 每一步 OPTIONAL MATCH 都跳过墓碑节点与关系，已下线的宿主机、分区或机房不会出现在部署范围中。
+var footprintQueryTemplates = []string{
+	`
+MATCH (app:{{label "App"}} {name: $app})-[d:{{rel "DEPLOYED_ON"}}]->(vm:{{label "VirtualMachine"}})
+WHERE coalesce(d.deleted, false) = false AND coalesce(vm.deleted, false) = false
+OPTIONAL MATCH (vm)<-[hv:{{rel "HOSTS_VM"}}]-(host)
+WHERE (host:{{label "HostMachine"}} OR host:{{label "PhysicalMachine"}})
+  AND coalesce(hv.deleted, false) = false AND coalesce(host.deleted, false) = false
+OPTIONAL MATCH (host)<-[hh:{{rel "HAS_HOST"}}|{{rel "HAS_PHYSICAL"}}]-(np:{{label "NetPartition"}})
+WHERE coalesce(hh.deleted, false) = false AND coalesce(np.deleted, false) = false
+OPTIONAL MATCH (np)<-[hp:{{rel "HAS_PARTITION"}}]-(idc:{{label "IDC"}})
+WHERE coalesce(hp.deleted, false) = false AND coalesce(idc.deleted, false) = false
+WITH vm, host, np, idc
+WHERE $idc = '' OR idc.name = $idc
+RETURN vm AS instance, host.cmdb_key AS host_key, host.ip AS host_ip, np.cmdb_key AS np_key, np.name AS np_name, idc.name AS idc
+`,
+	`
+MATCH (app:{{label "App"}} {name: $app})-[d:{{rel "DEPLOYED_ON"}}]->(host:{{label "HostMachine"}})
+WHERE coalesce(d.deleted, false) = false AND coalesce(host.deleted, false) = false
+OPTIONAL MATCH (host)<-[hh:{{rel "HAS_HOST"}}]-(np:{{label "NetPartition"}})
+WHERE coalesce(hh.deleted, false) = false AND coalesce(np.deleted, false) = false
+OPTIONAL MATCH (np)<-[hp:{{rel "HAS_PARTITION"}}]-(idc:{{label "IDC"}})
+WHERE coalesce(hp.deleted, false) = false AND coalesce(idc.deleted, false) = false
+WITH host, np, idc
+WHERE $idc = '' OR idc.name = $idc
+RETURN host AS instance, np.cmdb_key AS np_key, np.name AS np_name, idc.name AS idc
+`,
+	`
+MATCH (app:{{label "App"}} {name: $app})-[d:{{rel "DEPLOYED_ON"}}]->(phy:{{label "PhysicalMachine"}})
+WHERE coalesce(d.deleted, false) = false AND coalesce(phy.deleted, false) = false
+OPTIONAL MATCH (np:{{label "NetPartition"}})-[hh:{{rel "HAS_PHYSICAL"}}]->(phy)
+WHERE coalesce(hh.deleted, false) = false AND coalesce(np.deleted, false) = false
+OPTIONAL MATCH (np)<-[hp:{{rel "HAS_PARTITION"}}]-(idc:{{label "IDC"}})
+WHERE coalesce(hp.deleted, false) = false AND coalesce(idc.deleted, false) = false
+WITH phy, np, idc
+WHERE $idc = '' OR idc.name = $idc
+RETURN phy AS instance, np.cmdb_key AS np_key, np.name AS np_name, idc.name AS idc
+`,
+	`
+MATCH (app:{{label "App"}} {name: $app})-[d:{{rel "DEPLOYED_ON"}}]->(ctr:{{label "Container"}})
+WHERE coalesce(d.deleted, false) = false AND coalesce(ctr.deleted, false) = false
+OPTIONAL MATCH (ctr)-[ro:{{rel "RUNS_ON"}}]->(node)
+WHERE (node:{{label "VirtualMachine"}} OR node:{{label "HostMachine"}})
+  AND coalesce(ro.deleted, false) = false AND coalesce(node.deleted, false) = false
+OPTIONAL MATCH hosted = (node)<-[:{{rel "HOSTS_VM"}}*0..1]-(host)
+WHERE (host:{{label "HostMachine"}} OR host:{{label "PhysicalMachine"}})
+  AND coalesce(host.deleted, false) = false AND all(r IN relationships(hosted) WHERE coalesce(r.deleted, false) = false)
+OPTIONAL MATCH (host)<-[hh:{{rel "HAS_HOST"}}|{{rel "HAS_PHYSICAL"}}]-(np:{{label "NetPartition"}})
+WHERE coalesce(hh.deleted, false) = false AND coalesce(np.deleted, false) = false
+OPTIONAL MATCH (np)<-[hp:{{rel "HAS_PARTITION"}}]-(idc:{{label "IDC"}})
+WHERE coalesce(hp.deleted, false) = false AND coalesce(idc.deleted, false) = false
+WITH ctr, node, np, idc
+WHERE $idc = '' OR idc.name = $idc
+RETURN ctr AS instance, node.cmdb_key AS host_key, node.ip AS host_ip, np.cmdb_key AS np_key, np.name AS np_name, idc.name AS idc
+`,
+}
+
+// AppInstance 为应用部署的一台虚拟机、宿主机、物理机或一个容器。
+type AppInstance struct {
+	Key      string   `json:"key"`
+	Type     NodeType `json:"type"`
+	Name     string   `json:"name"`
+	IP       string   `json:"ip,omitempty"`
+	Hostname string   `json:"hostname,omitempty"`
+	// HostKey 与 HostIP 仅对虚拟机与容器有值，虚拟机为其所在宿主机，容器为其所在虚拟机或宿主机。
+	HostKey      string `json:"host_key,omitempty"`
+	HostIP       string `json:"host_ip,omitempty"`
+	PartitionKey string `json:"partition_key,omitempty"`
+	Partition    string `json:"partition,omitempty"`
+	IDC          string `json:"idc,omitempty"`
+}
+
+// AppFootprint 为应用的部署范围，Counts 按实例类型计数，指定机房时 Total 与 ListAppInstances 的结果一致。
+type AppFootprint struct {
+	App       string           `json:"app"`
+	IDC       string           `json:"idc,omitempty"`
+	Total     int              `json:"total"`
+	Counts    map[NodeType]int `json:"counts"`
+	Instances []AppInstance    `json:"instances"`
+}
+
+// FootprintReader 查询应用部署在哪些实例上。
+type FootprintReader interface {
+	AppFootprint(ctx context.Context, appName string, datacenter string) (AppFootprint, error)
+}
+
+// GraphFootprintReader 基于 Neo4j 只读查询实现 FootprintReader，结果按应用与机房短暂缓存。
+type GraphFootprintReader struct {
+	reader  graph.Reader
+	cache   *lruCache[AppFootprint]
+	schema  domain.Schema
+	queries []string
+}
+
+// FootprintOption 配置 GraphFootprintReader 的可选项。
+type FootprintOption func(*footprintOptions)
+
+type footprintOptions struct {
+	cache  CacheConfig
+	schema domain.Schema
+}
+
+// WithFootprintCache 设置部署范围缓存的容量与有效期，TTL<=0 时取 30 秒。
+func WithFootprintCache(cfg CacheConfig) FootprintOption {
+	return func(o *footprintOptions) {
+		o.cache = cfg
+	}
+}
+
+// WithFootprintSchema 按 schema 匹配图中的实际标签与关系类型，返回的实例类型仍为规范名。
+func WithFootprintSchema(schema domain.Schema) FootprintOption {
+	return func(o *footprintOptions) {
+		o.schema = schema
+	}
+}
+
+// NewGraphFootprintReader 构建基于 Neo4j 的部署范围查询。
+func NewGraphFootprintReader(reader graph.Reader, opts ...FootprintOption) *GraphFootprintReader {
+	var o footprintOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	if o.cache.TTL <= 0 {
+		o.cache.TTL = defaultFootprintCacheTTL
+	}
+	queries := make([]string, len(footprintQueryTemplates))
+	for i, text := range footprintQueryTemplates {
+		queries[i] = mustRenderSchemaQuery(o.schema, "footprint", text)
+	}
+	return &GraphFootprintReader{reader: reader, cache: newLRUCache[AppFootprint](o.cache), schema: o.schema, queries: queries}
+}
+
+// CacheStats 返回部署范围缓存的命中统计。
+func (r *GraphFootprintReader) CacheStats() CacheStats {
+	return r.cache.stats()
+}
+
+// AppFootprint 实现 FootprintReader，datacenter 为空时返回所有机房的实例；应用没有任何实例时返回空列表而不是错误。
+func (r *GraphFootprintReader) AppFootprint(ctx context.Context, appName string, datacenter string) (AppFootprint, error) {
+	appName, datacenter = strings.TrimSpace(appName), strings.TrimSpace(datacenter)
+	if appName == "" {
+		return AppFootprint{}, errors.New("app name is required")
+	}
+	key := appName + "|" + datacenter
+	if cached, ok := r.cache.get(key); ok {
+		return cached, nil
+	}
+
+	footprint := AppFootprint{App: appName, IDC: datacenter, Counts: make(map[NodeType]int), Instances: []AppInstance{}}
+	seen := make(map[string]bool)
+	params := map[string]any{"app": appName, "idc": datacenter}
+	for _, query := range r.queries {
+		records, err := r.reader.RunRead(ctx, query, params)
+		if err != nil {
+			return AppFootprint{}, fmt.Errorf("query footprint of app %s failed: %w", appName, err)
+		}
+		canonicalizeRecords(r.schema, records)
+		for _, record := range records {
+			inst, err := instanceFromRecord(record)
+			if err != nil {
+				return AppFootprint{}, fmt.Errorf("decode footprint of app %s failed: %w", appName, err)
+			}
+			// 同一实例经多个网络分区命中时只保留第一条
+			if seen[inst.Key] {
+				continue
+			}
+			seen[inst.Key] = true
+			footprint.Instances = append(footprint.Instances, inst)
+			footprint.Counts[inst.Type]++
+		}
+	}
+	footprint.Total = len(footprint.Instances)
+	sort.SliceStable(footprint.Instances, func(i, j int) bool {
+		a, b := footprint.Instances[i], footprint.Instances[j]
+		if a.Type != b.Type {
+			return nodeTypeRank(a.Type) < nodeTypeRank(b.Type)
+		}
+		return a.Key < b.Key
+	})
+	r.cache.put(key, footprint)
+	return footprint, nil
+}
+
+func instanceFromRecord(record map[string]any) (AppInstance, error) {
+	raw, ok := record["instance"].(neo4j.Node)
+	if !ok {
+		return AppInstance{}, fmt.Errorf("field instance is %T, not neo4j node", record["instance"])
+	}
+	node := nodeFromNeo4j(raw)
+	return AppInstance{
+		Key:          node.Key,
+		Type:         node.Type,
+		Name:         node.Name,
+		IP:           firstNonEmpty(node.Props["ip"]),
+		Hostname:     firstNonEmpty(node.Props["hostname"]),
+		HostKey:      firstNonEmpty(record["host_key"]),
+		HostIP:       firstNonEmpty(record["host_ip"]),
+		PartitionKey: firstNonEmpty(record["np_key"]),
+		Partition:    firstNonEmpty(record["np_name"]),
+		IDC:          firstNonEmpty(record["idc"]),
+	}, nil
+}
+
+// nodeTypeRank 返回节点类型在 knownNodeTypes 中的位置，未知类型排在最后。
+func nodeTypeRank(t NodeType) int {
+	for i, known := range knownNodeTypes {
+		if known == t {
+			return i
+		}
+	}
+	return len(knownNodeTypes)
+}
@@ -0,0 +1,52 @@
+package rca
+
+import (
+	"context"
+	"sort"
+)
+
+// splitHealthy 把健康信号与普通告警分开，健康信号不参与覆盖率计算。
+func splitHealthy(events []AlarmEvent) (alarms, healthy []AlarmEvent) {
+	for _, evt := range events {
+		if evt.Healthy {
+			healthy = append(healthy, evt)
+			continue
+		}
+		alarms = append(alarms, evt)
+	}
+	return alarms, healthy
+}
+
+// resolveHealthy 返回健康信号所指向的节点 key，即解析链路中的第一个节点；
+// 无法解析的信号直接忽略，不影响告警分析。
+func (a *Analyzer) resolveHealthy(ctx context.Context, signals []AlarmEvent) map[string]struct{} {
+	if len(signals) == 0 {
+		return nil
+	}
+	keys := make(map[string]struct{}, len(signals))
+	for _, evt := range signals {
+		resolved, err := a.provider.ResolveEvent(ctx, evt)
+		if err != nil || len(resolved) == 0 {
+			continue
+		}
+		keys[resolved[0].NodeRef.Key] = struct{}{}
+	}
+	return keys
+}
+
+// demoteHealthy 按 HealthyPenalty 降低上报健康信号节点的置信度，并重新排序。
+func demoteHealthy(candidates []Candidate, healthy map[string]struct{}, penalty float64) []Candidate {
+	if len(healthy) == 0 || penalty <= 0 {
+		return candidates
+	}
+	for i := range candidates {
+		if _, ok := healthy[candidates[i].Node.Key]; !ok {
+			continue
+		}
+		candidates[i].Confidence *= 1 - penalty
+		candidates[i].Metrics.Normalized = candidates[i].Confidence
+		candidates[i].Reason += "+HEALTHY_SIGNAL"
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Confidence > candidates[j].Confidence })
+	return candidates
+}
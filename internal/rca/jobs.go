@@ -0,0 +1,213 @@
+package rca
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// JobState 为异步分析任务的状态。
+type JobState string
+
+const (
+	JobPending   JobState = "pending"
+	JobRunning   JobState = "running"
+	JobDone      JobState = "done"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+// DefaultJobTTL 为任务结束后在内存中保留的时长。
+const DefaultJobTTL = 30 * time.Minute
+
+// DefaultMaxInFlightJobs 为同时处于 pending/running 状态的任务上限。
+const DefaultMaxInFlightJobs = 4
+
+var (
+	// ErrTooManyJobs 表示未结束的任务已达上限，新任务被拒绝。
+	ErrTooManyJobs = errors.New("too many in-flight rca jobs")
+	// ErrJobRegistryClosed 表示登记表已关闭，不再接受新任务。
+	ErrJobRegistryClosed = errors.New("rca job registry closed")
+)
+
+// Job 描述一次异步分析的状态与结果。
+type Job struct {
+	ID        string    `json:"job_id"`
+	WindowID  string    `json:"window_id,omitempty"`
+	State     JobState  `json:"state"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Error     string    `json:"error,omitempty"`
+	Result    *Result   `json:"result,omitempty"`
+}
+
+func (j Job) finished() bool {
+	return j.State == JobDone || j.State == JobFailed || j.State == JobCancelled
+}
+
+type jobEntry struct {
+	job    Job
+	cancel context.CancelFunc
+}
+
+// JobRegistry 在进程内登记异步分析任务，结束超过 TTL 的任务在后续访问时淘汰。
+// 未结束的任务数受 maxInFlight 限制，Close 取消全部任务并拒绝后续提交。
+type JobRegistry struct {
+	mu          sync.Mutex
+	jobs        map[string]*jobEntry
+	ttl         time.Duration
+	seq         int64
+	now         func() time.Time
+	root        context.Context
+	stop        context.CancelFunc
+	closed      bool
+	inFlight    int
+	maxInFlight int
+}
+
+// JobRegistryOption 配置 JobRegistry。
+type JobRegistryOption func(*JobRegistry)
+
+// WithMaxInFlight 设置未结束任务的上限，非正数时使用 DefaultMaxInFlightJobs。
+func WithMaxInFlight(n int) JobRegistryOption {
+	return func(r *JobRegistry) {
+		if n > 0 {
+			r.maxInFlight = n
+		}
+	}
+}
+
+// NewJobRegistry 创建任务登记表，ttl 非正数时使用 DefaultJobTTL。
+func NewJobRegistry(ttl time.Duration, opts ...JobRegistryOption) *JobRegistry {
+	if ttl <= 0 {
+		ttl = DefaultJobTTL
+	}
+	root, stop := context.WithCancel(context.Background())
+	r := &JobRegistry{jobs: make(map[string]*jobEntry), ttl: ttl, now: time.Now, root: root, stop: stop, maxInFlight: DefaultMaxInFlightJobs}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(r)
+		}
+	}
+	return r
+}
+
+// Submit 登记任务并在后台执行 fn，立即返回处于 pending 状态的任务。
+// fn 的上下文派生自登记表的根上下文，可通过 Cancel 或 Close 取消；
+// 未结束任务已达上限时返回 ErrTooManyJobs，登记表关闭后返回 ErrJobRegistryClosed。
+func (r *JobRegistry) Submit(windowID string, fn func(ctx context.Context) (Result, error)) (Job, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return Job{}, ErrJobRegistryClosed
+	}
+	if r.inFlight >= r.maxInFlight {
+		r.mu.Unlock()
+		return Job{}, ErrTooManyJobs
+	}
+	ctx, cancel := context.WithCancel(r.root)
+	r.evictLocked()
+	r.inFlight++
+	r.seq++
+	now := r.now()
+	entry := &jobEntry{
+		job:    Job{ID: fmt.Sprintf("job-%d-%d", now.Unix(), r.seq), WindowID: windowID, State: JobPending, CreatedAt: now, UpdatedAt: now},
+		cancel: cancel,
+	}
+	r.jobs[entry.job.ID] = entry
+	job := entry.job
+	r.mu.Unlock()
+
+	go r.run(ctx, entry, fn)
+	return job, nil
+}
+
+func (r *JobRegistry) run(ctx context.Context, entry *jobEntry, fn func(ctx context.Context) (Result, error)) {
+	defer entry.cancel()
+	defer func() {
+		r.mu.Lock()
+		r.inFlight--
+		r.mu.Unlock()
+	}()
+
+	r.mu.Lock()
+	if entry.job.State != JobPending {
+		r.mu.Unlock()
+		return
+	}
+	entry.job.State = JobRunning
+	entry.job.UpdatedAt = r.now()
+	r.mu.Unlock()
+
+	result, err := fn(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry.job.UpdatedAt = r.now()
+	switch {
+	case entry.job.State == JobCancelled:
+	case errors.Is(err, context.Canceled):
+		entry.job.State = JobCancelled
+	case err != nil:
+		entry.job.State = JobFailed
+		entry.job.Error = err.Error()
+	default:
+		entry.job.State = JobDone
+		entry.job.Result = &result
+	}
+}
+
+// Get 返回任务的当前状态。
+func (r *JobRegistry) Get(id string) (Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.evictLocked()
+	entry, ok := r.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return entry.job, true
+}
+
+// Cancel 取消未结束的任务，任务不存在或已结束时返回 false。
+func (r *JobRegistry) Cancel(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.jobs[id]
+	if !ok || entry.job.finished() {
+		return false
+	}
+	entry.cancel()
+	entry.job.State = JobCancelled
+	entry.job.UpdatedAt = r.now()
+	return true
+}
+
+// Close 取消全部未结束的任务，之后的 Submit 返回 ErrJobRegistryClosed。
+func (r *JobRegistry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	r.stop()
+	now := r.now()
+	for _, entry := range r.jobs {
+		if !entry.job.finished() {
+			entry.job.State = JobCancelled
+			entry.job.UpdatedAt = now
+		}
+	}
+}
+
+func (r *JobRegistry) evictLocked() {
+	cutoff := r.now().Add(-r.ttl)
+	for id, entry := range r.jobs {
+		if entry.job.finished() && entry.job.UpdatedAt.Before(cutoff) {
+			delete(r.jobs, id)
+		}
+	}
+}
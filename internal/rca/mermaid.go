@@ -0,0 +1,101 @@
+package rca
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 置信度分档，用于 Mermaid 中按档位着色。
+const (
+	mermaidHighConfidence   = 0.8
+	mermaidMediumConfidence = 0.5
+)
+
+// RenderPathMermaid 将候选及其告警扩散路径渲染为 Mermaid graph TD 流程图，候选按置信度分档着色。
+// 节点编号按候选、路径的出现顺序依次分配，相同结果总是得到相同输出，便于在文档中比对差异。
+// 边上标注子树内的去重告警数，与 RenderPathDOT 口径一致。
+func RenderPathMermaid(result Result) string {
+	r := mermaidRenderer{ids: make(map[string]string), linked: make(map[string]bool)}
+	r.b.WriteString("graph TD\n")
+	if window := windowLabel(result); window != "" {
+		fmt.Fprintf(&r.b, "  %%%% window: %s\n", window)
+	}
+	r.b.WriteString("  classDef high fill:#f66,stroke:#900,stroke-width:2px;\n")
+	r.b.WriteString("  classDef medium fill:#fc6,stroke:#960;\n")
+	r.b.WriteString("  classDef low fill:#ffc,stroke:#996;\n")
+	r.b.WriteString("  classDef impacted fill:#eee,stroke:#999;\n")
+
+	tiers := make(map[string]string, len(result.Candidates))
+	for _, cand := range result.Candidates {
+		id := r.node(cand.Node)
+		if _, ok := tiers[id]; !ok {
+			tiers[id] = confidenceTier(cand.Confidence)
+		}
+	}
+	for _, path := range result.Paths {
+		r.impacts(r.node(path.Candidate), path.Impacts)
+	}
+
+	for _, id := range r.order {
+		class, ok := tiers[id]
+		if !ok {
+			class = "impacted"
+		}
+		fmt.Fprintf(&r.b, "  class %s %s;\n", id, class)
+	}
+	return r.b.String()
+}
+
+type mermaidRenderer struct {
+	b      strings.Builder
+	ids    map[string]string
+	order  []string
+	linked map[string]bool
+}
+
+// node 为节点分配稳定编号，首次出现时输出声明。
+func (r *mermaidRenderer) node(ref NodeRef) string {
+	key := dotNodeID(ref)
+	if id, ok := r.ids[key]; ok {
+		return id
+	}
+	id := fmt.Sprintf("n%d", len(r.order))
+	r.ids[key] = id
+	r.order = append(r.order, id)
+	fmt.Fprintf(&r.b, "  %s[\"%s\"]\n", id, mermaidEscape(dotLabel(ref)))
+	return id
+}
+
+func (r *mermaidRenderer) impacts(parent string, impacts []PathImpact) {
+	for _, impact := range impacts {
+		child := r.node(impact.Node)
+		if edge := parent + "->" + child; !r.linked[edge] {
+			r.linked[edge] = true
+			fmt.Fprintf(&r.b, "  %s -->|\"%d events\"| %s\n", parent, impactEventCount(impact), child)
+		}
+		r.impacts(child, impact.Impacts)
+	}
+}
+
+func confidenceTier(confidence float64) string {
+	switch {
+	case confidence >= mermaidHighConfidence:
+		return "high"
+	case confidence >= mermaidMediumConfidence:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// mermaidEscape 使用 Mermaid 的实体写法转义标签中的特殊字符，换行转为 <br/>。
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(
+		"&", "#amp;",
+		`"`, "#quot;",
+		"<", "#lt;",
+		">", "#gt;",
+		"\r", "",
+		"\n", "<br/>",
+	).Replace(s)
+}
@@ -0,0 +1,220 @@
+package rca
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/graph"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// 邻域查询的默认与最大范围。
+const (
+	DefaultNeighborhoodDepth = 1
+	MaxNeighborhoodDepth     = 4
+	DefaultNeighborhoodNodes = 200
+)
+
+// ErrNodeNotFound 表示 cmdb_key 没有对应的拓扑节点。
+var ErrNodeNotFound = errors.New("topology node not found")
+
+// neighborhoodQueries 按默认 Schema 与深度预先渲染，每一跳展开为一段子查询，深度不能作为参数传入。
+var neighborhoodQueries = mustRenderNeighborhoodQueries(domain.DefaultSchema())
+
+// NeighborhoodQuery 描述一次邻域查询，Depth 与 MaxNodes 为 0 时使用默认值。
+type NeighborhoodQuery struct {
+	Key string
+	// Depth 为展开的最大跳数，取值 1 到 MaxNeighborhoodDepth。
+	Depth int
+	// RelTypes 非空时只沿这些类型的关系展开，返回的关系也只包含这些类型。
+	RelTypes []string
+	// MaxNodes 为除起点外最多返回的节点数，按距离由近到远截取。
+	MaxNodes int
+}
+
+// Relationship 为邻域中的一条关系，Source 与 Target 为两端节点的键。
+type Relationship struct {
+	Type   string         `json:"type"`
+	Source string         `json:"source"`
+	Target string         `json:"target"`
+	Props  map[string]any `json:"props,omitempty"`
+}
+
+// Neighborhood 为某个节点周围的拓扑，Nodes 包含起点，Truncated 表示节点数超过上限被截断。
+type Neighborhood struct {
+	Root          NodeRef        `json:"root"`
+	Depth         int            `json:"depth"`
+	Nodes         []NodeRef      `json:"nodes"`
+	Relationships []Relationship `json:"relationships"`
+	Truncated     bool           `json:"truncated"`
+}
+
+// NeighborhoodReader 查询节点周围的拓扑。
+type NeighborhoodReader interface {
+	Neighborhood(ctx context.Context, query NeighborhoodQuery) (Neighborhood, error)
+}
+
+// GraphNeighborhoodReader 基于 Neo4j 只读查询实现 NeighborhoodReader，软删除的节点与关系不会出现在结果中。
+type GraphNeighborhoodReader struct {
+	reader  graph.Reader
+	schema  domain.Schema
+	queries map[int]string
+}
+
+// NeighborhoodOption 配置 GraphNeighborhoodReader 的可选项。
+type NeighborhoodOption func(*GraphNeighborhoodReader)
+
+// WithNeighborhoodSchema 按 schema 匹配图中的实际标签与关系类型，返回结果中的标签与关系类型仍为规范名。
+func WithNeighborhoodSchema(schema domain.Schema) NeighborhoodOption {
+	return func(r *GraphNeighborhoodReader) {
+		r.schema = schema
+	}
+}
+
+// NewGraphNeighborhoodReader 构建基于 Neo4j 的邻域查询。
+func NewGraphNeighborhoodReader(reader graph.Reader, opts ...NeighborhoodOption) *GraphNeighborhoodReader {
+	r := &GraphNeighborhoodReader{reader: reader}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(r)
+		}
+	}
+	r.queries = neighborhoodQueries
+	if !r.schema.IsDefault() {
+		r.queries = mustRenderNeighborhoodQueries(r.schema)
+	}
+	return r
+}
+
+// Neighborhood 实现 NeighborhoodReader，起点不存在时返回 ErrNodeNotFound。
+func (r *GraphNeighborhoodReader) Neighborhood(ctx context.Context, q NeighborhoodQuery) (Neighborhood, error) {
+	key := strings.TrimSpace(q.Key)
+	if key == "" {
+		return Neighborhood{}, errors.New("cmdb_key is required")
+	}
+	depth := q.Depth
+	if depth == 0 {
+		depth = DefaultNeighborhoodDepth
+	}
+	if depth < 1 || depth > MaxNeighborhoodDepth {
+		return Neighborhood{}, fmt.Errorf("depth must be between 1 and %d", MaxNeighborhoodDepth)
+	}
+	limit := q.MaxNodes
+	if limit <= 0 {
+		limit = DefaultNeighborhoodNodes
+	}
+	types := make([]string, 0, len(q.RelTypes))
+	for _, t := range q.RelTypes {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, r.schema.Rel(t))
+		}
+	}
+
+	// 每跳多保留一个节点，总数超过 limit 时即可判定截断
+	params := map[string]any{"key": key, "types": types, "limit": limit, "hop_limit": limit + 1}
+	records, err := r.reader.RunRead(ctx, r.queries[depth], params)
+	if err != nil {
+		return Neighborhood{}, fmt.Errorf("query neighborhood of %s failed: %w", key, err)
+	}
+	canonicalizeRecords(r.schema, records)
+	if len(records) == 0 {
+		return Neighborhood{}, fmt.Errorf("%s: %w", key, ErrNodeNotFound)
+	}
+	result, err := neighborhoodFromRecord(records[0])
+	if err != nil {
+		return Neighborhood{}, fmt.Errorf("decode neighborhood of %s failed: %w", key, err)
+	}
+	result.Depth = depth
+	return result, nil
+}
+
+// neighborhoodFromRecord 转换 neighborhood.cql 的返回行，关系两端按节点 element ID 映射为节点键。
+func neighborhoodFromRecord(record map[string]any) (Neighborhood, error) {
+	root, err := nodeFromRecord(record, "root")
+	if err != nil {
+		return Neighborhood{}, err
+	}
+	if root == nil {
+		return Neighborhood{}, errors.New("field root is empty")
+	}
+	result := Neighborhood{Root: root.NodeRef, Nodes: []NodeRef{root.NodeRef}}
+	keys := map[string]string{elementID(record["root"]): root.Key}
+
+	rawNodes, _ := record["nodes"].([]any)
+	for _, raw := range rawNodes {
+		node, ok := raw.(neo4j.Node)
+		if !ok {
+			return Neighborhood{}, fmt.Errorf("nodes item is %T, not neo4j node", raw)
+		}
+		ref := nodeFromNeo4j(node).NodeRef
+		keys[node.ElementId] = ref.Key
+		result.Nodes = append(result.Nodes, ref)
+	}
+	sort.Slice(result.Nodes, func(i, j int) bool { return result.Nodes[i].Key < result.Nodes[j].Key })
+
+	rawRels, _ := record["rels"].([]any)
+	result.Relationships = make([]Relationship, 0, len(rawRels))
+	for _, raw := range rawRels {
+		rel, ok := raw.(neo4j.Relationship)
+		if !ok {
+			return Neighborhood{}, fmt.Errorf("rels item is %T, not neo4j relationship", raw)
+		}
+		source, sok := keys[rel.StartElementId]
+		target, tok := keys[rel.EndElementId]
+		if !sok || !tok {
+			continue
+		}
+		result.Relationships = append(result.Relationships, Relationship{Type: rel.Type, Source: source, Target: target, Props: rel.Props})
+	}
+	sort.Slice(result.Relationships, func(i, j int) bool {
+		a, b := result.Relationships[i], result.Relationships[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return a.Type < b.Type
+	})
+	result.Truncated, _ = record["truncated"].(bool)
+	return result, nil
+}
+
+func elementID(raw any) string {
+	if node, ok := raw.(neo4j.Node); ok {
+		return node.ElementId
+	}
+	return ""
+}
+
+func mustRenderNeighborhoodQueries(schema domain.Schema) map[int]string {
+	tmpl, err := parseQueryTemplates(schema, template.FuncMap{
+		"param": func(name string) string { return "$" + name },
+		"keep":  func() string { return "" },
+	})
+	if err != nil {
+		panic(err)
+	}
+	labels := make([]string, len(knownNodeTypes))
+	for i, t := range knownNodeTypes {
+		labels[i] = schema.Label(string(t))
+	}
+	queries := make(map[int]string, MaxNeighborhoodDepth)
+	for depth := 1; depth <= MaxNeighborhoodDepth; depth++ {
+		var sb strings.Builder
+		data := struct {
+			Labels string
+			Hops   []int
+		}{Labels: strings.Join(labels, "|"), Hops: make([]int, depth)}
+		if err := tmpl.ExecuteTemplate(&sb, "neighborhood.cql", data); err != nil {
+			panic(fmt.Errorf("render rca query neighborhood.cql: %w", err))
+		}
+		queries[depth] = sb.String()
+	}
+	return queries
+}
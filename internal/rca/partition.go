@@ -0,0 +1,233 @@
+package rca
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/graph"
+	"go.uber.org/zap"
+)
+
+// Partition 为带 CIDR 的网络分区，用于按 IP 反查所属分区。
+type Partition struct {
+	Key    string
+	Name   string
+	IDC    string
+	Prefix netip.Prefix
+}
+
+// PartitionIndex 按前缀长度分桶索引网络分区，查询时从最长前缀开始逐桶按掩码后的网段精确匹配，
+// 耗时只与不同前缀长度的个数有关，与分区数量无关；CIDR 重叠时自然取最具体的分区。
+type PartitionIndex struct {
+	// buckets 按前缀长度从长到短排列，IPv4 与 IPv6 分开
+	buckets []partitionBucket
+	size    int
+}
+
+type partitionBucket struct {
+	bits     int
+	is4      bool
+	networks map[netip.Prefix]Partition
+}
+
+// NewPartitionIndex 基于 partitions 构建索引，无效前缀被忽略；网段完全相同时保留 Key 较小者，保证结果稳定。
+func NewPartitionIndex(partitions []Partition) *PartitionIndex {
+	type bucketKey struct {
+		bits int
+		is4  bool
+	}
+	byBits := make(map[bucketKey]map[netip.Prefix]Partition)
+	idx := &PartitionIndex{}
+	for _, part := range partitions {
+		if !part.Prefix.IsValid() {
+			continue
+		}
+		part.Prefix = part.Prefix.Masked()
+		key := bucketKey{bits: part.Prefix.Bits(), is4: part.Prefix.Addr().Is4()}
+		networks, ok := byBits[key]
+		if !ok {
+			networks = make(map[netip.Prefix]Partition)
+			byBits[key] = networks
+		}
+		if existing, dup := networks[part.Prefix]; dup {
+			if existing.Key <= part.Key {
+				continue
+			}
+		} else {
+			idx.size++
+		}
+		networks[part.Prefix] = part
+	}
+	for key, networks := range byBits {
+		idx.buckets = append(idx.buckets, partitionBucket{bits: key.bits, is4: key.is4, networks: networks})
+	}
+	sort.Slice(idx.buckets, func(i, j int) bool { return idx.buckets[i].bits > idx.buckets[j].bits })
+	return idx
+}
+
+// Len 返回索引中的分区数。
+func (idx *PartitionIndex) Len() int {
+	if idx == nil {
+		return 0
+	}
+	return idx.size
+}
+
+// Lookup 返回 CIDR 包含 ip 的最具体分区，ip 无效或没有分区包含它时 ok=false；IPv4 映射的 IPv6 地址按 IPv4 处理。
+func (idx *PartitionIndex) Lookup(ip string) (Partition, bool) {
+	if idx == nil || len(idx.buckets) == 0 {
+		return Partition{}, false
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return Partition{}, false
+	}
+	addr = addr.Unmap()
+	for _, bucket := range idx.buckets {
+		if bucket.is4 != addr.Is4() {
+			continue
+		}
+		network, err := addr.Prefix(bucket.bits)
+		if err != nil {
+			continue
+		}
+		if part, ok := bucket.networks[network]; ok {
+			return part, true
+		}
+	}
+	return Partition{}, false
+}
+
+// partitionIndexQueryTemplate 读取所有带 CIDR 的存活网络分区。
+const partitionIndexQueryTemplate = `
+MATCH (np:{{label "NetPartition"}})
+WHERE coalesce(np.cidr, '') <> '' AND coalesce(np.deleted, false) = false
+RETURN np.cmdb_key AS key, np.name AS name, np.idc AS idc, np.cidr AS cidr`
+
+// LoadPartitionIndex 从图中读取带 CIDR 的网络分区并构建索引，网络分区按 schema 中的实际标签匹配，
+// 无法解析的 CIDR 回调 onInvalid 后跳过。
+func LoadPartitionIndex(ctx context.Context, client graph.Reader, schema domain.Schema, onInvalid func(key, cidr string, err error)) (*PartitionIndex, error) {
+	query := mustRenderSchemaQuery(schema, "partition_index", partitionIndexQueryTemplate)
+	records, err := client.RunRead(ctx, query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("load partition cidrs: %w", err)
+	}
+	partitions := make([]Partition, 0, len(records))
+	for _, record := range records {
+		key := firstNonEmpty(record["key"])
+		cidr := firstNonEmpty(record["cidr"])
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			if onInvalid != nil {
+				onInvalid(key, cidr, err)
+			}
+			continue
+		}
+		partitions = append(partitions, Partition{
+			Key:    key,
+			Name:   firstNonEmpty(record["name"]),
+			IDC:    firstNonEmpty(record["idc"]),
+			Prefix: prefix,
+		})
+	}
+	return NewPartitionIndex(partitions), nil
+}
+
+// Index 返回索引本身，使 *PartitionIndex 可直接作为 PartitionSource 使用。
+func (idx *PartitionIndex) Index() *PartitionIndex {
+	return idx
+}
+
+// PartitionSource 提供当前的网络分区 CIDR 索引，返回 nil 时不按 IP 回退到分区。
+type PartitionSource interface {
+	Index() *PartitionIndex
+}
+
+const (
+	// DefaultPartitionRefreshInterval 为重建网络分区 CIDR 索引的默认间隔。
+	DefaultPartitionRefreshInterval = 5 * time.Minute
+	// partitionLoadTimeout 限制单次加载网络分区 CIDR 的耗时。
+	partitionLoadTimeout = 10 * time.Second
+)
+
+// PartitionRefresher 按间隔从图中重建网络分区 CIDR 索引并原子替换，同步新增或修改的分区无需重启即可生效；
+// 重建失败时保留上一次的索引。
+type PartitionRefresher struct {
+	client   graph.Reader
+	schema   domain.Schema
+	interval time.Duration
+	logger   *zap.Logger
+	index    atomic.Pointer[PartitionIndex]
+}
+
+// NewPartitionRefresher 构建索引刷新器，interval 为 0 时使用 DefaultPartitionRefreshInterval，为负数时只在调用 Refresh 时重建。
+func NewPartitionRefresher(client graph.Reader, schema domain.Schema, interval time.Duration, logger *zap.Logger) *PartitionRefresher {
+	if interval == 0 {
+		interval = DefaultPartitionRefreshInterval
+	}
+	return &PartitionRefresher{client: client, schema: schema, interval: interval, logger: logger}
+}
+
+// Index 返回当前索引，尚未加载成功时返回 nil。
+func (r *PartitionRefresher) Index() *PartitionIndex {
+	if r == nil {
+		return nil
+	}
+	return r.index.Load()
+}
+
+// Refresh 从图中重建索引并替换当前索引，失败时保留原索引并返回错误。
+func (r *PartitionRefresher) Refresh(ctx context.Context) error {
+	if r == nil || r.client == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, partitionLoadTimeout)
+	defer cancel()
+	index, err := LoadPartitionIndex(ctx, r.client, r.schema, func(key, cidr string, err error) {
+		if r.logger != nil {
+			r.logger.Warn("skip invalid partition cidr", zap.String("partition", key), zap.String("cidr", cidr), zap.Error(err))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	r.index.Store(index)
+	return nil
+}
+
+// Start 在后台按间隔重建索引，返回停止函数；interval 为负数时不启动。
+func (r *PartitionRefresher) Start(parent context.Context) context.CancelFunc {
+	if r == nil || r.client == nil || r.interval < 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(parent)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := r.Refresh(ctx); err != nil && r.logger != nil {
+				r.logger.Warn("refresh partition cidr index failed, keep previous index", zap.Error(err))
+			}
+		}
+	}()
+	if r.logger != nil {
+		r.logger.Info("partition cidr index refresher started", zap.Duration("interval", r.interval))
+	}
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,80 @@
+package rca
+
+import "sort"
+
+// MergePaths 合并多组链路（如重叠窗口或重复分析的结果），同一候选只保留一棵影响树：
+// 各层影响按节点 key 递归合并，事件按 ID 去重。候选与影响保持首次出现的顺序，需要时再调用 SortPaths。
+func MergePaths(groups ...[]AlarmPath) []AlarmPath {
+	var total int
+	for _, paths := range groups {
+		total += len(paths)
+	}
+	index := make(map[string]int, total)
+	merged := make([]AlarmPath, 0, total)
+	for _, paths := range groups {
+		for _, path := range paths {
+			pos, ok := index[path.Candidate.Key]
+			if !ok {
+				index[path.Candidate.Key] = len(merged)
+				merged = append(merged, AlarmPath{Candidate: path.Candidate, Impacts: mergeImpacts(nil, path.Impacts)})
+				continue
+			}
+			merged[pos].Impacts = mergeImpacts(merged[pos].Impacts, path.Impacts)
+		}
+	}
+	return merged
+}
+
+// mergeImpacts 将 b 按节点 key 合并进 a 并返回新切片，同 key 的子影响递归合并，不修改入参。
+func mergeImpacts(a, b []PathImpact) []PathImpact {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	index := make(map[string]int, len(a)+len(b))
+	out := make([]PathImpact, 0, len(a)+len(b))
+	for _, list := range [][]PathImpact{a, b} {
+		for _, impact := range list {
+			pos, ok := index[impact.Node.Key]
+			if !ok {
+				index[impact.Node.Key] = len(out)
+				out = append(out, PathImpact{
+					Node:    impact.Node,
+					Events:  mergeEventLists(nil, impact.Events),
+					Impacts: mergeImpacts(nil, impact.Impacts),
+				})
+				continue
+			}
+			existing := &out[pos]
+			existing.Events = mergeEventLists(existing.Events, impact.Events)
+			existing.Impacts = mergeImpacts(existing.Impacts, impact.Impacts)
+		}
+	}
+	return out
+}
+
+// mergeEventLists 按事件 ID 合并两组事件引用，重复时保留先出现的一条，结果按发生时间排序。
+func mergeEventLists(a, b []AlarmEventRef) []AlarmEventRef {
+	seen := make(map[string]struct{}, len(a)+len(b))
+	out := make([]AlarmEventRef, 0, len(a)+len(b))
+	for _, list := range [][]AlarmEventRef{a, b} {
+		for _, ref := range list {
+			if _, ok := seen[ref.ID]; ok {
+				continue
+			}
+			seen[ref.ID] = struct{}{}
+			out = append(out, ref)
+		}
+	}
+	sortEventRefs(out)
+	return out
+}
+
+// sortEventRefs 按发生时间排序事件引用，时间相同时按 ID 决胜。
+func sortEventRefs(events []AlarmEventRef) {
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Occurred.Equal(events[j].Occurred) {
+			return events[i].ID < events[j].ID
+		}
+		return events[i].Occurred.Before(events[j].Occurred)
+	})
+}
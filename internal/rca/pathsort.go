@@ -0,0 +1,110 @@
+package rca
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// PathSort 控制链路及各层影响的输出顺序。
+type PathSort string
+
+const (
+	// PathSortByKey 按节点 key 排序，为默认顺序。
+	PathSortByKey PathSort = "byKey"
+	// PathSortByTime 按最早告警时间排序，先出问题的排在前面。
+	PathSortByTime PathSort = "byTime"
+	// PathSortByImpactCount 按影响的告警数量从多到少排序。
+	PathSortByImpactCount PathSort = "byImpactCount"
+)
+
+func (s PathSort) validate() error {
+	switch s {
+	case "", PathSortByKey, PathSortByTime, PathSortByImpactCount:
+		return nil
+	}
+	return fmt.Errorf("unknown path_sort %q", s)
+}
+
+// SortPaths 按指定方式原地排序链路及其各层影响，并列时按 key 决胜；可用于合并多个分析器的链路后统一排序。
+func SortPaths(paths []AlarmPath, mode PathSort) {
+	for i := range paths {
+		sortImpacts(paths[i].Impacts, mode)
+	}
+	sort.SliceStable(paths, func(i, j int) bool {
+		a, b := paths[i], paths[j]
+		switch mode {
+		case PathSortByTime:
+			ta, tb := earliestInImpacts(a.Impacts), earliestInImpacts(b.Impacts)
+			if !ta.Equal(tb) {
+				return earlier(ta, tb)
+			}
+		case PathSortByImpactCount:
+			ca, cb := impactCount(a.Impacts), impactCount(b.Impacts)
+			if ca != cb {
+				return ca > cb
+			}
+		}
+		return a.Candidate.Key < b.Candidate.Key
+	})
+}
+
+func sortImpacts(impacts []PathImpact, mode PathSort) {
+	for i := range impacts {
+		sortImpacts(impacts[i].Impacts, mode)
+	}
+	sort.SliceStable(impacts, func(i, j int) bool {
+		a, b := impacts[i], impacts[j]
+		switch mode {
+		case PathSortByTime:
+			ta, tb := earliestEvent(a), earliestEvent(b)
+			if !ta.Equal(tb) {
+				return earlier(ta, tb)
+			}
+		case PathSortByImpactCount:
+			if len(a.Events) != len(b.Events) {
+				return len(a.Events) > len(b.Events)
+			}
+		}
+		return a.Node.Key < b.Node.Key
+	})
+}
+
+// earlier 比较两个时间，零值视为未知并排在最后。
+func earlier(a, b time.Time) bool {
+	if a.IsZero() || b.IsZero() {
+		return !a.IsZero()
+	}
+	return a.Before(b)
+}
+
+func earliestEvent(impact PathImpact) time.Time {
+	var first time.Time
+	for _, evt := range impact.Events {
+		if earlier(evt.Occurred, first) {
+			first = evt.Occurred
+		}
+	}
+	if child := earliestInImpacts(impact.Impacts); earlier(child, first) {
+		first = child
+	}
+	return first
+}
+
+func earliestInImpacts(impacts []PathImpact) time.Time {
+	var first time.Time
+	for _, impact := range impacts {
+		if t := earliestEvent(impact); earlier(t, first) {
+			first = t
+		}
+	}
+	return first
+}
+
+func impactCount(impacts []PathImpact) int {
+	total := 0
+	for _, impact := range impacts {
+		total += len(impact.Events)
+	}
+	return total
+}
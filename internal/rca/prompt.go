@@ -86,6 +86,7 @@ func RenderPrompt(result Result, opts PromptOptions) string {
 
 	data := promptTemplateData{
 		Options:     opts,
+		Window:      windowLabel(result),
 		Payload:     trimmed,
 		PayloadJSON: string(payload),
 	}
@@ -105,6 +106,7 @@ type promptPayload struct {
 
 type promptTemplateData struct {
 	Options     PromptOptions
+	Window      string
 	Payload     promptPayload
 	PayloadJSON string
 }
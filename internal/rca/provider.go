@@ -2,11 +2,19 @@ package rca
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
+	"cmdb2neo/internal/domain"
 	"cmdb2neo/internal/graph"
+	"cmdb2neo/internal/metrics"
+	"cmdb2neo/pkg/logging"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
 // TopologyProvider 提供拓扑链路和部署信息。
@@ -15,56 +23,540 @@ type TopologyProvider interface {
 	ResolveEvent(ctx context.Context, event AlarmEvent) ([]Node, error)
 }
 
+// BatchTopologyProvider 为可选能力，一次解析多条告警的拓扑链路，返回结果与 events 按下标一一对应；
+// Analyzer 在 provider 实现该接口时优先使用，否则逐条调用 ResolveEvent。
+// 只有部分事件无法解析时应返回其余事件的链路，并以 EventErrors 报告失败的事件。
+type BatchTopologyProvider interface {
+	ResolveEvents(ctx context.Context, events []AlarmEvent) ([][]Node, error)
+}
+
+// EventErrors 为批量解析中失败事件的错误，按事件下标索引。
+type EventErrors map[int]error
+
+func (e EventErrors) Error() string {
+	indexes := make([]int, 0, len(e))
+	for i := range e {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	msgs := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		msgs = append(msgs, e[i].Error())
+	}
+	return fmt.Sprintf("%d events unresolved: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// layerQueryTemplate 按标签分别匹配计算层节点以命中 ip 索引。
+const layerQueryTemplate = `
+CALL {
+  MATCH (n:{{label "VirtualMachine"}}) WHERE n.ip = $ip RETURN n
+  UNION
+  MATCH (n:{{label "HostMachine"}}) WHERE n.ip = $ip RETURN n
+  UNION
+  MATCH (n:{{label "PhysicalMachine"}}) WHERE n.ip = $ip RETURN n
+  UNION
+  MATCH (n:{{label "Container"}}) WHERE n.ip = $ip RETURN n
+}
+WITH n WHERE coalesce(n.deleted, false) = false
+RETURN DISTINCT labels(n) AS labels
+`
+
+// appInstanceQueryTemplates 分别统计应用部署在虚拟机、宿主机、物理机、容器上且位于指定机房的实例数，链路上的墓碑节点与关系均不计入。
+var appInstanceQueryTemplates = []string{
+	`
+MATCH (app:{{label "App"}} {name: $app})-[d:{{rel "DEPLOYED_ON"}}]->(vm:{{label "VirtualMachine"}})
+WHERE coalesce(d.deleted, false) = false AND coalesce(vm.deleted, false) = false
+MATCH (vm)<-[hv:{{rel "HOSTS_VM"}}]-(host)
+WHERE (host:{{label "HostMachine"}} OR host:{{label "PhysicalMachine"}})
+  AND coalesce(hv.deleted, false) = false AND coalesce(host.deleted, false) = false
+MATCH (host)<-[hh:{{rel "HAS_HOST"}}|{{rel "HAS_PHYSICAL"}}]-(np:{{label "NetPartition"}})<-[hp:{{rel "HAS_PARTITION"}}]-(idc:{{label "IDC"}} {name: $idc})
+WHERE coalesce(hh.deleted, false) = false AND coalesce(np.deleted, false) = false
+  AND coalesce(hp.deleted, false) = false AND coalesce(idc.deleted, false) = false
+RETURN COUNT(DISTINCT vm) AS total
+`,
+	`
+MATCH (app:{{label "App"}} {name: $app})-[d:{{rel "DEPLOYED_ON"}}]->(host:{{label "HostMachine"}})
+WHERE coalesce(d.deleted, false) = false AND coalesce(host.deleted, false) = false
+MATCH (host)<-[hh:{{rel "HAS_HOST"}}]-(np:{{label "NetPartition"}})<-[hp:{{rel "HAS_PARTITION"}}]-(idc:{{label "IDC"}} {name: $idc})
+WHERE coalesce(hh.deleted, false) = false AND coalesce(np.deleted, false) = false
+  AND coalesce(hp.deleted, false) = false AND coalesce(idc.deleted, false) = false
+RETURN COUNT(DISTINCT host) AS total
+`,
+	`
+MATCH (app:{{label "App"}} {name: $app})-[d:{{rel "DEPLOYED_ON"}}]->(phy:{{label "PhysicalMachine"}})
+WHERE coalesce(d.deleted, false) = false AND coalesce(phy.deleted, false) = false
+MATCH (np:{{label "NetPartition"}})-[hh:{{rel "HAS_PHYSICAL"}}]->(phy)
+WHERE coalesce(hh.deleted, false) = false AND coalesce(np.deleted, false) = false
+MATCH (np)<-[hp:{{rel "HAS_PARTITION"}}]-(idc:{{label "IDC"}} {name: $idc})
+WHERE coalesce(hp.deleted, false) = false AND coalesce(idc.deleted, false) = false
+RETURN COUNT(DISTINCT phy) AS total
+`,
+	`
+MATCH (app:{{label "App"}} {name: $app})-[d:{{rel "DEPLOYED_ON"}}]->(ctr:{{label "Container"}})
+WHERE coalesce(d.deleted, false) = false AND coalesce(ctr.deleted, false) = false
+MATCH (ctr)-[ro:{{rel "RUNS_ON"}}]->(node)
+WHERE (node:{{label "VirtualMachine"}} OR node:{{label "HostMachine"}})
+  AND coalesce(ro.deleted, false) = false AND coalesce(node.deleted, false) = false
+MATCH hosted = (node)<-[:{{rel "HOSTS_VM"}}*0..1]-(host)
+WHERE (host:{{label "HostMachine"}} OR host:{{label "PhysicalMachine"}})
+  AND coalesce(host.deleted, false) = false AND all(r IN relationships(hosted) WHERE coalesce(r.deleted, false) = false)
+MATCH (host)<-[hh:{{rel "HAS_HOST"}}|{{rel "HAS_PHYSICAL"}}]-(np:{{label "NetPartition"}})<-[hp:{{rel "HAS_PARTITION"}}]-(idc:{{label "IDC"}} {name: $idc})
+WHERE coalesce(hh.deleted, false) = false AND coalesce(np.deleted, false) = false
+  AND coalesce(hp.deleted, false) = false AND coalesce(idc.deleted, false) = false
+RETURN COUNT(DISTINCT ctr) AS total
+`,
+}
+
 // GraphProvider 基于 Neo4j 的实现。
 type GraphProvider struct {
-	client graph.Reader
+	client     graph.Reader
+	onConflict func(LayerConflict)
+	// cache 为 nil 时每次都查询图
+	cache    *chainCache
+	recorder metrics.Recorder
+	tracer   trace.Tracer
+	// partitions 为 nil 或返回 nil 索引时不按 IP 回退到网络分区
+	partitions PartitionSource
+	schema     domain.Schema
+	// 以下查询按 schema 在构造时渲染
+	queries         map[NodeType]string
+	batchQueries    map[NodeType]string
+	layerQuery      string
+	instanceQueries []string
+}
+
+// LayerConflict 描述同一 IP 在多个承载层同时出现的情况。
+type LayerConflict struct {
+	IP       string     `json:"ip"`
+	Expected NodeType   `json:"expected"`
+	Layers   []NodeType `json:"layers"`
+}
+
+// GraphProviderOption 用于定制 GraphProvider。
+type GraphProviderOption func(*GraphProvider)
+
+// WithConflictReporter 在 IP 命中多个承载层时回调，未配置时不做额外查询。
+func WithConflictReporter(fn func(LayerConflict)) GraphProviderOption {
+	return func(p *GraphProvider) {
+		p.onConflict = fn
+	}
+}
+
+// WithCache 开启拓扑链路缓存，同一窗口内重复出现的主机、VM 直接命中内存。
+func WithCache(cfg CacheConfig) GraphProviderOption {
+	return func(p *GraphProvider) {
+		p.cache = newChainCache(cfg)
+	}
+}
+
+// WithProviderRecorder 上报链路缓存的命中与未命中次数。
+func WithProviderRecorder(recorder metrics.Recorder) GraphProviderOption {
+	return func(p *GraphProvider) {
+		p.recorder = recorder
+	}
+}
+
+// WithProviderTracerProvider 为按层级解析链路的查询开启 span，记录起始层级与事件数。
+func WithProviderTracerProvider(provider trace.TracerProvider) GraphProviderOption {
+	return func(p *GraphProvider) {
+		p.tracer = graph.Tracer(provider)
+	}
 }
 
-func NewGraphProvider(client graph.Reader) *GraphProvider {
-	return &GraphProvider{client: client}
+// WithPartitionIndex 在链路缺少网络分区时按事件 IP 在 CIDR 索引中查找所属分区补全，
+// 起始层级未命中的事件也能归属到分区及其 IDC。
+func WithPartitionIndex(index *PartitionIndex) GraphProviderOption {
+	return func(p *GraphProvider) {
+		if index != nil {
+			p.partitions = index
+		}
+	}
+}
+
+// WithPartitionSource 与 WithPartitionIndex 相同，但每次查找时从 source 取当前索引，
+// 配合 PartitionRefresher 可在不重建 provider 的情况下更新分区。
+func WithPartitionSource(source PartitionSource) GraphProviderOption {
+	return func(p *GraphProvider) {
+		p.partitions = source
+	}
+}
+
+// WithSchema 按 schema 匹配图中的实际标签与关系类型，返回的节点类型与标签仍为规范名。
+func WithSchema(schema domain.Schema) GraphProviderOption {
+	return func(p *GraphProvider) {
+		p.schema = schema
+	}
+}
+
+func NewGraphProvider(client graph.Reader, opts ...GraphProviderOption) *GraphProvider {
+	p := &GraphProvider{client: client}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(p)
+		}
+	}
+	if p.tracer == nil {
+		p.tracer = graph.Tracer(nil)
+	}
+	p.recorder = metrics.OrNop(p.recorder)
+	p.queries, p.batchQueries = resolveQueries, batchResolveQueries
+	if !p.schema.IsDefault() {
+		p.queries = mustRenderResolveQueries(false, p.schema)
+		p.batchQueries = mustRenderResolveQueries(true, p.schema)
+	}
+	p.layerQuery = mustRenderSchemaQuery(p.schema, "match_layers", layerQueryTemplate)
+	p.instanceQueries = make([]string, len(appInstanceQueryTemplates))
+	for i, text := range appInstanceQueryTemplates {
+		p.instanceQueries[i] = mustRenderSchemaQuery(p.schema, "app_instances", text)
+	}
+	return p
+}
+
+// startResolveSpan 为从 from 层解析 events 条事件的查询开启 span。
+func (p *GraphProvider) startResolveSpan(ctx context.Context, from NodeType, events int) (context.Context, trace.Span) {
+	return p.tracer.Start(ctx, "rca.resolve", trace.WithAttributes(
+		attribute.String("rca.node_type", string(from)),
+		attribute.Int("rca.event_count", events),
+	))
 }
 
 func (p *GraphProvider) ResolveEvent(ctx context.Context, event AlarmEvent) ([]Node, error) {
+	event = normalizeEventIP(event)
 	var chain Chain
 	var err error
 	switch event.ServerType {
 	case ServerTypeHost:
-		chain, err = p.resolveFromHost(ctx, event)
+		chain, err = p.resolveFrom(ctx, NodeTypeHostMachine, event)
 	case ServerTypePhysical:
-		chain, err = p.resolveFromPhysical(ctx, event)
+		chain, err = p.resolveFrom(ctx, NodeTypePhysicalMachine, event)
+	case ServerTypeVM:
+		chain, err = p.resolveFromGuest(ctx, NodeTypeVirtualMachine, event)
+	case ServerTypeContainer:
+		chain, err = p.resolveFromGuest(ctx, NodeTypeContainer, event)
 	default:
-		chain, err = p.resolveFromAppOrVM(ctx, event)
+		chain, err = p.resolveFrom(ctx, NodeTypeApp, event)
 	}
-	if err != nil {
+	if err != nil && !errors.Is(err, errNotFound) {
+		return nil, err
+	}
+	chain, attached, attachErr := p.attachPartition(ctx, event, chain)
+	if attachErr != nil {
+		return nil, attachErr
+	}
+	if err != nil && !attached {
 		return nil, err
 	}
+	p.reportConflict(ctx, event)
 	return chainToNodes(chain), nil
 }
 
-func (p *GraphProvider) ListAppInstances(ctx context.Context, appName string, datacenter string) (int, error) {
-	queries := []string{
-		`
-MATCH (app:App {name: $app})-[:DEPLOYED_ON]->(vm:VirtualMachine)
-MATCH (vm)<-[:HOSTS_VM]-(host:HostMachine)
-MATCH (host)<-[:HAS_HOST]-(np:NetPartition)<-[:HAS_PARTITION]-(idc:IDC {name: $idc})
-RETURN COUNT(DISTINCT vm) AS total
-`,
-		`
-MATCH (app:App {name: $app})-[:DEPLOYED_ON]->(host:HostMachine)
-MATCH (host)<-[:HAS_HOST]-(np:NetPartition)<-[:HAS_PARTITION]-(idc:IDC {name: $idc})
-RETURN COUNT(DISTINCT host) AS total
-`,
-		`
-MATCH (app:App {name: $app})-[:DEPLOYED_ON]->(phy:PhysicalMachine)
-MATCH (np:NetPartition)-[:HAS_PHYSICAL]->(phy)
-MATCH (np)<-[:HAS_PARTITION]-(idc:IDC {name: $idc})
-RETURN COUNT(DISTINCT phy) AS total
-`,
+// CacheStats 返回缓存命中统计，未开启缓存时为零值。
+func (p *GraphProvider) CacheStats() CacheStats {
+	if p.cache == nil {
+		return CacheStats{}
+	}
+	return p.cache.stats()
+}
+
+// ResolveEvents 按起始层级分组，每个层级只发一次 UNWIND 查询；VM、容器未命中的事件再并入按应用名解析的批次，
+// 回退语义与 ResolveEvent 一致，未命中的事件汇总为 EventErrors 与其余链路一并返回。
+func (p *GraphProvider) ResolveEvents(ctx context.Context, events []AlarmEvent) ([][]Node, error) {
+	events = normalizeEventIPs(events)
+	groups := make(map[NodeType][]int)
+	for i, evt := range events {
+		from := startLayer(evt)
+		groups[from] = append(groups[from], i)
+	}
+	chains := make([]Chain, len(events))
+	failed := make(EventErrors)
+	// App 必须最后解析，以便接收 VM、容器层未命中的事件
+	for _, from := range []NodeType{NodeTypeVirtualMachine, NodeTypeContainer, NodeTypeHostMachine, NodeTypePhysicalMachine, NodeTypeApp} {
+		indexes := groups[from]
+		if len(indexes) == 0 {
+			continue
+		}
+		found, err := p.resolveBatch(ctx, from, events, indexes)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range indexes {
+			chain, ok := found[i]
+			switch {
+			case ok:
+				chains[i] = chain
+			case from == NodeTypeVirtualMachine || from == NodeTypeContainer:
+				groups[NodeTypeApp] = append(groups[NodeTypeApp], i)
+			default:
+				failed[i] = notFoundError(from, events[i])
+			}
+		}
+	}
+	if err := p.attachPartitions(ctx, events, chains, failed); err != nil {
+		return nil, err
+	}
+
+	out := make([][]Node, len(events))
+	for i, evt := range events {
+		if _, ok := failed[i]; ok {
+			continue
+		}
+		p.reportConflict(ctx, evt)
+		out[i] = chainToNodes(chains[i])
+	}
+	if len(failed) > 0 {
+		return out, failed
+	}
+	return out, nil
+}
+
+// startLayer 返回事件解析的起始层级，与 ResolveEvent 的分支保持一致。
+func startLayer(event AlarmEvent) NodeType {
+	switch event.ServerType {
+	case ServerTypeHost:
+		return NodeTypeHostMachine
+	case ServerTypePhysical:
+		return NodeTypePhysicalMachine
+	case ServerTypeVM:
+		if machineKey(event) != "" {
+			return NodeTypeVirtualMachine
+		}
+	case ServerTypeContainer:
+		if machineKey(event) != "" {
+			return NodeTypeContainer
+		}
+	}
+	return NodeTypeApp
+}
+
+// normalizeEventIP 把事件的 IP 与 HostIP 转为规范写法，与同步写入图中的 ip 属性保持一致。
+func normalizeEventIP(event AlarmEvent) AlarmEvent {
+	event.IP = domain.NormalizeIP(event.IP)
+	event.HostIP = domain.NormalizeIP(event.HostIP)
+	return event
+}
+
+// normalizeEventIPs 返回 IP 规范化后的事件副本，不修改调用方的切片。
+func normalizeEventIPs(events []AlarmEvent) []AlarmEvent {
+	out := make([]AlarmEvent, len(events))
+	for i, evt := range events {
+		out[i] = normalizeEventIP(evt)
+	}
+	return out
+}
+
+// machineKey 按 cmdb_key、ip、hostname 的优先级返回事件用于匹配机器层的标识，均为空时返回空串。
+func machineKey(event AlarmEvent) string {
+	for _, key := range []string{event.CMDBKey, event.IP, event.Hostname} {
+		if key = strings.TrimSpace(key); key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+// resolveBatch 对 indexes 指定的事件执行一次批量查询，返回按事件下标索引的链路，未命中的事件不在结果中。
+func (p *GraphProvider) resolveBatch(ctx context.Context, from NodeType, events []AlarmEvent, indexes []int) (chains map[int]Chain, err error) {
+	query, ok := p.batchQueries[from]
+	if !ok {
+		return nil, fmt.Errorf("no resolve query for node type %q", from)
+	}
+	chains = make(map[int]Chain, len(indexes))
+	params := make([]map[string]any, 0, len(indexes))
+	for _, i := range indexes {
+		if p.cache != nil {
+			if chain, ok := p.cachedChain(cacheKey(from, events[i])); ok {
+				chains[i] = chain
+				continue
+			}
+		}
+		params = append(params, map[string]any{
+			"index":    i,
+			"cmdb_key": events[i].CMDBKey,
+			"ip":       events[i].IP,
+			"hostname": events[i].Hostname,
+			"name":     events[i].AppName,
+			"idc":      events[i].Datacenter,
+		})
+	}
+	if len(params) == 0 {
+		return chains, nil
+	}
+	ctx, span := p.startResolveSpan(ctx, from, len(params))
+	defer func() {
+		span.SetAttributes(attribute.Int("rca.chain_count", len(chains)))
+		graph.EndSpan(span, err)
+	}()
+	records, err := p.client.RunRead(ctx, query, map[string]any{"events": params})
+	if err != nil {
+		logging.FromContext(ctx, nil).Warn("rca batch resolve failed", zap.String("node_type", string(from)), zap.Int("events", len(params)), zap.Error(err))
+		return nil, err
+	}
+	canonicalizeRecords(p.schema, records)
+	for _, record := range records {
+		event, _ := record["event"].(map[string]any)
+		i := intValue(event["index"])
+		if _, seen := chains[i]; seen {
+			continue
+		}
+		chain, err := chainFromRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		chains[i] = chain
+		if p.cache != nil {
+			p.cache.put(cacheKey(from, events[i]), chain)
+		}
+	}
+	return chains, nil
+}
+
+// errNotFound 标记起始层级未命中，区别于查询失败。
+var errNotFound = errors.New("not found")
+
+// notFoundError 生成起始层级未命中时的错误。
+func notFoundError(from NodeType, event AlarmEvent) error {
+	switch from {
+	case NodeTypeHostMachine:
+		return fmt.Errorf("host %s %w", machineKey(event), errNotFound)
+	case NodeTypePhysicalMachine:
+		return fmt.Errorf("physical %s %w", machineKey(event), errNotFound)
+	default:
+		return fmt.Errorf("app %s %w", event.AppName, errNotFound)
+	}
+}
+
+// partitionEvent 返回按 cmdb_key 从网络分区层解析 part 的事件。
+func partitionEvent(part Partition, event AlarmEvent) AlarmEvent {
+	return AlarmEvent{CMDBKey: part.Key, Datacenter: event.Datacenter}
+}
+
+// mergePartition 用分区链路补上 chain 缺失的网络分区与 IDC。
+func mergePartition(chain, partition Chain) Chain {
+	chain.NetPartition = partition.NetPartition
+	if chain.IDC == nil {
+		chain.IDC = partition.IDC
+	}
+	return chain
+}
+
+// partitionIndex 返回当前的分区索引，未配置时返回 nil。
+func (p *GraphProvider) partitionIndex() *PartitionIndex {
+	if p.partitions == nil {
+		return nil
+	}
+	return p.partitions.Index()
+}
+
+// attachPartition 在链路没有网络分区且事件 IP 落在某个分区 CIDR 内时补上该分区，未补全时原样返回。
+func (p *GraphProvider) attachPartition(ctx context.Context, event AlarmEvent, chain Chain) (Chain, bool, error) {
+	index := p.partitionIndex()
+	if index == nil || chain.NetPartition != nil {
+		return chain, false, nil
+	}
+	part, ok := index.Lookup(event.IP)
+	if !ok {
+		return chain, false, nil
+	}
+	found, ok, err := p.lookup(ctx, NodeTypeNetPartition, partitionEvent(part, event))
+	if err != nil || !ok {
+		return chain, false, err
+	}
+	return mergePartition(chain, found), true, nil
+}
+
+// attachPartitions 是 attachPartition 的批量版本，一次查询补全所有缺少分区的链路，补全的未命中事件从 failed 中移除。
+func (p *GraphProvider) attachPartitions(ctx context.Context, events []AlarmEvent, chains []Chain, failed EventErrors) error {
+	index := p.partitionIndex()
+	if index == nil {
+		return nil
+	}
+	lookups := make([]AlarmEvent, len(events))
+	var indexes []int
+	for i, evt := range events {
+		if chains[i].NetPartition != nil {
+			continue
+		}
+		part, ok := index.Lookup(evt.IP)
+		if !ok {
+			continue
+		}
+		lookups[i] = partitionEvent(part, evt)
+		indexes = append(indexes, i)
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+	found, err := p.resolveBatch(ctx, NodeTypeNetPartition, lookups, indexes)
+	if err != nil {
+		return err
 	}
+	for i, partition := range found {
+		chains[i] = mergePartition(chains[i], partition)
+		delete(failed, i)
+	}
+	return nil
+}
+
+// expectedLayer 返回事件 ServerType 对应的承载层。
+func expectedLayer(serverType ServerType) NodeType {
+	switch serverType {
+	case ServerTypeHost:
+		return NodeTypeHostMachine
+	case ServerTypePhysical:
+		return NodeTypePhysicalMachine
+	case ServerTypeVM:
+		return NodeTypeVirtualMachine
+	case ServerTypeContainer:
+		return NodeTypeContainer
+	default:
+		return NodeType("")
+	}
+}
 
+// MatchLayers 返回 IP 在计算层（VM/宿主机/物理机/容器）中命中的节点类型，按标签分别匹配以命中 ip 索引。
+func (p *GraphProvider) MatchLayers(ctx context.Context, ip string) ([]NodeType, error) {
+	records, err := p.client.RunRead(ctx, p.layerQuery, map[string]any{"ip": domain.NormalizeIP(ip)})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[NodeType]struct{})
+	layers := make([]NodeType, 0, len(records))
+	for _, record := range records {
+		raw, _ := record["labels"].([]any)
+		labels := make([]string, 0, len(raw))
+		for _, lb := range raw {
+			if str, ok := lb.(string); ok {
+				labels = append(labels, str)
+			}
+		}
+		typ := inferNodeType(p.schema.CanonicalLabels(labels))
+		if typ == "" {
+			continue
+		}
+		if _, ok := seen[typ]; ok {
+			continue
+		}
+		seen[typ] = struct{}{}
+		layers = append(layers, typ)
+	}
+	return layers, nil
+}
+
+func (p *GraphProvider) reportConflict(ctx context.Context, event AlarmEvent) {
+	if p.onConflict == nil || strings.TrimSpace(event.IP) == "" {
+		return
+	}
+	layers, err := p.MatchLayers(ctx, event.IP)
+	if err != nil || len(layers) < 2 {
+		return
+	}
+	p.onConflict(LayerConflict{IP: event.IP, Expected: expectedLayer(event.ServerType), Layers: layers})
+}
+
+func (p *GraphProvider) ListAppInstances(ctx context.Context, appName string, datacenter string) (int, error) {
 	total := 0
 	params := map[string]any{"app": appName, "idc": datacenter}
-	for _, query := range queries {
+	for _, query := range p.instanceQueries {
 		records, err := p.client.RunRead(ctx, query, params)
 		if err != nil {
 			return 0, err
@@ -81,84 +573,86 @@ RETURN COUNT(DISTINCT phy) AS total
 	return total, nil
 }
 
-func (p *GraphProvider) resolveFromAppOrVM(ctx context.Context, event AlarmEvent) (Chain, error) {
-	query := `
-MATCH (app:App)
-WHERE app.name = $name
-OPTIONAL MATCH (app)-[:DEPLOYED_ON]->(vm:VirtualMachine)
-OPTIONAL MATCH (vm)<-[:HOSTS_VM]-(host:HostMachine)
-OPTIONAL MATCH (host)<-[:HAS_HOST]-(np:NetPartition)
-OPTIONAL MATCH (np)<-[:HAS_PARTITION]-(idc:IDC)
-RETURN app, vm, host, null AS physical, np, idc,
-       CASE WHEN vm IS NULL THEN 0 ELSE size((vm)<-[:DEPLOYED_ON]-(:App)) END AS vm_app_count,
-       CASE WHEN host IS NULL THEN 0 ELSE size((host)-[:HOSTS_VM]->(:VirtualMachine)) END AS host_vm_count,
-       CASE WHEN np IS NULL THEN 0 ELSE size((np)-[:HAS_HOST]->(:HostMachine)) END AS np_host_count,
-       CASE WHEN np IS NULL THEN 0 ELSE size((np)-[:HAS_PHYSICAL]->(:PhysicalMachine)) END AS np_physical_count,
-       CASE WHEN idc IS NULL THEN 0 ELSE size((idc)-[:HAS_PARTITION]->(:NetPartition)) END AS idc_np_count
-ORDER BY idc.name = $idc DESC
-LIMIT 1
-`
-	records, err := p.client.RunRead(ctx, query, map[string]any{
-		"name": event.AppName,
-		"idc":  event.Datacenter,
+// resolve 按层级模板执行链路查询，事件的 cmdb_key、ip、hostname、应用名、机房统一作为参数传入。
+func (p *GraphProvider) resolve(ctx context.Context, from NodeType, event AlarmEvent) (records []map[string]any, err error) {
+	query, ok := p.queries[from]
+	if !ok {
+		return nil, fmt.Errorf("no resolve query for node type %q", from)
+	}
+	ctx, span := p.startResolveSpan(ctx, from, 1)
+	defer func() {
+		if err != nil {
+			logging.FromContext(ctx, nil).Warn("rca resolve failed", zap.String("node_type", string(from)), zap.String("ip", event.IP), zap.String("app", event.AppName), zap.Error(err))
+		}
+		graph.EndSpan(span, err)
+	}()
+	records, err = p.client.RunRead(ctx, query, map[string]any{
+		"cmdb_key": event.CMDBKey,
+		"ip":       event.IP,
+		"hostname": event.Hostname,
+		"name":     event.AppName,
+		"idc":      event.Datacenter,
 	})
+	canonicalizeRecords(p.schema, records)
+	return records, err
+}
+
+// cachedChain 从缓存读取链路并上报命中结果。
+func (p *GraphProvider) cachedChain(key string) (Chain, bool) {
+	chain, ok := p.cache.get(key)
+	p.recorder.ObserveChainCache(ok)
+	return chain, ok
+}
+
+// lookup 从 from 层解析事件的链路，未命中返回 found=false；开启缓存时先查缓存，只缓存命中的结果。
+func (p *GraphProvider) lookup(ctx context.Context, from NodeType, event AlarmEvent) (Chain, bool, error) {
+	var key string
+	if p.cache != nil {
+		key = cacheKey(from, event)
+		if chain, ok := p.cachedChain(key); ok {
+			return chain, true, nil
+		}
+	}
+	records, err := p.resolve(ctx, from, event)
+	if err != nil || len(records) == 0 {
+		return Chain{}, false, err
+	}
+	chain, err := chainFromRecord(records[0])
 	if err != nil {
-		return Chain{}, err
+		return Chain{}, false, err
 	}
-	if len(records) == 0 {
-		return Chain{}, fmt.Errorf("app %s not found", event.AppName)
-	}
-	return chainFromRecord(records[0])
-}
-
-func (p *GraphProvider) resolveFromHost(ctx context.Context, event AlarmEvent) (Chain, error) {
-	query := `
-MATCH (host:HostMachine)
-WHERE host.ip = $ip
-OPTIONAL MATCH (app:App)-[:DEPLOYED_ON]->(host)
-OPTIONAL MATCH (host)<-[:HAS_HOST]-(np:NetPartition)
-OPTIONAL MATCH (np)<-[:HAS_PARTITION]-(idc:IDC)
-RETURN app, null AS vm, host, null AS physical, np, idc,
-       0 AS vm_app_count,
-       CASE WHEN host IS NULL THEN 0 ELSE size((host)-[:HOSTS_VM]->(:VirtualMachine)) END AS host_vm_count,
-       CASE WHEN np IS NULL THEN 0 ELSE size((np)-[:HAS_HOST]->(:HostMachine)) END AS np_host_count,
-       CASE WHEN np IS NULL THEN 0 ELSE size((np)-[:HAS_PHYSICAL]->(:PhysicalMachine)) END AS np_physical_count,
-       CASE WHEN idc IS NULL THEN 0 ELSE size((idc)-[:HAS_PARTITION]->(:NetPartition)) END AS idc_np_count
-LIMIT 1
-`
-	records, err := p.client.RunRead(ctx, query, map[string]any{"ip": event.IP})
+	if p.cache != nil {
+		p.cache.put(key, chain)
+	}
+	return chain, true, nil
+}
+
+// resolveFrom 从 from 层解析事件的链路，未命中时报错。
+func (p *GraphProvider) resolveFrom(ctx context.Context, from NodeType, event AlarmEvent) (Chain, error) {
+	chain, found, err := p.lookup(ctx, from, event)
 	if err != nil {
 		return Chain{}, err
 	}
-	if len(records) == 0 {
-		return Chain{}, fmt.Errorf("host %s not found", event.IP)
-	}
-	return chainFromRecord(records[0])
-}
-
-func (p *GraphProvider) resolveFromPhysical(ctx context.Context, event AlarmEvent) (Chain, error) {
-	query := `
-MATCH (phy:PhysicalMachine)
-WHERE phy.ip = $ip
-OPTIONAL MATCH (app:App)-[:DEPLOYED_ON]->(phy)
-OPTIONAL MATCH (np:NetPartition)-[:HAS_PHYSICAL]->(phy)
-OPTIONAL MATCH (np)<-[:HAS_PARTITION]-(idc:IDC)
-RETURN app, null AS vm, null AS host, phy AS physical, np, idc,
-       0 AS vm_app_count,
-       0 AS host_vm_count,
-       CASE WHEN np IS NULL THEN 0 ELSE size((np)-[:HAS_HOST]->(:HostMachine)) END AS np_host_count,
-       CASE WHEN np IS NULL THEN 0 ELSE size((np)-[:HAS_PHYSICAL]->(:PhysicalMachine)) END AS np_physical_count,
-       CASE WHEN idc IS NULL THEN 0 ELSE size((idc)-[:HAS_PARTITION]->(:NetPartition)) END AS idc_np_count
-LIMIT 1
-`
-	records, err := p.client.RunRead(ctx, query, map[string]any{"ip": event.IP})
+	if !found {
+		return Chain{}, notFoundError(from, event)
+	}
+	return chain, nil
+}
+
+// resolveFromGuest 按 VM 或容器的 cmdb_key、IP 或主机名限定在 from 层匹配，避免与同 IP 的其他层混淆；
+// 找不到时回退到按应用名解析。
+func (p *GraphProvider) resolveFromGuest(ctx context.Context, from NodeType, event AlarmEvent) (Chain, error) {
+	if machineKey(event) == "" {
+		return p.resolveFrom(ctx, NodeTypeApp, event)
+	}
+	chain, found, err := p.lookup(ctx, from, event)
 	if err != nil {
 		return Chain{}, err
 	}
-	if len(records) == 0 {
-		return Chain{}, fmt.Errorf("physical %s not found", event.IP)
+	if !found {
+		return p.resolveFrom(ctx, NodeTypeApp, event)
 	}
-	return chainFromRecord(records[0])
+	return chain, nil
 }
 
 func chainFromRecord(record map[string]any) (Chain, error) {
@@ -169,6 +663,11 @@ func chainFromRecord(record map[string]any) (Chain, error) {
 	} else {
 		chain.App = node
 	}
+	if node, err := nodeFromRecord(record, "container"); err != nil {
+		return Chain{}, err
+	} else {
+		chain.Container = node
+	}
 	if node, err := nodeFromRecord(record, "vm"); err != nil {
 		return Chain{}, err
 	} else {
@@ -194,12 +693,25 @@ func chainFromRecord(record map[string]any) (Chain, error) {
 	} else {
 		chain.IDC = node
 	}
+	if node, err := nodeFromRecord(record, "svc"); err != nil {
+		return Chain{}, err
+	} else {
+		chain.Service = node
+	}
 
+	setChildCount(chain.Container, NodeTypeApp, record["container_app_count"])
 	setChildCount(chain.VirtualMachine, NodeTypeApp, record["vm_app_count"])
+	setChildCount(chain.VirtualMachine, NodeTypeContainer, record["vm_container_count"])
 	setChildCount(chain.HostMachine, NodeTypeVirtualMachine, record["host_vm_count"])
+	setChildCount(chain.HostMachine, NodeTypeContainer, record["host_container_count"])
 	setChildCount(chain.NetPartition, NodeTypeHostMachine, record["np_host_count"])
 	setChildCount(chain.NetPartition, NodeTypePhysicalMachine, record["np_physical_count"])
 	setChildCount(chain.IDC, NodeTypeNetPartition, record["idc_np_count"])
+	setChildCount(chain.Service, NodeTypeApp, record["svc_app_count"])
+	setChildWeight(chain.VirtualMachine, NodeTypeApp, record["vm_app_weight"])
+	setChildWeight(chain.HostMachine, NodeTypeVirtualMachine, record["host_vm_weight"])
+	setLinkWeight(chain.App, record["app_link_weight"])
+	setLinkWeight(chain.VirtualMachine, record["vm_link_weight"])
 
 	if chain.HostMachine != nil && chain.PhysicalMachine != nil {
 		chain.PhysicalMachine = nil
@@ -221,6 +733,42 @@ func setChildCount(node *Node, childType NodeType, raw any) {
 	node.ChildCounts[childType] = value
 }
 
+func setChildWeight(node *Node, childType NodeType, raw any) {
+	if node == nil {
+		return
+	}
+	value := floatValue(raw)
+	if value <= 0 {
+		return
+	}
+	if node.ChildWeights == nil {
+		node.ChildWeights = make(map[NodeType]float64)
+	}
+	node.ChildWeights[childType] = value
+}
+
+func setLinkWeight(node *Node, raw any) {
+	if node == nil {
+		return
+	}
+	if value := floatValue(raw); value > 0 {
+		node.LinkWeight = value
+	}
+}
+
+func floatValue(raw any) float64 {
+	switch v := raw.(type) {
+	case float64:
+		return v
+	case int64:
+		return float64(v)
+	case int:
+		return float64(v)
+	default:
+		return 0
+	}
+}
+
 func intValue(raw any) int {
 	switch v := raw.(type) {
 	case int:
@@ -235,15 +783,7 @@ func intValue(raw any) int {
 }
 
 func chainToNodes(chain Chain) []Node {
-	ordered := []*Node{chain.App, chain.VirtualMachine, chain.HostMachine, chain.PhysicalMachine, chain.NetPartition, chain.IDC}
-	nodes := make([]Node, 0, len(ordered))
-	for _, ptr := range ordered {
-		if ptr == nil {
-			continue
-		}
-		nodes = append(nodes, *ptr)
-	}
-	return nodes
+	return chain.Nodes(knownNodeTypes)
 }
 
 func nodeFromRecord(record map[string]any, key string) (*Node, error) {
@@ -255,6 +795,11 @@ func nodeFromRecord(record map[string]any, key string) (*Node, error) {
 	if !ok {
 		return nil, fmt.Errorf("field %s is not neo4j node", key)
 	}
+	return nodeFromNeo4j(node), nil
+}
+
+// nodeFromNeo4j 把驱动返回的节点转换为 Node，缺少 cmdb_key 时按类型与 IP 或节点 ID 生成键。
+func nodeFromNeo4j(node neo4j.Node) *Node {
 	propsCopy := make(map[string]any, len(node.Props))
 	for k, v := range node.Props {
 		propsCopy[k] = v
@@ -263,7 +808,7 @@ func nodeFromRecord(record map[string]any, key string) (*Node, error) {
 	typeName := inferNodeType(labels)
 	name := firstNonEmpty(propsCopy["name"], propsCopy["hostname"], propsCopy["cmdb_key"], propsCopy["ip"])
 	partition := firstNonEmpty(propsCopy["network_partion"], propsCopy["partition"], propsCopy["name"])
-	key = firstNonEmpty(propsCopy["cmdb_key"])
+	key := firstNonEmpty(propsCopy["cmdb_key"])
 	if key == "" {
 		if ip := firstNonEmpty(propsCopy["ip"]); ip != "" {
 			key = fmt.Sprintf("%s:%s", typeName, ip)
@@ -282,13 +827,13 @@ func nodeFromRecord(record map[string]any, key string) (*Node, error) {
 			Props:     propsCopy,
 		},
 		ChildCounts: make(map[NodeType]int),
-	}, nil
+	}
 }
 
 func inferNodeType(labels []string) NodeType {
 	for _, lb := range labels {
 		switch NodeType(lb) {
-		case NodeTypeApp, NodeTypeVirtualMachine, NodeTypeHostMachine, NodeTypePhysicalMachine, NodeTypeNetPartition, NodeTypeIDC:
+		case NodeTypeApp, NodeTypeContainer, NodeTypeVirtualMachine, NodeTypeHostMachine, NodeTypePhysicalMachine, NodeTypeNetPartition, NodeTypeIDC, NodeTypeService:
 			return NodeType(lb)
 		}
 	}
@@ -0,0 +1,109 @@
+package rca
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"cmdb2neo/internal/domain"
+)
+
+// queries 目录下每个 resolve_<NodeType>.cql 描述从该层节点出发解析拓扑链路的查询，
+// 公共的返回列定义在 chain_return.cql 中。模板覆盖 knownNodeTypes 中的全部层级，hierarchy 只能在这些层级中
+// 选择与排序（如加入可选的 Container 层）；链路的返回列与 Chain 的字段一一对应，新增节点类型仍需同时修改
+// chain_return.cql、Chain 与 chainFromRecord。
+// 模板通过 {{param "ip"}} 引用事件参数，{{keep}} 在批量模式下把 event 带过 WITH，
+// {{label "App"}} 与 {{rel "DEPLOYED_ON"}} 按 domain.Schema 输出实际的标签与关系类型；
+// 机器层统一用 machine_key 按 cmdb_key、ip、hostname 的优先级匹配。
+//
+//go:embed queries/*.cql
+var queryFiles embed.FS
+
+// resolveQueries 与 batchResolveQueries 按默认 Schema 在包初始化时渲染，模板缺失或语法错误会直接 panic。
+var (
+	resolveQueries      = mustRenderResolveQueries(false, domain.DefaultSchema())
+	batchResolveQueries = mustRenderResolveQueries(true, domain.DefaultSchema())
+)
+
+// batchQueryPrefix 与 batchQuerySuffix 把单事件查询包成按 $events 逐行执行的子查询，
+// 每行返回 event 及单事件查询的全部列。
+const (
+	batchQueryPrefix = "UNWIND $events AS event\nCALL {\nWITH event\n"
+	batchQuerySuffix = "\n}\nRETURN *\n"
+)
+
+func resolveTemplateName(t NodeType) string {
+	return "resolve_" + string(t) + ".cql"
+}
+
+func mustRenderResolveQueries(batch bool, schema domain.Schema) map[NodeType]string {
+	queries, err := renderResolveQueries(batch, schema)
+	if err != nil {
+		panic(err)
+	}
+	return queries
+}
+
+func parseQueryTemplates(schema domain.Schema, funcs template.FuncMap) (*template.Template, error) {
+	tmpl, err := template.New("queries").Funcs(schemaFuncs(schema)).Funcs(funcs).ParseFS(queryFiles, "queries/*.cql")
+	if err != nil {
+		return nil, fmt.Errorf("parse rca query templates: %w", err)
+	}
+	return tmpl, nil
+}
+
+func renderResolveQueries(batch bool, schema domain.Schema) (map[NodeType]string, error) {
+	funcs := template.FuncMap{
+		"param": func(name string) string { return "$" + name },
+		"keep":  func() string { return "" },
+	}
+	if batch {
+		funcs["param"] = func(name string) string { return "event." + name }
+		funcs["keep"] = func() string { return ", event" }
+	}
+	tmpl, err := parseQueryTemplates(schema, funcs)
+	if err != nil {
+		return nil, err
+	}
+	queries := make(map[NodeType]string)
+	for _, t := range tmpl.Templates() {
+		name := t.Name()
+		if !strings.HasPrefix(name, "resolve_") || !strings.HasSuffix(name, ".cql") {
+			continue
+		}
+		var sb strings.Builder
+		if err := t.Execute(&sb, nil); err != nil {
+			return nil, fmt.Errorf("render rca query %s: %w", name, err)
+		}
+		nodeType := NodeType(strings.TrimSuffix(strings.TrimPrefix(name, "resolve_"), ".cql"))
+		if batch {
+			queries[nodeType] = batchQueryPrefix + sb.String() + batchQuerySuffix
+			continue
+		}
+		queries[nodeType] = sb.String()
+	}
+	return queries, nil
+}
+
+// ResolveQuery 返回从指定层级出发解析拓扑链路的查询。
+func ResolveQuery(t NodeType) (string, bool) {
+	query, ok := resolveQueries[t]
+	return query, ok
+}
+
+// BatchResolveQuery 返回按 $events 批量解析的查询，events 中每项需包含 index、ip、name、idc。
+func BatchResolveQuery(t NodeType) (string, bool) {
+	query, ok := batchResolveQueries[t]
+	return query, ok
+}
+
+// validateResolveQueries 校验 hierarchy 中每个层级都有对应的解析模板。
+func validateResolveQueries(hierarchy []NodeType) error {
+	for _, t := range hierarchy {
+		if _, ok := resolveQueries[t]; !ok {
+			return fmt.Errorf("no resolve query template %s for node type %q", resolveTemplateName(t), t)
+		}
+	}
+	return nil
+}
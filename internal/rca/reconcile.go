@@ -0,0 +1,83 @@
+package rca
+
+import "sort"
+
+// reconcileStages 把应用故障关联到最能解释其告警的拓扑候选，并按 StageWeights 统一打分排序。
+// 最佳候选为覆盖该应用事件最多者，其次按置信度、层级由低到高决胜。
+func reconcileStages(events []AlarmEvent, outages []AppOutage, candidates []Candidate, hierarchy []NodeType, weights StageWeights) []RootCause {
+	if weights.AppOutage == 0 && weights.Topology == 0 {
+		weights = DefaultStageWeights()
+	}
+	total := weights.AppOutage + weights.Topology
+
+	outageEvents := make(map[string]map[string]struct{}, len(outages))
+	for _, evt := range events {
+		key := evt.AppName + "|" + evt.Datacenter
+		ids, ok := outageEvents[key]
+		if !ok {
+			ids = make(map[string]struct{})
+			outageEvents[key] = ids
+		}
+		ids[buildEventID(evt)] = struct{}{}
+	}
+
+	level := make(map[NodeType]int, len(hierarchy))
+	for i, t := range hierarchy {
+		level[t] = i
+	}
+
+	linked := make(map[int][]LinkedOutage, len(candidates))
+	causes := make([]RootCause, 0, len(candidates)+len(outages))
+	for _, outage := range outages {
+		ids := outageEvents[outage.AppName+"|"+outage.Datacenter]
+		best, bestHits := -1, 0
+		for i, cand := range candidates {
+			hits := 0
+			for _, id := range cand.Explained {
+				if _, ok := ids[id]; ok {
+					hits++
+				}
+			}
+			if hits == 0 {
+				continue
+			}
+			if best < 0 || hits > bestHits ||
+				(hits == bestHits && cand.Confidence > candidates[best].Confidence) ||
+				(hits == bestHits && cand.Confidence == candidates[best].Confidence && level[cand.Node.Type] < level[candidates[best].Node.Type]) {
+				best, bestHits = i, hits
+			}
+		}
+
+		link := LinkedOutage{AppName: outage.AppName, Datacenter: outage.Datacenter, Coverage: outage.Coverage}
+		if best >= 0 {
+			link.Overlap = float64(bestHits) / float64(len(ids))
+			linked[best] = append(linked[best], link)
+			continue
+		}
+		causes = append(causes, RootCause{
+			Score:      weights.AppOutage * outage.Coverage / total,
+			AppOutages: []LinkedOutage{link},
+			Explained:  sortedStrings(ids),
+		})
+	}
+
+	for i, cand := range candidates {
+		node := cand.Node
+		outageScore := 0.0
+		for _, link := range linked[i] {
+			if v := link.Coverage * link.Overlap; v > outageScore {
+				outageScore = v
+			}
+		}
+		causes = append(causes, RootCause{
+			Node:       &node,
+			Score:      (weights.Topology*cand.Confidence + weights.AppOutage*outageScore) / total,
+			Confidence: cand.Confidence,
+			AppOutages: linked[i],
+			Explained:  cand.Explained,
+		})
+	}
+
+	sort.SliceStable(causes, func(i, j int) bool { return causes[i].Score > causes[j].Score })
+	return causes
+}
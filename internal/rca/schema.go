@@ -0,0 +1,62 @@
+package rca
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"cmdb2neo/internal/domain"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// schemaFuncs 为查询模板提供 {{label "App"}} 与 {{rel "DEPLOYED_ON"}}，把规范名换算为图中的实际名称。
+func schemaFuncs(schema domain.Schema) template.FuncMap {
+	return template.FuncMap{
+		"label": schema.Label,
+		"rel":   schema.Rel,
+	}
+}
+
+// mustRenderSchemaQuery 按 schema 渲染内嵌在代码中的查询模板，模板为常量，出错直接 panic。
+func mustRenderSchemaQuery(schema domain.Schema, name, text string) string {
+	tmpl, err := template.New(name).Funcs(schemaFuncs(schema)).Parse(text)
+	if err != nil {
+		panic(fmt.Errorf("parse rca query %s: %w", name, err))
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, nil); err != nil {
+		panic(fmt.Errorf("render rca query %s: %w", name, err))
+	}
+	return sb.String()
+}
+
+// canonicalizeRecords 把查询结果中节点的标签与关系的类型换算回规范名，使下游按 NodeType 常量判断类型；
+// 默认 Schema 下无需换算。records 为 RunRead 返回的副本，原地修改。
+func canonicalizeRecords(schema domain.Schema, records []map[string]any) {
+	if schema.IsDefault() {
+		return
+	}
+	for _, record := range records {
+		for k, v := range record {
+			record[k] = canonicalizeValue(schema, v)
+		}
+	}
+}
+
+func canonicalizeValue(schema domain.Schema, v any) any {
+	switch val := v.(type) {
+	case neo4j.Node:
+		val.Labels = schema.CanonicalLabels(val.Labels)
+		return val
+	case neo4j.Relationship:
+		val.Type = schema.CanonicalRel(val.Type)
+		return val
+	case []any:
+		for i, item := range val {
+			val[i] = canonicalizeValue(schema, item)
+		}
+		return val
+	default:
+		return v
+	}
+}
@@ -0,0 +1,100 @@
+package rca
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"cmdb2neo/internal/graph"
+)
+
+// ErrResultNotFound 表示窗口 ID 没有对应的已保存结果。
+var ErrResultNotFound = errors.New("rca result not found")
+
+// StoredResult 为按窗口归档的一次分析结果。
+type StoredResult struct {
+	WindowID   string    `json:"window_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	EventCount int       `json:"event_count"`
+	Result     Result    `json:"result"`
+}
+
+// ResultReader 按窗口 ID 读取已保存的分析结果。
+type ResultReader interface {
+	Load(ctx context.Context, windowID string) (StoredResult, error)
+}
+
+// GraphResultStore 将结果以 JSON 形式保存在 :RCAResult 节点上，同一窗口重复保存时覆盖。
+type GraphResultStore struct {
+	client graph.ReadWriter
+	now    func() time.Time
+}
+
+// NewGraphResultStore 构建基于 Neo4j 的结果存储。
+func NewGraphResultStore(client graph.ReadWriter) *GraphResultStore {
+	return &GraphResultStore{client: client, now: time.Now}
+}
+
+// Save 实现 ResultStore。
+func (s *GraphResultStore) Save(ctx context.Context, windowID string, result Result) error {
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("encode result failed: %w", err)
+	}
+	query := `
+MERGE (r:RCAResult {window_id: $window_id})
+SET r.created_at = $created_at,
+    r.event_count = $event_count,
+    r.window_start = $window_start,
+    r.window_end = $window_end,
+    r.payload = $payload
+`
+	params := map[string]any{
+		"window_id":    windowID,
+		"created_at":   s.now().UTC(),
+		"event_count":  result.EventCount,
+		"window_start": optionalTime(result.WindowStart),
+		"window_end":   optionalTime(result.WindowEnd),
+		"payload":      string(payload),
+	}
+	if err := s.client.RunWrite(ctx, query, params); err != nil {
+		return fmt.Errorf("save result %s failed: %w", windowID, err)
+	}
+	return nil
+}
+
+// Load 实现 ResultReader，窗口不存在时返回 ErrResultNotFound。
+func (s *GraphResultStore) Load(ctx context.Context, windowID string) (StoredResult, error) {
+	query := `
+MATCH (r:RCAResult {window_id: $window_id})
+RETURN r.created_at AS created_at, r.event_count AS event_count, r.payload AS payload
+`
+	records, err := s.client.RunRead(ctx, query, map[string]any{"window_id": windowID})
+	if err != nil {
+		return StoredResult{}, fmt.Errorf("load result %s failed: %w", windowID, err)
+	}
+	if len(records) == 0 {
+		return StoredResult{}, ErrResultNotFound
+	}
+	record := records[0]
+	stored := StoredResult{
+		WindowID:   windowID,
+		CreatedAt:  timeValue(record["created_at"]),
+		EventCount: intValue(record["event_count"]),
+	}
+	payload, _ := record["payload"].(string)
+	if err := json.Unmarshal([]byte(payload), &stored.Result); err != nil {
+		return StoredResult{}, fmt.Errorf("decode result %s failed: %w", windowID, err)
+	}
+	return stored, nil
+}
+
+// optionalTime 将零值时间转换为 nil，使 Neo4j 中不写入该属性。
+func optionalTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
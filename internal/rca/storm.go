@@ -0,0 +1,129 @@
+package rca
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// StormRule 描述告警风暴阈值：同一节点同一规则在 Window 内超过 Threshold 条的告警被抑制。
+// Threshold 或 Window 为 0 时不抑制。
+type StormRule struct {
+	Threshold int           `json:"threshold"`
+	Window    time.Duration `json:"window"`
+}
+
+func (r StormRule) enabled() bool {
+	return r.Threshold > 0 && r.Window > 0
+}
+
+// StormFilter 为告警风暴过滤配置，按规则名、ServerType、默认值的优先级选取阈值。
+type StormFilter struct {
+	Default     StormRule                `json:"default"`
+	ServerTypes map[ServerType]StormRule `json:"server_types,omitempty"`
+	Rules       map[string]StormRule     `json:"rules,omitempty"`
+}
+
+func (f StormFilter) ruleFor(evt AlarmEvent) StormRule {
+	if rule, ok := f.Rules[evt.RuleName]; ok {
+		return rule
+	}
+	if rule, ok := f.ServerTypes[evt.ServerType]; ok {
+		return rule
+	}
+	return f.Default
+}
+
+func (f StormFilter) enabled() bool {
+	if f.Default.enabled() {
+		return true
+	}
+	for _, rule := range f.ServerTypes {
+		if rule.enabled() {
+			return true
+		}
+	}
+	for _, rule := range f.Rules {
+		if rule.enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+func (f StormFilter) clone() StormFilter {
+	out := StormFilter{Default: f.Default}
+	if f.ServerTypes != nil {
+		out.ServerTypes = make(map[ServerType]StormRule, len(f.ServerTypes))
+		for k, v := range f.ServerTypes {
+			out.ServerTypes[k] = v
+		}
+	}
+	if f.Rules != nil {
+		out.Rules = make(map[string]StormRule, len(f.Rules))
+		for k, v := range f.Rules {
+			out.Rules[k] = v
+		}
+	}
+	return out
+}
+
+func (f StormFilter) validate() error {
+	check := func(name string, rule StormRule) error {
+		if rule.Threshold < 0 || rule.Window < 0 {
+			return fmt.Errorf("storm_filter %s must not be negative", name)
+		}
+		return nil
+	}
+	if err := check("default", f.Default); err != nil {
+		return err
+	}
+	for t, rule := range f.ServerTypes {
+		if err := check(fmt.Sprintf("server_type %s", t), rule); err != nil {
+			return err
+		}
+	}
+	for name, rule := range f.Rules {
+		if err := check(fmt.Sprintf("rule %s", name), rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FilterStorms 抑制告警风暴：同一节点（IP + ServerType）同一规则在窗口内只保留前 Threshold 条，
+// 多出的告警不再进入拓扑树，只累加到最近一条保留告警的 Suppressed 上。
+func FilterStorms(events []AlarmEvent, filter StormFilter) []AlarmEvent {
+	if !filter.enabled() || len(events) < 2 {
+		return events
+	}
+
+	ordered := append([]AlarmEvent(nil), events...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].OccurredAt.Before(ordered[j].OccurredAt) })
+
+	result := make([]AlarmEvent, 0, len(ordered))
+	kept := make(map[string][]int)
+	for _, evt := range ordered {
+		rule := filter.ruleFor(evt)
+		if !rule.enabled() {
+			result = append(result, evt)
+			continue
+		}
+		key := fmt.Sprintf("%s|%s|%s", evt.IP, evt.ServerType, evt.RuleName)
+		cutoff := evt.OccurredAt.Add(-rule.Window)
+		recent := kept[key][:0]
+		for _, pos := range kept[key] {
+			if !result[pos].OccurredAt.Before(cutoff) {
+				recent = append(recent, pos)
+			}
+		}
+		if len(recent) >= rule.Threshold {
+			result[recent[len(recent)-1]].Suppressed += occurrences(evt) + evt.Suppressed
+			kept[key] = recent
+			continue
+		}
+		kept[key] = append(recent, len(result))
+		result = append(result, evt)
+	}
+	return result
+}
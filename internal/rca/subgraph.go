@@ -0,0 +1,65 @@
+package rca
+
+import "sort"
+
+// ResolvedEdge 记录分析过程中经过的一条父子关系。
+type ResolvedEdge struct {
+	Parent     string   `json:"parent"`
+	ParentType NodeType `json:"parent_type"`
+	Child      string   `json:"child"`
+	ChildType  NodeType `json:"child_type"`
+}
+
+// subgraphRecorder 收集解析链路时经过的节点和边，用于结果导出。
+type subgraphRecorder struct {
+	nodes map[string]NodeRef
+	edges map[string]ResolvedEdge
+}
+
+func newSubgraphRecorder() *subgraphRecorder {
+	return &subgraphRecorder{
+		nodes: make(map[string]NodeRef),
+		edges: make(map[string]ResolvedEdge),
+	}
+}
+
+// record 记录一条由下至上的解析链路。
+func (r *subgraphRecorder) record(chain []Node) {
+	if r == nil {
+		return
+	}
+	var child *NodeRef
+	for i := range chain {
+		ref := chain[i].NodeRef
+		if _, ok := r.nodes[ref.Key]; !ok {
+			r.nodes[ref.Key] = ref
+		}
+		if child != nil {
+			edge := ResolvedEdge{Parent: ref.Key, ParentType: ref.Type, Child: child.Key, ChildType: child.Type}
+			r.edges[edge.Parent+"->"+edge.Child] = edge
+		}
+		child = &chain[i].NodeRef
+	}
+}
+
+func (r *subgraphRecorder) apply(res *Result) {
+	if r == nil {
+		return
+	}
+	res.ResolvedNodes = make([]NodeRef, 0, len(r.nodes))
+	for _, node := range r.nodes {
+		res.ResolvedNodes = append(res.ResolvedNodes, node)
+	}
+	sort.Slice(res.ResolvedNodes, func(i, j int) bool { return res.ResolvedNodes[i].Key < res.ResolvedNodes[j].Key })
+
+	res.ResolvedEdges = make([]ResolvedEdge, 0, len(r.edges))
+	for _, edge := range r.edges {
+		res.ResolvedEdges = append(res.ResolvedEdges, edge)
+	}
+	sort.Slice(res.ResolvedEdges, func(i, j int) bool {
+		if res.ResolvedEdges[i].Parent == res.ResolvedEdges[j].Parent {
+			return res.ResolvedEdges[i].Child < res.ResolvedEdges[j].Child
+		}
+		return res.ResolvedEdges[i].Parent < res.ResolvedEdges[j].Parent
+	})
+}
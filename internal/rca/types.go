@@ -1,6 +1,9 @@
 package rca
 
-import "time"
+import (
+	"math"
+	"time"
+)
 
 // ServerType 表示告警所在的承载层。
 type ServerType string
@@ -9,6 +12,8 @@ const (
 	ServerTypeHost     ServerType = "1"
 	ServerTypeVM       ServerType = "2"
 	ServerTypePhysical ServerType = "3"
+	// ServerTypeContainer 为容器（Pod），承载在虚拟机或宿主机之上。
+	ServerTypeContainer ServerType = "4"
 )
 
 // NodeType 用于表示拓扑层级。
@@ -21,18 +26,35 @@ const (
 	NodeTypePhysicalMachine NodeType = "PhysicalMachine"
 	NodeTypeNetPartition    NodeType = "NetPartition"
 	NodeTypeIDC             NodeType = "IDC"
+	// NodeTypeContainer 为可选的容器层，位于应用与虚拟机、宿主机之间，默认配置不包含。
+	NodeTypeContainer NodeType = "Container"
+	// NodeTypeService 为可选的逻辑服务层，聚合属于同一服务的应用，不在物理链路上。
+	NodeTypeService NodeType = "Service"
 )
 
 // AlarmEvent 描述一次告警事件输入。
 type AlarmEvent struct {
-	AppName          string     `json:"app_name"`
-	Datacenter       string     `json:"datacenter"`
-	HostIP           string     `json:"host_ip"`
-	IP               string     `json:"ip"`
+	AppName    string `json:"app_name"`
+	Datacenter string `json:"datacenter"`
+	HostIP     string `json:"host_ip"`
+	IP         string `json:"ip"`
+	// Hostname 与 CMDBKey 用于只上报主机名或 CMDB 主键的告警源，机器层按 cmdb_key、ip、hostname 的优先级匹配。
+	Hostname         string     `json:"hostname,omitempty"`
+	CMDBKey          string     `json:"cmdb_key,omitempty"`
 	NetworkPartition string     `json:"network_partition"`
 	ServerType       ServerType `json:"server_type"`
 	RuleName         string     `json:"rule_name"`
-	OccurredAt       time.Time  `json:"occurred_at"`
+	// Priority 为告警优先级（如 P1），按 Config.PriorityWeights 加权节点的影响面。
+	Priority   string    `json:"priority,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+	// Count 为合并后的发生次数，未合并的事件为 0 或 1。
+	Count int `json:"count,omitempty"`
+	// LastOccurredAt 为合并后最后一次发生时间。
+	LastOccurredAt time.Time `json:"last_occurred_at,omitempty"`
+	// Healthy 为 true 表示这是节点主动上报的健康信号，会降低该节点的候选置信度。
+	Healthy bool `json:"healthy,omitempty"`
+	// Suppressed 为风暴过滤时并入该事件而未进入拓扑树的告警条数。
+	Suppressed int `json:"suppressed,omitempty"`
 }
 
 // NodeRef 是拓扑节点的引用信息。
@@ -50,16 +72,55 @@ type NodeRef struct {
 type Node struct {
 	NodeRef
 	ChildCounts map[NodeType]int `json:"child_counts,omitempty"`
+	// ChildWeights 为按关系属性加权的子节点基线，目前仅宿主机的虚拟机（HOSTS_VM.weight）与虚拟机的应用（DEPLOYED_ON.instances）。
+	ChildWeights map[NodeType]float64 `json:"child_weights,omitempty"`
+	// LinkWeight 为节点与链路中父节点之间关系的权重，0 表示未知，加权覆盖率中按 1 计。
+	LinkWeight float64 `json:"link_weight,omitempty"`
 }
 
 // Chain 表示一条完整的拓扑链路。
 type Chain struct {
 	App             *Node
+	Container       *Node
 	VirtualMachine  *Node
 	HostMachine     *Node
 	PhysicalMachine *Node
 	NetPartition    *Node
 	IDC             *Node
+	Service         *Node
+}
+
+// Nodes 按 order 给出的层级顺序返回链路中存在的节点。
+func (c Chain) Nodes(order []NodeType) []Node {
+	nodes := make([]Node, 0, len(order))
+	for _, t := range order {
+		if ptr := c.layer(t); ptr != nil {
+			nodes = append(nodes, *ptr)
+		}
+	}
+	return nodes
+}
+
+func (c Chain) layer(t NodeType) *Node {
+	switch t {
+	case NodeTypeApp:
+		return c.App
+	case NodeTypeContainer:
+		return c.Container
+	case NodeTypeVirtualMachine:
+		return c.VirtualMachine
+	case NodeTypeHostMachine:
+		return c.HostMachine
+	case NodeTypePhysicalMachine:
+		return c.PhysicalMachine
+	case NodeTypeNetPartition:
+		return c.NetPartition
+	case NodeTypeIDC:
+		return c.IDC
+	case NodeTypeService:
+		return c.Service
+	}
+	return nil
 }
 
 // TopoNode 表示在 StageB 中构建的拓扑树节点。
@@ -69,6 +130,8 @@ type TopoNode struct {
 	Children map[string]*TopoNode
 	Impacts  map[string]*TopoImpact
 	Events   map[string]AlarmEventRef
+	// weighted 为 true 时 Coverage 按子节点关系权重计算，由分析器依据 Config.WeightedCoverage 设置。
+	weighted bool
 }
 
 // TopoImpact 描述父节点下的某个子节点对告警的影响。
@@ -107,6 +170,17 @@ func (n *TopoNode) AttachChild(child *TopoNode) {
 	child.Parent = n
 }
 
+// attachMember 记录逻辑上的从属关系（如应用属于服务），不改变子节点在物理链路中的父节点。
+func (n *TopoNode) attachMember(member *TopoNode) {
+	if member == nil {
+		return
+	}
+	if n.Children == nil {
+		n.Children = make(map[string]*TopoNode)
+	}
+	n.Children[member.NodeRef.Key] = member
+}
+
 // AddImpact 在父节点上记录来自子节点的告警。
 func (n *TopoNode) AddImpact(child *TopoNode, ref AlarmEventRef) {
 	if child == nil {
@@ -123,14 +197,18 @@ func (n *TopoNode) AddImpact(child *TopoNode, ref AlarmEventRef) {
 	impact.Events[ref.ID] = ref
 }
 
-// Coverage 计算节点的告警覆盖率以及被影响的子节点集合。
+// Coverage 计算节点的告警覆盖率以及被影响的子节点集合，基线未知时返回 0。
+// 开启加权覆盖率且节点有加权基线时按受影响子节点的关系权重之和计算。
 func (n *TopoNode) Coverage() float64 {
-	if len(n.Children) == 0 && len(n.Impacts) == 0 {
-		return 1.0
+	total, ok := n.baseline()
+	if !ok {
+		return 0
+	}
+	if n.weighted {
+		if coverage, ok := n.weightedCoverage(); ok {
+			return coverage
+		}
 	}
-
-	childType := n.ChildType()
-	total := n.ChildCounts[childType]
 
 	coverage := float64(len(n.Impacts)) / float64(total)
 	if coverage > 1 {
@@ -139,6 +217,44 @@ func (n *TopoNode) Coverage() float64 {
 	return coverage
 }
 
+// HasBaseline 报告节点是否有可用于计算覆盖率的子节点基线，ChildCounts 缺失或为 0 时基线未知。
+// 基线未知的节点有告警子节点时不参与候选评估；没有告警子节点的叶子节点按 0 覆盖率评估，
+// 以 UNKNOWN_BASELINE 标记为低置信度候选。
+func (n *TopoNode) HasBaseline() bool {
+	_, ok := n.baseline()
+	return ok
+}
+
+// weightedCoverage 返回按关系权重计算的覆盖率，节点没有加权基线时返回 false。
+func (n *TopoNode) weightedCoverage() (float64, bool) {
+	total := n.ChildWeights[n.ChildType()]
+	if total <= 0 {
+		return 0, false
+	}
+	sum := 0.0
+	for key := range n.Impacts {
+		weight := 1.0
+		if child, ok := n.Children[key]; ok && child.LinkWeight > 0 {
+			weight = child.LinkWeight
+		}
+		sum += weight
+	}
+	coverage := sum / total
+	if coverage > 1 {
+		coverage = 1
+	}
+	return coverage, true
+}
+
+func (n *TopoNode) baseline() (int, bool) {
+	childType := n.ChildType()
+	if childType == "" {
+		return 0, false
+	}
+	total := n.ChildCounts[childType]
+	return total, total > 0
+}
+
 // ChildType 返回当前节点活跃子节点的类型。
 func (n *TopoNode) ChildType() NodeType {
 	for _, impact := range n.Impacts {
@@ -150,22 +266,103 @@ func (n *TopoNode) ChildType() NodeType {
 	return NodeType("")
 }
 
-// ComputeScore 根据权重计算节点得分。
-func (n *TopoNode) ComputeScore(weights ScoreWeights) ScoreDetail {
+// TimeLead 计算节点自身告警相对子节点告警的领先程度，按该节点所有告警的时间跨度归一化到 [0,1]。
+// 自身告警指未经任何子节点传导、直接落在该节点上的事件；没有自身告警、没有子节点告警或自身告警更晚时为 0。
+func (n *TopoNode) TimeLead() float64 {
+	lead, span := n.leadDurations()
+	if lead <= 0 || span <= 0 {
+		return 0
+	}
+	return math.Min(float64(lead)/float64(span), 1)
+}
+
+// leadDurations 返回自身最早告警领先子节点最早告警的时长，以及该节点所有告警的时间跨度。
+func (n *TopoNode) leadDurations() (lead, span time.Duration) {
+	viaChild := make(map[string]struct{})
+	var childFirst time.Time
+	for _, impact := range n.Impacts {
+		if impact == nil {
+			continue
+		}
+		for id, ref := range impact.Events {
+			viaChild[id] = struct{}{}
+			childFirst = minTime(childFirst, ref.Occurred)
+		}
+	}
+	var ownFirst, start, end time.Time
+	for id, ref := range n.Events {
+		if ref.Occurred.IsZero() {
+			continue
+		}
+		start = minTime(start, ref.Occurred)
+		if ref.Occurred.After(end) {
+			end = ref.Occurred
+		}
+		if _, ok := viaChild[id]; !ok {
+			ownFirst = minTime(ownFirst, ref.Occurred)
+		}
+	}
+	if ownFirst.IsZero() || childFirst.IsZero() {
+		return 0, end.Sub(start)
+	}
+	return childFirst.Sub(ownFirst), end.Sub(start)
+}
+
+// minTime 返回两个时间中较早的非零值。
+func minTime(a, b time.Time) time.Time {
+	if b.IsZero() {
+		return a
+	}
+	if a.IsZero() || b.Before(a) {
+		return b
+	}
+	return a
+}
+
+// Impact 返回节点（含下游）归集的不同告警按优先级加权后占窗口告警总权重的比例，覆盖率相同时区分影响面大小。
+func (n *TopoNode) Impact(totalWeight float64) float64 {
+	if totalWeight <= 0 {
+		return 0
+	}
+	var weighted float64
+	for _, ref := range n.Events {
+		weighted += ref.weight()
+	}
+	impact := weighted / totalWeight
+	if impact > 1 {
+		return 1
+	}
+	return impact
+}
+
+// ComputeScore 根据权重计算节点得分，totalWeight 为窗口内进入拓扑的告警按优先级加权后的总和，用于衡量影响面；
+// 未配置优先级权重时即告警总数。
+func (n *TopoNode) ComputeScore(weights ScoreWeights, totalWeight float64) ScoreDetail {
 	coverage := n.Coverage()
 
-	raw := weights.Base + weights.Coverage*coverage
-	if raw < 0 {
+	lead := n.TimeLead()
+
+	impact := n.Impact(totalWeight)
+
+	raw := weights.Base + weights.Coverage*coverage + weights.TimeLead*lead + weights.Impact*impact
+	if math.IsNaN(raw) || raw < 0 {
 		raw = 0
 	}
 	if raw > 1 {
 		raw = 1
 	}
+	suppressed := 0
+	for _, ref := range n.Events {
+		suppressed += ref.Suppressed
+	}
 	return ScoreDetail{
 		Coverage:   coverage,
+		TimeLead:   lead,
+		Impact:     impact,
 		Base:       weights.Base,
 		RawScore:   raw,
 		Normalized: raw,
+		Suppressed: suppressed,
 	}
 }
 
@@ -195,15 +392,40 @@ type Candidate struct {
 	Reason     string      `json:"reason"`
 	Metrics    ScoreDetail `json:"metrics"`
 	Explained  []string    `json:"explained_event_ids"`
+	// Explanation 为面向值班人员的一句话说明，仅在 Config.Explain 开启时生成。
+	Explanation string `json:"explanation,omitempty"`
+}
+
+// RootCause 为跨阶段统一排序后的根因。拓扑候选与其解释的应用故障合并为一条；
+// 找不到拓扑解释的应用故障单独成条，此时 Node 为空。
+type RootCause struct {
+	Node       *NodeRef       `json:"node,omitempty"`
+	Score      float64        `json:"score"`
+	Confidence float64        `json:"confidence"`
+	AppOutages []LinkedOutage `json:"app_outages,omitempty"`
+	Explained  []string       `json:"explained_event_ids"`
+}
+
+// LinkedOutage 记录根因关联的应用故障及其事件被解释的比例。
+type LinkedOutage struct {
+	AppName    string  `json:"app_name"`
+	Datacenter string  `json:"datacenter"`
+	Coverage   float64 `json:"coverage"`
+	Overlap    float64 `json:"overlap"`
 }
 
 // ScoreDetail 拆解得分来源。
 type ScoreDetail struct {
 	Coverage   float64 `json:"coverage"`
+	TimeLead   float64 `json:"time_lead"`
 	Impact     float64 `json:"impact"`
 	Base       float64 `json:"base"`
 	RawScore   float64 `json:"raw_score"`
 	Normalized float64 `json:"normalized"`
+	// Calibrated 为开启 Config.CalibrateConfidence 时窗口内归一化后的置信度，与 Candidate.Confidence 一致。
+	Calibrated float64 `json:"calibrated,omitempty"`
+	// Suppressed 为该节点解释的告警中被风暴过滤抑制的条数，不参与打分。
+	Suppressed int `json:"suppressed,omitempty"`
 }
 
 // AlarmPath 记录某个候选节点下的触发链路。
@@ -221,16 +443,62 @@ type PathImpact struct {
 
 // AlarmEventRef 是压缩后的事件引用。
 type AlarmEventRef struct {
-	ID       string    `json:"id"`
-	RuleName string    `json:"rule_name"`
-	NodeType NodeType  `json:"node_type"`
-	Occurred time.Time `json:"occurred_at"`
+	ID         string    `json:"id"`
+	RuleName   string    `json:"rule_name"`
+	NodeType   NodeType  `json:"node_type"`
+	Occurred   time.Time `json:"occurred_at"`
+	Suppressed int       `json:"suppressed,omitempty"`
+	// Weight 为按告警优先级换算的影响面权重，0 视为 1。
+	Weight float64 `json:"weight,omitempty"`
+}
+
+// weight 返回事件计入影响面的权重，未设置时为 1。
+func (r AlarmEventRef) weight() float64 {
+	if r.Weight <= 0 {
+		return 1
+	}
+	return r.Weight
 }
 
 // Result 为一次 RCA 分析输出。
 type Result struct {
-	AppOutages []AppOutage `json:"app_outages"`
-	Candidates []Candidate `json:"candidates"`
-	Paths      []AlarmPath `json:"paths,omitempty"`
-	Prompt     string      `json:"prompt,omitempty"`
+	// EventCount 为本次分析输入的告警条数。
+	EventCount int `json:"event_count"`
+	// WindowStart/WindowEnd 为全部输入告警（含未解释的）的最早、最晚发生时间，没有有效时间时为零值。
+	WindowStart time.Time   `json:"window_start,omitempty"`
+	WindowEnd   time.Time   `json:"window_end,omitempty"`
+	AppOutages  []AppOutage `json:"app_outages"`
+	Candidates  []Candidate `json:"candidates"`
+	Paths       []AlarmPath `json:"paths,omitempty"`
+	RootCauses  []RootCause `json:"root_causes,omitempty"`
+	Prompt      string      `json:"prompt,omitempty"`
+	// ResolvedNodes/ResolvedEdges 仅在开启 CaptureTopology 时填充。
+	ResolvedNodes []NodeRef      `json:"resolved_nodes,omitempty"`
+	ResolvedEdges []ResolvedEdge `json:"resolved_edges,omitempty"`
+	// ResolutionErrors 为无法解析拓扑而被跳过的事件，开启 Config.FailFast 时不会出现。
+	ResolutionErrors []EventError `json:"resolution_errors,omitempty"`
+	// UnexplainedEvents 为未能归入任何拓扑节点的事件及原因。
+	UnexplainedEvents []UnexplainedEvent `json:"unexplained_events,omitempty"`
+}
+
+// EventError 记录单条事件的处理失败。
+type EventError struct {
+	EventID string     `json:"event_id"`
+	Event   AlarmEvent `json:"event"`
+	Error   string     `json:"error"`
+}
+
+// UnexplainedReason 说明事件为何未被解释。
+type UnexplainedReason string
+
+const (
+	// UnexplainedUnresolved 表示事件在拓扑中找不到对应节点。
+	UnexplainedUnresolved UnexplainedReason = "unresolved"
+)
+
+// UnexplainedEvent 为未被任何拓扑节点解释的事件。
+type UnexplainedEvent struct {
+	EventID string            `json:"event_id"`
+	Event   AlarmEvent        `json:"event"`
+	Reason  UnexplainedReason `json:"reason"`
 }
@@ -0,0 +1,329 @@
+package rca
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Adapter 把告警系统推送的 webhook 负载转换为 AlarmEvent，使告警源无需了解内部事件结构。
+type Adapter interface {
+	Adapt(payload []byte) ([]AlarmEvent, error)
+}
+
+// AdapterFunc 让普通函数实现 Adapter。
+type AdapterFunc func(payload []byte) ([]AlarmEvent, error)
+
+// Adapt 调用 f(payload)。
+func (f AdapterFunc) Adapt(payload []byte) ([]AlarmEvent, error) {
+	return f(payload)
+}
+
+// Adapters 按告警源名称登记 Adapter，名称不区分大小写。
+type Adapters struct {
+	bySource map[string]Adapter
+}
+
+// NewAdapters 返回空的 Adapter 登记表。
+func NewAdapters() *Adapters {
+	return &Adapters{bySource: make(map[string]Adapter)}
+}
+
+// DefaultAdapters 返回内置 alertmanager、zabbix、generic 三种告警源的登记表。
+func DefaultAdapters() *Adapters {
+	adapters := NewAdapters()
+	adapters.Register("alertmanager", AdapterFunc(AdaptAlertmanager))
+	adapters.Register("zabbix", AdapterFunc(AdaptZabbix))
+	adapters.Register("generic", AdapterFunc(AdaptGeneric))
+	return adapters
+}
+
+// Register 登记 source 对应的 Adapter，同名时覆盖，adapter 为 nil 时忽略。
+func (a *Adapters) Register(source string, adapter Adapter) {
+	if adapter == nil {
+		return
+	}
+	a.bySource[strings.ToLower(strings.TrimSpace(source))] = adapter
+}
+
+// Lookup 返回 source 对应的 Adapter。
+func (a *Adapters) Lookup(source string) (Adapter, bool) {
+	if a == nil {
+		return nil, false
+	}
+	adapter, ok := a.bySource[strings.ToLower(strings.TrimSpace(source))]
+	return adapter, ok
+}
+
+// Sources 返回已登记的告警源名称，按字母序排列。
+func (a *Adapters) Sources() []string {
+	if a == nil {
+		return nil
+	}
+	sources := make([]string, 0, len(a.bySource))
+	for source := range a.bySource {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// 标签名到事件字段的映射，按顺序取第一个非空值；alertmanager 与 generic 共用。
+var (
+	ipLabels        = []string{"ip", "host_ip", "instance_ip"}
+	hostIPLabels    = []string{"host_ip", "hypervisor_ip"}
+	hostnameLabels  = []string{"hostname", "host", "nodename"}
+	appLabels       = []string{"app_name", "app", "application", "service"}
+	idcLabels       = []string{"datacenter", "idc", "dc"}
+	partitionLabels = []string{"network_partition", "partition"}
+	ruleLabels      = []string{"rule_name", "alertname", "rule"}
+	priorityLabels  = []string{"priority", "severity"}
+)
+
+// eventFromLabels 按标签别名填充事件的定位字段。
+func eventFromLabels(labels map[string]string) AlarmEvent {
+	evt := AlarmEvent{
+		AppName:          labelValue(labels, appLabels...),
+		Datacenter:       labelValue(labels, idcLabels...),
+		IP:               labelValue(labels, ipLabels...),
+		Hostname:         labelValue(labels, hostnameLabels...),
+		CMDBKey:          labelValue(labels, "cmdb_key"),
+		NetworkPartition: labelValue(labels, partitionLabels...),
+		ServerType:       ServerType(labelValue(labels, "server_type")),
+		RuleName:         labelValue(labels, ruleLabels...),
+		Priority:         labelValue(labels, priorityLabels...),
+	}
+	// host_ip 同时是 ip 的别名，只有在 ip 另有来源时才作为宿主机 IP
+	if hostIP := labelValue(labels, hostIPLabels...); hostIP != evt.IP {
+		evt.HostIP = hostIP
+	}
+	return evt
+}
+
+func labelValue(labels map[string]string, names ...string) string {
+	for _, name := range names {
+		if v := strings.TrimSpace(labels[name]); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// alertmanagerPayload 为 Alertmanager webhook（version 4）的负载。
+type alertmanagerPayload struct {
+	Version      string            `json:"version"`
+	Status       string            `json:"status"`
+	CommonLabels map[string]string `json:"commonLabels"`
+	Alerts       []struct {
+		Status   string            `json:"status"`
+		Labels   map[string]string `json:"labels"`
+		StartsAt time.Time         `json:"startsAt"`
+		EndsAt   time.Time         `json:"endsAt"`
+	} `json:"alerts"`
+}
+
+// AdaptAlertmanager 转换 Alertmanager webhook：告警标签按别名映射到事件字段，缺少 IP 时取 instance 标签的主机部分，
+// 已恢复（resolved）的告警转为健康信号。
+func AdaptAlertmanager(payload []byte) ([]AlarmEvent, error) {
+	var body alertmanagerPayload
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, fmt.Errorf("decode alertmanager payload: %w", err)
+	}
+	events := make([]AlarmEvent, 0, len(body.Alerts))
+	for _, alert := range body.Alerts {
+		labels := make(map[string]string, len(body.CommonLabels)+len(alert.Labels))
+		for k, v := range body.CommonLabels {
+			labels[k] = v
+		}
+		for k, v := range alert.Labels {
+			labels[k] = v
+		}
+		evt := eventFromLabels(labels)
+		if evt.IP == "" || evt.Hostname == "" {
+			fillFromInstance(&evt, labels["instance"])
+		}
+		evt.OccurredAt = alert.StartsAt
+		if strings.EqualFold(alert.Status, "resolved") {
+			evt.Healthy = true
+			if !alert.EndsAt.IsZero() {
+				evt.OccurredAt = alert.EndsAt
+			}
+		}
+		events = append(events, evt)
+	}
+	return events, nil
+}
+
+// fillFromInstance 从 host:port 形式的 instance 标签中补全 IP 或主机名。
+func fillFromInstance(evt *AlarmEvent, instance string) {
+	host := strings.TrimSpace(instance)
+	if host == "" {
+		return
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		if evt.IP == "" {
+			evt.IP = host
+		}
+		return
+	}
+	if evt.Hostname == "" {
+		evt.Hostname = host
+	}
+}
+
+// zabbixEvent 为 Zabbix webhook 媒介推送的事件，字段名对应媒介参数中常用的宏：
+// {EVENT.ID}、{EVENT.NAME}、{HOST.NAME}、{HOST.IP}、{EVENT.VALUE}（1 为问题，0 为恢复）、{EVENT.DATE} {EVENT.TIME}。
+// 负载可以是单个事件或事件数组；tags 中的键值按与 generic 相同的别名补充应用、机房等字段。
+type zabbixEvent struct {
+	EventID    string            `json:"event_id"`
+	EventName  string            `json:"event_name"`
+	Trigger    string            `json:"trigger_name"`
+	Host       string            `json:"host"`
+	HostName   string            `json:"host_name"`
+	HostIP     string            `json:"host_ip"`
+	Status     string            `json:"status"`
+	EventValue string            `json:"event_value"`
+	EventDate  string            `json:"event_date"`
+	EventTime  string            `json:"event_time"`
+	Tags       map[string]string `json:"tags"`
+}
+
+// zabbixTimeLayout 为 {EVENT.DATE} {EVENT.TIME} 拼接后的格式。
+const zabbixTimeLayout = "2006.01.02 15:04:05"
+
+// AdaptZabbix 转换 Zabbix webhook 媒介推送的事件，恢复事件转为健康信号。
+func AdaptZabbix(payload []byte) ([]AlarmEvent, error) {
+	var items []zabbixEvent
+	if err := decodeOneOrMany(payload, &items); err != nil {
+		return nil, fmt.Errorf("decode zabbix payload: %w", err)
+	}
+	events := make([]AlarmEvent, 0, len(items))
+	for _, item := range items {
+		evt := eventFromLabels(item.Tags)
+		evt.IP = firstString(item.HostIP, evt.IP)
+		evt.Hostname = firstString(item.HostName, item.Host, evt.Hostname)
+		evt.RuleName = firstString(item.Trigger, item.EventName, evt.RuleName)
+		evt.Healthy = item.EventValue == "0" || strings.EqualFold(item.Status, "resolved") || strings.EqualFold(item.Status, "ok")
+		if ts := strings.TrimSpace(item.EventDate + " " + item.EventTime); ts != "" {
+			occurred, err := time.ParseInLocation(zabbixTimeLayout, ts, time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("zabbix event %s: invalid time %q: %w", item.EventID, ts, err)
+			}
+			evt.OccurredAt = occurred
+		}
+		events = append(events, evt)
+	}
+	return events, nil
+}
+
+// AdaptGeneric 转换扁平的键值告警：负载为对象数组，或带 events/alerts 数组的对象，或单个对象。
+// 键按别名映射到事件字段（如 alertname→rule_name、idc→datacenter），occurred_at 接受 RFC3339 或 Unix 秒，
+// status 为 resolved/ok 时转为健康信号。
+func AdaptGeneric(payload []byte) ([]AlarmEvent, error) {
+	var items []map[string]any
+	if err := decodeOneOrMany(payload, &items); err != nil {
+		return nil, fmt.Errorf("decode generic payload: %w", err)
+	}
+	if len(items) == 1 {
+		if wrapped, ok := unwrapGeneric(items[0]); ok {
+			items = wrapped
+		}
+	}
+	events := make([]AlarmEvent, 0, len(items))
+	for i, item := range items {
+		labels := make(map[string]string, len(item))
+		for k, v := range item {
+			labels[strings.ToLower(k)] = scalarString(v)
+		}
+		evt := eventFromLabels(labels)
+		if evt.IP == "" || evt.Hostname == "" {
+			fillFromInstance(&evt, labels["instance"])
+		}
+		status := labelValue(labels, "status")
+		evt.Healthy = strings.EqualFold(status, "resolved") || strings.EqualFold(status, "ok") || labels["healthy"] == "true"
+		if raw := labelValue(labels, "occurred_at", "time", "timestamp"); raw != "" {
+			occurred, err := parseEventTime(raw)
+			if err != nil {
+				return nil, fmt.Errorf("event %d: %w", i, err)
+			}
+			evt.OccurredAt = occurred
+		}
+		events = append(events, evt)
+	}
+	return events, nil
+}
+
+// unwrapGeneric 在对象带 events 或 alerts 数组时返回数组中的对象。
+func unwrapGeneric(item map[string]any) ([]map[string]any, bool) {
+	var items []map[string]any
+	var wrapped bool
+	for _, key := range []string{"events", "alerts"} {
+		list, ok := item[key].([]any)
+		if !ok {
+			continue
+		}
+		wrapped = true
+		for _, raw := range list {
+			if obj, ok := raw.(map[string]any); ok {
+				items = append(items, obj)
+			}
+		}
+	}
+	return items, wrapped
+}
+
+// decodeOneOrMany 把单个 JSON 对象或对象数组解码到 out 指向的切片。
+func decodeOneOrMany[T any](payload []byte, out *[]T) error {
+	trimmed := strings.TrimSpace(string(payload))
+	if strings.HasPrefix(trimmed, "[") {
+		return json.Unmarshal(payload, out)
+	}
+	var one T
+	if err := json.Unmarshal(payload, &one); err != nil {
+		return err
+	}
+	*out = []T{one}
+	return nil
+}
+
+// parseEventTime 解析 RFC3339 时间或 Unix 秒。
+func parseEventTime(raw string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	ts, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: want RFC3339 or unix seconds", raw)
+	}
+	return ts, nil
+}
+
+// scalarString 把 JSON 标量转为字符串，对象与数组返回空串。
+func scalarString(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		return ""
+	}
+}
+
+func firstString(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package router
+
+import (
+	rca "cmdb2neo/internal/rca"
+	"cmdb2neo/pkg/logging"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AppHandler 提供应用部署范围查询，回答“某个应用部署在哪些机器上”。
+type AppHandler struct {
+	footprints rca.FootprintReader
+	logger     *zap.Logger
+}
+
+// NewAppHandler 构建应用查询处理器。
+func NewAppHandler(footprints rca.FootprintReader, logger *zap.Logger) *AppHandler {
+	return &AppHandler{footprints: footprints, logger: logger}
+}
+
+// WithAppHandler 在 /api/v1/apps 注册应用查询路由。
+func WithAppHandler(handler *AppHandler) EngineOption {
+	return func(engine *gin.Engine) {
+		if handler != nil {
+			handler.RegisterRoutes(engine.Group("/api/v1/apps"))
+		}
+	}
+}
+
+// RegisterRoutes 将应用查询路由注册到给定的路由组。
+func (h *AppHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/:name/instances", h.handleInstances)
+}
+
+// handleInstances 返回应用部署的虚拟机、宿主机与物理机实例，idc 非空时只返回该机房的实例。
+func (h *AppHandler) handleInstances(c *gin.Context) {
+	if h.footprints == nil {
+		c.JSON(503, gin.H{"error": "footprint reader not configured"})
+		return
+	}
+	footprint, err := h.footprints.AppFootprint(c.Request.Context(), c.Param("name"), c.Query("idc"))
+	if err != nil {
+		logging.FromContext(c.Request.Context(), h.logger).Warn("app footprint query failed", zap.String("app", c.Param("name")), zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, footprint)
+}
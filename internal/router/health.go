@@ -0,0 +1,84 @@
+package router
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultReadyTimeout 限制单次就绪检查的耗时，探针每隔几秒调用一次。
+const defaultReadyTimeout = 2 * time.Second
+
+// Pinger 检查下游依赖是否可达。
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// ConnectionStatus 汇报后台连通性监控的结果，持续失败时返回非空错误。
+type ConnectionStatus interface {
+	Err() error
+}
+
+// HealthHandler 提供存活与就绪探针。
+type HealthHandler struct {
+	graph        Pinger
+	configLoaded bool
+	timeout      time.Duration
+	connection   ConnectionStatus
+}
+
+// HealthOption 配置 HealthHandler 的可选项。
+type HealthOption func(*HealthHandler)
+
+// WithConnectionStatus 让 /readyz 在后台连通性监控持续失败时返回 503。
+func WithConnectionStatus(status ConnectionStatus) HealthOption {
+	return func(h *HealthHandler) {
+		h.connection = status
+	}
+}
+
+// NewHealthHandler 构建探针处理器，graph 为空或 configLoaded 为 false 时 /readyz 返回 503。
+func NewHealthHandler(graph Pinger, configLoaded bool, opts ...HealthOption) *HealthHandler {
+	h := &HealthHandler{graph: graph, configLoaded: configLoaded, timeout: defaultReadyTimeout}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(h)
+		}
+	}
+	return h
+}
+
+// RegisterRoutes 在引擎根路径注册 /healthz 与 /readyz。
+func (h *HealthHandler) RegisterRoutes(engine *gin.Engine) {
+	engine.GET("/healthz", h.handleHealthz)
+	engine.GET("/readyz", h.handleReadyz)
+}
+
+func (h *HealthHandler) handleHealthz(c *gin.Context) {
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+func (h *HealthHandler) handleReadyz(c *gin.Context) {
+	if !h.configLoaded {
+		c.JSON(503, gin.H{"status": "unavailable", "reason": "config not loaded"})
+		return
+	}
+	if h.graph == nil {
+		c.JSON(503, gin.H{"status": "unavailable", "reason": "neo4j client not configured"})
+		return
+	}
+	if h.connection != nil {
+		if err := h.connection.Err(); err != nil {
+			c.JSON(503, gin.H{"status": "unavailable", "reason": "neo4j connection unhealthy: " + err.Error()})
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	defer cancel()
+	if err := h.graph.Ping(ctx); err != nil {
+		c.JSON(503, gin.H{"status": "unavailable", "reason": "neo4j unreachable: " + err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
+}
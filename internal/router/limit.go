@@ -0,0 +1,120 @@
+package router
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 根因分析接口的默认请求限制。
+const (
+	DefaultMaxEvents    = 5000
+	DefaultMaxBodyBytes = 10 << 20
+	DefaultRateLimit    = 10.0
+	DefaultRateBurst    = 20
+)
+
+// rateLimiterSweepInterval 为清理空闲令牌桶的最小间隔。
+const rateLimiterSweepInterval = time.Minute
+
+// RateLimiter 为按客户端分别计数的令牌桶限流器，令牌按 rate 个/秒补充，桶容量为 burst，并发安全。
+type RateLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter 创建限流器，rate<=0 或 burst<=0 时取默认值。
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if rate <= 0 {
+		rate = DefaultRateLimit
+	}
+	if burst <= 0 {
+		burst = DefaultRateBurst
+	}
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Allow 为 key 消耗一个令牌；令牌不足时返回 false 及下一个令牌可用前需要等待的时间。
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep 删除已经补满的令牌桶，它们与新建的桶等价，避免客户端 IP 增多后内存无限增长。
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimiterSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware 按客户端 IP 限流，只有来自可信代理的请求才采用转发头中的地址，超限时返回 429 并在 Retry-After 中给出需要等待的秒数。
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ok, wait := l.Allow(c.ClientIP())
+		if !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// limitBody 限制请求体大小，超出后读取请求体会返回 *http.MaxBytesError。
+func limitBody(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		}
+		c.Next()
+	}
+}
+
+// payloadErrorStatus 在请求体超出大小限制时返回 413，其余读取或解码错误返回 400。
+func payloadErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
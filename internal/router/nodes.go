@@ -0,0 +1,147 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/graph"
+	"cmdb2neo/pkg/logging"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// NodesHandler 提供按标签分页列出节点的只读接口，供资产清单类页面使用。
+type NodesHandler struct {
+	reader graph.Reader
+	logger *zap.Logger
+	schema domain.Schema
+}
+
+// NodesHandlerOption 用于定制 NodesHandler。
+type NodesHandlerOption func(*NodesHandler)
+
+// WithNodesSchema 按 schema 匹配图中的实际标签，请求与响应中的 label 仍为规范名。
+func WithNodesSchema(schema domain.Schema) NodesHandlerOption {
+	return func(h *NodesHandler) {
+		h.schema = schema
+	}
+}
+
+// NewNodesHandler 构建节点列表处理器。
+func NewNodesHandler(reader graph.Reader, logger *zap.Logger, opts ...NodesHandlerOption) *NodesHandler {
+	h := &NodesHandler{reader: reader, logger: logger}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(h)
+		}
+	}
+	return h
+}
+
+// WithNodesHandler 在 /api/v1/nodes 注册节点列表路由。
+func WithNodesHandler(handler *NodesHandler) EngineOption {
+	return func(engine *gin.Engine) {
+		if handler != nil {
+			handler.RegisterRoutes(engine.Group("/api/v1/nodes"))
+		}
+	}
+}
+
+// RegisterRoutes 将节点列表路由注册到给定的路由组。
+func (h *NodesHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("", h.handleList)
+}
+
+// handleList 按 label 与 graph.NodeFilterProps 中的属性过滤并分页返回节点，label 未知或分页参数非法时返回 400。
+// 本页节点边读边写入响应，不在内存中拼出整页。
+func (h *NodesHandler) handleList(c *gin.Context) {
+	if h.reader == nil {
+		c.JSON(503, gin.H{"error": "graph reader not configured"})
+		return
+	}
+	q := graph.NodeQuery{Label: c.Query("label"), Filters: make(map[string]string), Schema: h.schema}
+	var ok bool
+	if q.Page, ok = positiveQuery(c, "page"); !ok {
+		return
+	}
+	if q.Limit, ok = positiveQuery(c, "limit"); !ok {
+		return
+	}
+	for name := range graph.NodeFilterProps {
+		if value, ok := c.GetQuery(name); ok && value != "" {
+			q.Filters[name] = value
+		}
+	}
+	listing, err := graph.NewNodeListing(q)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	total, err := listing.Count(c.Request.Context(), h.reader)
+	if err != nil {
+		h.log(c).Warn("count nodes failed", zap.String("label", listing.Label), zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.streamPage(c, listing, total); err != nil {
+		// 响应头已经发出，只能中断输出，客户端会收到不完整的 JSON
+		h.log(c).Warn("list nodes failed", zap.String("label", listing.Label), zap.Error(err))
+		_ = c.Error(err)
+	}
+}
+
+// streamPage 以与 graph.NodePage 相同的 JSON 结构输出本页，items 逐个编码并写出。
+func (h *NodesHandler) streamPage(c *gin.Context, listing *graph.NodeListing, total int) error {
+	head, err := json.Marshal(graph.NodePage{Label: listing.Label, Page: listing.Page, Limit: listing.Limit, Total: total})
+	if err != nil {
+		return err
+	}
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(200)
+	w := c.Writer
+	// 去掉末尾的 "items":null} 后接上逐个写出的数组
+	head = bytes.TrimSuffix(head, []byte(`"items":null}`))
+	if _, err := w.Write(append(head, `"items":[`...)); err != nil {
+		return err
+	}
+	if listing.HasItems(total) {
+		first := true
+		err := listing.Each(c.Request.Context(), h.reader, func(item graph.NodeItem) error {
+			encoded, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			if !first {
+				encoded = append([]byte{','}, encoded...)
+			}
+			first = false
+			_, err = w.Write(encoded)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = w.Write([]byte("]}"))
+	return err
+}
+
+func (h *NodesHandler) log(c *gin.Context) *zap.Logger {
+	return logging.FromContext(c.Request.Context(), h.logger)
+}
+
+// positiveQuery 读取正整数查询参数，缺省时返回 0；非法时写入 400 并返回 false。
+func positiveQuery(c *gin.Context, name string) (int, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		c.JSON(400, gin.H{"error": name + " must be a positive integer"})
+		return 0, false
+	}
+	return n, true
+}
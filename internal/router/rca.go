@@ -1,62 +1,563 @@
 package router
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/graph"
 	rca "cmdb2neo/internal/rca"
+	"cmdb2neo/pkg/logging"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 // RCAHandler 负责处理根因分析相关的 HTTP 请求。
 type RCAHandler struct {
 	analyzer *rca.Analyzer
+	alarms   rca.AlarmSource
+	results  rca.ResultReader
+	jobs     *rca.JobRegistry
+	adapters *rca.Adapters
 	logger   *zap.Logger
+	tracer   trace.Tracer
+	// maxEvents 为单次请求允许的最大告警条数，maxBodyBytes 为请求体的最大字节数
+	maxEvents    int
+	maxBodyBytes int64
+	// limiter 为 nil 时不限流
+	limiter *RateLimiter
+}
+
+// RCAHandlerOption 用于定制 RCAHandler。
+type RCAHandlerOption func(*RCAHandler)
+
+// WithAlarmSource 启用基于图中告警节点的分析接口。
+func WithAlarmSource(source rca.AlarmSource) RCAHandlerOption {
+	return func(h *RCAHandler) {
+		h.alarms = source
+	}
+}
+
+// WithResultReader 启用按窗口 ID 查询历史分析结果的接口。
+func WithResultReader(reader rca.ResultReader) RCAHandlerOption {
+	return func(h *RCAHandler) {
+		h.results = reader
+	}
+}
+
+// WithJobRegistry 指定异步分析使用的任务登记表，未配置时使用默认 TTL 的登记表。
+func WithJobRegistry(jobs *rca.JobRegistry) RCAHandlerOption {
+	return func(h *RCAHandler) {
+		h.jobs = jobs
+	}
+}
+
+// WithAdapters 指定 webhook 接口按告警源使用的 Adapter，未配置时使用 rca.DefaultAdapters。
+func WithAdapters(adapters *rca.Adapters) RCAHandlerOption {
+	return func(h *RCAHandler) {
+		h.adapters = adapters
+	}
+}
+
+// WithMaxEvents 限制单次分析请求的告警条数（多窗口时为各窗口之和），超出返回 413，n<=0 时取 DefaultMaxEvents。
+func WithMaxEvents(n int) RCAHandlerOption {
+	return func(h *RCAHandler) {
+		if n > 0 {
+			h.maxEvents = n
+		}
+	}
+}
+
+// WithMaxBodyBytes 限制请求体大小，超出返回 413，n<=0 时取 DefaultMaxBodyBytes。
+func WithMaxBodyBytes(n int64) RCAHandlerOption {
+	return func(h *RCAHandler) {
+		if n > 0 {
+			h.maxBodyBytes = n
+		}
+	}
+}
+
+// WithRateLimiter 对所有根因分析路由按客户端 IP 限流。
+func WithRateLimiter(limiter *RateLimiter) RCAHandlerOption {
+	return func(h *RCAHandler) {
+		h.limiter = limiter
+	}
+}
+
+// WithTracerProvider 为每个请求开启根 span，未配置时不产生 span。
+func WithTracerProvider(provider trace.TracerProvider) RCAHandlerOption {
+	return func(h *RCAHandler) {
+		h.tracer = graph.Tracer(provider)
+	}
 }
 
 // NewRCAHandler 构建一个新的 RCAHandler。
-func NewRCAHandler(analyzer *rca.Analyzer, logger *zap.Logger) *RCAHandler {
-	return &RCAHandler{analyzer: analyzer, logger: logger}
+func NewRCAHandler(analyzer *rca.Analyzer, logger *zap.Logger, opts ...RCAHandlerOption) *RCAHandler {
+	h := &RCAHandler{
+		analyzer:     analyzer,
+		logger:       logger,
+		jobs:         rca.NewJobRegistry(rca.DefaultJobTTL),
+		adapters:     rca.DefaultAdapters(),
+		maxEvents:    DefaultMaxEvents,
+		maxBodyBytes: DefaultMaxBodyBytes,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(h)
+		}
+	}
+	if h.tracer == nil {
+		h.tracer = graph.Tracer(nil)
+	}
+	return h
+}
+
+// Close 取消全部未结束的异步分析任务，用于服务关闭。
+func (h *RCAHandler) Close() {
+	if h != nil && h.jobs != nil {
+		h.jobs.Close()
+	}
 }
 
 // RegisterRoutes 将根因分析路由注册到给定的路由组。
 func (h *RCAHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.Use(h.traceRequest)
+	if h.limiter != nil {
+		rg.Use(h.limiter.Middleware())
+	}
+	rg.Use(limitBody(h.maxBodyBytes))
 	rg.POST("/analyze", h.handleAnalyze)
+	rg.POST("/analyze/recent", h.handleAnalyzeRecent)
+	rg.POST("/webhook/:source", h.handleWebhook)
+	rg.GET("/results/:window_id", h.handleGetResult)
+	rg.GET("/results/:window_id/graph.dot", h.handleGetResultGraph)
+	rg.GET("/jobs/:id", h.handleGetJob)
+	rg.DELETE("/jobs/:id", h.handleCancelJob)
+}
+
+// log 返回带 request_id 的请求级日志器，未经请求日志中间件时返回 h.logger。
+func (h *RCAHandler) log(c *gin.Context) *zap.Logger {
+	return logging.FromContext(c.Request.Context(), h.logger)
+}
+
+// traceRequest 沿用请求头中的 trace 上下文为每个请求开启根 span，分析与图查询的 span 挂在其下。
+func (h *RCAHandler) traceRequest(c *gin.Context) {
+	ctx := propagation.TraceContext{}.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+	ctx, span := h.tracer.Start(ctx, c.Request.Method+" "+c.FullPath(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.method", c.Request.Method),
+			attribute.String("http.route", c.FullPath()),
+		))
+	defer span.End()
+	if id := logging.RequestID(ctx); id != "" {
+		span.SetAttributes(attribute.String("http.request_id", id))
+	}
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+
+	status := c.Writer.Status()
+	span.SetAttributes(attribute.Int("http.status_code", status))
+	if status >= 500 {
+		span.SetStatus(codes.Error, strconv.Itoa(status))
+	}
+}
+
+// handleGetJob 返回异步分析任务的状态，完成后附带结果。
+func (h *RCAHandler) handleGetJob(c *gin.Context) {
+	job, ok := h.jobs.Get(c.Param("id"))
+	if !ok {
+		c.JSON(404, gin.H{"error": "job not found"})
+		return
+	}
+	c.JSON(200, job)
+}
+
+// handleCancelJob 取消未结束的异步分析任务。
+func (h *RCAHandler) handleCancelJob(c *gin.Context) {
+	id := c.Param("id")
+	if !h.jobs.Cancel(id) {
+		if _, ok := h.jobs.Get(id); ok {
+			c.JSON(409, gin.H{"error": "job already finished"})
+			return
+		}
+		c.JSON(404, gin.H{"error": "job not found"})
+		return
+	}
+	job, _ := h.jobs.Get(id)
+	c.JSON(200, job)
+}
+
+// handleGetResult 返回指定窗口已保存的分析结果，format=mermaid 或 dot 时返回对应的图表文本，format=csv 时导出候选。
+func (h *RCAHandler) handleGetResult(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "mermaid" && format != "dot" && format != "csv" {
+		c.JSON(400, gin.H{"error": "format must be json, mermaid, dot or csv"})
+		return
+	}
+	stored, ok := h.loadResult(c)
+	if !ok {
+		return
+	}
+	switch format {
+	case "mermaid":
+		c.Data(200, "text/vnd.mermaid; charset=utf-8", []byte(rca.RenderPathMermaid(stored.Result)))
+	case "dot":
+		c.Data(200, "text/vnd.graphviz; charset=utf-8", []byte(rca.RenderPathDOT(stored.Result)))
+	case "csv":
+		h.writeCandidatesCSV(c, stored)
+	default:
+		c.JSON(200, stored)
+	}
+}
+
+// writeCandidatesCSV 以附件形式流式输出候选 CSV，写出过程中失败时状态码已发送，只记录日志。
+func (h *RCAHandler) writeCandidatesCSV(c *gin.Context, stored rca.StoredResult) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "rca-"+csvFilename(stored.WindowID)+".csv"))
+	c.Status(200)
+	if err := rca.WriteCandidatesCSV(c.Writer, stored.Result.Candidates); err != nil {
+		h.log(c).Warn("write candidates csv failed", zap.String("window_id", stored.WindowID), zap.Error(err))
+	}
+}
+
+// csvFilename 只保留窗口 ID 中适合作为文件名的字符。
+func csvFilename(windowID string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, windowID)
+	if name == "" {
+		return "result"
+	}
+	return name
+}
+
+// handleGetResultGraph 以 Graphviz DOT 格式返回指定窗口的候选与告警路径，便于复盘时可视化。
+func (h *RCAHandler) handleGetResultGraph(c *gin.Context) {
+	stored, ok := h.loadResult(c)
+	if !ok {
+		return
+	}
+	c.Data(200, "text/vnd.graphviz; charset=utf-8", []byte(rca.RenderPathDOT(stored.Result)))
+}
+
+// loadResult 读取已保存的结果，失败时写入错误响应并返回 false。
+func (h *RCAHandler) loadResult(c *gin.Context) (rca.StoredResult, bool) {
+	if h.results == nil {
+		c.JSON(503, gin.H{"error": "result store is not configured"})
+		return rca.StoredResult{}, false
+	}
+	stored, err := h.results.Load(c.Request.Context(), c.Param("window_id"))
+	if errors.Is(err, rca.ErrResultNotFound) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return rca.StoredResult{}, false
+	}
+	if err != nil {
+		h.log(c).Error("load result failed", zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return rca.StoredResult{}, false
+	}
+	return stored, true
+}
+
+// errorStatus 按错误类别映射状态码，便于调用方区分可重试的错误：图查询超时为 504，Neo4j 或 CMDB 暂时不可用为 503，
+// CMDB 认证失败为 502，数据校验失败为 422，其余错误为 500。
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, graph.ErrQueryTimeout):
+		return 504
+	case errors.Is(err, graph.ErrNeo4jUnavailable), errors.Is(err, cmdb.ErrCMDBUnavailable):
+		return 503
+	case errors.Is(err, cmdb.ErrCMDBAuth):
+		return 502
+	case errors.Is(err, domain.ErrValidation):
+		return 422
+	}
+	return 500
+}
+
+const (
+	defaultRecentMinutes = 5
+	maxRecentMinutes     = 24 * 60
+)
+
+// handleAnalyzeRecent 分析图中最近 minutes 分钟内的告警节点。
+func (h *RCAHandler) handleAnalyzeRecent(c *gin.Context) {
+	if h.alarms == nil {
+		c.JSON(503, gin.H{"error": "alarm source is not configured"})
+		return
+	}
+	minutes := defaultRecentMinutes
+	if raw := c.Query("minutes"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxRecentMinutes {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("minutes must be an integer in [1,%d]", maxRecentMinutes)})
+			return
+		}
+		minutes = v
+	}
+
+	now := time.Now()
+	events, err := h.alarms.RecentAlarms(c.Request.Context(), now.Add(-time.Duration(minutes)*time.Minute))
+	if err != nil {
+		h.log(c).Error("load recent alarms failed", zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	windowID := fmt.Sprintf("recent-%d-%dm", now.Unix(), minutes)
+	if len(events) == 0 {
+		c.JSON(200, analyzeResponse{WindowID: windowID})
+		return
+	}
+	result, err := h.analyzer.AnalyzeWithOptions(c.Request.Context(), events, rca.AnalyzeOptions{WindowID: windowID})
+	if err != nil {
+		h.log(c).Error("analyze recent alarms failed", zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, analyzeResponse{WindowID: windowID, Result: result})
+}
+
+// handleWebhook 用 :source 对应的 Adapter 把告警系统推送的负载转换为事件后分析，window_id 可由查询参数指定。
+func (h *RCAHandler) handleWebhook(c *gin.Context) {
+	source := strings.ToLower(c.Param("source"))
+	adapter, ok := h.adapters.Lookup(source)
+	if !ok {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("unknown webhook source %q, supported: %s", source, strings.Join(h.adapters.Sources(), ", "))})
+		return
+	}
+	payload, err := c.GetRawData()
+	if err != nil {
+		h.rejectPayload(c, err)
+		return
+	}
+	events, err := adapter.Adapt(payload)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.checkEventCount(c, len(events)) {
+		return
+	}
+	windowID := strings.TrimSpace(c.Query("window_id"))
+	if windowID == "" {
+		windowID = fmt.Sprintf("webhook-%s-%d", source, time.Now().Unix())
+	}
+	if len(events) == 0 {
+		c.JSON(200, analyzeResponse{WindowID: windowID})
+		return
+	}
+	result, err := h.analyzer.AnalyzeWithOptions(c.Request.Context(), events, rca.AnalyzeOptions{WindowID: windowID})
+	if err != nil {
+		h.log(c).Error("analyze webhook alarms failed", zap.String("source", source), zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, analyzeResponse{WindowID: windowID, Result: result})
 }
 
 type analyzeRequest struct {
 	WindowID string           `json:"window_id"`
 	Events   []rca.AlarmEvent `json:"events"`
+	ReadOnly bool             `json:"read_only"`
+	// CaptureTopology 为 true 时返回分析经过的子图。
+	CaptureTopology bool `json:"capture_topology"`
+	// ConfigOverride 为可选的配置覆盖，仅作用于本次分析，未指定的字段沿用服务端配置。
+	ConfigOverride *rca.ConfigOverride `json:"config_override,omitempty"`
+	// Config 为 ConfigOverride 的旧字段名，两者不能同时出现。
+	Config *rca.ConfigOverride `json:"config,omitempty"`
+	// Async 为 true 时立即返回任务 ID，分析在后台执行，也可通过查询参数 async=true 开启。
+	Async bool `json:"async"`
+	// Windows 不为空时逐个窗口独立分析，WindowID/Events 被忽略，其余选项对所有窗口生效。
+	Windows []analyzeWindow `json:"windows,omitempty"`
+}
+
+// analyzeWindow 为多窗口请求中的单个告警窗口。
+type analyzeWindow struct {
+	WindowID string           `json:"window_id"`
+	Events   []rca.AlarmEvent `json:"events"`
+}
+
+// eventCount 返回请求中的告警总数，多窗口时为各窗口之和。
+func (r analyzeRequest) eventCount() int {
+	if len(r.Windows) == 0 {
+		return len(r.Events)
+	}
+	var n int
+	for _, window := range r.Windows {
+		n += len(window.Events)
+	}
+	return n
+}
+
+// rejectPayload 对无法读取或解码的请求体返回 413（超出大小限制）或 400。
+func (h *RCAHandler) rejectPayload(c *gin.Context, err error) {
+	if status := payloadErrorStatus(err); status == 413 {
+		c.JSON(status, gin.H{"error": fmt.Sprintf("request body exceeds %d bytes", h.maxBodyBytes)})
+		return
+	}
+	c.JSON(400, gin.H{"error": "invalid request payload"})
+}
+
+// checkEventCount 在告警条数超出限制时返回 413 并返回 false。
+func (h *RCAHandler) checkEventCount(c *gin.Context, n int) bool {
+	if n > h.maxEvents {
+		c.JSON(413, gin.H{"error": fmt.Sprintf("too many events: %d exceeds the limit of %d", n, h.maxEvents)})
+		return false
+	}
+	return true
 }
 
 type analyzeResponse struct {
 	WindowID string     `json:"window_id"`
 	Result   rca.Result `json:"result"`
+	// Error 仅在多窗口请求中该窗口分析失败时填充。
+	Error string `json:"error,omitempty"`
+}
+
+type analyzeWindowsResponse struct {
+	Windows []analyzeResponse `json:"windows"`
+}
+
+// bindAnalyzeRequest 兼容单窗口对象、带 windows 的对象以及顶层窗口数组三种请求体。
+func bindAnalyzeRequest(c *gin.Context, req *analyzeRequest) error {
+	raw, err := c.GetRawData()
+	if err != nil {
+		return err
+	}
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &req.Windows); err != nil {
+			return err
+		}
+		if len(req.Windows) == 0 {
+			return fmt.Errorf("windows payload is empty")
+		}
+		return nil
+	}
+	return json.Unmarshal(raw, req)
 }
 
 func (h *RCAHandler) handleAnalyze(c *gin.Context) {
 	var req analyzeRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": "invalid request payload"})
+	if err := bindAnalyzeRequest(c, &req); err != nil {
+		h.rejectPayload(c, err)
 		return
 	}
-	if len(req.Events) == 0 {
+	if len(req.Windows) == 0 && len(req.Events) == 0 {
 		c.JSON(400, gin.H{"error": "events payload is empty"})
 		return
 	}
+	if !h.checkEventCount(c, req.eventCount()) {
+		return
+	}
+	opts := rca.AnalyzeOptions{
+		ReadOnly:        req.ReadOnly,
+		CaptureTopology: req.CaptureTopology,
+	}
+	override := req.ConfigOverride
+	if req.Config != nil {
+		if override != nil {
+			c.JSON(400, gin.H{"error": "config and config_override are mutually exclusive"})
+			return
+		}
+		override = req.Config
+	}
+	if override != nil {
+		cfg, err := h.analyzer.Config().Merge(*override)
+		if err != nil {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("invalid config override: %v", err)})
+			return
+		}
+		opts.Config = &cfg
+	}
+	async := req.Async || c.Query("async") == "true"
+	if len(req.Windows) > 0 {
+		if async {
+			c.JSON(400, gin.H{"error": "async is not supported for multiple windows"})
+			return
+		}
+		h.analyzeWindows(c, req.Windows, opts)
+		return
+	}
 	windowID := strings.TrimSpace(req.WindowID)
 	if windowID == "" {
 		windowID = fmt.Sprintf("auto-%d", time.Now().Unix())
 	}
-	result, err := h.analyzer.Analyze(c.Request.Context(), req.Events)
-	if err != nil {
-		if h.logger != nil {
-			h.logger.Error("analyze failed", zap.Error(err))
+	opts.WindowID = windowID
+	if async {
+		parent := trace.SpanContextFromContext(c.Request.Context())
+		logger := h.log(c)
+		job, err := h.jobs.Submit(windowID, func(ctx context.Context) (rca.Result, error) {
+			// 任务在请求结束后执行，仅沿用请求的 trace 上下文与带 request_id 的日志器
+			ctx = logging.WithLogger(trace.ContextWithSpanContext(ctx, parent), logger)
+			result, err := h.analyzer.AnalyzeWithOptions(ctx, req.Events, opts)
+			if err != nil {
+				logger.Error("async analyze failed", zap.String("window_id", windowID), zap.Error(err))
+			}
+			return result, err
+		})
+		if err != nil {
+			status := 503
+			if errors.Is(err, rca.ErrTooManyJobs) {
+				status = 429
+			}
+			h.log(c).Warn("submit async analyze rejected", zap.String("window_id", windowID), zap.Error(err))
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
 		}
-		c.JSON(500, gin.H{"error": err.Error()})
+		c.JSON(202, job)
+		return
+	}
+	result, err := h.analyzer.AnalyzeWithOptions(c.Request.Context(), req.Events, opts)
+	if err != nil {
+		h.log(c).Error("analyze failed", zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(200, analyzeResponse{WindowID: windowID, Result: result})
 }
+
+// analyzeWindows 逐个窗口独立分析，单个窗口失败只记录在该窗口的 error 中。
+func (h *RCAHandler) analyzeWindows(c *gin.Context, windows []analyzeWindow, base rca.AnalyzeOptions) {
+	now := time.Now().Unix()
+	resp := analyzeWindowsResponse{Windows: make([]analyzeResponse, 0, len(windows))}
+	for i, window := range windows {
+		windowID := strings.TrimSpace(window.WindowID)
+		if windowID == "" {
+			windowID = fmt.Sprintf("auto-%d-%d", now, i)
+		}
+		item := analyzeResponse{WindowID: windowID}
+		if len(window.Events) == 0 {
+			item.Error = "events payload is empty"
+			resp.Windows = append(resp.Windows, item)
+			continue
+		}
+		opts := base
+		opts.WindowID = windowID
+		result, err := h.analyzer.AnalyzeWithOptions(c.Request.Context(), window.Events, opts)
+		if err != nil {
+			h.log(c).Error("analyze window failed", zap.String("window_id", windowID), zap.Error(err))
+			item.Error = err.Error()
+		} else {
+			item.Result = result
+		}
+		resp.Windows = append(resp.Windows, item)
+	}
+	c.JSON(200, resp)
+}
@@ -0,0 +1,95 @@
+package router
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"cmdb2neo/pkg/logging"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RequestIDHeader 为携带请求关联 ID 的请求头与响应头。
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength 为沿用调用方请求 ID 的最大长度，超出或含非法字符时重新生成。
+const maxRequestIDLength = 128
+
+// quietPaths 为探针与指标采集路径，正常响应时只在 debug 级别记录。
+var quietPaths = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// WithRequestLogging 为所有路由沿用或生成 X-Request-ID，把带 request_id 的日志器放入请求上下文，
+// 并在请求结束时记录方法、路径、状态码与耗时；5xx 记为 error，4xx 记为 warn。
+func WithRequestLogging(logger *zap.Logger) EngineOption {
+	return func(engine *gin.Engine) {
+		if logger != nil {
+			engine.Use(RequestLogger(logger))
+		}
+	}
+}
+
+// RequestLogger 返回请求日志中间件，见 WithRequestLogging。
+func RequestLogger(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+		reqLogger := logger.With(zap.String("request_id", id))
+		ctx := logging.WithRequestID(c.Request.Context(), id)
+		c.Request = c.Request.WithContext(logging.WithLogger(ctx, reqLogger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := zapcore.InfoLevel
+		switch {
+		case status >= 500:
+			level = zapcore.ErrorLevel
+		case status >= 400:
+			level = zapcore.WarnLevel
+		case quietPaths[c.Request.URL.Path]:
+			level = zapcore.DebugLevel
+		}
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("route", c.FullPath()),
+			zap.Int("status", status),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Int("bytes", c.Writer.Size()),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypeAny); len(errs) > 0 {
+			fields = append(fields, zap.String("errors", errs.String()))
+		}
+		if ce := reqLogger.Check(level, "http request"); ce != nil {
+			ce.Write(fields...)
+		}
+	}
+}
+
+// validRequestID 只沿用长度合理、由可见 ASCII 字符组成的请求 ID，避免日志注入。
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return time.Now().UTC().Format("20060102T150405.000000000")
+	}
+	return hex.EncodeToString(buf[:])
+}
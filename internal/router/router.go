@@ -1,16 +1,56 @@
 package router
 
-import "github.com/gin-gonic/gin"
+import (
+	"net/http"
 
-// NewEngine 构建 gin 引擎并注册所有模块路由。
-func NewEngine(rcaHandler *RCAHandler) *gin.Engine {
+	"github.com/gin-gonic/gin"
+)
+
+// EngineOption 配置 gin 引擎的可选路由。
+type EngineOption func(*gin.Engine)
+
+// WithMetricsHandler 在 /metrics 注册指标采集端点。
+func WithMetricsHandler(handler http.Handler) EngineOption {
+	return func(engine *gin.Engine) {
+		if handler != nil {
+			engine.GET("/metrics", gin.WrapH(handler))
+		}
+	}
+}
+
+// WithTrustedProxies 信任来自 proxies（IP 或 CIDR）的 X-Forwarded-For 等转发头，
+// 限流与访问日志据此取客户端 IP；为空时不信任任何代理，直接使用连接对端地址。
+func WithTrustedProxies(proxies []string) EngineOption {
+	if len(proxies) == 0 {
+		return nil
+	}
+	return func(engine *gin.Engine) {
+		if err := engine.SetTrustedProxies(proxies); err != nil {
+			// 配置校验已拒绝非法地址，这里兜底为不信任任何代理
+			_ = engine.SetTrustedProxies(nil)
+		}
+	}
+}
+
+// NewEngine 构建 gin 引擎并注册所有模块路由；默认不信任任何代理的转发头，需要时通过 WithTrustedProxies 配置。
+// opts 按顺序在模块路由之前应用，中间件类选项只对其后注册的路由生效，应排在路由类选项之前。
+func NewEngine(rcaHandler *RCAHandler, syncHandler *SyncHandler, opts ...EngineOption) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	engine := gin.New()
+	_ = engine.SetTrustedProxies(nil)
 	engine.Use(gin.Recovery())
+	for _, opt := range opts {
+		if opt != nil {
+			opt(engine)
+		}
+	}
 
 	api := engine.Group("/api/v1")
 	rcaGroup := api.Group("/rca")
 	rcaHandler.RegisterRoutes(rcaGroup)
 
+	if syncHandler != nil {
+		syncHandler.RegisterRoutes(api.Group("/sync"))
+	}
 	return engine
 }
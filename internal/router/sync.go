@@ -0,0 +1,129 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cmdb2neo/internal/app"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// defaultTriggerWait 为手动触发后同步等待结果的时长，超过后返回 202。
+const defaultTriggerWait = 2 * time.Second
+
+// SyncTrigger 在后台启动一次同步，返回触发 ID 与结果通道。
+type SyncTrigger interface {
+	StartSync(ctx context.Context, init bool) (string, <-chan error, error)
+}
+
+// SyncHandler 负责同步触发、进度查询与取消等 HTTP 请求。
+type SyncHandler struct {
+	tracker *app.SyncTracker
+	trigger SyncTrigger
+	wait    time.Duration
+	logger  *zap.Logger
+}
+
+// SyncHandlerOption 用于定制 SyncHandler。
+type SyncHandlerOption func(*SyncHandler)
+
+// WithSyncTrigger 启用 POST /sync 手动触发接口，未配置时返回 503。
+func WithSyncTrigger(trigger SyncTrigger) SyncHandlerOption {
+	return func(h *SyncHandler) {
+		h.trigger = trigger
+	}
+}
+
+// WithTriggerWait 设置手动触发后等待结果的时长。
+func WithTriggerWait(wait time.Duration) SyncHandlerOption {
+	return func(h *SyncHandler) {
+		h.wait = wait
+	}
+}
+
+// NewSyncHandler 构建一个新的 SyncHandler。
+func NewSyncHandler(tracker *app.SyncTracker, logger *zap.Logger, opts ...SyncHandlerOption) *SyncHandler {
+	h := &SyncHandler{tracker: tracker, logger: logger, wait: defaultTriggerWait}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(h)
+		}
+	}
+	return h
+}
+
+// RegisterRoutes 将同步相关路由注册到给定的路由组。
+func (h *SyncHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.POST("", h.handleTrigger)
+	rg.GET("/progress", h.handleProgress)
+	rg.POST("/cancel", h.handleCancel)
+}
+
+// handleTrigger 在后台启动同步，短时间内完成时返回 200 与写入统计，否则返回 202 与触发 ID。
+func (h *SyncHandler) handleTrigger(c *gin.Context) {
+	if h.trigger == nil || h.tracker == nil {
+		c.JSON(503, gin.H{"error": "sync service not configured"})
+		return
+	}
+	var init bool
+	switch c.DefaultQuery("mode", "sync") {
+	case "sync":
+	case "init":
+		init = true
+	default:
+		c.JSON(400, gin.H{"error": "mode must be sync or init"})
+		return
+	}
+
+	// 同步脱离请求生命周期执行，客户端断开不影响本次同步，可通过 /sync/cancel 取消
+	id, done, err := h.trigger.StartSync(context.Background(), init)
+	if err != nil {
+		if errors.Is(err, app.ErrSyncRunning) {
+			c.JSON(409, gin.H{"error": "sync already in progress", "progress": h.tracker.Progress()})
+			return
+		}
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if h.logger != nil {
+		h.logger.Info("sync triggered via http", zap.String("trigger_id", id), zap.Bool("init", init))
+	}
+
+	timer := time.NewTimer(h.wait)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"trigger_id": id, "error": err.Error(), "progress": h.tracker.Progress()})
+			return
+		}
+		c.JSON(200, gin.H{"trigger_id": id, "progress": h.tracker.Progress()})
+	case <-timer.C:
+		c.JSON(202, gin.H{"trigger_id": id, "progress": h.tracker.Progress()})
+	}
+}
+
+func (h *SyncHandler) handleProgress(c *gin.Context) {
+	if h.tracker == nil {
+		c.JSON(503, gin.H{"error": "sync service not configured"})
+		return
+	}
+	c.JSON(200, h.tracker.Progress())
+}
+
+func (h *SyncHandler) handleCancel(c *gin.Context) {
+	if h.tracker == nil {
+		c.JSON(503, gin.H{"error": "sync service not configured"})
+		return
+	}
+	if !h.tracker.Cancel() {
+		c.JSON(409, gin.H{"error": "no sync in progress"})
+		return
+	}
+	if h.logger != nil {
+		h.logger.Info("sync cancelled via http")
+	}
+	c.JSON(200, gin.H{"cancelled": true, "progress": h.tracker.Progress()})
+}
@@ -0,0 +1,120 @@
+package router
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	rca "cmdb2neo/internal/rca"
+	"cmdb2neo/pkg/logging"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// TopologyHandler 提供只读的拓扑邻域查询，便于排查根因分析为何归因到某个节点。
+type TopologyHandler struct {
+	reader   rca.NeighborhoodReader
+	logger   *zap.Logger
+	maxNodes int
+	maxDepth int
+}
+
+// TopologyHandlerOption 用于定制 TopologyHandler。
+type TopologyHandlerOption func(*TopologyHandler)
+
+// WithMaxTopologyNodes 限制单次查询返回的节点数，请求的 limit 超出时按上限截取，n<=0 时取 rca.DefaultNeighborhoodNodes。
+func WithMaxTopologyNodes(n int) TopologyHandlerOption {
+	return func(h *TopologyHandler) {
+		if n > 0 {
+			h.maxNodes = n
+		}
+	}
+}
+
+// WithMaxTopologyDepth 限制可查询的最大跳数，n<=0 或超过 rca.MaxNeighborhoodDepth 时取 rca.MaxNeighborhoodDepth。
+func WithMaxTopologyDepth(n int) TopologyHandlerOption {
+	return func(h *TopologyHandler) {
+		if n > 0 && n <= rca.MaxNeighborhoodDepth {
+			h.maxDepth = n
+		}
+	}
+}
+
+// NewTopologyHandler 构建拓扑查询处理器。
+func NewTopologyHandler(reader rca.NeighborhoodReader, logger *zap.Logger, opts ...TopologyHandlerOption) *TopologyHandler {
+	h := &TopologyHandler{
+		reader:   reader,
+		logger:   logger,
+		maxNodes: rca.DefaultNeighborhoodNodes,
+		maxDepth: rca.MaxNeighborhoodDepth,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(h)
+		}
+	}
+	return h
+}
+
+// WithTopologyHandler 在 /api/v1/topology 注册拓扑查询路由。
+func WithTopologyHandler(handler *TopologyHandler) EngineOption {
+	return func(engine *gin.Engine) {
+		if handler != nil {
+			handler.RegisterRoutes(engine.Group("/api/v1/topology"))
+		}
+	}
+}
+
+// RegisterRoutes 将拓扑查询路由注册到给定的路由组。
+func (h *TopologyHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/:cmdb_key", h.handleNeighborhood)
+}
+
+// handleNeighborhood 返回节点 depth 跳以内的邻居与关系；rel_type 可重复或逗号分隔以过滤关系类型，limit 限制节点数。
+func (h *TopologyHandler) handleNeighborhood(c *gin.Context) {
+	if h.reader == nil {
+		c.JSON(503, gin.H{"error": "topology reader not configured"})
+		return
+	}
+	depth, err := strconv.Atoi(c.DefaultQuery("depth", strconv.Itoa(rca.DefaultNeighborhoodDepth)))
+	if err != nil || depth < 1 || depth > h.maxDepth {
+		c.JSON(400, gin.H{"error": "depth must be an integer between 1 and " + strconv.Itoa(h.maxDepth)})
+		return
+	}
+	limit := h.maxNodes
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(400, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		if n < limit {
+			limit = n
+		}
+	}
+	var types []string
+	for _, raw := range c.QueryArray("rel_type") {
+		for _, t := range strings.Split(raw, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				types = append(types, t)
+			}
+		}
+	}
+
+	result, err := h.reader.Neighborhood(c.Request.Context(), rca.NeighborhoodQuery{
+		Key:      c.Param("cmdb_key"),
+		Depth:    depth,
+		RelTypes: types,
+		MaxNodes: limit,
+	})
+	if err != nil {
+		if errors.Is(err, rca.ErrNodeNotFound) {
+			c.JSON(404, gin.H{"error": "node not found"})
+			return
+		}
+		logging.FromContext(c.Request.Context(), h.logger).Warn("topology query failed", zap.String("cmdb_key", c.Param("cmdb_key")), zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, result)
+}
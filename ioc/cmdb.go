@@ -6,46 +6,85 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
 // InitCMDBClient 构建 CMDB 数据源客户端。
-func InitCMDBClient(cfg *app.Config) (cmdb.Client, error) {
-	return newCmdbClient(cfg)
+func InitCMDBClient(cfg *app.Config, logger *zap.Logger, tracer trace.TracerProvider) (cmdb.Client, error) {
+	return newCmdbClient(cfg, logger, tracer)
 }
 
-func newCmdbClient(cfg *app.Config) (cmdb.Client, error) {
+func newCmdbClient(cfg *app.Config, logger *zap.Logger, tracer trace.TracerProvider) (cmdb.Client, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("config is nil")
 	}
 	baseURL := strings.TrimSpace(cfg.Sync.Source.BaseURL)
 	if baseURL == "" {
+		if dir := strings.TrimSpace(cfg.Sync.Source.FileDir); dir != "" {
+			return cmdb.NewFileClient(dir)
+		}
 		if cfg.Sync.InitialResync {
-			return nil, fmt.Errorf("sync.source.base_url is required for initial resync")
+			return nil, fmt.Errorf("sync.source.base_url or sync.source.file_dir is required for initial resync")
 		}
 		return &cmdb.StaticClient{}, nil
 	}
 
-	var tokenSource cmdb.TokenSource
-	if cfg.Sync.Source.AuthEndpoint != "" && cfg.Sync.Source.Username != "" {
-		ts, err := cmdb.NewPasswordTokenSource(cmdb.PasswordTokenConfig{
-			Endpoint: cfg.Sync.Source.AuthEndpoint,
-			Username: cfg.Sync.Source.Username,
-			Password: cfg.Sync.Source.Password,
-			Timeout:  5 * time.Second,
-		})
-		if err != nil {
-			return nil, err
-		}
-		tokenSource = ts
-	} else if cfg.Sync.Source.StaticToken != "" {
-		tokenSource = &cmdb.StaticTokenSource{Value: cfg.Sync.Source.StaticToken}
+	tlsConfig := cmdb.TLSConfig{
+		CertFile:           cfg.Sync.Source.TLS.CertFile,
+		KeyFile:            cfg.Sync.Source.TLS.KeyFile,
+		CAFile:             cfg.Sync.Source.TLS.CAFile,
+		InsecureSkipVerify: cfg.Sync.Source.TLS.InsecureSkipVerify,
+	}
+	tokenSource, err := newTokenSource(cfg.Sync.Source, tlsConfig)
+	if err != nil {
+		return nil, err
 	}
 
 	httpCfg := cmdb.HTTPConfig{
-		BaseURL:        baseURL,
-		TokenSource:    tokenSource,
-		SnapshotAPI:    cfg.Sync.Source.SnapshotAPI,
-		AuthHeaderName: cfg.Sync.Source.AuthHeader,
+		BaseURL:         baseURL,
+		TokenSource:     tokenSource,
+		SnapshotAPI:     cfg.Sync.Source.SnapshotAPI,
+		PartitionAPI:    cfg.Sync.Source.PartitionAPI,
+		AuthHeaderName:  cfg.Sync.Source.AuthHeader,
+		AuthScheme:      cfg.Sync.Source.AuthScheme,
+		TLS:             tlsConfig,
+		RetryAttempts:   cfg.Sync.Retry.Attempts,
+		RetryBackoff:    time.Duration(cfg.Sync.Retry.BackoffSeconds) * time.Second,
+		Workers:         cfg.Sync.ParallelWorkers,
+		IDCs:            cfg.Sync.Source.IDCs,
+		PaginationMode:  cmdb.PaginationMode(cfg.Sync.Source.Pagination),
+		MaxInvalidRatio: cfg.Sync.Source.MaxInvalidRatio,
+		Logger:          logger,
+		TracerProvider:  tracer,
 	}
 	return cmdb.NewHTTPClient(httpCfg)
 }
+
+// newTokenSource 按已配置的认证项选择 TokenSource：auth_endpoint 配合 client_id 使用 OAuth2 client credentials，
+// 配合 username 使用用户名密码，否则使用 static_token，都未配置时不带认证头。
+func newTokenSource(src app.SyncSource, tlsConfig cmdb.TLSConfig) (cmdb.TokenSource, error) {
+	switch {
+	case src.AuthEndpoint != "" && src.ClientID != "":
+		return cmdb.NewClientCredentialsTokenSource(cmdb.ClientCredentialsConfig{
+			Endpoint:     src.AuthEndpoint,
+			ClientID:     src.ClientID,
+			ClientSecret: src.ClientSecret,
+			Scope:        src.Scope,
+			Timeout:      5 * time.Second,
+			TLS:          tlsConfig,
+		})
+	case src.AuthEndpoint != "" && src.Username != "":
+		return cmdb.NewPasswordTokenSource(cmdb.PasswordTokenConfig{
+			Endpoint: src.AuthEndpoint,
+			Username: src.Username,
+			Password: src.Password,
+			Timeout:  5 * time.Second,
+			TLS:      tlsConfig,
+		})
+	case src.StaticToken != "":
+		return &cmdb.StaticTokenSource{Value: src.StaticToken}, nil
+	}
+	return nil, nil
+}
@@ -2,12 +2,16 @@ package ioc
 
 import (
 	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/domain"
 	"strings"
 )
 
 const defaultConfigPath = "configs/config.yaml"
 
-var configPath = defaultConfigPath
+var (
+	configPath  = defaultConfigPath
+	allowDelete bool
+)
 
 // SetConfigPath 设置配置文件路径。
 func SetConfigPath(path string) {
@@ -16,7 +20,28 @@ func SetConfigPath(path string) {
 	}
 }
 
+// SetAllowDelete 由命令行开启删除，优先于配置文件中的 sync.allow_delete。
+func SetAllowDelete(allow bool) {
+	allowDelete = allow
+}
+
 // InitConfig 读取应用配置。
 func InitConfig() (*app.Config, error) {
-	return app.LoadConfig(configPath)
+	cfg, err := app.LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if allowDelete {
+		cfg.Sync.AllowDelete = true
+	}
+	// 命令行覆盖后再校验一次，保证注入到各组件的配置始终合法
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// InitSchema 按 schema 配置构建图中使用的标签、关系类型与 cmdb_key 前缀。
+func InitSchema(cfg *app.Config) (domain.Schema, error) {
+	return cfg.Schema.Build()
 }
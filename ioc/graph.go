@@ -3,13 +3,26 @@ package ioc
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"cmdb2neo/internal/app"
 	"cmdb2neo/internal/graph"
+	"cmdb2neo/internal/metrics"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
-// InitGraphClient 构建只读图数据库客户端。
-func InitGraphClient(ctx context.Context, cfg *app.Config) (*graph.Client, error) {
+// InitBookmarkManager 在开启 neo4j.routing 时返回同步与 RCA 共享的书签管理器，单实例部署时为 nil。
+func InitBookmarkManager(cfg *app.Config) neo4j.BookmarkManager {
+	if cfg == nil || !cfg.Neo4j.Routing {
+		return nil
+	}
+	return neo4j.NewBookmarkManager(neo4j.BookmarkManagerConfig{})
+}
+
+// InitGraphClient 构建图数据库客户端。
+func InitGraphClient(ctx context.Context, cfg *app.Config, recorder metrics.Recorder, tracer trace.TracerProvider, bookmarks neo4j.BookmarkManager) (*graph.Client, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("config is nil")
 	}
@@ -20,5 +33,27 @@ func InitGraphClient(ctx context.Context, cfg *app.Config) (*graph.Client, error
 		Database:             cfg.Neo4j.Database,
 		MaxConnectionPool:    cfg.Neo4j.MaxConnectionPool,
 		ConnectionTimeoutSec: cfg.Neo4j.ConnectTimeoutSecond,
-	})
+		QueryTimeoutSec:      cfg.Neo4j.QueryTimeoutSecond,
+		Routing:              cfg.Neo4j.Routing,
+		CacheTTL:             time.Duration(cfg.Neo4j.QueryCacheTTLSecond) * time.Second,
+		CacheSize:            cfg.Neo4j.QueryCacheSize,
+	}, graph.WithRecorder(recorder), graph.WithTracerProvider(tracer), graph.WithBookmarkManager(bookmarks))
+}
+
+// InitConnectionMonitors 为 RCA 与同步两个 Neo4j 客户端构建连通性监控，持续失败时重建 driver 并让就绪探针失败。
+func InitConnectionMonitors(cfg *app.Config, client *graph.Client, svc *app.Service, recorder metrics.Recorder, logger *zap.Logger) graph.ConnectionMonitors {
+	opts := []graph.MonitorOption{graph.WithMonitorRecorder(recorder), graph.WithMonitorLogger(logger)}
+	if cfg != nil && cfg.Neo4j.HealthCheckIntervalSecond > 0 {
+		opts = append(opts, graph.WithCheckInterval(time.Duration(cfg.Neo4j.HealthCheckIntervalSecond)*time.Second))
+	}
+	var monitors graph.ConnectionMonitors
+	if client != nil {
+		monitors = append(monitors, graph.NewConnectionMonitor("graph", client, opts...))
+	}
+	if svc != nil {
+		if m := svc.ConnectionMonitor(opts...); m != nil {
+			monitors = append(monitors, m)
+		}
+	}
+	return monitors
 }
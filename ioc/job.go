@@ -2,27 +2,32 @@ package ioc
 
 import (
 	"context"
+	"time"
 
 	"cmdb2neo/internal/app"
 	"cmdb2neo/internal/job"
 	"go.uber.org/zap"
 )
 
-// InitScheduler 构建定时任务调度器。
-<<<<<<< HEAD
-func InitScheduler(cfg *app.Config, logger *zap.Logger) *job.Scheduler {
-	return job.NewScheduler(cfg, logger)
-=======
-func InitScheduler(cfg app.Config, svc *app.Service, logger *zap.Logger) *job.Scheduler {
+// InitScheduler 构建定时任务调度器，暂时性错误按 job_retry 重跑，开启 distributed_lock 时多副本间通过 Neo4j 租约锁互斥。
+func InitScheduler(cfg *app.Config, svc *app.Service, logger *zap.Logger) *job.Scheduler {
 	var syncFn func(context.Context) error
+	var opts []job.SchedulerOption
+	if cfg != nil {
+		opts = append(opts, job.WithTransientRetry(cfg.Sync.JobRetry.Attempts, time.Duration(cfg.Sync.JobRetry.BackoffSeconds)*time.Second))
+	}
 	if svc != nil {
 		syncFn = svc.Sync
+		if cfg != nil && cfg.Sync.DistributedLock {
+			if lock := svc.SyncLock(""); lock != nil {
+				opts = append(opts, job.WithLocker(lock, time.Duration(cfg.Sync.LockTTLSeconds)*time.Second))
+			}
+		}
 	}
-	return job.NewScheduler(cfg, syncFn, logger)
+	return job.NewScheduler(job.ConfigJobs(cfg, syncFn), logger, opts...)
 }
 
 // InitHourlyLogger 构建每小时日志任务。
 func InitHourlyLogger(logger *zap.Logger) *job.HourlyLogger {
 	return job.NewHourlyLogger(logger)
->>>>>>> recovery-branch
 }
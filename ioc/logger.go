@@ -1,11 +1,12 @@
 package ioc
 
 import (
+	"cmdb2neo/internal/app"
 	"cmdb2neo/pkg/logging"
 	"go.uber.org/zap"
 )
 
-// InitLogger 构建全局 logger。
-func InitLogger() (*zap.Logger, error) {
-	return logging.NewZpaLogger()
+// InitLogger 按 log.level 构建全局 logger。
+func InitLogger(cfg *app.Config) (*zap.Logger, error) {
+	return logging.NewLevelLogger(cfg.Log.Level)
 }
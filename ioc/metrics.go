@@ -0,0 +1,8 @@
+package ioc
+
+import "cmdb2neo/internal/metrics"
+
+// InitMetrics 构建 Prometheus 指标，同时作为各模块的指标记录器注入。
+func InitMetrics() *metrics.Prometheus {
+	return metrics.NewPrometheus()
+}
@@ -1,8 +1,16 @@
 package ioc
 
 import (
+	"context"
+	"time"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/domain"
 	"cmdb2neo/internal/graph"
+	"cmdb2neo/internal/metrics"
 	"cmdb2neo/internal/rca"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
 // InitRCAConfig 返回默认根因分析配置。
@@ -10,12 +18,58 @@ func InitRCAConfig() rca.Config {
 	return rca.DefaultConfig()
 }
 
-// InitRCAProvider 构建拓扑数据提供者。
-func InitRCAProvider(client graph.Reader) rca.TopologyProvider {
-	return rca.NewGraphProvider(client)
+// InitPartitionRefresher 构建网络分区 CIDR 索引刷新器并在启动时加载一次，加载失败时暂不启用按 IP 回退到分区，
+// 之后由 HTTP 服务按 rca.partition_refresh_seconds 定期重建。
+func InitPartitionRefresher(cfg *app.Config, client graph.Reader, schema domain.Schema, logger *zap.Logger) *rca.PartitionRefresher {
+	var interval time.Duration
+	if cfg != nil {
+		interval = time.Duration(cfg.RCA.PartitionRefreshSeconds) * time.Second
+	}
+	refresher := rca.NewPartitionRefresher(client, schema, interval, logger)
+	if err := refresher.Refresh(context.Background()); err != nil && logger != nil {
+		logger.Warn("partition cidr index unavailable, ip fallback disabled until next refresh", zap.Error(err))
+	}
+	return refresher
+}
+
+// InitRCAProvider 构建拓扑数据提供者，按 IP 回退到网络分区时使用 partitions 中的当前索引。
+func InitRCAProvider(cfg *app.Config, client graph.Reader, schema domain.Schema, partitions *rca.PartitionRefresher, recorder metrics.Recorder, logger *zap.Logger, tracer trace.TracerProvider) rca.TopologyProvider {
+	opts := []rca.GraphProviderOption{
+		rca.WithProviderRecorder(recorder),
+		rca.WithProviderTracerProvider(tracer),
+		rca.WithSchema(schema),
+		rca.WithPartitionSource(partitions),
+	}
+	// 告警风暴时同一主机、VM 会反复出现，短 TTL 缓存避免重复查询，拓扑变更最多延迟一个 TTL 生效
+	if cfg != nil && cfg.RCA.ChainCacheSize > 0 && cfg.RCA.ChainCacheTTLSeconds > 0 {
+		opts = append(opts, rca.WithCache(rca.CacheConfig{
+			Size: cfg.RCA.ChainCacheSize,
+			TTL:  time.Duration(cfg.RCA.ChainCacheTTLSeconds) * time.Second,
+		}))
+	}
+	if logger != nil && cfg != nil && cfg.RCA.ReportLayerConflicts {
+		opts = append(opts, rca.WithConflictReporter(func(conflict rca.LayerConflict) {
+			logger.Warn("ip matches multiple layers",
+				zap.String("ip", conflict.IP),
+				zap.String("expected", string(conflict.Expected)),
+				zap.Any("layers", conflict.Layers))
+		}))
+	}
+	return rca.NewGraphProvider(client, opts...)
+}
+
+// InitReplayRCAProvider 构建回放使用的拓扑数据提供者：回放逐步改写拓扑，不启用缓存，
+// 也不加载启动时的分区 CIDR 索引，避免后续步骤读到前一步的拓扑。
+func InitReplayRCAProvider(client graph.Reader, schema domain.Schema, tracer trace.TracerProvider) rca.TopologyProvider {
+	return rca.NewGraphProvider(client, rca.WithProviderTracerProvider(tracer), rca.WithSchema(schema))
+}
+
+// InitRCAResultStore 构建保存分析结果的存储。
+func InitRCAResultStore(client graph.ReadWriter) *rca.GraphResultStore {
+	return rca.NewGraphResultStore(client)
 }
 
-// InitRCAAnalyzer 构建根因分析器。
-func InitRCAAnalyzer(provider rca.TopologyProvider, cfg rca.Config) (*rca.Analyzer, error) {
-	return rca.NewAnalyzer(provider, cfg)
+// InitRCAAnalyzer 构建根因分析器，带窗口 ID 的分析结果写入 store。
+func InitRCAAnalyzer(provider rca.TopologyProvider, cfg rca.Config, store rca.ResultStore, recorder metrics.Recorder, tracer trace.TracerProvider, logger *zap.Logger) (*rca.Analyzer, error) {
+	return rca.NewAnalyzer(provider, cfg, rca.WithResultStore(store), rca.WithRecorder(recorder), rca.WithTracerProvider(tracer), rca.WithLogger(logger))
 }
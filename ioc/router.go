@@ -1,18 +1,80 @@
 package ioc
 
 import (
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/graph"
+	"cmdb2neo/internal/metrics"
 	"cmdb2neo/internal/rca"
 	"cmdb2neo/internal/router"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
-// InitRCAHandler 构建根因分析 HTTP 处理器。
-func InitRCAHandler(analyzer *rca.Analyzer, logger *zap.Logger) *router.RCAHandler {
-	return router.NewRCAHandler(analyzer, logger)
+// InitRCAHandler 构建根因分析 HTTP 处理器，provider 支持读取告警节点时开启 recent 接口；请求规模与限流取自 http.rca 配置。
+func InitRCAHandler(analyzer *rca.Analyzer, provider rca.TopologyProvider, results rca.ResultReader, cfg *app.Config, logger *zap.Logger, tracer trace.TracerProvider) *router.RCAHandler {
+	opts := []router.RCAHandlerOption{router.WithResultReader(results), router.WithTracerProvider(tracer)}
+	if cfg != nil {
+		limits := cfg.HTTP.RCA
+		opts = append(opts, router.WithMaxEvents(limits.MaxEvents), router.WithMaxBodyBytes(int64(limits.MaxBodyBytes)))
+		opts = append(opts, router.WithJobRegistry(rca.NewJobRegistry(rca.DefaultJobTTL, rca.WithMaxInFlight(limits.MaxAsyncJobs))))
+		if limits.RateLimitPerSecond >= 0 {
+			opts = append(opts, router.WithRateLimiter(router.NewRateLimiter(limits.RateLimitPerSecond, limits.RateLimitBurst)))
+		}
+	}
+	if source, ok := provider.(rca.AlarmSource); ok {
+		opts = append(opts, router.WithAlarmSource(source))
+	}
+	return router.NewRCAHandler(analyzer, logger, opts...)
 }
 
-// InitGinEngine 构建 gin 引擎。
-func InitGinEngine(rcaHandler *router.RCAHandler) *gin.Engine {
-	return router.NewEngine(rcaHandler)
+// InitTopologyHandler 构建拓扑邻域查询处理器，节点数与跳数上限取自 http.topology 配置。
+func InitTopologyHandler(client graph.Reader, schema domain.Schema, cfg *app.Config, logger *zap.Logger) *router.TopologyHandler {
+	var opts []router.TopologyHandlerOption
+	if cfg != nil {
+		opts = append(opts, router.WithMaxTopologyNodes(cfg.HTTP.Topology.MaxNodes), router.WithMaxTopologyDepth(cfg.HTTP.Topology.MaxDepth))
+	}
+	return router.NewTopologyHandler(rca.NewGraphNeighborhoodReader(client, rca.WithNeighborhoodSchema(schema)), logger, opts...)
+}
+
+// InitAppHandler 构建应用部署范围查询处理器。
+func InitAppHandler(client graph.Reader, schema domain.Schema, logger *zap.Logger) *router.AppHandler {
+	return router.NewAppHandler(rca.NewGraphFootprintReader(client, rca.WithFootprintSchema(schema)), logger)
+}
+
+// InitNodesHandler 构建节点分页列表处理器。
+func InitNodesHandler(client graph.Reader, schema domain.Schema, logger *zap.Logger) *router.NodesHandler {
+	return router.NewNodesHandler(client, logger, router.WithNodesSchema(schema))
+}
+
+// InitSyncHandler 构建同步触发与进度 HTTP 处理器。
+func InitSyncHandler(svc *app.Service, logger *zap.Logger) *router.SyncHandler {
+	if svc == nil {
+		return router.NewSyncHandler(nil, logger)
+	}
+	return router.NewSyncHandler(svc.Tracker(), logger, router.WithSyncTrigger(svc))
+}
+
+// InitHealthHandler 构建存活与就绪探针，就绪检查依赖 Neo4j 连通性、后台连通性监控与已加载的配置。
+func InitHealthHandler(client *graph.Client, cfg *app.Config, monitors graph.ConnectionMonitors) *router.HealthHandler {
+	var opts []router.HealthOption
+	if len(monitors) > 0 {
+		opts = append(opts, router.WithConnectionStatus(monitors))
+	}
+	if client == nil {
+		return router.NewHealthHandler(nil, cfg != nil, opts...)
+	}
+	return router.NewHealthHandler(client, cfg != nil, opts...)
+}
+
+// InitGinEngine 构建 gin 引擎，按配置信任反向代理，为每个请求记录带 X-Request-ID 的访问日志，注册拓扑、应用与节点列表查询并暴露 /metrics。
+func InitGinEngine(rcaHandler *router.RCAHandler, syncHandler *router.SyncHandler, topologyHandler *router.TopologyHandler, appHandler *router.AppHandler, nodesHandler *router.NodesHandler, prom *metrics.Prometheus, cfg *app.Config, logger *zap.Logger) *gin.Engine {
+	return router.NewEngine(rcaHandler, syncHandler,
+		router.WithTrustedProxies(cfg.HTTP.TrustedProxies),
+		router.WithRequestLogging(logger),
+		router.WithTopologyHandler(topologyHandler),
+		router.WithAppHandler(appHandler),
+		router.WithNodesHandler(nodesHandler),
+		router.WithMetricsHandler(prom.Handler()))
 }
@@ -5,9 +5,12 @@ import (
 
 	"cmdb2neo/internal/app"
 	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/metrics"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // InitAppService 构建 CMDB 同步服务。
-func InitAppService(ctx context.Context, cfg *app.Config, client cmdb.Client) (*app.Service, error) {
-	return app.NewService(ctx, cfg, client)
+func InitAppService(ctx context.Context, cfg *app.Config, client cmdb.Client, recorder metrics.Recorder, tracer trace.TracerProvider, bookmarks neo4j.BookmarkManager) (*app.Service, error) {
+	return app.NewService(ctx, cfg, client, app.WithRecorder(recorder), app.WithTracerProvider(tracer), app.WithBookmarkManager(bookmarks))
 }
@@ -0,0 +1,11 @@
+package ioc
+
+import (
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// InitTracerProvider 返回各模块共用的 TracerProvider，默认不导出 span；接入 collector 时在此替换为 SDK 实现。
+func InitTracerProvider() trace.TracerProvider {
+	return noop.NewTracerProvider()
+}
@@ -2,18 +2,23 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strings"
 
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/metrics"
 	"cmdb2neo/ioc"
 )
 
 func main() {
 	env := flag.String("env", "local", "configuration environment: local|test|prod")
 	configPath := flag.String("config", "", "path to configuration file (overrides -env)")
+	allowDelete := flag.Bool("allow-delete", false, "allow sync to delete graph data (overrides sync.allow_delete)")
 	flag.Parse()
 
 	path, err := resolveConfigPath(*env, *configPath)
@@ -25,22 +30,211 @@ func main() {
 	}
 
 	ioc.SetConfigPath(path)
+	ioc.SetAllowDelete(*allowDelete)
 	log.Printf("using config: %s", path)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	app, cleanup, err := InitApp(ctx)
+	switch flag.Arg(0) {
+	case "validate":
+		os.Exit(runValidate(ctx))
+	case "reconcile":
+		os.Exit(runReconcile(ctx))
+	case "replay":
+		os.Exit(runReplay(ctx, flag.Args()[1:]))
+	}
+
+	srv, cleanup, err := InitApp(ctx)
 	if err != nil {
 		log.Fatalf("init app failed: %v", err)
 	}
 	defer cleanup()
 
-	if err := app.Run(ctx); err != nil {
+	if err := srv.Run(ctx); err != nil {
 		log.Fatalf("app run failed: %v", err)
 	}
 }
 
+// initService 为一次性子命令构建同步服务，不启动 HTTP 服务与定时任务。
+func initService(ctx context.Context) (*app.Service, error) {
+	cfg, err := ioc.InitConfig()
+	if err != nil {
+		return nil, fmt.Errorf("load config failed: %w", err)
+	}
+	logger, err := ioc.InitLogger(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("init logger failed: %w", err)
+	}
+	tracer := ioc.InitTracerProvider()
+	cmdbClient, err := ioc.InitCMDBClient(cfg, logger, tracer)
+	if err != nil {
+		return nil, fmt.Errorf("init cmdb client failed: %w", err)
+	}
+	// 一次性子命令没有采集端点，不记录指标
+	svc, err := ioc.InitAppService(ctx, cfg, cmdbClient, metrics.Nop{}, tracer, ioc.InitBookmarkManager(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("init app service failed: %w", err)
+	}
+	return svc, nil
+}
+
+// runValidate 执行图一致性检查并把报告输出到 stdout，存在问题时返回非零退出码，便于部署前卡点。
+func runValidate(ctx context.Context) int {
+	svc, err := initService(ctx)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	defer svc.Close(ctx)
+
+	report, err := svc.Validate(ctx)
+	if err != nil {
+		log.Printf("validate failed: %v", err)
+		return 2
+	}
+	printJSON(report)
+	if !report.OK() {
+		return 1
+	}
+	return 0
+}
+
+// runReconcile 修复 CMDB 与图之间的漂移并把汇总输出到 stdout。
+func runReconcile(ctx context.Context) int {
+	svc, err := initService(ctx)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	defer svc.Close(ctx)
+
+	summary, err := svc.Reconcile(ctx)
+	if err != nil {
+		log.Printf("reconcile failed: %v", err)
+		return 1
+	}
+	printJSON(summary)
+	return 0
+}
+
+// runReplay 按时间顺序回放目录中的快照与告警窗口，每步同步后分析并把候选输出到 stdout。
+func runReplay(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	fromFlag := fs.String("from", "", "replay steps at or after this time (RFC3339 or 20060102T150405Z)")
+	toFlag := fs.String("to", "", "replay steps at or before this time (RFC3339 or 20060102T150405Z)")
+	uriFlag := fs.String("neo4j-uri", "", "neo4j uri of the dedicated replay graph")
+	databaseFlag := fs.String("neo4j-database", "", "neo4j database of the dedicated replay graph")
+	wipeFlag := fs.Bool("wipe", false, "delete existing CMDB data in the replay graph before replaying")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Print("usage: replay (--neo4j-uri URI | --neo4j-database DB) [--wipe] [--from TIME] [--to TIME] <dir>")
+		return 2
+	}
+	target := replayTarget{uri: strings.TrimSpace(*uriFlag), database: strings.TrimSpace(*databaseFlag), wipe: *wipeFlag}
+	if target.uri == "" && target.database == "" {
+		// 回放会改写拓扑，不允许落到配置中的线上图
+		log.Print("replay requires --neo4j-uri or --neo4j-database pointing at a dedicated graph")
+		return 2
+	}
+	from, err := app.ParseReplayTime(*fromFlag)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	to, err := app.ParseReplayTime(*toFlag)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	steps, err := app.LoadReplaySteps(fs.Arg(0), from, to)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	if len(steps) == 0 {
+		log.Printf("no replay steps found in %s", fs.Arg(0))
+		return 2
+	}
+
+	flow, cleanup, err := initReplay(ctx, target)
+	if err != nil {
+		log.Print(err)
+		return 2
+	}
+	defer cleanup()
+
+	results, err := flow.Run(ctx, steps)
+	printJSON(results)
+	if err != nil {
+		log.Printf("replay failed: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// replayTarget 为回放专用的图，uri 与 database 为空时沿用配置。
+type replayTarget struct {
+	uri      string
+	database string
+	wipe     bool
+}
+
+// initReplay 构建回放流程：连接 target 指定的图，同步与分析共用书签管理器，保证每步分析读到该步写入后的拓扑；
+// 分析结果只输出到 stdout，不写入结果存储。
+func initReplay(ctx context.Context, target replayTarget) (*app.ReplayFlow, func(), error) {
+	cfg, err := ioc.InitConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("load config failed: %w", err)
+	}
+	if target.uri != "" {
+		cfg.Neo4j.URI = target.uri
+	}
+	if target.database != "" {
+		cfg.Neo4j.Database = target.database
+	}
+	schema, err := ioc.InitSchema(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("init schema failed: %w", err)
+	}
+	logger, err := ioc.InitLogger(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("init logger failed: %w", err)
+	}
+	tracer := ioc.InitTracerProvider()
+	bookmarks := ioc.InitBookmarkManager(cfg)
+	// 回放的数据源按步骤替换为快照目录，这里的 CMDB 客户端不会被调用
+	svc, err := ioc.InitAppService(ctx, cfg, &cmdb.StaticClient{}, metrics.Nop{}, tracer, bookmarks)
+	if err != nil {
+		return nil, nil, fmt.Errorf("init app service failed: %w", err)
+	}
+	// 查询缓存会让后续步骤读到旧拓扑，回放时关闭
+	graphCfg := *cfg
+	graphCfg.Neo4j.QueryCacheTTLSecond = 0
+	client, err := ioc.InitGraphClient(ctx, &graphCfg, metrics.Nop{}, tracer, bookmarks)
+	if err != nil {
+		_ = svc.Close(ctx)
+		return nil, nil, fmt.Errorf("init graph client failed: %w", err)
+	}
+	provider := ioc.InitReplayRCAProvider(client, schema, tracer)
+	analyzer, err := ioc.InitRCAAnalyzer(provider, ioc.InitRCAConfig(), nil, metrics.Nop{}, tracer, logger)
+	if err != nil {
+		_ = client.Close(ctx)
+		_ = svc.Close(ctx)
+		return nil, nil, fmt.Errorf("init analyzer failed: %w", err)
+	}
+	cleanup := func() {
+		_ = client.Close(ctx)
+		_ = svc.Close(ctx)
+	}
+	return &app.ReplayFlow{Sync: svc.SyncFlow, Analyzer: analyzer, Reset: svc.GraphResetter(), Wipe: target.wipe, Logger: logger}, cleanup, nil
+}
+
+func printJSON(v any) {
+	out, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Println(string(out))
+}
+
 func resolveConfigPath(env, override string) (string, error) {
 	if trimmed := strings.TrimSpace(override); trimmed != "" {
 		return trimmed, nil
@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type loggerKey struct{}
+
+type requestIDKey struct{}
+
+// nop 为上下文与 fallback 都没有日志器时返回的日志器。
+var nop = zap.NewNop()
+
+// WithLogger 把请求级日志器放入 ctx，下游通过 FromContext 取出，使同一请求的日志带相同的关联字段。
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	if logger == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext 返回 ctx 中的请求级日志器，没有时返回 fallback，fallback 也为 nil 时返回不输出的日志器。
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+			return logger
+		}
+	}
+	if fallback != nil {
+		return fallback
+	}
+	return nop
+}
+
+// WithRequestID 把请求 ID 放入 ctx。
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 返回 ctx 中的请求 ID，没有时返回空串。
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -1,10 +1,38 @@
 package logging
 
-import "go.uber.org/zap"
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
 
 func NewZpaLogger() (*zap.Logger, error) {
+	return NewLevelLogger("")
+}
+
+// NewLevelLogger 按 level 创建控制台格式的日志器，level 为空时为 info。
+func NewLevelLogger(level string) (*zap.Logger, error) {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
 	cfg := zap.NewDevelopmentConfig()
 	cfg.Encoding = "console"
-	cfg.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
+	cfg.Level = zap.NewAtomicLevelAt(lvl)
 	return cfg.Build()
 }
+
+// ParseLevel 解析 debug、info、warn、error 等日志级别，不区分大小写，空串为 info。
+func ParseLevel(level string) (zapcore.Level, error) {
+	level = strings.TrimSpace(level)
+	if level == "" {
+		return zapcore.InfoLevel, nil
+	}
+	lvl, err := zapcore.ParseLevel(strings.ToLower(level))
+	if err != nil {
+		return zapcore.InfoLevel, fmt.Errorf("unknown log level %q", level)
+	}
+	return lvl, nil
+}
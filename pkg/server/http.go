@@ -5,7 +5,10 @@ import (
 	"strings"
 
 	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/graph"
 	"cmdb2neo/internal/job"
+	"cmdb2neo/internal/rca"
+	"cmdb2neo/internal/router"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -18,21 +21,31 @@ type HTTPServer struct {
 	Service *app.Service
 	Job     *job.Scheduler
 	Hourly  *job.HourlyLogger
+	Health  *router.HealthHandler
+	// Monitors 周期性校验 Neo4j 连通性并在持续失败时重建 driver。
+	Monitors graph.ConnectionMonitors
+	// Partitions 定期重建 RCA 按 IP 回退使用的网络分区 CIDR 索引。
+	Partitions *rca.PartitionRefresher
+	// RCA 在关闭时取消未结束的异步分析任务。
+	RCA *router.RCAHandler
 }
 
-// NewHTTPServer 构建 HTTPServer。
-<<<<<<< HEAD
-func NewHTTPServer(engine *gin.Engine, logger *zap.Logger, cfg *app.Config, svc *app.Service, scheduler *job.Scheduler) *HTTPServer {
-=======
-func NewHTTPServer(engine *gin.Engine, logger *zap.Logger, cfg app.Config, svc *app.Service, scheduler *job.Scheduler, hourly *job.HourlyLogger) *HTTPServer {
->>>>>>> recovery-branch
+// NewHTTPServer 构建 HTTPServer，health 不为空时在 engine 上注册 /healthz 与 /readyz。
+func NewHTTPServer(engine *gin.Engine, logger *zap.Logger, cfg *app.Config, svc *app.Service, scheduler *job.Scheduler, hourly *job.HourlyLogger, health *router.HealthHandler, monitors graph.ConnectionMonitors, partitions *rca.PartitionRefresher, rcaHandler *router.RCAHandler) *HTTPServer {
+	if engine != nil && health != nil {
+		health.RegisterRoutes(engine)
+	}
 	return &HTTPServer{
-		Engine:  engine,
-		Logger:  logger,
-		Config:  cfg,
-		Service: svc,
-		Job:     scheduler,
-		Hourly:  hourly,
+		Engine:     engine,
+		Logger:     logger,
+		Config:     cfg,
+		Service:    svc,
+		Job:        scheduler,
+		Hourly:     hourly,
+		Health:     health,
+		Monitors:   monitors,
+		Partitions: partitions,
+		RCA:        rcaHandler,
 	}
 }
 
@@ -56,6 +69,14 @@ func (s *HTTPServer) Run(ctx context.Context) error {
 		cancelHourly = s.Hourly.Start(ctx)
 		defer cancelHourly()
 	}
+	if len(s.Monitors) > 0 {
+		cancelMonitors := s.Monitors.Start(ctx)
+		defer cancelMonitors()
+	}
+	if s.Partitions != nil {
+		cancelPartitions := s.Partitions.Start(ctx)
+		defer cancelPartitions()
+	}
 
 	initialResync := false
 	if s.Config != nil {
@@ -81,6 +102,9 @@ func (s *HTTPServer) Run(ctx context.Context) error {
 
 // Shutdown 释放资源。
 func (s *HTTPServer) Shutdown(ctx context.Context) {
+	if s.RCA != nil {
+		s.RCA.Close()
+	}
 	if s.Service != nil {
 		if err := s.Service.Close(ctx); err != nil && s.Logger != nil {
 			s.Logger.Warn("close app service failed", zap.Error(err))
@@ -7,6 +7,11 @@ import (
 
 // Retry 尝试执行 fn，失败则按退避重试。
 func Retry(ctx context.Context, attempts int, backoff time.Duration, fn func() error) error {
+	return RetryIf(ctx, attempts, backoff, nil, fn)
+}
+
+// RetryIf 与 Retry 相同，但仅在 retryable 返回 true 时重试；retryable 为 nil 表示任何错误都重试。
+func RetryIf(ctx context.Context, attempts int, backoff time.Duration, retryable func(error) bool, fn func() error) error {
 	if attempts <= 0 {
 		attempts = 1
 	}
@@ -19,6 +24,12 @@ func Retry(ctx context.Context, attempts int, backoff time.Duration, fn func() e
 		if err == nil {
 			return nil
 		}
+		if retryable != nil && !retryable(err) {
+			return err
+		}
+		if i == attempts-1 {
+			break
+		}
 		timer := time.NewTimer(backoff)
 		select {
 		case <-ctx.Done():
@@ -0,0 +1,76 @@
+package integration
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/graph"
+	"cmdb2neo/internal/rca"
+)
+
+func TestRecentAlarmsNormalizesStoredTimeTypes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	ctx := context.Background()
+
+	client, err := graph.NewClient(ctx, graph.Config{
+		URI:      "bolt://localhost:7687",
+		Username: "neo4j",
+		Password: "StrongPassw0rd",
+		Database: "neo4j",
+	})
+	if err != nil {
+		t.Skipf("neo4j not available: %v", err)
+	}
+	defer client.Close(ctx)
+
+	cleanup := func() {
+		_ = client.RunWrite(ctx, "MATCH (a:Alarm) WHERE a.rule_name STARTS WITH 'it-recent-' DELETE a", nil)
+	}
+	cleanup()
+	defer cleanup()
+
+	since := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	// 各存储类型各一条窗口内的告警（无时区字符串按 UTC），另有一条窗口外的字符串告警与一条格式错误的告警
+	err = client.RunWrite(ctx, `
+CREATE (:Alarm {rule_name: 'it-recent-datetime', occurred_at: datetime($dt)}),
+       (:Alarm {rule_name: 'it-recent-string', occurred_at: $str}),
+       (:Alarm {rule_name: 'it-recent-epoch', occurred_at: $epoch}),
+       (:Alarm {rule_name: 'it-recent-zoneless', occurred_at: $zoneless}),
+       (:Alarm {rule_name: 'it-recent-old', occurred_at: $old}),
+       (:Alarm {rule_name: 'it-recent-malformed', occurred_at: $malformed})
+`, map[string]any{
+		"dt":        since.Add(30 * time.Minute).Format(time.RFC3339),
+		"str":       since.Add(10 * time.Minute).Format(time.RFC3339),
+		"epoch":     since.Add(20 * time.Minute).UnixMilli(),
+		"old":       since.Add(-time.Hour).Format(time.RFC3339),
+		"zoneless":  since.Add(15 * time.Minute).Format("2006-01-02T15:04:05"),
+		"malformed": "2099-13-45T99:00:00",
+	})
+	if err != nil {
+		t.Fatalf("seed alarms: %v", err)
+	}
+
+	events, err := rca.NewGraphProvider(client).RecentAlarms(ctx, since)
+	if err != nil {
+		t.Fatalf("recent alarms: %v", err)
+	}
+	var rules []string
+	for _, evt := range events {
+		if strings.HasPrefix(evt.RuleName, "it-recent-") {
+			rules = append(rules, evt.RuleName)
+		}
+	}
+	want := []string{"it-recent-string", "it-recent-zoneless", "it-recent-epoch", "it-recent-datetime"}
+	if len(rules) != len(want) {
+		t.Fatalf("expect %v, got %v", want, rules)
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Fatalf("expect %v in time order, got %v", want, rules)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/loader"
+)
+
+func TestTransactionalBatchRollsBackPartialWrites(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	ctx := context.Background()
+
+	client, err := loader.NewClient(ctx, loader.Config{
+		URI:      "bolt://localhost:7687",
+		Username: "neo4j",
+		Password: "StrongPassw0rd",
+		Database: "neo4j",
+	})
+	if err != nil {
+		t.Skipf("neo4j not available: %v", err)
+	}
+	defer client.Close(ctx)
+
+	schema := loader.NewSchemaManager(client)
+	if err := schema.Reset(ctx, loader.ResetOptions{Confirm: true}); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+
+	// 第二批包含嵌套 map，Neo4j 不允许作为属性值，写入必然失败
+	rows := []domain.NodeRow{
+		{CMDBKey: "HM_1", Labels: []string{domain.LabelHostMachine}, Properties: map[string]any{"ip": "10.0.0.1"}, RunID: "run-1"},
+		{CMDBKey: "HM_2", Labels: []string{domain.LabelHostMachine}, Properties: map[string]any{"bad": map[string]any{"x": 1}}, RunID: "run-1"},
+	}
+	count := func() int64 {
+		records, err := client.RunRead(ctx, "MATCH (n:HostMachine) RETURN count(n) AS count", nil)
+		if err != nil {
+			t.Fatalf("count failed: %v", err)
+		}
+		return records[0]["count"].(int64)
+	}
+
+	upserter := loader.NewNodeUpserter(client, 1)
+	upserter.BatchTransactional = true
+	if _, err := upserter.UpsertNodes(ctx, rows); err == nil {
+		t.Fatal("expect transactional write to fail")
+	}
+	if n := count(); n != 0 {
+		t.Fatalf("expect transactional write rolled back, got %d nodes", n)
+	}
+
+	upserter.BatchTransactional = false
+	if _, err := upserter.UpsertNodes(ctx, rows); err == nil {
+		t.Fatal("expect per-chunk write to fail")
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("expect per-chunk write to keep the first chunk, got %d nodes", n)
+	}
+}
@@ -0,0 +1,190 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/graph"
+	"cmdb2neo/internal/loader"
+	"cmdb2neo/internal/rca"
+)
+
+func TestEdgeFixerBindsOutOfOrderNodes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	ctx := context.Background()
+
+	client, err := loader.NewClient(ctx, loader.Config{
+		URI:      "bolt://localhost:7687",
+		Username: "neo4j",
+		Password: "StrongPassw0rd",
+		Database: "neo4j",
+	})
+	if err != nil {
+		t.Skipf("neo4j not available: %v", err)
+	}
+	defer client.Close(ctx)
+
+	schema := loader.NewSchemaManager(client)
+	if err := schema.Reset(ctx, loader.ResetOptions{Confirm: true}); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if _, err := schema.Ensure(ctx); err != nil {
+		t.Fatalf("ensure schema failed: %v", err)
+	}
+
+	// 每批只写快照中的实体，端点不在同一批时映射器无法生成关系，模拟分页/增量写入的乱序到达
+	write := func(snapshot cmdb.Snapshot) {
+		t.Helper()
+		nodes, rels := cmdb.BuildInitRows(snapshot)
+		if _, err := loader.NewNodeUpserter(client, 100).UpsertNodes(ctx, nodes); err != nil {
+			t.Fatalf("upsert nodes failed: %v", err)
+		}
+		if _, err := loader.NewRelUpserter(client, 100).UpsertRels(ctx, rels); err != nil {
+			t.Fatalf("upsert rels failed: %v", err)
+		}
+	}
+	countEdges := func(query string) int64 {
+		t.Helper()
+		records, err := client.RunRead(ctx, query, nil)
+		if err != nil {
+			t.Fatalf("count edges failed: %v", err)
+		}
+		n, _ := records[0]["n"].(int64)
+		return n
+	}
+
+	// 更早批次写入的宿主机与之后才到达的虚拟机均不属于本次 runID，不应被补边
+	write(cmdb.Snapshot{RunID: "20250101T000000Z", HostMachines: []cmdb.HostMachine{{Id: 5, Ip: "10.0.0.5"}}})
+	write(cmdb.Snapshot{
+		RunID:           "20250102T000000Z",
+		VirtualMachines: []cmdb.VirtualMachine{{Id: 3, Ip: "10.0.1.3", HostIp: "10.0.0.1"}, {Id: 6, Ip: "10.0.1.6", HostIp: "10.0.0.5"}},
+		Apps:            []cmdb.App{{Id: 7, Ip: "10.0.1.4", Name: "late-vm", ServerType: "2"}},
+	})
+	runID := "20250103T000000Z"
+	write(cmdb.Snapshot{
+		RunID:           runID,
+		HostMachines:    []cmdb.HostMachine{{Id: 1, Ip: "10.0.0.1"}},
+		VirtualMachines: []cmdb.VirtualMachine{{Id: 4, Ip: "10.0.1.4", HostIp: "10.0.0.1"}},
+	})
+	if n := countEdges(`MATCH (:HostMachine {cmdb_key: "HM_1"})-[r:HOSTS_VM]->(:VirtualMachine {cmdb_key: "VM_3"}) RETURN count(r) AS n`); n != 0 {
+		t.Fatalf("expect VM_3 unparented before fix, got %d edges", n)
+	}
+
+	fixer := loader.NewEdgeFixer(client)
+	report, err := fixer.Run(ctx, runID)
+	if err != nil {
+		t.Fatalf("fix edges failed: %v", err)
+	}
+	fixes := make(map[string]loader.EdgeFix, len(report.Fixes))
+	for _, fix := range report.Fixes {
+		fixes[fix.Name()] = fix
+	}
+	if fixes["HOSTS_VM:VirtualMachine"].Created != 1 || fixes["DEPLOYED_ON:VirtualMachine"].Created != 1 || report.Created() != 2 || report.Repaired() != 0 {
+		t.Fatalf("expect one late HOSTS_VM and one late DEPLOYED_ON, got %+v", report)
+	}
+	if n := countEdges(`MATCH (:HostMachine {cmdb_key: "HM_1"})-[r:HOSTS_VM {last_seen_run_id: "20250103T000000Z"}]->(:VirtualMachine {cmdb_key: "VM_3"}) RETURN count(r) AS n`); n != 1 {
+		t.Fatalf("expect VM_3 parented by HM_1, got %d edges", n)
+	}
+	if n := countEdges(`MATCH (:App {cmdb_key: "APP_7"})-[r:DEPLOYED_ON]->(:VirtualMachine {cmdb_key: "VM_4"}) RETURN count(r) AS n`); n != 1 {
+		t.Fatalf("expect APP_7 deployed on late VM_4, got %d edges", n)
+	}
+	if n := countEdges(`MATCH (:HostMachine {cmdb_key: "HM_5"})-[r:HOSTS_VM]->(:VirtualMachine {cmdb_key: "VM_6"}) RETURN count(r) AS n`); n != 0 {
+		t.Fatalf("expect nodes outside run %s untouched, got %d edges", runID, n)
+	}
+
+	// 软删除的边在同一批次重跑时被恢复而不是重复创建
+	if _, err := client.RunWrite(ctx, `MATCH (:HostMachine {cmdb_key: "HM_1"})-[r:HOSTS_VM]->(:VirtualMachine {cmdb_key: "VM_3"})
+SET r.deleted = true, r.active = false, r.deleted_at = datetime()`, nil); err != nil {
+		t.Fatalf("tombstone edge failed: %v", err)
+	}
+	report, err = fixer.Run(ctx, runID)
+	if err != nil {
+		t.Fatalf("rerun fix edges failed: %v", err)
+	}
+	if report.Created() != 0 || report.Repaired() != 1 {
+		t.Fatalf("expect rerun to repair the tombstoned edge only, got %+v", report)
+	}
+	if n := countEdges(`MATCH (:HostMachine {cmdb_key: "HM_1"})-[r:HOSTS_VM]->(:VirtualMachine {cmdb_key: "VM_3"}) WHERE r.deleted = false AND r.deleted_at IS NULL RETURN count(r) AS n`); n != 1 {
+		t.Fatalf("expect repaired edge active, got %d", n)
+	}
+}
+
+func TestBareMetalAppResolvesThroughPhysicalMachine(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	ctx := context.Background()
+
+	client, err := loader.NewClient(ctx, loader.Config{
+		URI:      "bolt://localhost:7687",
+		Username: "neo4j",
+		Password: "StrongPassw0rd",
+		Database: "neo4j",
+	})
+	if err != nil {
+		t.Skipf("neo4j not available: %v", err)
+	}
+	defer client.Close(ctx)
+
+	schema := loader.NewSchemaManager(client)
+	if err := schema.Reset(ctx, loader.ResetOptions{Confirm: true}); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if _, err := schema.Ensure(ctx); err != nil {
+		t.Fatalf("ensure schema failed: %v", err)
+	}
+
+	write := func(snapshot cmdb.Snapshot) {
+		t.Helper()
+		nodes, rels := cmdb.BuildInitRows(snapshot)
+		if _, err := loader.NewNodeUpserter(client, 100).UpsertNodes(ctx, nodes); err != nil {
+			t.Fatalf("upsert nodes failed: %v", err)
+		}
+		if _, err := loader.NewRelUpserter(client, 100).UpsertRels(ctx, rels); err != nil {
+			t.Fatalf("upsert rels failed: %v", err)
+		}
+	}
+	// 应用先于它所在的物理机到达，由补边按 IP 绑定到物理机
+	write(cmdb.Snapshot{RunID: "20250101T000000Z", Apps: []cmdb.App{{Id: 7007, Ip: "10.0.2.10", Name: "billing-batch"}}})
+	runID := "20250102T000000Z"
+	write(cmdb.Snapshot{
+		RunID:             runID,
+		IDCs:              []cmdb.IDC{{Id: 1, Name: "M5"}},
+		NetworkPartitions: []cmdb.NetworkPartition{{Id: 10, Idc: "1", Name: "prod"}},
+		PhysicalMachines:  []cmdb.PhysicalMachine{{Id: 6001, Idc: "1", NetworkPartion: "10", Ip: "10.0.2.10", Hostname: "m5-phy-blade-01"}},
+	})
+	report, err := loader.NewEdgeFixer(client).Run(ctx, runID)
+	if err != nil {
+		t.Fatalf("fix edges failed: %v", err)
+	}
+	var bound int
+	for _, fix := range report.Fixes {
+		if fix.Name() == "DEPLOYED_ON:PhysicalMachine" {
+			bound = fix.Created
+		}
+	}
+	if bound != 1 {
+		t.Fatalf("expect app bound to its physical machine, got %+v", report)
+	}
+
+	reader, err := graph.NewClient(ctx, graph.Config{URI: "bolt://localhost:7687", Username: "neo4j", Password: "StrongPassw0rd", Database: "neo4j"})
+	if err != nil {
+		t.Fatalf("graph client failed: %v", err)
+	}
+	defer reader.Close(ctx)
+
+	nodes, err := rca.NewGraphProvider(reader).ResolveEvent(ctx, rca.AlarmEvent{AppName: "billing-batch"})
+	if err != nil {
+		t.Fatalf("resolve app event failed: %v", err)
+	}
+	types := make(map[rca.NodeType]string, len(nodes))
+	for _, node := range nodes {
+		types[node.Type] = node.Key
+	}
+	if types[rca.NodeTypePhysicalMachine] != "PM_6001" || types[rca.NodeTypeNetPartition] == "" {
+		t.Fatalf("expect app chained through PM_6001 and its partition, got %+v", types)
+	}
+}
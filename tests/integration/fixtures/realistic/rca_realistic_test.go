@@ -17,11 +17,10 @@ import (
 
 func TestRealisticFixtureRootCause(t *testing.T) {
 	fixture := loadFixtureData(t)
-	provider := &fixtureProvider{data: fixture}
 	events := loadFixtureEvents(t)
 
 	cfg := rca.DefaultConfig()
-	cfg.Hierarchy = []rca.NodeType{rca.NodeTypeVirtualMachine, rca.NodeTypeHostMachine, rca.NodeTypeNetPartition}
+	cfg.Hierarchy = []rca.NodeType{rca.NodeTypeApp, rca.NodeTypeVirtualMachine, rca.NodeTypeHostMachine, rca.NodeTypeNetPartition}
 	cfg.Layers[rca.NodeTypeVirtualMachine] = rca.LayerConfig{
 		CoverageThreshold: 0.6,
 		MinChildren:       1,
@@ -38,12 +37,13 @@ func TestRealisticFixtureRootCause(t *testing.T) {
 		Weights:           rca.ScoreWeights{Coverage: 0.5, TimeLead: 0.25, Impact: 0.2, Base: 0.05},
 	}
 
-	analyzer, err := rca.NewAnalyzer(provider, nil, cfg)
+	provider := &fixtureProvider{data: fixture, order: cfg.Hierarchy}
+	analyzer, err := rca.NewAnalyzer(provider, cfg)
 	if err != nil {
 		t.Fatalf("new analyzer: %v", err)
 	}
 
-	result, err := analyzer.Analyze(context.Background(), "window-realistic", events)
+	result, err := analyzer.AnalyzeWithOptions(context.Background(), events, rca.AnalyzeOptions{WindowID: "window-realistic"})
 	if err != nil {
 		t.Fatalf("analyze failed: %v", err)
 	}
@@ -79,7 +79,7 @@ func TestRealisticFixtureRootCause(t *testing.T) {
 	}
 	impacted := make([]string, 0, len(hostPath.Impacts))
 	for _, imp := range hostPath.Impacts {
-		impacted = append(impacted, imp.Node.CMDBKey)
+		impacted = append(impacted, imp.Node.Key)
 	}
 	sort.Strings(impacted)
 	expectedImpacted := []string{"VM_5002", "VM_5003", "VM_5004"}
@@ -300,110 +300,132 @@ func loadFixtureEvents(t *testing.T) []rca.AlarmEvent {
 		if err != nil {
 			t.Fatalf("parse time %s: %v", f.Occurred, err)
 		}
-		events = append(events, rca.AlarmEvent{
-			ID:       f.ID,
-			Source:   f.Source,
-			Priority: f.Priority,
-			NodeType: rca.NodeType(f.NodeType),
-			IP:       f.IP,
-			HostIP:   f.HostIP,
-			Service:  f.Service,
-			Occurred: ts,
-			Attrs:    f.Attributes,
-		})
+		evt := rca.AlarmEvent{
+			AppName:    f.Service,
+			IP:         f.IP,
+			HostIP:     f.HostIP,
+			CMDBKey:    f.Attributes["cmdb_key"],
+			RuleName:   f.Source + "-" + f.Priority,
+			OccurredAt: ts,
+		}
+		switch rca.NodeType(f.NodeType) {
+		case rca.NodeTypeHostMachine:
+			evt.ServerType = rca.ServerTypeHost
+		default:
+			evt.ServerType = rca.ServerTypeVM
+		}
+		events = append(events, evt)
 	}
 	return events
 }
 
 // ---------- Topology Provider backed by fixtures ----------
 
+// fixtureProvider 按 order 给出的层级返回链路，层级之外的节点（如 IDC）不参与分析。
 type fixtureProvider struct {
-	data *fixtureData
+	data  *fixtureData
+	order []rca.NodeType
+}
+
+func (p *fixtureProvider) ListAppInstances(_ context.Context, appName string, _ string) (int, error) {
+	count := 0
+	for _, app := range p.data.appByIP {
+		if app.Name == appName {
+			count++
+		}
+	}
+	return count, nil
 }
 
-func (p *fixtureProvider) ResolveContext(_ context.Context, event rca.AlarmEvent) (rca.AlarmContext, error) {
-	switch event.NodeType {
-	case rca.NodeTypeApp:
-		return p.resolveApp(event)
-	case rca.NodeTypeHostMachine:
-		return p.resolveHost(event)
+func (p *fixtureProvider) ResolveEvent(_ context.Context, event rca.AlarmEvent) ([]rca.Node, error) {
+	var (
+		chain rca.Chain
+		err   error
+	)
+	switch event.ServerType {
+	case rca.ServerTypeVM:
+		chain, err = p.resolveApp(event)
+	case rca.ServerTypeHost:
+		chain, err = p.resolveHost(event)
 	default:
-		return rca.AlarmContext{}, fmt.Errorf("unsupported node type %s", event.NodeType)
+		return nil, fmt.Errorf("unsupported server type %s", event.ServerType)
+	}
+	if err != nil {
+		return nil, err
 	}
+	return chain.Nodes(p.order), nil
 }
 
-func (p *fixtureProvider) resolveApp(event rca.AlarmEvent) (rca.AlarmContext, error) {
+func (p *fixtureProvider) resolveApp(event rca.AlarmEvent) (rca.Chain, error) {
 	app, err := p.findApp(event)
 	if err != nil {
-		return rca.AlarmContext{}, err
+		return rca.Chain{}, err
 	}
 	vm, err := p.findVMByIP(app.IP)
 	if err != nil {
-		return rca.AlarmContext{}, err
+		return rca.Chain{}, err
 	}
 	host, err := p.findHostByIP(vm.HostIP)
 	if err != nil {
-		return rca.AlarmContext{}, err
+		return rca.Chain{}, err
 	}
 	np, err := p.findNP(host.NetworkPartition)
 	if err != nil {
-		return rca.AlarmContext{}, err
+		return rca.Chain{}, err
 	}
 	idc, err := p.findIDC(np.IDC)
 	if err != nil {
-		return rca.AlarmContext{}, err
+		return rca.Chain{}, err
 	}
 
-	ctx := rca.AlarmContext{
+	return rca.Chain{
 		App:            p.newAppNode(app),
 		VirtualMachine: p.newVMNode(vm),
 		HostMachine:    p.newHostNode(host),
 		NetPartition:   p.newNPNode(np),
 		IDC:            p.newIDCNode(idc),
-	}
-	return ctx, nil
+	}, nil
 }
 
-func (p *fixtureProvider) resolveHost(event rca.AlarmEvent) (rca.AlarmContext, error) {
+func (p *fixtureProvider) resolveHost(event rca.AlarmEvent) (rca.Chain, error) {
 	var host hostFixture
 	var err error
 	if event.HostIP != "" {
 		host, err = p.findHostByIP(event.HostIP)
-	} else if cmdb := event.Attrs["cmdb_key"]; cmdb != "" {
-		host, err = p.findHostByCMDB(cmdb)
+	} else if event.CMDBKey != "" {
+		host, err = p.findHostByCMDB(event.CMDBKey)
 	} else {
 		host, err = p.findHostByIP(event.IP)
 	}
 	if err != nil {
-		return rca.AlarmContext{}, err
+		return rca.Chain{}, err
 	}
 	np, err := p.findNP(host.NetworkPartition)
 	if err != nil {
-		return rca.AlarmContext{}, err
+		return rca.Chain{}, err
 	}
 	idc, err := p.findIDC(np.IDC)
 	if err != nil {
-		return rca.AlarmContext{}, err
+		return rca.Chain{}, err
 	}
 
-	ctx := rca.AlarmContext{
+	return rca.Chain{
 		HostMachine:  p.newHostNode(host),
 		NetPartition: p.newNPNode(np),
 		IDC:          p.newIDCNode(idc),
-	}
-	return ctx, nil
+	}, nil
 }
 
 func (p *fixtureProvider) findApp(event rca.AlarmEvent) (appFixture, error) {
 	if app, ok := p.data.appByIP[event.IP]; ok {
 		return app, nil
 	}
-	if event.Service != "" {
-		if app, ok := p.data.appByName[event.Service]; ok {
+	if event.AppName != "" {
+		if app, ok := p.data.appByName[event.AppName]; ok {
 			return app, nil
 		}
 	}
-	return appFixture{}, fmt.Errorf("app not found for event %s", event.ID)
+	return appFixture{}, fmt.Errorf("app not found for event %s@%s", event.AppName, event.IP)
 }
 
 func (p *fixtureProvider) findVMByIP(ip string) (vmFixture, error) {
@@ -450,10 +472,10 @@ func (p *fixtureProvider) newAppNode(app appFixture) *rca.Node {
 	key := fmt.Sprintf("APP_%d", app.ID)
 	return &rca.Node{
 		NodeRef: rca.NodeRef{
-			CMDBKey: key,
-			Type:    rca.NodeTypeApp,
-			Name:    app.Name,
-			Labels:  []string{"App"},
+			Key:    key,
+			Type:   rca.NodeTypeApp,
+			Name:   app.Name,
+			Labels: []string{"App"},
 			Props: map[string]any{
 				"ip":   app.IP,
 				"name": app.Name,
@@ -470,10 +492,10 @@ func (p *fixtureProvider) newVMNode(vm vmFixture) *rca.Node {
 	}
 	return &rca.Node{
 		NodeRef: rca.NodeRef{
-			CMDBKey: key,
-			Type:    rca.NodeTypeVirtualMachine,
-			Name:    vm.HostName,
-			Labels:  []string{"VirtualMachine", "Compute"},
+			Key:    key,
+			Type:   rca.NodeTypeVirtualMachine,
+			Name:   vm.HostName,
+			Labels: []string{"VirtualMachine", "Compute"},
 			Props: map[string]any{
 				"ip":       vm.IP,
 				"host_ip":  vm.HostIP,
@@ -494,10 +516,10 @@ func (p *fixtureProvider) newHostNode(host hostFixture) *rca.Node {
 	}
 	return &rca.Node{
 		NodeRef: rca.NodeRef{
-			CMDBKey: key,
-			Type:    rca.NodeTypeHostMachine,
-			Name:    host.HostName,
-			Labels:  []string{"HostMachine", "Machine", "Compute"},
+			Key:    key,
+			Type:   rca.NodeTypeHostMachine,
+			Name:   host.HostName,
+			Labels: []string{"HostMachine", "Machine", "Compute"},
 			Props: map[string]any{
 				"ip":       host.IP,
 				"hostname": host.HostName,
@@ -515,10 +537,10 @@ func (p *fixtureProvider) newNPNode(np npFixture) *rca.Node {
 	physicalCount := len(p.data.npPhysicals[key])
 	return &rca.Node{
 		NodeRef: rca.NodeRef{
-			CMDBKey: key,
-			Type:    rca.NodeTypeNetPartition,
-			Name:    np.Name,
-			Labels:  []string{"NetPartition"},
+			Key:    key,
+			Type:   rca.NodeTypeNetPartition,
+			Name:   np.Name,
+			Labels: []string{"NetPartition"},
 			Props: map[string]any{
 				"name": np.Name,
 				"cidr": np.CIDR,
@@ -536,10 +558,10 @@ func (p *fixtureProvider) newIDCNode(idc idcFixture) *rca.Node {
 	npCount := len(p.data.idcPartitions[key])
 	return &rca.Node{
 		NodeRef: rca.NodeRef{
-			CMDBKey: key,
-			Type:    rca.NodeTypeIDC,
-			Name:    idc.Name,
-			Labels:  []string{"IDC"},
+			Key:    key,
+			Type:   rca.NodeTypeIDC,
+			Name:   idc.Name,
+			Labels: []string{"IDC"},
 			Props: map[string]any{
 				"name": idc.Name,
 			},
@@ -554,7 +576,7 @@ func (p *fixtureProvider) newIDCNode(idc idcFixture) *rca.Node {
 
 func findCandidate(list []rca.Candidate, key string) (rca.Candidate, bool) {
 	for _, cand := range list {
-		if cand.Node.CMDBKey == key {
+		if cand.Node.Key == key {
 			return cand, true
 		}
 	}
@@ -563,7 +585,7 @@ func findCandidate(list []rca.Candidate, key string) (rca.Candidate, bool) {
 
 func findPath(paths []rca.AlarmPath, key string) (rca.AlarmPath, bool) {
 	for _, path := range paths {
-		if path.Candidate.CMDBKey == key {
+		if path.Candidate.Key == key {
 			return path, true
 		}
 	}
@@ -0,0 +1,89 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/graph"
+	"cmdb2neo/internal/loader"
+	"cmdb2neo/internal/rca"
+)
+
+func TestFootprintSkipsTombstonedHost(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	ctx := context.Background()
+
+	client, err := loader.NewClient(ctx, loader.Config{
+		URI:      "bolt://localhost:7687",
+		Username: "neo4j",
+		Password: "StrongPassw0rd",
+		Database: "neo4j",
+	})
+	if err != nil {
+		t.Skipf("neo4j not available: %v", err)
+	}
+	defer client.Close(ctx)
+
+	schema := loader.NewSchemaManager(client)
+	if err := schema.Reset(ctx, loader.ResetOptions{Confirm: true}); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if _, err := schema.Ensure(ctx); err != nil {
+		t.Fatalf("ensure schema failed: %v", err)
+	}
+
+	nodes, rels := cmdb.BuildInitRows(cmdb.Snapshot{
+		RunID:             "20250101T000000Z",
+		IDCs:              []cmdb.IDC{{Id: 1, Name: "M5"}},
+		NetworkPartitions: []cmdb.NetworkPartition{{Id: 10, Idc: "1", Name: "prod"}},
+		HostMachines:      []cmdb.HostMachine{{Id: 100, Idc: "1", NetworkPartion: "10", Ip: "10.0.0.10"}},
+		VirtualMachines:   []cmdb.VirtualMachine{{Id: 300, Idc: "1", NetworkPartion: "10", Ip: "10.0.0.12", HostIp: "10.0.0.10"}},
+		Apps:              []cmdb.App{{Id: 400, Name: "it-footprint", Ip: "10.0.0.12", ServerType: "2"}},
+	})
+	if _, err := loader.NewNodeUpserter(client, 100).UpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("upsert nodes failed: %v", err)
+	}
+	if _, err := loader.NewRelUpserter(client, 100).UpsertRels(ctx, rels); err != nil {
+		t.Fatalf("upsert rels failed: %v", err)
+	}
+
+	reader, err := graph.NewClient(ctx, graph.Config{URI: "bolt://localhost:7687", Username: "neo4j", Password: "StrongPassw0rd", Database: "neo4j"})
+	if err != nil {
+		t.Fatalf("graph client failed: %v", err)
+	}
+	defer reader.Close(ctx)
+
+	before, err := rca.NewGraphFootprintReader(reader).AppFootprint(ctx, "it-footprint", "M5")
+	if err != nil {
+		t.Fatalf("footprint failed: %v", err)
+	}
+	if before.Total != 1 || before.Instances[0].HostIP != "10.0.0.10" {
+		t.Fatalf("expect the vm on its live host before tombstoning, got %+v", before)
+	}
+
+	// 宿主机下线后只标记墓碑，部署范围不应再经由它归到分区与机房
+	if err := reader.RunWrite(ctx, "MATCH (h:HostMachine {ip: '10.0.0.10'}) SET h.deleted = true", nil); err != nil {
+		t.Fatalf("tombstone host failed: %v", err)
+	}
+	all, err := rca.NewGraphFootprintReader(reader).AppFootprint(ctx, "it-footprint", "")
+	if err != nil {
+		t.Fatalf("footprint failed: %v", err)
+	}
+	if all.Total != 1 || all.Instances[0].HostKey != "" || all.Instances[0].Partition != "" || all.Instances[0].IDC != "" {
+		t.Fatalf("expect tombstoned host dropped from the vm footprint, got %+v", all)
+	}
+	scoped, err := rca.NewGraphFootprintReader(reader).AppFootprint(ctx, "it-footprint", "M5")
+	if err != nil {
+		t.Fatalf("footprint failed: %v", err)
+	}
+	count, err := rca.NewGraphProvider(reader).ListAppInstances(ctx, "it-footprint", "M5")
+	if err != nil {
+		t.Fatalf("list app instances failed: %v", err)
+	}
+	if scoped.Total != 0 || count != 0 {
+		t.Fatalf("expect no instances in M5 through a tombstoned host, got footprint %d and count %d", scoped.Total, count)
+	}
+}
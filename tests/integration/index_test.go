@@ -0,0 +1,81 @@
+package integration
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/loader"
+	"cmdb2neo/internal/rca"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+func TestResolveQueriesUseIndexes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	ctx := context.Background()
+
+	client, err := loader.NewClient(ctx, loader.Config{
+		URI:      "bolt://localhost:7687",
+		Username: "neo4j",
+		Password: "StrongPassw0rd",
+		Database: "neo4j",
+	})
+	if err != nil {
+		t.Skipf("neo4j not available: %v", err)
+	}
+	defer client.Close(ctx)
+
+	if _, err := loader.NewSchemaManager(client).Ensure(ctx); err != nil {
+		t.Fatalf("ensure schema failed: %v", err)
+	}
+	if err := client.RunRaw(ctx, "CALL db.awaitIndexes(60)", nil); err != nil {
+		t.Fatalf("await indexes failed: %v", err)
+	}
+
+	driver, err := neo4j.NewDriverWithContext("bolt://localhost:7687", neo4j.BasicAuth("neo4j", "StrongPassw0rd", ""))
+	if err != nil {
+		t.Fatalf("create driver failed: %v", err)
+	}
+	defer driver.Close(ctx)
+	session := driver.NewSession(ctx, neo4j.SessionConfig{DatabaseName: "neo4j"})
+	defer session.Close(ctx)
+
+	var operators func(plan neo4j.Plan) []string
+	operators = func(plan neo4j.Plan) []string {
+		ops := []string{plan.Operator()}
+		for _, child := range plan.Children() {
+			ops = append(ops, operators(child)...)
+		}
+		return ops
+	}
+
+	params := map[string]any{"ip": "10.0.0.1", "name": "app", "idc": "M5"}
+	for _, typ := range []rca.NodeType{rca.NodeTypeApp, rca.NodeTypeVirtualMachine, rca.NodeTypeHostMachine, rca.NodeTypePhysicalMachine, rca.NodeTypeNetPartition, rca.NodeTypeIDC} {
+		query, ok := rca.ResolveQuery(typ)
+		if !ok {
+			t.Fatalf("missing resolve query for %s", typ)
+		}
+		res, err := session.Run(ctx, "EXPLAIN "+query, params)
+		if err != nil {
+			t.Fatalf("explain %s failed: %v", typ, err)
+		}
+		summary, err := res.Consume(ctx)
+		if err != nil {
+			t.Fatalf("explain %s failed: %v", typ, err)
+		}
+		var seek bool
+		for _, op := range operators(summary.Plan()) {
+			switch {
+			case strings.HasPrefix(op, "AllNodesScan"):
+				t.Fatalf("resolve query for %s falls back to AllNodesScan", typ)
+			case strings.HasPrefix(op, "NodeIndex"), strings.HasPrefix(op, "NodeUnique"):
+				seek = true
+			}
+		}
+		if !seek {
+			t.Fatalf("resolve query for %s does not seek an index: %v", typ, operators(summary.Plan()))
+		}
+	}
+}
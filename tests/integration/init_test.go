@@ -31,27 +31,36 @@ func TestInitNeo4jWithJSON(t *testing.T) {
 	}
 	defer client.Close(ctx)
 
-	if err := client.RunWrite(ctx, "MATCH (n) DETACH DELETE n", nil); err != nil {
+	schema := loader.NewSchemaManager(client)
+	if err := schema.Reset(ctx, loader.ResetOptions{Confirm: true}); err != nil {
 		t.Fatalf("cleanup failed: %v", err)
 	}
-
-	schema := loader.NewSchemaManager(client)
-	if err := schema.Ensure(ctx); err != nil {
+	if _, err := schema.Ensure(ctx); err != nil {
 		t.Fatalf("ensure schema failed: %v", err)
 	}
 
 	nodeUpserter := loader.NewNodeUpserter(client, 200)
-	if err := nodeUpserter.InitNodes(ctx, nodes); err != nil {
+	stats, err := nodeUpserter.InitNodes(ctx, nodes)
+	if err != nil {
 		t.Fatalf("init nodes failed: %v", err)
 	}
+	if stats.Created != len(nodes) || stats.Updated != 0 {
+		t.Fatalf("expect %d nodes created on empty graph, got %+v", len(nodes), stats)
+	}
+	if stats, err = nodeUpserter.UpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("re-upsert nodes failed: %v", err)
+	}
+	if stats.Created != 0 || stats.Updated != len(nodes) {
+		t.Fatalf("expect %d nodes matched on re-upsert, got %+v", len(nodes), stats)
+	}
 
 	relUpserter := loader.NewRelUpserter(client, 200)
-	if err := relUpserter.InitRels(ctx, rels); err != nil {
+	if _, err := relUpserter.InitRels(ctx, rels); err != nil {
 		t.Fatalf("init relationships failed: %v", err)
 	}
 
 	fixer := loader.NewEdgeFixer(client)
-	if err := fixer.Run(ctx, snapshot.RunID); err != nil {
+	if _, err := fixer.Run(ctx, snapshot.RunID); err != nil {
 		t.Fatalf("fix edges failed: %v", err)
 	}
 
@@ -0,0 +1,54 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/loader"
+)
+
+func TestSyncLockLease(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	ctx := context.Background()
+
+	client, err := loader.NewClient(ctx, loader.Config{
+		URI:      "bolt://localhost:7687",
+		Username: "neo4j",
+		Password: "StrongPassw0rd",
+		Database: "neo4j",
+	})
+	if err != nil {
+		t.Skipf("neo4j not available: %v", err)
+	}
+	defer client.Close(ctx)
+	if _, err := loader.NewSchemaManager(client).Ensure(ctx); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+
+	const name = "integration_lock"
+	a, b := loader.NewSyncLock(client, "replica-a"), loader.NewSyncLock(client, "replica-b")
+	defer a.Unlock(ctx, name)
+	defer b.Unlock(ctx, name)
+
+	if ok, err := a.TryLock(ctx, name, time.Minute); err != nil || !ok {
+		t.Fatalf("expect replica a to acquire, got %v %v", ok, err)
+	}
+	if ok, err := b.TryLock(ctx, name, time.Minute); err != nil || ok {
+		t.Fatalf("expect replica b rejected while lease is live, got %v %v", ok, err)
+	}
+	if ok, err := a.TryLock(ctx, name, -time.Second); err != nil || !ok {
+		t.Fatalf("expect owner to renew its own lease, got %v %v", ok, err)
+	}
+	if ok, err := b.TryLock(ctx, name, time.Minute); err != nil || !ok {
+		t.Fatalf("expect replica b to take over an expired lease, got %v %v", ok, err)
+	}
+	if err := a.Unlock(ctx, name); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if ok, _ := a.TryLock(ctx, name, time.Minute); ok {
+		t.Fatal("expect stale owner unlock to leave the new lease intact")
+	}
+}
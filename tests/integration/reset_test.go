@@ -0,0 +1,81 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/loader"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+func TestResetKeepsNonCMDBNodes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	ctx := context.Background()
+
+	client, err := loader.NewClient(ctx, loader.Config{
+		URI:      "bolt://localhost:7687",
+		Username: "neo4j",
+		Password: "StrongPassw0rd",
+		Database: "neo4j",
+	})
+	if err != nil {
+		t.Skipf("neo4j not available: %v", err)
+	}
+	defer client.Close(ctx)
+
+	schema := loader.NewSchemaManager(client)
+	if _, err := schema.Ensure(ctx); err != nil {
+		t.Fatalf("ensure schema failed: %v", err)
+	}
+	nodes, _ := cmdb.BuildInitRows(cmdb.Snapshot{
+		RunID:        "reset",
+		IDCs:         []cmdb.IDC{{Id: 1, Name: "M5"}},
+		HostMachines: []cmdb.HostMachine{{Id: 1, Ip: "10.0.0.1"}},
+	})
+	if _, err := loader.NewNodeUpserter(client, 100).UpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("upsert nodes failed: %v", err)
+	}
+	// 非 CMDB 节点，以及带 CMDB 标签但没有 cmdb_key 的节点都应保留
+	if _, err := client.RunWrite(ctx, "CREATE (:ResetProbe {name: 'keep'}), (:App {name: 'manual'})", nil); err != nil {
+		t.Fatalf("seed foreign nodes failed: %v", err)
+	}
+	defer func() {
+		_, _ = client.RunWrite(ctx, "MATCH (n) WHERE n:ResetProbe OR (n:App AND n.cmdb_key IS NULL) DETACH DELETE n", nil)
+	}()
+
+	if err := schema.Reset(ctx, loader.ResetOptions{}); err == nil {
+		t.Fatalf("expect reset without confirm to be refused")
+	}
+	for i := 0; i < 2; i++ {
+		if err := schema.Reset(ctx, loader.ResetOptions{Confirm: true}); err != nil {
+			t.Fatalf("reset #%d failed: %v", i+1, err)
+		}
+	}
+
+	driver, err := neo4j.NewDriverWithContext("bolt://localhost:7687", neo4j.BasicAuth("neo4j", "StrongPassw0rd", ""))
+	if err != nil {
+		t.Fatalf("create driver failed: %v", err)
+	}
+	defer driver.Close(ctx)
+
+	count := func(query string) int64 {
+		t.Helper()
+		res, err := neo4j.ExecuteQuery(ctx, driver, query, nil, neo4j.EagerResultTransformer, neo4j.ExecuteQueryWithDatabase("neo4j"))
+		if err != nil {
+			t.Fatalf("execute %s failed: %v", query, err)
+		}
+		return res.Records[0].Values[0].(int64)
+	}
+	if got := count("MATCH (n) WHERE n.cmdb_key IS NOT NULL RETURN count(n)"); got != 0 {
+		t.Fatalf("expect cmdb nodes removed, got %d", got)
+	}
+	if got := count("MATCH (n:ResetProbe) RETURN count(n)"); got != 1 {
+		t.Fatalf("expect foreign node kept, got %d", got)
+	}
+	if got := count("MATCH (n:App) WHERE n.cmdb_key IS NULL RETURN count(n)"); got != 1 {
+		t.Fatalf("expect keyless App kept, got %d", got)
+	}
+}
@@ -0,0 +1,61 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cmdb2neo/internal/graph"
+	"cmdb2neo/internal/rca"
+)
+
+func TestGraphResultStoreRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	ctx := context.Background()
+
+	client, err := graph.NewClient(ctx, graph.Config{
+		URI:      "bolt://localhost:7687",
+		Username: "neo4j",
+		Password: "StrongPassw0rd",
+		Database: "neo4j",
+	})
+	if err != nil {
+		t.Skipf("neo4j not available: %v", err)
+	}
+	defer client.Close(ctx)
+
+	const windowID = "it-result-store"
+	defer client.RunWrite(ctx, "MATCH (r:RCAResult {window_id: $id}) DELETE r", map[string]any{"id": windowID})
+
+	store := rca.NewGraphResultStore(client)
+	result := rca.Result{EventCount: 3, Candidates: []rca.Candidate{{Node: rca.NodeRef{Key: "HM_1", Type: rca.NodeTypeHostMachine}, Confidence: 0.8}}}
+	if err := store.Save(ctx, windowID, result); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	// 同一窗口再次保存应覆盖而不是新建节点
+	result.EventCount = 4
+	if err := store.Save(ctx, windowID, result); err != nil {
+		t.Fatalf("save again: %v", err)
+	}
+
+	stored, err := store.Load(ctx, windowID)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if stored.EventCount != 4 || stored.CreatedAt.IsZero() || len(stored.Result.Candidates) != 1 || stored.Result.Candidates[0].Node.Key != "HM_1" {
+		t.Fatalf("unexpected stored result: %+v", stored)
+	}
+	records, err := client.RunRead(ctx, "MATCH (r:RCAResult {window_id: $id}) RETURN count(r) AS c", map[string]any{"id": windowID})
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if records[0]["c"].(int64) != 1 {
+		t.Fatalf("expect a single result node, got %v", records[0]["c"])
+	}
+
+	if _, err := store.Load(ctx, "it-missing-window"); !errors.Is(err, rca.ErrResultNotFound) {
+		t.Fatalf("expect ErrResultNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/loader"
+)
+
+func TestSyncCollectsEntitiesMissingFromLatestRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	ctx := context.Background()
+
+	client, err := loader.NewClient(ctx, loader.Config{
+		URI:      "bolt://localhost:7687",
+		Username: "neo4j",
+		Password: "StrongPassw0rd",
+		Database: "neo4j",
+	})
+	if err != nil {
+		t.Skipf("neo4j not available: %v", err)
+	}
+	defer client.Close(ctx)
+
+	schema := loader.NewSchemaManager(client)
+	if err := schema.Reset(ctx, loader.ResetOptions{Confirm: true}); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if _, err := schema.Ensure(ctx); err != nil {
+		t.Fatalf("ensure schema failed: %v", err)
+	}
+
+	source := &cmdb.StaticClient{Snapshot: cmdb.Snapshot{
+		RunID:           "20250101T000000Z",
+		IDCs:            []cmdb.IDC{{Id: 1, Name: "M5"}},
+		HostMachines:    []cmdb.HostMachine{{Id: 1, Idc: "1", Ip: "10.0.0.1"}, {Id: 2, Idc: "1", Ip: "10.0.0.2"}},
+		VirtualMachines: []cmdb.VirtualMachine{{Id: 3, Idc: "1", Ip: "10.0.1.1", HostIp: "10.0.0.1"}, {Id: 4, Idc: "1", Ip: "10.0.1.2", HostIp: "10.0.0.2"}},
+	}}
+	cleaner := loader.NewCleaner(client)
+	flow := &app.SyncFlow{
+		CMDB:        source,
+		Nodes:       loader.NewNodeUpserter(client, 100),
+		Rels:        loader.NewRelUpserter(client, 100),
+		Fixer:       loader.NewEdgeFixer(client),
+		Cleaner:     cleaner,
+		FullResync:  true,
+		AllowDelete: true,
+		HardDelete:  true,
+		Guard:       app.DeleteGuard{MaxRatio: 1},
+	}
+	if err := flow.Run(ctx); err != nil {
+		t.Fatalf("first sync failed: %v", err)
+	}
+
+	next := source.Snapshot
+	next.RunID = "20250102T000000Z"
+	next.HostMachines = next.HostMachines[:1]
+	next.VirtualMachines = next.VirtualMachines[:1]
+	source.Snapshot = next
+	if err := flow.Run(ctx); err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+
+	records, err := client.RunRead(ctx, `MATCH (n) WHERE n.cmdb_key IS NOT NULL
+RETURN n.cmdb_key AS key, n.first_seen_run_id AS first, n.last_seen_run_id AS last ORDER BY key`, nil)
+	if err != nil {
+		t.Fatalf("read nodes failed: %v", err)
+	}
+	got := make(map[string][2]any, len(records))
+	for _, rec := range records {
+		got[rec["key"].(string)] = [2]any{rec["first"], rec["last"]}
+	}
+	for _, key := range []string{"HM_2", "VM_4"} {
+		if _, ok := got[key]; ok {
+			t.Fatalf("expect %s collected, got %v", key, got)
+		}
+	}
+	for _, key := range []string{"IDC_1", "HM_1", "VM_3"} {
+		stamps, ok := got[key]
+		if !ok {
+			t.Fatalf("expect %s to survive, got %v", key, got)
+		}
+		if stamps[0] != "20250101T000000Z" || stamps[1] != next.RunID {
+			t.Fatalf("expect %s first/last seen run ids preserved and refreshed, got %v", key, stamps)
+		}
+	}
+
+	rels, err := client.RunRead(ctx, `MATCH ()-[r]->() RETURN type(r) AS type, r.last_seen_run_id AS last`, nil)
+	if err != nil {
+		t.Fatalf("read rels failed: %v", err)
+	}
+	if len(rels) == 0 {
+		t.Fatal("expect current relationships to survive")
+	}
+	for _, rec := range rels {
+		if rec["last"] != next.RunID {
+			t.Fatalf("expect every surviving relationship stamped with %s, got %v", next.RunID, rec)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"cmdb2neo/internal/loader"
+)
+
+func TestSchemaEnsureIsIdempotent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	ctx := context.Background()
+
+	client, err := loader.NewClient(ctx, loader.Config{
+		URI:      "bolt://localhost:7687",
+		Username: "neo4j",
+		Password: "StrongPassw0rd",
+		Database: "neo4j",
+	})
+	if err != nil {
+		t.Skipf("neo4j not available: %v", err)
+	}
+	defer client.Close(ctx)
+
+	schema := loader.NewSchemaManager(client)
+	if err := schema.Drop(ctx); err != nil {
+		t.Fatalf("drop failed: %v", err)
+	}
+	first, err := schema.Ensure(ctx)
+	if err != nil {
+		t.Fatalf("first ensure failed: %v", err)
+	}
+	if len(first.Created) != len(loader.RequiredSchema) {
+		t.Fatalf("expect all schema objects created after drop, got %+v", first)
+	}
+	second, err := schema.Ensure(ctx)
+	if err != nil {
+		t.Fatalf("second ensure failed: %v", err)
+	}
+	if len(second.Created) != 0 || len(second.Existing) != len(loader.RequiredSchema) {
+		t.Fatalf("expect rerun to find everything present, got %+v", second)
+	}
+}
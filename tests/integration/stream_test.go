@@ -0,0 +1,57 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cmdb2neo/internal/graph"
+)
+
+func TestRunReadStream(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	ctx := context.Background()
+
+	client, err := graph.NewClient(ctx, graph.Config{
+		URI:      "bolt://localhost:7687",
+		Username: "neo4j",
+		Password: "StrongPassw0rd",
+		Database: "neo4j",
+	})
+	if err != nil {
+		t.Skipf("neo4j not available: %v", err)
+	}
+	defer client.Close(ctx)
+
+	const query = "UNWIND range(1, $n) AS i RETURN i"
+	var seen []int64
+	err = client.RunReadStream(ctx, query, map[string]any{"n": 1000}, func(record map[string]any) error {
+		seen = append(seen, record["i"].(int64))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if len(seen) != 1000 || seen[0] != 1 || seen[999] != 1000 {
+		t.Fatalf("unexpected streamed records: %d", len(seen))
+	}
+
+	// 回调中途出错时停止遍历并返回该错误，会话随之关闭，之后的查询不受影响
+	stop := errors.New("stop")
+	calls := 0
+	err = client.RunReadStream(ctx, query, map[string]any{"n": 1000}, func(map[string]any) error {
+		calls++
+		if calls == 10 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || calls != 10 {
+		t.Fatalf("expect early stop after 10 records, got %d calls, err %v", calls, err)
+	}
+	if err := client.Ping(ctx); err != nil {
+		t.Fatalf("client unusable after aborted stream: %v", err)
+	}
+}
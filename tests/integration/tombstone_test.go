@@ -0,0 +1,106 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/graph"
+	"cmdb2neo/internal/loader"
+	"cmdb2neo/internal/rca"
+)
+
+func TestSoftDeleteTombstonesAndPurge(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	ctx := context.Background()
+
+	client, err := loader.NewClient(ctx, loader.Config{
+		URI:      "bolt://localhost:7687",
+		Username: "neo4j",
+		Password: "StrongPassw0rd",
+		Database: "neo4j",
+	})
+	if err != nil {
+		t.Skipf("neo4j not available: %v", err)
+	}
+	defer client.Close(ctx)
+
+	schema := loader.NewSchemaManager(client)
+	if err := schema.Reset(ctx, loader.ResetOptions{Confirm: true}); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if _, err := schema.Ensure(ctx); err != nil {
+		t.Fatalf("ensure schema failed: %v", err)
+	}
+
+	write := func(snapshot cmdb.Snapshot) {
+		nodes, rels := cmdb.BuildInitRows(snapshot)
+		if _, err := loader.NewNodeUpserter(client, 100).UpsertNodes(ctx, nodes); err != nil {
+			t.Fatalf("upsert nodes failed: %v", err)
+		}
+		if _, err := loader.NewRelUpserter(client, 100).UpsertRels(ctx, rels); err != nil {
+			t.Fatalf("upsert rels failed: %v", err)
+		}
+	}
+	base := cmdb.Snapshot{
+		RunID:        "20250101T000000Z",
+		HostMachines: []cmdb.HostMachine{{Id: 1, Ip: "10.0.0.1"}, {Id: 2, Ip: "10.0.0.2"}},
+	}
+	write(base)
+	next := base
+	next.RunID = "20250102T000000Z"
+	next.HostMachines = base.HostMachines[:1]
+	write(next)
+
+	cleaner := loader.NewCleaner(client)
+	if err := cleaner.SoftDeleteRelationships(ctx, next.RunID); err != nil {
+		t.Fatalf("soft delete rels failed: %v", err)
+	}
+	if err := cleaner.SoftDeleteNodes(ctx, next.RunID); err != nil {
+		t.Fatalf("soft delete nodes failed: %v", err)
+	}
+
+	reader, err := graph.NewClient(ctx, graph.Config{URI: "bolt://localhost:7687", Username: "neo4j", Password: "StrongPassw0rd", Database: "neo4j"})
+	if err != nil {
+		t.Fatalf("graph client failed: %v", err)
+	}
+	defer reader.Close(ctx)
+
+	countDeleted := func() int {
+		rows, err := reader.RunRead(ctx, "MATCH (n:HostMachine {deleted: true}) RETURN count(n) AS c", nil)
+		if err != nil {
+			t.Fatalf("count tombstones failed: %v", err)
+		}
+		return int(rows[0]["c"].(int64))
+	}
+	if got := countDeleted(); got != 1 {
+		t.Fatalf("expect 1 tombstoned host, got %d", got)
+	}
+
+	// RCA 解析应忽略墓碑节点
+	provider := rca.NewGraphProvider(reader)
+	if _, err := provider.ResolveEvent(ctx, rca.AlarmEvent{IP: "10.0.0.2", ServerType: rca.ServerTypeHost}); err == nil {
+		t.Fatalf("expect tombstoned host to be invisible to RCA")
+	}
+	if _, err := provider.ResolveEvent(ctx, rca.AlarmEvent{IP: "10.0.0.1", ServerType: rca.ServerTypeHost}); err != nil {
+		t.Fatalf("live host should resolve: %v", err)
+	}
+
+	// 保留期内不清除，保留期为 0 时清除
+	if err := cleaner.PurgeTombstones(ctx, time.Hour); err != nil {
+		t.Fatalf("purge failed: %v", err)
+	}
+	if got := countDeleted(); got != 1 {
+		t.Fatalf("tombstone within retention must survive purge, got %d", got)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := cleaner.PurgeTombstones(ctx, 0); err != nil {
+		t.Fatalf("purge failed: %v", err)
+	}
+	if got := countDeleted(); got != 0 {
+		t.Fatalf("expect tombstone purged, got %d", got)
+	}
+}
@@ -0,0 +1,67 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/loader"
+)
+
+func TestGraphValidatorFindsOrphans(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	ctx := context.Background()
+
+	client, err := loader.NewClient(ctx, loader.Config{
+		URI:      "bolt://localhost:7687",
+		Username: "neo4j",
+		Password: "StrongPassw0rd",
+		Database: "neo4j",
+	})
+	if err != nil {
+		t.Skipf("neo4j not available: %v", err)
+	}
+	defer client.Close(ctx)
+
+	schema := loader.NewSchemaManager(client)
+	if err := schema.Reset(ctx, loader.ResetOptions{Confirm: true}); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if _, err := schema.Ensure(ctx); err != nil {
+		t.Fatalf("ensure schema failed: %v", err)
+	}
+
+	// 虚拟机的宿主机不存在，应用部署的 IP 也不存在
+	nodes, rels := cmdb.BuildInitRows(cmdb.Snapshot{
+		RunID:             "validate",
+		IDCs:              []cmdb.IDC{{Id: 1, Name: "M5"}},
+		NetworkPartitions: []cmdb.NetworkPartition{{Id: 10, Idc: "1"}},
+		HostMachines:      []cmdb.HostMachine{{Id: 100, NetworkPartion: "10", Ip: "10.0.0.10"}},
+		VirtualMachines:   []cmdb.VirtualMachine{{Id: 300, Ip: "10.0.0.12", HostIp: "10.9.9.9"}},
+		Apps:              []cmdb.App{{Id: 400, Ip: "10.0.0.99"}},
+	})
+	if _, err := loader.NewNodeUpserter(client, 100).UpsertNodes(ctx, nodes); err != nil {
+		t.Fatalf("upsert nodes failed: %v", err)
+	}
+	if _, err := loader.NewRelUpserter(client, 100).UpsertRels(ctx, rels); err != nil {
+		t.Fatalf("upsert rels failed: %v", err)
+	}
+
+	report, err := (&app.GraphValidator{Reader: client}).Run(ctx)
+	if err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	counts := make(map[string]int, len(report.Checks))
+	for _, check := range report.Checks {
+		counts[check.Name] = check.Count
+	}
+	want := map[string]int{"idc_without_partition": 0, "partition_without_machine": 0, "vm_without_host": 1, "app_not_deployed": 1}
+	for name, n := range want {
+		if counts[name] != n {
+			t.Fatalf("check %s: expect %d, got %d (%+v)", name, n, counts[name], report)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/cmdb"
+)
+
+func TestSyncFlowSkipsCleanupWithoutAllowDelete(t *testing.T) {
+	for _, allow := range []bool{false, true} {
+		nodes := &fakeNodeWriter{}
+		cleaner := &fakeCleaner{}
+		flow := &app.SyncFlow{
+			CMDB:        &cmdb.StaticClient{Snapshot: sampleSnapshot()},
+			Nodes:       nodes,
+			Rels:        &fakeRelWriter{},
+			Cleaner:     cleaner,
+			AllowDelete: allow,
+			HardDelete:  true,
+		}
+		if err := flow.Run(context.Background()); err != nil {
+			t.Fatalf("allow=%v run: %v", allow, err)
+		}
+		if len(nodes.rows) != 5 {
+			t.Fatalf("allow=%v: upserts must run regardless, got %d rows", allow, len(nodes.rows))
+		}
+		want := 0
+		if allow {
+			want = 1
+		}
+		if cleaner.nodeDeletes != want || cleaner.relDeletes != want {
+			t.Fatalf("allow=%v: expect %d cleanup passes, got %+v", allow, want, cleaner)
+		}
+	}
+}
+
+func TestDeltaSyncDefersRemovalsWithoutAllowDelete(t *testing.T) {
+	client := &cmdb.StaticClient{Snapshot: sampleSnapshot()}
+	deleter := &fakeDeleter{}
+	nodes := &fakeNodeWriter{}
+	store := &memorySnapshotStore{snapshot: sampleSnapshot(), saved: true}
+	flow := &app.SyncFlow{CMDB: client, Nodes: nodes, Rels: &fakeRelWriter{}, Cleaner: &fakeCleaner{}, Deleter: deleter, Snapshots: store, HardDelete: true}
+
+	next := sampleSnapshot()
+	next.RunID = "run-2"
+	next.Apps = nil
+	next.NetworkPartitions[0].Name = "np-renamed"
+	client.Snapshot = next
+
+	if err := flow.Run(context.Background()); err != nil {
+		t.Fatalf("run without allow_delete: %v", err)
+	}
+	if len(deleter.nodes) != 0 || len(deleter.rels) != 0 {
+		t.Fatalf("expect no deletions without allow_delete, got %+v", deleter)
+	}
+	if len(nodes.rows) != 1 {
+		t.Fatalf("expect the renamed partition upserted, got %d rows", len(nodes.rows))
+	}
+	// 基线前进到 run-2 但保留下线的应用，已写入的变更不再重复比较
+	if store.snapshot.RunID != "run-2" || len(store.snapshot.Apps) != 1 || store.snapshot.NetworkPartitions[0].Name != "np-renamed" {
+		t.Fatalf("expect baseline advanced with the removed app retained, got %+v", store.snapshot)
+	}
+
+	if err := flow.Run(context.Background()); err != nil {
+		t.Fatalf("second run without allow_delete: %v", err)
+	}
+	if len(nodes.rows) != 1 {
+		t.Fatalf("expect written changes not re-applied, got %d rows", len(nodes.rows))
+	}
+
+	flow.AllowDelete = true
+	if err := flow.Run(context.Background()); err != nil {
+		t.Fatalf("run with allow_delete: %v", err)
+	}
+	if len(deleter.nodes) != 1 || len(deleter.rels) != 1 {
+		t.Fatalf("expect the removed app and its deployment deleted, got %+v", deleter)
+	}
+	if store.snapshot.RunID != "run-2" || len(store.snapshot.Apps) != 0 {
+		t.Fatalf("expect baseline to drop the deleted app, got %+v", store.snapshot)
+	}
+}
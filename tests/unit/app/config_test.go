@@ -0,0 +1,157 @@
+package app_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/app"
+)
+
+func validConfig() app.Config {
+	return app.Config{
+		Neo4j: app.Neo4j{URI: "bolt://localhost:7687", Username: "neo4j"},
+		Sync:  app.Sync{BatchSize: 100, IntervalSeconds: 300, JobCron: "0 7 * * *"},
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		mutate func(*app.Config)
+		path   string
+	}{
+		{"missing uri", func(c *app.Config) { c.Neo4j.URI = " " }, "neo4j.uri"},
+		{"missing username", func(c *app.Config) { c.Neo4j.Username = "" }, "neo4j.username"},
+		{"zero batch size", func(c *app.Config) { c.Sync.BatchSize = 0 }, "sync.batch_size"},
+		{"negative interval", func(c *app.Config) { c.Sync.IntervalSeconds = -1 }, "sync.interval_seconds"},
+		{"bad cron", func(c *app.Config) { c.Sync.JobCron = "every day" }, "sync.job_cron"},
+		{"negative lock ttl", func(c *app.Config) { c.Sync.LockTTLSeconds = -5 }, "sync.lock_ttl_seconds"},
+		{"initial resync without source", func(c *app.Config) { c.Sync.InitialResync = true }, "sync.source.base_url"},
+		{"routing on http uri", func(c *app.Config) { c.Neo4j.URI, c.Neo4j.Routing = "http://localhost:7474", true }, "neo4j.routing"},
+		{"negative health check interval", func(c *app.Config) { c.Neo4j.HealthCheckIntervalSecond = -1 }, "neo4j.health_check_interval_second"},
+		{"negative rca max events", func(c *app.Config) { c.HTTP.RCA.MaxEvents = -1 }, "http.rca.max_events"},
+		{"negative rca body limit", func(c *app.Config) { c.HTTP.RCA.MaxBodyBytes = -1 }, "http.rca.max_body_bytes"},
+		{"negative rca burst", func(c *app.Config) { c.HTTP.RCA.RateLimitBurst = -1 }, "http.rca.rate_limit_burst"},
+		{"negative rca async jobs", func(c *app.Config) { c.HTTP.RCA.MaxAsyncJobs = -1 }, "http.rca.max_async_jobs"},
+		{"invalid trusted proxy", func(c *app.Config) { c.HTTP.TrustedProxies = []string{"10.0.0.0/8", "lb-01"} }, "http.trusted_proxies"},
+		{"unknown log level", func(c *app.Config) { c.Log.Level = "verbose" }, "log.level"},
+		{"negative query cache ttl", func(c *app.Config) { c.Neo4j.QueryCacheTTLSecond = -1 }, "neo4j.query_cache_ttl_second"},
+		{"negative chain cache size", func(c *app.Config) { c.RCA.ChainCacheSize = -1 }, "rca.chain_cache_size"},
+		{"negative chain cache ttl", func(c *app.Config) { c.RCA.ChainCacheTTLSeconds = -1 }, "rca.chain_cache_ttl_seconds"},
+		{"negative topology max nodes", func(c *app.Config) { c.HTTP.Topology.MaxNodes = -1 }, "http.topology.max_nodes"},
+		{"topology depth too large", func(c *app.Config) { c.HTTP.Topology.MaxDepth = 5 }, "http.topology.max_depth"},
+		{"invalid schema label", func(c *app.Config) { c.Schema.Labels = map[string]string{"App": "App-1"} }, "schema"},
+		{"unknown schema rel", func(c *app.Config) { c.Schema.Rels = map[string]string{"OWNS": "HAS"} }, "schema"},
+	}
+	base := validConfig()
+	if err := base.Validate(); err != nil {
+		t.Fatalf("expect baseline config valid, got %v", err)
+	}
+	for _, tc := range cases {
+		cfg := validConfig()
+		tc.mutate(&cfg)
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), tc.path) {
+			t.Fatalf("%s: expect error naming %s, got %v", tc.name, tc.path, err)
+		}
+	}
+
+	withDir := validConfig()
+	withDir.Sync.InitialResync = true
+	withDir.Sync.Source.FileDir = "/data/snapshots"
+	if err := withDir.Validate(); err != nil {
+		t.Fatalf("expect file_dir to satisfy initial resync, got %v", err)
+	}
+}
+
+func TestLoadConfigAggregatesValidationErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("neo4j:\n  uri: \"\"\nsync:\n  batch_size: 0\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	_, err := app.LoadConfig(path)
+	if err == nil {
+		t.Fatal("expect invalid config rejected")
+	}
+	for _, want := range []string{path, "neo4j.uri", "neo4j.username", "sync.batch_size"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expect error to mention %s, got %v", want, err)
+		}
+	}
+}
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+const envConfig = `neo4j:
+  uri: bolt://localhost:7687
+  username: neo4j
+  password: "${TEST_NEO4J_PASSWORD}"
+sync:
+  batch_size: 100
+  source:
+    static_token: "Bearer ${TEST_CMDB_TOKEN}"
+    password: literal$value
+`
+
+func TestLoadConfigExpandsEnvAndOverrides(t *testing.T) {
+	t.Setenv("TEST_NEO4J_PASSWORD", "s3cret")
+	t.Setenv("TEST_CMDB_TOKEN", "abc")
+	t.Setenv("CMDB2NEO_NEO4J_URI", "bolt://prod-neo4j:7687")
+	t.Setenv("CMDB2NEO_SYNC_BATCH_SIZE", "500")
+	t.Setenv("CMDB2NEO_SYNC_SOURCE_IDCS", "M5, IDC1")
+
+	cfg, err := app.LoadConfig(writeConfig(t, envConfig))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Neo4j.Password != "s3cret" || cfg.Sync.Source.StaticToken != "Bearer abc" || cfg.Sync.Source.Password != "literal$value" {
+		t.Fatalf("unexpected expansion: %+v", cfg)
+	}
+	if cfg.Neo4j.URI != "bolt://prod-neo4j:7687" || cfg.Sync.BatchSize != 500 || strings.Join(cfg.Sync.Source.IDCs, "|") != "M5|IDC1" {
+		t.Fatalf("unexpected overrides: %+v", cfg)
+	}
+}
+
+func TestLoadConfigRejectsUnsetEnvReference(t *testing.T) {
+	t.Setenv("TEST_CMDB_TOKEN", "abc")
+	_, err := app.LoadConfig(writeConfig(t, envConfig))
+	if err == nil || !strings.Contains(err.Error(), "TEST_NEO4J_PASSWORD") || !strings.Contains(err.Error(), "neo4j.password") {
+		t.Fatalf("expect error naming the unset variable and path, got %v", err)
+	}
+
+	t.Setenv("TEST_NEO4J_PASSWORD", "s3cret")
+	t.Setenv("CMDB2NEO_SYNC_BATCH_SIZE", "many")
+	if _, err := app.LoadConfig(writeConfig(t, envConfig)); err == nil || !strings.Contains(err.Error(), "CMDB2NEO_SYNC_BATCH_SIZE") {
+		t.Fatalf("expect invalid override rejected, got %v", err)
+	}
+}
+
+// 流程重跑与单次 CMDB 请求的重试分开配置，同一接口不会被两层重试叠加请求。
+func TestLoadConfigSeparatesFlowRetryFromRequestRetry(t *testing.T) {
+	cfg, err := app.LoadConfig(writeConfig(t, `neo4j:
+  uri: bolt://localhost:7687
+  username: neo4j
+sync:
+  batch_size: 100
+  retry:
+    attempts: 3
+    backoff_seconds: 2
+  flow_retry:
+    attempts: 1
+`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Sync.Retry.Attempts != 3 || cfg.Sync.FlowRetry.Attempts != 1 {
+		t.Fatalf("expect independent retry settings, got retry=%+v flow_retry=%+v", cfg.Sync.Retry, cfg.Sync.FlowRetry)
+	}
+}
@@ -0,0 +1,69 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+)
+
+func TestDeleteGuardThresholds(t *testing.T) {
+	counts := []domain.LabelCount{
+		{Label: "App", Total: 100, Stale: 30},
+		{Label: "IDC", Total: 2, Stale: 1},
+	}
+	if err := (app.DeleteGuard{}).Check(nil, counts); !errors.Is(err, app.ErrDeleteGuard) {
+		t.Fatalf("expect App over default ratio to trip, got %v", err)
+	}
+	if err := (app.DeleteGuard{MaxRatio: 0.5}).Check(nil, counts); err != nil {
+		t.Fatalf("expect 30%% under 0.5 to pass, got %v", err)
+	}
+	if err := (app.DeleteGuard{MinCount: 50}).Check(nil, counts); err != nil {
+		t.Fatalf("expect counts below min count to pass, got %v", err)
+	}
+	if err := (app.DeleteGuard{MaxRatio: 1}).Check(nil, []domain.LabelCount{{Label: "App", Total: 20, Stale: 20}}); err != nil {
+		t.Fatalf("expect ratio 1 to disable the guard, got %v", err)
+	}
+}
+
+func TestSyncFlowAbortsCleanupOverDeleteRatio(t *testing.T) {
+	client := &countingClient{snapshot: sampleSnapshot()}
+	cleaner := &fakeCleaner{staleCounts: []domain.LabelCount{{Label: "App", Total: 40, Stale: 35}}}
+	flow := &app.SyncFlow{CMDB: client, Nodes: &fakeNodeWriter{}, Rels: &fakeRelWriter{}, Cleaner: cleaner, AllowDelete: true, Retry: app.Retry{Attempts: 3}}
+
+	if err := flow.Run(context.Background()); !errors.Is(err, app.ErrDeleteGuard) {
+		t.Fatalf("expect ErrDeleteGuard, got %v", err)
+	}
+	if cleaner.softNodeDeletes != 0 || cleaner.softRelDeletes != 0 || cleaner.nodeDeletes != 0 {
+		t.Fatalf("expect no deletes once guard trips, got %+v", cleaner)
+	}
+	if client.fetches != 1 {
+		t.Fatalf("expect guard errors not retried, got %d fetches", client.fetches)
+	}
+}
+
+func TestDeltaSyncGuardsRemovedEntities(t *testing.T) {
+	prev := sampleSnapshot()
+	for i := 0; i < 12; i++ {
+		prev.Apps = append(prev.Apps, cmdb.App{Id: 500 + i, Name: "bulk", Ip: "10.0.0.12"})
+	}
+	deleter := &fakeDeleter{}
+	store := &memorySnapshotStore{snapshot: prev, saved: true}
+	next := sampleSnapshot()
+	next.RunID = "run-2"
+	next.Apps = nil
+	flow := &app.SyncFlow{CMDB: &cmdb.StaticClient{Snapshot: next}, Nodes: &fakeNodeWriter{}, Rels: &fakeRelWriter{}, Cleaner: &fakeCleaner{}, Deleter: deleter, Snapshots: store, AllowDelete: true}
+
+	if err := flow.Run(context.Background()); !errors.Is(err, app.ErrDeleteGuard) {
+		t.Fatalf("expect ErrDeleteGuard, got %v", err)
+	}
+	if len(deleter.tombstoneNodes) != 0 || len(deleter.nodes) != 0 {
+		t.Fatalf("expect no deletes once guard trips, got %+v", deleter)
+	}
+	if store.snapshot.RunID != prev.RunID {
+		t.Fatalf("expect baseline kept, got %s", store.snapshot.RunID)
+	}
+}
@@ -0,0 +1,100 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+)
+
+type memorySnapshotStore struct {
+	snapshot cmdb.Snapshot
+	saved    bool
+}
+
+func (s *memorySnapshotStore) Load(context.Context) (cmdb.Snapshot, bool, error) {
+	return s.snapshot, s.saved, nil
+}
+
+func (s *memorySnapshotStore) Save(_ context.Context, snapshot cmdb.Snapshot) error {
+	s.snapshot, s.saved = snapshot, true
+	return nil
+}
+
+type fakeDeleter struct {
+	nodes          []domain.NodeRow
+	rels           []domain.RelRow
+	tombstoneNodes []domain.NodeRow
+	tombstoneRels  []domain.RelRow
+}
+
+func (d *fakeDeleter) DeleteNodes(_ context.Context, rows []domain.NodeRow) error {
+	d.nodes = append(d.nodes, rows...)
+	return nil
+}
+
+func (d *fakeDeleter) DeleteRelationships(_ context.Context, rows []domain.RelRow) error {
+	d.rels = append(d.rels, rows...)
+	return nil
+}
+
+func (d *fakeDeleter) TombstoneNodes(_ context.Context, rows []domain.NodeRow) error {
+	d.tombstoneNodes = append(d.tombstoneNodes, rows...)
+	return nil
+}
+
+func (d *fakeDeleter) TombstoneRelationships(_ context.Context, rows []domain.RelRow) error {
+	d.tombstoneRels = append(d.tombstoneRels, rows...)
+	return nil
+}
+
+func TestSyncFlowWritesOnlyDelta(t *testing.T) {
+	client := &cmdb.StaticClient{Snapshot: sampleSnapshot()}
+	nodes := &fakeNodeWriter{}
+	cleaner := &fakeCleaner{}
+	deleter := &fakeDeleter{}
+	store := &memorySnapshotStore{}
+	flow := &app.SyncFlow{CMDB: client, Nodes: nodes, Rels: &fakeRelWriter{}, Cleaner: cleaner, Deleter: deleter, Snapshots: store, AllowDelete: true, HardDelete: true}
+
+	// 首次没有基线，走全量路径并保存快照
+	if err := flow.Run(context.Background()); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if len(nodes.rows) != 5 || cleaner.nodeDeletes != 1 || !store.saved {
+		t.Fatalf("expect full sync on first run, rows=%d cleaner=%+v saved=%v", len(nodes.rows), cleaner, store.saved)
+	}
+
+	next := sampleSnapshot()
+	next.RunID = "run-2"
+	next.VirtualMachines[0].Hostname = "vm-renamed"
+	next.Apps = nil
+	client.Snapshot = next
+	nodes.rows = nil
+
+	if err := flow.Run(context.Background()); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if len(nodes.rows) != 1 || nodes.rows[0].CMDBKey != domain.MakeKey(domain.PrefixVirtual, 300) {
+		t.Fatalf("expect only the changed vm upserted, got %+v", nodes.rows)
+	}
+	if len(deleter.nodes) != 1 || deleter.nodes[0].CMDBKey != domain.MakeKey(domain.PrefixApp, 400) {
+		t.Fatalf("expect removed app deleted by key, got %+v", deleter.nodes)
+	}
+	if cleaner.nodeDeletes != 1 {
+		t.Fatalf("delta sync must not run run-id based cleanup")
+	}
+	if store.snapshot.RunID != "run-2" {
+		t.Fatalf("expect baseline advanced to run-2, got %s", store.snapshot.RunID)
+	}
+
+	flow.FullResync = true
+	nodes.rows = nil
+	if err := flow.Run(context.Background()); err != nil {
+		t.Fatalf("full resync run: %v", err)
+	}
+	if len(nodes.rows) != 4 || cleaner.nodeDeletes != 2 {
+		t.Fatalf("expect full upsert with full_resync, rows=%d cleaner=%+v", len(nodes.rows), cleaner)
+	}
+}
@@ -0,0 +1,46 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+)
+
+func TestSyncFlowRefusesEmptySnapshotOverPopulatedGraph(t *testing.T) {
+	client := &countingClient{snapshot: cmdb.Snapshot{RunID: "run-2"}}
+	nodes := &fakeNodeWriter{}
+	cleaner := &fakeCleaner{staleCounts: []domain.LabelCount{
+		{Label: domain.LabelIDC, Total: 1, Stale: 1},
+		{Label: domain.LabelHostMachine, Total: 3, Stale: 3},
+		{Label: domain.LabelApp, Total: 5, Stale: 5},
+	}}
+	flow := &app.SyncFlow{CMDB: client, Nodes: nodes, Rels: &fakeRelWriter{}, Cleaner: cleaner, AllowDelete: true, HardDelete: true, Retry: app.Retry{Attempts: 3}}
+
+	if err := flow.Run(context.Background()); !errors.Is(err, app.ErrSnapshotEmpty) {
+		t.Fatalf("expect ErrSnapshotEmpty, got %v", err)
+	}
+	if cleaner.nodeDeletes != 0 || cleaner.relDeletes != 0 || cleaner.softNodeDeletes != 0 || cleaner.softRelDeletes != 0 {
+		t.Fatalf("expect no deletes for an empty snapshot, got %+v", cleaner)
+	}
+	if nodes.calls != 0 {
+		t.Fatalf("expect no writes for an empty snapshot, got %d", nodes.calls)
+	}
+	if client.fetches != 1 {
+		t.Fatalf("expect empty snapshot errors not retried, got %d fetches", client.fetches)
+	}
+}
+
+func TestSyncFlowAllowsEmptySnapshotOnEmptyGraph(t *testing.T) {
+	// 图中只有配置补齐的机房，不算已有数据
+	cleaner := &fakeCleaner{staleCounts: []domain.LabelCount{{Label: domain.LabelIDC, Total: 1}}}
+	snapshot := cmdb.Snapshot{RunID: "run-1", IDCs: []cmdb.IDC{{Id: 1, Name: "M5"}}}
+	flow := &app.SyncFlow{CMDB: &cmdb.StaticClient{Snapshot: snapshot}, Nodes: &fakeNodeWriter{}, Rels: &fakeRelWriter{}, Cleaner: cleaner, AllowDelete: true}
+
+	if err := flow.Run(context.Background()); err != nil {
+		t.Fatalf("expect empty graph to accept an empty snapshot, got %v", err)
+	}
+}
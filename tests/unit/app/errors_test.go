@@ -0,0 +1,83 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/loader"
+)
+
+type failingClient struct {
+	err     error
+	fetches int
+}
+
+func (c *failingClient) FetchSnapshot(context.Context) (cmdb.Snapshot, error) {
+	c.fetches++
+	return cmdb.Snapshot{}, c.err
+}
+
+func TestSyncFlowRetriesOnlyTransientCMDBErrors(t *testing.T) {
+	cases := []struct {
+		name      string
+		kind      error
+		fetches   int
+		transient bool
+	}{
+		{"unavailable", cmdb.ErrCMDBUnavailable, 3, true},
+		{"auth", cmdb.ErrCMDBAuth, 1, false},
+		{"validation", domain.ErrValidation, 1, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &failingClient{err: domain.MarkError(tc.kind, errors.New("upstream failure"))}
+			flow := &app.SyncFlow{CMDB: client, Nodes: &fakeNodeWriter{}, Rels: &fakeRelWriter{}, Cleaner: &fakeCleaner{}, Retry: app.Retry{Attempts: 3}}
+
+			err := flow.Run(context.Background())
+			if !errors.Is(err, tc.kind) {
+				t.Fatalf("expect %v through the flow, got %v", tc.kind, err)
+			}
+			if client.fetches != tc.fetches {
+				t.Fatalf("expect %d fetches, got %d", tc.fetches, client.fetches)
+			}
+			if app.IsTransientError(err) != tc.transient {
+				t.Fatalf("expect transient=%v for %v", tc.transient, err)
+			}
+		})
+	}
+}
+
+func TestSyncFlowSurfacesNeo4jUnavailable(t *testing.T) {
+	nodes := &fakeNodeWriter{failures: 5}
+	flow := &app.SyncFlow{CMDB: &cmdb.StaticClient{Snapshot: sampleSnapshot()}, Nodes: &unavailableNodeWriter{nodes}, Rels: &fakeRelWriter{}, Cleaner: &fakeCleaner{}, Retry: app.Retry{Attempts: 2}}
+
+	err := flow.Run(context.Background())
+	if !errors.Is(err, loader.ErrNeo4jUnavailable) || !app.IsTransientError(err) {
+		t.Fatalf("expect ErrNeo4jUnavailable through the flow, got %v", err)
+	}
+	if nodes.calls != 2 {
+		t.Fatalf("expect transient write errors retried, got %d calls", nodes.calls)
+	}
+}
+
+func TestStrictValidationErrorIsValidationKind(t *testing.T) {
+	flow := &app.SyncFlow{CMDB: &cmdb.StaticClient{Snapshot: danglingSnapshot()}, Nodes: &fakeNodeWriter{}, Rels: &fakeRelWriter{}, Cleaner: &fakeCleaner{}, StrictValidation: true}
+	err := flow.Run(context.Background())
+	if !errors.Is(err, app.ErrSnapshotInvalid) || !errors.Is(err, domain.ErrValidation) {
+		t.Fatalf("expect ErrSnapshotInvalid marked as ErrValidation, got %v", err)
+	}
+}
+
+// unavailableNodeWriter 把底层写入的错误标记为 Neo4j 不可用，模拟 loader 对驱动错误的分类。
+type unavailableNodeWriter struct {
+	*fakeNodeWriter
+}
+
+func (w *unavailableNodeWriter) UpsertNodes(ctx context.Context, rows []domain.NodeRow) (loader.WriteStats, error) {
+	stats, err := w.fakeNodeWriter.UpsertNodes(ctx, rows)
+	return stats, domain.MarkError(loader.ErrNeo4jUnavailable, err)
+}
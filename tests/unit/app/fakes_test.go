@@ -0,0 +1,88 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/loader"
+)
+
+var errTransient = errors.New("transient neo4j failure")
+
+// fakeNodeWriter 记录写入的节点，failures 次调用内返回临时错误。
+type fakeNodeWriter struct {
+	failures int
+	calls    int
+	rows     []domain.NodeRow
+}
+
+func (w *fakeNodeWriter) InitNodes(ctx context.Context, rows []domain.NodeRow) (loader.WriteStats, error) {
+	return w.UpsertNodes(ctx, rows)
+}
+
+func (w *fakeNodeWriter) UpsertNodes(_ context.Context, rows []domain.NodeRow) (loader.WriteStats, error) {
+	w.calls++
+	if w.calls <= w.failures {
+		return loader.WriteStats{}, errTransient
+	}
+	w.rows = append(w.rows, rows...)
+	return loader.WriteStats{Created: len(rows), Batches: 1}, nil
+}
+
+type fakeRelWriter struct {
+	rows []domain.RelRow
+}
+
+func (w *fakeRelWriter) InitRels(ctx context.Context, rows []domain.RelRow) (loader.WriteStats, error) {
+	return w.UpsertRels(ctx, rows)
+}
+
+func (w *fakeRelWriter) UpsertRels(_ context.Context, rows []domain.RelRow) (loader.WriteStats, error) {
+	w.rows = append(w.rows, rows...)
+	return loader.WriteStats{Created: len(rows), Batches: 1}, nil
+}
+
+type fakeCleaner struct {
+	nodeDeletes     int
+	relDeletes      int
+	softNodeDeletes int
+	softRelDeletes  int
+	staleCounts     []domain.LabelCount
+}
+
+func (c *fakeCleaner) HardDeleteRelationships(context.Context, string) error {
+	c.relDeletes++
+	return nil
+}
+
+func (c *fakeCleaner) HardDeleteNodes(context.Context, string) error {
+	c.nodeDeletes++
+	return nil
+}
+
+func (c *fakeCleaner) SoftDeleteRelationships(context.Context, string) error {
+	c.softRelDeletes++
+	return nil
+}
+
+func (c *fakeCleaner) SoftDeleteNodes(context.Context, string) error {
+	c.softNodeDeletes++
+	return nil
+}
+
+func (c *fakeCleaner) CountStale(context.Context, string) ([]domain.LabelCount, error) {
+	return c.staleCounts, nil
+}
+
+func sampleSnapshot() cmdb.Snapshot {
+	return cmdb.Snapshot{
+		RunID:             "run-1",
+		IDCs:              []cmdb.IDC{{Id: 1, Name: "M5"}},
+		NetworkPartitions: []cmdb.NetworkPartition{{Id: 10, Idc: "1", Name: "np"}},
+		HostMachines:      []cmdb.HostMachine{{Id: 100, Idc: "1", NetworkPartion: "10", Ip: "10.0.0.10"}},
+		VirtualMachines:   []cmdb.VirtualMachine{{Id: 300, Idc: "1", NetworkPartion: "10", Ip: "10.0.0.12", HostIp: "10.0.0.10"}},
+		Apps:              []cmdb.App{{Id: 400, Name: "app1", Ip: "10.0.0.12"}},
+	}
+}
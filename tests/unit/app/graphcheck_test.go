@@ -0,0 +1,107 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/domain"
+)
+
+type fakeGraphReader struct {
+	rows    map[string][]map[string]any
+	queries []string
+	err     error
+}
+
+func (r *fakeGraphReader) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	r.queries = append(r.queries, query)
+	if r.err != nil {
+		return nil, r.err
+	}
+	if params["limit"] != 3 {
+		return nil, errors.New("sample limit not passed")
+	}
+	for match, rows := range r.rows {
+		if strings.HasPrefix(query, match) {
+			return rows, nil
+		}
+	}
+	return []map[string]any{{"count": int64(0), "samples": []any{}}}, nil
+}
+
+func TestGraphValidatorReportsViolations(t *testing.T) {
+	checks := app.DefaultGraphChecks()
+	reader := &fakeGraphReader{rows: map[string][]map[string]any{
+		checks[2].Match: {{"count": int64(4), "samples": []any{"VM_1", "VM_2", "VM_3"}}},
+	}}
+	validator := &app.GraphValidator{Reader: reader, SampleSize: 3}
+
+	report, err := validator.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(reader.queries) != len(checks) || len(report.Checks) != len(checks) {
+		t.Fatalf("expect every check to run, got %d queries", len(reader.queries))
+	}
+	if report.OK() || report.Violations != 4 {
+		t.Fatalf("expect 4 violations, got %+v", report)
+	}
+	vm := report.Checks[2]
+	if vm.Name != "vm_without_host" || vm.Count != 4 || len(vm.Samples) != 3 || vm.Samples[0] != "VM_1" {
+		t.Fatalf("unexpected vm check result %+v", vm)
+	}
+}
+
+func TestGraphValidatorRunsCustomChecks(t *testing.T) {
+	custom := app.GraphCheck{Name: "np_without_cidr", Match: "MATCH (n:NetPartition) WHERE n.cidr = ''"}
+	reader := &fakeGraphReader{rows: map[string][]map[string]any{
+		custom.Match: {{"count": int64(1), "samples": []any{"NP_1"}}},
+	}}
+	validator := &app.GraphValidator{Reader: reader, Checks: append(app.DefaultGraphChecks(), custom), SampleSize: 3}
+
+	report, err := validator.Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	last := report.Checks[len(report.Checks)-1]
+	if last.Name != custom.Name || last.Count != 1 || report.Violations != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
+
+func TestGraphValidatorPropagatesQueryError(t *testing.T) {
+	validator := &app.GraphValidator{Reader: &fakeGraphReader{err: errors.New("boom")}}
+	if _, err := validator.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "idc_without_partition") {
+		t.Fatalf("expect error naming the failed check, got %v", err)
+	}
+}
+
+func TestGraphValidatorUsesRemappedSchema(t *testing.T) {
+	schema, err := domain.NewSchema(
+		map[string]string{domain.LabelVirtualMachine: "CmdbVM", domain.LabelMachine: "CmdbMachine", domain.LabelApp: "CmdbApp"},
+		map[string]string{domain.RelHostsVM: "CMDB_HOSTS_VM", domain.RelAppDeploy: "CMDB_DEPLOYED_ON"},
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("new schema: %v", err)
+	}
+	reader := &fakeGraphReader{}
+	validator := &app.GraphValidator{Reader: reader, Schema: schema, SampleSize: 3}
+	if _, err := validator.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	queries := strings.Join(reader.queries, "\n")
+	for _, want := range []string{"(n:CmdbVM)", "(c:CmdbMachine)-[r:CMDB_HOSTS_VM]->(n)", "(n:CmdbApp)", "[r:CMDB_DEPLOYED_ON]", "(c:CmdbVM|HostMachine)"} {
+		if !strings.Contains(queries, want) {
+			t.Fatalf("expect %q in checks built from the schema, got:\n%s", want, queries)
+		}
+	}
+	for _, stale := range []string{":VirtualMachine", ":HOSTS_VM", ":App)", ":DEPLOYED_ON"} {
+		if strings.Contains(queries, stale) {
+			t.Fatalf("expect no default name %q left, got:\n%s", stale, queries)
+		}
+	}
+}
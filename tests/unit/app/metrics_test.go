@@ -0,0 +1,81 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/cmdb"
+)
+
+// fakeRecorder 记录流程上报的指标，无需 Prometheus registry。
+type fakeRecorder struct {
+	fetches  int
+	syncs    map[string][]error
+	upserted map[string][2]int
+	orphans  map[string]int
+}
+
+func newFakeRecorder() *fakeRecorder {
+	return &fakeRecorder{syncs: make(map[string][]error), upserted: make(map[string][2]int), orphans: make(map[string]int)}
+}
+
+func (r *fakeRecorder) ObserveCMDBFetch(time.Duration, error)     { r.fetches++ }
+func (r *fakeRecorder) ObserveQuery(string, time.Duration, error) {}
+func (r *fakeRecorder) ObserveAnalyze(time.Duration, int, error)  {}
+func (r *fakeRecorder) ObserveConnectivity(string, error)         {}
+func (r *fakeRecorder) ObserveReconnect(string, error)            {}
+func (r *fakeRecorder) SetSessionsInUse(string, int)              {}
+func (r *fakeRecorder) ObserveQueryCache(bool)                    {}
+func (r *fakeRecorder) ObserveChainCache(bool)                    {}
+
+func (r *fakeRecorder) SetOrphans(label string, n int) { r.orphans[label] = n }
+
+func (r *fakeRecorder) ObserveUpserted(flow string, nodes, rels int) {
+	total := r.upserted[flow]
+	r.upserted[flow] = [2]int{total[0] + nodes, total[1] + rels}
+}
+
+func (r *fakeRecorder) ObserveSync(flow string, _ time.Duration, err error) {
+	r.syncs[flow] = append(r.syncs[flow], err)
+}
+
+func TestFlowsRecordMetrics(t *testing.T) {
+	recorder := newFakeRecorder()
+	nodes := &fakeNodeWriter{}
+	rels := &fakeRelWriter{}
+	initFlow := &app.InitFlow{
+		CMDB:    &cmdb.StaticClient{Snapshot: sampleSnapshot()},
+		Nodes:   nodes,
+		Rels:    rels,
+		Metrics: recorder,
+	}
+	if err := initFlow.Run(context.Background()); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if got := recorder.upserted["init"]; got[0] != len(nodes.rows) || got[1] != len(rels.rows) || got[0] == 0 {
+		t.Fatalf("expect init upserts %d/%d recorded, got %v", len(nodes.rows), len(rels.rows), got)
+	}
+
+	syncFlow := &app.SyncFlow{
+		CMDB:    &cmdb.StaticClient{Snapshot: sampleSnapshot()},
+		Nodes:   &fakeNodeWriter{failures: 3},
+		Rels:    &fakeRelWriter{},
+		Cleaner: &fakeCleaner{},
+		Retry:   app.Retry{Attempts: 2},
+		Metrics: recorder,
+	}
+	if err := syncFlow.Run(context.Background()); err == nil {
+		t.Fatalf("expect sync to fail")
+	}
+	if recorder.fetches != 3 {
+		t.Fatalf("expect a fetch per attempt, got %d", recorder.fetches)
+	}
+	if len(recorder.syncs["init"]) != 1 || recorder.syncs["init"][0] != nil {
+		t.Fatalf("expect one successful init run, got %v", recorder.syncs["init"])
+	}
+	if len(recorder.syncs["sync"]) != 1 || recorder.syncs["sync"][0] == nil {
+		t.Fatalf("expect retries reported as one failed sync run, got %v", recorder.syncs["sync"])
+	}
+}
@@ -0,0 +1,205 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/loader"
+)
+
+type fakeGraphState struct {
+	nodes []domain.NodeRow
+	rels  []domain.RelRow
+}
+
+func (g *fakeGraphState) Nodes(context.Context) ([]domain.NodeRow, error) {
+	return g.nodes, nil
+}
+
+func (g *fakeGraphState) Relationships(context.Context) ([]domain.RelRow, error) {
+	return g.rels, nil
+}
+
+// graphFromSnapshot 模拟图中已写入快照的状态，整数按 Neo4j 的 int64 返回。
+func graphFromSnapshot(snapshot cmdb.Snapshot) *fakeGraphState {
+	nodes, rels := cmdb.BuildInitRows(snapshot)
+	for i := range nodes {
+		props := make(map[string]any, len(nodes[i].Properties))
+		for k, v := range nodes[i].Properties {
+			if n, ok := v.(int); ok {
+				v = int64(n)
+			}
+			props[k] = v
+		}
+		nodes[i].Properties = props
+	}
+	return &fakeGraphState{nodes: nodes, rels: rels}
+}
+
+func TestReconcileFlowRepairsOnlyDrift(t *testing.T) {
+	graph := graphFromSnapshot(sampleSnapshot())
+	// 漂移：虚拟机主机名被改、部署关系丢失、残留一个已下线的宿主机
+	for i := range graph.nodes {
+		if graph.nodes[i].CMDBKey == domain.MakeKey(domain.PrefixVirtual, 300) {
+			graph.nodes[i].Properties["hostname"] = "stale"
+		}
+	}
+	kept := graph.rels[:0]
+	for _, rel := range graph.rels {
+		if rel.Type != domain.RelAppDeploy {
+			kept = append(kept, rel)
+		}
+	}
+	graph.rels = kept
+	graph.nodes = append(graph.nodes, domain.NodeRow{CMDBKey: domain.MakeKey(domain.PrefixHostMachine, 999), Labels: []string{domain.LabelHostMachine, domain.LabelMachine, domain.LabelCompute}})
+
+	nodes := &fakeNodeWriter{}
+	rels := &fakeRelWriter{}
+	deleter := &fakeDeleter{}
+	flow := &app.ReconcileFlow{
+		CMDB:       &cmdb.StaticClient{Snapshot: sampleSnapshot()},
+		Graph:      graph,
+		Nodes:      nodes,
+		Rels:       rels,
+		Deleter:    deleter,
+		HardDelete: true,
+	}
+
+	summary, err := flow.Run(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if len(nodes.rows) != 1 || nodes.rows[0].CMDBKey != domain.MakeKey(domain.PrefixVirtual, 300) {
+		t.Fatalf("expect only the drifted vm upserted, got %+v", nodes.rows)
+	}
+	if len(rels.rows) != 1 || rels.rows[0].Type != domain.RelAppDeploy {
+		t.Fatalf("expect only the missing deployment upserted, got %+v", rels.rows)
+	}
+	if len(deleter.nodes) != 0 || summary.DeleteSkipped != 1 {
+		t.Fatalf("expect extra host kept without allow_delete, summary=%+v", summary)
+	}
+	if summary.NodesUpdated != 1 || summary.RelsAdded != 1 || summary.NodesAdded != 0 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	flow.AllowDelete = true
+	summary, err = flow.Run(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile with allow_delete: %v", err)
+	}
+	if len(deleter.nodes) != 1 || summary.NodesDeleted != 1 || summary.DeleteSkipped != 0 {
+		t.Fatalf("expect extra host deleted, summary=%+v deleted=%+v", summary, deleter.nodes)
+	}
+}
+
+func TestReconcileFlowDeleteGuardBlocksMassDelete(t *testing.T) {
+	graph := graphFromSnapshot(sampleSnapshot())
+	// 图中 4 台宿主机有 3 台不在快照中，删除比例 75%
+	for _, id := range []int{901, 902, 903} {
+		graph.nodes = append(graph.nodes, domain.NodeRow{CMDBKey: domain.MakeKey(domain.PrefixHostMachine, id), Labels: []string{domain.LabelHostMachine, domain.LabelMachine, domain.LabelCompute}})
+	}
+	deleter := &fakeDeleter{}
+	flow := &app.ReconcileFlow{
+		CMDB:        &cmdb.StaticClient{Snapshot: sampleSnapshot()},
+		Graph:       graph,
+		Nodes:       &fakeNodeWriter{},
+		Rels:        &fakeRelWriter{},
+		Deleter:     deleter,
+		AllowDelete: true,
+		Guard:       app.DeleteGuard{MaxRatio: 0.5, MinCount: 1},
+	}
+	if _, err := flow.Run(context.Background()); !errors.Is(err, app.ErrDeleteGuard) {
+		t.Fatalf("expect ErrDeleteGuard, got %v", err)
+	}
+	if len(deleter.tombstoneNodes) != 0 || len(deleter.tombstoneRels) != 0 {
+		t.Fatalf("expect nothing tombstoned when the guard trips, got %+v / %+v", deleter.tombstoneNodes, deleter.tombstoneRels)
+	}
+
+	flow.Guard = app.DeleteGuard{MaxRatio: 0.8, MinCount: 1}
+	summary, err := flow.Run(context.Background())
+	if err != nil || summary.NodesDeleted != 3 || len(deleter.tombstoneNodes) != 3 {
+		t.Fatalf("expect deletion within the ratio to proceed, got %+v / %v", summary, err)
+	}
+}
+
+func TestReconcileFlowNoDriftWritesNothing(t *testing.T) {
+	nodes := &fakeNodeWriter{}
+	flow := &app.ReconcileFlow{
+		CMDB:  &cmdb.StaticClient{Snapshot: sampleSnapshot()},
+		Graph: graphFromSnapshot(sampleSnapshot()),
+		Nodes: nodes,
+		Rels:  &fakeRelWriter{},
+	}
+	summary, err := flow.Run(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if nodes.calls != 0 || summary != (app.ReconcileSummary{RunID: "run-1"}) {
+		t.Fatalf("expect no writes for an in-sync graph, calls=%d summary=%+v", nodes.calls, summary)
+	}
+}
+
+type fakeOrphanRepairer struct {
+	runIDs []string
+	report loader.OrphanReport
+}
+
+func (r *fakeOrphanRepairer) Run(_ context.Context, runID string) (loader.OrphanReport, error) {
+	r.runIDs = append(r.runIDs, runID)
+	return r.report, nil
+}
+
+func TestReconcileFlowRepairsOrphans(t *testing.T) {
+	orphans := &fakeOrphanRepairer{report: loader.OrphanReport{Labels: []loader.OrphanFix{
+		{Label: domain.LabelHostMachine, Type: domain.RelHasHost, Orphans: 2, Fixed: 2},
+		{Label: domain.LabelVirtualMachine, Type: domain.RelHostsVM, Orphans: 3, Fixed: 1},
+	}}}
+	recorder := newFakeRecorder()
+	flow := &app.ReconcileFlow{
+		CMDB:    &cmdb.StaticClient{Snapshot: sampleSnapshot()},
+		Graph:   graphFromSnapshot(sampleSnapshot()),
+		Nodes:   &fakeNodeWriter{},
+		Rels:    &fakeRelWriter{},
+		Orphans: orphans,
+		Metrics: recorder,
+	}
+	summary, err := flow.Run(context.Background())
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if len(orphans.runIDs) != 1 || orphans.runIDs[0] != "run-1" {
+		t.Fatalf("expect orphan pass scoped to the reconcile run, got %v", orphans.runIDs)
+	}
+	if summary.OrphansFixed != 3 || summary.OrphansRemaining != 2 {
+		t.Fatalf("unexpected orphan totals %+v", summary)
+	}
+	if recorder.orphans[domain.LabelHostMachine] != 0 || recorder.orphans[domain.LabelVirtualMachine] != 2 || len(recorder.orphans) != 2 {
+		t.Fatalf("expect remaining orphans recorded per label, got %v", recorder.orphans)
+	}
+}
+
+func TestReconcileFlowRejectsEmptySnapshot(t *testing.T) {
+	deleter := &fakeDeleter{}
+	nodes := &fakeNodeWriter{}
+	flow := &app.ReconcileFlow{
+		CMDB:        &cmdb.StaticClient{Snapshot: cmdb.Snapshot{RunID: "run-2", IDCs: sampleSnapshot().IDCs}},
+		Graph:       graphFromSnapshot(sampleSnapshot()),
+		Nodes:       nodes,
+		Rels:        &fakeRelWriter{},
+		Deleter:     deleter,
+		AllowDelete: true,
+		HardDelete:  true,
+		// 小图低于 MinCount，删除保护不会生效
+		Guard: app.DeleteGuard{MaxRatio: 0.1, MinCount: 10},
+	}
+	if _, err := flow.Run(context.Background()); !errors.Is(err, app.ErrSnapshotEmpty) {
+		t.Fatalf("expect ErrSnapshotEmpty, got %v", err)
+	}
+	if nodes.calls != 0 || len(deleter.nodes) != 0 || len(deleter.rels) != 0 {
+		t.Fatalf("expect nothing written or deleted, got calls=%d deleter=%+v", nodes.calls, deleter)
+	}
+}
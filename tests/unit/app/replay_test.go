@@ -0,0 +1,158 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/loader"
+	"cmdb2neo/internal/rca"
+)
+
+// recordingAnalyzer 记录每次分析的窗口与告警，并为每条告警返回一个候选。
+type recordingAnalyzer struct {
+	windows []string
+	events  [][]rca.AlarmEvent
+}
+
+func (a *recordingAnalyzer) AnalyzeWithOptions(_ context.Context, events []rca.AlarmEvent, opts rca.AnalyzeOptions) (rca.Result, error) {
+	a.windows = append(a.windows, opts.WindowID)
+	a.events = append(a.events, events)
+	result := rca.Result{}
+	for _, evt := range events {
+		result.Candidates = append(result.Candidates, rca.Candidate{Node: rca.NodeRef{Key: "APP_" + evt.AppName}})
+	}
+	return result, nil
+}
+
+func writeReplayFile(t *testing.T, dir, step, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, step, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayFlowRunsStepsInOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeReplayFile(t, dir, "20240301T120000Z", "idc.json", `[{"id": 2, "name": "星光"}]`)
+	writeReplayFile(t, dir, "20240301T110000Z", "alarms.json", `[{"app_name": "payment", "rule_name": "down", "occurred_at": "2024-03-01T11:00:00Z"}]`)
+	writeReplayFile(t, dir, "20240301T100000Z", "idc.json", `[{"id": 1, "name": "M5"}]`)
+	writeReplayFile(t, dir, "20240301T100000Z", "alarms.json", `[{"app_name": "order", "rule_name": "down", "occurred_at": "2024-03-01T10:00:00Z"}]`)
+	// 时间范围外、命名不符合格式或没有内容的目录都不回放
+	writeReplayFile(t, dir, "20240302T000000Z", "idc.json", `[{"id": 3, "name": "三星大厦"}]`)
+	writeReplayFile(t, dir, "notes", "alarms.json", `[]`)
+	if err := os.MkdirAll(filepath.Join(dir, "20240301T103000Z"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	to, err := app.ParseReplayTime("2024-03-01T23:59:59Z")
+	if err != nil {
+		t.Fatalf("parse to: %v", err)
+	}
+	steps, err := app.LoadReplaySteps(dir, time.Time{}, to)
+	if err != nil {
+		t.Fatalf("load steps: %v", err)
+	}
+	if len(steps) != 3 || steps[0].RunID() != "20240301T100000Z" || steps[2].RunID() != "20240301T120000Z" {
+		t.Fatalf("expect 3 steps sorted by time, got %+v", steps)
+	}
+
+	nodes := &fakeNodeWriter{}
+	analyzer := &recordingAnalyzer{}
+	flow := &app.ReplayFlow{
+		Sync:     &app.SyncFlow{Nodes: nodes, Rels: &fakeRelWriter{}, Cleaner: &fakeCleaner{}, Retry: app.Retry{Attempts: 1}},
+		Analyzer: analyzer,
+	}
+	results, err := flow.Run(context.Background(), steps)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+
+	var runIDs []string
+	for _, row := range nodes.rows {
+		runIDs = append(runIDs, row.RunID+":"+row.Properties["name"].(string))
+	}
+	if len(runIDs) != 2 || runIDs[0] != "20240301T100000Z:M5" || runIDs[1] != "20240301T120000Z:星光" {
+		t.Fatalf("expect snapshots synced in order with step run ids, got %v", runIDs)
+	}
+	if len(analyzer.windows) != 2 || analyzer.windows[0] != "replay-20240301T100000Z" || analyzer.events[1][0].AppName != "payment" {
+		t.Fatalf("expect alarm windows analyzed in order, got %v", analyzer.windows)
+	}
+	if len(results) != 3 || results[0].RunID != "20240301T100000Z" || len(results[0].Candidates) != 1 || results[1].RunID != "" || results[2].Events != 0 {
+		t.Fatalf("unexpected replay results %+v", results)
+	}
+}
+
+func TestParseReplayTimeRejectsUnknownFormat(t *testing.T) {
+	if got, err := app.ParseReplayTime(" "); err != nil || !got.IsZero() {
+		t.Fatalf("expect empty value to mean unbounded, got %v %v", got, err)
+	}
+	if _, err := app.ParseReplayTime("2024/03/01"); err == nil {
+		t.Fatalf("expect error for unsupported format")
+	}
+}
+
+// recordingResetter 记录回放前的清空操作，并清空 cleaner 统计到的现有节点。
+type recordingResetter struct {
+	resets  int
+	cleaner *fakeCleaner
+}
+
+func (r *recordingResetter) Reset(_ context.Context, opts loader.ResetOptions) error {
+	if !opts.Confirm {
+		return loader.ErrResetNotConfirmed
+	}
+	r.resets++
+	if r.cleaner != nil {
+		r.cleaner.staleCounts = nil
+	}
+	return nil
+}
+
+func TestReplayFlowRefusesNonEmptyGraphWithoutWipe(t *testing.T) {
+	dir := t.TempDir()
+	writeReplayFile(t, dir, "20240301T100000Z", "idc.json", `[{"id": 1, "name": "M5"}]`)
+	steps, err := app.LoadReplaySteps(dir, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("load steps: %v", err)
+	}
+	live := []domain.LabelCount{{Label: domain.LabelHostMachine, Total: 12}}
+
+	nodes := &fakeNodeWriter{}
+	resetter := &recordingResetter{}
+	flow := &app.ReplayFlow{
+		Sync:     &app.SyncFlow{Nodes: nodes, Rels: &fakeRelWriter{}, Cleaner: &fakeCleaner{staleCounts: live}, Retry: app.Retry{Attempts: 1}},
+		Analyzer: &recordingAnalyzer{},
+		Reset:    resetter,
+	}
+	if _, err := flow.Run(context.Background(), steps); !errors.Is(err, app.ErrReplayGraphNotEmpty) {
+		t.Fatalf("expect replay refused on a non-empty graph, got %v", err)
+	}
+	if len(nodes.rows) != 0 || resetter.resets != 0 {
+		t.Fatalf("expect nothing written or wiped, got %d rows, %d resets", len(nodes.rows), resetter.resets)
+	}
+
+	cleaner := &fakeCleaner{staleCounts: live}
+	resetter.cleaner = cleaner
+	flow.Wipe = true
+	flow.Sync = &app.SyncFlow{Nodes: nodes, Rels: &fakeRelWriter{}, Cleaner: cleaner, Retry: app.Retry{Attempts: 1}}
+	if _, err := flow.Run(context.Background(), steps); err != nil {
+		t.Fatalf("replay with wipe: %v", err)
+	}
+	if resetter.resets != 1 || len(nodes.rows) == 0 {
+		t.Fatalf("expect graph wiped once before syncing, got %d resets, %d rows", resetter.resets, len(nodes.rows))
+	}
+	// 回放目标图按批次硬删除快照中缺失的实体，不受配置中的 allow_delete 影响
+	if cleaner.nodeDeletes != 1 || cleaner.relDeletes != 1 {
+		t.Fatalf("expect stale data hard deleted after the step, got nodes=%d rels=%d", cleaner.nodeDeletes, cleaner.relDeletes)
+	}
+}
@@ -0,0 +1,72 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/cmdb"
+)
+
+type fakePurger struct {
+	retentions []time.Duration
+}
+
+func (p *fakePurger) PurgeTombstones(_ context.Context, retention time.Duration) error {
+	p.retentions = append(p.retentions, retention)
+	return nil
+}
+
+func TestSyncFlowSoftDeletesByDefault(t *testing.T) {
+	cleaner := &fakeCleaner{}
+	purger := &fakePurger{}
+	flow := &app.SyncFlow{
+		CMDB:               &cmdb.StaticClient{Snapshot: sampleSnapshot()},
+		Nodes:              &fakeNodeWriter{},
+		Rels:               &fakeRelWriter{},
+		Cleaner:            cleaner,
+		AllowDelete:        true,
+		Purger:             purger,
+		TombstoneRetention: 24 * time.Hour,
+	}
+	if err := flow.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if cleaner.softNodeDeletes != 1 || cleaner.softRelDeletes != 1 || cleaner.nodeDeletes != 0 || cleaner.relDeletes != 0 {
+		t.Fatalf("expect tombstones instead of hard deletes, got %+v", cleaner)
+	}
+	if len(purger.retentions) != 1 || purger.retentions[0] != 24*time.Hour {
+		t.Fatalf("expect a purge with the configured retention, got %v", purger.retentions)
+	}
+
+	flow.HardDelete = true
+	if err := flow.Run(context.Background()); err != nil {
+		t.Fatalf("hard delete run: %v", err)
+	}
+	if cleaner.nodeDeletes != 1 || cleaner.softNodeDeletes != 1 {
+		t.Fatalf("expect hard delete when opted in, got %+v", cleaner)
+	}
+}
+
+func TestDeltaSyncTombstonesRemovedEntities(t *testing.T) {
+	client := &cmdb.StaticClient{Snapshot: sampleSnapshot()}
+	deleter := &fakeDeleter{}
+	store := &memorySnapshotStore{snapshot: sampleSnapshot(), saved: true}
+	flow := &app.SyncFlow{CMDB: client, Nodes: &fakeNodeWriter{}, Rels: &fakeRelWriter{}, Cleaner: &fakeCleaner{}, Deleter: deleter, Snapshots: store, AllowDelete: true}
+
+	next := sampleSnapshot()
+	next.RunID = "run-2"
+	next.Apps = nil
+	client.Snapshot = next
+
+	if err := flow.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(deleter.nodes) != 0 || len(deleter.rels) != 0 {
+		t.Fatalf("expect no hard deletes by default, got %+v", deleter)
+	}
+	if len(deleter.tombstoneNodes) != 1 || len(deleter.tombstoneRels) != 1 {
+		t.Fatalf("expect removed app and deployment tombstoned, got %+v", deleter)
+	}
+}
@@ -0,0 +1,127 @@
+package app_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/loader"
+)
+
+// pagedClient 按页回调快照片段，并把每次翻页记录到共享事件日志。
+type pagedClient struct {
+	parts  []cmdb.Snapshot
+	events *[]string
+}
+
+func (c *pagedClient) FetchSnapshot(context.Context) (cmdb.Snapshot, error) {
+	return cmdb.Snapshot{}, fmt.Errorf("batch fetch should not be used")
+}
+
+func (c *pagedClient) StreamSnapshot(_ context.Context, fn func(cmdb.Snapshot) error) (string, error) {
+	for i, part := range c.parts {
+		*c.events = append(*c.events, fmt.Sprintf("page%d", i+1))
+		part.RunID = "run-stream"
+		if err := fn(part); err != nil {
+			return "", err
+		}
+	}
+	return "run-stream", nil
+}
+
+// spyNodeWriter 记录每次写入的批大小，用于确认是按页写入的。
+type spyNodeWriter struct {
+	events  *[]string
+	batches []int
+}
+
+func (w *spyNodeWriter) InitNodes(ctx context.Context, rows []domain.NodeRow) (loader.WriteStats, error) {
+	return w.UpsertNodes(ctx, rows)
+}
+
+func (w *spyNodeWriter) UpsertNodes(_ context.Context, rows []domain.NodeRow) (loader.WriteStats, error) {
+	*w.events = append(*w.events, "nodes")
+	w.batches = append(w.batches, len(rows))
+	return loader.WriteStats{Created: len(rows), Batches: 1}, nil
+}
+
+type spyRelWriter struct {
+	events *[]string
+	rows   [][]domain.RelRow
+}
+
+func (w *spyRelWriter) InitRels(ctx context.Context, rows []domain.RelRow) (loader.WriteStats, error) {
+	return w.UpsertRels(ctx, rows)
+}
+
+func (w *spyRelWriter) UpsertRels(_ context.Context, rows []domain.RelRow) (loader.WriteStats, error) {
+	*w.events = append(*w.events, "rels")
+	w.rows = append(w.rows, rows)
+	return loader.WriteStats{Created: len(rows), Batches: 1}, nil
+}
+
+func TestSyncFlowStreamingWritesPerPage(t *testing.T) {
+	var events []string
+	client := &pagedClient{events: &events, parts: []cmdb.Snapshot{
+		{IDCs: []cmdb.IDC{{Id: 1, Name: "M5"}}},
+		{
+			HostMachines: []cmdb.HostMachine{{Id: 100, Ip: "10.0.0.10"}},
+			Apps:         []cmdb.App{{Id: 400, Name: "app1", Ip: "10.0.0.12", ServerType: "2"}},
+		},
+		{VirtualMachines: []cmdb.VirtualMachine{{Id: 300, Ip: "10.0.0.12", HostIp: "10.0.0.10"}}},
+	}}
+	nodes := &spyNodeWriter{events: &events}
+	rels := &spyRelWriter{events: &events}
+	cleaner := &fakeCleaner{}
+
+	flow := &app.SyncFlow{CMDB: client, Nodes: nodes, Rels: rels, Cleaner: cleaner, Streaming: true, AllowDelete: true, HardDelete: true}
+	if err := flow.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	expected := []string{"page1", "nodes", "rels", "page2", "nodes", "rels", "page3", "nodes", "rels"}
+	if fmt.Sprint(events) != fmt.Sprint(expected) {
+		t.Fatalf("expect interleaved writes %v, got %v", expected, events)
+	}
+	if fmt.Sprint(nodes.batches) != "[1 2 1]" {
+		t.Fatalf("expect per-page node batches [1 2 1], got %v", nodes.batches)
+	}
+
+	// 应用所在的虚拟机在下一页才出现，部署关系应随该页一并写入
+	last := rels.rows[2]
+	var deploy, hosts bool
+	for _, rel := range last {
+		switch rel.Type {
+		case domain.RelAppDeploy:
+			deploy = rel.StartKey == domain.MakeKey(domain.PrefixApp, 400)
+		case domain.RelHostsVM:
+			hosts = rel.EndKey == domain.MakeKey(domain.PrefixVirtual, 300)
+		}
+	}
+	if !deploy || !hosts {
+		t.Fatalf("expect deferred relations on the last page, got %+v", last)
+	}
+	if cleaner.nodeDeletes != 1 || cleaner.relDeletes != 1 {
+		t.Fatalf("expect a final clean pass, got %+v", cleaner)
+	}
+}
+
+func TestSyncFlowStreamingFallsBackToBatch(t *testing.T) {
+	nodes := &fakeNodeWriter{}
+	flow := &app.SyncFlow{
+		CMDB:      &cmdb.StaticClient{Snapshot: sampleSnapshot()},
+		Nodes:     nodes,
+		Rels:      &fakeRelWriter{},
+		Cleaner:   &fakeCleaner{},
+		Streaming: true,
+	}
+	if err := flow.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if nodes.calls != 1 || len(nodes.rows) != 5 {
+		t.Fatalf("expect a single batch write of 5 nodes, got calls=%d rows=%d", nodes.calls, len(nodes.rows))
+	}
+}
@@ -0,0 +1,78 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/cmdb"
+)
+
+func TestSyncFlowRetriesWholeFlow(t *testing.T) {
+	nodes := &fakeNodeWriter{failures: 1}
+	rels := &fakeRelWriter{}
+	cleaner := &fakeCleaner{}
+	flow := &app.SyncFlow{
+		CMDB:        &cmdb.StaticClient{Snapshot: sampleSnapshot()},
+		Nodes:       nodes,
+		Rels:        rels,
+		Cleaner:     cleaner,
+		Retry:       app.Retry{Attempts: 2},
+		AllowDelete: true,
+		HardDelete:  true,
+	}
+
+	if err := flow.Run(context.Background()); err != nil {
+		t.Fatalf("sync should succeed on second attempt: %v", err)
+	}
+	if nodes.calls != 2 {
+		t.Fatalf("expect 2 upsert attempts, got %d", nodes.calls)
+	}
+	if len(rels.rows) == 0 {
+		t.Fatalf("relationships should be written after retry")
+	}
+	if cleaner.nodeDeletes != 1 || cleaner.relDeletes != 1 {
+		t.Fatalf("cleanup should run once after the successful attempt, got nodes=%d rels=%d", cleaner.nodeDeletes, cleaner.relDeletes)
+	}
+}
+
+func TestSyncFlowGivesUpAfterAttempts(t *testing.T) {
+	nodes := &fakeNodeWriter{failures: 3}
+	cleaner := &fakeCleaner{}
+	flow := &app.SyncFlow{
+		CMDB:       &cmdb.StaticClient{Snapshot: sampleSnapshot()},
+		Nodes:      nodes,
+		Rels:       &fakeRelWriter{},
+		Cleaner:    cleaner,
+		Retry:      app.Retry{Attempts: 2},
+		HardDelete: true,
+	}
+
+	if err := flow.Run(context.Background()); err == nil {
+		t.Fatalf("expect error after exhausting attempts")
+	}
+	if nodes.calls != 2 {
+		t.Fatalf("expect 2 attempts, got %d", nodes.calls)
+	}
+	if cleaner.nodeDeletes != 0 {
+		t.Fatalf("cleanup must not run when upserts fail")
+	}
+}
+
+func TestSyncFlowReportsPayloadIssues(t *testing.T) {
+	snapshot := sampleSnapshot()
+	snapshot.Payload = cmdb.PayloadStats{Records: 5, Invalid: 2, UnknownServerTypes: map[int]int{5: 2}}
+	var written map[string]int
+	flow := &app.SyncFlow{CMDB: &cmdb.StaticClient{Snapshot: snapshot}, Nodes: &fakeNodeWriter{}, Rels: &fakeRelWriter{}, Cleaner: &fakeCleaner{},
+		Progress: func(stage string, counts map[string]int) {
+			if stage == "written" {
+				written = counts
+			}
+		}}
+	if err := flow.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if written["records_invalid"] != 2 || written["unknown_server_type"] != 2 || written["missing_id"] != 0 {
+		t.Fatalf("expect payload issues in sync summary, got %v", written)
+	}
+}
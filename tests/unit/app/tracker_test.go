@@ -0,0 +1,27 @@
+package app_test
+
+import (
+	"sync"
+	"testing"
+
+	"cmdb2neo/internal/app"
+)
+
+func TestServiceTrackerIsSharedAcrossConcurrentCallers(t *testing.T) {
+	var svc app.Service
+	trackers := make([]*app.SyncTracker, 16)
+	var wg sync.WaitGroup
+	for i := range trackers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			trackers[i] = svc.Tracker()
+		}(i)
+	}
+	wg.Wait()
+	for i, tracker := range trackers {
+		if tracker == nil || tracker != trackers[0] {
+			t.Fatalf("caller %d got a different tracker", i)
+		}
+	}
+}
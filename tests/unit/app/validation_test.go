@@ -0,0 +1,56 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/cmdb"
+)
+
+func danglingSnapshot() cmdb.Snapshot {
+	snapshot := sampleSnapshot()
+	snapshot.VirtualMachines[0].HostIp = "10.9.9.9"
+	return snapshot
+}
+
+type countingClient struct {
+	snapshot cmdb.Snapshot
+	fetches  int
+}
+
+func (c *countingClient) FetchSnapshot(context.Context) (cmdb.Snapshot, error) {
+	c.fetches++
+	return c.snapshot, nil
+}
+
+func TestStrictValidationAbortsBeforeWriting(t *testing.T) {
+	client := &countingClient{snapshot: danglingSnapshot()}
+	nodes := &fakeNodeWriter{}
+
+	initFlow := &app.InitFlow{CMDB: client, Nodes: nodes, Rels: &fakeRelWriter{}, StrictValidation: true}
+	if err := initFlow.Run(context.Background()); !errors.Is(err, app.ErrSnapshotInvalid) {
+		t.Fatalf("expect ErrSnapshotInvalid from init, got %v", err)
+	}
+
+	syncFlow := &app.SyncFlow{CMDB: client, Nodes: nodes, Rels: &fakeRelWriter{}, Cleaner: &fakeCleaner{}, Retry: app.Retry{Attempts: 3}, StrictValidation: true}
+	if err := syncFlow.Run(context.Background()); !errors.Is(err, app.ErrSnapshotInvalid) {
+		t.Fatalf("expect ErrSnapshotInvalid from sync, got %v", err)
+	}
+	if nodes.calls != 0 || client.fetches != 2 {
+		t.Fatalf("expect no writes and no retries, got %d calls and %d fetches", nodes.calls, client.fetches)
+	}
+}
+
+func TestLenientValidationOnlyWarns(t *testing.T) {
+	client := &cmdb.StaticClient{Snapshot: danglingSnapshot()}
+	nodes := &fakeNodeWriter{}
+	flow := &app.SyncFlow{CMDB: client, Nodes: nodes, Rels: &fakeRelWriter{}, Cleaner: &fakeCleaner{}}
+	if err := flow.Run(context.Background()); err != nil {
+		t.Fatalf("lenient sync: %v", err)
+	}
+	if len(nodes.rows) != 5 {
+		t.Fatalf("expect snapshot written despite issues, got %d rows", len(nodes.rows))
+	}
+}
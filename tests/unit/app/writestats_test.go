@@ -0,0 +1,38 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/cmdb"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFlowsLogWriteStats(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+	client := &cmdb.StaticClient{Snapshot: sampleSnapshot()}
+
+	initFlow := &app.InitFlow{CMDB: client, Nodes: &fakeNodeWriter{}, Rels: &fakeRelWriter{}, Logger: logger}
+	if err := initFlow.Run(context.Background()); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	syncFlow := &app.SyncFlow{CMDB: client, Nodes: &fakeNodeWriter{}, Rels: &fakeRelWriter{}, Cleaner: &fakeCleaner{}, Logger: logger}
+	if err := syncFlow.Run(context.Background()); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	nodes, rels := cmdb.BuildInitRows(sampleSnapshot())
+	for _, msg := range []string{"初始化同步完成", "增量同步完成"} {
+		entries := logs.FilterMessage(msg).All()
+		if len(entries) != 1 {
+			t.Fatalf("expect one %q log, got %d", msg, len(entries))
+		}
+		fields := entries[0].ContextMap()
+		if fields["nodes_created"] != int64(len(nodes)) || fields["rels_created"] != int64(len(rels)) || fields["batches"] != int64(2) {
+			t.Fatalf("expect %q to report write stats, got %v", msg, fields)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+)
+
+var testIDCs = []string{"M5", "IDC1", "IDC2"}
+
+func TestHTTPClientReusesPageOnNotModified(t *testing.T) {
+	var fullResponses, notModified int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + r.URL.Query().Get("idc") + `-v1"`
+		if r.Header.Get("If-None-Match") == etag {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&fullResponses, 1)
+		w.Header().Set("ETag", etag)
+		_ = json.NewEncoder(w).Encode(cmdb.Request{Data: cmdb.ResponseData{
+			Page:  1,
+			Limit: 20,
+			Total: 1,
+			Data: []cmdb.DataContent{{
+				Id:               1,
+				NetworkPartition: "np-" + r.URL.Query().Get("idc"),
+				ServerType:       1,
+				Ip:               "10.0.0.1",
+				HostName:         "host-1",
+			}},
+		}})
+	}))
+	defer srv.Close()
+
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: testIDCs})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+
+	first, err := client.FetchSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("first fetch: %v", err)
+	}
+	second, err := client.FetchSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("second fetch: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&notModified); got != int32(len(first.IDCs)) {
+		t.Fatalf("expect %d conditional hits, got %d", len(first.IDCs), got)
+	}
+	if got := atomic.LoadInt32(&fullResponses); got != int32(len(first.IDCs)) {
+		t.Fatalf("expect only first run to receive bodies, got %d", got)
+	}
+	if len(second.HostMachines) != len(first.HostMachines) || len(second.NetworkPartitions) != len(first.NetworkPartitions) {
+		t.Fatalf("cached snapshot mismatch: first=%+v second=%+v", first, second)
+	}
+}
+
+func TestHTTPClientKeepsEveryAppOnMachine(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(cmdb.Request{Data: cmdb.ResponseData{
+			Page:  1,
+			Limit: 20,
+			Total: 1,
+			Data: []cmdb.DataContent{{
+				Id:         7,
+				ServerType: 2,
+				Ip:         "10.0.1.7",
+				AppObj:     []cmdb.AppObject{{ID: 400, Name: "order"}, {ID: 401, Name: "payment"}},
+			}},
+		}})
+	}))
+	defer srv.Close()
+
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: []string{"M5"}})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	snapshot, err := client.FetchSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if len(snapshot.Apps) != 2 {
+		t.Fatalf("expect both apps on the VM, got %+v", snapshot.Apps)
+	}
+	for i, id := range []int{400, 401} {
+		if app := snapshot.Apps[i]; app.Id != id || app.Ip != "10.0.1.7" {
+			t.Fatalf("expect app %d deployed on 10.0.1.7, got %+v", id, app)
+		}
+	}
+}
+
+func TestHTTPClientDerivesStableAppIDs(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 第二次同步时机器 id 与应用顺序都变了，应用本身没变
+		item := cmdb.DataContent{Id: 7, ServerType: 2, Ip: "10.0.1.7", AppObj: []cmdb.AppObject{{Name: "order"}, {Name: "payment"}}}
+		if atomic.AddInt32(&calls, 1) > 1 {
+			item = cmdb.DataContent{Id: 8, ServerType: 2, Ip: "10.0.1.7", AppObj: []cmdb.AppObject{{Name: "payment"}, {Name: "order"}, {Name: "order"}}}
+		}
+		_ = json.NewEncoder(w).Encode(cmdb.Request{Data: cmdb.ResponseData{Page: 1, Limit: 20, Total: 1, Data: []cmdb.DataContent{item}}})
+	}))
+	defer srv.Close()
+
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: []string{"M5"}})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	appKeys := func() map[string]string {
+		snapshot, err := client.FetchSnapshot(context.Background())
+		if err != nil {
+			t.Fatalf("fetch: %v", err)
+		}
+		keys := make(map[string]string, len(snapshot.Apps))
+		for _, app := range snapshot.Apps {
+			keys[app.Name] = domain.MakeKey(domain.PrefixApp, app.KeyID())
+		}
+		if len(keys) != len(snapshot.Apps) {
+			t.Fatalf("expect apps deduplicated by stable id, got %+v", snapshot.Apps)
+		}
+		return keys
+	}
+	first, second := appKeys(), appKeys()
+	if len(first) != 2 || first["order"] == first["payment"] {
+		t.Fatalf("expect distinct keys for both apps, got %v", first)
+	}
+	for name, key := range first {
+		if second[name] != key {
+			t.Fatalf("expect %s keyed %s on re-sync, got %s", name, key, second[name])
+		}
+	}
+	if first["order"] != "APP_n:order@10.0.1.7" || first["order"] != domain.MakeKey(domain.PrefixApp, cmdb.AppNaturalKey("order", "10.0.1.7")) {
+		t.Fatalf("expect key derived from name and ip, got %s", first["order"])
+	}
+}
+
+func TestNaturalAppKeysStaySeparateFromNumericIDs(t *testing.T) {
+	snapshot := cmdb.Snapshot{
+		HostMachines: []cmdb.HostMachine{{Id: 1, Ip: "10.0.1.7"}},
+		Apps: []cmdb.App{
+			{Id: 400, Name: "order", Ip: "10.0.1.7"},
+			{NaturalKey: cmdb.AppNaturalKey("order", "10.0.1.7"), Name: "order", Ip: "10.0.1.7"},
+		},
+	}
+	nodes, _ := cmdb.BuildInitRows(snapshot)
+	props := make(map[string]map[string]any)
+	for _, node := range nodes {
+		if node.Labels[0] == domain.LabelApp {
+			props[node.CMDBKey] = node.Properties
+		}
+	}
+	if len(props) != 2 || props["APP_400"]["cmdb_id"] != 400 {
+		t.Fatalf("expect numeric and natural keys as separate apps, got %v", props)
+	}
+	natural, ok := props["APP_n:order@10.0.1.7"]
+	if _, hasID := natural["cmdb_id"]; !ok || hasID {
+		t.Fatalf("expect natural-keyed app without cmdb_id, got %v", props)
+	}
+}
+
+func TestHTTPClientRetriesServerErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(cmdb.Request{})
+	}))
+	defer srv.Close()
+
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: testIDCs, RetryAttempts: 3, RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := client.FetchSnapshot(context.Background()); err != nil {
+		t.Fatalf("fetch should recover after retries: %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got < 3 {
+		t.Fatalf("expect at least 3 calls, got %d", got)
+	}
+}
+
+func TestHTTPClientDoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: testIDCs, RetryAttempts: 5, RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := client.FetchSnapshot(context.Background()); err == nil {
+		t.Fatalf("expect 403 to fail")
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("4xx must not be retried, got %d calls", got)
+	}
+}
+
+func TestHTTPClientRetryHonorsCancellation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: testIDCs, RetryAttempts: 10, RetryBackoff: time.Hour})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.FetchSnapshot(ctx); err == nil {
+		t.Fatalf("expect cancellation error")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("retry loop ignored context cancellation")
+	}
+}
+
+func TestHTTPClientParallelFetchKeepsStableOrder(t *testing.T) {
+	hostIDs := map[string][]int{"M5": {11, 12}, "IDC1": {21, 22, 23}, "IDC2": {31}}
+	delays := map[string]time.Duration{"M5": 30 * time.Millisecond, "IDC1": 10 * time.Millisecond, "IDC2": 0}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idc := r.URL.Query().Get("idc")
+		time.Sleep(delays[idc])
+		var data []cmdb.DataContent
+		for _, id := range hostIDs[idc] {
+			data = append(data, cmdb.DataContent{Id: id, ServerType: 1, NetworkPartition: "np", Ip: "10.0.0.1"})
+		}
+		_ = json.NewEncoder(w).Encode(cmdb.Request{Data: cmdb.ResponseData{Page: 1, Limit: 20, Total: len(data), Data: data}})
+	}))
+	defer srv.Close()
+
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: testIDCs, Workers: 3})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	expected := []int{11, 12, 21, 22, 23, 31}
+	for run := 0; run < 3; run++ {
+		snapshot, err := client.FetchSnapshot(context.Background())
+		if err != nil {
+			t.Fatalf("fetch: %v", err)
+		}
+		if len(snapshot.HostMachines) != len(expected) {
+			t.Fatalf("expect %d hosts, got %d", len(expected), len(snapshot.HostMachines))
+		}
+		for i, host := range snapshot.HostMachines {
+			if host.Id != expected[i] {
+				t.Fatalf("run %d: host order mismatch at %d: got %d want %d", run, i, host.Id, expected[i])
+			}
+		}
+		if snapshot.IDCs[0].Name != "M5" || snapshot.IDCs[2].Name != "IDC2" {
+			t.Fatalf("idc order changed: %+v", snapshot.IDCs)
+		}
+	}
+}
+
+func TestHTTPClientParallelFetchReturnsFirstError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("idc") == "IDC1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(cmdb.Request{})
+	}))
+	defer srv.Close()
+
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: testIDCs, Workers: 3})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := client.FetchSnapshot(context.Background()); err == nil {
+		t.Fatalf("expect error from failing idc")
+	}
+}
+
+func TestHTTPClientRequiresIDCs(t *testing.T) {
+	if _, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: "http://cmdb.local", IDCs: []string{" ", ""}}); err == nil {
+		t.Fatalf("expect error when no idc configured")
+	}
+}
+
+func TestIDCIDKeepsLegacyIDs(t *testing.T) {
+	for name, want := range map[string]int{"M5": 1, "IDC1": 2, "IDC2": 3} {
+		if got := cmdb.IDCID(name); got != want {
+			t.Fatalf("expect legacy idc %s to keep id %d, got %d", name, want, got)
+		}
+	}
+	if id := cmdb.IDCID("IDC9"); id <= 3 || id != cmdb.IDCID("IDC9") {
+		t.Fatalf("expect new idc to get a stable hashed id, got %d", id)
+	}
+}
+
+func TestHTTPClientIDCIDsFollowName(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(cmdb.Request{})
+	}))
+	defer srv.Close()
+
+	fetch := func(idcs []string) map[string]int {
+		t.Helper()
+		client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: idcs})
+		if err != nil {
+			t.Fatalf("new client: %v", err)
+		}
+		snapshot, err := client.FetchSnapshot(context.Background())
+		if err != nil {
+			t.Fatalf("fetch: %v", err)
+		}
+		ids := make(map[string]int, len(snapshot.IDCs))
+		for _, idc := range snapshot.IDCs {
+			ids[idc.Name] = idc.Id
+		}
+		return ids
+	}
+
+	before := fetch([]string{"M5", "IDC1"})
+	after := fetch([]string{"IDC9", "IDC1", "M5", "M5"})
+	if len(after) != 3 {
+		t.Fatalf("expect duplicated idc to be dropped, got %v", after)
+	}
+	for name, id := range before {
+		if after[name] != id {
+			t.Fatalf("idc %s id changed from %d to %d after reordering", name, id, after[name])
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+)
+
+// containerFixture 为 容器→虚拟机→宿主机 的快照目录，另有一个容器直接跑在宿主机上。
+const containerFixture = "fixtures/container"
+
+func relsByType(rels []domain.RelRow, relType string) map[string]domain.RelRow {
+	out := make(map[string]domain.RelRow)
+	for _, rel := range rels {
+		if rel.Type == relType {
+			out[rel.StartKey] = rel
+		}
+	}
+	return out
+}
+
+func TestFileClientLoadsContainerFixture(t *testing.T) {
+	client, err := cmdb.NewFileClient(containerFixture)
+	if err != nil {
+		t.Fatalf("new file client: %v", err)
+	}
+	snapshot, err := client.FetchSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if snapshot.RunID != "20250301T000000Z" || len(snapshot.Containers) != 3 {
+		t.Fatalf("expect 3 containers, got %+v", snapshot.Containers)
+	}
+	if ctr := snapshot.Containers[0]; ctr.HostIp != "10.20.1.10" || ctr.NetworkPartion != "10" || ctr.ServerType != "4" {
+		t.Fatalf("unexpected container mapping %+v", ctr)
+	}
+	if issues := cmdb.ValidateSnapshot(snapshot); len(issues) != 0 {
+		t.Fatalf("expect fixture to be consistent, got %+v", issues)
+	}
+}
+
+func TestBuildInitRowsContainerChain(t *testing.T) {
+	client, err := cmdb.NewFileClient(containerFixture)
+	if err != nil {
+		t.Fatalf("new file client: %v", err)
+	}
+	snapshot, err := client.FetchSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	nodes, rels := cmdb.BuildInitRows(snapshot)
+
+	var containers int
+	for _, node := range nodes {
+		if node.Labels[0] != domain.LabelContainer {
+			continue
+		}
+		containers++
+		if domain.JoinLabels(node.Labels) != domain.JoinLabels([]string{domain.LabelContainer, domain.LabelCompute}) {
+			t.Fatalf("unexpected container labels %v", node.Labels)
+		}
+	}
+	if containers != 3 {
+		t.Fatalf("expect 3 container nodes, got %d", containers)
+	}
+
+	ctr := func(id int) string { return domain.MakeKey(domain.PrefixContainer, id) }
+	runsOn := relsByType(rels, domain.RelRunsOn)
+	if len(runsOn) != 3 {
+		t.Fatalf("expect every container to run on a node, got %+v", runsOn)
+	}
+	if rel := runsOn[ctr(300)]; rel.EndKey != domain.MakeKey(domain.PrefixVirtual, 200) || rel.Properties["via"] != "vm_ip" {
+		t.Fatalf("expect container 300 on vm 200, got %+v", rel)
+	}
+	if rel := runsOn[ctr(302)]; rel.EndKey != domain.MakeKey(domain.PrefixHostMachine, 100) || rel.Properties["via"] != "host_ip" {
+		t.Fatalf("expect container 302 directly on host 100, got %+v", rel)
+	}
+	if rel := relsByType(rels, domain.RelHostsVM)[domain.MakeKey(domain.PrefixHostMachine, 100)]; rel.EndKey != domain.MakeKey(domain.PrefixVirtual, 200) {
+		t.Fatalf("expect vm 200 hosted by host 100, got %+v", rel)
+	}
+	deployed := relsByType(rels, domain.RelAppDeploy)
+	if rel := deployed[domain.MakeKey(domain.PrefixApp, 400)]; rel.EndKey != ctr(300) || rel.Properties["via"] != "container_ip" {
+		t.Fatalf("expect app 400 deployed on container 300, got %+v", rel)
+	}
+}
+
+func TestRowMapperResolvesContainerBeforeVM(t *testing.T) {
+	mapper := cmdb.NewRowMapper("run-ctr")
+	_, rels := mapper.Map(cmdb.Snapshot{Containers: []cmdb.Container{{Id: 1, Ip: "10.30.0.1", HostIp: "10.20.1.10"}}})
+	if len(relsByType(rels, domain.RelRunsOn)) != 0 || mapper.Pending() != 1 {
+		t.Fatalf("expect container pending until its vm arrives, got %+v / %d", rels, mapper.Pending())
+	}
+	_, rels = mapper.Map(cmdb.Snapshot{VirtualMachines: []cmdb.VirtualMachine{{Id: 2, Ip: "10.20.1.10"}}})
+	if rel := relsByType(rels, domain.RelRunsOn)[domain.MakeKey(domain.PrefixContainer, 1)]; rel.EndKey != domain.MakeKey(domain.PrefixVirtual, 2) {
+		t.Fatalf("expect pending RUNS_ON resolved on the next page, got %+v", rels)
+	}
+	if mapper.Pending() != 0 {
+		t.Fatalf("expect nothing pending, got %d", mapper.Pending())
+	}
+}
+
+func TestValidateSnapshotFlagsDanglingContainerHost(t *testing.T) {
+	issues := cmdb.ValidateSnapshot(cmdb.Snapshot{
+		Containers: []cmdb.Container{{Id: 1, Ip: "10.30.0.1", HostIp: "10.20.9.9"}},
+		Apps:       []cmdb.App{{Id: 2, Ip: "10.30.0.1", ServerType: "4"}},
+	})
+	if len(issues) != 1 || issues[0].Kind != cmdb.IssueContainerHost || issues[0].Severity != cmdb.SeverityError {
+		t.Fatalf("expect one container_host error, got %+v", issues)
+	}
+}
+
+func TestHTTPClientMapsContainers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(cmdb.Request{Data: cmdb.ResponseData{
+			Page:  1,
+			Limit: 20,
+			Total: 2,
+			Data: []cmdb.DataContent{
+				{Id: 200, ServerType: 2, Ip: "10.20.1.10", HostIp: "10.20.0.10"},
+				{Id: 300, ServerType: 4, Ip: "10.30.0.1", HostName: "cart-7d9f", HostIp: "10.20.1.10", AppObj: []cmdb.AppObject{{ID: 400, Name: "cart-service"}}},
+			},
+		}})
+	}))
+	defer srv.Close()
+
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: []string{"M5"}})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	snapshot, err := client.FetchSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if snapshot.Payload.Invalid != 0 {
+		t.Fatalf("expect server_type 4 to be recognized, got %+v", snapshot.Payload)
+	}
+	if len(snapshot.Containers) != 1 || snapshot.Containers[0].HostIp != "10.20.1.10" || snapshot.Containers[0].Hostname != "cart-7d9f" {
+		t.Fatalf("expect container mapped from server_type 4, got %+v", snapshot.Containers)
+	}
+	if len(snapshot.Apps) != 1 || snapshot.Apps[0].ServerType != "4" {
+		t.Fatalf("expect app on the container to carry server_type 4, got %+v", snapshot.Apps)
+	}
+}
@@ -0,0 +1,88 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+)
+
+func diffBaseSnapshot(runID string) cmdb.Snapshot {
+	return cmdb.Snapshot{
+		RunID: runID,
+		IDCs:  []cmdb.IDC{{Id: 1, Name: "M5"}},
+		HostMachines: []cmdb.HostMachine{
+			{Id: 100, Ip: "10.0.0.10"},
+			{Id: 101, Ip: "10.0.0.11"},
+		},
+		VirtualMachines: []cmdb.VirtualMachine{{Id: 300, Ip: "10.0.0.30", HostIp: "10.0.0.10"}},
+		Apps:            []cmdb.App{{Id: 400, Name: "order", Ip: "10.0.0.30"}},
+	}
+}
+
+func TestDiffSnapshotsIdentical(t *testing.T) {
+	diff := cmdb.DiffSnapshots(diffBaseSnapshot("r1"), diffBaseSnapshot("r2"))
+	if !diff.Empty() {
+		t.Fatalf("expect empty diff across run ids, got %+v", diff)
+	}
+}
+
+func TestDiffSnapshotsTracksVMMove(t *testing.T) {
+	prev := diffBaseSnapshot("r1")
+	curr := diffBaseSnapshot("r2")
+	curr.VirtualMachines[0].HostIp = "10.0.0.11"
+	curr.Apps = nil
+	curr.HostMachines = append(curr.HostMachines, cmdb.HostMachine{Id: 102, Ip: "10.0.0.12"})
+
+	diff := cmdb.DiffSnapshots(prev, curr)
+
+	vmKey := domain.MakeKey(domain.PrefixVirtual, 300)
+	if len(diff.ChangedNodes) != 1 || diff.ChangedNodes[0].CMDBKey != vmKey || diff.ChangedNodes[0].Properties["host_ip"] != "10.0.0.11" {
+		t.Fatalf("expect moved vm as changed node, got %+v", diff.ChangedNodes)
+	}
+	if len(diff.AddedNodes) != 1 || diff.AddedNodes[0].CMDBKey != domain.MakeKey(domain.PrefixHostMachine, 102) {
+		t.Fatalf("expect new host added, got %+v", diff.AddedNodes)
+	}
+	if len(diff.RemovedNodes) != 1 || diff.RemovedNodes[0].CMDBKey != domain.MakeKey(domain.PrefixApp, 400) {
+		t.Fatalf("expect app removed, got %+v", diff.RemovedNodes)
+	}
+
+	var oldHostEdge, newHostEdge bool
+	for _, rel := range diff.RemovedRels {
+		if rel.Type == domain.RelHostsVM && rel.StartKey == domain.MakeKey(domain.PrefixHostMachine, 100) {
+			oldHostEdge = true
+		}
+	}
+	for _, rel := range diff.AddedRels {
+		if rel.Type == domain.RelHostsVM && rel.StartKey == domain.MakeKey(domain.PrefixHostMachine, 101) {
+			newHostEdge = true
+		}
+	}
+	if !oldHostEdge || !newHostEdge {
+		t.Fatalf("expect HOSTS_VM edge to move, removed=%+v added=%+v", diff.RemovedRels, diff.AddedRels)
+	}
+}
+
+func TestFileSnapshotStoreRoundTrip(t *testing.T) {
+	store, err := cmdb.NewFileSnapshotStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	ctx := context.Background()
+	if _, ok, err := store.Load(ctx); err != nil || ok {
+		t.Fatalf("expect empty store, ok=%v err=%v", ok, err)
+	}
+	for _, runID := range []string{"r1", "r2"} {
+		if err := store.Save(ctx, diffBaseSnapshot(runID)); err != nil {
+			t.Fatalf("save %s: %v", runID, err)
+		}
+	}
+	loaded, ok, err := store.Load(ctx)
+	if err != nil || !ok {
+		t.Fatalf("load: ok=%v err=%v", ok, err)
+	}
+	if loaded.RunID != "r2" || len(loaded.HostMachines) != 2 {
+		t.Fatalf("unexpected snapshot %+v", loaded)
+	}
+}
@@ -0,0 +1,171 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/loader"
+)
+
+func customSchema(t *testing.T) domain.Schema {
+	t.Helper()
+	schema, err := domain.NewSchema(
+		map[string]string{domain.LabelApp: "Application", domain.LabelVirtualMachine: "VM"},
+		map[string]string{domain.RelAppDeploy: "INSTALLED_ON"},
+		map[string]string{domain.LabelApp: "A"},
+	)
+	if err != nil {
+		t.Fatalf("new schema: %v", err)
+	}
+	return schema
+}
+
+func TestDefaultSchemaMatchesConstants(t *testing.T) {
+	schema := domain.DefaultSchema()
+	if !schema.IsDefault() {
+		t.Fatalf("expect default schema")
+	}
+	for _, label := range domain.EntityLabels {
+		if schema.Label(label) != label || schema.CanonicalLabel(label) != label {
+			t.Fatalf("expect label %s unchanged", label)
+		}
+	}
+	for _, relType := range domain.RelTypes {
+		if schema.Rel(relType) != relType {
+			t.Fatalf("expect rel %s unchanged", relType)
+		}
+	}
+	if schema.MakeKey(domain.LabelVirtualMachine, 7) != domain.MakeKey(domain.PrefixVirtual, 7) {
+		t.Fatalf("expect default key prefix, got %s", schema.MakeKey(domain.LabelVirtualMachine, 7))
+	}
+	// 空值与默认值相同的覆盖项等同于未配置
+	same, err := domain.NewSchema(map[string]string{domain.LabelApp: "App", domain.LabelIDC: " "}, nil, map[string]string{domain.LabelApp: domain.PrefixApp})
+	if err != nil || !same.IsDefault() {
+		t.Fatalf("expect no-op overrides to keep default schema, got %v", err)
+	}
+}
+
+func TestSchemaOverridesFallBackToDefaults(t *testing.T) {
+	schema := customSchema(t)
+	if schema.Label(domain.LabelApp) != "Application" || schema.Label(domain.LabelHostMachine) != domain.LabelHostMachine {
+		t.Fatalf("unexpected labels: %s %s", schema.Label(domain.LabelApp), schema.Label(domain.LabelHostMachine))
+	}
+	if schema.Rel(domain.RelAppDeploy) != "INSTALLED_ON" || schema.Rel(domain.RelHostsVM) != domain.RelHostsVM {
+		t.Fatalf("unexpected rels: %v", schema.RelTypes())
+	}
+	if schema.MakeKey(domain.LabelApp, 1) != "A_1" || schema.MakeKey(domain.LabelIDC, 1) != "IDC_1" {
+		t.Fatalf("unexpected keys: %s %s", schema.MakeKey(domain.LabelApp, 1), schema.MakeKey(domain.LabelIDC, 1))
+	}
+	if got := schema.LabelPattern([]string{domain.LabelVirtualMachine, domain.LabelMachine}); got != ":Machine:VM" {
+		t.Fatalf("unexpected label pattern %s", got)
+	}
+	if schema.CanonicalLabel("Application") != domain.LabelApp || schema.CanonicalRel("INSTALLED_ON") != domain.RelAppDeploy {
+		t.Fatalf("expect actual names mapped back to canonical ones")
+	}
+}
+
+func TestSchemaRejectsInvalidNames(t *testing.T) {
+	cases := []struct {
+		name     string
+		labels   map[string]string
+		rels     map[string]string
+		prefixes map[string]string
+		want     error
+	}{
+		{"unknown label", map[string]string{"User": "Person"}, nil, nil, domain.ErrInvalidLabel},
+		{"injected label", map[string]string{domain.LabelApp: "App) DETACH DELETE (n"}, nil, nil, domain.ErrInvalidLabel},
+		{"label taken by another", map[string]string{domain.LabelApp: domain.LabelService}, nil, nil, domain.ErrInvalidLabel},
+		{"duplicate labels", map[string]string{domain.LabelApp: "Node", domain.LabelService: "Node"}, nil, nil, domain.ErrInvalidLabel},
+		{"unknown rel", nil, map[string]string{"OWNS": "HAS"}, nil, domain.ErrInvalidRelType},
+		{"injected rel", nil, map[string]string{domain.RelAppDeploy: "RUNS-ON"}, nil, domain.ErrInvalidRelType},
+		{"bad prefix", nil, nil, map[string]string{domain.LabelApp: "APP_"}, domain.ErrInvalidKeyPrefix},
+		{"duplicate prefix", nil, nil, map[string]string{domain.LabelApp: domain.PrefixService}, domain.ErrInvalidKeyPrefix},
+		{"prefix for helper label", nil, nil, map[string]string{domain.LabelMachine: "M"}, domain.ErrInvalidKeyPrefix},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := domain.NewSchema(tc.labels, tc.rels, tc.prefixes); !errors.Is(err, tc.want) {
+				t.Fatalf("expect %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestBuildInitRowsUsesSchemaPrefixes(t *testing.T) {
+	snapshot := cmdb.Snapshot{
+		RunID:           "test",
+		VirtualMachines: []cmdb.VirtualMachine{{Id: 300, Hostname: "vm1", Ip: "10.0.0.12"}},
+		Apps:            []cmdb.App{{Id: 400, Name: "app1", Ip: "10.0.0.12"}},
+	}
+	nodes, rels := cmdb.BuildInitRows(snapshot, cmdb.WithSchema(customSchema(t)))
+	keys := make(map[string][]string, len(nodes))
+	for _, node := range nodes {
+		keys[node.CMDBKey] = node.Labels
+	}
+	if _, ok := keys["A_400"]; !ok {
+		t.Fatalf("expect app key with custom prefix, got %v", keys)
+	}
+	if labels, ok := keys["VM_300"]; !ok || !strings.Contains(strings.Join(labels, ","), domain.LabelVirtualMachine) {
+		t.Fatalf("expect rows to keep canonical labels, got %v", keys)
+	}
+	if len(rels) != 1 || rels[0].StartKey != "A_400" || rels[0].Type != domain.RelAppDeploy {
+		t.Fatalf("expect canonical deploy rel from custom key, got %+v", rels)
+	}
+}
+
+func TestEdgeFixerUsesSchemaNames(t *testing.T) {
+	client := &fakeEdgeFixClient{counts: map[string][2]int64{"DEPLOYED_ON:VirtualMachine": {1, 0}}}
+	var queries []string
+	fixer := loader.NewEdgeFixer(&recordingEdgeFixClient{inner: client, queries: &queries})
+	fixer.Schema = customSchema(t)
+	report, err := fixer.Run(context.Background(), "20250101T000000Z")
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if report.Created() != 1 {
+		t.Fatalf("expect report keyed by canonical names, got %+v", report)
+	}
+	all := strings.Join(queries, "\n")
+	if !strings.Contains(all, "(app:Application)") || !strings.Contains(all, "[r:INSTALLED_ON]") || !strings.Contains(all, "(vm:VM {ip: app.ip})") {
+		t.Fatalf("expect custom names in fix statements:\n%s", all)
+	}
+	if strings.Contains(all, ":App)") || strings.Contains(all, ":DEPLOYED_ON]") || strings.Contains(all, "{{") {
+		t.Fatalf("expect default names replaced:\n%s", all)
+	}
+}
+
+// recordingEdgeFixClient 记录补边语句后交给 inner 处理。
+type recordingEdgeFixClient struct {
+	inner   loader.EdgeFixClient
+	queries *[]string
+}
+
+func (c *recordingEdgeFixClient) RunWriteRecords(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	*c.queries = append(*c.queries, query)
+	return c.inner.RunWriteRecords(ctx, query, params)
+}
+
+func TestSchemaManagerUsesSchemaLabels(t *testing.T) {
+	client := &recordingSchemaClient{}
+	manager := loader.NewSchemaManager(client)
+	manager.Schema = customSchema(t)
+	if _, err := manager.Ensure(context.Background()); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	all := strings.Join(client.statements, "\n")
+	if !strings.Contains(all, "CREATE CONSTRAINT app_cmdb_key IF NOT EXISTS FOR (n:Application)") || strings.Contains(all, "(n:App)") {
+		t.Fatalf("expect constraints on custom label:\n%s", all)
+	}
+
+	statements, err := loader.ResetStatements(loader.ResetOptions{Confirm: true, Labels: []string{domain.LabelApp}, Schema: manager.Schema})
+	if err != nil {
+		t.Fatalf("reset statements: %v", err)
+	}
+	if len(statements) != 1 || !strings.HasPrefix(statements[0], "MATCH (n:Application)") {
+		t.Fatalf("expect reset scoped to custom label, got %v", statements)
+	}
+}
@@ -0,0 +1,113 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/graph"
+	"cmdb2neo/internal/loader"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+func TestMarkErrorKeepsMessageAndChain(t *testing.T) {
+	cause := errors.New("dial tcp: connection refused")
+	err := fmt.Errorf("拉取失败: %w", domain.MarkError(cmdb.ErrCMDBUnavailable, cause))
+	if err.Error() != "拉取失败: dial tcp: connection refused" {
+		t.Fatalf("expect message unchanged, got %q", err.Error())
+	}
+	if !errors.Is(err, cmdb.ErrCMDBUnavailable) || !errors.Is(err, cause) {
+		t.Fatalf("expect both kind and cause reachable, got %v", err)
+	}
+	if domain.MarkError(cmdb.ErrCMDBAuth, nil) != nil {
+		t.Fatalf("expect nil error to stay nil")
+	}
+}
+
+func TestHTTPClientClassifiesErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		handle func(w http.ResponseWriter)
+		kind   error
+	}{
+		{"unauthorized", func(w http.ResponseWriter) { w.WriteHeader(http.StatusUnauthorized) }, cmdb.ErrCMDBAuth},
+		{"forbidden", func(w http.ResponseWriter) { w.WriteHeader(http.StatusForbidden) }, cmdb.ErrCMDBAuth},
+		{"server error", func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) }, cmdb.ErrCMDBUnavailable},
+		{"malformed body", func(w http.ResponseWriter) { _, _ = w.Write([]byte("{not json")) }, domain.ErrValidation},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { tc.handle(w) }))
+			defer srv.Close()
+
+			client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: testIDCs, RetryAttempts: 2, RetryBackoff: time.Millisecond})
+			if err != nil {
+				t.Fatalf("new client: %v", err)
+			}
+			if _, err := client.FetchSnapshot(context.Background()); !errors.Is(err, tc.kind) {
+				t.Fatalf("expect %v, got %v", tc.kind, err)
+			}
+		})
+	}
+}
+
+func TestPasswordTokenSourceClassifiesErrors(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusUnauthorized)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(int(status.Load())) }))
+	defer srv.Close()
+
+	tokens, err := cmdb.NewPasswordTokenSource(cmdb.PasswordTokenConfig{Endpoint: srv.URL, Username: "u", Password: "p"})
+	if err != nil {
+		t.Fatalf("new token source: %v", err)
+	}
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: testIDCs, TokenSource: tokens})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := client.FetchSnapshot(context.Background()); !errors.Is(err, cmdb.ErrCMDBAuth) {
+		t.Fatalf("expect rejected credentials to be ErrCMDBAuth, got %v", err)
+	}
+	// 刷新失败后处于退避期，使用新的 token source 验证 5xx 的分类
+	status.Store(http.StatusServiceUnavailable)
+	tokens, err = cmdb.NewPasswordTokenSource(cmdb.PasswordTokenConfig{Endpoint: srv.URL, Username: "u", Password: "p"})
+	if err != nil {
+		t.Fatalf("new token source: %v", err)
+	}
+	if _, err := tokens.Token(context.Background()); !errors.Is(err, cmdb.ErrCMDBUnavailable) || errors.Is(err, cmdb.ErrCMDBAuth) {
+		t.Fatalf("expect token endpoint 5xx to be ErrCMDBUnavailable, got %v", err)
+	}
+}
+
+func TestFileClientMarksMalformedFilesAsValidation(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "idc.json"), []byte("[{"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	client, err := cmdb.NewFileClient(dir)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := client.FetchSnapshot(context.Background()); !errors.Is(err, domain.ErrValidation) {
+		t.Fatalf("expect ErrValidation, got %v", err)
+	}
+}
+
+func TestGraphClassifyErrorSharesLoaderSentinel(t *testing.T) {
+	err := graph.ClassifyError(fmt.Errorf("执行查询失败: %w", &neo4j.ConnectivityError{Inner: errors.New("connection refused")}))
+	if !errors.Is(err, graph.ErrNeo4jUnavailable) || !errors.Is(err, loader.ErrNeo4jUnavailable) {
+		t.Fatalf("expect connectivity failure marked unavailable for both readers and writers, got %v", err)
+	}
+	if plain := errors.New("syntax error"); graph.ClassifyError(plain) != plain {
+		t.Fatalf("expect unrelated errors unchanged")
+	}
+}
@@ -0,0 +1,122 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/tests/testdata"
+)
+
+func TestFileClientMatchesJSONFixture(t *testing.T) {
+	client, err := cmdb.NewFileClient(".")
+	if err != nil {
+		t.Fatalf("new file client: %v", err)
+	}
+	first, err := client.FetchSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	second, err := client.FetchSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("fetch again: %v", err)
+	}
+	if first.RunID == "" || first.RunID != second.RunID {
+		t.Fatalf("expect stable run id, got %q and %q", first.RunID, second.RunID)
+	}
+
+	expected := testdata.LoadSnapshotFromJSON(t)
+	if len(first.IDCs) != len(expected.IDCs) ||
+		len(first.NetworkPartitions) != len(expected.NetworkPartitions) ||
+		len(first.HostMachines) != len(expected.HostMachines) ||
+		len(first.PhysicalMachines) != len(expected.PhysicalMachines) ||
+		len(first.VirtualMachines) != len(expected.VirtualMachines) ||
+		len(first.Apps) != len(expected.Apps) {
+		t.Fatalf("snapshot size mismatch with fixture loader")
+	}
+	if first.VirtualMachines[0] != expected.VirtualMachines[0] {
+		t.Fatalf("vm mapping mismatch: %+v vs %+v", first.VirtualMachines[0], expected.VirtualMachines[0])
+	}
+}
+
+func writeSnapshotDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestFileClientUsesRunIDFile(t *testing.T) {
+	dir := writeSnapshotDir(t, map[string]string{
+		"idc.json": `[{"id":1,"name":"M5"}]`,
+		"run_id":   "20250101T000000Z-0002\n",
+	})
+	client, err := cmdb.NewFileClient(dir)
+	if err != nil {
+		t.Fatalf("new file client: %v", err)
+	}
+	snapshot, err := client.FetchSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if snapshot.RunID != "20250101T000000Z-0002" {
+		t.Fatalf("expect run id from file, got %q", snapshot.RunID)
+	}
+}
+
+// run_id 文件中无法按字符串比较先后的批次号会让墓碑清理误判，读取时直接拒绝。
+func TestFileClientRejectsMalformedRunID(t *testing.T) {
+	dir := writeSnapshotDir(t, map[string]string{
+		"idc.json": `[{"id":1,"name":"M5"}]`,
+		"run_id":   "incident-42\n",
+	})
+	client, err := cmdb.NewFileClient(dir)
+	if err != nil {
+		t.Fatalf("new file client: %v", err)
+	}
+	_, err = client.FetchSnapshot(context.Background())
+	if !errors.Is(err, domain.ErrInvalidRunID) || !errors.Is(err, domain.ErrValidation) {
+		t.Fatalf("expect invalid run id validation error, got %v", err)
+	}
+}
+
+func TestFileClientRejectsMalformedFile(t *testing.T) {
+	dir := writeSnapshotDir(t, map[string]string{
+		"idc.json":          `[{"id":1,"name":"M5"}]`,
+		"host_machine.json": `[{"id":1,`,
+	})
+	client, err := cmdb.NewFileClient(dir)
+	if err != nil {
+		t.Fatalf("new file client: %v", err)
+	}
+	_, err = client.FetchSnapshot(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "host_machine.json") {
+		t.Fatalf("expect error naming the malformed file, got %v", err)
+	}
+}
+
+func TestFileClientRejectsDanglingHostIP(t *testing.T) {
+	dir := writeSnapshotDir(t, map[string]string{
+		"idc.json":               `[{"id":1,"name":"M5"}]`,
+		"network_partition.json": `[{"id":10,"idc":"M5","Name":"np"}]`,
+		"host_machine.json":      `[{"id":100,"idc":"M5","network_partition":"np","server_type":1,"ip":"10.0.0.1"}]`,
+		"virtual_machine.json":   `[{"id":300,"idc":"M5","network_partition":"np","server_type":2,"ip":"10.0.0.2","host_ip":"10.0.0.99"}]`,
+	})
+	client, err := cmdb.NewFileClient(dir)
+	if err != nil {
+		t.Fatalf("new file client: %v", err)
+	}
+	_, err = client.FetchSnapshot(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "10.0.0.99") {
+		t.Fatalf("expect dangling host_ip error, got %v", err)
+	}
+}
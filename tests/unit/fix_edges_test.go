@@ -0,0 +1,66 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/loader"
+)
+
+// fakeEdgeFixClient 按语句匹配的关系与终点标签返回预设的补边计数，并记录收到的参数。
+type fakeEdgeFixClient struct {
+	counts map[string][2]int64
+	params []map[string]any
+	err    error
+}
+
+func (c *fakeEdgeFixClient) RunWriteRecords(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	c.params = append(c.params, params)
+	if c.err != nil {
+		return nil, c.err
+	}
+	for name, n := range c.counts {
+		relType, target, _ := strings.Cut(name, ":")
+		if strings.Contains(query, "'"+relType+"' AS type, '"+target+"' AS target") {
+			return []map[string]any{{"type": relType, "target": target, "created": n[0], "repaired": n[1]}}, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestEdgeFixerReportsPerRelationship(t *testing.T) {
+	client := &fakeEdgeFixClient{counts: map[string][2]int64{
+		"HOSTS_VM:VirtualMachine":     {2, 1},
+		"DEPLOYED_ON:VirtualMachine":  {3, 0},
+		"DEPLOYED_ON:PhysicalMachine": {1, 0},
+	}}
+	report, err := loader.NewEdgeFixer(client).Run(context.Background(), "20250101T000000Z")
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	var names []string
+	for _, fix := range report.Fixes {
+		names = append(names, fix.Name())
+	}
+	if strings.Join(names, ",") != "HOSTS_VM:VirtualMachine,DEPLOYED_ON:VirtualMachine,DEPLOYED_ON:PhysicalMachine" {
+		t.Fatalf("expect entries in statement order, got %v", names)
+	}
+	if report.Created() != 6 || report.Repaired() != 1 {
+		t.Fatalf("expect totals 6 created / 1 repaired, got %d / %d", report.Created(), report.Repaired())
+	}
+	for _, params := range client.params {
+		if params["run_id"] != "20250101T000000Z" {
+			t.Fatalf("expect every statement scoped to run id, got %v", params)
+		}
+	}
+}
+
+func TestEdgeFixerWrapsError(t *testing.T) {
+	cause := errors.New("deadlock")
+	_, err := loader.NewEdgeFixer(&fakeEdgeFixClient{err: cause}).Run(context.Background(), "r1")
+	if !errors.Is(err, cause) || !strings.Contains(err.Error(), "补边失败") {
+		t.Fatalf("expect wrapped error, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/loader"
+)
+
+var maliciousIdentifiers = []string{
+	"",
+	"App) DETACH DELETE (n",
+	"App`) MATCH (x",
+	"App {cmdb_key: 'x'}",
+	"App:Secret",
+	"App\nMATCH (n) DETACH DELETE n",
+	"1App",
+	"App ",
+	"Secret",
+	"has_host",
+}
+
+func TestValidateLabels(t *testing.T) {
+	if err := domain.ValidateLabels([]string{domain.LabelHostMachine, domain.LabelMachine, domain.LabelCompute}); err != nil {
+		t.Fatalf("known labels rejected: %v", err)
+	}
+	if err := domain.ValidateLabels(nil); !errors.Is(err, domain.ErrInvalidLabel) {
+		t.Fatalf("expect empty label set rejected, got %v", err)
+	}
+	for _, label := range maliciousIdentifiers {
+		if err := domain.ValidateLabels([]string{domain.LabelApp, label}); !errors.Is(err, domain.ErrInvalidLabel) {
+			t.Fatalf("label %q: expect ErrInvalidLabel, got %v", label, err)
+		}
+	}
+}
+
+func TestValidateRelType(t *testing.T) {
+	for _, relType := range domain.RelTypes {
+		if err := domain.ValidateRelType(relType); err != nil {
+			t.Fatalf("known rel type %s rejected: %v", relType, err)
+		}
+	}
+	for _, relType := range append(maliciousIdentifiers, "HOSTS_VM]->(x) DETACH DELETE x //") {
+		if err := domain.ValidateRelType(relType); !errors.Is(err, domain.ErrInvalidRelType) {
+			t.Fatalf("rel type %q: expect ErrInvalidRelType, got %v", relType, err)
+		}
+	}
+}
+
+// 校验发生在访问数据库之前，nil client 足以确认非法标识符在拼接前就被拒绝。
+func TestLoaderRejectsMaliciousIdentifiers(t *testing.T) {
+	ctx := context.Background()
+	nodes := []domain.NodeRow{{CMDBKey: "APP_1", Labels: []string{"App) DETACH DELETE (n"}, RunID: "r1"}}
+	rels := []domain.RelRow{{StartKey: "APP_1", EndKey: "VM_1", Type: "DEPLOYED_ON]->() DETACH DELETE n //", RunID: "r1"}}
+
+	if _, err := loader.NewNodeUpserter(nil, 10).UpsertNodes(ctx, nodes); !errors.Is(err, domain.ErrInvalidLabel) {
+		t.Fatalf("upsert nodes: expect ErrInvalidLabel, got %v", err)
+	}
+	if _, err := loader.NewNodeUpserter(nil, 10).InitNodes(ctx, nodes); !errors.Is(err, domain.ErrInvalidLabel) {
+		t.Fatalf("init nodes: expect ErrInvalidLabel, got %v", err)
+	}
+	if _, err := loader.NewRelUpserter(nil, 10).UpsertRels(ctx, rels); !errors.Is(err, domain.ErrInvalidRelType) {
+		t.Fatalf("upsert rels: expect ErrInvalidRelType, got %v", err)
+	}
+	cleaner := loader.NewCleaner(nil)
+	if err := cleaner.DeleteNodes(ctx, nodes); !errors.Is(err, domain.ErrInvalidLabel) {
+		t.Fatalf("delete nodes: expect ErrInvalidLabel, got %v", err)
+	}
+	if err := cleaner.TombstoneNodes(ctx, nodes); !errors.Is(err, domain.ErrInvalidLabel) {
+		t.Fatalf("tombstone nodes: expect ErrInvalidLabel, got %v", err)
+	}
+	if err := cleaner.DeleteRelationships(ctx, rels); !errors.Is(err, domain.ErrInvalidRelType) {
+		t.Fatalf("delete rels: expect ErrInvalidRelType, got %v", err)
+	}
+	if err := cleaner.TombstoneRelationships(ctx, rels); !errors.Is(err, domain.ErrInvalidRelType) {
+		t.Fatalf("tombstone rels: expect ErrInvalidRelType, got %v", err)
+	}
+}
@@ -13,30 +13,18 @@ func TestLabelPattern(t *testing.T) {
 	}
 }
 
-type AppObj struct {
-	Id         int    `json:"id"`
-	FullCNName string `json:"full_cn_name"`
-}
-type DataContent struct {
-	Id               int    `json:"id"`
-	Idc              string `json:"idc"`
-	NetworkPartition string `json:"network_partition"`
-	ServerType       int    `json:"server_type"`
-	Ip               string `json:"ip"`
-	HostName         string `json:"host_name"`
-	HostIp           string `json:"host_ip"`
-	AppObj           AppObj `json:"app_obj"`
-}
-
-type ResponseData struct {
-	Page  int           `json:"page"`
-	Limit int           `json:"limit"`
-	Total int           `json:"total"`
-	Data  []DataContent `json:"data"`
-}
-
-type Request struct {
-	Code int          `json:"code"`
-	Data ResponseData `json:"data"`
-	Msg  string       `json:"msg"`
+func TestNormalizeIP(t *testing.T) {
+	cases := map[string]string{
+		"2001:DB8:0:0:0:0:0:1": "2001:db8::1",
+		"2001:db8::1":          "2001:db8::1",
+		" 10.0.0.1 ":           "10.0.0.1",
+		"::ffff:10.0.0.1":      "10.0.0.1",
+		"host-01":              "host-01",
+		"":                     "",
+	}
+	for raw, want := range cases {
+		if got := domain.NormalizeIP(raw); got != want {
+			t.Fatalf("NormalizeIP(%q) = %q, want %q", raw, got, want)
+		}
+	}
 }
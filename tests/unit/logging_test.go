@@ -0,0 +1,41 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"cmdb2neo/pkg/logging"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]zapcore.Level{"": zapcore.InfoLevel, "debug": zapcore.DebugLevel, " WARN ": zapcore.WarnLevel, "error": zapcore.ErrorLevel}
+	for raw, want := range cases {
+		got, err := logging.ParseLevel(raw)
+		if err != nil || got != want {
+			t.Fatalf("ParseLevel(%q) = %v, %v; want %v", raw, got, err, want)
+		}
+	}
+	if _, err := logging.ParseLevel("verbose"); err == nil {
+		t.Fatalf("expect unknown level rejected")
+	}
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	fallback := zap.NewExample()
+	if got := logging.FromContext(context.Background(), fallback); got != fallback {
+		t.Fatalf("expect fallback without request logger")
+	}
+	if got := logging.FromContext(context.Background(), nil); got == nil {
+		t.Fatalf("expect no-op logger instead of nil")
+	}
+	scoped := fallback.With(zap.String("request_id", "r1"))
+	ctx := logging.WithRequestID(logging.WithLogger(context.Background(), scoped), "r1")
+	if got := logging.FromContext(ctx, fallback); got != scoped {
+		t.Fatalf("expect request-scoped logger from context")
+	}
+	if logging.RequestID(ctx) != "r1" || logging.RequestID(context.Background()) != "" {
+		t.Fatalf("unexpected request id round trip")
+	}
+}
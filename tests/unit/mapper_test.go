@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
 )
 
 func TestBuildInitRows(t *testing.T) {
@@ -25,3 +26,237 @@ func TestBuildInitRows(t *testing.T) {
 		t.Fatalf("expect relationships, got 0")
 	}
 }
+
+func TestBuildInitRowsServiceLayer(t *testing.T) {
+	mapper := cmdb.NewRowMapper("run-svc")
+	nodes, rels := mapper.Map(cmdb.Snapshot{Apps: []cmdb.App{
+		{Id: 1, Name: "order-a", Service: "order"},
+		{Id: 2, Name: "order-b", Service: "order"},
+		{Id: 3, Name: "legacy"},
+	}})
+	// 下一页出现的同名服务不应重复建节点
+	more, moreRels := mapper.Map(cmdb.Snapshot{Apps: []cmdb.App{{Id: 4, Name: "order-c", Service: "order"}}})
+	nodes, rels = append(nodes, more...), append(rels, moreRels...)
+
+	var services int
+	for _, node := range nodes {
+		if node.Labels[0] == domain.LabelService {
+			services++
+			if node.CMDBKey != domain.MakeKey(domain.PrefixService, "order") || node.Properties["name"] != "order" {
+				t.Fatalf("unexpected service node: %+v", node)
+			}
+		}
+	}
+	var partOf int
+	for _, rel := range rels {
+		if rel.Type == domain.RelPartOf {
+			partOf++
+			if rel.EndKey != domain.MakeKey(domain.PrefixService, "order") {
+				t.Fatalf("unexpected PART_OF target: %+v", rel)
+			}
+		}
+	}
+	if services != 1 || partOf != 3 {
+		t.Fatalf("expect 1 service and 3 PART_OF, got %d/%d", services, partOf)
+	}
+}
+
+func TestBuildInitRowsMultipleAppsPerVM(t *testing.T) {
+	nodes, rels := cmdb.BuildInitRows(cmdb.Snapshot{
+		RunID:           "run-apps",
+		VirtualMachines: []cmdb.VirtualMachine{{Id: 300, Ip: "10.0.0.12"}},
+		Apps: []cmdb.App{
+			{Id: 400, Name: "order", Ip: "10.0.0.12", ServerType: "2"},
+			{Id: 401, Name: "payment", Ip: "10.0.0.12", ServerType: "2"},
+		},
+	})
+
+	apps := make(map[string]bool)
+	for _, node := range nodes {
+		if node.Labels[0] == domain.LabelApp {
+			apps[node.CMDBKey] = true
+		}
+	}
+	if len(apps) != 2 || !apps[domain.MakeKey(domain.PrefixApp, 400)] || !apps[domain.MakeKey(domain.PrefixApp, 401)] {
+		t.Fatalf("expect one App node per CMDB id, got %v", apps)
+	}
+	deployed := make(map[string]string)
+	for _, rel := range rels {
+		if rel.Type == domain.RelAppDeploy {
+			deployed[rel.StartKey] = rel.EndKey
+		}
+	}
+	vmKey := domain.MakeKey(domain.PrefixVirtual, 300)
+	if len(deployed) != 2 || deployed[domain.MakeKey(domain.PrefixApp, 400)] != vmKey || deployed[domain.MakeKey(domain.PrefixApp, 401)] != vmKey {
+		t.Fatalf("expect both apps DEPLOYED_ON %s, got %v", vmKey, deployed)
+	}
+}
+
+func TestBuildInitRowsAppsOnBareMetal(t *testing.T) {
+	_, rels := cmdb.BuildInitRows(cmdb.Snapshot{
+		RunID:            "run-metal",
+		HostMachines:     []cmdb.HostMachine{{Id: 100, Ip: "10.0.0.10"}},
+		PhysicalMachines: []cmdb.PhysicalMachine{{Id: 200, Ip: "10.0.0.11"}},
+		VirtualMachines:  []cmdb.VirtualMachine{{Id: 300, Ip: "10.0.0.12", HostIp: "10.0.0.10"}, {Id: 301, Ip: "10.0.0.11"}},
+		Apps: []cmdb.App{
+			{Id: 400, Name: "agent", Ip: "10.0.0.10"},
+			{Id: 401, Name: "batch", Ip: "10.0.0.11", ServerType: "3"},
+			{Id: 402, Name: "order", Ip: "10.0.0.12"},
+			{Id: 403, Name: "shadow", Ip: "10.0.0.11"},
+		},
+	})
+
+	deployed := make(map[string]string)
+	for _, rel := range rels {
+		if rel.Type == domain.RelAppDeploy {
+			deployed[rel.StartKey] = rel.EndKey
+		}
+	}
+	want := map[string]string{
+		domain.MakeKey(domain.PrefixApp, 400): domain.MakeKey(domain.PrefixHostMachine, 100),
+		domain.MakeKey(domain.PrefixApp, 401): domain.MakeKey(domain.PrefixPhysical, 200),
+		domain.MakeKey(domain.PrefixApp, 402): domain.MakeKey(domain.PrefixVirtual, 300),
+		// 未指明承载层且 IP 同时命中虚拟机与物理机时优先虚拟机
+		domain.MakeKey(domain.PrefixApp, 403): domain.MakeKey(domain.PrefixVirtual, 301),
+	}
+	for app, target := range want {
+		if deployed[app] != target {
+			t.Fatalf("expect %s DEPLOYED_ON %s, got %v", app, target, deployed)
+		}
+	}
+}
+
+func TestBuildInitRowsNormalizesIPv6(t *testing.T) {
+	nodes, rels := cmdb.BuildInitRows(cmdb.Snapshot{
+		RunID:           "run-v6",
+		HostMachines:    []cmdb.HostMachine{{Id: 100, Ip: "2001:DB8:0:0:0:0:0:A"}},
+		VirtualMachines: []cmdb.VirtualMachine{{Id: 300, Ip: "2001:db8:0:0::c", HostIp: "2001:db8::a"}},
+		Apps:            []cmdb.App{{Id: 400, Name: "order", Ip: "2001:DB8::C", ServerType: "2"}},
+	})
+
+	ips := make(map[string]any)
+	for _, node := range nodes {
+		ips[node.CMDBKey] = node.Properties["ip"]
+	}
+	for key, want := range map[string]string{
+		domain.MakeKey(domain.PrefixHostMachine, 100): "2001:db8::a",
+		domain.MakeKey(domain.PrefixVirtual, 300):     "2001:db8::c",
+		domain.MakeKey(domain.PrefixApp, 400):         "2001:db8::c",
+	} {
+		if ips[key] != want {
+			t.Fatalf("expect %s ip %s, got %v", key, want, ips[key])
+		}
+	}
+	edges := make(map[string]string)
+	for _, rel := range rels {
+		edges[rel.Type+":"+rel.StartKey] = rel.EndKey
+	}
+	if edges[domain.RelHostsVM+":"+domain.MakeKey(domain.PrefixHostMachine, 100)] != domain.MakeKey(domain.PrefixVirtual, 300) {
+		t.Fatalf("expect equivalent host_ip to bind the VM to its host, got %v", edges)
+	}
+	if edges[domain.RelAppDeploy+":"+domain.MakeKey(domain.PrefixApp, 400)] != domain.MakeKey(domain.PrefixVirtual, 300) {
+		t.Fatalf("expect equivalent app ip to bind the app to its VM, got %v", edges)
+	}
+}
+
+func TestBuildInitRowsVMsOnHostsAndPhysicals(t *testing.T) {
+	nodes, rels := cmdb.BuildInitRows(cmdb.Snapshot{
+		RunID:            "run-mixed",
+		HostMachines:     []cmdb.HostMachine{{Id: 100, Ip: "10.0.0.10"}},
+		PhysicalMachines: []cmdb.PhysicalMachine{{Id: 200, Ip: "10.0.0.20"}, {Id: 201, Ip: "10.0.0.10"}},
+		VirtualMachines: []cmdb.VirtualMachine{
+			{Id: 300, Ip: "10.0.1.1", HostIp: "10.0.0.10"},
+			{Id: 301, Ip: "10.0.1.2", HostIp: "10.0.0.20"},
+			{Id: 302, Ip: "10.0.1.3", HostIp: "10.0.0.20"},
+			{Id: 303, Ip: "10.0.1.4", HostIp: "10.0.0.99"},
+		},
+	})
+	if len(nodes) != 7 {
+		t.Fatalf("expect 7 nodes, got %d", len(nodes))
+	}
+
+	hosts := make(map[string]domain.RelRow)
+	for _, rel := range rels {
+		if rel.Type == domain.RelHostsVM {
+			hosts[rel.EndKey] = rel
+		}
+	}
+	want := map[string]struct{ start, via string }{
+		// 宿主机与物理机 IP 相同时优先宿主机
+		domain.MakeKey(domain.PrefixVirtual, 300): {domain.MakeKey(domain.PrefixHostMachine, 100), "host_ip"},
+		domain.MakeKey(domain.PrefixVirtual, 301): {domain.MakeKey(domain.PrefixPhysical, 200), "physical_ip"},
+		domain.MakeKey(domain.PrefixVirtual, 302): {domain.MakeKey(domain.PrefixPhysical, 200), "physical_ip"},
+	}
+	if len(hosts) != len(want) {
+		t.Fatalf("expect %d HOSTS_VM edges, got %+v", len(want), hosts)
+	}
+	for vmKey, w := range want {
+		rel, ok := hosts[vmKey]
+		if !ok || rel.StartKey != w.start || rel.Properties["via"] != w.via {
+			t.Fatalf("unexpected HOSTS_VM edge for %s: %+v", vmKey, rel)
+		}
+	}
+
+	// 物理机在后续分页才出现时，挂起的虚拟机同样补齐到物理机
+	mapper := cmdb.NewRowMapper("run-paged")
+	_, first := mapper.Map(cmdb.Snapshot{VirtualMachines: []cmdb.VirtualMachine{{Id: 310, HostIp: "10.0.0.30"}}})
+	_, second := mapper.Map(cmdb.Snapshot{PhysicalMachines: []cmdb.PhysicalMachine{{Id: 210, Ip: "10.0.0.30"}}})
+	if len(first) != 0 || len(second) != 1 || second[0].StartKey != domain.MakeKey(domain.PrefixPhysical, 210) || mapper.Pending() != 0 {
+		t.Fatalf("expect pending vm resolved to physical machine, got %+v / %+v", first, second)
+	}
+}
+
+func TestBuildInitRowsAttachesRelationshipProperties(t *testing.T) {
+	snapshot := cmdb.Snapshot{
+		RunID:        "run-weights",
+		HostMachines: []cmdb.HostMachine{{Id: 100, Ip: "10.0.0.10"}},
+		VirtualMachines: []cmdb.VirtualMachine{
+			{Id: 300, Ip: "10.0.1.1", HostIp: "10.0.0.10", Weight: 0.25},
+			{Id: 301, Ip: "10.0.1.2", HostIp: "10.0.0.10"},
+		},
+		Apps: []cmdb.App{
+			{Id: 400, Name: "order", Ip: "10.0.1.1", Instances: 3},
+			{Id: 401, Name: "payment", Ip: "10.0.1.2"},
+		},
+	}
+	_, rels := cmdb.BuildInitRows(snapshot)
+	props := make(map[string]map[string]any, len(rels))
+	for _, rel := range rels {
+		props[rel.EndKey+"<-"+rel.StartKey] = rel.Properties
+	}
+	vm, app := domain.MakeKey(domain.PrefixVirtual, 300), domain.MakeKey(domain.PrefixApp, 400)
+	if got := props[vm+"<-"+domain.MakeKey(domain.PrefixHostMachine, 100)]; got["weight"] != 0.25 || got["via"] != "host_ip" {
+		t.Fatalf("expect weight on HOSTS_VM, got %v", got)
+	}
+	if got := props[vm+"<-"+app]; got["instances"] != 3 || got["via"] != "vm_ip" {
+		t.Fatalf("expect instances on DEPLOYED_ON, got %v", got)
+	}
+	// 快照未提供数值时关系只带来源
+	if got := props[domain.MakeKey(domain.PrefixVirtual, 301)+"<-"+domain.MakeKey(domain.PrefixApp, 401)]; len(got) != 1 {
+		t.Fatalf("expect only via on plain relationship, got %v", got)
+	}
+
+	// 挂起后补齐的关系同样带上属性
+	mapper := cmdb.NewRowMapper("run-paged")
+	mapper.Map(cmdb.Snapshot{Apps: []cmdb.App{{Id: 402, Ip: "10.0.1.9", ServerType: "2", Instances: 2}}})
+	_, later := mapper.Map(cmdb.Snapshot{VirtualMachines: []cmdb.VirtualMachine{{Id: 309, Ip: "10.0.1.9"}}})
+	if len(later) != 1 || later[0].Properties["instances"] != 2 {
+		t.Fatalf("expect pending deployment to keep instances, got %+v", later)
+	}
+
+	// Neo4j 读回的整数为 int64，浮点为 float64，写回后不应被视为漂移
+	graphNodes, graphRels := cmdb.BuildInitRows(snapshot)
+	for i := range graphRels {
+		roundTrip := make(map[string]any, len(graphRels[i].Properties))
+		for k, v := range graphRels[i].Properties {
+			if n, ok := v.(int); ok {
+				v = int64(n)
+			}
+			roundTrip[k] = v
+		}
+		graphRels[i].Properties = roundTrip
+	}
+	if diff := cmdb.DiffGraph(graphNodes, graphRels, snapshot); len(diff.AddedRels)+len(diff.ChangedRels) != 0 {
+		t.Fatalf("expect relationship properties to round-trip, got %+v", diff)
+	}
+}
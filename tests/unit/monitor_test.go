@@ -0,0 +1,113 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/graph"
+)
+
+// fakeConnector 按顺序返回预设的连通性结果并记录重连次数。
+type fakeConnector struct {
+	mu         sync.Mutex
+	verify     []error
+	reconnect  error
+	reconnects int
+}
+
+func (c *fakeConnector) VerifyConnectivity(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.verify) == 0 {
+		return nil
+	}
+	err := c.verify[0]
+	c.verify = c.verify[1:]
+	return err
+}
+
+func (c *fakeConnector) Reconnect(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reconnects++
+	return c.reconnect
+}
+
+func TestConnectionMonitorFlipsUnhealthyAfterThreshold(t *testing.T) {
+	down := errors.New("connection refused")
+	conn := &fakeConnector{verify: []error{down, down, down, down}, reconnect: errors.New("still down")}
+	m := graph.NewConnectionMonitor("graph", conn, graph.WithFailureThreshold(2))
+	ctx := context.Background()
+
+	if err := m.Check(ctx); err == nil {
+		t.Fatalf("expect first check to fail")
+	}
+	if err := m.Err(); err != nil {
+		t.Fatalf("expect healthy below threshold, got %v", err)
+	}
+	if conn.reconnects != 0 {
+		t.Fatalf("expect no reconnect below threshold, got %d", conn.reconnects)
+	}
+	if err := m.Check(ctx); err == nil {
+		t.Fatalf("expect second check to fail")
+	}
+	if conn.reconnects != 1 {
+		t.Fatalf("expect reconnect at threshold, got %d", conn.reconnects)
+	}
+	if err := m.Err(); !errors.Is(err, down) {
+		t.Fatalf("expect unhealthy with cause, got %v", err)
+	}
+	if err := (graph.ConnectionMonitors{m}).Err(); !errors.Is(err, down) {
+		t.Fatalf("expect monitors to surface cause, got %v", err)
+	}
+}
+
+func TestConnectionMonitorRecoversOnReconnect(t *testing.T) {
+	down := errors.New("connection reset")
+	conn := &fakeConnector{verify: []error{down, down}}
+	m := graph.NewConnectionMonitor("loader", conn, graph.WithFailureThreshold(2))
+	ctx := context.Background()
+
+	_ = m.Check(ctx)
+	if err := m.Check(ctx); err != nil {
+		t.Fatalf("expect successful reconnect to count as recovery, got %v", err)
+	}
+	if conn.reconnects != 1 {
+		t.Fatalf("expect one reconnect, got %d", conn.reconnects)
+	}
+	if err := m.Err(); err != nil {
+		t.Fatalf("expect healthy after reconnect, got %v", err)
+	}
+	// 恢复后失败计数清零，单次失败不会立即判定不可用
+	conn.verify = []error{down}
+	_ = m.Check(ctx)
+	if err := m.Err(); err != nil {
+		t.Fatalf("expect failure count reset after recovery, got %v", err)
+	}
+}
+
+func TestConnectionMonitorStartStops(t *testing.T) {
+	conn := &fakeConnector{verify: []error{errors.New("down")}}
+	m := graph.NewConnectionMonitor("graph", conn, graph.WithCheckInterval(time.Millisecond), graph.WithFailureThreshold(1))
+	stop := m.Start(context.Background())
+	deadline := time.Now().Add(time.Second)
+	for {
+		conn.mu.Lock()
+		n := conn.reconnects
+		conn.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expect background check to reconnect")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	if err := m.Err(); err != nil {
+		t.Fatalf("expect healthy after reconnect, got %v", err)
+	}
+}
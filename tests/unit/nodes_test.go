@@ -0,0 +1,160 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/graph"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+type nodesReader struct {
+	queries []string
+	params  []map[string]any
+	total   int64
+}
+
+func (r *nodesReader) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	r.queries = append(r.queries, query)
+	r.params = append(r.params, params)
+	if strings.Contains(query, "count(n)") {
+		return []map[string]any{{"total": r.total}}, nil
+	}
+	return []map[string]any{
+		{"n": neo4j.Node{Labels: []string{"HostMachine", "Machine"}, Props: map[string]any{"cmdb_key": "HM_3", "idc": "M5"}}},
+	}, nil
+}
+
+func TestListNodesPagesWithParameters(t *testing.T) {
+	reader := &nodesReader{total: 3}
+	page, err := graph.ListNodes(context.Background(), reader, graph.NodeQuery{
+		Label:   "hostmachine",
+		Filters: map[string]string{"idc": "M5", "partition": "prod"},
+		Page:    2,
+		Limit:   2,
+	})
+	if err != nil {
+		t.Fatalf("list nodes: %v", err)
+	}
+	if page.Label != "HostMachine" || page.Total != 3 || page.Page != 2 || len(page.Items) != 1 || page.Items[0].CMDBKey != "HM_3" {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	if len(reader.queries) != 2 {
+		t.Fatalf("expect count and page queries, got %d", len(reader.queries))
+	}
+	query, params := reader.queries[1], reader.params[1]
+	for _, want := range []string{"MATCH (n:HostMachine)", "n.idc = $f_idc", "n.network_partion = $f_partition", "SKIP $skip LIMIT $limit"} {
+		if !strings.Contains(query, want) {
+			t.Fatalf("expect %q in query:\n%s", want, query)
+		}
+	}
+	if params["skip"] != 2 || params["limit"] != 2 || params["f_idc"] != "M5" || params["f_partition"] != "prod" {
+		t.Fatalf("unexpected params: %v", params)
+	}
+}
+
+func TestListNodesSkipsPageQueryBeyondTotal(t *testing.T) {
+	reader := &nodesReader{total: 1}
+	page, err := graph.ListNodes(context.Background(), reader, graph.NodeQuery{Label: "App", Page: 5})
+	if err != nil || page.Total != 1 || len(page.Items) != 0 || page.Limit != graph.DefaultNodePageSize {
+		t.Fatalf("unexpected page %+v, err %v", page, err)
+	}
+	if len(reader.queries) != 1 {
+		t.Fatalf("expect only the count query, got %d", len(reader.queries))
+	}
+}
+
+// streamNodesReader 实现 graph.StreamReader，分页查询只允许走流式读取。
+type streamNodesReader struct {
+	nodesReader
+	streamed int
+}
+
+func (r *streamNodesReader) RunRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	if !strings.Contains(query, "count(n)") {
+		return nil, errors.New("page query must be streamed")
+	}
+	return r.nodesReader.RunRead(ctx, query, params)
+}
+
+func (r *streamNodesReader) RunReadStream(_ context.Context, _ string, _ map[string]any, fn func(map[string]any) error) error {
+	for i := 0; i < 3; i++ {
+		r.streamed++
+		node := neo4j.Node{Labels: []string{"App"}, Props: map[string]any{"cmdb_key": "APP_" + strconv.Itoa(i+1)}}
+		if err := fn(map[string]any{"n": node}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestListNodesStreamsWhenSupported(t *testing.T) {
+	reader := &streamNodesReader{nodesReader: nodesReader{total: 3}}
+	page, err := graph.ListNodes(context.Background(), reader, graph.NodeQuery{Label: "App"})
+	if err != nil {
+		t.Fatalf("list nodes: %v", err)
+	}
+	if len(page.Items) != 3 || page.Items[2].CMDBKey != "APP_3" || reader.streamed != 3 {
+		t.Fatalf("unexpected streamed page: %+v", page)
+	}
+}
+
+func TestForEachRecordStopsOnCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	for name, reader := range map[string]graph.Reader{
+		"stream":   &streamNodesReader{},
+		"fallback": &nodesReader{total: 3},
+	} {
+		calls := 0
+		err := graph.ForEachRecord(context.Background(), reader, "MATCH (n) RETURN n", nil, func(map[string]any) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Fatalf("%s: expect stop after first record, got %d calls, err %v", name, calls, err)
+		}
+	}
+}
+
+func TestListNodesRejectsInjection(t *testing.T) {
+	labels := []string{
+		"",
+		"Label",
+		"HostMachine) DETACH DELETE n //",
+		"HostMachine`) MATCH (m",
+		"HostMachine:Secret",
+		"Machine",
+	}
+	for _, label := range labels {
+		reader := &nodesReader{}
+		_, err := graph.ListNodes(context.Background(), reader, graph.NodeQuery{Label: label})
+		if !errors.Is(err, graph.ErrUnknownLabel) {
+			t.Fatalf("label %q: expect ErrUnknownLabel, got %v", label, err)
+		}
+		if len(reader.queries) != 0 {
+			t.Fatalf("label %q: no query may run, got %v", label, reader.queries)
+		}
+	}
+
+	reader := &nodesReader{total: 1}
+	if _, err := graph.ListNodes(context.Background(), reader, graph.NodeQuery{Label: "App", Filters: map[string]string{"name = 'x' OR 1=1 //": "x"}}); err == nil || len(reader.queries) != 0 {
+		t.Fatalf("expect unknown filter property rejected before querying, got %v", err)
+	}
+	evil := "x' OR 1=1 //"
+	if _, err := graph.ListNodes(context.Background(), reader, graph.NodeQuery{Label: "App", Filters: map[string]string{"name": evil}}); err != nil {
+		t.Fatalf("list nodes: %v", err)
+	}
+	for i, query := range reader.queries {
+		if strings.Contains(query, evil) || reader.params[i]["f_name"] != evil {
+			t.Fatalf("filter value must be passed as parameter, got query:\n%s", query)
+		}
+	}
+	for _, q := range []graph.NodeQuery{{Label: "App", Page: -1}, {Label: "App", Limit: graph.MaxNodePageSize + 1}} {
+		if _, err := graph.ListNodes(context.Background(), &nodesReader{}, q); err == nil {
+			t.Fatalf("expect invalid paging rejected: %+v", q)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/loader"
+)
+
+// fakeOrphanClient 按语句返回的标签给出预设的孤儿数与修复数，并记录收到的语句。
+type fakeOrphanClient struct {
+	counts  map[string][2]int64
+	queries []string
+	err     error
+}
+
+func (c *fakeOrphanClient) RunWriteRecords(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	c.queries = append(c.queries, query)
+	if c.err != nil {
+		return nil, c.err
+	}
+	if params["run_id"] != "reconcile-1" {
+		return nil, errors.New("missing run id")
+	}
+	for label, n := range c.counts {
+		if strings.Contains(query, "RETURN '"+label+"' AS label") {
+			return []map[string]any{{"label": label, "type": "T", "orphans": n[0], "fixed": n[1]}}, nil
+		}
+	}
+	return []map[string]any{{"label": "other", "orphans": int64(0), "fixed": int64(0)}}, nil
+}
+
+func TestOrphanReconcilerReportsPerLabel(t *testing.T) {
+	client := &fakeOrphanClient{counts: map[string][2]int64{
+		domain.LabelNetPartition:   {1, 1},
+		domain.LabelHostMachine:    {4, 3},
+		domain.LabelVirtualMachine: {5, 2},
+		domain.LabelContainer:      {2, 1},
+	}}
+	report, err := loader.NewOrphanReconciler(client).Run(context.Background(), "reconcile-1")
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if len(client.queries) != 5 || len(report.Labels) != 5 {
+		t.Fatalf("expect one statement per orphan label, got %d queries / %+v", len(client.queries), report)
+	}
+	if report.Labels[0].Label != domain.LabelNetPartition || report.Labels[1].Label != domain.LabelHostMachine || report.Labels[3].Label != domain.LabelVirtualMachine || report.Labels[4].Label != domain.LabelContainer {
+		t.Fatalf("expect entries in statement order, got %+v", report.Labels)
+	}
+	if report.Fixed() != 7 || report.Remaining() != 5 || report.Labels[3].Remaining() != 3 {
+		t.Fatalf("expect 7 fixed / 5 remaining, got %d / %d", report.Fixed(), report.Remaining())
+	}
+	vm := client.queries[3]
+	if !strings.Contains(vm, "(host:HostMachine {ip: vm.host_ip})") || !strings.Contains(vm, "(phy:PhysicalMachine {ip: vm.host_ip})") || !strings.Contains(vm, "size(candidates) = 1") {
+		t.Fatalf("expect vm reattached by host_ip to a unique host or physical machine:\n%s", vm)
+	}
+	ctr := client.queries[4]
+	if !strings.Contains(ctr, "(vm:VirtualMachine {ip: ctr.host_ip})") || !strings.Contains(ctr, "(host:HostMachine {ip: ctr.host_ip})") || !strings.Contains(ctr, "MERGE (ctr)-[r:RUNS_ON]->(p)") {
+		t.Fatalf("expect container reattached by host_ip to a unique vm or host:\n%s", ctr)
+	}
+}
+
+func TestOrphanReconcilerUsesSchemaNames(t *testing.T) {
+	client := &fakeOrphanClient{}
+	reconciler := loader.NewOrphanReconciler(client)
+	reconciler.Schema = customSchema(t)
+	if _, err := reconciler.Run(context.Background(), "reconcile-1"); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	all := strings.Join(client.queries, "\n")
+	if !strings.Contains(all, "MATCH (vm:VM)") || strings.Contains(all, ":VirtualMachine") || strings.Contains(all, "{{") {
+		t.Fatalf("expect custom labels in orphan statements:\n%s", all)
+	}
+}
+
+func TestOrphanReconcilerWrapsError(t *testing.T) {
+	cause := errors.New("deadlock")
+	_, err := loader.NewOrphanReconciler(&fakeOrphanClient{err: cause}).Run(context.Background(), "reconcile-1")
+	if !errors.Is(err, cause) || !strings.Contains(err.Error(), "修复孤儿节点失败") {
+		t.Fatalf("expect wrapped error, got %v", err)
+	}
+}
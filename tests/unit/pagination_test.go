@@ -0,0 +1,135 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"cmdb2neo/internal/cmdb"
+)
+
+// roundTripFunc 让普通函数充当 http.RoundTripper，用于在不起服务的情况下模拟 CMDB。
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func jsonResponse(t *testing.T, data cmdb.ResponseData) *http.Response {
+	t.Helper()
+	body, err := json.Marshal(cmdb.Request{Data: data})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(bytes.NewReader(body))}
+}
+
+func hosts(ids ...int) []cmdb.DataContent {
+	out := make([]cmdb.DataContent, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, cmdb.DataContent{Id: id, ServerType: 1, Ip: "10.0.0." + strconv.Itoa(id)})
+	}
+	return out
+}
+
+func TestHTTPClientOffsetPagination(t *testing.T) {
+	var pages []string
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		if r.URL.Query().Get("cursor") != "" {
+			t.Errorf("offset mode must not send cursor")
+		}
+		switch page {
+		case "1":
+			return jsonResponse(t, cmdb.ResponseData{Page: 1, Limit: 2, Total: 3, Data: hosts(1, 2), NextCursor: "ignored"}), nil
+		default:
+			return jsonResponse(t, cmdb.ResponseData{Page: 2, Limit: 2, Total: 3, Data: hosts(3)}), nil
+		}
+	})
+
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{
+		BaseURL:      "http://cmdb.local",
+		IDCs:         []string{"M5"},
+		CustomClient: &http.Client{Transport: transport},
+	})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	snapshot, err := client.FetchSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if len(snapshot.HostMachines) != 3 {
+		t.Fatalf("expect 3 hosts, got %d", len(snapshot.HostMachines))
+	}
+	if len(pages) != 2 || pages[0] != "1" || pages[1] != "2" {
+		t.Fatalf("expect pages [1 2], got %v", pages)
+	}
+}
+
+func TestHTTPClientCursorPagination(t *testing.T) {
+	var cursors []string
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		cursor := r.URL.Query().Get("cursor")
+		cursors = append(cursors, cursor)
+		switch cursor {
+		case "":
+			// 游标模式下 total 不可信，不能据此提前结束
+			return jsonResponse(t, cmdb.ResponseData{Limit: 2, Total: 2, Data: hosts(1, 2), NextCursor: "c2"}), nil
+		case "c2":
+			return jsonResponse(t, cmdb.ResponseData{Data: nil, NextCursor: "c3"}), nil
+		case "c3":
+			return jsonResponse(t, cmdb.ResponseData{Data: hosts(3)}), nil
+		default:
+			t.Errorf("unexpected cursor %q", cursor)
+			return jsonResponse(t, cmdb.ResponseData{}), nil
+		}
+	})
+
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{
+		BaseURL:        "http://cmdb.local",
+		IDCs:           []string{"M5"},
+		PaginationMode: cmdb.PaginationCursor,
+		CustomClient:   &http.Client{Transport: transport},
+	})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	snapshot, err := client.FetchSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if len(snapshot.HostMachines) != 3 {
+		t.Fatalf("expect 3 hosts, got %d", len(snapshot.HostMachines))
+	}
+	if len(cursors) != 3 || cursors[1] != "c2" || cursors[2] != "c3" {
+		t.Fatalf("expect cursors [\"\" c2 c3], got %q", cursors)
+	}
+}
+
+func TestHTTPClientCursorLoopIsRejected(t *testing.T) {
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return jsonResponse(t, cmdb.ResponseData{Data: hosts(1), NextCursor: "same"}), nil
+	})
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{
+		BaseURL:        "http://cmdb.local",
+		IDCs:           []string{"M5"},
+		PaginationMode: cmdb.PaginationCursor,
+		CustomClient:   &http.Client{Transport: transport},
+	})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := client.FetchSnapshot(context.Background()); err == nil {
+		t.Fatalf("expect repeated cursor to abort")
+	}
+}
+
+func TestHTTPClientRejectsUnknownPagination(t *testing.T) {
+	if _, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: "http://cmdb.local", IDCs: []string{"M5"}, PaginationMode: "token"}); err == nil {
+		t.Fatalf("expect unknown pagination mode to be rejected")
+	}
+}
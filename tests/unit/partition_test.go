@@ -0,0 +1,132 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cmdb2neo/internal/cmdb"
+)
+
+func TestNormalizeCIDR(t *testing.T) {
+	cases := map[string]string{
+		"10.0.0.0/24":    "10.0.0.0/24",
+		" 10.0.0.17/24 ": "10.0.0.0/24",
+		"2001:db8::1/64": "2001:db8::/64",
+		"":               "",
+	}
+	for raw, want := range cases {
+		got, err := cmdb.NormalizeCIDR(raw)
+		if err != nil || got != want {
+			t.Fatalf("NormalizeCIDR(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"10.0.0.0", "10.0.0.0/33", "not-a-cidr"} {
+		if _, err := cmdb.NormalizeCIDR(raw); err == nil {
+			t.Fatalf("expect error for %q", raw)
+		}
+	}
+}
+
+func TestHTTPClientPopulatesPartitionCIDR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/partitions" {
+			if r.URL.Query().Get("idc") != "M5" {
+				t.Errorf("expect idc query, got %q", r.URL.RawQuery)
+			}
+			_ = json.NewEncoder(w).Encode(cmdb.PartitionRequest{Data: []cmdb.PartitionContent{
+				{Name: "prod", CIDR: "10.0.0.0/24"},
+				{Name: "dmz", CIDR: "10.1.0.0/33"},
+				{Name: "inline", CIDR: "10.9.0.0/16"},
+			}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(cmdb.Request{Data: cmdb.ResponseData{
+			Page:  1,
+			Limit: 20,
+			Total: 4,
+			Data: []cmdb.DataContent{
+				{Id: 1, NetworkPartition: "prod", ServerType: 1, Ip: "10.0.0.1"},
+				{Id: 2, NetworkPartition: "dmz", ServerType: 1, Ip: "10.1.0.1"},
+				{Id: 3, NetworkPartition: "inline", ServerType: 1, Ip: "10.2.0.1", NetworkPartitionCIDR: "10.2.0.9/16"},
+				{Id: 4, NetworkPartition: "broken", ServerType: 1, Ip: "10.3.0.1", NetworkPartitionCIDR: "bogus"},
+			},
+		}})
+	}))
+	defer srv.Close()
+
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: []string{"M5"}, PartitionAPI: "/api/v1/partitions"})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	snapshot, err := client.FetchSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	got := make(map[string]string)
+	for _, np := range snapshot.NetworkPartitions {
+		got[np.Name] = np.CIDR
+	}
+	want := map[string]string{"prod": "10.0.0.0/24", "dmz": "", "inline": "10.2.0.0/16", "broken": ""}
+	for name, cidr := range want {
+		if got[name] != cidr {
+			t.Fatalf("partition %s: expect cidr %q, got %q (all %v)", name, cidr, got[name], got)
+		}
+	}
+}
+
+func TestHTTPClientIgnoresPartitionAPIFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/partitions" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(cmdb.Request{Data: cmdb.ResponseData{
+			Page:  1,
+			Limit: 20,
+			Total: 1,
+			Data:  []cmdb.DataContent{{Id: 1, NetworkPartition: "prod", ServerType: 1, Ip: "10.0.0.1"}},
+		}})
+	}))
+	defer srv.Close()
+
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: []string{"M5"}, PartitionAPI: "/api/v1/partitions"})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	snapshot, err := client.FetchSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("partition api failure should not fail the sync: %v", err)
+	}
+	if len(snapshot.NetworkPartitions) != 1 || snapshot.NetworkPartitions[0].CIDR != "" {
+		t.Fatalf("expect partition without cidr, got %+v", snapshot.NetworkPartitions)
+	}
+}
+
+func TestMatchPartitionPrefersLongestPrefix(t *testing.T) {
+	partitions := []cmdb.NetworkPartition{
+		{Id: 1, Name: "wide", CIDR: "10.0.0.0/16"},
+		{Id: 2, Name: "narrow", CIDR: "10.0.1.0/24"},
+		{Id: 3, Name: "blank", CIDR: ""},
+		{Id: 4, Name: "v6", CIDR: "2001:db8::/32"},
+	}
+	cases := map[string]string{
+		"10.0.1.5":        "narrow",
+		"10.0.2.5":        "wide",
+		"::ffff:10.0.1.5": "narrow",
+		"2001:db8::1":     "v6",
+	}
+	for ip, want := range cases {
+		np, ok := cmdb.MatchPartition(partitions, ip)
+		if !ok || np.Name != want {
+			t.Fatalf("MatchPartition(%q) = %+v, %v; want %s", ip, np, ok, want)
+		}
+	}
+	for _, ip := range []string{"192.168.0.1", "", "bogus"} {
+		if np, ok := cmdb.MatchPartition(partitions, ip); ok {
+			t.Fatalf("expect no match for %q, got %+v", ip, np)
+		}
+	}
+}
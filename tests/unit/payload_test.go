@@ -0,0 +1,85 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+)
+
+// contractDriftServer 模拟上游新增 server_type 5 并漏填字段的 CMDB 接口。
+func contractDriftServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(cmdb.Request{Data: cmdb.ResponseData{
+			Page:  1,
+			Limit: 20,
+			Total: 4,
+			Data: []cmdb.DataContent{
+				{Id: 1, ServerType: 1, Ip: "10.0.0.1"},
+				{Id: 2, ServerType: 5, Ip: "10.0.0.2", AppObj: []cmdb.AppObject{{ID: 400, Name: "order"}}},
+				{Id: 3, ServerType: 5, Ip: "10.0.0.3"},
+				{ServerType: 2},
+			},
+		}})
+	}))
+}
+
+func TestHTTPClientCountsUnknownServerTypes(t *testing.T) {
+	srv := contractDriftServer()
+	defer srv.Close()
+
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: []string{"M5"}})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	snapshot, err := client.FetchSnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("expect drift below threshold to only warn, got %v", err)
+	}
+	stats := snapshot.Payload
+	if stats.Records != 4 || stats.Invalid != 3 || stats.UnknownServerTypes[5] != 2 || stats.MissingID != 1 || stats.MissingIP != 1 {
+		t.Fatalf("unexpected payload stats %+v", stats)
+	}
+	if len(snapshot.HostMachines) != 1 || len(snapshot.PhysicalMachines) != 0 {
+		t.Fatalf("expect unknown server types not mapped to machines, got %+v", snapshot)
+	}
+	// 未知类型机器上的应用仍保留，与之前的行为一致
+	if len(snapshot.Apps) != 1 {
+		t.Fatalf("expect apps on unknown machines kept, got %+v", snapshot.Apps)
+	}
+}
+
+func TestHTTPClientFailsOverInvalidRatio(t *testing.T) {
+	srv := contractDriftServer()
+	defer srv.Close()
+
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: []string{"M5"}, MaxInvalidRatio: 0.5})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := client.FetchSnapshot(context.Background()); !errors.Is(err, domain.ErrValidation) {
+		t.Fatalf("expect 3/4 invalid records over 50%% to fail, got %v", err)
+	}
+
+	var pages int
+	_, err = client.StreamSnapshot(context.Background(), func(cmdb.Snapshot) error {
+		pages++
+		return nil
+	})
+	if !errors.Is(err, domain.ErrValidation) || pages == 0 {
+		t.Fatalf("expect streaming to fail after delivering pages, got %v after %d pages", err, pages)
+	}
+
+	lenient, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: []string{"M5"}, MaxInvalidRatio: 0.8})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := lenient.FetchSnapshot(context.Background()); err != nil {
+		t.Fatalf("expect 75%% under 80%% to pass, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/graph"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+const cachedQuery = "MATCH (n:HostMachine {ip: $ip}) RETURN n"
+
+func cachedRecords() []map[string]any {
+	return []map[string]any{{
+		"n":    neo4j.Node{Labels: []string{"HostMachine"}, Props: map[string]any{"cmdb_key": "HM_1", "ip": "10.0.0.1"}},
+		"tags": []any{"a", "b"},
+	}}
+}
+
+func TestQueryCacheDisabledByDefault(t *testing.T) {
+	cache := graph.NewQueryCache(0, 10)
+	cache.Put(cachedQuery, nil, cachedRecords())
+	if _, ok := cache.Get(cachedQuery, nil); ok {
+		t.Fatalf("zero TTL must disable caching")
+	}
+	if cache.Stats() != (graph.QueryCacheStats{}) {
+		t.Fatalf("disabled cache must report zero stats, got %+v", cache.Stats())
+	}
+}
+
+func TestQueryCacheHitsIdenticalQueries(t *testing.T) {
+	cache := graph.NewQueryCache(time.Minute, 10)
+	cache.Put(cachedQuery, map[string]any{"ip": "10.0.0.1", "limit": 5}, cachedRecords())
+
+	got, ok := cache.Get(cachedQuery, map[string]any{"limit": 5, "ip": "10.0.0.1"})
+	if !ok || len(got) != 1 {
+		t.Fatalf("expect identical query and params to hit, got %v %v", got, ok)
+	}
+	if _, ok := cache.Get(cachedQuery, map[string]any{"ip": "10.0.0.2", "limit": 5}); ok {
+		t.Fatalf("different params must miss")
+	}
+	if _, ok := cache.Get(cachedQuery+" LIMIT 1", map[string]any{"ip": "10.0.0.1", "limit": 5}); ok {
+		t.Fatalf("different query text must miss")
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 2 || stats.Size != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestQueryCacheReturnsClones(t *testing.T) {
+	cache := graph.NewQueryCache(time.Minute, 10)
+	original := cachedRecords()
+	cache.Put(cachedQuery, nil, original)
+	original[0]["n"].(neo4j.Node).Props["ip"] = "mutated-before-get"
+
+	first, _ := cache.Get(cachedQuery, nil)
+	node := first[0]["n"].(neo4j.Node)
+	if node.Props["ip"] != "10.0.0.1" {
+		t.Fatalf("mutating the stored slice must not change the cache, got %v", node.Props["ip"])
+	}
+	node.Props["ip"] = "mutated"
+	node.Labels[0] = "Mutated"
+	first[0]["tags"].([]any)[0] = "mutated"
+	first[0]["extra"] = true
+
+	second, _ := cache.Get(cachedQuery, nil)
+	again := second[0]["n"].(neo4j.Node)
+	if again.Props["ip"] != "10.0.0.1" || again.Labels[0] != "HostMachine" || second[0]["tags"].([]any)[0] != "a" || second[0]["extra"] != nil {
+		t.Fatalf("cached records leaked caller mutations: %+v", second[0])
+	}
+}
+
+func TestQueryCacheExpiresAndEvicts(t *testing.T) {
+	cache := graph.NewQueryCache(20*time.Millisecond, 1)
+	cache.Put(cachedQuery, map[string]any{"ip": "1"}, cachedRecords())
+	cache.Put(cachedQuery, map[string]any{"ip": "2"}, cachedRecords())
+	if _, ok := cache.Get(cachedQuery, map[string]any{"ip": "1"}); ok {
+		t.Fatalf("expect size 1 cache to evict the older entry")
+	}
+	if _, ok := cache.Get(cachedQuery, map[string]any{"ip": "2"}); !ok {
+		t.Fatalf("expect newest entry cached")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := cache.Get(cachedQuery, map[string]any{"ip": "2"}); ok {
+		t.Fatalf("expect entry to expire after TTL")
+	}
+
+	cache.Put(cachedQuery, nil, cachedRecords())
+	cache.Purge()
+	if _, ok := cache.Get(cachedQuery, nil); ok || cache.Stats().Size != 0 {
+		t.Fatalf("expect purge to drop all entries")
+	}
+}
@@ -0,0 +1,52 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/graph"
+)
+
+func TestQueryTimeoutDefaults(t *testing.T) {
+	if got := graph.QueryTimeout(0); got != graph.DefaultQueryTimeout {
+		t.Fatalf("expect default timeout, got %s", got)
+	}
+	if got := graph.QueryTimeout(5); got != 5*time.Second {
+		t.Fatalf("expect 5s, got %s", got)
+	}
+}
+
+func TestTimeoutErrorWrapsOnlyDeadline(t *testing.T) {
+	queryErr := errors.New("driver: context deadline exceeded")
+
+	ctx, cancel := graph.WithQueryTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	err := graph.TimeoutError(ctx, time.Millisecond, queryErr)
+	if !errors.Is(err, graph.ErrQueryTimeout) || !errors.Is(err, queryErr) {
+		t.Fatalf("expect timeout to wrap both sentinel and cause, got %v", err)
+	}
+
+	// 调用方主动取消不算查询超时
+	canceled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	if err := graph.TimeoutError(canceled, time.Second, context.Canceled); errors.Is(err, graph.ErrQueryTimeout) {
+		t.Fatalf("expect cancellation not reported as timeout, got %v", err)
+	}
+	if err := graph.TimeoutError(context.Background(), time.Second, queryErr); err != queryErr {
+		t.Fatalf("expect other errors untouched, got %v", err)
+	}
+}
+
+func TestWithQueryTimeoutKeepsEarlierCallerDeadline(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ctx, cancelQuery := graph.WithQueryTimeout(parent, time.Hour)
+	defer cancelQuery()
+	want, _ := parent.Deadline()
+	if got, ok := ctx.Deadline(); !ok || !got.Equal(want) {
+		t.Fatalf("expect caller deadline %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,114 @@
+package rca_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// alarmReader 模拟图中存储的 :Alarm 节点，并记录查询参数；records 为空时返回默认的两条告警。
+type alarmReader struct {
+	since   any
+	params  map[string]any
+	query   string
+	records []map[string]any
+}
+
+func (r *alarmReader) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	if !strings.Contains(query, "MATCH (a:Alarm)") {
+		return nil, nil
+	}
+	r.since = params["since"]
+	r.params = params
+	r.query = query
+	if r.records != nil {
+		return r.records, nil
+	}
+	occurred := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	return []map[string]any{
+		{"alarm": neo4j.Node{Labels: []string{"Alarm"}, Props: map[string]any{
+			"app_name": "order", "datacenter": "M5", "ip": "10.0.0.1", "server_type": "2",
+			"rule_name": "down", "occurred_at": occurred,
+		}}},
+		{"alarm": neo4j.Node{Labels: []string{"Alarm"}, Props: map[string]any{
+			"ip": "10.0.1.10", "server_type": int64(1), "rule_name": "heartbeat",
+			"occurred_at": occurred.Add(time.Minute).Format(time.RFC3339), "healthy": true,
+		}}},
+	}, nil
+}
+
+func TestGraphProviderRecentAlarms(t *testing.T) {
+	reader := &alarmReader{}
+	provider := rca.NewGraphProvider(reader)
+	since := time.Date(2024, 5, 1, 17, 55, 0, 0, time.FixedZone("CST", 8*3600))
+
+	events, err := provider.RecentAlarms(context.Background(), since)
+	if err != nil {
+		t.Fatalf("recent alarms: %v", err)
+	}
+	if got, ok := reader.since.(time.Time); !ok || !got.Equal(since) || got.Location() != time.UTC {
+		t.Fatalf("expect since parameter %v, got %v", since, reader.since)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expect 2 events, got %d", len(events))
+	}
+	// 查询中不调用可能因单条数据报错的 datetime()，各类型只与对应口径的 since 比较
+	if strings.Contains(reader.query, "datetime(") {
+		t.Fatalf("expect recent alarms query not to convert values:\n%s", reader.query)
+	}
+	for _, want := range []string{"$since_local", "$since_millis", "$since_day"} {
+		if !strings.Contains(reader.query, want) || reader.params[strings.TrimPrefix(want, "$")] == nil {
+			t.Fatalf("expect recent alarms query to bind %q:\n%s", want, reader.query)
+		}
+	}
+	first := events[0]
+	if first.AppName != "order" || first.ServerType != rca.ServerTypeVM || first.OccurredAt.IsZero() {
+		t.Fatalf("unexpected first event %+v", first)
+	}
+	second := events[1]
+	if second.ServerType != rca.ServerTypeHost || !second.Healthy || !second.OccurredAt.After(first.OccurredAt) {
+		t.Fatalf("unexpected second event %+v", second)
+	}
+}
+
+func alarmNode(rule string, occurred any) map[string]any {
+	return map[string]any{"alarm": neo4j.Node{Labels: []string{"Alarm"}, Props: map[string]any{"rule_name": rule, "occurred_at": occurred}}}
+}
+
+func TestGraphProviderRecentAlarmsParsesStoredTimeFormats(t *testing.T) {
+	since := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	reader := &alarmReader{records: []map[string]any{
+		alarmNode("zoneless", "2024-05-01T10:30:00"),
+		alarmNode("epoch", since.Add(20*time.Minute).UnixMilli()),
+		alarmNode("epoch-string", "1714558200000"),
+		alarmNode("local", neo4j.LocalDateTime(time.Date(2024, 5, 1, 10, 40, 0, 0, time.Local))),
+		alarmNode("offset", "2024-05-01T18:50:00+08:00"),
+		alarmNode("old-zoneless", "2024-05-01T09:59:59"),
+		alarmNode("malformed", "2024-13-45T99:00:00"),
+	}}
+
+	events, err := rca.NewGraphProvider(reader).RecentAlarms(context.Background(), since)
+	if err != nil {
+		t.Fatalf("recent alarms: %v", err)
+	}
+	want := map[string]time.Time{
+		"epoch-string": since.Add(10 * time.Minute),
+		"epoch":        since.Add(20 * time.Minute),
+		"zoneless":     since.Add(30 * time.Minute),
+		"local":        since.Add(40 * time.Minute),
+		"offset":       since.Add(50 * time.Minute),
+	}
+	order := []string{"epoch-string", "epoch", "zoneless", "local", "offset"}
+	if len(events) != len(order) {
+		t.Fatalf("expect %v, got %+v", order, events)
+	}
+	for i, evt := range events {
+		if evt.RuleName != order[i] || !evt.OccurredAt.Equal(want[evt.RuleName]) {
+			t.Fatalf("expect %s at %v in position %d, got %s at %v", order[i], want[order[i]], i, evt.RuleName, evt.OccurredAt)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package rca_test
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -13,87 +12,44 @@ import (
 	"cmdb2neo/internal/rca"
 )
 
-type mockProvider struct {
-	contexts map[string]rca.AlarmContext
-}
-
-type mockStore struct {
-	saved  bool
-	window string
-	result rca.Result
-}
-
-func (m *mockProvider) ResolveContext(_ context.Context, event rca.AlarmEvent) (rca.AlarmContext, error) {
-	ctx, ok := m.contexts[event.ID]
-	if !ok {
-		return rca.AlarmContext{}, fmt.Errorf("unknown event %s", event.ID)
-	}
-	return ctx, nil
-}
-
-func (m *mockStore) Save(_ context.Context, windowID string, result rca.Result) error {
-	m.saved = true
-	m.window = windowID
-	m.result = result
-	return nil
-}
-
 func TestAnalyzerBasic(t *testing.T) {
 	events := loadAlarmEvents(t)
 
-	vm1 := newNode("VM_100", rca.NodeTypeVirtualMachine, "vm-100", map[rca.NodeType]int{rca.NodeTypeApp: 2})
-	host1 := newNode("HM_10", rca.NodeTypeHostMachine, "host-10", map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 2})
-	np1 := newNode("NP_1", rca.NodeTypeNetPartition, "np-1", map[rca.NodeType]int{rca.NodeTypeHostMachine: 1})
-	idc := newNode("IDC_1", rca.NodeTypeIDC, "idc-1", map[rca.NodeType]int{rca.NodeTypeNetPartition: 1})
-
-	contexts := map[string]rca.AlarmContext{
-		"evt-app-1": {
-			App:            newNode("APP_1", rca.NodeTypeApp, "order-service", nil),
-			VirtualMachine: vm1,
-			HostMachine:    host1,
-			NetPartition:   np1,
-			IDC:            idc,
-		},
-		"evt-app-2": {
-			App:            newNode("APP_2", rca.NodeTypeApp, "payment-service", nil),
-			VirtualMachine: vm1,
-			HostMachine:    host1,
-			NetPartition:   np1,
-			IDC:            idc,
-		},
-	}
-
-	provider := &mockProvider{contexts: contexts}
-	store := &mockStore{}
+	vm := topoNode("VM_100", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 2})
+	host := topoNode("HM_10", rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 2})
+	provider := &chainProvider{chains: map[string][]rca.Node{
+		"172.16.20.101": {topoNode("APP_1", rca.NodeTypeApp, nil), vm, host},
+		"172.16.20.102": {topoNode("APP_2", rca.NodeTypeApp, nil), vm, host},
+	}}
+	store := &recordingStore{}
 
 	cfg := rca.DefaultConfig()
-	cfg.Hierarchy = []rca.NodeType{rca.NodeTypeVirtualMachine, rca.NodeTypeHostMachine}
-	vmConfig := cfg.Layers[rca.NodeTypeVirtualMachine]
-	vmConfig.CoverageThreshold = 0.5
-	vmConfig.MinChildren = 1
-	cfg.Layers[rca.NodeTypeVirtualMachine] = vmConfig
-
-	hostConfig := cfg.Layers[rca.NodeTypeHostMachine]
-	hostConfig.CoverageThreshold = 0.5
-	hostConfig.MinChildren = 1
-	cfg.Layers[rca.NodeTypeHostMachine] = hostConfig
-
-	analyzer, err := rca.NewAnalyzer(provider, store, cfg)
+	cfg.Hierarchy = []rca.NodeType{rca.NodeTypeApp, rca.NodeTypeVirtualMachine, rca.NodeTypeHostMachine}
+	// 覆盖率需严格大于阈值，宿主机只有一半 VM 告警，阈值放到 0.4 以进入候选
+	for typ, threshold := range map[rca.NodeType]float64{rca.NodeTypeVirtualMachine: 0.5, rca.NodeTypeHostMachine: 0.4} {
+		layer := cfg.Layers[typ]
+		layer.CoverageThreshold = threshold
+		layer.MinChildren = 1
+		cfg.Layers[typ] = layer
+	}
+
+	analyzer, err := rca.NewAnalyzer(provider, cfg, rca.WithResultStore(store))
 	if err != nil {
 		t.Fatalf("new analyzer: %v", err)
 	}
 
-	result, err := analyzer.Analyze(context.Background(), "window-001", events)
+	result, err := analyzer.AnalyzeWithOptions(context.Background(), events, rca.AnalyzeOptions{WindowID: "window-001"})
 	if err != nil {
 		t.Fatalf("analyze failed: %v", err)
 	}
 
-	if !store.saved || store.window != "window-001" {
-		t.Fatalf("expected store to persist result, got saved=%v window=%s", store.saved, store.window)
+	if store.saves != 1 || store.windows[0] != "window-001" {
+		t.Fatalf("expected store to persist result, got saves=%d windows=%v", store.saves, store.windows)
 	}
 
-	if len(result.Candidates) != 2 {
-		t.Fatalf("expected 2 candidates, got %d", len(result.Candidates))
+	// 后序遍历中告警应用自身覆盖率为 1，与 VM、宿主机一起进入候选
+	if len(result.Candidates) != 4 {
+		t.Fatalf("expected 4 candidates, got %+v", result.Candidates)
 	}
 
 	vmCandidate := findCandidate(t, result.Candidates, rca.NodeTypeVirtualMachine)
@@ -106,26 +62,21 @@ func TestAnalyzerBasic(t *testing.T) {
 		t.Fatalf("host coverage expect 0.5, got %.3f", hostCandidate.Coverage)
 	}
 
-	if len(result.Paths) != 2 {
-		t.Fatalf("expected 2 paths, got %d", len(result.Paths))
+	if len(result.Paths) != len(result.Candidates) {
+		t.Fatalf("expected one path per candidate, got %d", len(result.Paths))
 	}
-
-	if len(result.UnexplainedEvents) != 0 {
-		t.Fatalf("expected no unexplained events, got %d", len(result.UnexplainedEvents))
+	for _, path := range result.Paths {
+		if path.Candidate.Key == "HM_10" && (len(path.Impacts) != 1 || path.Impacts[0].Node.Key != "VM_100") {
+			t.Fatalf("expected host path to nest the alarmed VM, got %+v", path.Impacts)
+		}
 	}
-}
 
-func newNode(key string, typ rca.NodeType, name string, childCounts map[rca.NodeType]int) *rca.Node {
-	return &rca.Node{
-		NodeRef: rca.NodeRef{
-			CMDBKey: key,
-			Type:    typ,
-			Name:    name,
-		},
-		ChildCounts: childCounts,
+	if len(result.UnexplainedEvents) != 0 {
+		t.Fatalf("expected no unexplained events, got %+v", result.UnexplainedEvents)
 	}
 }
 
+// loadAlarmEvents 读取 tests/unit/alerm_events.json，告警均为 VM 上的应用告警。
 func loadAlarmEvents(t *testing.T) []rca.AlarmEvent {
 	t.Helper()
 
@@ -133,9 +84,7 @@ func loadAlarmEvents(t *testing.T) []rca.AlarmEvent {
 	if !ok {
 		t.Fatalf("runtime caller failed for %s", "alerm_events.json")
 	}
-	baseDir := filepath.Dir(file)
-	testsDir := filepath.Dir(baseDir)
-	path := filepath.Join(testsDir, "alerm_events.json")
+	path := filepath.Join(filepath.Dir(filepath.Dir(file)), "alerm_events.json")
 
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -145,7 +94,6 @@ func loadAlarmEvents(t *testing.T) []rca.AlarmEvent {
 		ID         string `json:"id"`
 		Source     string `json:"source"`
 		Priority   string `json:"priority"`
-		NodeType   string `json:"node_type"`
 		IP         string `json:"ip"`
 		HostIP     string `json:"host_ip"`
 		Service    string `json:"service"`
@@ -161,14 +109,12 @@ func loadAlarmEvents(t *testing.T) []rca.AlarmEvent {
 			t.Fatalf("parse time %s: %v", item.OccurredAt, err)
 		}
 		events = append(events, rca.AlarmEvent{
-			ID:       item.ID,
-			Source:   item.Source,
-			Priority: item.Priority,
-			NodeType: rca.NodeType(item.NodeType),
-			IP:       item.IP,
-			HostIP:   item.HostIP,
-			Service:  item.Service,
-			Occurred: ts,
+			AppName:    item.Service,
+			IP:         item.IP,
+			HostIP:     item.HostIP,
+			ServerType: rca.ServerTypeVM,
+			RuleName:   item.Source + "-" + item.Priority,
+			OccurredAt: ts,
 		})
 	}
 	return events
@@ -0,0 +1,67 @@
+package rca_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestCoverageUnknownBaseline(t *testing.T) {
+	leaf := rca.NewTopoNode(topoNode("APP_1", rca.NodeTypeApp, nil))
+	leaf.AddEvent("e1", rca.AlarmEventRef{ID: "e1", Occurred: time.Now()})
+	if leaf.HasBaseline() || leaf.Coverage() != 0 {
+		t.Fatalf("expect leaf baseline unknown with coverage 0, got %v", leaf.Coverage())
+	}
+
+	// 有告警子节点但 ChildCounts 为 0
+	vm := rca.NewTopoNode(topoNode("VM_1", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 0}))
+	vm.AttachChild(leaf)
+	vm.AddImpact(leaf, rca.AlarmEventRef{ID: "e1", NodeType: rca.NodeTypeApp})
+	if vm.HasBaseline() {
+		t.Fatalf("expect zero child count to be unknown baseline")
+	}
+	score := vm.ComputeScore(rca.ScoreWeights{Coverage: 0.7, Impact: 0.3}, 1)
+	for name, v := range map[string]float64{"coverage": vm.Coverage(), "raw": score.RawScore, "normalized": score.Normalized} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			t.Fatalf("expect finite %s, got %v", name, v)
+		}
+	}
+}
+
+func TestAnalyzerExcludesZeroBaselineNodes(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	provider := &chainProvider{chains: map[string][]rca.Node{
+		"10.0.0.1": {
+			topoNode("APP_1", rca.NodeTypeApp, nil),
+			topoNode("VM_1", rca.NodeTypeVirtualMachine, nil),
+			topoNode("HM_1", rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 1}),
+		},
+	}}
+	cfg := rca.DefaultConfig()
+	cfg.Hierarchy = []rca.NodeType{rca.NodeTypeApp, rca.NodeTypeVirtualMachine, rca.NodeTypeHostMachine}
+
+	result := analyzeWith(t, provider, cfg, []rca.AlarmEvent{{AppName: "a", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: start}})
+
+	byKey := make(map[string]rca.Candidate, len(result.Candidates))
+	for _, cand := range result.Candidates {
+		if math.IsNaN(cand.Confidence) || math.IsInf(cand.Confidence, 0) {
+			t.Fatalf("expect finite confidence, got %+v", cand)
+		}
+		byKey[cand.Node.Key] = cand
+	}
+	if _, ok := byKey["VM_1"]; ok {
+		t.Fatalf("expect VM without baseline excluded, got %+v", result.Candidates)
+	}
+	// 单个子节点的基线为 1，唯一的 VM 告警即完全覆盖
+	host, ok := byKey["HM_1"]
+	if !ok || host.Coverage != 1 {
+		t.Fatalf("expect single-child host fully covered, got %+v", result.Candidates)
+	}
+	app, ok := byKey["APP_1"]
+	if !ok || app.Coverage != 0 || !strings.Contains(app.Reason, "UNKNOWN_BASELINE") || app.Confidence >= host.Confidence {
+		t.Fatalf("expect leaf app flagged as low-confidence candidate, got %+v", result.Candidates)
+	}
+}
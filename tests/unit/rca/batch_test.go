@@ -0,0 +1,131 @@
+package rca_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/rca"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// batchReader 只应答批量解析查询：VM 层仅命中 10.0.0.1，应用层按名称全部命中，并记录每次查询。
+type batchReader struct {
+	queries []string
+}
+
+func (r *batchReader) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	r.queries = append(r.queries, query)
+	events, _ := params["events"].([]map[string]any)
+	var records []map[string]any
+	for _, event := range events {
+		switch {
+		case strings.Contains(query, "WITH event\nMATCH (vm:VirtualMachine)") && event["ip"] == "10.0.0.1":
+			records = append(records, map[string]any{
+				"event":    event,
+				"vm":       neo4j.Node{Labels: []string{"VirtualMachine"}, Props: map[string]any{"cmdb_key": "VM_1", "ip": "10.0.0.1"}},
+				"host":     neo4j.Node{Labels: []string{"HostMachine"}, Props: map[string]any{"cmdb_key": "HM_1"}},
+				"physical": neo4j.Node{Labels: []string{"PhysicalMachine"}, Props: map[string]any{"cmdb_key": "PM_1"}},
+			})
+		case strings.Contains(query, "WITH event\nMATCH (app:App)"):
+			name, _ := event["name"].(string)
+			records = append(records, map[string]any{
+				"event": event,
+				"app":   neo4j.Node{Labels: []string{"App"}, Props: map[string]any{"cmdb_key": "APP_" + name, "name": name}},
+			})
+		}
+	}
+	return records, nil
+}
+
+func TestGraphProviderResolvesEventsInBatches(t *testing.T) {
+	reader := &batchReader{}
+	provider := rca.NewGraphProvider(reader)
+	events := []rca.AlarmEvent{
+		{AppName: "a", IP: "10.0.0.1", ServerType: rca.ServerTypeVM},
+		{AppName: "b", IP: "10.0.0.2", ServerType: rca.ServerTypeVM},
+		{AppName: "c"},
+	}
+
+	chains, err := provider.ResolveEvents(context.Background(), events)
+	if err != nil {
+		t.Fatalf("resolve events: %v", err)
+	}
+	if len(reader.queries) != 2 {
+		t.Fatalf("expect one query per layer, got %d", len(reader.queries))
+	}
+	for _, query := range reader.queries {
+		if !strings.HasPrefix(query, "UNWIND $events AS event") {
+			t.Fatalf("expect batched query, got:\n%s", query)
+		}
+	}
+	if len(chains) != len(events) {
+		t.Fatalf("expect a chain per event, got %d", len(chains))
+	}
+	for _, node := range chains[0] {
+		if node.Type == rca.NodeTypePhysicalMachine {
+			t.Fatalf("expect physical machine dropped when host present, got %+v", chains[0])
+		}
+	}
+	if len(chains[0]) != 2 || chains[0][0].Key != "VM_1" {
+		t.Fatalf("unexpected VM chain %+v", chains[0])
+	}
+	if chains[1][0].Key != "APP_b" || chains[2][0].Key != "APP_c" {
+		t.Fatalf("expect VM miss to fall back to app lookup, got %+v / %+v", chains[1], chains[2])
+	}
+}
+
+func TestGraphProviderBatchReportsMissingHost(t *testing.T) {
+	provider := rca.NewGraphProvider(&batchReader{})
+	_, err := provider.ResolveEvents(context.Background(), []rca.AlarmEvent{{IP: "10.0.0.9", ServerType: rca.ServerTypeHost}})
+	if err == nil || !strings.Contains(err.Error(), "host 10.0.0.9 not found") {
+		t.Fatalf("expect missing host error, got %v", err)
+	}
+}
+
+// batchOnlyProvider 同时实现逐条与批量接口，逐条调用即视为未走批量路径。
+type batchOnlyProvider struct {
+	chainProvider
+	single  int
+	batches int
+}
+
+func (p *batchOnlyProvider) ResolveEvent(ctx context.Context, event rca.AlarmEvent) ([]rca.Node, error) {
+	p.single++
+	return p.chainProvider.ResolveEvent(ctx, event)
+}
+
+func (p *batchOnlyProvider) ResolveEvents(ctx context.Context, events []rca.AlarmEvent) ([][]rca.Node, error) {
+	p.batches++
+	out := make([][]rca.Node, 0, len(events))
+	for _, evt := range events {
+		chain, err := p.chainProvider.ResolveEvent(ctx, evt)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, chain)
+	}
+	return out, nil
+}
+
+func TestAnalyzerPrefersBatchResolution(t *testing.T) {
+	app := topoNode("APP_1", rca.NodeTypeApp, nil)
+	vm := topoNode("VM_1", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 1})
+	provider := &batchOnlyProvider{chainProvider: chainProvider{chains: map[string][]rca.Node{
+		"10.0.0.1": {app, vm},
+		"10.0.0.2": {app, vm},
+	}}}
+	analyzer, err := rca.NewAnalyzer(provider, rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	if _, err := analyzer.Analyze(context.Background(), []rca.AlarmEvent{
+		{AppName: "app-1", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "cpu"},
+		{AppName: "app-1", IP: "10.0.0.2", ServerType: rca.ServerTypeVM, RuleName: "mem"},
+	}); err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if provider.batches != 1 || provider.single != 0 {
+		t.Fatalf("expect a single batch call, got batches=%d single=%d", provider.batches, provider.single)
+	}
+}
@@ -0,0 +1,145 @@
+package rca_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/metrics"
+	"cmdb2neo/internal/rca"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// cacheReader 为每个宿主机 IP 返回一条链路，并统计 RunRead 次数。
+type cacheReader struct {
+	mu    sync.Mutex
+	reads int
+}
+
+func (r *cacheReader) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	r.mu.Lock()
+	r.reads++
+	r.mu.Unlock()
+	host := func(ip any) neo4j.Node {
+		return neo4j.Node{Labels: []string{"HostMachine"}, Props: map[string]any{"cmdb_key": "HM_" + ip.(string), "ip": ip}}
+	}
+	if events, ok := params["events"].([]map[string]any); ok {
+		records := make([]map[string]any, 0, len(events))
+		for _, event := range events {
+			records = append(records, map[string]any{"event": event, "host": host(event["ip"])})
+		}
+		return records, nil
+	}
+	if strings.Contains(query, "MATCH (host:HostMachine)") {
+		return []map[string]any{{"host": host(params["ip"])}}, nil
+	}
+	return nil, nil
+}
+
+func TestGraphProviderCacheSkipsRepeatedReads(t *testing.T) {
+	reader := &cacheReader{}
+	provider := rca.NewGraphProvider(reader, rca.WithCache(rca.CacheConfig{Size: 8, TTL: time.Minute}))
+	event := rca.AlarmEvent{IP: "10.0.0.1", ServerType: rca.ServerTypeHost}
+
+	if _, err := provider.ResolveEvent(context.Background(), event); err != nil {
+		t.Fatalf("first resolve: %v", err)
+	}
+	nodes, err := provider.ResolveEvent(context.Background(), event)
+	if err != nil {
+		t.Fatalf("second resolve: %v", err)
+	}
+	if reader.reads != 1 {
+		t.Fatalf("expect second resolve served from cache, got %d reads", reader.reads)
+	}
+	if len(nodes) != 1 || nodes[0].Key != "HM_10.0.0.1" {
+		t.Fatalf("unexpected cached chain %+v", nodes)
+	}
+
+	if _, err := provider.ResolveEvents(context.Background(), []rca.AlarmEvent{event, {IP: "10.0.0.2", ServerType: rca.ServerTypeHost}}); err != nil {
+		t.Fatalf("batch resolve: %v", err)
+	}
+	if reader.reads != 2 {
+		t.Fatalf("expect batch to query only the uncached event, got %d reads", reader.reads)
+	}
+	if stats := provider.CacheStats(); stats.Hits != 2 || stats.Misses != 2 || stats.Size != 2 {
+		t.Fatalf("unexpected cache stats %+v", stats)
+	}
+}
+
+func TestGraphProviderCacheExpiresAndEvicts(t *testing.T) {
+	reader := &cacheReader{}
+	provider := rca.NewGraphProvider(reader, rca.WithCache(rca.CacheConfig{Size: 1, TTL: 20 * time.Millisecond}))
+	resolve := func(ip string) {
+		t.Helper()
+		if _, err := provider.ResolveEvent(context.Background(), rca.AlarmEvent{IP: ip, ServerType: rca.ServerTypeHost}); err != nil {
+			t.Fatalf("resolve %s: %v", ip, err)
+		}
+	}
+
+	resolve("10.0.0.1")
+	resolve("10.0.0.2")
+	resolve("10.0.0.1")
+	if reader.reads != 3 {
+		t.Fatalf("expect size 1 cache to evict the older entry, got %d reads", reader.reads)
+	}
+	time.Sleep(30 * time.Millisecond)
+	resolve("10.0.0.1")
+	if reader.reads != 4 {
+		t.Fatalf("expect expired entry to be re-read, got %d reads", reader.reads)
+	}
+}
+
+func TestGraphProviderCacheConcurrentBatches(t *testing.T) {
+	reader := &cacheReader{}
+	provider := rca.NewGraphProvider(reader, rca.WithCache(rca.CacheConfig{}))
+	events := []rca.AlarmEvent{{IP: "10.0.0.1", ServerType: rca.ServerTypeHost}, {IP: "10.0.0.2", ServerType: rca.ServerTypeHost}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := provider.ResolveEvents(context.Background(), events); err != nil {
+				t.Errorf("batch resolve: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if stats := provider.CacheStats(); stats.Hits+stats.Misses != 16 || stats.Size != 2 {
+		t.Fatalf("unexpected cache stats after concurrent batches %+v", stats)
+	}
+}
+
+// cacheRecorder 只统计链路缓存的命中与未命中次数。
+type cacheRecorder struct {
+	metrics.Nop
+	hits, misses int
+}
+
+func (r *cacheRecorder) ObserveChainCache(hit bool) {
+	if hit {
+		r.hits++
+		return
+	}
+	r.misses++
+}
+
+func TestGraphProviderReportsCacheLookups(t *testing.T) {
+	recorder := &cacheRecorder{}
+	provider := rca.NewGraphProvider(&cacheReader{}, rca.WithCache(rca.CacheConfig{Size: 8, TTL: time.Minute}), rca.WithProviderRecorder(recorder))
+	event := rca.AlarmEvent{IP: "10.0.0.1", ServerType: rca.ServerTypeHost}
+	for i := 0; i < 2; i++ {
+		if _, err := provider.ResolveEvent(context.Background(), event); err != nil {
+			t.Fatalf("resolve: %v", err)
+		}
+	}
+	if _, err := provider.ResolveEvents(context.Background(), []rca.AlarmEvent{event, {IP: "10.0.0.2", ServerType: rca.ServerTypeHost}}); err != nil {
+		t.Fatalf("batch resolve: %v", err)
+	}
+	stats := provider.CacheStats()
+	if recorder.hits != int(stats.Hits) || recorder.misses != int(stats.Misses) || recorder.hits != 2 || recorder.misses != 2 {
+		t.Fatalf("expect recorder to match cache stats %+v, got hits=%d misses=%d", stats, recorder.hits, recorder.misses)
+	}
+}
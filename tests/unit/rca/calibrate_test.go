@@ -0,0 +1,89 @@
+package rca_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestCalibrateConfidenceSumsToOne(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	provider := &chainProvider{chains: map[string][]rca.Node{
+		"10.0.0.1": {
+			topoNode("APP_1", rca.NodeTypeApp, nil),
+			topoNode("VM_1", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 1}),
+		},
+		"10.0.0.2": {
+			topoNode("APP_2", rca.NodeTypeApp, nil),
+			topoNode("VM_2", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 3}),
+		},
+	}}
+	events := []rca.AlarmEvent{
+		{AppName: "a", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "cpu", OccurredAt: start},
+		{AppName: "b", IP: "10.0.0.2", ServerType: rca.ServerTypeVM, RuleName: "cpu", OccurredAt: start},
+	}
+	cfg := rca.DefaultConfig()
+	cfg.Layers[rca.NodeTypeVirtualMachine] = rca.LayerConfig{CoverageThreshold: 0.2, MinChildren: 1, Weights: rca.ScoreWeights{Coverage: 0.7, Impact: 0.3}}
+
+	raw := analyzeWith(t, provider, cfg, events)
+
+	cfg.CalibrateConfidence = true
+	calibrated := analyzeWith(t, provider, cfg, events)
+
+	if len(calibrated.Candidates) != len(raw.Candidates) || len(raw.Candidates) < 2 {
+		t.Fatalf("expected same candidate set, got raw=%d calibrated=%d", len(raw.Candidates), len(calibrated.Candidates))
+	}
+	before := make(map[string]rca.Candidate, len(raw.Candidates))
+	for _, cand := range raw.Candidates {
+		before[cand.Node.Key] = cand
+	}
+	sum := 0.0
+	for i, cand := range calibrated.Candidates {
+		sum += cand.Confidence
+		if i > 0 && cand.Confidence > calibrated.Candidates[i-1].Confidence {
+			t.Fatalf("calibration must keep ranking, got %+v", calibrated.Candidates)
+		}
+		if cand.Metrics.Calibrated != cand.Confidence {
+			t.Fatalf("expected calibrated metric to match confidence for %s", cand.Node.Key)
+		}
+		orig, ok := before[cand.Node.Key]
+		if !ok || cand.Metrics.RawScore != orig.Metrics.RawScore || cand.Metrics.Normalized != orig.Confidence {
+			t.Fatalf("expected raw metrics untouched for %s, got %+v", cand.Node.Key, cand.Metrics)
+		}
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Fatalf("expected calibrated confidences to sum to 1, got %f", sum)
+	}
+	for _, cand := range raw.Candidates {
+		if cand.Metrics.Calibrated != 0 {
+			t.Fatalf("expected no calibrated metric when disabled, got %+v", cand.Metrics)
+		}
+	}
+}
+
+func TestConfigOverrideCalibrateConfidence(t *testing.T) {
+	on := true
+	merged, err := rca.DefaultConfig().Merge(rca.ConfigOverride{CalibrateConfidence: &on})
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if !merged.CalibrateConfidence {
+		t.Fatalf("expected override to enable calibration")
+	}
+}
+
+func analyzeWith(t *testing.T, provider rca.TopologyProvider, cfg rca.Config, events []rca.AlarmEvent) rca.Result {
+	t.Helper()
+	analyzer, err := rca.NewAnalyzer(provider, cfg)
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	result, err := analyzer.Analyze(context.Background(), events)
+	if err != nil {
+		t.Fatalf("analyze failed: %v", err)
+	}
+	return result
+}
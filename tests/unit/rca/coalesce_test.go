@@ -0,0 +1,95 @@
+package rca_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestCoalesceEventsMergesRapidRepeats(t *testing.T) {
+	base := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	flap := rca.AlarmEvent{AppName: "order", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "ping"}
+
+	var events []rca.AlarmEvent
+	for i := 0; i < 5; i++ {
+		evt := flap
+		evt.OccurredAt = base.Add(time.Duration(i*5) * time.Second)
+		events = append(events, evt)
+	}
+	late := flap
+	late.OccurredAt = base.Add(2 * time.Minute)
+	events = append(events, late)
+
+	coalesced := rca.CoalesceEvents(events, 30*time.Second)
+	if len(coalesced) != 2 {
+		t.Fatalf("expect 2 coalesced events, got %d", len(coalesced))
+	}
+	first := coalesced[0]
+	if first.Count != 5 {
+		t.Fatalf("expect count 5, got %d", first.Count)
+	}
+	if !first.OccurredAt.Equal(base) || !first.LastOccurredAt.Equal(base.Add(20*time.Second)) {
+		t.Fatalf("unexpected span %s - %s", first.OccurredAt, first.LastOccurredAt)
+	}
+	if coalesced[1].Count != 1 || !coalesced[1].OccurredAt.Equal(late.OccurredAt) {
+		t.Fatalf("late repeat should stay separate, got %+v", coalesced[1])
+	}
+
+	if got := rca.CoalesceEvents(events, 0); len(got) != len(events) {
+		t.Fatalf("zero window must keep events, got %d", len(got))
+	}
+}
+
+func TestAnalyzerCollapsesDuplicateAlarms(t *testing.T) {
+	base := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	var alarms []rca.AlarmEvent
+	for i := 0; i < 3; i++ {
+		evt := singleEvent()[0]
+		evt.OccurredAt = base.Add(time.Duration(i) * time.Second)
+		alarms = append(alarms, evt)
+	}
+
+	cfg := rca.DefaultConfig()
+	cfg.CoalesceWindow = 10 * time.Second
+	analyzer, err := rca.NewAnalyzer(singleChainProvider(), cfg)
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	result, err := analyzer.Analyze(context.Background(), alarms)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	for _, cand := range result.Candidates {
+		if len(cand.Explained) != 1 {
+			t.Fatalf("expect 3 identical alarms to collapse to 1, got %v", cand.Explained)
+		}
+	}
+}
+
+func TestCoalesceEventsByKeyFields(t *testing.T) {
+	base := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	events := []rca.AlarmEvent{
+		{AppName: "order", IP: "10.0.0.1", RuleName: "ping", OccurredAt: base.Add(time.Second)},
+		{AppName: "pay", IP: "10.0.0.1", RuleName: "ping", OccurredAt: base},
+		{AppName: "pay", IP: "10.0.0.2", RuleName: "ping", OccurredAt: base},
+	}
+
+	if got := rca.CoalesceEventsBy(events, time.Minute, nil); len(got) != 3 {
+		t.Fatalf("default key includes app name, expect 3 events, got %d", len(got))
+	}
+	got := rca.CoalesceEventsBy(events, time.Minute, []string{"ip", "rule_name"})
+	if len(got) != 2 {
+		t.Fatalf("expect alarms on the same ip to collapse, got %+v", got)
+	}
+	if got[0].AppName != "pay" || got[0].Count != 2 || !got[0].OccurredAt.Equal(base) {
+		t.Fatalf("expect earliest alarm kept as representative, got %+v", got[0])
+	}
+
+	cfg := rca.DefaultConfig()
+	cfg.CoalesceKeys = []string{"hostname"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expect unknown coalesce key to be rejected")
+	}
+}
@@ -0,0 +1,124 @@
+package rca_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestConfigForTopologyPresets(t *testing.T) {
+	levels := []rca.Level{rca.LevelLenient, rca.LevelBalanced, rca.LevelStrict}
+	configs := make([]rca.Config, 0, len(levels))
+	for _, level := range levels {
+		cfg, err := rca.ConfigForTopology(4, level)
+		if err != nil {
+			t.Fatalf("generate %s: %v", level, err)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("%s config invalid: %v", level, err)
+		}
+		// 前 4 层为 应用、VM、宿主机/物理机、网络分区，IDC 在 StopAt 之上不配置
+		if cfg.StopAt != rca.NodeTypeNetPartition || len(cfg.Layers) != 5 {
+			t.Fatalf("%s expect 4 levels up to NetPartition, got stop_at=%s layers=%v", level, cfg.StopAt, cfg.Layers)
+		}
+		if _, ok := cfg.Layers[rca.NodeTypeIDC]; ok {
+			t.Fatalf("%s must not configure layers above depth", level)
+		}
+		if cfg.Layers[rca.NodeTypeHostMachine] != cfg.Layers[rca.NodeTypePhysicalMachine] {
+			t.Fatalf("%s expect host and physical machines to share a level", level)
+		}
+		bottom := cfg.Layers[rca.NodeTypeApp].CoverageThreshold
+		top := cfg.Layers[rca.NodeTypeNetPartition].CoverageThreshold
+		if top <= bottom {
+			t.Fatalf("%s upper layers should be stricter: bottom=%.2f top=%.2f", level, bottom, top)
+		}
+		configs = append(configs, cfg)
+	}
+
+	for i := 1; i < len(configs); i++ {
+		for layer := range configs[i].Layers {
+			prev := configs[i-1].Layers[layer].CoverageThreshold
+			curr := configs[i].Layers[layer].CoverageThreshold
+			if curr-prev < 0.1 {
+				t.Fatalf("%s vs %s threshold for %s too close: %.2f vs %.2f", levels[i-1], levels[i], layer, prev, curr)
+			}
+		}
+	}
+}
+
+// 每个深度生成的配置都要能分析 VM 与物理机两种典型链路，候选不超出该深度。
+func TestConfigForTopologyAcceptsTypicalChains(t *testing.T) {
+	idc := topoNode("IDC_1", rca.NodeTypeIDC, map[rca.NodeType]int{rca.NodeTypeNetPartition: 1})
+	np := topoNode("NP_1", rca.NodeTypeNetPartition, map[rca.NodeType]int{rca.NodeTypeHostMachine: 1, rca.NodeTypePhysicalMachine: 1})
+	provider := &chainProvider{chains: map[string][]rca.Node{
+		"10.0.0.1": {
+			topoNode("APP_1", rca.NodeTypeApp, nil),
+			topoNode("VM_1", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 1}),
+			topoNode("HM_1", rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 1}),
+			np, idc,
+		},
+		"10.0.1.1": {
+			topoNode("APP_2", rca.NodeTypeApp, nil),
+			topoNode("PM_1", rca.NodeTypePhysicalMachine, map[rca.NodeType]int{rca.NodeTypeApp: 1}),
+			np, idc,
+		},
+	}}
+	events := []rca.AlarmEvent{
+		{AppName: "a", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: time.Now()},
+		{AppName: "b", IP: "10.0.1.1", ServerType: rca.ServerTypePhysical, RuleName: "down", OccurredAt: time.Now()},
+	}
+	levels := [][]rca.NodeType{
+		{rca.NodeTypeApp},
+		{rca.NodeTypeVirtualMachine},
+		{rca.NodeTypeHostMachine, rca.NodeTypePhysicalMachine},
+		{rca.NodeTypeNetPartition},
+		{rca.NodeTypeIDC},
+	}
+	for depth := 1; depth <= len(levels); depth++ {
+		cfg, err := rca.ConfigForTopology(depth, rca.LevelLenient)
+		if err != nil {
+			t.Fatalf("depth %d: %v", depth, err)
+		}
+		analyzer, err := rca.NewAnalyzer(provider, cfg)
+		if err != nil {
+			t.Fatalf("depth %d: new analyzer: %v", depth, err)
+		}
+		result, err := analyzer.Analyze(context.Background(), events)
+		if err != nil {
+			t.Fatalf("depth %d: analyze typical chains: %v", depth, err)
+		}
+		allowed := make(map[rca.NodeType]bool)
+		for _, level := range levels[:depth] {
+			for _, typ := range level {
+				allowed[typ] = true
+			}
+		}
+		for _, cand := range result.Candidates {
+			if !allowed[cand.Node.Type] {
+				t.Fatalf("depth %d: candidate %s above the configured depth", depth, cand.Node.Key)
+			}
+		}
+	}
+}
+
+func TestConfigForTopologyRejectsBadInput(t *testing.T) {
+	if _, err := rca.ConfigForTopology(0, rca.LevelBalanced); err == nil {
+		t.Fatalf("expect error for zero depth")
+	}
+	if _, err := rca.ConfigForTopology(3, rca.Level("extreme")); err == nil {
+		t.Fatalf("expect error for unknown strictness")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := rca.DefaultConfig().Validate(); err != nil {
+		t.Fatalf("default config should validate: %v", err)
+	}
+	cfg := rca.DefaultConfig()
+	cfg.Hierarchy = append(cfg.Hierarchy, rca.NodeType("Rack"))
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expect unknown node type rejected")
+	}
+}
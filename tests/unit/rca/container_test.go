@@ -0,0 +1,144 @@
+package rca_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// containerProvider 让 cart 应用的 3 个实例分别跑在同一 VM 上的 3 个容器里。
+func containerProvider() *chainProvider {
+	vm := topoNode("VM_200", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 3, rca.NodeTypeContainer: 3})
+	host := topoNode("HM_100", rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 1})
+	chains := make(map[string][]rca.Node)
+	for i := 1; i <= 3; i++ {
+		chains[fmt.Sprintf("10.30.0.%d", i)] = []rca.Node{
+			topoNode(fmt.Sprintf("APP_%d", 400+i), rca.NodeTypeApp, nil),
+			topoNode(fmt.Sprintf("CTR_%d", 300+i), rca.NodeTypeContainer, map[rca.NodeType]int{rca.NodeTypeApp: 1}),
+			vm,
+			host,
+		}
+	}
+	return &chainProvider{chains: chains}
+}
+
+func containerAlarms(n int) []rca.AlarmEvent {
+	var alarms []rca.AlarmEvent
+	for i := 1; i <= n; i++ {
+		alarms = append(alarms, rca.AlarmEvent{AppName: "cart-service", IP: fmt.Sprintf("10.30.0.%d", i), ServerType: rca.ServerTypeContainer, RuleName: "5xx", OccurredAt: time.Now()})
+	}
+	return alarms
+}
+
+func TestDefaultHierarchySkipsContainerLayer(t *testing.T) {
+	analyzer, err := rca.NewAnalyzer(containerProvider(), rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	result, err := analyzer.Analyze(context.Background(), containerAlarms(3))
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	for _, cand := range result.Candidates {
+		if cand.Node.Type == rca.NodeTypeContainer {
+			t.Fatalf("expect container layer dropped by default hierarchy, got %+v", cand)
+		}
+	}
+	if vm := findCandidate(t, result.Candidates, rca.NodeTypeVirtualMachine); vm.Coverage != 1 {
+		t.Fatalf("expect vm covering every alarmed app, got %+v", vm)
+	}
+}
+
+func TestContainerLayerBetweenAppAndVM(t *testing.T) {
+	cfg := rca.DefaultConfig()
+	cfg.Hierarchy = []rca.NodeType{rca.NodeTypeApp, rca.NodeTypeContainer, rca.NodeTypeVirtualMachine, rca.NodeTypeHostMachine}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	analyzer, err := rca.NewAnalyzer(containerProvider(), cfg)
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	result, err := analyzer.Analyze(context.Background(), containerAlarms(3))
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	findCandidate(t, result.Candidates, rca.NodeTypeContainer)
+	for _, path := range result.Paths {
+		if path.Candidate.Key != "VM_200" {
+			continue
+		}
+		if len(path.Impacts) != 3 || path.Impacts[0].Node.Type != rca.NodeTypeContainer {
+			t.Fatalf("expect vm path to nest the alarmed containers, got %+v", path.Impacts)
+		}
+		return
+	}
+	t.Fatalf("vm path missing: %+v", result.Paths)
+}
+
+// containerReader 只响应容器层查询，返回 容器→VM→宿主机 的链路。
+type containerReader struct{ queries []string }
+
+func (r *containerReader) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	r.queries = append(r.queries, query)
+	if !strings.Contains(query, "MATCH (container:Container)") {
+		return nil, nil
+	}
+	record := map[string]any{
+		"app":                  neo4j.Node{Id: 1, Labels: []string{"App"}, Props: map[string]any{"cmdb_key": "APP_400", "name": "cart-service"}},
+		"container":            neo4j.Node{Id: 2, Labels: []string{"Container", "Compute"}, Props: map[string]any{"cmdb_key": "CTR_300", "ip": "10.30.0.1"}},
+		"vm":                   neo4j.Node{Id: 3, Labels: []string{"VirtualMachine", "Compute"}, Props: map[string]any{"cmdb_key": "VM_200"}},
+		"host":                 neo4j.Node{Id: 4, Labels: []string{"HostMachine", "Compute"}, Props: map[string]any{"cmdb_key": "HM_100"}},
+		"np":                   neo4j.Node{Id: 5, Labels: []string{"NetPartition"}, Props: map[string]any{"cmdb_key": "NP_10"}},
+		"idc":                  neo4j.Node{Id: 6, Labels: []string{"IDC"}, Props: map[string]any{"cmdb_key": "IDC_1"}},
+		"container_app_count":  int64(1),
+		"vm_app_count":         int64(2),
+		"vm_container_count":   int64(2),
+		"host_vm_count":        int64(1),
+		"host_container_count": int64(1),
+		"np_host_count":        int64(1),
+		"idc_np_count":         int64(1),
+	}
+	if events, ok := params["events"].([]map[string]any); ok {
+		out := make([]map[string]any, 0, len(events))
+		for _, evt := range events {
+			rec := make(map[string]any, len(record)+1)
+			for k, v := range record {
+				rec[k] = v
+			}
+			rec["event"] = evt
+			out = append(out, rec)
+		}
+		return out, nil
+	}
+	return []map[string]any{record}, nil
+}
+
+func TestGraphProviderResolvesContainerEvent(t *testing.T) {
+	reader := &containerReader{}
+	provider := rca.NewGraphProvider(reader)
+	nodes, err := provider.ResolveEvent(context.Background(), rca.AlarmEvent{AppName: "cart-service", IP: "10.30.0.1", ServerType: rca.ServerTypeContainer, RuleName: "5xx", OccurredAt: time.Now()})
+	if err != nil {
+		t.Fatalf("resolve event: %v", err)
+	}
+	keys := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		keys = append(keys, node.Key)
+	}
+	if got := strings.Join(keys, ","); got != "APP_400,CTR_300,VM_200,HM_100,NP_10,IDC_1" {
+		t.Fatalf("expect container chain attributed upward, got %s", got)
+	}
+	if !strings.Contains(reader.queries[0], "MATCH (container:Container)") {
+		t.Fatalf("expect container event to start at the container layer, got %s", reader.queries[0])
+	}
+	for _, node := range nodes {
+		if node.Key == "VM_200" && node.ChildCounts[rca.NodeTypeContainer] != 2 {
+			t.Fatalf("expect vm container count from the graph, got %+v", node.ChildCounts)
+		}
+	}
+}
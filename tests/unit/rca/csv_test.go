@@ -0,0 +1,39 @@
+package rca_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestWriteCandidatesCSV(t *testing.T) {
+	candidates := []rca.Candidate{
+		{Node: rca.NodeRef{Key: "VM_1", Type: rca.NodeTypeVirtualMachine, Name: "vm-01"}, Confidence: 0.5, Coverage: 0.5, Explained: []string{"e1"}},
+		{Node: rca.NodeRef{Key: "HM_1", Type: rca.NodeTypeHostMachine, Name: `rack 3, "hm-01"`}, Confidence: 0.9, Coverage: 1, Reason: "all children, alarmed", Explained: []string{"e1", "e2"}},
+	}
+	var buf bytes.Buffer
+	if err := rca.WriteCandidatesCSV(&buf, candidates); err != nil {
+		t.Fatalf("write csv: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("read back csv: %v", err)
+	}
+	if len(records) != 3 || records[0][0] != "node_key" {
+		t.Fatalf("expect header plus 2 rows, got %v", records)
+	}
+	want := []string{"HM_1", "HostMachine", `rack 3, "hm-01"`, "0.9000", "1.0000", "all children, alarmed", "2"}
+	for i, v := range want {
+		if records[1][i] != v {
+			t.Fatalf("expect highest confidence first with %v, got %v", want, records[1])
+		}
+	}
+	if records[2][0] != "VM_1" || records[2][6] != "1" {
+		t.Fatalf("unexpected second row %v", records[2])
+	}
+	if candidates[0].Node.Key != "VM_1" {
+		t.Fatal("input slice must not be reordered")
+	}
+}
@@ -0,0 +1,58 @@
+package rca_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestAnalyzerDedupsCandidateReachedViaTwoChains(t *testing.T) {
+	app := topoNode("APP_1", rca.NodeTypeApp, nil)
+	vm1 := topoNode("VM_1", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 1})
+	vm2 := topoNode("VM_2", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 1})
+	host := topoNode("HM_1", rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 4})
+
+	provider := &chainProvider{chains: map[string][]rca.Node{
+		"10.0.0.1": {app, vm1, host},
+		"10.0.0.2": {app, vm2, host},
+	}}
+	analyzer, err := rca.NewAnalyzer(provider, rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+
+	now := time.Now()
+	result, err := analyzer.Analyze(context.Background(), []rca.AlarmEvent{
+		{AppName: "app-1", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "cpu", OccurredAt: now},
+		{AppName: "app-1", IP: "10.0.0.2", ServerType: rca.ServerTypeVM, RuleName: "mem", OccurredAt: now},
+	})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+
+	count := 0
+	var merged rca.Candidate
+	for _, cand := range result.Candidates {
+		if cand.Node.Key == "APP_1" {
+			count++
+			merged = cand
+		}
+	}
+	if count != 1 {
+		t.Fatalf("expect APP_1 once, got %d", count)
+	}
+	if len(merged.Explained) != 2 {
+		t.Fatalf("expect merged explained events, got %v", merged.Explained)
+	}
+	paths := 0
+	for _, path := range result.Paths {
+		if path.Candidate.Key == "APP_1" {
+			paths++
+		}
+	}
+	if paths != 1 {
+		t.Fatalf("expect single APP_1 path, got %d", paths)
+	}
+}
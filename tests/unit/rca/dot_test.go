@@ -0,0 +1,78 @@
+package rca_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestRenderPathDOT(t *testing.T) {
+	host := rca.NodeRef{Key: "HM_1", Type: rca.NodeTypeHostMachine, Name: `hm "01"`}
+	vm1 := rca.NodeRef{Key: "VM_1", Type: rca.NodeTypeVirtualMachine, Name: "vm-01"}
+	vm2 := rca.NodeRef{Key: "VM_2", Type: rca.NodeTypeVirtualMachine, Name: "vm-02"}
+	app := rca.NodeRef{Key: "APP_1", Type: rca.NodeTypeApp, Name: "order"}
+	result := rca.Result{
+		Candidates: []rca.Candidate{{Node: host, Confidence: 0.9}, {Node: vm1, Confidence: 0.5}},
+		Paths: []rca.AlarmPath{{
+			Candidate: host,
+			Impacts: []rca.PathImpact{
+				{Node: vm1, Impacts: []rca.PathImpact{{Node: app, Events: []rca.AlarmEventRef{{ID: "e1"}, {ID: "e2", Suppressed: 2}}}}},
+				{Node: vm2, Events: []rca.AlarmEventRef{{ID: "e3"}}},
+			},
+		}},
+	}
+
+	dot := rca.RenderPathDOT(result)
+	for _, want := range []string{
+		"digraph rca {",
+		`"HM_1" [label="hm \"01\"\nHostMachine", fillcolor=tomato`,
+		`"VM_1" [label="vm-01\nVirtualMachine", fillcolor=lightyellow`,
+		`"HM_1" -> "VM_1" [label="4 events"];`,
+		`"VM_1" -> "APP_1" [label="4 events"];`,
+		`"HM_1" -> "VM_2" [label="1 events"];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Fatalf("expect %q in DOT output:\n%s", want, dot)
+		}
+	}
+	if strings.Count(dot, `"HM_1" [`) != 1 {
+		t.Fatalf("expect each node declared once:\n%s", dot)
+	}
+	if !strings.HasSuffix(dot, "}\n") {
+		t.Fatalf("expect closed graph:\n%s", dot)
+	}
+}
+
+// chainResult 用 应用→VM→宿主机 的单条链路分析一条带 2 条抑制重复的告警。
+func chainResult(t *testing.T) rca.Result {
+	t.Helper()
+	provider := &chainProvider{chains: map[string][]rca.Node{"10.0.0.1": {
+		topoNode("APP_1", rca.NodeTypeApp, nil),
+		topoNode("VM_1", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 1}),
+		topoNode("HM_1", rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 1}),
+	}}}
+	analyzer, err := rca.NewAnalyzer(provider, rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	result, err := analyzer.Analyze(context.Background(), []rca.AlarmEvent{{AppName: "app-1", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "cpu", OccurredAt: time.Now(), Suppressed: 2}})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	return result
+}
+
+func TestRenderPathDOTCountsAnalyzerEventsOnce(t *testing.T) {
+	dot := rca.RenderPathDOT(chainResult(t))
+	for _, want := range []string{
+		`"HM_1" -> "VM_1" [label="3 events"];`,
+		`"VM_1" -> "APP_1" [label="3 events"];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Fatalf("expect %q in DOT output:\n%s", want, dot)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package rca_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestCandidateExplanation(t *testing.T) {
+	host := topoNode("HM_1", rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 4})
+	chains := map[string][]rca.Node{"10.0.1.10": {host}}
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	alarms := []rca.AlarmEvent{{IP: "10.0.1.10", ServerType: rca.ServerTypeHost, RuleName: "down", OccurredAt: start}}
+	for i := 1; i <= 3; i++ {
+		ip := fmt.Sprintf("10.0.0.%d", i)
+		chains[ip] = []rca.Node{topoNode(fmt.Sprintf("VM_%d", i), rca.NodeTypeVirtualMachine, nil), host}
+		alarms = append(alarms, rca.AlarmEvent{IP: ip, ServerType: rca.ServerTypeVM, RuleName: "cpu", OccurredAt: start.Add(40 * time.Second)})
+	}
+	provider := &chainProvider{chains: chains}
+
+	explain := func(cfg rca.Config, opts ...rca.AnalyzerOption) string {
+		analyzer, err := rca.NewAnalyzer(provider, cfg, opts...)
+		if err != nil {
+			t.Fatalf("new analyzer: %v", err)
+		}
+		result, err := analyzer.Analyze(context.Background(), alarms)
+		if err != nil {
+			t.Fatalf("analyze: %v", err)
+		}
+		for _, cand := range result.Candidates {
+			if cand.Node.Key == "HM_1" {
+				return cand.Explanation
+			}
+		}
+		t.Fatalf("host candidate missing: %+v", result.Candidates)
+		return ""
+	}
+
+	if got := explain(rca.DefaultConfig()); got != "" {
+		t.Fatalf("expect no explanation unless enabled, got %q", got)
+	}
+
+	cfg := rca.DefaultConfig()
+	cfg.Explain = true
+	if got, want := explain(cfg), "4 台虚拟机中 3 台告警，自身告警早于下游 40s"; got != want {
+		t.Fatalf("expect %q, got %q", want, got)
+	}
+
+	opts := rca.DefaultPromptOptions()
+	opts.Language = "en-US"
+	if got, want := explain(cfg, rca.WithPromptOptions(opts)), "3/4 child VMs alarmed, fired 40s before downstream"; got != want {
+		t.Fatalf("expect %q, got %q", want, got)
+	}
+}
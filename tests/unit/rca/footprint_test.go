@@ -0,0 +1,119 @@
+package rca_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/rca"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+type footprintReader struct {
+	calls   int
+	err     error
+	queries []string
+}
+
+func (r *footprintReader) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	r.calls++
+	r.queries = append(r.queries, query)
+	if r.err != nil {
+		return nil, r.err
+	}
+	switch {
+	case strings.Contains(query, "(vm:VirtualMachine)"):
+		vm := func(key, ip string) neo4j.Node {
+			return neo4j.Node{Labels: []string{"VirtualMachine"}, Props: map[string]any{"cmdb_key": key, "ip": ip, "hostname": strings.ToLower(key)}}
+		}
+		return []map[string]any{
+			{"instance": vm("VM_2", "10.0.0.2"), "host_key": "HM_1", "host_ip": "10.1.0.1", "np_key": "NP_1", "np_name": "prod", "idc": params["idc"]},
+			{"instance": vm("VM_1", "10.0.0.1"), "host_key": "HM_1", "host_ip": "10.1.0.1", "np_key": "NP_1", "np_name": "prod", "idc": params["idc"]},
+			// 同一虚拟机经两个网络分区命中
+			{"instance": vm("VM_1", "10.0.0.1"), "host_key": "HM_1", "host_ip": "10.1.0.1", "np_key": "NP_2", "np_name": "backup", "idc": params["idc"]},
+		}, nil
+	case strings.Contains(query, "(phy:PhysicalMachine)"):
+		return []map[string]any{
+			{"instance": neo4j.Node{Labels: []string{"PhysicalMachine"}, Props: map[string]any{"cmdb_key": "PM_1", "ip": "10.2.0.1"}}, "np_key": nil, "np_name": nil, "idc": nil},
+		}, nil
+	}
+	return nil, nil
+}
+
+func TestGraphFootprintReaderAggregatesInstances(t *testing.T) {
+	reader := &footprintReader{}
+	fr := rca.NewGraphFootprintReader(reader)
+	got, err := fr.AppFootprint(context.Background(), " order ", "M5")
+	if err != nil {
+		t.Fatalf("footprint: %v", err)
+	}
+	if got.App != "order" || got.IDC != "M5" || got.Total != 3 {
+		t.Fatalf("unexpected footprint header: %+v", got)
+	}
+	if got.Counts[rca.NodeTypeVirtualMachine] != 2 || got.Counts[rca.NodeTypePhysicalMachine] != 1 || got.Counts[rca.NodeTypeHostMachine] != 0 {
+		t.Fatalf("unexpected counts: %v", got.Counts)
+	}
+	keys := make([]string, 0, len(got.Instances))
+	for _, inst := range got.Instances {
+		keys = append(keys, inst.Key)
+	}
+	if strings.Join(keys, ",") != "VM_1,VM_2,PM_1" {
+		t.Fatalf("expect instances ordered by layer then key, got %v", keys)
+	}
+	vm := got.Instances[0]
+	if vm.IP != "10.0.0.1" || vm.Hostname != "vm_1" || vm.HostKey != "HM_1" || vm.HostIP != "10.1.0.1" || vm.Partition != "prod" || vm.PartitionKey != "NP_1" || vm.IDC != "M5" {
+		t.Fatalf("unexpected vm instance: %+v", vm)
+	}
+	if pm := got.Instances[2]; pm.Type != rca.NodeTypePhysicalMachine || pm.IP != "10.2.0.1" || pm.IDC != "" {
+		t.Fatalf("unexpected physical instance: %+v", pm)
+	}
+
+	calls := reader.calls
+	if _, err := fr.AppFootprint(context.Background(), "order", "M5"); err != nil {
+		t.Fatalf("footprint: %v", err)
+	}
+	if reader.calls != calls || fr.CacheStats().Hits != 1 {
+		t.Fatalf("expect cached footprint, got %d extra queries, stats %+v", reader.calls-calls, fr.CacheStats())
+	}
+	if _, err := fr.AppFootprint(context.Background(), "order", ""); err != nil || reader.calls == calls {
+		t.Fatalf("expect different idc to query again, err %v", err)
+	}
+}
+
+func TestGraphFootprintReaderErrors(t *testing.T) {
+	if _, err := rca.NewGraphFootprintReader(&footprintReader{}).AppFootprint(context.Background(), " ", ""); err == nil {
+		t.Fatalf("expect empty app name rejected")
+	}
+	boom := errors.New("neo4j down")
+	fr := rca.NewGraphFootprintReader(&footprintReader{err: boom})
+	if _, err := fr.AppFootprint(context.Background(), "order", ""); !errors.Is(err, boom) {
+		t.Fatalf("expect query error wrapped, got %v", err)
+	}
+	if fr.CacheStats().Size != 0 {
+		t.Fatalf("failed queries must not be cached")
+	}
+}
+
+// 部署范围沿链路逐层 OPTIONAL MATCH，每一层都要跳过墓碑节点与关系，否则会经由已下线的宿主机归到分区与机房。
+func TestGraphFootprintQueriesSkipTombstonesOnEveryHop(t *testing.T) {
+	reader := &footprintReader{}
+	if _, err := rca.NewGraphFootprintReader(reader).AppFootprint(context.Background(), "order", ""); err != nil {
+		t.Fatalf("footprint: %v", err)
+	}
+	if len(reader.queries) == 0 {
+		t.Fatalf("expect footprint queries to run")
+	}
+	for _, query := range reader.queries {
+		hops := strings.Split(query, "OPTIONAL MATCH")[1:]
+		if len(hops) == 0 {
+			t.Fatalf("expect optional hops in footprint query:\n%s", query)
+		}
+		for _, hop := range hops {
+			hop, _, _ = strings.Cut(hop, "\nWITH ")
+			if !strings.Contains(hop, "deleted, false) = false") {
+				t.Fatalf("optional hop without tombstone filter:\nOPTIONAL MATCH%s", hop)
+			}
+		}
+	}
+}
@@ -10,59 +10,56 @@ import (
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
 
+// mockGraphReader 对 VM 层查询按应用名返回完整链路，链路同时带有宿主机与物理机。
 type mockGraphReader struct{}
 
 func (m *mockGraphReader) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
-	switch {
-	case strings.Contains(query, "MATCH (app:App)"):
-		service, _ := params["service"].(string)
-		return []map[string]any{buildAppRecord(service)}, nil
-	default:
+	if !strings.Contains(query, "MATCH (vm:VirtualMachine)") {
 		return nil, nil
 	}
+	if events, ok := params["events"].([]map[string]any); ok {
+		records := make([]map[string]any, 0, len(events))
+		for _, event := range events {
+			name, _ := event["name"].(string)
+			record := buildAppRecord(name)
+			record["event"] = event
+			records = append(records, record)
+		}
+		return records, nil
+	}
+	name, _ := params["name"].(string)
+	return []map[string]any{buildAppRecord(name)}, nil
 }
 
-func TestGraphTopologyProviderDropPhysical(t *testing.T) {
-	provider := rca.NewGraphTopologyProvider(&mockGraphReader{})
-	evt := rca.AlarmEvent{
-		ID:       "evt-app-1",
-		NodeType: rca.NodeTypeApp,
-		Service:  "order-service",
-		Occurred: time.Now(),
-	}
+func TestGraphProviderDropsPhysical(t *testing.T) {
+	provider := rca.NewGraphProvider(&mockGraphReader{})
+	evt := rca.AlarmEvent{AppName: "order-service", IP: "172.16.20.101", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: time.Now()}
 
-	ctx, err := provider.ResolveContext(context.Background(), evt)
+	nodes, err := provider.ResolveEvent(context.Background(), evt)
 	if err != nil {
-		t.Fatalf("resolve context: %v", err)
+		t.Fatalf("resolve event: %v", err)
 	}
 
-	if ctx.HostMachine == nil {
-		t.Fatalf("expected host node present")
+	types := make(map[rca.NodeType]bool, len(nodes))
+	for _, node := range nodes {
+		types[node.Type] = true
 	}
-	if ctx.PhysicalMachine != nil {
-		t.Fatalf("expected physical node dropped when host exists")
+	if !types[rca.NodeTypeHostMachine] {
+		t.Fatalf("expected host node present, got %+v", nodes)
+	}
+	if types[rca.NodeTypePhysicalMachine] {
+		t.Fatalf("expected physical node dropped when host exists, got %+v", nodes)
 	}
 }
 
 func TestAnalyzerWithGraphProvider(t *testing.T) {
 	events := []rca.AlarmEvent{
-		{
-			ID:       "evt-app-1",
-			NodeType: rca.NodeTypeApp,
-			Service:  "order-service",
-			Occurred: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
-		},
-		{
-			ID:       "evt-app-2",
-			NodeType: rca.NodeTypeApp,
-			Service:  "payment-service",
-			Occurred: time.Date(2024, 3, 1, 10, 0, 30, 0, time.UTC),
-		},
-	}
-
-	provider := rca.NewGraphTopologyProvider(&mockGraphReader{})
+		{AppName: "order-service", IP: "172.16.20.101", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{AppName: "payment-service", IP: "172.16.20.102", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: time.Date(2024, 3, 1, 10, 0, 30, 0, time.UTC)},
+	}
+
+	provider := rca.NewGraphProvider(&mockGraphReader{})
 	cfg := rca.DefaultConfig()
-	cfg.Hierarchy = []rca.NodeType{rca.NodeTypeVirtualMachine, rca.NodeTypeHostMachine}
 	cfg.Layers[rca.NodeTypeVirtualMachine] = rca.LayerConfig{
 		CoverageThreshold: 0.5,
 		MinChildren:       1,
@@ -74,23 +71,69 @@ func TestAnalyzerWithGraphProvider(t *testing.T) {
 		Weights:           rca.ScoreWeights{Coverage: 0.7, TimeLead: 0.2, Impact: 0.1},
 	}
 
-	analyzer, err := rca.NewAnalyzer(provider, nil, cfg)
+	analyzer, err := rca.NewAnalyzer(provider, cfg)
 	if err != nil {
 		t.Fatalf("new analyzer: %v", err)
 	}
 
-	result, err := analyzer.Analyze(context.Background(), "window-graph", events)
+	result, err := analyzer.AnalyzeWithOptions(context.Background(), events, rca.AnalyzeOptions{WindowID: "window-graph"})
 	if err != nil {
 		t.Fatalf("analyze failed: %v", err)
 	}
 
-	if len(result.Candidates) != 2 {
-		t.Fatalf("expected 2 candidates, got %d", len(result.Candidates))
-	}
+	keys := make(map[string]bool, len(result.Candidates))
 	for _, cand := range result.Candidates {
 		if cand.Node.Type == rca.NodeTypePhysicalMachine {
 			t.Fatalf("physical machine should not appear as candidate in this scenario")
 		}
+		keys[cand.Node.Key] = true
+	}
+	if !keys["VM_100"] {
+		t.Fatalf("expected VM_100 hosting both alarmed apps as candidate, got %v", keys)
+	}
+	if keys["HM_10"] {
+		t.Fatalf("host with 1 of 3 VMs alarmed should stay below threshold, got %v", keys)
+	}
+}
+
+// bareMetalReader 对应用层查询返回部署在物理机上、没有虚拟机的链路。
+type bareMetalReader struct{ queries []string }
+
+func (r *bareMetalReader) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	r.queries = append(r.queries, query)
+	if !strings.Contains(query, "MATCH (app:App)") {
+		return nil, nil
+	}
+	record := map[string]any{
+		"app":               neo4j.Node{Id: 1, Labels: []string{"App"}, Props: map[string]any{"cmdb_key": "APP_7007", "name": "billing-batch"}},
+		"physical":          neo4j.Node{Id: 2, Labels: []string{"PhysicalMachine", "Compute"}, Props: map[string]any{"cmdb_key": "PM_6001", "hostname": "m5-phy-blade-01"}},
+		"np":                neo4j.Node{Id: 3, Labels: []string{"NetPartition"}, Props: map[string]any{"cmdb_key": "NP_201", "name": "Production Zone"}},
+		"idc":               neo4j.Node{Id: 4, Labels: []string{"IDC"}, Props: map[string]any{"cmdb_key": "IDC_101", "name": "M5"}},
+		"np_physical_count": int64(2),
+		"idc_np_count":      int64(2),
+	}
+	if events, ok := params["events"].([]map[string]any); ok {
+		record["event"] = events[0]
+	}
+	return []map[string]any{record}, nil
+}
+
+func TestGraphProviderResolvesAppOnPhysicalMachine(t *testing.T) {
+	reader := &bareMetalReader{}
+	provider := rca.NewGraphProvider(reader)
+	nodes, err := provider.ResolveEvent(context.Background(), rca.AlarmEvent{AppName: "billing-batch", RuleName: "down", OccurredAt: time.Now()})
+	if err != nil {
+		t.Fatalf("resolve event: %v", err)
+	}
+	keys := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		keys = append(keys, node.Key)
+	}
+	if got := strings.Join(keys, ","); got != "APP_7007,PM_6001,NP_201,IDC_101" {
+		t.Fatalf("expect app chained through its physical machine, got %s", got)
+	}
+	if len(reader.queries) == 0 || !strings.Contains(reader.queries[0], "DEPLOYED_ON]->(phy:PhysicalMachine)") {
+		t.Fatalf("expect app query to follow direct physical deployments")
 	}
 }
 
@@ -114,3 +157,142 @@ func buildAppRecord(service string) map[string]any {
 		"idc_np_count":      int64(1),
 	}
 }
+
+// ipv6HostReader 只在宿主机层查询的 ip 参数为规范写法 2001:db8::a 时返回链路。
+type ipv6HostReader struct{}
+
+func (ipv6HostReader) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	if !strings.Contains(query, "MATCH (host:HostMachine)") {
+		return nil, nil
+	}
+	record := map[string]any{
+		"host": neo4j.Node{Id: 1, Labels: []string{"HostMachine", "Compute"}, Props: map[string]any{"cmdb_key": "HM_100", "ip": "2001:db8::a"}},
+	}
+	if events, ok := params["events"].([]map[string]any); ok {
+		var out []map[string]any
+		for _, evt := range events {
+			if evt["ip"] == "2001:db8::a" {
+				out = append(out, map[string]any{"host": record["host"], "event": evt})
+			}
+		}
+		return out, nil
+	}
+	if params["ip"] != "2001:db8::a" {
+		return nil, nil
+	}
+	return []map[string]any{record}, nil
+}
+
+func TestGraphProviderMatchesEquivalentIPv6(t *testing.T) {
+	provider := rca.NewGraphProvider(ipv6HostReader{})
+	spellings := []string{"2001:db8::a", "2001:DB8:0:0:0:0:0:A", "2001:db8:0::000a"}
+	events := make([]rca.AlarmEvent, 0, len(spellings))
+	for _, ip := range spellings {
+		evt := rca.AlarmEvent{ServerType: rca.ServerTypeHost, IP: ip, RuleName: "ping", OccurredAt: time.Now()}
+		events = append(events, evt)
+		nodes, err := provider.ResolveEvent(context.Background(), evt)
+		if err != nil || len(nodes) != 1 || nodes[0].Key != "HM_100" {
+			t.Fatalf("expect %s to resolve to HM_100, got %+v, %v", ip, nodes, err)
+		}
+	}
+	chains, err := provider.ResolveEvents(context.Background(), events)
+	if err != nil {
+		t.Fatalf("batch resolve: %v", err)
+	}
+	for i, nodes := range chains {
+		if len(nodes) != 1 || nodes[0].Key != "HM_100" {
+			t.Fatalf("expect batch event %d to resolve to HM_100, got %+v", i, nodes)
+		}
+	}
+	if events[1].IP != "2001:DB8:0:0:0:0:0:A" {
+		t.Fatalf("resolution must not rewrite the caller's events")
+	}
+}
+
+// mixedHostReader 模拟部分虚拟机在宿主机上、部分在物理机上的链路，物理机由查询放在 host 位置返回。
+type mixedHostReader struct{ queries []string }
+
+func (r *mixedHostReader) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	r.queries = append(r.queries, query)
+	if !strings.Contains(query, "MATCH (vm:VirtualMachine)") {
+		return nil, nil
+	}
+	if events, ok := params["events"].([]map[string]any); ok {
+		records := make([]map[string]any, 0, len(events))
+		for _, event := range events {
+			name, _ := event["name"].(string)
+			record := mixedHostRecord(name)
+			record["event"] = event
+			records = append(records, record)
+		}
+		return records, nil
+	}
+	name, _ := params["name"].(string)
+	return []map[string]any{mixedHostRecord(name)}, nil
+}
+
+func mixedHostRecord(app string) map[string]any {
+	vms := map[string]string{"order": "VM_1", "billing": "VM_2", "report": "VM_3"}
+	host := neo4j.Node{Id: 10, Labels: []string{"HostMachine", "Machine", "Compute"}, Props: map[string]any{"cmdb_key": "HM_10", "hostname": "host-10"}}
+	hostVMs := int64(3)
+	if app != "order" {
+		host = neo4j.Node{Id: 20, Labels: []string{"PhysicalMachine", "Machine", "Compute"}, Props: map[string]any{"cmdb_key": "PM_20", "hostname": "pm-20"}}
+		hostVMs = 2
+	}
+	return map[string]any{
+		"app":               neo4j.Node{Id: 1, Labels: []string{"App"}, Props: map[string]any{"cmdb_key": "APP_" + app, "name": app}},
+		"vm":                neo4j.Node{Id: 2, Labels: []string{"VirtualMachine", "Compute"}, Props: map[string]any{"cmdb_key": vms[app]}},
+		"host":              host,
+		"np":                neo4j.Node{Id: 5, Labels: []string{"NetPartition"}, Props: map[string]any{"cmdb_key": "NP_1", "name": "net-1"}},
+		"idc":               neo4j.Node{Id: 6, Labels: []string{"IDC"}, Props: map[string]any{"cmdb_key": "IDC_1", "name": "idc-1"}},
+		"vm_app_count":      int64(1),
+		"host_vm_count":     hostVMs,
+		"np_host_count":     int64(1),
+		"np_physical_count": int64(1),
+		"idc_np_count":      int64(1),
+	}
+}
+
+func TestGraphProviderPlacesPhysicalHostInHostSlot(t *testing.T) {
+	reader := &mixedHostReader{}
+	provider := rca.NewGraphProvider(reader)
+	nodes, err := provider.ResolveEvent(context.Background(), rca.AlarmEvent{AppName: "billing", IP: "10.0.0.2", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: time.Now()})
+	if err != nil {
+		t.Fatalf("resolve event: %v", err)
+	}
+	keys := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		keys = append(keys, node.Key)
+	}
+	if got := strings.Join(keys, ","); got != "APP_billing,VM_2,PM_20,NP_1,IDC_1" {
+		t.Fatalf("expect vm chained through its physical machine, got %s", got)
+	}
+	query := reader.queries[0]
+	if !strings.Contains(query, "(host:HostMachine OR host:PhysicalMachine)") || !strings.Contains(query, "[r3:HAS_HOST|HAS_PHYSICAL]") {
+		t.Fatalf("expect vm query to traverse both host types:\n%s", query)
+	}
+
+	events := []rca.AlarmEvent{
+		{AppName: "order", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{AppName: "billing", IP: "10.0.0.2", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: time.Date(2024, 3, 1, 10, 0, 10, 0, time.UTC)},
+		{AppName: "report", IP: "10.0.0.3", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: time.Date(2024, 3, 1, 10, 0, 20, 0, time.UTC)},
+	}
+	analyzer, err := rca.NewAnalyzer(provider, rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	result, err := analyzer.AnalyzeWithOptions(context.Background(), events, rca.AnalyzeOptions{WindowID: "window-mixed"})
+	if err != nil {
+		t.Fatalf("analyze failed: %v", err)
+	}
+	types := make(map[string]rca.NodeType, len(result.Candidates))
+	for _, cand := range result.Candidates {
+		types[cand.Node.Key] = cand.Node.Type
+	}
+	if types["PM_20"] != rca.NodeTypePhysicalMachine {
+		t.Fatalf("expect physical machine hosting both alarmed vms as candidate, got %v", types)
+	}
+	if _, ok := types["HM_10"]; ok {
+		t.Fatalf("host with 1 of 3 vms alarmed should stay below threshold, got %v", types)
+	}
+}
@@ -0,0 +1,63 @@
+package rca_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestHealthySignalDemotesFullyCoveredHost(t *testing.T) {
+	host := topoNode("HM_1", rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 2})
+	vm1 := topoNode("VM_1", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 1})
+	vm2 := topoNode("VM_2", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 1})
+	provider := &chainProvider{chains: map[string][]rca.Node{
+		"10.0.0.1":  {topoNode("APP_1", rca.NodeTypeApp, nil), vm1, host},
+		"10.0.0.2":  {topoNode("APP_2", rca.NodeTypeApp, nil), vm2, host},
+		"10.0.1.10": {host},
+	}}
+	analyzer, err := rca.NewAnalyzer(provider, rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+
+	now := time.Now()
+	alarms := []rca.AlarmEvent{
+		{AppName: "order", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: now},
+		{AppName: "order", IP: "10.0.0.2", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: now},
+	}
+	hostConfidence := func(result rca.Result) (float64, int) {
+		for i, cand := range result.Candidates {
+			if cand.Node.Key == "HM_1" {
+				return cand.Confidence, i
+			}
+		}
+		t.Fatalf("host candidate missing: %+v", result.Candidates)
+		return 0, 0
+	}
+
+	baseline, err := analyzer.Analyze(context.Background(), alarms)
+	if err != nil {
+		t.Fatalf("analyze baseline: %v", err)
+	}
+	before, _ := hostConfidence(baseline)
+
+	healthy := rca.AlarmEvent{IP: "10.0.1.10", ServerType: rca.ServerTypeHost, RuleName: "heartbeat", OccurredAt: now, Healthy: true}
+	demoted, err := analyzer.Analyze(context.Background(), append(alarms, healthy))
+	if err != nil {
+		t.Fatalf("analyze with healthy signal: %v", err)
+	}
+	after, rank := hostConfidence(demoted)
+	if after >= before {
+		t.Fatalf("expect healthy signal to lower host confidence, before=%.2f after=%.2f", before, after)
+	}
+	if rank == 0 {
+		t.Fatalf("expect demoted host to no longer rank first: %+v", demoted.Candidates)
+	}
+	for _, cand := range demoted.Candidates {
+		if cand.Node.Key == "HM_1" && len(cand.Explained) != len(alarms) {
+			t.Fatalf("healthy signal must not be explained as an alarm: %v", cand.Explained)
+		}
+	}
+}
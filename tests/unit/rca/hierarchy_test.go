@@ -0,0 +1,71 @@
+package rca_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestChainNodesFollowOrder(t *testing.T) {
+	vm, host, np := topoNode("VM_1", rca.NodeTypeVirtualMachine, nil), topoNode("HM_1", rca.NodeTypeHostMachine, nil), topoNode("NP_1", rca.NodeTypeNetPartition, nil)
+	chain := rca.Chain{VirtualMachine: &vm, HostMachine: &host, NetPartition: &np}
+
+	nodes := chain.Nodes([]rca.NodeType{rca.NodeTypeVirtualMachine, rca.NodeTypeNetPartition, rca.NodeTypeHostMachine})
+	var keys []string
+	for _, node := range nodes {
+		keys = append(keys, node.NodeRef.Key)
+	}
+	if strings.Join(keys, ",") != "VM_1,NP_1,HM_1" {
+		t.Fatalf("expect chain in hierarchy order, got %v", keys)
+	}
+}
+
+func TestAnalyzerOrdersChainByHierarchy(t *testing.T) {
+	provider := &chainProvider{chains: map[string][]rca.Node{
+		"10.0.0.1": {
+			topoNode("VM_1", rca.NodeTypeVirtualMachine, nil),
+			topoNode("HM_1", rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeNetPartition: 1}),
+			topoNode("NP_1", rca.NodeTypeNetPartition, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 1}),
+		},
+	}}
+	alarms := []rca.AlarmEvent{{IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: time.Now()}}
+
+	cfg := rca.DefaultConfig()
+	cfg.Hierarchy = []rca.NodeType{rca.NodeTypeVirtualMachine, rca.NodeTypeNetPartition, rca.NodeTypeHostMachine}
+	analyzer, err := rca.NewAnalyzer(provider, cfg)
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	result, err := analyzer.Analyze(context.Background(), alarms)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	for _, path := range result.Paths {
+		if path.Candidate.Key == "HM_1" {
+			if len(path.Impacts) != 1 || path.Impacts[0].Node.Key != "NP_1" {
+				t.Fatalf("expect host above partition per hierarchy, got %+v", path.Impacts)
+			}
+			return
+		}
+	}
+	t.Fatalf("host path missing: %+v", result.Paths)
+}
+
+func TestAnalyzerRejectsTypeOutsideHierarchy(t *testing.T) {
+	cfg := rca.DefaultConfig()
+	cfg.Hierarchy = []rca.NodeType{rca.NodeTypeApp, rca.NodeTypeVirtualMachine, rca.NodeTypeHostMachine, rca.NodeTypeNetPartition, rca.NodeTypeIDC}
+	provider := &chainProvider{chains: map[string][]rca.Node{
+		"10.0.0.1": {topoNode("HM_1", rca.NodeTypeHostMachine, nil), topoNode("PM_1", rca.NodeTypePhysicalMachine, nil)},
+	}}
+	analyzer, err := rca.NewAnalyzer(provider, cfg)
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	_, err = analyzer.Analyze(context.Background(), []rca.AlarmEvent{{IP: "10.0.0.1", ServerType: rca.ServerTypeHost, RuleName: "down", OccurredAt: time.Now()}})
+	if err == nil || !strings.Contains(err.Error(), "PhysicalMachine") {
+		t.Fatalf("expect error naming the missing layer, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package rca_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/rca"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// hostnameReader 模拟只有主机名 vm-01 能命中的 VM 层，按 machine_key 的优先级判定参数。
+type hostnameReader struct{}
+
+func hostnameMatches(params map[string]any) bool {
+	return params["cmdb_key"] == "" && params["ip"] == "" && params["hostname"] == "vm-01"
+}
+
+func (hostnameReader) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	vm := neo4j.Node{Labels: []string{"VirtualMachine"}, Props: map[string]any{"cmdb_key": "VM_1", "hostname": "vm-01"}}
+	host := neo4j.Node{Labels: []string{"HostMachine"}, Props: map[string]any{"cmdb_key": "HM_1"}}
+	if !strings.Contains(query, "MATCH (vm:VirtualMachine)") {
+		return nil, nil
+	}
+	if events, ok := params["events"].([]map[string]any); ok {
+		var records []map[string]any
+		for _, event := range events {
+			if hostnameMatches(event) {
+				records = append(records, map[string]any{"event": event, "vm": vm, "host": host})
+			}
+		}
+		return records, nil
+	}
+	if hostnameMatches(params) {
+		return []map[string]any{{"vm": vm, "host": host}}, nil
+	}
+	return nil, nil
+}
+
+func TestGraphProviderFallsBackToHostname(t *testing.T) {
+	provider := rca.NewGraphProvider(hostnameReader{})
+	event := rca.AlarmEvent{Hostname: "vm-01", ServerType: rca.ServerTypeVM, RuleName: "cpu"}
+
+	nodes, err := provider.ResolveEvent(context.Background(), event)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if len(nodes) != 2 || nodes[0].Key != "VM_1" || nodes[1].Key != "HM_1" {
+		t.Fatalf("expect hostname-only event resolved on VM layer, got %+v", nodes)
+	}
+
+	chains, err := provider.ResolveEvents(context.Background(), []rca.AlarmEvent{event})
+	if err != nil {
+		t.Fatalf("resolve events: %v", err)
+	}
+	if len(chains) != 1 || len(chains[0]) == 0 || chains[0][0].Key != "VM_1" {
+		t.Fatalf("expect batch path to match hostname, got %+v", chains)
+	}
+
+	_, err = provider.ResolveEvent(context.Background(), rca.AlarmEvent{Hostname: "hm-01", ServerType: rca.ServerTypeHost})
+	if err == nil || !strings.Contains(err.Error(), "hm-01") {
+		t.Fatalf("expect not-found error naming the hostname, got %v", err)
+	}
+}
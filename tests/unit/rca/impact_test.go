@@ -0,0 +1,71 @@
+package rca_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestImpactBreaksCoverageTie(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	vmA := topoNode("VM_A", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 4})
+	hostA := topoNode("HM_A", rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 2})
+	vmB := topoNode("VM_B", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 1})
+	hostB := topoNode("HM_B", rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 2})
+
+	provider := &chainProvider{chains: map[string][]rca.Node{
+		"10.0.1.1": {topoNode("APP_B", rca.NodeTypeApp, nil), vmB, hostB},
+	}}
+	events := []rca.AlarmEvent{{AppName: "b", IP: "10.0.1.1", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: start}}
+	// HM_A 下的 VM 承载 4 个告警应用，HM_B 只有 1 个，两台宿主机的 VM 覆盖率同为 0.5
+	for i := 0; i < 4; i++ {
+		ip := fmt.Sprintf("10.0.0.%d", i+1)
+		provider.chains[ip] = []rca.Node{topoNode(fmt.Sprintf("APP_A%d", i), rca.NodeTypeApp, nil), vmA, hostA}
+		events = append(events, rca.AlarmEvent{AppName: fmt.Sprintf("a%d", i), IP: ip, ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: start})
+	}
+
+	cfg := rca.DefaultConfig()
+	cfg.Hierarchy = []rca.NodeType{rca.NodeTypeApp, rca.NodeTypeVirtualMachine, rca.NodeTypeHostMachine}
+	layer := cfg.Layers[rca.NodeTypeHostMachine]
+	layer.CoverageThreshold = 0.4
+	cfg.Layers[rca.NodeTypeHostMachine] = layer
+
+	result := analyzeWith(t, provider, cfg, events)
+
+	hostACand := findCandidateByKey(t, result.Candidates, "HM_A")
+	hostBCand := findCandidateByKey(t, result.Candidates, "HM_B")
+	if hostACand.Coverage != hostBCand.Coverage {
+		t.Fatalf("expected coverage tie, got %v vs %v", hostACand.Coverage, hostBCand.Coverage)
+	}
+	if math.Abs(hostACand.Metrics.Impact-0.8) > 1e-9 || math.Abs(hostBCand.Metrics.Impact-0.2) > 1e-9 {
+		t.Fatalf("expected impact 0.8 vs 0.2, got %v vs %v", hostACand.Metrics.Impact, hostBCand.Metrics.Impact)
+	}
+	if hostACand.Confidence <= hostBCand.Confidence {
+		t.Fatalf("expected wider blast radius to rank higher, got %v <= %v", hostACand.Confidence, hostBCand.Confidence)
+	}
+}
+
+func TestImpactZeroWithoutTotal(t *testing.T) {
+	node := rca.NewTopoNode(topoNode("VM_1", rca.NodeTypeVirtualMachine, nil))
+	node.AddEvent("e1", rca.AlarmEventRef{ID: "e1"})
+	if impact := node.Impact(0); impact != 0 {
+		t.Fatalf("expected zero impact without window total, got %v", impact)
+	}
+	if impact := node.Impact(4); impact != 0.25 {
+		t.Fatalf("expected impact 0.25, got %v", impact)
+	}
+}
+
+func findCandidateByKey(t *testing.T, candidates []rca.Candidate, key string) rca.Candidate {
+	t.Helper()
+	for _, cand := range candidates {
+		if cand.Node.Key == key {
+			return cand
+		}
+	}
+	t.Fatalf("candidate %s missing in %+v", key, candidates)
+	return rca.Candidate{}
+}
@@ -0,0 +1,143 @@
+package rca_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+// waitJob 轮询直到任务结束。
+func waitJob(t *testing.T, jobs *rca.JobRegistry, id string) rca.Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, ok := jobs.Get(id)
+		if !ok {
+			t.Fatalf("job %s disappeared", id)
+		}
+		if job.State != rca.JobPending && job.State != rca.JobRunning {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return rca.Job{}
+}
+
+// submitJob 提交任务并要求登记成功。
+func submitJob(t *testing.T, jobs *rca.JobRegistry, windowID string, fn func(context.Context) (rca.Result, error)) rca.Job {
+	t.Helper()
+	job, err := jobs.Submit(windowID, fn)
+	if err != nil {
+		t.Fatalf("submit %s: %v", windowID, err)
+	}
+	return job
+}
+
+// blockingJob 返回阻塞到上下文取消的任务函数，started 在任务开始执行时关闭。
+func blockingJob(started chan struct{}) func(context.Context) (rca.Result, error) {
+	return func(ctx context.Context) (rca.Result, error) {
+		close(started)
+		<-ctx.Done()
+		return rca.Result{}, ctx.Err()
+	}
+}
+
+func TestJobRegistryLifecycle(t *testing.T) {
+	jobs := rca.NewJobRegistry(time.Minute)
+
+	done := submitJob(t, jobs, "w-1", func(context.Context) (rca.Result, error) {
+		return rca.Result{EventCount: 2}, nil
+	})
+	if done.State != rca.JobPending || done.ID == "" {
+		t.Fatalf("expect a pending job with id, got %+v", done)
+	}
+	if job := waitJob(t, jobs, done.ID); job.State != rca.JobDone || job.Result == nil || job.Result.EventCount != 2 {
+		t.Fatalf("expect done job with result, got %+v", job)
+	}
+
+	failed := submitJob(t, jobs, "w-2", func(context.Context) (rca.Result, error) {
+		return rca.Result{}, errors.New("boom")
+	})
+	if job := waitJob(t, jobs, failed.ID); job.State != rca.JobFailed || job.Error != "boom" {
+		t.Fatalf("expect failed job, got %+v", job)
+	}
+
+	started := make(chan struct{})
+	blocked := submitJob(t, jobs, "w-3", blockingJob(started))
+	<-started
+	if !jobs.Cancel(blocked.ID) {
+		t.Fatalf("expect running job to be cancellable")
+	}
+	if job := waitJob(t, jobs, blocked.ID); job.State != rca.JobCancelled {
+		t.Fatalf("expect cancelled job, got %+v", job)
+	}
+	if jobs.Cancel(blocked.ID) || jobs.Cancel("missing") {
+		t.Fatalf("finished or unknown jobs must not be cancellable")
+	}
+}
+
+func TestJobRegistryEvictsFinishedJobs(t *testing.T) {
+	jobs := rca.NewJobRegistry(20 * time.Millisecond)
+	job := submitJob(t, jobs, "w-1", func(context.Context) (rca.Result, error) { return rca.Result{}, nil })
+	waitJob(t, jobs, job.ID)
+
+	time.Sleep(40 * time.Millisecond)
+	if _, ok := jobs.Get(job.ID); ok {
+		t.Fatalf("expect finished job to be evicted after ttl")
+	}
+}
+
+func TestJobRegistryRejectsPastMaxInFlight(t *testing.T) {
+	jobs := rca.NewJobRegistry(time.Minute, rca.WithMaxInFlight(1))
+	started := make(chan struct{})
+	first := submitJob(t, jobs, "w-1", blockingJob(started))
+	<-started
+
+	if _, err := jobs.Submit("w-2", func(context.Context) (rca.Result, error) { return rca.Result{}, nil }); !errors.Is(err, rca.ErrTooManyJobs) {
+		t.Fatalf("expect ErrTooManyJobs past the limit, got %v", err)
+	}
+
+	jobs.Cancel(first.ID)
+	waitJob(t, jobs, first.ID)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, err := jobs.Submit("w-3", func(context.Context) (rca.Result, error) { return rca.Result{}, nil })
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, rca.ErrTooManyJobs) || time.Now().After(deadline) {
+			t.Fatalf("expect slot to be released after the job finished, got %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestJobRegistryCloseCancelsRunningJobs(t *testing.T) {
+	jobs := rca.NewJobRegistry(time.Minute)
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	running := submitJob(t, jobs, "w-1", func(ctx context.Context) (rca.Result, error) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return rca.Result{}, ctx.Err()
+	})
+	<-started
+
+	jobs.Close()
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expect Close to cancel the running job context")
+	}
+	if job := waitJob(t, jobs, running.ID); job.State != rca.JobCancelled {
+		t.Fatalf("expect cancelled job after close, got %+v", job)
+	}
+	if _, err := jobs.Submit("w-2", func(context.Context) (rca.Result, error) { return rca.Result{}, nil }); !errors.Is(err, rca.ErrJobRegistryClosed) {
+		t.Fatalf("expect ErrJobRegistryClosed after close, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package rca_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/rca"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// sharedIPReader 模拟同一 IP 同时属于 VM 与宿主机的图。
+type sharedIPReader struct{}
+
+func (sharedIPReader) RunRead(_ context.Context, query string, _ map[string]any) ([]map[string]any, error) {
+	vm := neo4j.Node{Id: 1, Labels: []string{"VirtualMachine", "Compute"}, Props: map[string]any{"cmdb_key": "VM_1", "ip": "10.0.0.5"}}
+	host := neo4j.Node{Id: 2, Labels: []string{"HostMachine", "Machine", "Compute"}, Props: map[string]any{"cmdb_key": "HM_1", "ip": "10.0.0.5"}}
+	switch {
+	case strings.Contains(query, "RETURN DISTINCT labels(n)"):
+		return []map[string]any{
+			{"labels": []any{"VirtualMachine", "Compute"}},
+			{"labels": []any{"HostMachine", "Machine", "Compute"}},
+		}, nil
+	case strings.Contains(query, "MATCH (vm:VirtualMachine)\nWHERE"):
+		return []map[string]any{{"vm": vm, "host": nil}}, nil
+	case strings.Contains(query, "MATCH (host:HostMachine)\nWHERE"):
+		return []map[string]any{{"host": host}}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func TestGraphProviderScopesIPToExpectedLayer(t *testing.T) {
+	var conflicts []rca.LayerConflict
+	provider := rca.NewGraphProvider(sharedIPReader{}, rca.WithConflictReporter(func(c rca.LayerConflict) {
+		conflicts = append(conflicts, c)
+	}))
+
+	cases := []struct {
+		serverType rca.ServerType
+		expectKey  string
+	}{
+		{rca.ServerTypeVM, "VM_1"},
+		{rca.ServerTypeHost, "HM_1"},
+	}
+	for _, tc := range cases {
+		nodes, err := provider.ResolveEvent(context.Background(), rca.AlarmEvent{IP: "10.0.0.5", ServerType: tc.serverType})
+		if err != nil {
+			t.Fatalf("resolve %s: %v", tc.serverType, err)
+		}
+		if len(nodes) == 0 || nodes[0].Key != tc.expectKey {
+			t.Fatalf("server type %s expect %s, got %+v", tc.serverType, tc.expectKey, nodes)
+		}
+	}
+
+	if len(conflicts) != 2 {
+		t.Fatalf("expect conflict reported per event, got %d", len(conflicts))
+	}
+	if conflicts[0].Expected != rca.NodeTypeVirtualMachine || len(conflicts[0].Layers) != 2 {
+		t.Fatalf("unexpected conflict %+v", conflicts[0])
+	}
+}
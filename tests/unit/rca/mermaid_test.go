@@ -0,0 +1,57 @@
+package rca_test
+
+import (
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestRenderPathMermaidIsDeterministic(t *testing.T) {
+	host := rca.NodeRef{Key: "HM_1", Type: rca.NodeTypeHostMachine, Name: `hm "01"`}
+	vm1 := rca.NodeRef{Key: "VM_1", Type: rca.NodeTypeVirtualMachine, Name: "vm-01"}
+	vm2 := rca.NodeRef{Key: "VM_2", Type: rca.NodeTypeVirtualMachine, Name: "vm-02"}
+	app := rca.NodeRef{Key: "APP_1", Type: rca.NodeTypeApp, Name: "order"}
+	result := rca.Result{
+		Candidates: []rca.Candidate{{Node: host, Confidence: 0.9}, {Node: vm1, Confidence: 0.6}},
+		Paths: []rca.AlarmPath{{
+			Candidate: host,
+			Impacts: []rca.PathImpact{
+				{Node: vm1, Impacts: []rca.PathImpact{{Node: app, Events: []rca.AlarmEventRef{{ID: "e1"}, {ID: "e2"}}}}},
+				{Node: vm2, Events: []rca.AlarmEventRef{{ID: "e3"}}},
+			},
+		}},
+	}
+
+	want := strings.Join([]string{
+		"graph TD",
+		"  classDef high fill:#f66,stroke:#900,stroke-width:2px;",
+		"  classDef medium fill:#fc6,stroke:#960;",
+		"  classDef low fill:#ffc,stroke:#996;",
+		"  classDef impacted fill:#eee,stroke:#999;",
+		`  n0["hm #quot;01#quot;<br/>HostMachine"]`,
+		`  n1["vm-01<br/>VirtualMachine"]`,
+		`  n0 -->|"2 events"| n1`,
+		`  n2["order<br/>App"]`,
+		`  n1 -->|"2 events"| n2`,
+		`  n3["vm-02<br/>VirtualMachine"]`,
+		`  n0 -->|"1 events"| n3`,
+		"  class n0 high;",
+		"  class n1 medium;",
+		"  class n2 impacted;",
+		"  class n3 impacted;",
+		"",
+	}, "\n")
+	for i := 0; i < 3; i++ {
+		if got := rca.RenderPathMermaid(result); got != want {
+			t.Fatalf("unexpected mermaid output:\n%s\nwant:\n%s", got, want)
+		}
+	}
+}
+
+func TestRenderPathMermaidCountsAnalyzerEventsOnce(t *testing.T) {
+	chart := rca.RenderPathMermaid(chainResult(t))
+	if strings.Count(chart, `-->|"3 events"|`) != 2 || strings.Contains(chart, `-->|"2 events"|`) || strings.Contains(chart, `-->|"1 events"|`) {
+		t.Fatalf("expect every edge to count the alarm and its suppressed duplicates once:\n%s", chart)
+	}
+}
@@ -0,0 +1,53 @@
+package rca_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+// analyzeRecorder 只记录分析指标。
+type analyzeRecorder struct {
+	candidates []int
+	errs       []error
+}
+
+func (r *analyzeRecorder) ObserveCMDBFetch(time.Duration, error)     {}
+func (r *analyzeRecorder) ObserveUpserted(string, int, int)          {}
+func (r *analyzeRecorder) ObserveQuery(string, time.Duration, error) {}
+func (r *analyzeRecorder) ObserveSync(string, time.Duration, error)  {}
+func (r *analyzeRecorder) ObserveConnectivity(string, error)         {}
+func (r *analyzeRecorder) ObserveReconnect(string, error)            {}
+func (r *analyzeRecorder) SetSessionsInUse(string, int)              {}
+func (r *analyzeRecorder) ObserveQueryCache(bool)                    {}
+func (r *analyzeRecorder) ObserveChainCache(bool)                    {}
+func (r *analyzeRecorder) SetOrphans(string, int)                    {}
+
+func (r *analyzeRecorder) ObserveAnalyze(_ time.Duration, candidates int, err error) {
+	r.candidates = append(r.candidates, candidates)
+	r.errs = append(r.errs, err)
+}
+
+func TestAnalyzerRecordsMetrics(t *testing.T) {
+	recorder := &analyzeRecorder{}
+	analyzer, err := rca.NewAnalyzer(singleChainProvider(), rca.DefaultConfig(), rca.WithRecorder(recorder))
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	result, err := analyzer.Analyze(context.Background(), singleEvent())
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if _, err := analyzer.Analyze(context.Background(), nil); err == nil {
+		t.Fatalf("expect empty alarms rejected")
+	}
+
+	if len(recorder.candidates) != 2 || recorder.candidates[0] != len(result.Candidates) || recorder.errs[0] != nil {
+		t.Fatalf("unexpected successful analyze metrics %v / %v", recorder.candidates, recorder.errs)
+	}
+	if recorder.errs[1] == nil {
+		t.Fatalf("expect failed analyze recorded")
+	}
+}
@@ -0,0 +1,94 @@
+package rca_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/rca"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+type neighborhoodReader struct {
+	records []map[string]any
+	query   string
+	params  map[string]any
+}
+
+func (r *neighborhoodReader) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	r.query, r.params = query, params
+	return r.records, nil
+}
+
+func TestGraphNeighborhoodReaderConvertsRecord(t *testing.T) {
+	vm := neo4j.Node{ElementId: "e1", Labels: []string{"VirtualMachine"}, Props: map[string]any{"cmdb_key": "VM_1", "name": "vm-1"}}
+	host := neo4j.Node{ElementId: "e2", Labels: []string{"HostMachine"}, Props: map[string]any{"cmdb_key": "HM_1", "name": "host-1"}}
+	app := neo4j.Node{ElementId: "e3", Labels: []string{"App"}, Props: map[string]any{"cmdb_key": "APP_1", "name": "order"}}
+	reader := &neighborhoodReader{records: []map[string]any{{
+		"root":      vm,
+		"nodes":     []any{host, app},
+		"truncated": true,
+		"rels": []any{
+			neo4j.Relationship{ElementId: "r1", StartElementId: "e2", EndElementId: "e1", Type: "HOSTS_VM"},
+			neo4j.Relationship{ElementId: "r2", StartElementId: "e3", EndElementId: "e1", Type: "DEPLOYED_ON", Props: map[string]any{"source": "cmdb"}},
+		},
+	}}}
+
+	got, err := rca.NewGraphNeighborhoodReader(reader).Neighborhood(context.Background(), rca.NeighborhoodQuery{
+		Key: "VM_1", Depth: 2, RelTypes: []string{"HOSTS_VM", " ", "DEPLOYED_ON"}, MaxNodes: 10,
+	})
+	if err != nil {
+		t.Fatalf("neighborhood: %v", err)
+	}
+	if strings.Count(reader.query, "CALL {") != 2 {
+		t.Fatalf("expect one expansion per hop rendered, got query:\n%s", reader.query)
+	}
+	if types := reader.params["types"].([]string); len(types) != 2 || reader.params["limit"] != 10 || reader.params["key"] != "VM_1" {
+		t.Fatalf("unexpected params: %v", reader.params)
+	}
+	if got.Root.Key != "VM_1" || got.Depth != 2 || !got.Truncated {
+		t.Fatalf("unexpected root/depth/truncated: %+v", got)
+	}
+	if len(got.Nodes) != 3 || got.Nodes[0].Key != "APP_1" || got.Nodes[1].Key != "HM_1" || got.Nodes[2].Key != "VM_1" {
+		t.Fatalf("expect nodes including root sorted by key, got %+v", got.Nodes)
+	}
+	if len(got.Relationships) != 2 {
+		t.Fatalf("expect 2 relationships, got %+v", got.Relationships)
+	}
+	first := got.Relationships[0]
+	if first.Source != "APP_1" || first.Target != "VM_1" || first.Type != "DEPLOYED_ON" || first.Props["source"] != "cmdb" {
+		t.Fatalf("unexpected relationship: %+v", first)
+	}
+}
+
+func TestGraphNeighborhoodReaderDefaultsAndErrors(t *testing.T) {
+	reader := &neighborhoodReader{}
+	nr := rca.NewGraphNeighborhoodReader(reader)
+	if _, err := nr.Neighborhood(context.Background(), rca.NeighborhoodQuery{Key: "MISSING"}); !errors.Is(err, rca.ErrNodeNotFound) {
+		t.Fatalf("expect ErrNodeNotFound, got %v", err)
+	}
+	if strings.Count(reader.query, "CALL {") != 1 || reader.params["limit"] != rca.DefaultNeighborhoodNodes || reader.params["hop_limit"] != rca.DefaultNeighborhoodNodes+1 {
+		t.Fatalf("expect default depth and limit, got params %v", reader.params)
+	}
+	for _, q := range []rca.NeighborhoodQuery{{Key: ""}, {Key: "VM_1", Depth: rca.MaxNeighborhoodDepth + 1}, {Key: "VM_1", Depth: -1}} {
+		if _, err := nr.Neighborhood(context.Background(), q); err == nil || errors.Is(err, rca.ErrNodeNotFound) {
+			t.Fatalf("expect validation error for %+v, got %v", q, err)
+		}
+	}
+}
+
+func TestNeighborhoodExpandsHopByHopWithLimit(t *testing.T) {
+	reader := &neighborhoodReader{}
+	nr := rca.NewGraphNeighborhoodReader(reader)
+	_, _ = nr.Neighborhood(context.Background(), rca.NeighborhoodQuery{Key: "VM_1", Depth: rca.MaxNeighborhoodDepth, MaxNodes: 5})
+	if strings.Contains(reader.query, "*1..") {
+		t.Fatalf("expect no variable-length path expansion:\n%s", reader.query)
+	}
+	if n := strings.Count(reader.query, "LIMIT $hop_limit"); n != rca.MaxNeighborhoodDepth {
+		t.Fatalf("expect one limited expansion per hop, got %d:\n%s", n, reader.query)
+	}
+	if reader.params["hop_limit"] != 6 {
+		t.Fatalf("expect hop limit one above max nodes, got %v", reader.params)
+	}
+}
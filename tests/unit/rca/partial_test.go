@@ -0,0 +1,57 @@
+package rca_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestAnalyzeSkipsUnresolvedEvents(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	alarms := []rca.AlarmEvent{
+		{AppName: "a", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "cpu", OccurredAt: start},
+		{AppName: "ghost", IP: "10.9.9.9", ServerType: rca.ServerTypeVM, RuleName: "cpu", OccurredAt: start},
+	}
+
+	providers := map[string]rca.TopologyProvider{
+		"single": singleChainProvider(),
+		"batch":  rca.NewGraphProvider(&batchReader{}),
+	}
+	for name, provider := range providers {
+		analyzer, err := rca.NewAnalyzer(provider, rca.DefaultConfig())
+		if err != nil {
+			t.Fatalf("%s: new analyzer: %v", name, err)
+		}
+		events := alarms
+		if name == "batch" {
+			// batchReader 按应用名兜底命中，用宿主机告警制造解析失败
+			events = []rca.AlarmEvent{alarms[0], {IP: "10.9.9.9", ServerType: rca.ServerTypeHost, RuleName: "down", OccurredAt: start}}
+		}
+		result, err := analyzer.Analyze(context.Background(), events)
+		if err != nil {
+			t.Fatalf("%s: expect unresolved event skipped, got %v", name, err)
+		}
+		if len(result.Candidates) == 0 {
+			t.Fatalf("%s: expect candidates from resolvable events", name)
+		}
+		if len(result.ResolutionErrors) != 1 || result.ResolutionErrors[0].Event.IP != "10.9.9.9" || result.ResolutionErrors[0].Error == "" {
+			t.Fatalf("%s: unexpected resolution errors %+v", name, result.ResolutionErrors)
+		}
+		if len(result.UnexplainedEvents) != 1 || result.UnexplainedEvents[0].Reason != rca.UnexplainedUnresolved ||
+			result.UnexplainedEvents[0].EventID != result.ResolutionErrors[0].EventID {
+			t.Fatalf("%s: unexpected unexplained events %+v", name, result.UnexplainedEvents)
+		}
+
+		cfg := rca.DefaultConfig()
+		cfg.FailFast = true
+		strict, err := rca.NewAnalyzer(provider, cfg)
+		if err != nil {
+			t.Fatalf("%s: new analyzer: %v", name, err)
+		}
+		if _, err := strict.Analyze(context.Background(), events); err == nil {
+			t.Fatalf("%s: expect fail fast to abort the window", name)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package rca_test
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/rca"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+func testPartitionIndex() *rca.PartitionIndex {
+	return rca.NewPartitionIndex([]rca.Partition{
+		{Key: "NP_1", Name: "prod", Prefix: netip.MustParsePrefix("10.0.0.0/16")},
+		{Key: "NP_2", Name: "prod-db", Prefix: netip.MustParsePrefix("10.0.8.0/22")},
+		{Key: "NP_3", Name: "prod-db-core", Prefix: netip.MustParsePrefix("10.0.9.0/24")},
+		{Key: "NP_4", Name: "v6", Prefix: netip.MustParsePrefix("2001:db8::/32")},
+		{Key: "NP_5", Name: "v6-edge", Prefix: netip.MustParsePrefix("2001:db8:ab::/48")},
+		{Key: "NP_6", Name: "unset"},
+	})
+}
+
+func TestPartitionIndexLookupIPv4(t *testing.T) {
+	idx := testPartitionIndex()
+	if idx.Len() != 5 {
+		t.Fatalf("expect invalid prefix skipped, got %d partitions", idx.Len())
+	}
+	cases := map[string]string{
+		"10.0.1.1":         "NP_1",
+		"10.0.8.1":         "NP_2",
+		"10.0.9.77":        "NP_3",
+		" 10.0.11.255 ":    "NP_2",
+		"::ffff:10.0.9.77": "NP_3",
+	}
+	for ip, want := range cases {
+		part, ok := idx.Lookup(ip)
+		if !ok || part.Key != want {
+			t.Fatalf("Lookup(%q) = %+v, %v; want %s", ip, part, ok, want)
+		}
+	}
+	for _, ip := range []string{"10.1.0.1", "192.168.0.1", "", "not-an-ip"} {
+		if part, ok := idx.Lookup(ip); ok {
+			t.Fatalf("expect no partition for %q, got %+v", ip, part)
+		}
+	}
+}
+
+func TestPartitionIndexLookupIPv6(t *testing.T) {
+	idx := testPartitionIndex()
+	cases := map[string]string{
+		"2001:db8::1":          "NP_4",
+		"2001:DB8:0:0:0:0:0:1": "NP_4",
+		"2001:db8:ab:1::5":     "NP_5",
+	}
+	for ip, want := range cases {
+		part, ok := idx.Lookup(ip)
+		if !ok || part.Key != want {
+			t.Fatalf("Lookup(%q) = %+v, %v; want %s", ip, part, ok, want)
+		}
+	}
+	if part, ok := idx.Lookup("2001:db9::1"); ok {
+		t.Fatalf("expect no partition outside 2001:db8::/32, got %+v", part)
+	}
+}
+
+func TestPartitionIndexDuplicateNetworkIsStable(t *testing.T) {
+	idx := rca.NewPartitionIndex([]rca.Partition{
+		{Key: "NP_9", Prefix: netip.MustParsePrefix("10.0.0.0/24")},
+		{Key: "NP_7", Prefix: netip.MustParsePrefix("10.0.0.128/24")},
+	})
+	if part, ok := idx.Lookup("10.0.0.5"); !ok || part.Key != "NP_7" || idx.Len() != 1 {
+		t.Fatalf("expect smallest key kept for duplicate network, got %+v (len %d)", part, idx.Len())
+	}
+}
+
+// partitionReader 只认识网络分区层查询，模拟主机不在图中、但其 IP 落在分区 CIDR 内的情况。
+type partitionReader struct{ queries []string }
+
+func (r *partitionReader) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	r.queries = append(r.queries, query)
+	if strings.Contains(query, "RETURN np.cmdb_key AS key") {
+		return []map[string]any{
+			{"key": "NP_201", "name": "Production Zone", "idc": "M5", "cidr": "172.20.0.0/20"},
+			{"key": "NP_bad", "name": "broken", "idc": "M5", "cidr": "172.20.0.0/99"},
+		}, nil
+	}
+	if !strings.Contains(query, "MATCH (np:NetPartition)") {
+		return nil, nil
+	}
+	record := map[string]any{
+		"np":                neo4j.Node{Id: 3, Labels: []string{"NetPartition"}, Props: map[string]any{"cmdb_key": "NP_201", "name": "Production Zone"}},
+		"idc":               neo4j.Node{Id: 4, Labels: []string{"IDC"}, Props: map[string]any{"cmdb_key": "IDC_101", "name": "M5"}},
+		"np_host_count":     int64(4),
+		"idc_np_count":      int64(2),
+		"np_physical_count": int64(1),
+	}
+	if events, ok := params["events"].([]map[string]any); ok {
+		var out []map[string]any
+		for _, evt := range events {
+			if evt["cmdb_key"] != "NP_201" {
+				continue
+			}
+			rec := make(map[string]any, len(record)+1)
+			for k, v := range record {
+				rec[k] = v
+			}
+			rec["event"] = evt
+			out = append(out, rec)
+		}
+		return out, nil
+	}
+	if params["cmdb_key"] != "NP_201" {
+		return nil, nil
+	}
+	return []map[string]any{record}, nil
+}
+
+func loadTestPartitionIndex(t *testing.T, reader *partitionReader) *rca.PartitionIndex {
+	t.Helper()
+	var invalid []string
+	idx, err := rca.LoadPartitionIndex(context.Background(), reader, domain.DefaultSchema(), func(key, cidr string, err error) {
+		invalid = append(invalid, key)
+	})
+	if err != nil {
+		t.Fatalf("load index: %v", err)
+	}
+	if idx.Len() != 1 || len(invalid) != 1 || invalid[0] != "NP_bad" {
+		t.Fatalf("expect invalid cidr skipped, got len=%d invalid=%v", idx.Len(), invalid)
+	}
+	return idx
+}
+
+func TestGraphProviderFallsBackToPartitionByIP(t *testing.T) {
+	reader := &partitionReader{}
+	provider := rca.NewGraphProvider(reader, rca.WithPartitionIndex(loadTestPartitionIndex(t, reader)))
+	nodes, err := provider.ResolveEvent(context.Background(), rca.AlarmEvent{
+		ServerType: rca.ServerTypeHost, IP: "172.20.3.4", RuleName: "ping", OccurredAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("expect ip fallback to resolve the partition, got %v", err)
+	}
+	if got := nodeKeys(nodes); got != "NP_201,IDC_101" {
+		t.Fatalf("expect event attributed to its partition, got %s", got)
+	}
+
+	if _, err := provider.ResolveEvent(context.Background(), rca.AlarmEvent{
+		ServerType: rca.ServerTypeHost, IP: "10.9.9.9", RuleName: "ping", OccurredAt: time.Now(),
+	}); err == nil || err.Error() != "host 10.9.9.9 not found" {
+		t.Fatalf("expect not found outside every cidr, got %v", err)
+	}
+}
+
+func TestGraphProviderBatchFallsBackToPartitionByIP(t *testing.T) {
+	reader := &partitionReader{}
+	provider := rca.NewGraphProvider(reader, rca.WithPartitionIndex(loadTestPartitionIndex(t, reader)))
+	events := []rca.AlarmEvent{
+		{ServerType: rca.ServerTypeHost, IP: "10.9.9.9", RuleName: "ping", OccurredAt: time.Now()},
+		{ServerType: rca.ServerTypePhysical, IP: "172.20.15.1", RuleName: "ping", OccurredAt: time.Now()},
+	}
+	nodes, err := provider.ResolveEvents(context.Background(), events)
+	failed, ok := err.(rca.EventErrors)
+	if !ok || len(failed) != 1 || failed[0] == nil {
+		t.Fatalf("expect only the first event unresolved, got %v", err)
+	}
+	if got := nodeKeys(nodes[1]); got != "NP_201,IDC_101" {
+		t.Fatalf("expect second event attributed to its partition, got %s", got)
+	}
+}
+
+// refreshingPartitionReader 在 cidrs 为 false 时模拟同步前图中还没有分区 CIDR，err 不为空时分区查询失败。
+type refreshingPartitionReader struct {
+	partitionReader
+	cidrs bool
+	err   error
+}
+
+func (r *refreshingPartitionReader) RunRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	if strings.Contains(query, "RETURN np.cmdb_key AS key") {
+		if r.err != nil {
+			return nil, r.err
+		}
+		if !r.cidrs {
+			return nil, nil
+		}
+	}
+	return r.partitionReader.RunRead(ctx, query, params)
+}
+
+func TestPartitionRefresherSwapsIndexWithoutRebuildingProvider(t *testing.T) {
+	reader := &refreshingPartitionReader{}
+	refresher := rca.NewPartitionRefresher(reader, domain.DefaultSchema(), -1, nil)
+	if err := refresher.Refresh(context.Background()); err != nil {
+		t.Fatalf("initial refresh: %v", err)
+	}
+	provider := rca.NewGraphProvider(reader, rca.WithPartitionSource(refresher))
+	event := rca.AlarmEvent{ServerType: rca.ServerTypeHost, IP: "172.20.3.4", RuleName: "ping", OccurredAt: time.Now()}
+	if _, err := provider.ResolveEvent(context.Background(), event); err == nil {
+		t.Fatalf("expect no partition fallback before the cidr is synced")
+	}
+
+	// 同步写入分区 CIDR 后刷新，已构建的 provider 立即使用新索引
+	reader.cidrs = true
+	if err := refresher.Refresh(context.Background()); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	nodes, err := provider.ResolveEvent(context.Background(), event)
+	if err != nil || nodeKeys(nodes) != "NP_201,IDC_101" {
+		t.Fatalf("expect refreshed index used for ip fallback, got %v / %v", nodeKeys(nodes), err)
+	}
+
+	// 刷新失败时保留上一次的索引
+	reader.err = errors.New("neo4j down")
+	if err := refresher.Refresh(context.Background()); err == nil {
+		t.Fatalf("expect refresh error surfaced")
+	}
+	if idx := refresher.Index(); idx == nil || idx.Len() != 1 {
+		t.Fatalf("expect previous index kept after a failed refresh, got %+v", idx)
+	}
+}
+
+func TestPartitionRefresherStartRefreshesPeriodically(t *testing.T) {
+	reader := &refreshingPartitionReader{cidrs: true}
+	refresher := rca.NewPartitionRefresher(reader, domain.DefaultSchema(), 10*time.Millisecond, nil)
+	stop := refresher.Start(context.Background())
+	defer stop()
+	deadline := time.Now().Add(time.Second)
+	for refresher.Index() == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("expect index loaded by the background refresh")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func nodeKeys(nodes []rca.Node) string {
+	keys := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		keys = append(keys, node.Key)
+	}
+	return strings.Join(keys, ",")
+}
@@ -0,0 +1,104 @@
+package rca_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func impactNode(key string, typ rca.NodeType, events []rca.AlarmEventRef, children ...rca.PathImpact) rca.PathImpact {
+	return rca.PathImpact{Node: rca.NodeRef{Key: key, Type: typ}, Events: events, Impacts: children}
+}
+
+func TestMergePathsSharesSubBranch(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	e1 := rca.AlarmEventRef{ID: "e1", RuleName: "cpu", Occurred: now}
+	e2 := rca.AlarmEventRef{ID: "e2", RuleName: "mem", Occurred: now.Add(time.Minute)}
+	host := rca.NodeRef{Key: "HM_1", Type: rca.NodeTypeHostMachine}
+
+	// 两个窗口都经 VM_1 到达 HM_1，VM_1 下各自带出不同的应用
+	first := []rca.AlarmPath{{Candidate: host, Impacts: []rca.PathImpact{
+		impactNode("VM_1", rca.NodeTypeVirtualMachine, []rca.AlarmEventRef{e1},
+			impactNode("APP_1", rca.NodeTypeApp, []rca.AlarmEventRef{e1})),
+	}}}
+	second := []rca.AlarmPath{{Candidate: host, Impacts: []rca.PathImpact{
+		impactNode("VM_1", rca.NodeTypeVirtualMachine, []rca.AlarmEventRef{e2, e1},
+			impactNode("APP_1", rca.NodeTypeApp, []rca.AlarmEventRef{e1}),
+			impactNode("APP_2", rca.NodeTypeApp, []rca.AlarmEventRef{e2})),
+		impactNode("VM_2", rca.NodeTypeVirtualMachine, []rca.AlarmEventRef{e2}),
+	}}}
+
+	merged := rca.MergePaths(first, second)
+	if len(merged) != 1 || merged[0].Candidate.Key != "HM_1" {
+		t.Fatalf("expect a single HM_1 tree, got %+v", merged)
+	}
+	impacts := merged[0].Impacts
+	if len(impacts) != 2 || impacts[0].Node.Key != "VM_1" || impacts[1].Node.Key != "VM_2" {
+		t.Fatalf("expect VM_1 merged once next to VM_2, got %+v", impacts)
+	}
+	vm := impacts[0]
+	if len(vm.Events) != 2 || vm.Events[0].ID != "e1" || vm.Events[1].ID != "e2" {
+		t.Fatalf("expect VM_1 events deduped and time ordered, got %+v", vm.Events)
+	}
+	if len(vm.Impacts) != 2 || vm.Impacts[0].Node.Key != "APP_1" || vm.Impacts[1].Node.Key != "APP_2" {
+		t.Fatalf("expect shared APP_1 merged and APP_2 added, got %+v", vm.Impacts)
+	}
+	if len(vm.Impacts[0].Events) != 1 {
+		t.Fatalf("expect APP_1 event not duplicated, got %+v", vm.Impacts[0].Events)
+	}
+
+	// 入参不被修改
+	if len(first[0].Impacts[0].Events) != 1 || len(first[0].Impacts[0].Impacts) != 1 {
+		t.Fatalf("expect inputs untouched, got %+v", first)
+	}
+}
+
+func TestMergePathsAcrossOverlappingWindows(t *testing.T) {
+	app1 := topoNode("APP_1", rca.NodeTypeApp, nil)
+	app2 := topoNode("APP_2", rca.NodeTypeApp, nil)
+	vm := topoNode("VM_1", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 2})
+	host := topoNode("HM_1", rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 1})
+	provider := &chainProvider{chains: map[string][]rca.Node{
+		"10.0.0.1": {app1, vm, host},
+		"10.0.0.2": {app2, vm, host},
+	}}
+	analyzer, err := rca.NewAnalyzer(provider, rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	shared := rca.AlarmEvent{AppName: "app-1", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "cpu", OccurredAt: now}
+	later := rca.AlarmEvent{AppName: "app-2", IP: "10.0.0.2", ServerType: rca.ServerTypeVM, RuleName: "cpu", OccurredAt: now.Add(time.Minute)}
+	first, err := analyzer.Analyze(context.Background(), []rca.AlarmEvent{shared})
+	if err != nil {
+		t.Fatalf("analyze first window: %v", err)
+	}
+	second, err := analyzer.Analyze(context.Background(), []rca.AlarmEvent{shared, later})
+	if err != nil {
+		t.Fatalf("analyze second window: %v", err)
+	}
+
+	merged := rca.MergePaths(first.Paths, second.Paths)
+	rca.SortPaths(merged, rca.PathSortByKey)
+	seen := make(map[string]int)
+	for _, path := range merged {
+		seen[path.Candidate.Key]++
+		if path.Candidate.Key != "HM_1" {
+			continue
+		}
+		if len(path.Impacts) != 1 || len(path.Impacts[0].Events) != 2 || len(path.Impacts[0].Impacts) != 2 {
+			t.Fatalf("expect HM_1 tree to hold VM_1 once with both apps, got %+v", path.Impacts)
+		}
+	}
+	for key, n := range seen {
+		if n != 1 {
+			t.Fatalf("expect one tree per candidate, %s appears %d times", key, n)
+		}
+	}
+	if seen["HM_1"] != 1 {
+		t.Fatalf("HM_1 path missing: %+v", merged)
+	}
+}
@@ -0,0 +1,105 @@
+package rca_test
+
+import (
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func impactFixture() []rca.AlarmPath {
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	ref := func(id string, minute int) rca.AlarmEventRef {
+		return rca.AlarmEventRef{ID: id, Occurred: base.Add(time.Duration(minute) * time.Minute)}
+	}
+	impact := func(key string, events ...rca.AlarmEventRef) rca.PathImpact {
+		return rca.PathImpact{Node: rca.NodeRef{Key: key, Type: rca.NodeTypeVirtualMachine}, Events: events}
+	}
+	return []rca.AlarmPath{
+		{
+			Candidate: rca.NodeRef{Key: "HM_1", Type: rca.NodeTypeHostMachine},
+			Impacts: []rca.PathImpact{
+				impact("VM_a", ref("e1", 5)),
+				impact("VM_b", ref("e2", 1), ref("e3", 9), ref("e4", 9)),
+				impact("VM_c", ref("e5", 3), ref("e6", 4)),
+			},
+		},
+		{
+			Candidate: rca.NodeRef{Key: "HM_0", Type: rca.NodeTypeHostMachine},
+			Impacts: []rca.PathImpact{
+				impact("VM_d", ref("e7", 7)),
+			},
+		},
+	}
+}
+
+func impactKeys(path rca.AlarmPath) []string {
+	keys := make([]string, 0, len(path.Impacts))
+	for _, impact := range path.Impacts {
+		keys = append(keys, impact.Node.Key)
+	}
+	return keys
+}
+
+func TestSortPathsModes(t *testing.T) {
+	cases := []struct {
+		mode    rca.PathSort
+		paths   []string
+		impacts []string
+	}{
+		{rca.PathSortByKey, []string{"HM_0", "HM_1"}, []string{"VM_a", "VM_b", "VM_c"}},
+		{"", []string{"HM_0", "HM_1"}, []string{"VM_a", "VM_b", "VM_c"}},
+		{rca.PathSortByTime, []string{"HM_1", "HM_0"}, []string{"VM_b", "VM_c", "VM_a"}},
+		{rca.PathSortByImpactCount, []string{"HM_1", "HM_0"}, []string{"VM_b", "VM_c", "VM_a"}},
+	}
+	for _, tc := range cases {
+		paths := impactFixture()
+		rca.SortPaths(paths, tc.mode)
+		if paths[0].Candidate.Key != tc.paths[0] || paths[1].Candidate.Key != tc.paths[1] {
+			t.Fatalf("mode %q: unexpected path order %s, %s", tc.mode, paths[0].Candidate.Key, paths[1].Candidate.Key)
+		}
+		var host rca.AlarmPath
+		for _, p := range paths {
+			if p.Candidate.Key == "HM_1" {
+				host = p
+			}
+		}
+		got := impactKeys(host)
+		for i := range tc.impacts {
+			if got[i] != tc.impacts[i] {
+				t.Fatalf("mode %q: expect impacts %v, got %v", tc.mode, tc.impacts, got)
+			}
+		}
+	}
+}
+
+func TestByTimeDiffersFromByImpactCount(t *testing.T) {
+	paths := []rca.AlarmPath{{
+		Candidate: rca.NodeRef{Key: "HM_1"},
+		Impacts: []rca.PathImpact{
+			{Node: rca.NodeRef{Key: "VM_late"}, Events: []rca.AlarmEventRef{{ID: "a", Occurred: time.Unix(200, 0)}, {ID: "b", Occurred: time.Unix(300, 0)}}},
+			{Node: rca.NodeRef{Key: "VM_early"}, Events: []rca.AlarmEventRef{{ID: "c", Occurred: time.Unix(100, 0)}}},
+		},
+	}}
+	rca.SortPaths(paths, rca.PathSortByTime)
+	if got := impactKeys(paths[0]); got[0] != "VM_early" {
+		t.Fatalf("byTime should put earliest first, got %v", got)
+	}
+	rca.SortPaths(paths, rca.PathSortByImpactCount)
+	if got := impactKeys(paths[0]); got[0] != "VM_late" {
+		t.Fatalf("byImpactCount should put largest first, got %v", got)
+	}
+}
+
+func TestPathSortValidated(t *testing.T) {
+	cfg := rca.DefaultConfig()
+	cfg.PathSort = "byColor"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expect unknown path_sort to be rejected")
+	}
+	mode := rca.PathSortByTime
+	merged, err := rca.DefaultConfig().Merge(rca.ConfigOverride{PathSort: &mode})
+	if err != nil || merged.PathSort != rca.PathSortByTime {
+		t.Fatalf("merge path_sort: %v %v", merged.PathSort, err)
+	}
+}
@@ -0,0 +1,111 @@
+package rca_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+// priorityAnalyzer 构造两台宿主机，各自上面有 2 个告警应用，告警条数相同。
+func priorityAnalyzer(t *testing.T, weights map[string]float64) *rca.Analyzer {
+	t.Helper()
+	chains := make(map[string][]rca.Node)
+	for h, host := range []string{"HM_A", "HM_B"} {
+		hostNode := topoNode(host, rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeApp: 2})
+		for i := 1; i <= 2; i++ {
+			chains[fmt.Sprintf("10.0.%d.%d", h, i)] = []rca.Node{topoNode(fmt.Sprintf("APP_%d%d", h, i), rca.NodeTypeApp, nil), hostNode}
+		}
+	}
+	cfg := rca.DefaultConfig()
+	cfg.Hierarchy = []rca.NodeType{rca.NodeTypeApp, rca.NodeTypeHostMachine}
+	cfg.Layers[rca.NodeTypeHostMachine] = rca.LayerConfig{CoverageThreshold: 0.5, MinChildren: 1, Weights: rca.ScoreWeights{Coverage: 0.5, Impact: 0.5}}
+	cfg.PriorityWeights = weights
+	analyzer, err := rca.NewAnalyzer(&chainProvider{chains: chains}, cfg)
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	return analyzer
+}
+
+// priorityAlarms 让 HM_A 上的应用报 P1，HM_B 上的应用报 P4。
+func priorityAlarms() []rca.AlarmEvent {
+	var alarms []rca.AlarmEvent
+	for h, priority := range []string{"P1", "P4"} {
+		for i := 1; i <= 2; i++ {
+			alarms = append(alarms, rca.AlarmEvent{AppName: fmt.Sprintf("app-%d%d", h, i), IP: fmt.Sprintf("10.0.%d.%d", h, i), ServerType: rca.ServerTypeVM, RuleName: "down", Priority: priority, OccurredAt: time.Now()})
+		}
+	}
+	return alarms
+}
+
+func hostConfidence(t *testing.T, result rca.Result, key string) float64 {
+	t.Helper()
+	for _, cand := range result.Candidates {
+		if cand.Node.Key == key {
+			return cand.Confidence
+		}
+	}
+	t.Fatalf("candidate %s missing: %+v", key, result.Candidates)
+	return 0
+}
+
+func TestPriorityWeightsRankEqualCounts(t *testing.T) {
+	result, err := priorityAnalyzer(t, nil).Analyze(context.Background(), priorityAlarms())
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if a, b := hostConfidence(t, result, "HM_A"), hostConfidence(t, result, "HM_B"); a != b {
+		t.Fatalf("expect equal scores without priority weights, got %.3f vs %.3f", a, b)
+	}
+
+	result, err = priorityAnalyzer(t, map[string]float64{"P1": 4, "P4": 1}).Analyze(context.Background(), priorityAlarms())
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	a, b := hostConfidence(t, result, "HM_A"), hostConfidence(t, result, "HM_B")
+	// 覆盖率均为 1；影响面按 8/10 与 2/10 加权
+	if a != 0.9 || b != 0.6 {
+		t.Fatalf("expect P1 host to outrank P4 host, got %.3f vs %.3f", a, b)
+	}
+}
+
+func TestPriorityWeightsUnknownDefaultsToOne(t *testing.T) {
+	alarms := priorityAlarms()
+	for i := range alarms {
+		if alarms[i].Priority == "P4" {
+			alarms[i].Priority = "P9"
+		}
+	}
+	result, err := priorityAnalyzer(t, map[string]float64{"P1": 3}).Analyze(context.Background(), alarms)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if b := hostConfidence(t, result, "HM_B"); b != 0.5+0.5*2.0/8.0 {
+		t.Fatalf("expect unknown priority weighted as 1, got %.3f", b)
+	}
+}
+
+func TestConfigRejectsNonPositivePriorityWeight(t *testing.T) {
+	cfg := rca.DefaultConfig()
+	cfg.PriorityWeights = map[string]float64{"P1": 0}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expect zero priority weight rejected")
+	}
+	merged, err := rca.DefaultConfig().Merge(rca.ConfigOverride{PriorityWeights: map[string]float64{"P1": 2}})
+	if err != nil || merged.PriorityWeights["P1"] != 2 {
+		t.Fatalf("expect override to set priority weights, got %+v / %v", merged.PriorityWeights, err)
+	}
+}
+
+func TestAlertmanagerSeverityMapsToPriority(t *testing.T) {
+	events, err := rca.AdaptAlertmanager([]byte(`{"alerts":[{"status":"firing","labels":{"alertname":"down","ip":"10.0.0.1","severity":"P1"}}]}`))
+	if err != nil {
+		t.Fatalf("adapt: %v", err)
+	}
+	if len(events) != 1 || events[0].Priority != "P1" {
+		t.Fatalf("expect severity label mapped to priority, got %+v", events)
+	}
+}
@@ -0,0 +1,33 @@
+package rca_test
+
+import (
+	"context"
+	"fmt"
+
+	"cmdb2neo/internal/rca"
+)
+
+// chainProvider 根据事件 IP 返回预置的拓扑链路，用于脱离 Neo4j 的分析测试。
+type chainProvider struct {
+	chains    map[string][]rca.Node
+	instances map[string]int
+}
+
+func (p *chainProvider) ResolveEvent(_ context.Context, event rca.AlarmEvent) ([]rca.Node, error) {
+	chain, ok := p.chains[event.IP]
+	if !ok {
+		return nil, fmt.Errorf("unknown ip %s", event.IP)
+	}
+	return chain, nil
+}
+
+func (p *chainProvider) ListAppInstances(_ context.Context, appName string, _ string) (int, error) {
+	return p.instances[appName], nil
+}
+
+func topoNode(key string, typ rca.NodeType, childCounts map[rca.NodeType]int) rca.Node {
+	return rca.Node{
+		NodeRef:     rca.NodeRef{Key: key, Type: typ, Name: key},
+		ChildCounts: childCounts,
+	}
+}
@@ -0,0 +1,63 @@
+package rca_test
+
+import (
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestEveryHierarchyTypeHasResolveQuery(t *testing.T) {
+	columns := []string{"app", "vm", "host", "physical", "np", "idc", "svc", "vm_app_count", "host_vm_count", "np_host_count", "np_physical_count", "idc_np_count", "svc_app_count", "vm_app_weight", "host_vm_weight", "app_link_weight", "vm_link_weight"}
+	// 可选的 Container 层不在默认 hierarchy 中，但开启后同样需要模板
+	for _, nodeType := range append(rca.DefaultConfig().Hierarchy, rca.NodeTypeContainer, rca.NodeTypeService) {
+		query, ok := rca.ResolveQuery(nodeType)
+		if !ok {
+			t.Fatalf("missing resolve query for %s", nodeType)
+		}
+		if _, ok := rca.BatchResolveQuery(nodeType); !ok {
+			t.Fatalf("missing batch resolve query for %s", nodeType)
+		}
+		if strings.Contains(query, "{{") || !strings.Contains(query, "RETURN app, vm, host, physical, np, idc, svc") {
+			t.Fatalf("query for %s not fully rendered:\n%s", nodeType, query)
+		}
+		for _, col := range columns {
+			if !strings.Contains(query, col) {
+				t.Fatalf("query for %s missing column %s", nodeType, col)
+			}
+		}
+	}
+	if err := rca.DefaultConfig().Validate(); err != nil {
+		t.Fatalf("default hierarchy should validate against templates: %v", err)
+	}
+}
+
+func TestResolveQueriesKeepLayerScope(t *testing.T) {
+	cases := map[rca.NodeType]string{
+		rca.NodeTypeVirtualMachine:  "MATCH (vm:VirtualMachine)\nWHERE ($cmdb_key <> '' AND vm.cmdb_key = $cmdb_key",
+		rca.NodeTypeHostMachine:     "MATCH (host:HostMachine)\nWHERE ($cmdb_key <> '' AND host.cmdb_key = $cmdb_key",
+		rca.NodeTypePhysicalMachine: "MATCH (phy:PhysicalMachine)\nWHERE ($cmdb_key <> '' AND phy.cmdb_key = $cmdb_key",
+		rca.NodeTypeApp:             "MATCH (app:App)\nWHERE app.name = $name",
+	}
+	for nodeType, prefix := range cases {
+		query, _ := rca.ResolveQuery(nodeType)
+		if !strings.HasPrefix(query, prefix) {
+			t.Fatalf("query for %s should start with %q, got:\n%s", nodeType, prefix, query)
+		}
+	}
+	machines := map[rca.NodeType]string{rca.NodeTypeVirtualMachine: "vm", rca.NodeTypeHostMachine: "host", rca.NodeTypePhysicalMachine: "phy"}
+	for nodeType, v := range machines {
+		query, _ := rca.ResolveQuery(nodeType)
+		for _, cond := range []string{
+			"$cmdb_key = '' AND $ip <> '' AND " + v + ".ip = $ip",
+			"$cmdb_key = '' AND $ip = '' AND $hostname <> '' AND " + v + ".hostname = $hostname",
+		} {
+			if !strings.Contains(query, cond) {
+				t.Fatalf("query for %s missing fallback %q", nodeType, cond)
+			}
+		}
+	}
+	if _, ok := rca.ResolveQuery("Switch"); ok {
+		t.Fatalf("unexpected template for unknown node type")
+	}
+}
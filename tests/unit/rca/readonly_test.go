@@ -0,0 +1,69 @@
+package rca_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+type recordingStore struct {
+	saves   int
+	windows []string
+}
+
+func (s *recordingStore) Save(_ context.Context, windowID string, _ rca.Result) error {
+	s.saves++
+	s.windows = append(s.windows, windowID)
+	return nil
+}
+
+func singleChainProvider() *chainProvider {
+	return &chainProvider{chains: map[string][]rca.Node{
+		"10.0.0.1": {
+			topoNode("APP_1", rca.NodeTypeApp, nil),
+			topoNode("VM_1", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 1}),
+		},
+	}}
+}
+
+func singleEvent() []rca.AlarmEvent {
+	return []rca.AlarmEvent{{AppName: "app-1", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "cpu", OccurredAt: time.Now()}}
+}
+
+func TestAnalyzerReadOnlySkipsStore(t *testing.T) {
+	store := &recordingStore{}
+	analyzer, err := rca.NewAnalyzer(singleChainProvider(), rca.DefaultConfig(), rca.WithResultStore(store))
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+
+	if _, err := analyzer.AnalyzeWithOptions(context.Background(), singleEvent(), rca.AnalyzeOptions{WindowID: "w-1", ReadOnly: true}); err != nil {
+		t.Fatalf("analyze read-only: %v", err)
+	}
+	if store.saves != 0 {
+		t.Fatalf("expect no save in read-only call, got %d", store.saves)
+	}
+
+	if _, err := analyzer.AnalyzeWithOptions(context.Background(), singleEvent(), rca.AnalyzeOptions{WindowID: "w-2"}); err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if store.saves != 1 || store.windows[0] != "w-2" {
+		t.Fatalf("expect one save for w-2, got %v", store.windows)
+	}
+}
+
+func TestAnalyzerReadOnlyOptionNeverSaves(t *testing.T) {
+	store := &recordingStore{}
+	analyzer, err := rca.NewAnalyzer(singleChainProvider(), rca.DefaultConfig(), rca.WithResultStore(store), rca.WithReadOnly())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	if _, err := analyzer.AnalyzeWithOptions(context.Background(), singleEvent(), rca.AnalyzeOptions{WindowID: "w-1"}); err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if store.saves != 0 {
+		t.Fatalf("read-only analyzer must not save, got %d", store.saves)
+	}
+}
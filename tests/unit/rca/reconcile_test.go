@@ -0,0 +1,80 @@
+package rca_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestRootCausesLinkHostToAppOutage(t *testing.T) {
+	host := topoNode("HM_1", rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 2})
+	vm1 := topoNode("VM_1", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 1})
+	vm2 := topoNode("VM_2", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 1})
+	provider := &chainProvider{
+		chains: map[string][]rca.Node{
+			"10.0.0.1": {topoNode("APP_1", rca.NodeTypeApp, nil), vm1, host},
+			"10.0.0.2": {topoNode("APP_2", rca.NodeTypeApp, nil), vm2, host},
+		},
+		instances: map[string]int{"order": 2},
+	}
+	analyzer, err := rca.NewAnalyzer(provider, rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+
+	now := time.Now()
+	result, err := analyzer.Analyze(context.Background(), []rca.AlarmEvent{
+		{AppName: "order", Datacenter: "M5", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: now},
+		{AppName: "order", Datacenter: "M5", IP: "10.0.0.2", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: now},
+	})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if len(result.AppOutages) != 1 {
+		t.Fatalf("expect one app outage, got %+v", result.AppOutages)
+	}
+	if len(result.RootCauses) == 0 {
+		t.Fatalf("expect root causes")
+	}
+
+	top := result.RootCauses[0]
+	if top.Node == nil || top.Node.Key != "HM_1" {
+		t.Fatalf("expect host to rank first, got %+v", top)
+	}
+	if len(top.AppOutages) != 1 || top.AppOutages[0].AppName != "order" || top.AppOutages[0].Overlap != 1 {
+		t.Fatalf("expect order outage linked to host, got %+v", top.AppOutages)
+	}
+	for _, cause := range result.RootCauses[1:] {
+		if len(cause.AppOutages) != 0 {
+			t.Fatalf("outage must be linked once, also found on %+v", cause)
+		}
+	}
+}
+
+func TestRootCausesKeepUnexplainedOutage(t *testing.T) {
+	provider := &chainProvider{
+		chains:    map[string][]rca.Node{"10.0.0.1": {topoNode("APP_1", rca.NodeTypeApp, nil)}},
+		instances: map[string]int{"order": 1},
+	}
+	cfg := rca.DefaultConfig()
+	cfg.Layers[rca.NodeTypeApp] = rca.LayerConfig{CoverageThreshold: 1}
+	analyzer, err := rca.NewAnalyzer(provider, cfg)
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+
+	result, err := analyzer.Analyze(context.Background(), []rca.AlarmEvent{
+		{AppName: "order", Datacenter: "M5", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if len(result.Candidates) != 0 {
+		t.Fatalf("expect no topology candidates, got %+v", result.Candidates)
+	}
+	if len(result.RootCauses) != 1 || result.RootCauses[0].Node != nil || len(result.RootCauses[0].AppOutages) != 1 {
+		t.Fatalf("expect standalone outage root cause, got %+v", result.RootCauses)
+	}
+}
@@ -0,0 +1,132 @@
+package rca_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/rca"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+func renamedSchema(t *testing.T) domain.Schema {
+	t.Helper()
+	schema, err := domain.NewSchema(
+		map[string]string{domain.LabelVirtualMachine: "VM", domain.LabelApp: "Application"},
+		map[string]string{domain.RelAppDeploy: "INSTALLED_ON", domain.RelHostsVM: "HOSTS"},
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("new schema: %v", err)
+	}
+	return schema
+}
+
+// renamedGraphReader 模拟标签与关系类型改名后的图：只响应使用实际名称的查询，返回的节点也带实际标签。
+type renamedGraphReader struct {
+	queries []string
+}
+
+func (r *renamedGraphReader) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	r.queries = append(r.queries, query)
+	switch {
+	case strings.Contains(query, "MATCH (vm:VM)"):
+		return []map[string]any{{
+			"app":  neo4j.Node{Id: 1, Labels: []string{"Application"}, Props: map[string]any{"cmdb_key": "APP_1", "name": params["name"]}},
+			"vm":   neo4j.Node{Id: 2, Labels: []string{"VM", "Compute"}, Props: map[string]any{"cmdb_key": "VM_100", "name": "vm-100"}},
+			"host": neo4j.Node{Id: 3, Labels: []string{"HostMachine", "Compute"}, Props: map[string]any{"cmdb_key": "HM_10"}},
+		}}, nil
+	case strings.Contains(query, "MATCH (n:VM) WHERE n.ip = $ip"):
+		return []map[string]any{{"labels": []any{"VM", "Compute"}}}, nil
+	}
+	return nil, nil
+}
+
+func TestGraphProviderRendersQueriesWithSchema(t *testing.T) {
+	reader := &renamedGraphReader{}
+	provider := rca.NewGraphProvider(reader, rca.WithSchema(renamedSchema(t)))
+	nodes, err := provider.ResolveEvent(context.Background(), rca.AlarmEvent{
+		AppName: "order", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("resolve event: %v", err)
+	}
+	types := make(map[rca.NodeType]string, len(nodes))
+	for _, node := range nodes {
+		types[node.Type] = node.Key
+	}
+	if types[rca.NodeTypeVirtualMachine] != "VM_100" || types[rca.NodeTypeApp] != "APP_1" {
+		t.Fatalf("expect labels mapped back to canonical node types, got %+v", nodes)
+	}
+	query := reader.queries[0]
+	if !strings.Contains(query, "(app:Application)-[r1:INSTALLED_ON]->(vm)") || !strings.Contains(query, "[r2:HOSTS]") {
+		t.Fatalf("expect custom names in resolve query:\n%s", query)
+	}
+	if strings.Contains(query, ":DEPLOYED_ON]") || strings.Contains(query, ":VirtualMachine") {
+		t.Fatalf("expect default names replaced in resolve query:\n%s", query)
+	}
+
+	layers, err := provider.MatchLayers(context.Background(), "10.0.0.1")
+	if err != nil {
+		t.Fatalf("match layers: %v", err)
+	}
+	if len(layers) != 1 || layers[0] != rca.NodeTypeVirtualMachine {
+		t.Fatalf("expect canonical layer, got %v", layers)
+	}
+	// 包级 ResolveQuery 仍按默认 Schema 渲染
+	if query, _ := rca.ResolveQuery(rca.NodeTypeVirtualMachine); !strings.HasPrefix(query, "MATCH (vm:VirtualMachine)") {
+		t.Fatalf("expect default resolve query unchanged:\n%s", query)
+	}
+}
+
+func TestFootprintAndNeighborhoodUseSchema(t *testing.T) {
+	schema := renamedSchema(t)
+	var footprintQueries []string
+	footprint := rca.NewGraphFootprintReader(readerFunc(func(query string, params map[string]any) []map[string]any {
+		footprintQueries = append(footprintQueries, query)
+		if !strings.Contains(query, "(vm:VM)") {
+			return nil
+		}
+		return []map[string]any{{"instance": neo4j.Node{Labels: []string{"VM"}, Props: map[string]any{"cmdb_key": "VM_1"}}}}
+	}), rca.WithFootprintSchema(schema))
+	got, err := footprint.AppFootprint(context.Background(), "order", "")
+	if err != nil {
+		t.Fatalf("footprint: %v", err)
+	}
+	if got.Counts[rca.NodeTypeVirtualMachine] != 1 {
+		t.Fatalf("expect vm counted under canonical type, got %v", got.Counts)
+	}
+	if !strings.Contains(footprintQueries[0], "MATCH (app:Application {name: $app})-[d:INSTALLED_ON]->(vm:VM)") {
+		t.Fatalf("expect custom names in footprint query:\n%s", footprintQueries[0])
+	}
+
+	var params map[string]any
+	neighborhood := rca.NewGraphNeighborhoodReader(readerFunc(func(query string, p map[string]any) []map[string]any {
+		params = p
+		if !strings.Contains(query, "|VM|") {
+			t.Errorf("expect custom labels in neighborhood query:\n%s", query)
+		}
+		root := neo4j.Node{ElementId: "1", Labels: []string{"Application"}, Props: map[string]any{"cmdb_key": "APP_1"}}
+		vm := neo4j.Node{ElementId: "2", Labels: []string{"VM"}, Props: map[string]any{"cmdb_key": "VM_1"}}
+		rel := neo4j.Relationship{Type: "INSTALLED_ON", StartElementId: "1", EndElementId: "2"}
+		return []map[string]any{{"root": root, "nodes": []any{vm}, "rels": []any{rel}, "truncated": false}}
+	}), rca.WithNeighborhoodSchema(schema))
+	result, err := neighborhood.Neighborhood(context.Background(), rca.NeighborhoodQuery{Key: "APP_1", RelTypes: []string{domain.RelAppDeploy}})
+	if err != nil {
+		t.Fatalf("neighborhood: %v", err)
+	}
+	if types, _ := params["types"].([]string); len(types) != 1 || types[0] != "INSTALLED_ON" {
+		t.Fatalf("expect rel filter mapped to actual type, got %v", params["types"])
+	}
+	if result.Root.Type != rca.NodeTypeApp || len(result.Relationships) != 1 || result.Relationships[0].Type != domain.RelAppDeploy {
+		t.Fatalf("expect canonical names in result, got %+v", result)
+	}
+}
+
+type readerFunc func(query string, params map[string]any) []map[string]any
+
+func (f readerFunc) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	return f(query, params), nil
+}
@@ -0,0 +1,75 @@
+package rca_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+// serviceProvider 让 order 服务下 4 个应用分别部署在不同 VM 上。
+func serviceProvider() *chainProvider {
+	svc := topoNode("SVC_order", rca.NodeTypeService, map[rca.NodeType]int{rca.NodeTypeApp: 4})
+	chains := make(map[string][]rca.Node)
+	for i := 1; i <= 4; i++ {
+		chains[fmt.Sprintf("10.0.0.%d", i)] = []rca.Node{
+			topoNode(fmt.Sprintf("APP_%d", i), rca.NodeTypeApp, nil),
+			topoNode(fmt.Sprintf("VM_%d", i), rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 2}),
+			svc,
+		}
+	}
+	return &chainProvider{chains: chains}
+}
+
+func serviceAlarms(n int) []rca.AlarmEvent {
+	var alarms []rca.AlarmEvent
+	for i := 1; i <= n; i++ {
+		alarms = append(alarms, rca.AlarmEvent{AppName: fmt.Sprintf("app-%d", i), IP: fmt.Sprintf("10.0.0.%d", i), ServerType: rca.ServerTypeVM, RuleName: "5xx", OccurredAt: time.Now()})
+	}
+	return alarms
+}
+
+func TestServiceLayerAggregatesApps(t *testing.T) {
+	cfg := rca.DefaultConfig()
+	cfg.Hierarchy = append(cfg.Hierarchy, rca.NodeTypeService)
+	cfg.Layers[rca.NodeTypeService] = rca.LayerConfig{CoverageThreshold: 0.6, MinChildren: 1, Weights: rca.ScoreWeights{Coverage: 1}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	analyzer, err := rca.NewAnalyzer(serviceProvider(), cfg)
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	result, err := analyzer.Analyze(context.Background(), serviceAlarms(3))
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	for _, cand := range result.Candidates {
+		if cand.Node.Key != "SVC_order" {
+			continue
+		}
+		if cand.Coverage != 0.75 || len(cand.Explained) != 3 {
+			t.Fatalf("expect service covering 3 of 4 apps, got %+v", cand)
+		}
+		return
+	}
+	t.Fatalf("service candidate missing: %+v", result.Candidates)
+}
+
+func TestServiceLayerIgnoredUnlessConfigured(t *testing.T) {
+	analyzer, err := rca.NewAnalyzer(serviceProvider(), rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	result, err := analyzer.Analyze(context.Background(), serviceAlarms(3))
+	if err != nil {
+		t.Fatalf("service nodes must not break the default hierarchy: %v", err)
+	}
+	for _, cand := range result.Candidates {
+		if cand.Node.Type == rca.NodeTypeService {
+			t.Fatalf("unexpected service candidate without the layer configured: %+v", cand)
+		}
+	}
+}
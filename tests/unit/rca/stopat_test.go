@@ -0,0 +1,56 @@
+package rca_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestStopAtSkipsUpperLayers(t *testing.T) {
+	np := topoNode("NP_1", rca.NodeTypeNetPartition, map[rca.NodeType]int{rca.NodeTypeHostMachine: 1})
+	idc := topoNode("IDC_1", rca.NodeTypeIDC, map[rca.NodeType]int{rca.NodeTypeNetPartition: 1})
+	host := topoNode("HM_1", rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 1})
+	provider := &chainProvider{chains: map[string][]rca.Node{
+		"10.0.0.1": {topoNode("VM_1", rca.NodeTypeVirtualMachine, nil), host, np, idc},
+	}}
+	alarms := []rca.AlarmEvent{{IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: time.Now()}}
+
+	cfg := rca.DefaultConfig()
+	cfg.StopAt = rca.NodeTypeNetPartition
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	analyzer, err := rca.NewAnalyzer(provider, cfg)
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	result, err := analyzer.Analyze(context.Background(), alarms)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	var sawPartition bool
+	for _, cand := range result.Candidates {
+		if cand.Node.Type == rca.NodeTypeIDC {
+			t.Fatalf("IDC must not be evaluated above stop_at: %+v", result.Candidates)
+		}
+		sawPartition = sawPartition || cand.Node.Key == "NP_1"
+	}
+	if !sawPartition {
+		t.Fatalf("expect the stop_at layer itself to remain a candidate: %+v", result.Candidates)
+	}
+}
+
+func TestStopAtMustBeInHierarchy(t *testing.T) {
+	cfg := rca.DefaultConfig()
+	cfg.Hierarchy = []rca.NodeType{rca.NodeTypeApp, rca.NodeTypeVirtualMachine, rca.NodeTypeHostMachine}
+	cfg.StopAt = rca.NodeTypeIDC
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expect error when stop_at is outside the hierarchy")
+	}
+	stop := rca.NodeTypeVirtualMachine
+	if _, err := cfg.Merge(rca.ConfigOverride{StopAt: &stop}); err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+}
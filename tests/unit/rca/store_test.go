@@ -0,0 +1,58 @@
+package rca_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/rca"
+)
+
+// memoryGraph 按 window_id 保存写入参数，读取时按 Load 的返回列回放。
+type memoryGraph struct {
+	nodes map[string]map[string]any
+}
+
+func (g *memoryGraph) RunWrite(_ context.Context, query string, params map[string]any) error {
+	if !strings.Contains(query, "MERGE (r:RCAResult") {
+		return errors.New("unexpected write")
+	}
+	g.nodes[params["window_id"].(string)] = params
+	return nil
+}
+
+func (g *memoryGraph) RunRead(_ context.Context, _ string, params map[string]any) ([]map[string]any, error) {
+	node, ok := g.nodes[params["window_id"].(string)]
+	if !ok {
+		return nil, nil
+	}
+	return []map[string]any{{"created_at": node["created_at"], "event_count": int64(node["event_count"].(int)), "payload": node["payload"]}}, nil
+}
+
+func TestAnalyzerPersistsResultForWindow(t *testing.T) {
+	graph := &memoryGraph{nodes: map[string]map[string]any{}}
+	store := rca.NewGraphResultStore(graph)
+	analyzer, err := rca.NewAnalyzer(singleChainProvider(), rca.DefaultConfig(), rca.WithResultStore(store))
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	result, err := analyzer.AnalyzeWithOptions(context.Background(), singleEvent(), rca.AnalyzeOptions{WindowID: "w-1"})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+
+	stored, err := store.Load(context.Background(), "w-1")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if stored.WindowID != "w-1" || stored.CreatedAt.IsZero() || stored.EventCount != len(singleEvent()) {
+		t.Fatalf("unexpected stored metadata: %+v", stored)
+	}
+	if len(stored.Result.Candidates) != len(result.Candidates) || stored.Result.EventCount != result.EventCount {
+		t.Fatalf("stored result differs from analysis: %+v vs %+v", stored.Result, result)
+	}
+	if _, err := store.Load(context.Background(), "w-2"); !errors.Is(err, rca.ErrResultNotFound) {
+		t.Fatalf("expect ErrResultNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package rca_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+// stormEvents 在 10.0.0.1 上每秒产生一条 ping 告警，共 n 条。
+func stormEvents(n int) []rca.AlarmEvent {
+	base := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	events := make([]rca.AlarmEvent, 0, n)
+	for i := 0; i < n; i++ {
+		events = append(events, rca.AlarmEvent{AppName: "app-1", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "ping", OccurredAt: base.Add(time.Duration(i) * time.Second)})
+	}
+	return events
+}
+
+func TestFilterStormsSuppressesExcess(t *testing.T) {
+	events := append(stormEvents(10), rca.AlarmEvent{IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "disk", OccurredAt: time.Date(2024, 3, 1, 10, 0, 5, 0, time.UTC)})
+	filter := rca.StormFilter{Default: rca.StormRule{Threshold: 3, Window: time.Minute}}
+
+	kept := rca.FilterStorms(events, filter)
+	var ping, disk, suppressed int
+	for _, evt := range kept {
+		switch evt.RuleName {
+		case "ping":
+			ping++
+		case "disk":
+			disk++
+		}
+		suppressed += evt.Suppressed
+	}
+	if ping != 3 || disk != 1 || suppressed != 7 {
+		t.Fatalf("expect 3 ping + 1 disk kept with 7 suppressed, got ping=%d disk=%d suppressed=%d", ping, disk, suppressed)
+	}
+
+	// 规则级阈值优先于默认值
+	filter.Rules = map[string]rca.StormRule{"ping": {}}
+	if kept := rca.FilterStorms(events, filter); len(kept) != len(events) {
+		t.Fatalf("expect rule override to disable the filter for ping, got %d events", len(kept))
+	}
+}
+
+func TestStormSuppressionVisibleInMetrics(t *testing.T) {
+	cfg := rca.DefaultConfig()
+	cfg.StormFilter = rca.StormFilter{ServerTypes: map[rca.ServerType]rca.StormRule{rca.ServerTypeVM: {Threshold: 2, Window: time.Minute}}}
+	analyzer, err := rca.NewAnalyzer(singleChainProvider(), cfg)
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	result, err := analyzer.Analyze(context.Background(), stormEvents(12))
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	for _, cand := range result.Candidates {
+		if cand.Node.Key == "VM_1" {
+			if cand.Metrics.Suppressed != 10 {
+				t.Fatalf("expect 10 suppressed alarms in metrics, got %+v", cand.Metrics)
+			}
+			return
+		}
+	}
+	t.Fatalf("VM candidate missing: %+v", result.Candidates)
+}
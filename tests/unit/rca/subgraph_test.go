@@ -0,0 +1,124 @@
+package rca_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/rca"
+	"cmdb2neo/tests/testdata"
+)
+
+// fixtureChains 按 tests/unit 下的 JSON 快照为每个告警 IP 组装 应用→VM→宿主机→分区→IDC 链路，
+// 各层的子节点数同样取自快照。
+func fixtureChains(t *testing.T, events []rca.AlarmEvent) *chainProvider {
+	t.Helper()
+	snapshot := testdata.LoadSnapshotFromJSON(t)
+
+	appsByIP := make(map[string]int)
+	vmsByHost := make(map[string]int)
+	hostsByNP := make(map[string]int)
+	npsByIDC := make(map[string]int)
+	for _, app := range snapshot.Apps {
+		appsByIP[app.Ip]++
+	}
+	for _, vm := range snapshot.VirtualMachines {
+		vmsByHost[vm.HostIp]++
+	}
+	for _, host := range snapshot.HostMachines {
+		hostsByNP[host.NetworkPartion]++
+	}
+	for _, np := range snapshot.NetworkPartitions {
+		npsByIDC[np.Idc]++
+	}
+
+	chains := make(map[string][]rca.Node, len(events))
+	for _, evt := range events {
+		var chain []rca.Node
+		for _, app := range snapshot.Apps {
+			if app.Ip == evt.IP && app.Name == evt.AppName {
+				chain = append(chain, topoNode(domain.MakeKey(domain.PrefixApp, app.KeyID()), rca.NodeTypeApp, nil))
+			}
+		}
+		var hostIP string
+		for _, vm := range snapshot.VirtualMachines {
+			if vm.Ip == evt.IP {
+				hostIP = vm.HostIp
+				chain = append(chain, topoNode(domain.MakeKey(domain.PrefixVirtual, vm.Id), rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: appsByIP[vm.Ip]}))
+			}
+		}
+		for _, host := range snapshot.HostMachines {
+			if host.Ip != hostIP {
+				continue
+			}
+			chain = append(chain, topoNode(domain.MakeKey(domain.PrefixHostMachine, host.Id), rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: vmsByHost[host.Ip]}))
+			for _, np := range snapshot.NetworkPartitions {
+				if strconv.Itoa(np.Id) == host.NetworkPartion {
+					chain = append(chain, topoNode(domain.MakeKey(domain.PrefixNetPartition, np.Id), rca.NodeTypeNetPartition, map[rca.NodeType]int{rca.NodeTypeHostMachine: hostsByNP[host.NetworkPartion]}))
+				}
+			}
+			for _, idc := range snapshot.IDCs {
+				if strconv.Itoa(idc.Id) == host.Idc {
+					chain = append(chain, topoNode(domain.MakeKey(domain.PrefixIDC, idc.Id), rca.NodeTypeIDC, map[rca.NodeType]int{rca.NodeTypeNetPartition: npsByIDC[host.Idc]}))
+				}
+			}
+		}
+		if len(chain) != 5 {
+			t.Fatalf("fixture chain for %s is incomplete: %+v", evt.IP, chain)
+		}
+		chains[evt.IP] = chain
+	}
+	return &chainProvider{chains: chains}
+}
+
+func TestAnalyzerCapturesResolvedSubgraph(t *testing.T) {
+	events := loadAlarmEvents(t)
+	provider := fixtureChains(t, events)
+	analyzer, err := rca.NewAnalyzer(provider, rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+
+	plain, err := analyzer.Analyze(context.Background(), events)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if plain.ResolvedNodes != nil || plain.ResolvedEdges != nil {
+		t.Fatalf("subgraph must be opt-in")
+	}
+
+	result, err := analyzer.AnalyzeWithOptions(context.Background(), events, rca.AnalyzeOptions{CaptureTopology: true})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	captured := make(map[string]bool, len(result.ResolvedNodes))
+	for _, node := range result.ResolvedNodes {
+		captured[node.Key] = true
+	}
+	nodes := make(map[string]bool)
+	edges := make(map[[2]string]bool)
+	for _, chain := range provider.chains {
+		for i, node := range chain {
+			nodes[node.Key] = true
+			if !captured[node.Key] {
+				t.Fatalf("node %s missing from captured subgraph", node.Key)
+			}
+			if i > 0 {
+				edges[[2]string{chain[i-1].Key, node.Key}] = true
+			}
+		}
+	}
+	if len(result.ResolvedNodes) != len(nodes) {
+		t.Fatalf("expect %d distinct nodes, got %d", len(nodes), len(result.ResolvedNodes))
+	}
+	// 两个告警应用位于同一宿主机的两台 VM 上，链路在宿主机处汇合
+	if len(result.ResolvedEdges) != len(edges) {
+		t.Fatalf("expect %d distinct edges, got %d: %+v", len(edges), len(result.ResolvedEdges), result.ResolvedEdges)
+	}
+	for _, edge := range result.ResolvedEdges {
+		if !edges[[2]string{edge.Child, edge.Parent}] {
+			t.Fatalf("captured edge %+v is not on any fixture chain", edge)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package rca_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+// leadFixture 构造宿主机自身告警与其下 VM 告警相差 hostOffset 的拓扑。
+func leadFixture(hostOffset time.Duration) *rca.TopoNode {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	host := rca.NewTopoNode(topoNode("HM_1", rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 1}))
+	vm := rca.NewTopoNode(topoNode("VM_1", rca.NodeTypeVirtualMachine, nil))
+	host.AttachChild(vm)
+
+	own := rca.AlarmEventRef{ID: "host-down", NodeType: rca.NodeTypeHostMachine, Occurred: start.Add(hostOffset)}
+	host.AddEvent(own.ID, own)
+	for i, offset := range []time.Duration{time.Minute, 2 * time.Minute} {
+		ref := rca.AlarmEventRef{ID: []string{"vm-cpu", "vm-mem"}[i], NodeType: rca.NodeTypeVirtualMachine, Occurred: start.Add(offset)}
+		vm.AddEvent(ref.ID, ref)
+		host.AddEvent(ref.ID, rca.AlarmEventRef{ID: ref.ID, NodeType: rca.NodeTypeHostMachine, Occurred: ref.Occurred})
+		host.AddImpact(vm, ref)
+	}
+	return host
+}
+
+func TestTimeLeadRewardsEarlierParentAlarms(t *testing.T) {
+	// 宿主机告警早于首个 VM 告警 1 分钟，总跨度 2 分钟
+	if lead := leadFixture(0).TimeLead(); math.Abs(lead-0.5) > 1e-9 {
+		t.Fatalf("expect lead 0.5, got %v", lead)
+	}
+	if lead := leadFixture(3 * time.Minute).TimeLead(); lead != 0 {
+		t.Fatalf("expect no lead when parent alarms after children, got %v", lead)
+	}
+
+	weights := rca.ScoreWeights{Coverage: 0.5, TimeLead: 0.4}
+	early := leadFixture(0).ComputeScore(weights, 0)
+	late := leadFixture(3*time.Minute).ComputeScore(weights, 0)
+	if early.TimeLead != 0.5 || math.Abs(early.RawScore-0.7) > 1e-9 {
+		t.Fatalf("expect time lead folded into raw score, got %+v", early)
+	}
+	if early.RawScore <= late.RawScore {
+		t.Fatalf("expect earlier parent to outscore late one, got %v <= %v", early.RawScore, late.RawScore)
+	}
+}
+
+func TestTimeLeadZeroWithoutOwnAlarms(t *testing.T) {
+	vm := rca.NewTopoNode(topoNode("VM_1", rca.NodeTypeVirtualMachine, nil))
+	vm.AddEvent("vm-cpu", rca.AlarmEventRef{ID: "vm-cpu", Occurred: time.Now()})
+	if lead := vm.TimeLead(); lead != 0 {
+		t.Fatalf("expect leaf without children to have no lead, got %v", lead)
+	}
+}
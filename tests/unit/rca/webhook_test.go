@@ -0,0 +1,128 @@
+package rca_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestAdaptAlertmanagerSamplePayload(t *testing.T) {
+	payload, err := os.ReadFile("alertmanager_webhook.json")
+	if err != nil {
+		t.Fatalf("read sample: %v", err)
+	}
+	adapter, ok := rca.DefaultAdapters().Lookup("Alertmanager")
+	if !ok {
+		t.Fatalf("alertmanager adapter not registered")
+	}
+	events, err := adapter.Adapt(payload)
+	if err != nil {
+		t.Fatalf("adapt: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expect one event per alert, got %d", len(events))
+	}
+
+	node := events[0]
+	if node.RuleName != "NodeDown" || node.IP != "10.20.1.15" || node.Datacenter != "M5" || node.ServerType != rca.ServerTypeHost || node.Healthy {
+		t.Fatalf("unexpected node alert mapping: %+v", node)
+	}
+	if want := time.Date(2025, 3, 4, 8, 15, 30, 123000000, time.UTC); !node.OccurredAt.Equal(want) {
+		t.Fatalf("expect startsAt as occurred_at, got %v", node.OccurredAt)
+	}
+
+	app := events[1]
+	if app.AppName != "order-service" || app.IP != "" || app.Hostname != "order-7d9c5b8f6-x2kqp" || app.RuleName != "HTTPErrorRateHigh" {
+		t.Fatalf("expect non-IP instance kept as hostname, got %+v", app)
+	}
+
+	resolved := events[2]
+	if !resolved.Healthy || resolved.IP != "10.20.3.7" || resolved.ServerType != rca.ServerTypeVM {
+		t.Fatalf("expect resolved alert as healthy signal, got %+v", resolved)
+	}
+	if want := time.Date(2025, 3, 4, 8, 10, 0, 0, time.UTC); !resolved.OccurredAt.Equal(want) {
+		t.Fatalf("expect endsAt as occurred_at for resolved alerts, got %v", resolved.OccurredAt)
+	}
+}
+
+func TestAdaptZabbixProblemAndRecovery(t *testing.T) {
+	payload := []byte(`[
+		{"event_id":"101","trigger_name":"Zabbix agent is not available","host_name":"m5-host-01","host_ip":"10.20.1.15",
+		 "event_value":"1","event_date":"2025.03.04","event_time":"08:15:30","tags":{"idc":"M5","server_type":"1"}},
+		{"event_id":"102","event_name":"High CPU","host":"m5-vm-07","host_ip":"10.20.3.7","event_value":"0","tags":{"app":"order"}}
+	]`)
+	events, err := rca.AdaptZabbix(payload)
+	if err != nil {
+		t.Fatalf("adapt: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expect 2 events, got %d", len(events))
+	}
+	problem := events[0]
+	if problem.RuleName != "Zabbix agent is not available" || problem.IP != "10.20.1.15" || problem.Hostname != "m5-host-01" ||
+		problem.Datacenter != "M5" || problem.ServerType != rca.ServerTypeHost || problem.Healthy {
+		t.Fatalf("unexpected problem mapping: %+v", problem)
+	}
+	if want := time.Date(2025, 3, 4, 8, 15, 30, 0, time.Local); !problem.OccurredAt.Equal(want) {
+		t.Fatalf("expect event date/time parsed, got %v", problem.OccurredAt)
+	}
+	if recovery := events[1]; !recovery.Healthy || recovery.AppName != "order" || recovery.RuleName != "High CPU" {
+		t.Fatalf("expect recovery as healthy signal, got %+v", recovery)
+	}
+
+	single, err := rca.AdaptZabbix([]byte(`{"event_id":"103","trigger_name":"ping","host_ip":"10.0.0.1"}`))
+	if err != nil || len(single) != 1 || single[0].IP != "10.0.0.1" {
+		t.Fatalf("expect single object accepted, got %+v, %v", single, err)
+	}
+	if _, err := rca.AdaptZabbix([]byte(`{"event_id":"104","event_date":"04/03/2025","event_time":"08:00"}`)); err == nil {
+		t.Fatalf("expect invalid zabbix time rejected")
+	}
+}
+
+func TestAdaptGenericAliases(t *testing.T) {
+	events, err := rca.AdaptGeneric([]byte(`{"alerts":[
+		{"alertname":"down","ip":"10.0.0.1","idc":"M5","server_type":2,"occurred_at":1741075200},
+		{"rule":"latency","app":"order","instance":"10.0.0.2:8080","status":"resolved","time":"2025-03-04T08:00:00Z"}
+	]}`))
+	if err != nil {
+		t.Fatalf("adapt: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expect 2 events, got %+v", events)
+	}
+	first := events[0]
+	if first.RuleName != "down" || first.IP != "10.0.0.1" || first.Datacenter != "M5" || first.ServerType != rca.ServerTypeVM || first.OccurredAt.Unix() != 1741075200 {
+		t.Fatalf("unexpected first event: %+v", first)
+	}
+	second := events[1]
+	if second.RuleName != "latency" || second.AppName != "order" || second.IP != "10.0.0.2" || !second.Healthy {
+		t.Fatalf("unexpected second event: %+v", second)
+	}
+
+	if events, err := rca.AdaptGeneric([]byte(`{"events":[]}`)); err != nil || len(events) != 0 {
+		t.Fatalf("expect empty wrapper to yield no events, got %+v, %v", events, err)
+	}
+	if _, err := rca.AdaptGeneric([]byte(`[{"rule":"x","occurred_at":"yesterday"}]`)); err == nil {
+		t.Fatalf("expect invalid time rejected")
+	}
+}
+
+func TestAdaptersRegisterCustomSource(t *testing.T) {
+	adapters := rca.NewAdapters()
+	adapters.Register(" Grafana ", rca.AdapterFunc(func([]byte) ([]rca.AlarmEvent, error) {
+		return []rca.AlarmEvent{{RuleName: "custom"}}, nil
+	}))
+	adapters.Register("nil", nil)
+	if got := adapters.Sources(); len(got) != 1 || got[0] != "grafana" {
+		t.Fatalf("expect only grafana registered, got %v", got)
+	}
+	adapter, ok := adapters.Lookup("GRAFANA")
+	if !ok {
+		t.Fatalf("expect case-insensitive lookup")
+	}
+	if events, _ := adapter.Adapt(nil); len(events) != 1 || events[0].RuleName != "custom" {
+		t.Fatalf("unexpected events %+v", events)
+	}
+}
@@ -0,0 +1,101 @@
+package rca_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+// weightedChain 返回 APP_1 -> VM_1 -> HM_1 链路，宿主机上的两台虚拟机中 VM_1 的 HOSTS_VM.weight 为 3、另一台为 1。
+func weightedChain() *chainProvider {
+	vm := topoNode("VM_1", rca.NodeTypeVirtualMachine, map[rca.NodeType]int{rca.NodeTypeApp: 1})
+	vm.LinkWeight = 3
+	host := topoNode("HM_1", rca.NodeTypeHostMachine, map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 2})
+	host.ChildWeights = map[rca.NodeType]float64{rca.NodeTypeVirtualMachine: 4}
+	return &chainProvider{chains: map[string][]rca.Node{
+		"10.0.0.1": {topoNode("APP_1", rca.NodeTypeApp, nil), vm, host},
+	}}
+}
+
+func TestWeightedCoverageUsesRelationshipWeights(t *testing.T) {
+	events := []rca.AlarmEvent{{AppName: "a", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}}
+	cfg := rca.DefaultConfig()
+	cfg.Hierarchy = []rca.NodeType{rca.NodeTypeApp, rca.NodeTypeVirtualMachine, rca.NodeTypeHostMachine}
+
+	// 按数量计算只覆盖一半的虚拟机，低于宿主机层 0.6 的阈值
+	for _, cand := range analyzeWith(t, weightedChain(), cfg, events).Candidates {
+		if cand.Node.Key == "HM_1" {
+			t.Fatalf("expect host below threshold without weighting, got %+v", cand)
+		}
+	}
+
+	cfg.WeightedCoverage = true
+	var host *rca.Candidate
+	result := analyzeWith(t, weightedChain(), cfg, events)
+	for i := range result.Candidates {
+		if result.Candidates[i].Node.Key == "HM_1" {
+			host = &result.Candidates[i]
+		}
+	}
+	if host == nil || host.Coverage != 0.75 {
+		t.Fatalf("expect weighted host coverage 0.75, got %+v", result.Candidates)
+	}
+	// 没有加权基线的虚拟机按数量计算
+	for _, cand := range result.Candidates {
+		if cand.Node.Key == "VM_1" && cand.Coverage != 1 {
+			t.Fatalf("expect vm to fall back to count coverage, got %+v", cand)
+		}
+	}
+}
+
+func TestConfigOverrideWeightedCoverage(t *testing.T) {
+	on := true
+	merged, err := rca.DefaultConfig().Merge(rca.ConfigOverride{WeightedCoverage: &on})
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if !merged.WeightedCoverage || rca.DefaultConfig().WeightedCoverage {
+		t.Fatalf("expect override to enable weighted coverage only on the copy")
+	}
+}
+
+// weightedReader 在虚拟机层返回带加权基线与链路权重的记录。
+type weightedReader struct{}
+
+func (weightedReader) RunRead(_ context.Context, query string, _ map[string]any) ([]map[string]any, error) {
+	if !strings.Contains(query, "MATCH (vm:VirtualMachine)") {
+		return nil, nil
+	}
+	record := buildAppRecord("order-service")
+	record["vm_app_weight"] = float64(5)
+	record["host_vm_weight"] = float64(7)
+	record["app_link_weight"] = float64(2)
+	record["vm_link_weight"] = float64(3)
+	return []map[string]any{record}, nil
+}
+
+func TestGraphProviderReadsRelationshipWeights(t *testing.T) {
+	provider := rca.NewGraphProvider(weightedReader{})
+	evt := rca.AlarmEvent{AppName: "order-service", IP: "172.16.20.101", ServerType: rca.ServerTypeVM, RuleName: "down", OccurredAt: time.Now()}
+	nodes, err := provider.ResolveEvent(context.Background(), evt)
+	if err != nil {
+		t.Fatalf("resolve event: %v", err)
+	}
+	byType := make(map[rca.NodeType]rca.Node, len(nodes))
+	for _, node := range nodes {
+		byType[node.Type] = node
+	}
+	if got := byType[rca.NodeTypeApp].LinkWeight; got != 2 {
+		t.Fatalf("expect app link weight 2, got %v", got)
+	}
+	vm := byType[rca.NodeTypeVirtualMachine]
+	if vm.LinkWeight != 3 || vm.ChildWeights[rca.NodeTypeApp] != 5 {
+		t.Fatalf("expect vm weights parsed, got %+v", vm)
+	}
+	if got := byType[rca.NodeTypeHostMachine].ChildWeights[rca.NodeTypeVirtualMachine]; got != 7 {
+		t.Fatalf("expect host vm weight 7, got %v", got)
+	}
+}
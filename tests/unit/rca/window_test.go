@@ -0,0 +1,65 @@
+package rca_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+)
+
+func TestResultWindowCoversUnexplainedEvents(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []rca.AlarmEvent{
+		{AppName: "app-1", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "cpu", OccurredAt: start.Add(time.Minute)},
+		// 无法解析的告警也要计入窗口
+		{AppName: "ghost", IP: "10.9.9.9", ServerType: rca.ServerTypeVM, RuleName: "cpu", OccurredAt: start},
+		{AppName: "ghost", IP: "10.9.9.8", ServerType: rca.ServerTypeVM, RuleName: "cpu", OccurredAt: start.Add(5 * time.Minute)},
+		// 零值时间不影响窗口
+		{AppName: "app-1", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "mem"},
+	}
+
+	analyzer, err := rca.NewAnalyzer(singleChainProvider(), rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	result, err := analyzer.Analyze(context.Background(), events)
+	if err != nil {
+		t.Fatalf("analyze failed: %v", err)
+	}
+
+	if !result.WindowStart.Equal(start) || !result.WindowEnd.Equal(start.Add(5*time.Minute)) {
+		t.Fatalf("unexpected window %s ~ %s", result.WindowStart, result.WindowEnd)
+	}
+	if result.EventCount != len(events) {
+		t.Fatalf("expected event count %d, got %d", len(events), result.EventCount)
+	}
+	label := "2025-01-01T00:00:00Z ~ 2025-01-01T00:05:00Z"
+	if !strings.Contains(result.Prompt, "- Window: "+label) {
+		t.Fatalf("expected prompt to show window, got %s", result.Prompt)
+	}
+	if dot := rca.RenderPathDOT(result); !strings.Contains(dot, `label="window `+label+`";`) {
+		t.Fatalf("expected dot graph label, got %s", dot)
+	}
+	if mermaid := rca.RenderPathMermaid(result); !strings.Contains(mermaid, "%% window: "+label) {
+		t.Fatalf("expected mermaid window comment, got %s", mermaid)
+	}
+}
+
+func TestResultWindowZeroWithoutTimestamps(t *testing.T) {
+	analyzer, err := rca.NewAnalyzer(singleChainProvider(), rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	result, err := analyzer.Analyze(context.Background(), []rca.AlarmEvent{{AppName: "app-1", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "cpu"}})
+	if err != nil {
+		t.Fatalf("analyze failed: %v", err)
+	}
+	if !result.WindowStart.IsZero() || !result.WindowEnd.IsZero() {
+		t.Fatalf("expected zero window, got %s ~ %s", result.WindowStart, result.WindowEnd)
+	}
+	if strings.Contains(result.Prompt, "Window:") || strings.Contains(rca.RenderPathDOT(result), "labelloc") {
+		t.Fatalf("expected unknown window to be omitted")
+	}
+}
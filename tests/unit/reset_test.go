@@ -0,0 +1,49 @@
+package unit
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/loader"
+)
+
+func TestResetStatementsRequireConfirm(t *testing.T) {
+	if _, err := loader.ResetStatements(loader.ResetOptions{}); !errors.Is(err, loader.ErrResetNotConfirmed) {
+		t.Fatalf("expect ErrResetNotConfirmed, got %v", err)
+	}
+}
+
+func TestResetStatementsRejectForeignLabel(t *testing.T) {
+	if _, err := loader.ResetStatements(loader.ResetOptions{Confirm: true, Labels: []string{"User"}}); err == nil {
+		t.Fatalf("expect error for non-cmdb label")
+	}
+}
+
+func TestResetStatementsScopedToCMDB(t *testing.T) {
+	statements, err := loader.ResetStatements(loader.ResetOptions{Confirm: true, DropSchema: true})
+	if err != nil {
+		t.Fatalf("reset statements: %v", err)
+	}
+	var deletes, drops int
+	for _, stmt := range statements {
+		switch {
+		case strings.Contains(stmt, "DETACH DELETE"):
+			if !strings.Contains(stmt, "MATCH (n:") || !strings.Contains(stmt, "n.cmdb_key IS NOT NULL") {
+				t.Fatalf("delete must be label and cmdb_key scoped: %s", stmt)
+			}
+			deletes++
+		case strings.HasPrefix(stmt, "DROP "):
+			if !strings.HasSuffix(stmt, "IF EXISTS") {
+				t.Fatalf("drop must be idempotent: %s", stmt)
+			}
+			drops++
+		default:
+			t.Fatalf("unexpected statement: %s", stmt)
+		}
+	}
+	if deletes != len(domain.EntityLabels) || drops != len(loader.RequiredSchema) {
+		t.Fatalf("expect %d deletes and %d drops, got %d/%d", len(domain.EntityLabels), len(loader.RequiredSchema), deletes, drops)
+	}
+}
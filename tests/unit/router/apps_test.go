@@ -0,0 +1,56 @@
+package router_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cmdb2neo/internal/rca"
+	"cmdb2neo/internal/router"
+)
+
+type stubFootprints struct{ app, idc string }
+
+func (s *stubFootprints) AppFootprint(_ context.Context, app, idc string) (rca.AppFootprint, error) {
+	s.app, s.idc = app, idc
+	if app == "broken" {
+		return rca.AppFootprint{}, errors.New("neo4j down")
+	}
+	return rca.AppFootprint{
+		App:       app,
+		IDC:       idc,
+		Total:     1,
+		Counts:    map[rca.NodeType]int{rca.NodeTypeVirtualMachine: 1},
+		Instances: []rca.AppInstance{{Key: "VM_1", Type: rca.NodeTypeVirtualMachine, IP: "10.0.0.1", IDC: idc}},
+	}, nil
+}
+
+func TestAppInstances(t *testing.T) {
+	stub := &stubFootprints{}
+	engine := router.NewEngine(router.NewRCAHandler(nil, nil), nil, router.WithAppHandler(router.NewAppHandler(stub, nil)))
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/apps/order/instances?idc=M5", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if stub.app != "order" || stub.idc != "M5" {
+		t.Fatalf("unexpected lookup app=%q idc=%q", stub.app, stub.idc)
+	}
+	var body rca.AppFootprint
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Total != 1 || len(body.Instances) != 1 || body.Instances[0].IP != "10.0.0.1" {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/apps/broken/instances", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expect 500 on query failure, got %d", rec.Code)
+	}
+}
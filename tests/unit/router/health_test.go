@@ -0,0 +1,60 @@
+package router_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/router"
+	"github.com/gin-gonic/gin"
+)
+
+// fakePinger 返回预设的连通性结果。
+type fakePinger struct{ err error }
+
+func (p fakePinger) Ping(context.Context) error { return p.err }
+
+func probe(t *testing.T, handler *router.HealthHandler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	handler.RegisterRoutes(engine)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestHealthProbes(t *testing.T) {
+	if w := probe(t, router.NewHealthHandler(fakePinger{err: errors.New("down")}, false), "/healthz"); w.Code != http.StatusOK {
+		t.Fatalf("expect healthz to ignore dependencies, got %d", w.Code)
+	}
+	if w := probe(t, router.NewHealthHandler(fakePinger{}, true), "/readyz"); w.Code != http.StatusOK {
+		t.Fatalf("expect ready, got %d: %s", w.Code, w.Body.String())
+	}
+	w := probe(t, router.NewHealthHandler(fakePinger{err: errors.New("connection refused")}, true), "/readyz")
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "connection refused") {
+		t.Fatalf("expect 503 with reason, got %d: %s", w.Code, w.Body.String())
+	}
+	w = probe(t, router.NewHealthHandler(fakePinger{}, false), "/readyz")
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "config") {
+		t.Fatalf("expect 503 when config missing, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// fakeStatus 返回预设的后台连通性监控结果。
+type fakeStatus struct{ err error }
+
+func (s fakeStatus) Err() error { return s.err }
+
+func TestReadyzReportsConnectionMonitor(t *testing.T) {
+	w := probe(t, router.NewHealthHandler(fakePinger{}, true, router.WithConnectionStatus(fakeStatus{err: errors.New("3 consecutive connectivity failures")})), "/readyz")
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "consecutive connectivity failures") {
+		t.Fatalf("expect 503 when monitor unhealthy, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := probe(t, router.NewHealthHandler(fakePinger{}, true, router.WithConnectionStatus(fakeStatus{})), "/readyz"); w.Code != http.StatusOK {
+		t.Fatalf("expect ready when monitor healthy, got %d: %s", w.Code, w.Body.String())
+	}
+}
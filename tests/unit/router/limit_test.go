@@ -0,0 +1,130 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+	"cmdb2neo/internal/router"
+)
+
+func newLimitedEngine(t *testing.T, opts ...router.RCAHandlerOption) http.Handler {
+	t.Helper()
+	analyzer, err := rca.NewAnalyzer(stubProvider{}, rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	return router.NewEngine(router.NewRCAHandler(analyzer, nil, opts...), nil)
+}
+
+func postFrom(handler http.Handler, path, remoteAddr, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = remoteAddr
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+const threeEvents = `[{"app_name":"a","rule_name":"down"},{"app_name":"b","rule_name":"down"},{"app_name":"c","rule_name":"down"}]`
+
+func TestAnalyzeRejectsTooManyEvents(t *testing.T) {
+	engine := newLimitedEngine(t, router.WithMaxEvents(2))
+
+	rec := postFrom(engine, "/api/v1/rca/analyze", "10.0.0.1:1234", `{"events":`+threeEvents+`}`)
+	if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), "limit of 2") {
+		t.Fatalf("expect 413 for 3 events, got %d: %s", rec.Code, rec.Body.String())
+	}
+	windows := `[{"events":[{"app_name":"a","rule_name":"down"}]},{"events":[{"app_name":"b","rule_name":"down"},{"app_name":"c","rule_name":"down"}]}]`
+	if rec := postFrom(engine, "/api/v1/rca/analyze", "10.0.0.1:1234", windows); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expect events counted across windows, got %d", rec.Code)
+	}
+	if rec := postFrom(engine, "/api/v1/rca/webhook/generic", "10.0.0.1:1234", threeEvents); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expect webhook events limited too, got %d", rec.Code)
+	}
+	if rec := postFrom(engine, "/api/v1/rca/analyze", "10.0.0.1:1234", `{"events":[{"app_name":"a","rule_name":"down"}]}`); rec.Code != http.StatusOK {
+		t.Fatalf("expect request within the limit accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAnalyzeRejectsOversizedBody(t *testing.T) {
+	engine := newLimitedEngine(t, router.WithMaxBodyBytes(64))
+	rec := postFrom(engine, "/api/v1/rca/analyze", "10.0.0.1:1234", `{"events":`+threeEvents+`}`)
+	if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), "64 bytes") {
+		t.Fatalf("expect 413 for oversized body, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRCARoutesRateLimitedPerClientIP(t *testing.T) {
+	engine := newLimitedEngine(t, router.WithRateLimiter(router.NewRateLimiter(0.5, 2)))
+	body := `{"events":[{"app_name":"a","rule_name":"down"}]}`
+	for i := 0; i < 2; i++ {
+		if rec := postFrom(engine, "/api/v1/rca/analyze", "10.0.0.1:1234", body); rec.Code != http.StatusOK {
+			t.Fatalf("request %d within burst: status %d", i, rec.Code)
+		}
+	}
+	rec := postFrom(engine, "/api/v1/rca/analyze", "10.0.0.1:5678", body)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expect 429 after burst, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("expect Retry-After of 2s at 0.5 req/s, got %q", got)
+	}
+	if rec := postFrom(engine, "/api/v1/rca/analyze", "10.0.0.2:1234", body); rec.Code != http.StatusOK {
+		t.Fatalf("expect other clients unaffected, got %d", rec.Code)
+	}
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	limiter := router.NewRateLimiter(20, 1)
+	if ok, _ := limiter.Allow("a"); !ok {
+		t.Fatalf("expect first request allowed")
+	}
+	ok, wait := limiter.Allow("a")
+	if ok || wait <= 0 || wait > 50*time.Millisecond {
+		t.Fatalf("expect denial with wait up to 50ms, got ok=%v wait=%v", ok, wait)
+	}
+	time.Sleep(wait + 10*time.Millisecond)
+	if ok, _ := limiter.Allow("a"); !ok {
+		t.Fatalf("expect token refilled after waiting")
+	}
+}
+
+func TestRateLimitIgnoresForwardedForFromUntrustedPeers(t *testing.T) {
+	analyzer, err := rca.NewAnalyzer(stubProvider{}, rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	handler := router.NewRCAHandler(analyzer, nil, router.WithRateLimiter(router.NewRateLimiter(0.5, 1)))
+	body := `{"events":[{"app_name":"a","rule_name":"down"}]}`
+	post := func(engine http.Handler, forwarded string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/rca/analyze", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwarded)
+		req.RemoteAddr = "10.0.0.1:1234"
+		engine.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// 默认不信任代理，伪造的 X-Forwarded-For 无法绕过限流
+	engine := router.NewEngine(handler, nil)
+	if code := post(engine, "192.0.2.1"); code != http.StatusOK {
+		t.Fatalf("expect first request allowed, got %d", code)
+	}
+	if code := post(engine, "192.0.2.2"); code != http.StatusTooManyRequests {
+		t.Fatalf("expect spoofed forwarded address to share the peer bucket, got %d", code)
+	}
+
+	// 对端为可信代理时按转发的客户端地址分别计数
+	handler = router.NewRCAHandler(analyzer, nil, router.WithRateLimiter(router.NewRateLimiter(0.5, 1)))
+	engine = router.NewEngine(handler, nil, router.WithTrustedProxies([]string{"10.0.0.0/8"}))
+	for _, client := range []string{"192.0.2.1", "192.0.2.2"} {
+		if code := post(engine, client); code != http.StatusOK {
+			t.Fatalf("expect client %s behind trusted proxy counted separately, got %d", client, code)
+		}
+	}
+}
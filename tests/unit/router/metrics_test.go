@@ -0,0 +1,53 @@
+package router_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/metrics"
+	"cmdb2neo/internal/router"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	prom := metrics.NewPrometheus()
+	prom.ObserveSync("sync", time.Second, nil)
+	prom.ObserveSync("sync", time.Second, errors.New("boom"))
+	prom.ObserveQuery("read", 10*time.Millisecond, nil)
+	prom.ObserveAnalyze(50*time.Millisecond, 3, nil)
+	prom.ObserveQueryCache(true)
+	prom.ObserveQueryCache(false)
+	prom.ObserveQueryCache(true)
+	prom.ObserveChainCache(false)
+
+	engine := router.NewEngine(router.NewRCAHandler(nil, nil), nil, router.WithMetricsHandler(prom.Handler()))
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expect 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`cmdb2neo_sync_runs_total{flow="sync",result="success"} 1`,
+		`cmdb2neo_sync_runs_total{flow="sync",result="failure"} 1`,
+		`cmdb2neo_neo4j_query_duration_seconds_count{mode="read",result="success"} 1`,
+		`cmdb2neo_rca_candidates_sum 3`,
+		`cmdb2neo_neo4j_query_cache_lookups_total{result="hit"} 2`,
+		`cmdb2neo_neo4j_query_cache_lookups_total{result="miss"} 1`,
+		`cmdb2neo_rca_chain_cache_lookups_total{result="miss"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics output missing %q", want)
+		}
+	}
+
+	plain := router.NewEngine(router.NewRCAHandler(nil, nil), nil)
+	w = httptest.NewRecorder()
+	plain.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expect no metrics route without handler, got %d", w.Code)
+	}
+}
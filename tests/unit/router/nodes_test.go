@@ -0,0 +1,68 @@
+package router_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/graph"
+	"cmdb2neo/internal/router"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+type listReader struct{ queries []string }
+
+func (r *listReader) RunRead(_ context.Context, query string, _ map[string]any) ([]map[string]any, error) {
+	r.queries = append(r.queries, query)
+	if strings.Contains(query, "count(n)") {
+		return []map[string]any{{"total": int64(1)}}, nil
+	}
+	return []map[string]any{{"n": neo4j.Node{Labels: []string{"IDC"}, Props: map[string]any{"cmdb_key": "IDC_1"}}}}, nil
+}
+
+func TestNodesList(t *testing.T) {
+	reader := &listReader{}
+	engine := router.NewEngine(router.NewRCAHandler(nil, nil), nil, router.WithNodesHandler(router.NewNodesHandler(reader, nil)))
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/nodes?label=IDC&name=M5&page=1&limit=10", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var page graph.NodePage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("streamed body is not valid JSON: %v: %s", err, rec.Body.String())
+	}
+	if page.Label != "IDC" || page.Total != 1 || page.Limit != 10 || len(page.Items) != 1 || page.Items[0].CMDBKey != "IDC_1" {
+		t.Fatalf("unexpected page: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/nodes?label=IDC&page=2", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"label":"IDC","page":2,"limit":50,"total":1,"items":[]}` {
+		t.Fatalf("expect empty page past the end, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	reader.queries = nil
+	bad := []string{
+		"/api/v1/nodes",
+		"/api/v1/nodes?label=" + url.QueryEscape("IDC) DETACH DELETE n //"),
+		"/api/v1/nodes?label=IDC&page=0",
+		"/api/v1/nodes?label=IDC&limit=abc",
+		"/api/v1/nodes?label=IDC&limit=100000",
+	}
+	for _, path := range bad {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expect 400, got %d: %s", path, rec.Code, rec.Body.String())
+		}
+	}
+	if len(reader.queries) != 0 {
+		t.Fatalf("rejected requests must not reach neo4j, got %v", reader.queries)
+	}
+}
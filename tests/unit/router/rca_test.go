@@ -0,0 +1,331 @@
+package router_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+	"cmdb2neo/internal/router"
+)
+
+// stubProvider 让所有事件解析到同一条 App→VM 链路，VM 下共有两个应用。
+type stubProvider struct{}
+
+func (stubProvider) ResolveEvent(context.Context, rca.AlarmEvent) ([]rca.Node, error) {
+	return []rca.Node{
+		{NodeRef: rca.NodeRef{Key: "APP_1", Type: rca.NodeTypeApp}},
+		{NodeRef: rca.NodeRef{Key: "VM_1", Type: rca.NodeTypeVirtualMachine}, ChildCounts: map[rca.NodeType]int{rca.NodeTypeApp: 2}},
+	}, nil
+}
+
+func (stubProvider) ListAppInstances(context.Context, string, string) (int, error) {
+	return 0, nil
+}
+
+func newRCAEngine(t *testing.T) http.Handler {
+	t.Helper()
+	analyzer, err := rca.NewAnalyzer(stubProvider{}, rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	return router.NewEngine(router.NewRCAHandler(analyzer, nil), nil)
+}
+
+func postAnalyze(t *testing.T, handler http.Handler, body map[string]any) *httptest.ResponseRecorder {
+	t.Helper()
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/rca/analyze", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func candidateKeys(t *testing.T, rec *httptest.ResponseRecorder) map[string]bool {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Result rca.Result `json:"result"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	keys := make(map[string]bool, len(resp.Result.Candidates))
+	for _, cand := range resp.Result.Candidates {
+		keys[cand.Node.Key] = true
+	}
+	return keys
+}
+
+var overrideEvents = []map[string]any{{"app_name": "order", "ip": "10.0.0.1", "server_type": "2", "rule_name": "down"}}
+
+func TestAnalyzeConfigOverrideChangesCandidates(t *testing.T) {
+	engine := newRCAEngine(t)
+
+	if keys := candidateKeys(t, postAnalyze(t, engine, map[string]any{"events": overrideEvents})); keys["VM_1"] {
+		t.Fatalf("VM_1 should not pass the default threshold, got %v", keys)
+	}
+
+	override := map[string]any{"layers": map[string]any{"VirtualMachine": map[string]any{"coverage_threshold": 0.4}}}
+	if keys := candidateKeys(t, postAnalyze(t, engine, map[string]any{"events": overrideEvents, "config": override})); !keys["VM_1"] {
+		t.Fatalf("VM_1 should appear with lowered threshold, got %v", keys)
+	}
+
+	// 覆盖只作用于单次请求
+	if keys := candidateKeys(t, postAnalyze(t, engine, map[string]any{"events": overrideEvents})); keys["VM_1"] {
+		t.Fatalf("override leaked into default config, got %v", keys)
+	}
+}
+
+func TestAnalyzeRejectsInvalidOverride(t *testing.T) {
+	engine := newRCAEngine(t)
+	override := map[string]any{"app_outage_threshold": 1.5}
+	if rec := postAnalyze(t, engine, map[string]any{"events": overrideEvents, "config": override}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expect 400 for invalid override, got %d", rec.Code)
+	}
+}
+
+func TestAnalyzeConfigOverrideField(t *testing.T) {
+	engine := newRCAEngine(t)
+	override := map[string]any{"layers": map[string]any{"VirtualMachine": map[string]any{"coverage_threshold": 0.4}}}
+	if keys := candidateKeys(t, postAnalyze(t, engine, map[string]any{"events": overrideEvents, "config_override": override})); !keys["VM_1"] {
+		t.Fatalf("VM_1 should appear with config_override, got %v", keys)
+	}
+
+	withService := map[string]any{"hierarchy": []string{"App", "VirtualMachine", "HostMachine", "PhysicalMachine", "NetPartition", "IDC", "Service"}}
+	if rec := postAnalyze(t, engine, map[string]any{"events": overrideEvents, "config_override": withService}); rec.Code != http.StatusOK {
+		t.Fatalf("expect hierarchy override accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	invalid := []map[string]any{
+		{"config_override": map[string]any{"hierarchy": []string{"App", "Rack"}}},
+		{"config_override": map[string]any{"hierarchy": []string{"App", "App"}}},
+		{"config_override": map[string]any{"layers": map[string]any{"IDC": map[string]any{"coverage_threshold": -0.1}}}},
+		{"config_override": override, "config": override},
+	}
+	for _, body := range invalid {
+		body["events"] = overrideEvents
+		if rec := postAnalyze(t, engine, body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expect 400 for %v, got %d", body, rec.Code)
+		}
+	}
+}
+
+type stubAlarmSource struct {
+	events []rca.AlarmEvent
+	since  time.Time
+}
+
+func (s *stubAlarmSource) RecentAlarms(_ context.Context, since time.Time) ([]rca.AlarmEvent, error) {
+	s.since = since
+	return s.events, nil
+}
+
+func TestAnalyzeRecentAlarms(t *testing.T) {
+	analyzer, err := rca.NewAnalyzer(stubProvider{}, rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	source := &stubAlarmSource{events: []rca.AlarmEvent{{AppName: "order", IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "down"}}}
+	engine := router.NewEngine(router.NewRCAHandler(analyzer, nil, router.WithAlarmSource(source)), nil)
+
+	rec := serve(engine, http.MethodPost, "/api/v1/rca/analyze/recent?minutes=10")
+	if keys := candidateKeys(t, rec); !keys["APP_1"] {
+		t.Fatalf("expect candidates from graph alarms, got %v", keys)
+	}
+	if window := time.Since(source.since); window < 10*time.Minute || window > 11*time.Minute {
+		t.Fatalf("expect a 10 minute lookback, got %v", window)
+	}
+
+	if rec := serve(engine, http.MethodPost, "/api/v1/rca/analyze/recent?minutes=abc"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expect 400 for invalid minutes, got %d", rec.Code)
+	}
+
+	source.events = nil
+	if rec := serve(engine, http.MethodPost, "/api/v1/rca/analyze/recent"); rec.Code != http.StatusOK {
+		t.Fatalf("expect 200 when no alarms, got %d", rec.Code)
+	}
+}
+
+func TestAnalyzeRecentWithoutSource(t *testing.T) {
+	if rec := serve(newRCAEngine(t), http.MethodPost, "/api/v1/rca/analyze/recent"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expect 503 without alarm source, got %d", rec.Code)
+	}
+}
+
+func TestAnalyzeStopAtOverride(t *testing.T) {
+	engine := newRCAEngine(t)
+	layers := map[string]any{"VirtualMachine": map[string]any{"coverage_threshold": 0.4}}
+
+	override := map[string]any{"layers": layers, "stop_at": "App"}
+	if keys := candidateKeys(t, postAnalyze(t, engine, map[string]any{"events": overrideEvents, "config": override})); keys["VM_1"] || !keys["APP_1"] {
+		t.Fatalf("expect only App layer candidates with stop_at App, got %v", keys)
+	}
+
+	override = map[string]any{"stop_at": "Rack"}
+	if rec := postAnalyze(t, engine, map[string]any{"events": overrideEvents, "config": override}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expect 400 for stop_at outside hierarchy, got %d", rec.Code)
+	}
+}
+
+type stubResultReader struct {
+	stored rca.StoredResult
+}
+
+func (r stubResultReader) Load(_ context.Context, windowID string) (rca.StoredResult, error) {
+	if windowID != r.stored.WindowID {
+		return rca.StoredResult{}, rca.ErrResultNotFound
+	}
+	return r.stored, nil
+}
+
+func TestGetStoredResult(t *testing.T) {
+	analyzer, err := rca.NewAnalyzer(stubProvider{}, rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	stored := rca.StoredResult{WindowID: "w-1", CreatedAt: time.Now().UTC(), EventCount: 2, Result: rca.Result{EventCount: 2}}
+	engine := router.NewEngine(router.NewRCAHandler(analyzer, nil, router.WithResultReader(stubResultReader{stored: stored})), nil)
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rca/results/w-1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var got rca.StoredResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.WindowID != "w-1" || got.EventCount != 2 || !got.CreatedAt.Equal(stored.CreatedAt) {
+		t.Fatalf("unexpected stored result: %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rca/results/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expect 404 for unknown window, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rca/results/w-1/graph.dot", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/vnd.graphviz") || !strings.HasPrefix(rec.Body.String(), "digraph rca {") {
+		t.Fatalf("expect DOT graph, got %d %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rca/results/w-1?format=mermaid", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Body.String(), "graph TD\n") {
+		t.Fatalf("expect mermaid flowchart, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rca/results/w-1?format=csv", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv; charset=utf-8" ||
+		rec.Header().Get("Content-Disposition") != `attachment; filename="rca-w-1.csv"` || !strings.HasPrefix(rec.Body.String(), "node_key,") {
+		t.Fatalf("expect csv attachment, got %d %v: %s", rec.Code, rec.Header(), rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rca/results/w-1?format=svg", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expect 400 for unsupported format, got %d", rec.Code)
+	}
+}
+
+func TestAnalyzeAsyncReturnsJob(t *testing.T) {
+	engine := newRCAEngine(t)
+
+	rec := postAnalyze(t, engine, map[string]any{"events": overrideEvents, "window_id": "w-async", "async": true})
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expect 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var job rca.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if job.ID == "" || job.WindowID != "w-async" {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for job.State != rca.JobDone && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		rec = httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rca/jobs/"+job.ID, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("poll status %d: %s", rec.Code, rec.Body.String())
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	if job.State != rca.JobDone || job.Result == nil || len(job.Result.Candidates) == 0 {
+		t.Fatalf("expect finished job with candidates, got %+v", job)
+	}
+
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/v1/rca/jobs/"+job.ID, nil))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expect 409 cancelling a finished job, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/rca/jobs/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expect 404 for unknown job, got %d", rec.Code)
+	}
+}
+
+func decodeWindows(t *testing.T, rec *httptest.ResponseRecorder) []map[string]any {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Windows []map[string]any `json:"windows"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return resp.Windows
+}
+
+func TestAnalyzeMultipleWindows(t *testing.T) {
+	engine := newRCAEngine(t)
+	windows := []map[string]any{
+		{"window_id": "w-1", "events": overrideEvents},
+		{"window_id": "w-2", "events": []map[string]any{}},
+	}
+
+	got := decodeWindows(t, postAnalyze(t, engine, map[string]any{"windows": windows, "read_only": true}))
+	if len(got) != 2 || got[0]["window_id"] != "w-1" || got[1]["window_id"] != "w-2" {
+		t.Fatalf("expect one response per window in order, got %v", got)
+	}
+	if got[0]["error"] != nil || got[1]["error"] == nil {
+		t.Fatalf("expect only the empty window to fail, got %v", got)
+	}
+
+	// 顶层数组形式等价于 windows 字段
+	payload, _ := json.Marshal(windows)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/rca/analyze", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	engine.ServeHTTP(rec, req)
+	if arr := decodeWindows(t, rec); len(arr) != 2 || arr[0]["error"] != nil {
+		t.Fatalf("expect array payload to be analyzed per window, got %v", arr)
+	}
+
+	if rec := postAnalyze(t, engine, map[string]any{"windows": windows, "async": true}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expect 400 for async multi-window, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,84 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"cmdb2neo/internal/rca"
+	"cmdb2neo/internal/router"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newLoggedEngine(t *testing.T, level zapcore.Level) (http.Handler, *observer.ObservedLogs) {
+	t.Helper()
+	core, logs := observer.New(level)
+	logger := zap.New(core)
+	analyzer, err := rca.NewAnalyzer(stubProvider{}, rca.DefaultConfig(), rca.WithLogger(logger))
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	return router.NewEngine(router.NewRCAHandler(analyzer, logger), nil, router.WithRequestLogging(logger)), logs
+}
+
+func fieldsOf(entry observer.LoggedEntry) map[string]any {
+	return entry.ContextMap()
+}
+
+func TestRequestLoggingPropagatesRequestID(t *testing.T) {
+	engine, logs := newLoggedEngine(t, zapcore.DebugLevel)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/rca/analyze", strings.NewReader(`{"events":[{"app_name":"a","rule_name":"down"}]}`))
+	req.Header.Set(router.RequestIDHeader, "incident-42")
+	engine.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get(router.RequestIDHeader); got != "incident-42" {
+		t.Fatalf("expect caller request id echoed, got %q", got)
+	}
+
+	access := logs.FilterMessage("http request").All()
+	if len(access) != 1 {
+		t.Fatalf("expect one access log, got %d", len(access))
+	}
+	fields := fieldsOf(access[0])
+	if fields["request_id"] != "incident-42" || fields["method"] != "POST" || fields["route"] != "/api/v1/rca/analyze" || fields["status"] != int64(200) {
+		t.Fatalf("unexpected access log fields: %v", fields)
+	}
+	if access[0].Level != zapcore.InfoLevel {
+		t.Fatalf("expect successful request logged at info, got %v", access[0].Level)
+	}
+	analyzed := logs.FilterMessage("rca analyze finished").All()
+	if len(analyzed) != 1 || fieldsOf(analyzed[0])["request_id"] != "incident-42" {
+		t.Fatalf("expect analyzer to log with the request-scoped logger, got %+v", analyzed)
+	}
+}
+
+func TestRequestLoggingGeneratesRequestID(t *testing.T) {
+	engine, logs := newLoggedEngine(t, zapcore.InfoLevel)
+	hexID := regexp.MustCompile(`^[0-9a-f]{32}$`)
+	for _, incoming := range []string{"", "bad id\nwith newline", strings.Repeat("x", 200)} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/rca/analyze", strings.NewReader(`{}`))
+		if incoming != "" {
+			req.Header.Set(router.RequestIDHeader, incoming)
+		}
+		engine.ServeHTTP(rec, req)
+		if got := rec.Header().Get(router.RequestIDHeader); !hexID.MatchString(got) {
+			t.Fatalf("expect generated request id for %q, got %q", incoming, got)
+		}
+	}
+	for _, entry := range logs.FilterMessage("http request").All() {
+		if entry.Level != zapcore.WarnLevel {
+			t.Fatalf("expect 4xx logged at warn, got %v", entry.Level)
+		}
+	}
+	if logs.FilterMessage("rca analyze finished").Len() != 0 {
+		t.Fatalf("debug analyzer logs must be suppressed at info level")
+	}
+}
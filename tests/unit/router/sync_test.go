@@ -0,0 +1,69 @@
+package router_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/router"
+)
+
+func TestSyncProgressAndCancel(t *testing.T) {
+	tracker := app.NewSyncTracker()
+	engine := router.NewEngine(router.NewRCAHandler(nil, nil), router.NewSyncHandler(tracker, nil))
+
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- tracker.Run(context.Background(), func(ctx context.Context) error {
+			tracker.Report("nodes", map[string]int{"nodes": 42})
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}()
+	<-started
+
+	var progress app.SyncProgress
+	rec := serve(engine, http.MethodGet, "/api/v1/sync/progress")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("progress status %d", rec.Code)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &progress); err != nil {
+		t.Fatalf("decode progress: %v", err)
+	}
+	if !progress.Running || progress.Stage != "nodes" || progress.Counts["nodes"] != 42 {
+		t.Fatalf("unexpected progress %+v", progress)
+	}
+
+	if rec := serve(engine, http.MethodPost, "/api/v1/sync/cancel"); rec.Code != http.StatusOK {
+		t.Fatalf("cancel status %d", rec.Code)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expect canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("sync not cancelled")
+	}
+
+	if got := tracker.Progress(); got.Running || got.Stage != "cancelled" || !got.Cancelled {
+		t.Fatalf("unexpected final progress %+v", got)
+	}
+	if rec := serve(engine, http.MethodPost, "/api/v1/sync/cancel"); rec.Code != http.StatusConflict {
+		t.Fatalf("cancel without running sync expect 409, got %d", rec.Code)
+	}
+}
+
+func serve(handler http.Handler, method, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	handler.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,50 @@
+package router_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/graph"
+	"cmdb2neo/internal/rca"
+	"cmdb2neo/internal/router"
+)
+
+// timeoutProvider 模拟图查询超时。
+type timeoutProvider struct{}
+
+func (timeoutProvider) ResolveEvent(context.Context, rca.AlarmEvent) ([]rca.Node, error) {
+	return nil, fmt.Errorf("执行查询失败: %w", graph.ErrQueryTimeout)
+}
+
+func (timeoutProvider) ListAppInstances(context.Context, string, string) (int, error) {
+	return 0, nil
+}
+
+// timeoutReader 读取结果时超时。
+type timeoutReader struct{}
+
+func (timeoutReader) Load(context.Context, string) (rca.StoredResult, error) {
+	return rca.StoredResult{}, fmt.Errorf("load result failed: %w", graph.ErrQueryTimeout)
+}
+
+func TestAnalyzeQueryTimeoutReturns504(t *testing.T) {
+	analyzer, err := rca.NewAnalyzer(timeoutProvider{}, rca.DefaultConfig())
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	engine := router.NewEngine(router.NewRCAHandler(analyzer, nil, router.WithResultReader(timeoutReader{})), nil)
+
+	rec := postAnalyze(t, engine, map[string]any{
+		"events": []map[string]any{{"app_name": "a", "ip": "10.0.0.1", "server_type": "2", "rule_name": "down", "occurred_at": time.Now()}},
+	})
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expect 504 on query timeout, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := serve(engine, http.MethodGet, "/api/v1/rca/results/w-1"); rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expect 504 loading result, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
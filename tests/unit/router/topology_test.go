@@ -0,0 +1,80 @@
+package router_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cmdb2neo/internal/rca"
+	"cmdb2neo/internal/router"
+)
+
+type stubNeighborhood struct {
+	last rca.NeighborhoodQuery
+}
+
+func (s *stubNeighborhood) Neighborhood(_ context.Context, q rca.NeighborhoodQuery) (rca.Neighborhood, error) {
+	s.last = q
+	if q.Key == "MISSING" {
+		return rca.Neighborhood{}, fmt.Errorf("%s: %w", q.Key, rca.ErrNodeNotFound)
+	}
+	root := rca.NodeRef{Key: q.Key, Type: rca.NodeTypeVirtualMachine}
+	return rca.Neighborhood{
+		Root:          root,
+		Depth:         q.Depth,
+		Nodes:         []rca.NodeRef{root, {Key: "HM_1", Type: rca.NodeTypeHostMachine}},
+		Relationships: []rca.Relationship{{Type: "HOSTS_VM", Source: "HM_1", Target: q.Key}},
+	}, nil
+}
+
+func newTopologyEngine(reader rca.NeighborhoodReader, opts ...router.TopologyHandlerOption) http.Handler {
+	handler := router.NewTopologyHandler(reader, nil, opts...)
+	return router.NewEngine(router.NewRCAHandler(nil, nil), nil, router.WithTopologyHandler(handler))
+}
+
+func TestTopologyNeighborhood(t *testing.T) {
+	stub := &stubNeighborhood{}
+	engine := newTopologyEngine(stub, router.WithMaxTopologyNodes(50))
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/topology/VM_1?depth=2&rel_type=HOSTS_VM,DEPLOYED_ON&rel_type=HAS_HOST&limit=500", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if stub.last.Key != "VM_1" || stub.last.Depth != 2 || stub.last.MaxNodes != 50 || len(stub.last.RelTypes) != 3 {
+		t.Fatalf("unexpected query: %+v", stub.last)
+	}
+	var body rca.Neighborhood
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Root.Key != "VM_1" || len(body.Nodes) != 2 || len(body.Relationships) != 1 || body.Relationships[0].Source != "HM_1" {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+}
+
+func TestTopologyNeighborhoodErrors(t *testing.T) {
+	engine := newTopologyEngine(&stubNeighborhood{}, router.WithMaxTopologyDepth(2))
+	cases := map[string]int{
+		"/api/v1/topology/MISSING":       http.StatusNotFound,
+		"/api/v1/topology/VM_1?depth=3":  http.StatusBadRequest,
+		"/api/v1/topology/VM_1?depth=x":  http.StatusBadRequest,
+		"/api/v1/topology/VM_1?limit=0":  http.StatusBadRequest,
+		"/api/v1/topology/VM_1?limit=10": http.StatusOK,
+	}
+	for path, want := range cases {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Fatalf("%s: expect %d, got %d: %s", path, want, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	newTopologyEngine(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/topology/VM_1", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expect 503 without reader, got %d", rec.Code)
+	}
+}
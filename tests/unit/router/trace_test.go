@@ -0,0 +1,80 @@
+package router_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/rca"
+	"cmdb2neo/internal/router"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// vmReader 对 VM 层批量查询返回同一条 VM→宿主机链路。
+type vmReader struct{}
+
+func (vmReader) RunRead(_ context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	if !strings.Contains(query, "MATCH (vm:VirtualMachine)") {
+		return nil, nil
+	}
+	events, _ := params["events"].([]map[string]any)
+	records := make([]map[string]any, 0, len(events))
+	for _, event := range events {
+		records = append(records, map[string]any{
+			"event": event,
+			"vm":    neo4j.Node{Labels: []string{"VirtualMachine"}, Props: map[string]any{"cmdb_key": "VM_1"}},
+			"host":  neo4j.Node{Labels: []string{"HostMachine"}, Props: map[string]any{"cmdb_key": "HM_1"}},
+		})
+	}
+	return records, nil
+}
+
+func TestAnalyzeRequestTracing(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	provider := rca.NewGraphProvider(vmReader{}, rca.WithProviderTracerProvider(tp))
+	analyzer, err := rca.NewAnalyzer(provider, rca.DefaultConfig(), rca.WithTracerProvider(tp))
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	engine := router.NewEngine(router.NewRCAHandler(analyzer, nil, router.WithTracerProvider(tp)), nil)
+
+	payload, _ := json.Marshal(map[string]any{"events": []rca.AlarmEvent{{IP: "10.0.0.1", ServerType: rca.ServerTypeVM, RuleName: "cpu", OccurredAt: time.Now()}}})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/rca/analyze", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range spans.Ended() {
+		byName[span.Name()] = span
+	}
+	root, analyze, resolve := byName["POST /api/v1/rca/analyze"], byName["rca.analyze"], byName["rca.resolve"]
+	if root == nil || analyze == nil || resolve == nil {
+		t.Fatalf("expect request, analyze and resolve spans, got %v", byName)
+	}
+	if got := root.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("expect incoming trace continued, got trace %s", got)
+	}
+	if analyze.Parent().SpanID() != root.SpanContext().SpanID() || resolve.Parent().SpanID() != analyze.SpanContext().SpanID() {
+		t.Fatalf("expect request > analyze > resolve nesting")
+	}
+	attrs := make(map[string]string)
+	for _, kv := range resolve.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["rca.node_type"] != string(rca.NodeTypeVirtualMachine) || attrs["rca.event_count"] != "1" {
+		t.Fatalf("unexpected resolve attributes %v", attrs)
+	}
+}
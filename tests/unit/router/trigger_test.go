@@ -0,0 +1,105 @@
+package router_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/cmdb"
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/loader"
+	"cmdb2neo/internal/router"
+)
+
+// fakeTrigger 通过真实的 SyncTracker 执行 run，模拟 app.Service.StartSync。
+type fakeTrigger struct {
+	tracker *app.SyncTracker
+	run     func(ctx context.Context) error
+}
+
+func (f fakeTrigger) StartSync(ctx context.Context, _ bool) (string, <-chan error, error) {
+	return f.tracker.Start(ctx, f.run)
+}
+
+type triggerResponse struct {
+	TriggerID string           `json:"trigger_id"`
+	Progress  app.SyncProgress `json:"progress"`
+}
+
+func TestSyncTrigger(t *testing.T) {
+	if rec := serve(router.NewEngine(router.NewRCAHandler(nil, nil), router.NewSyncHandler(nil, nil)), http.MethodPost, "/api/v1/sync"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expect 503 without sync service, got %d", rec.Code)
+	}
+
+	tracker := app.NewSyncTracker()
+	fast := fakeTrigger{tracker: tracker, run: func(context.Context) error {
+		tracker.Report("written", map[string]int{"nodes_created": 3})
+		return nil
+	}}
+	engine := router.NewEngine(router.NewRCAHandler(nil, nil), router.NewSyncHandler(tracker, nil, router.WithSyncTrigger(fast)))
+	rec := serve(engine, http.MethodPost, "/api/v1/sync")
+	var resp triggerResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expect 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if resp.TriggerID == "" || resp.Progress.TriggerID != resp.TriggerID || resp.Progress.Stage != "done" || resp.Progress.Counts["nodes_created"] != 3 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	release := make(chan struct{})
+	slow := fakeTrigger{tracker: tracker, run: func(ctx context.Context) error {
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}}
+	engine = router.NewEngine(router.NewRCAHandler(nil, nil),
+		router.NewSyncHandler(tracker, nil, router.WithSyncTrigger(slow), router.WithTriggerWait(10*time.Millisecond)))
+	if rec := serve(engine, http.MethodPost, "/api/v1/sync?mode=init"); rec.Code != http.StatusAccepted {
+		t.Fatalf("expect 202 for long sync, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(engine, http.MethodPost, "/api/v1/sync"); rec.Code != http.StatusConflict {
+		t.Fatalf("expect 409 for overlapping trigger, got %d", rec.Code)
+	}
+	if rec := serve(engine, http.MethodPost, "/api/v1/sync?mode=bogus"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expect 400 for unknown mode, got %d", rec.Code)
+	}
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for tracker.Progress().Running {
+		if time.Now().After(deadline) {
+			t.Fatal("triggered sync did not finish")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSyncTriggerMapsErrorKinds(t *testing.T) {
+	cases := []struct {
+		kind   error
+		status int
+	}{
+		{loader.ErrNeo4jUnavailable, http.StatusServiceUnavailable},
+		{cmdb.ErrCMDBUnavailable, http.StatusServiceUnavailable},
+		{cmdb.ErrCMDBAuth, http.StatusBadGateway},
+		{domain.ErrValidation, http.StatusUnprocessableEntity},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		tracker := app.NewSyncTracker()
+		failing := fakeTrigger{tracker: tracker, run: func(context.Context) error {
+			return fmt.Errorf("增量同步失败: %w", domain.MarkError(tc.kind, errors.New("cause")))
+		}}
+		engine := router.NewEngine(router.NewRCAHandler(nil, nil), router.NewSyncHandler(tracker, nil, router.WithSyncTrigger(failing)))
+		if rec := serve(engine, http.MethodPost, "/api/v1/sync"); rec.Code != tc.status {
+			t.Fatalf("expect %d for %v, got %d: %s", tc.status, tc.kind, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package router_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/graph"
+	"cmdb2neo/internal/rca"
+	"cmdb2neo/internal/router"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// downReader 模拟 graph.Client 在 Neo4j 不可达时返回的已分类错误。
+type downReader struct{}
+
+func (downReader) RunRead(context.Context, string, map[string]any) ([]map[string]any, error) {
+	return nil, graph.ClassifyError(fmt.Errorf("执行查询失败: %w", &neo4j.ConnectivityError{Inner: errors.New("connection refused")}))
+}
+
+func TestReadEndpointsReturn503WhenNeo4jUnavailable(t *testing.T) {
+	cfg := rca.DefaultConfig()
+	cfg.FailFast = true
+	analyzer, err := rca.NewAnalyzer(rca.NewGraphProvider(downReader{}), cfg)
+	if err != nil {
+		t.Fatalf("new analyzer: %v", err)
+	}
+	engine := router.NewEngine(router.NewRCAHandler(analyzer, nil), nil,
+		router.WithNodesHandler(router.NewNodesHandler(downReader{}, nil)))
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/nodes?label=IDC", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expect 503 listing nodes, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = postAnalyze(t, engine, map[string]any{
+		"events": []map[string]any{{"app_name": "a", "ip": "10.0.0.1", "server_type": "2", "rule_name": "down", "occurred_at": time.Now()}},
+	})
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expect 503 analyzing, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,58 @@
+package router_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func postWebhook(handler http.Handler, source, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/rca/webhook/"+source+"?window_id=wh-1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWebhookAnalyzesAlertmanagerPayload(t *testing.T) {
+	payload, err := os.ReadFile("../rca/alertmanager_webhook.json")
+	if err != nil {
+		t.Fatalf("read sample: %v", err)
+	}
+	rec := postWebhook(newRCAEngine(t), "alertmanager", string(payload))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		WindowID string `json:"window_id"`
+		Result   struct {
+			EventCount int `json:"event_count"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.WindowID != "wh-1" {
+		t.Fatalf("expect window id from query, got %q", resp.WindowID)
+	}
+	if resp.Result.EventCount != 3 {
+		t.Fatalf("expect every alert analyzed, got %d", resp.Result.EventCount)
+	}
+}
+
+func TestWebhookRejectsUnknownSourceAndBadPayload(t *testing.T) {
+	engine := newRCAEngine(t)
+	rec := postWebhook(engine, "nagios", `{}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "alertmanager") {
+		t.Fatalf("expect 400 listing supported sources, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := postWebhook(engine, "alertmanager", `not json`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expect 400 for undecodable payload, got %d", rec.Code)
+	}
+	if rec := postWebhook(engine, "generic", `[]`); rec.Code != http.StatusOK {
+		t.Fatalf("expect empty payload accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
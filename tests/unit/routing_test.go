@@ -0,0 +1,36 @@
+package unit
+
+import (
+	"testing"
+
+	"cmdb2neo/internal/graph"
+)
+
+func TestRoutingURI(t *testing.T) {
+	cases := []struct {
+		uri     string
+		routing bool
+		want    string
+		wantErr bool
+	}{
+		{uri: "bolt://db:7687", routing: false, want: "bolt://db:7687"},
+		{uri: "bolt://db:7687", routing: true, want: "neo4j://db:7687"},
+		{uri: "bolt+s://db:7687", routing: true, want: "neo4j+s://db:7687"},
+		{uri: "bolt+ssc://db:7687", routing: true, want: "neo4j+ssc://db:7687"},
+		{uri: "neo4j://cluster:7687", routing: true, want: "neo4j://cluster:7687"},
+		{uri: "http://db:7474", routing: true, wantErr: true},
+		{uri: "db:7687", routing: true, wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := graph.RoutingURI(tc.uri, tc.routing)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("%s: expect error, got %s", tc.uri, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Fatalf("%s routing=%v: expect %s, got %s (%v)", tc.uri, tc.routing, tc.want, got, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/cypher"
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/loader"
+)
+
+func TestUpsertersRejectRowsWithoutRunID(t *testing.T) {
+	ctx := context.Background()
+	nodes := []domain.NodeRow{{CMDBKey: "HM_1", Labels: []string{domain.LabelHostMachine}}}
+	if _, err := loader.NewNodeUpserter(nil, 10).UpsertNodes(ctx, nodes); err == nil {
+		t.Fatal("expect node without run_id rejected")
+	}
+	rels := []domain.RelRow{{StartKey: "IDC_1", EndKey: "HM_1", Type: domain.RelHasHost}}
+	if _, err := loader.NewRelUpserter(nil, 10).InitRels(ctx, rels); err == nil {
+		t.Fatal("expect relationship without run_id rejected")
+	}
+}
+
+func TestWriteTemplatesStampLastSeenRunID(t *testing.T) {
+	for _, name := range []string{"init_nodes.cql", "upsert_nodes.cql", "init_edges.cql", "upsert_rels.cql"} {
+		query := cypher.MustTemplate(name, map[string]string{"LabelPattern": ":HostMachine", "RelType": ":HAS_HOST"})
+		if !strings.Contains(query, "."+domain.PropLastSeenRunID+" = row.run_id") {
+			t.Fatalf("%s must stamp %s on every write", name, domain.PropLastSeenRunID)
+		}
+		if !strings.Contains(query, "coalesce(") {
+			t.Fatalf("%s must keep %s on match", name, domain.PropFirstSeenRunID)
+		}
+	}
+}
+
+// Neo4j 5 不再支持对属性使用 exists()，删除模板需改用 IS NOT NULL。
+func TestDeleteTemplatesAvoidPropertyExists(t *testing.T) {
+	for _, name := range []string{"hard_delete.cql", "soft_delete.cql"} {
+		query := cypher.MustTemplate(name, map[string]string{"LabelPattern": ""})
+		if strings.Contains(query, "exists(") {
+			t.Fatalf("%s uses exists() on a property, rejected by Neo4j 5", name)
+		}
+		if !strings.Contains(query, "n.cmdb_key IS NOT NULL") {
+			t.Fatalf("%s must only touch CMDB nodes", name)
+		}
+	}
+}
+
+func TestNewRunIDIsFixedWidthAndStrictlyIncreasing(t *testing.T) {
+	at := time.Date(2031, 1, 1, 0, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	first := domain.NewRunID(at)
+	if first != "20301231T160000Z" {
+		t.Fatalf("expect utc timestamp run id, got %q", first)
+	}
+	// 同一秒与时钟回拨时追加序号，仍按字符串严格递增
+	ids := []string{first, domain.NewRunID(at), domain.NewRunID(at.Add(-time.Minute)), domain.NewRunID(at.Add(time.Second))}
+	if ids[1] != "20301231T160000Z-0001" || ids[2] != "20301231T160000Z-0002" || ids[3] != "20301231T160001Z" {
+		t.Fatalf("unexpected run ids %v", ids)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i-1] >= ids[i] {
+			t.Fatalf("expect strictly increasing run ids, got %v", ids)
+		}
+		if err := domain.ValidateRunID(ids[i]); err != nil {
+			t.Fatalf("generated run id rejected: %v", err)
+		}
+	}
+}
+
+func TestValidateRunIDRejectsUnorderedIDs(t *testing.T) {
+	for _, id := range []string{"", "incident-42", "2025-01-01T00:00:00Z", "20250101T000000", "20251301T000000Z", "20250101T000000Z-1"} {
+		if err := domain.ValidateRunID(id); !errors.Is(err, domain.ErrInvalidRunID) {
+			t.Fatalf("expect %q rejected, got %v", id, err)
+		}
+	}
+}
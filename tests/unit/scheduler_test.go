@@ -0,0 +1,159 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/app"
+	"cmdb2neo/internal/domain"
+	"cmdb2neo/internal/job"
+	"cmdb2neo/internal/loader"
+)
+
+func TestSchedulerSpec(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  *app.Config
+		want string
+	}{
+		{"nil config", nil, "0 7 * * *"},
+		{"cron wins", &app.Config{Sync: app.Sync{JobCron: "*/5 * * * *", IntervalSeconds: 60}}, "*/5 * * * *"},
+		{"interval fallback", &app.Config{Sync: app.Sync{IntervalSeconds: 300}}, "@every 300s"},
+		{"default", &app.Config{}, "0 7 * * *"},
+	}
+	for _, tc := range cases {
+		jobs := job.NewScheduler(job.ConfigJobs(tc.cfg, nil), nil).Jobs()
+		if len(jobs) != 1 || jobs[0].Name != "sync" || jobs[0].Spec != tc.want {
+			t.Fatalf("%s: expect single sync job on %q, got %+v", tc.name, tc.want, jobs)
+		}
+	}
+}
+
+func TestSchedulerRunsJobsIndependently(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan string, 2)
+	block := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			started <- name
+			<-release
+			return nil
+		}
+	}
+	s := job.NewScheduler([]job.Job{
+		{Name: "full_resync", Spec: "0 2 * * *", Func: block("full_resync")},
+		{Name: "incremental", Spec: "*/15 * * * *", Func: block("incremental")},
+	}, nil)
+
+	now := time.Date(2025, 1, 1, 1, 20, 0, 0, time.Local)
+	next := s.NextRuns(now)
+	if !next["full_resync"].Equal(time.Date(2025, 1, 1, 2, 0, 0, 0, time.Local)) ||
+		!next["incremental"].Equal(time.Date(2025, 1, 1, 1, 30, 0, 0, time.Local)) {
+		t.Fatalf("unexpected next runs %v", next)
+	}
+
+	done := make(chan error, 2)
+	go func() { done <- s.Run("full_resync") }()
+	<-started
+	// 全量任务未结束时增量任务照常执行，同名任务则被防重入拦截
+	go func() { done <- s.Run("incremental") }()
+	<-started
+	if err := s.Run("full_resync"); !errors.Is(err, job.ErrJobRunning) {
+		t.Fatalf("expect overlapping run rejected, got %v", err)
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Fatalf("run: %v", err)
+		}
+	}
+	if err := s.Run("missing"); err == nil {
+		t.Fatal("expect unknown job rejected")
+	}
+}
+
+// memoryLocker 为进程内租约锁，多个调度器共享同一实例即可模拟多副本。
+type memoryLocker struct {
+	mu   sync.Mutex
+	held map[string]bool
+}
+
+func (l *memoryLocker) TryLock(_ context.Context, name string, _ time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held[name] {
+		return false, nil
+	}
+	l.held[name] = true
+	return true, nil
+}
+
+func (l *memoryLocker) Unlock(_ context.Context, name string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.held, name)
+	return nil
+}
+
+func TestSchedulerSkipsWhenLockHeldByAnotherReplica(t *testing.T) {
+	locker := &memoryLocker{held: make(map[string]bool)}
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var runs atomic.Int32
+	syncFn := func(context.Context) error {
+		if runs.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+		return nil
+	}
+	jobs := []job.Job{{Name: "sync", Spec: "0 7 * * *", Func: syncFn}}
+	replicaA := job.NewScheduler(jobs, nil, job.WithLocker(locker, time.Minute))
+	replicaB := job.NewScheduler(jobs, nil, job.WithLocker(locker, time.Minute))
+
+	done := make(chan error, 1)
+	go func() { done <- replicaA.Run("sync") }()
+	<-started
+	if err := replicaB.Run("sync"); !errors.Is(err, job.ErrLockHeld) {
+		t.Fatalf("expect replica B skipped while A holds the lock, got %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("replica A: %v", err)
+	}
+	if err := replicaB.Run("sync"); err != nil {
+		t.Fatalf("expect lock released after A finished, got %v", err)
+	}
+	if got := runs.Load(); got != 2 {
+		t.Fatalf("expect 2 runs, got %d", got)
+	}
+}
+
+func TestSchedulerRetriesOnlyTransientErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		err   error
+		calls int32
+	}{
+		{"transient", domain.MarkError(loader.ErrNeo4jUnavailable, errors.New("connection refused")), 3},
+		{"validation", domain.MarkError(domain.ErrValidation, errors.New("bad snapshot")), 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			s := job.NewScheduler([]job.Job{{Name: "sync", Spec: "@every 1h", Func: func(context.Context) error {
+				calls.Add(1)
+				return tc.err
+			}}}, nil, job.WithTransientRetry(3, time.Millisecond))
+			if err := s.Run("sync"); !errors.Is(err, tc.err) {
+				t.Fatalf("expect job error returned, got %v", err)
+			}
+			if calls.Load() != tc.calls {
+				t.Fatalf("expect %d runs, got %d", tc.calls, calls.Load())
+			}
+		})
+	}
+}
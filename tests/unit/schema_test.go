@@ -0,0 +1,105 @@
+package unit
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+
+	"cmdb2neo/internal/loader"
+)
+
+// recordingSchemaClient 记录执行的语句，existing 为 SHOW 查询返回的已有对象名。
+type recordingSchemaClient struct {
+	existing   []string
+	statements []string
+}
+
+func (c *recordingSchemaClient) RunRaw(_ context.Context, query string, _ map[string]any) error {
+	c.statements = append(c.statements, query)
+	return nil
+}
+
+func (c *recordingSchemaClient) RunRead(_ context.Context, query string, _ map[string]any) ([]map[string]any, error) {
+	if query != "SHOW CONSTRAINTS YIELD name" {
+		return nil, nil
+	}
+	records := make([]map[string]any, 0, len(c.existing))
+	for _, name := range c.existing {
+		records = append(records, map[string]any{"name": name})
+	}
+	return records, nil
+}
+
+func TestSchemaEnsureStatements(t *testing.T) {
+	client := &recordingSchemaClient{existing: []string{"idc_cmdb_key", "app_cmdb_key"}}
+	report, err := loader.NewSchemaManager(client).Ensure(context.Background())
+	if err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+
+	want := []string{
+		"CREATE CONSTRAINT idc_cmdb_key IF NOT EXISTS FOR (n:IDC) REQUIRE n.cmdb_key IS UNIQUE",
+		"CREATE CONSTRAINT np_cmdb_key IF NOT EXISTS FOR (n:NetPartition) REQUIRE n.cmdb_key IS UNIQUE",
+		"CREATE CONSTRAINT host_cmdb_key IF NOT EXISTS FOR (n:HostMachine) REQUIRE n.cmdb_key IS UNIQUE",
+		"CREATE CONSTRAINT physical_cmdb_key IF NOT EXISTS FOR (n:PhysicalMachine) REQUIRE n.cmdb_key IS UNIQUE",
+		"CREATE CONSTRAINT vm_cmdb_key IF NOT EXISTS FOR (n:VirtualMachine) REQUIRE n.cmdb_key IS UNIQUE",
+		"CREATE CONSTRAINT container_cmdb_key IF NOT EXISTS FOR (n:Container) REQUIRE n.cmdb_key IS UNIQUE",
+		"CREATE CONSTRAINT app_cmdb_key IF NOT EXISTS FOR (n:App) REQUIRE n.cmdb_key IS UNIQUE",
+		"CREATE CONSTRAINT service_cmdb_key IF NOT EXISTS FOR (n:Service) REQUIRE n.cmdb_key IS UNIQUE",
+		"CREATE CONSTRAINT rca_result_window_id IF NOT EXISTS FOR (n:RCAResult) REQUIRE n.window_id IS UNIQUE",
+		"CREATE CONSTRAINT sync_lock_name IF NOT EXISTS FOR (n:SyncLock) REQUIRE n.name IS UNIQUE",
+		"CREATE INDEX vm_host_ip IF NOT EXISTS FOR (n:VirtualMachine) ON (n.host_ip)",
+		"CREATE INDEX host_ip IF NOT EXISTS FOR (n:HostMachine) ON (n.ip)",
+		"CREATE INDEX physical_ip IF NOT EXISTS FOR (n:PhysicalMachine) ON (n.ip)",
+		"CREATE INDEX container_host_ip IF NOT EXISTS FOR (n:Container) ON (n.host_ip)",
+		"CREATE INDEX container_ip IF NOT EXISTS FOR (n:Container) ON (n.ip)",
+		"CREATE INDEX app_ip IF NOT EXISTS FOR (n:App) ON (n.ip)",
+		"CREATE INDEX vm_ip IF NOT EXISTS FOR (n:VirtualMachine) ON (n.ip)",
+		"CREATE INDEX host_hostname IF NOT EXISTS FOR (n:HostMachine) ON (n.hostname)",
+		"CREATE INDEX physical_hostname IF NOT EXISTS FOR (n:PhysicalMachine) ON (n.hostname)",
+		"CREATE INDEX vm_hostname IF NOT EXISTS FOR (n:VirtualMachine) ON (n.hostname)",
+		"CREATE INDEX container_hostname IF NOT EXISTS FOR (n:Container) ON (n.hostname)",
+		"CREATE INDEX app_name IF NOT EXISTS FOR (n:App) ON (n.name)",
+		"CREATE INDEX service_name IF NOT EXISTS FOR (n:Service) ON (n.name)",
+		"CREATE INDEX np_name IF NOT EXISTS FOR (n:NetPartition) ON (n.name)",
+		"CREATE INDEX idc_name IF NOT EXISTS FOR (n:IDC) ON (n.name)",
+	}
+	if !reflect.DeepEqual(client.statements, want) {
+		t.Fatalf("schema drift, got statements:\n%v", client.statements)
+	}
+	if !reflect.DeepEqual(report.Existing, []string{"idc_cmdb_key", "app_cmdb_key"}) || len(report.Created) != len(want)-2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestSchemaDropStatements(t *testing.T) {
+	client := &recordingSchemaClient{}
+	if err := loader.NewSchemaManager(client).Drop(context.Background()); err != nil {
+		t.Fatalf("drop: %v", err)
+	}
+	if len(client.statements) != len(loader.RequiredSchema) {
+		t.Fatalf("expect one drop per schema object, got %v", client.statements)
+	}
+	if client.statements[0] != "DROP CONSTRAINT idc_cmdb_key IF EXISTS" || client.statements[len(client.statements)-1] != "DROP INDEX idc_name IF EXISTS" {
+		t.Fatalf("unexpected drop statements: %v", client.statements)
+	}
+}
+
+func TestSchemaIndexesCoverRCALookups(t *testing.T) {
+	indexed := loader.NewSchemaManager(nil).IndexedProperties()
+	lookups := map[string]string{
+		"VirtualMachine":  "ip",
+		"HostMachine":     "ip",
+		"PhysicalMachine": "ip",
+		"App":             "name",
+		"NetPartition":    "name",
+		"IDC":             "name",
+		"Service":         "name",
+	}
+	for label, prop := range lookups {
+		if !slices.Contains(indexed[label], prop) {
+			t.Fatalf("expect index on %s.%s, got %v", label, prop, indexed[label])
+		}
+	}
+}
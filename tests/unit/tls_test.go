@@ -0,0 +1,213 @@
+package unit
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/cmdb"
+)
+
+// testPKI 为一套自签 CA 及其签发的服务端、客户端证书，PEM 文件写在临时目录下。
+type testPKI struct {
+	caPool     *x509.CertPool
+	serverCert tls.Certificate
+	caFile     string
+	certFile   string
+	keyFile    string
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	dir := t.TempDir()
+	caKey := mustKey(t)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "cmdb test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create ca: %v", err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage) ([]byte, *ecdsa.PrivateKey) {
+		key := mustKey(t)
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "cmdb2neo"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("issue cert: %v", err)
+		}
+		return der, key
+	}
+	serverDER, serverKey := issue(2, x509.ExtKeyUsageServerAuth)
+	clientDER, clientKey := issue(3, x509.ExtKeyUsageClientAuth)
+
+	pki := testPKI{
+		caPool:   pool,
+		caFile:   writePEM(t, dir, "ca.pem", "CERTIFICATE", caDER),
+		certFile: writePEM(t, dir, "client.pem", "CERTIFICATE", clientDER),
+		keyFile:  writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", marshalKey(t, clientKey)),
+	}
+	pki.serverCert = tls.Certificate{Certificate: [][]byte{serverDER}, PrivateKey: serverKey}
+	return pki
+}
+
+func mustKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func marshalKey(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	return der
+}
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+// newMTLSServer 启动要求客户端证书的 CMDB 假服务。
+func newMTLSServer(pki testPKI) *httptest.Server {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(cmdb.Request{})
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{pki.serverCert}, ClientCAs: pki.caPool, ClientAuth: tls.RequireAndVerifyClientCert}
+	// 被拒绝的握手是预期行为，不输出到测试日志
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	return srv
+}
+
+func TestHTTPClientMutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	srv := newMTLSServer(pki)
+	defer srv.Close()
+
+	client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: testIDCs,
+		TLS: cmdb.TLSConfig{CertFile: pki.certFile, KeyFile: pki.keyFile, CAFile: pki.caFile}})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := client.FetchSnapshot(context.Background()); err != nil {
+		t.Fatalf("expect mTLS fetch to succeed, got %v", err)
+	}
+
+	// 只信任 CA 而不出示客户端证书时服务端拒绝握手
+	anonymous, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: testIDCs, TLS: cmdb.TLSConfig{CAFile: pki.caFile}})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := anonymous.FetchSnapshot(context.Background()); err == nil {
+		t.Fatalf("expect handshake without client cert to fail")
+	}
+}
+
+func TestHTTPClientInsecureSkipVerify(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(cmdb.Request{})
+	}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	strict, _ := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: testIDCs})
+	if _, err := strict.FetchSnapshot(context.Background()); err == nil {
+		t.Fatalf("expect self-signed server to be rejected by default")
+	}
+	insecure, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: testIDCs, TLS: cmdb.TLSConfig{InsecureSkipVerify: true}})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	if _, err := insecure.FetchSnapshot(context.Background()); err != nil {
+		t.Fatalf("expect insecure client to accept self-signed server, got %v", err)
+	}
+}
+
+func TestHTTPClientRejectsInvalidTLSFiles(t *testing.T) {
+	pki := newTestPKI(t)
+	notPEM := filepath.Join(t.TempDir(), "bundle.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cases := []struct {
+		name string
+		tls  cmdb.TLSConfig
+		want string
+	}{
+		{"missing key", cmdb.TLSConfig{CertFile: pki.certFile}, "同时配置"},
+		{"missing cert file", cmdb.TLSConfig{CertFile: filepath.Join(t.TempDir(), "absent.pem"), KeyFile: pki.keyFile}, "客户端证书"},
+		{"mismatched pair", cmdb.TLSConfig{CertFile: pki.caFile, KeyFile: pki.keyFile}, "客户端证书"},
+		{"missing ca", cmdb.TLSConfig{CAFile: filepath.Join(t.TempDir(), "absent.pem")}, "CA"},
+		{"ca without pem", cmdb.TLSConfig{CAFile: notPEM}, "CA"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: "https://cmdb.example", IDCs: testIDCs, TLS: tc.tls})
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expect error mentioning %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestTokenSourceUsesTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "abc", "expires_in": 3600})
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{pki.serverCert}, ClientCAs: pki.caPool, ClientAuth: tls.RequireAndVerifyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	ts, err := cmdb.NewClientCredentialsTokenSource(cmdb.ClientCredentialsConfig{Endpoint: srv.URL, ClientID: "svc", ClientSecret: "s",
+		TLS: cmdb.TLSConfig{CertFile: pki.certFile, KeyFile: pki.keyFile, CAFile: pki.caFile}})
+	if err != nil {
+		t.Fatalf("new token source: %v", err)
+	}
+	if token, err := ts.Token(context.Background()); err != nil || token != "abc" {
+		t.Fatalf("expect token over mTLS, got %q (%v)", token, err)
+	}
+}
@@ -0,0 +1,314 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cmdb2neo/internal/cmdb"
+)
+
+// fakeAuthServer 按调用次序签发 token-1、token-2 …，respond 可定制有效期相关的字段与响应头。
+func fakeAuthServer(calls *atomic.Int32, delay time.Duration, respond func(w http.ResponseWriter) map[string]any) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		time.Sleep(delay)
+		body := map[string]any{}
+		if respond != nil {
+			body = respond(w)
+		}
+		body["access_token"] = fmt.Sprintf("token-%d", n)
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+}
+
+func newTokenSource(t *testing.T, endpoint string) *cmdb.PasswordTokenSource {
+	t.Helper()
+	ts, err := cmdb.NewPasswordTokenSource(cmdb.PasswordTokenConfig{Endpoint: endpoint, Username: "u", Password: "p"})
+	if err != nil {
+		t.Fatalf("new token source: %v", err)
+	}
+	return ts
+}
+
+// waitForToken 轮询直到 Token 返回 want，用于等待后台刷新完成。
+func waitForToken(t *testing.T, ts *cmdb.PasswordTokenSource, want string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		token, err := ts.Token(context.Background())
+		if err == nil && token == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expect %s after background refresh, got %q (%v)", want, token, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPasswordTokenSourceSharesInflightRefresh(t *testing.T) {
+	var calls atomic.Int32
+	srv := fakeAuthServer(&calls, 50*time.Millisecond, func(http.ResponseWriter) map[string]any {
+		return map[string]any{"expires_in": 3600}
+	})
+	defer srv.Close()
+	ts := newTokenSource(t, srv.URL)
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 32)
+	errs := make([]error, len(tokens))
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], errs[i] = ts.Token(context.Background())
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range tokens {
+		if errs[i] != nil || tokens[i] != "token-1" {
+			t.Fatalf("caller %d: expect shared token-1, got %q (%v)", i, tokens[i], errs[i])
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expect concurrent callers to share one refresh, got %d", got)
+	}
+}
+
+func TestPasswordTokenSourceWaiterHonorsCancellation(t *testing.T) {
+	var calls atomic.Int32
+	srv := fakeAuthServer(&calls, 200*time.Millisecond, nil)
+	defer srv.Close()
+	ts := newTokenSource(t, srv.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := ts.Token(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expect waiter to give up on its own deadline, got %v", err)
+	}
+	// 取消只影响等待方，刷新完成后其他调用方直接复用结果
+	if token, err := ts.Token(context.Background()); err != nil || token != "token-1" {
+		t.Fatalf("expect refresh to outlive the cancelled caller, got %q (%v)", token, err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expect a single refresh, got %d", got)
+	}
+}
+
+// 注入的 http.Client 没有超时，刷新仍按配置的 Timeout 结束，认证接口挂起时不会拖住全部调用方。
+func TestPasswordTokenSourceRefreshHonorsTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { <-release }))
+	defer srv.Close()
+	defer close(release)
+	ts, err := cmdb.NewPasswordTokenSource(cmdb.PasswordTokenConfig{
+		Endpoint:   srv.URL,
+		Username:   "u",
+		Password:   "p",
+		Timeout:    50 * time.Millisecond,
+		HTTPClient: &http.Client{},
+	})
+	if err != nil {
+		t.Fatalf("new token source: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ts.Token(context.Background())
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expect refresh to hit its deadline, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expect refresh to give up after the configured timeout")
+	}
+}
+
+func TestPasswordTokenSourceRefreshesProactively(t *testing.T) {
+	var calls atomic.Int32
+	srv := fakeAuthServer(&calls, 0, func(http.ResponseWriter) map[string]any {
+		// 有效期短于默认 2 分钟的提前刷新窗口，窗口收紧为有效期的一半
+		return map[string]any{"expires_in": 1}
+	})
+	defer srv.Close()
+	ts := newTokenSource(t, srv.URL)
+
+	if token, err := ts.Token(context.Background()); err != nil || token != "token-1" {
+		t.Fatalf("first token: %q (%v)", token, err)
+	}
+	if token, err := ts.Token(context.Background()); err != nil || token != "token-1" || calls.Load() != 1 {
+		t.Fatalf("expect fresh short-lived token reused without refresh, got %q (%v), %d calls", token, err, calls.Load())
+	}
+	waitForToken(t, ts, "token-2")
+}
+
+func TestPasswordTokenSourceExpiryFromHeaders(t *testing.T) {
+	cases := []struct {
+		name    string
+		header  func(h http.Header)
+		refresh bool
+	}{
+		{"default ttl", func(http.Header) {}, false},
+		{"long max-age", func(h http.Header) { h.Set("Cache-Control", "private, max-age=3600") }, false},
+		{"short max-age", func(h http.Header) { h.Set("Cache-Control", "max-age=1") }, true},
+		{"short expires", func(h http.Header) { h.Set("Expires", time.Now().Add(2*time.Second).UTC().Format(http.TimeFormat)) }, true},
+		{"max-age wins over expires", func(h http.Header) {
+			h.Set("Cache-Control", "max-age=3600")
+			h.Set("Expires", time.Now().Add(2*time.Second).UTC().Format(http.TimeFormat))
+		}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := fakeAuthServer(&calls, 0, func(w http.ResponseWriter) map[string]any {
+				tc.header(w.Header())
+				return map[string]any{}
+			})
+			defer srv.Close()
+			ts := newTokenSource(t, srv.URL)
+
+			if _, err := ts.Token(context.Background()); err != nil {
+				t.Fatalf("first token: %v", err)
+			}
+			if _, err := ts.Token(context.Background()); err != nil {
+				t.Fatalf("second token: %v", err)
+			}
+			if tc.refresh {
+				waitForToken(t, ts, "token-2")
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+			if got := calls.Load(); got != 1 {
+				t.Fatalf("expect cached token without refresh, got %d calls", got)
+			}
+		})
+	}
+}
+
+func TestPasswordTokenSourceBacksOffAfterFailedRefresh(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	ts := newTokenSource(t, srv.URL)
+
+	for i := 0; i < 5; i++ {
+		if _, err := ts.Token(context.Background()); !errors.Is(err, cmdb.ErrCMDBUnavailable) {
+			t.Fatalf("call %d: expect unavailable error, got %v", i, err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expect callers within the backoff to reuse the last error, got %d auth requests", got)
+	}
+}
+
+func TestTokenSourcesRequestBodies(t *testing.T) {
+	type captured struct {
+		contentType string
+		json        map[string]string
+		form        map[string]string
+	}
+	var mu sync.Mutex
+	var got captured
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = captured{contentType: r.Header.Get("Content-Type")}
+		if got.contentType == "application/json" {
+			_ = json.NewDecoder(r.Body).Decode(&got.json)
+		} else if err := r.ParseForm(); err == nil {
+			got.form = map[string]string{}
+			for key := range r.PostForm {
+				got.form[key] = r.PostForm.Get(key)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "abc", "expires_in": 3600})
+	}))
+	defer srv.Close()
+
+	password := newTokenSource(t, srv.URL)
+	if _, err := password.Token(context.Background()); err != nil {
+		t.Fatalf("password token: %v", err)
+	}
+	mu.Lock()
+	if got.json["username"] != "u" || got.json["password"] != "p" {
+		t.Fatalf("expect JSON credentials, got %+v", got)
+	}
+	mu.Unlock()
+
+	clientCreds, err := cmdb.NewClientCredentialsTokenSource(cmdb.ClientCredentialsConfig{Endpoint: srv.URL, ClientID: "svc", ClientSecret: "s3cret", Scope: "cmdb.read"})
+	if err != nil {
+		t.Fatalf("new client credentials source: %v", err)
+	}
+	if token, err := clientCreds.Token(context.Background()); err != nil || token != "abc" {
+		t.Fatalf("client credentials token: %q (%v)", token, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string]string{"grant_type": "client_credentials", "client_id": "svc", "client_secret": "s3cret", "scope": "cmdb.read"}
+	if got.contentType != "application/x-www-form-urlencoded" || fmt.Sprint(got.form) != fmt.Sprint(want) {
+		t.Fatalf("expect form-encoded client credentials %v, got %+v", want, got)
+	}
+}
+
+func TestClientCredentialsTokenSourceRequiresSecret(t *testing.T) {
+	if _, err := cmdb.NewClientCredentialsTokenSource(cmdb.ClientCredentialsConfig{Endpoint: "http://auth", ClientID: "svc"}); err == nil {
+		t.Fatalf("expect missing client_secret to be rejected")
+	}
+}
+
+func TestHTTPClientAuthHeaderFormats(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+		scheme string
+		want   map[string]string
+	}{
+		{"default bearer", "", "", map[string]string{"Authorization": "Bearer abc"}},
+		{"custom scheme", "", "Token", map[string]string{"Authorization": "Token abc"}},
+		{"bare token in custom header", "X-Auth-Token", cmdb.AuthSchemeNone, map[string]string{"X-Auth-Token": "abc", "Authorization": ""}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			seen := map[string]string{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				for name := range tc.want {
+					seen[name] = r.Header.Get(name)
+				}
+				mu.Unlock()
+				_ = json.NewEncoder(w).Encode(cmdb.Request{})
+			}))
+			defer srv.Close()
+
+			client, err := cmdb.NewHTTPClient(cmdb.HTTPConfig{BaseURL: srv.URL, IDCs: testIDCs, TokenSource: &cmdb.StaticTokenSource{Value: "abc"},
+				AuthHeaderName: tc.header, AuthScheme: tc.scheme})
+			if err != nil {
+				t.Fatalf("new client: %v", err)
+			}
+			if _, err := client.FetchSnapshot(context.Background()); err != nil {
+				t.Fatalf("fetch: %v", err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if fmt.Sprint(seen) != fmt.Sprint(tc.want) {
+				t.Fatalf("expect headers %v, got %v", tc.want, seen)
+			}
+		})
+	}
+}
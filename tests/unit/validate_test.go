@@ -0,0 +1,74 @@
+package unit
+
+import (
+	"testing"
+
+	"cmdb2neo/internal/cmdb"
+)
+
+func TestValidateSnapshotReportsDanglingReferences(t *testing.T) {
+	snapshot := cmdb.Snapshot{
+		IDCs:              []cmdb.IDC{{Id: 1, Name: "M5"}},
+		NetworkPartitions: []cmdb.NetworkPartition{{Id: 10, Idc: "M5"}, {Id: 11, Idc: "M9"}},
+		HostMachines:      []cmdb.HostMachine{{Id: 100, NetworkPartion: "10", Ip: "10.0.0.10"}, {Id: 101, NetworkPartion: "99", Ip: "10.0.0.11"}},
+		VirtualMachines:   []cmdb.VirtualMachine{{Id: 300, NetworkPartion: "10", Ip: "10.0.0.12", HostIp: "10.0.0.10"}, {Id: 301, Ip: "10.0.0.13", HostIp: "10.9.9.9"}},
+		Apps:              []cmdb.App{{Id: 400, Ip: "10.0.0.12"}, {Id: 401, Ip: "10.0.0.10", ServerType: "2"}, {Id: 402}},
+	}
+
+	issues := cmdb.ValidateSnapshot(snapshot)
+	got := make(map[string]cmdb.ValidationIssue, len(issues))
+	for _, issue := range issues {
+		got[issue.Kind+"|"+issue.Key] = issue
+	}
+	want := map[string]struct {
+		severity cmdb.Severity
+		ref      string
+	}{
+		cmdb.IssueNPIDC + "|NP_11":         {cmdb.SeverityWarning, "M9"},
+		cmdb.IssueMachineNP + "|HM_101":    {cmdb.SeverityWarning, "99"},
+		cmdb.IssueVMHost + "|VM_301":       {cmdb.SeverityError, "10.9.9.9"},
+		cmdb.IssueAppDeployed + "|APP_401": {cmdb.SeverityError, "10.0.0.10"},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("expect %d issues, got %+v", len(want), issues)
+	}
+	for key, w := range want {
+		issue, ok := got[key]
+		if !ok {
+			t.Fatalf("missing issue %s in %+v", key, issues)
+		}
+		if issue.Severity != w.severity || issue.Ref != w.ref || issue.Message == "" {
+			t.Fatalf("unexpected issue %s: %+v", key, issue)
+		}
+	}
+
+	counts := cmdb.CountIssues(issues)
+	if counts[cmdb.SeverityError] != 2 || counts[cmdb.SeverityWarning] != 2 {
+		t.Fatalf("unexpected counts %v", counts)
+	}
+}
+
+func TestValidateSnapshotMatchesEquivalentIPv6(t *testing.T) {
+	issues := cmdb.ValidateSnapshot(cmdb.Snapshot{
+		HostMachines:    []cmdb.HostMachine{{Id: 100, Ip: "2001:DB8:0:0:0:0:0:A"}},
+		VirtualMachines: []cmdb.VirtualMachine{{Id: 300, Ip: "2001:db8::c", HostIp: "2001:db8::a"}},
+		Apps:            []cmdb.App{{Id: 400, Ip: "2001:DB8:0::C", ServerType: "2"}},
+	})
+	if len(issues) != 0 {
+		t.Fatalf("expect equivalent IPv6 spellings to match, got %+v", issues)
+	}
+}
+
+func TestValidateSnapshotAcceptsVMOnPhysicalMachine(t *testing.T) {
+	snapshot := cmdb.Snapshot{
+		PhysicalMachines: []cmdb.PhysicalMachine{{Id: 200, Ip: "10.0.0.20"}},
+		VirtualMachines:  []cmdb.VirtualMachine{{Id: 300, Ip: "10.0.0.12", HostIp: "10.0.0.20"}},
+	}
+	if issues := cmdb.ValidateSnapshot(snapshot); len(issues) != 0 {
+		t.Fatalf("expect vm hosted on a physical machine to validate, got %+v", issues)
+	}
+	nodes, rels := cmdb.BuildInitRows(snapshot)
+	if len(nodes) != 2 || len(rels) != 1 || rels[0].StartKey != "PM_200" || rels[0].EndKey != "VM_300" {
+		t.Fatalf("expect mapper to link the vm to the physical machine, got %+v", rels)
+	}
+}
@@ -5,6 +5,9 @@ package main
 import (
 	"context"
 
+	"cmdb2neo/internal/graph"
+	"cmdb2neo/internal/metrics"
+	"cmdb2neo/internal/rca"
 	"cmdb2neo/ioc"
 	"cmdb2neo/pkg/server"
 	"github.com/google/wire"
@@ -13,15 +16,31 @@ import (
 func InitApp(ctx context.Context) (*server.HTTPServer, func(), error) {
 	panic(wire.Build(
 		ioc.InitConfig,
+		ioc.InitSchema,
 		ioc.InitLogger,
+		ioc.InitMetrics,
+		ioc.InitTracerProvider,
+		wire.Bind(new(metrics.Recorder), new(*metrics.Prometheus)),
 		ioc.InitCMDBClient,
+		ioc.InitBookmarkManager,
 		ioc.InitAppService,
 		ioc.InitGraphClient,
 		ioc.InitRCAConfig,
+		ioc.InitPartitionRefresher,
 		ioc.InitRCAProvider,
+		ioc.InitRCAResultStore,
+		wire.Bind(new(rca.ResultStore), new(*rca.GraphResultStore)),
+		wire.Bind(new(rca.ResultReader), new(*rca.GraphResultStore)),
+		wire.Bind(new(graph.ReadWriter), new(*graph.Client)),
 		ioc.InitRCAAnalyzer,
 		ioc.InitRCAHandler,
+		ioc.InitSyncHandler,
+		ioc.InitTopologyHandler,
+		ioc.InitAppHandler,
+		ioc.InitNodesHandler,
 		ioc.InitGinEngine,
+		ioc.InitConnectionMonitors,
+		ioc.InitHealthHandler,
 		ioc.InitScheduler,
 		ioc.InitHourlyLogger,
 		server.NewHTTPServer,
@@ -15,25 +15,32 @@ func InitApp(ctx context.Context) (*server.HTTPServer, func(), error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	logger, err := ioc.InitLogger()
+	schema, err := ioc.InitSchema(cfg)
 	if err != nil {
 		return nil, nil, err
 	}
-	cmdbClient, err := ioc.InitCMDBClient(cfg)
+	logger, err := ioc.InitLogger(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	prometheus := ioc.InitMetrics()
+	tracerProvider := ioc.InitTracerProvider()
+	cmdbClient, err := ioc.InitCMDBClient(cfg, logger, tracerProvider)
 	if err != nil {
 		if logger != nil {
 			_ = logger.Sync()
 		}
 		return nil, nil, err
 	}
-	appService, err := ioc.InitAppService(ctx, cfg, cmdbClient)
+	bookmarkManager := ioc.InitBookmarkManager(cfg)
+	appService, err := ioc.InitAppService(ctx, cfg, cmdbClient, prometheus, tracerProvider, bookmarkManager)
 	if err != nil {
 		if logger != nil {
 			_ = logger.Sync()
 		}
 		return nil, nil, err
 	}
-	graphClient, err := ioc.InitGraphClient(ctx, cfg)
+	graphClient, err := ioc.InitGraphClient(ctx, cfg, prometheus, tracerProvider, bookmarkManager)
 	if err != nil {
 		if appService != nil {
 			_ = appService.Close(ctx)
@@ -44,8 +51,10 @@ func InitApp(ctx context.Context) (*server.HTTPServer, func(), error) {
 		return nil, nil, err
 	}
 	rcaConfig := ioc.InitRCAConfig()
-	provider := ioc.InitRCAProvider(graphClient)
-	analyzer, err := ioc.InitRCAAnalyzer(provider, rcaConfig)
+	partitionRefresher := ioc.InitPartitionRefresher(cfg, graphClient, schema, logger)
+	provider := ioc.InitRCAProvider(cfg, graphClient, schema, partitionRefresher, prometheus, logger, tracerProvider)
+	resultStore := ioc.InitRCAResultStore(graphClient)
+	analyzer, err := ioc.InitRCAAnalyzer(provider, rcaConfig, resultStore, prometheus, tracerProvider, logger)
 	if err != nil {
 		_ = graphClient.Close(ctx)
 		if appService != nil {
@@ -56,11 +65,17 @@ func InitApp(ctx context.Context) (*server.HTTPServer, func(), error) {
 		}
 		return nil, nil, err
 	}
-	rcaHandler := ioc.InitRCAHandler(analyzer, logger)
-	engine := ioc.InitGinEngine(rcaHandler)
+	rcaHandler := ioc.InitRCAHandler(analyzer, provider, resultStore, cfg, logger, tracerProvider)
+	syncHandler := ioc.InitSyncHandler(appService, logger)
+	topologyHandler := ioc.InitTopologyHandler(graphClient, schema, cfg, logger)
+	appHandler := ioc.InitAppHandler(graphClient, schema, logger)
+	nodesHandler := ioc.InitNodesHandler(graphClient, schema, logger)
+	engine := ioc.InitGinEngine(rcaHandler, syncHandler, topologyHandler, appHandler, nodesHandler, prometheus, cfg, logger)
 	scheduler := ioc.InitScheduler(cfg, appService, logger)
 	hourlyLogger := ioc.InitHourlyLogger(logger)
-	httpServer := server.NewHTTPServer(engine, logger, cfg, appService, scheduler, hourlyLogger)
+	connectionMonitors := ioc.InitConnectionMonitors(cfg, graphClient, appService, prometheus, logger)
+	healthHandler := ioc.InitHealthHandler(graphClient, cfg, connectionMonitors)
+	httpServer := server.NewHTTPServer(engine, logger, cfg, appService, scheduler, hourlyLogger, healthHandler, connectionMonitors, partitionRefresher, rcaHandler)
 	cleanup := func() {
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()